@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// createdReposPath records every ADO repository creation the tool attempts
+// and completes, one JSON object per line. ADO repositories carry no
+// creation time, so this is how a rerun recognizes a repository an earlier
+// run created but did not finish with.
+const createdReposPath = "runs/created-repos.jsonl"
+
+var createdReposMu sync.Mutex
+
+// createdRepo is one line of createdReposPath: a creation attempt
+// ("creating"), its outcome ("created", with the repository's id and URL,
+// or "failed"), or the adoption of an existing repository ("adopted").
+type createdRepo struct {
+	Time      string `json:"time"`
+	Run       string `json:"run,omitempty"`
+	Event     string `json:"event"`
+	Org       string `json:"org"`
+	Project   string `json:"project"`
+	Name      string `json:"name"`
+	ID        string `json:"id,omitempty"`
+	RemoteURL string `json:"remote_url,omitempty"`
+}
+
+// recordCreatedRepo appends e to createdReposPath, rewriting it whole like
+// the audit log so a crash cannot leave a torn line.
+func recordCreatedRepo(e createdRepo) error {
+	e.Time = fileTimestamp(time.Now())
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	createdReposMu.Lock()
+	defer createdReposMu.Unlock()
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return err
+	}
+	log, err := readFileRecover(createdReposPath, validateJSONLines)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		damaged, _ := os.ReadFile(createdReposPath)
+		log = salvageJSONLines(damaged)
+	}
+	return writeFileAtomic(createdReposPath, append(append(log, data...), '\n'), 0644)
+}
+
+// record notes a creation event of the run in createdReposPath. Failing to
+// record is only a warning; it only weakens recognition on a rerun.
+func (t *azureTarget) record(e createdRepo) {
+	e.Run, e.Org, e.Project = t.runID, t.org, t.project
+	if err := recordCreatedRepo(e); err != nil {
+		t.log(fmt.Sprintf("Warning: could not record %s of %s in %s: %v", e.Event, e.Name, createdReposPath, err))
+	}
+}
+
+// knownCreation returns the earliest record of another run than run having
+// created name in the project: a completed or adopted creation, or an
+// attempt without a recorded failure, which is what a crash right after
+// the create request leaves.
+func knownCreation(org, project, name, run string) (*createdRepo, error) {
+	createdReposMu.Lock()
+	data, err := readFileRecover(createdReposPath, validateJSONLines)
+	createdReposMu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var events []createdRepo
+	failed := map[string]bool{} // runs whose attempt failed
+	for _, line := range strings.Split(string(data), "\n") {
+		var e createdRepo
+		if json.Unmarshal([]byte(line), &e) != nil {
+			continue
+		}
+		if e.Run == run || !strings.EqualFold(e.Org, org) || !strings.EqualFold(e.Project, project) || !strings.EqualFold(e.Name, name) {
+			continue
+		}
+		if e.Event == "failed" {
+			failed[e.Run] = true
+		}
+		events = append(events, e)
+	}
+	for i, e := range events {
+		if e.Event == "created" || e.Event == "adopted" || (e.Event == "creating" && !failed[e.Run]) {
+			return &events[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// azureRepoEmpty reports whether the repository has no refs.
+func azureRepoEmpty(ctx context.Context, org, project, repoID, token string) (bool, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/refs?$top=1&api-version=7.0", org, url.PathEscape(project), repoID)
+	var result struct {
+		Value []struct {
+			Name string `json:"name"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return false, err
+	}
+	return len(result.Value) == 0, nil
+}
+
+// adoptExisting decides whether the live repository name, whose creation
+// conflicted, is one to carry on with: one a run of this tool created
+// (recorded in createdReposPath), or an empty one. Anything else is a
+// foreign repository and conflictErr is returned. On adoption it returns
+// the push URL.
+func (t *azureTarget) adoptExisting(ctx context.Context, name string, conflictErr error) (string, error) {
+	existing, err := getAzureRepo(ctx, t.org, t.project, name, t.token)
+	if err != nil {
+		return "", fmt.Errorf("%v (could not look up the existing repository: %v)", conflictErr, err)
+	}
+	empty, err := azureRepoEmpty(ctx, t.org, t.project, existing.ID, t.token)
+	if err != nil {
+		return "", fmt.Errorf("%v (could not check whether the existing repository is empty: %v)", conflictErr, err)
+	}
+	known, err := knownCreation(t.org, t.project, name, t.runID)
+	if err != nil {
+		t.log(fmt.Sprintf("Warning: could not read %s: %v", createdReposPath, err))
+	}
+
+	switch {
+	case known != nil:
+		how := known.Event
+		if how == "creating" {
+			how = "created" // the run stopped before recording the outcome
+		}
+		t.log(fmt.Sprintf("Adopted existing repo %s %s at %s by run %s (empty: %t).", existing.Name, how, known.Time, orDefault(known.Run, "unknown"), empty))
+	case empty:
+		t.log(fmt.Sprintf("Adopted existing repo %s: it is empty, created outside any recorded run.", existing.Name))
+	default:
+		return "", fmt.Errorf("a repository named %s already exists, has content and was not created by this tool: %v", name, conflictErr)
+	}
+	t.record(createdRepo{Event: "adopted", Name: existing.Name, ID: existing.ID, RemoteURL: existing.RemoteURL})
+	return authRemoteURL(ctx, existing.RemoteURL, t.token), nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// largeBlobSize is the size above which a blob is reported as large;
+// GitHub warns at 50 MB and some targets reject much larger pushes.
+const largeBlobSize = 50 << 20
+
+// notScannedExcluded marks deep analysis fields of a repository excluded
+// from the scan, so they do not read as "nothing found".
+const notScannedExcluded = "not scanned (excluded)"
+
+// repoAnalysis is the result of the deep scan of one repository's history.
+type repoAnalysis struct {
+	Excluded    bool
+	LargeBlobs  int   // blobs over largeBlobSize anywhere in history
+	LargestBlob int64 // bytes
+	LFS         bool  // the default branch routes paths through LFS
+	Submodules  int   // submodules on the default branch
+	Bytes       int64 // the size of the clone
+	Error       string
+}
+
+// analyzeRepo clones source (owner/name) and scans its history for large
+// blobs, LFS attributes and submodules.
+func analyzeRepo(ctx context.Context, source, token string) repoAnalysis {
+	var a repoAnalysis
+	dir, err := os.MkdirTemp("", "analyze-")
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer os.RemoveAll(dir)
+
+	cloneURL := fmt.Sprintf("https://%s@github.com/%s.git", token, source)
+	if output, err := runGit(ctx, nil, "clone", "--bare", cloneURL, dir); err != nil {
+		a.Error = fmt.Sprintf("clone failed: %v: %s", err, strings.ReplaceAll(lastLine(output), token, "***"))
+		return a
+	}
+	a.Bytes = dirSize(dir)
+
+	out, err := runGit(ctx, nil, "-C", dir, "cat-file", "--batch-all-objects", "--batch-check=%(objecttype) %(objectsize)")
+	if err != nil {
+		a.Error = fmt.Sprintf("listing objects: %v", err)
+		return a
+	}
+	for _, line := range strings.Split(out, "\n") {
+		kind, size, ok := strings.Cut(line, " ")
+		if !ok || kind != "blob" {
+			continue
+		}
+		n, _ := strconv.ParseInt(size, 10, 64)
+		if n > largeBlobSize {
+			a.LargeBlobs++
+		}
+		if n > a.LargestBlob {
+			a.LargestBlob = n
+		}
+	}
+
+	a.LFS = repoUsesLFS(ctx, dir)
+	if modules, err := runGit(ctx, nil, "-C", dir, "show", "HEAD:.gitmodules"); err == nil {
+		a.Submodules = strings.Count(modules, "[submodule ")
+	}
+	return a
+}
+
+// largeBlobsText, lfsText and submodulesText render the fields for the plan.
+func (a *repoAnalysis) largeBlobsText() string {
+	switch {
+	case a.Excluded:
+		return notScannedExcluded
+	case a.Error != "":
+		return "scan failed"
+	case a.LargeBlobs == 0:
+		return "none"
+	}
+	return fmt.Sprintf("%d (largest %s)", a.LargeBlobs, formatBytes(a.LargestBlob))
+}
+
+func (a *repoAnalysis) lfsText() string {
+	switch {
+	case a.Excluded:
+		return notScannedExcluded
+	case a.Error != "":
+		return "scan failed"
+	case a.LFS:
+		return "yes"
+	}
+	return "no"
+}
+
+func (a *repoAnalysis) submodulesText() string {
+	switch {
+	case a.Excluded:
+		return notScannedExcluded
+	case a.Error != "":
+		return "scan failed"
+	}
+	return strconv.Itoa(a.Submodules)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// apiError is a REST API response with an unexpected status.
+type apiError struct {
+	Service    string // "Azure", "GitHub", "Gitea", "GitLab"
+	StatusCode int
+	Status     string
+	RequestID  string // the provider's request id, if it sent one
+	// RateLimitReset is when a rate limit the request hit resets; zero if
+	// the request was not rate limited.
+	RateLimitReset time.Time
+}
+
+func (e *apiError) Error() string {
+	msg := e.Service + " API error: " + e.Status
+	switch {
+	case e.RateLimited():
+		msg += ": rate limited, retry after " + e.RateLimitReset.Local().Format("15:04:05")
+	case e.StatusCode == http.StatusUnauthorized:
+		msg += ": the token is invalid or expired"
+	}
+	if e.RequestID != "" {
+		msg += " (request id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// RateLimited reports whether the request was refused by a rate limit.
+func (e *apiError) RateLimited() bool {
+	return !e.RateLimitReset.IsZero()
+}
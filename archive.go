@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// archiveMode is how a repository that is archived on GitHub is made
+// read-only in Azure DevOps.
+type archiveMode int
+
+const (
+	// archiveLeaveWritable leaves the ADO repository as it is.
+	archiveLeaveWritable archiveMode = iota
+	// archiveDisable sets isDisabled. Disabled repositories cannot be
+	// browsed or cloned.
+	archiveDisable
+	// archiveDenyPush denies pushing to Project Valid Users, which keeps the
+	// repository browsable.
+	archiveDenyPush
+)
+
+// archiveModeNames are the UI labels for each archiveMode, in order.
+var archiveModeNames = []string{
+	"Leave writable",
+	"Disable repository",
+	"Deny pushes (stays browsable)",
+}
+
+// gitSecurityNamespace is the ID of the Azure DevOps "Git Repositories"
+// security namespace.
+const gitSecurityNamespace = "2e9eb7ed-3c0a-47d4-87c1-0ffdd275fd87"
+
+// denyPushBits are the Git Repositories permissions denied for
+// archiveDenyPush: Contribute, Force push, Create branch and Create tag.
+const denyPushBits = 4 | 8 | 16 | 32
+
+// vsspsURL maps an organization URL (https://dev.azure.com/org) to its
+// identity service URL (https://vssps.dev.azure.com/org).
+func vsspsURL(org string) string {
+	return strings.Replace(org, "://dev.azure.com/", "://vssps.dev.azure.com/", 1)
+}
+
+// projectValidUsers returns the identity descriptor of the project's
+// "Project Valid Users" group, which covers everyone with project access.
+func projectValidUsers(ctx context.Context, org, project, token string) (string, error) {
+	filter := fmt.Sprintf(`[%s]\Project Valid Users`, project)
+	apiURL := fmt.Sprintf("%s/_apis/identities?searchFilter=General&filterValue=%s&queryMembership=None&api-version=7.0", vsspsURL(org), url.QueryEscape(filter))
+	var result struct {
+		Value []struct {
+			Descriptor string `json:"descriptor"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return "", err
+	}
+	if len(result.Value) == 0 {
+		return "", fmt.Errorf("group %s not found", filter)
+	}
+	return result.Value[0].Descriptor, nil
+}
+
+// repoSecurityToken is the Git Repositories security token for a repository.
+func repoSecurityToken(repo *azureRepo) string {
+	return fmt.Sprintf("repoV2/%s/%s", repo.Project.ID, repo.ID)
+}
+
+// setAzureRepoDisabled sets or clears isDisabled on a repository.
+func setAzureRepoDisabled(ctx context.Context, org, project, repoID string, disabled bool, token string) error {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s?api-version=7.0", org, url.PathEscape(project), repoID)
+	payload := map[string]interface{}{
+		"isDisabled": disabled,
+	}
+	return azureRequest(ctx, "PATCH", apiURL, token, payload, http.StatusOK, nil)
+}
+
+// applyArchivedState makes the ADO copy of an archived GitHub repository
+// read-only according to mode and returns a description of the state that
+// was applied.
+func applyArchivedState(ctx context.Context, org, project, repoName, token string, mode archiveMode) (string, error) {
+	if mode == archiveLeaveWritable {
+		return "left writable", nil
+	}
+	repo, err := getAzureRepo(ctx, org, project, repoName, token)
+	if err != nil {
+		return "", fmt.Errorf("looking up Azure repo: %v", err)
+	}
+
+	if mode == archiveDisable {
+		if err := setAzureRepoDisabled(ctx, org, project, repo.ID, true, token); err != nil {
+			return "", fmt.Errorf("disabling repo: %v", err)
+		}
+		return "disabled", nil
+	}
+
+	descriptor, err := projectValidUsers(ctx, org, project, token)
+	if err != nil {
+		return "", fmt.Errorf("resolving Project Valid Users: %v", err)
+	}
+	apiURL := fmt.Sprintf("%s/_apis/accesscontrolentries/%s?api-version=7.0", org, gitSecurityNamespace)
+	payload := map[string]interface{}{
+		"token": repoSecurityToken(repo),
+		"merge": true,
+		"accessControlEntries": []map[string]interface{}{
+			{"descriptor": descriptor, "allow": 0, "deny": denyPushBits},
+		},
+	}
+	if err := azureRequest(ctx, "POST", apiURL, token, payload, http.StatusOK, nil); err != nil {
+		return "", fmt.Errorf("denying pushes: %v", err)
+	}
+	return "pushes denied", nil
+}
+
+// releaseArchivedState undoes applyArchivedState for a repository that is
+// being brought back into use: it re-enables the repository and removes the
+// deny-push entry, whichever of the two was applied.
+func releaseArchivedState(ctx context.Context, org, project, repoName, token string) error {
+	repo, err := getAzureRepo(ctx, org, project, repoName, token)
+	if err != nil {
+		return fmt.Errorf("looking up Azure repo: %v", err)
+	}
+	if repo.IsDisabled {
+		if err := setAzureRepoDisabled(ctx, org, project, repo.ID, false, token); err != nil {
+			return fmt.Errorf("enabling repo: %v", err)
+		}
+	}
+
+	descriptor, err := projectValidUsers(ctx, org, project, token)
+	if err != nil {
+		return fmt.Errorf("resolving Project Valid Users: %v", err)
+	}
+	apiURL := fmt.Sprintf("%s/_apis/accesscontrolentries/%s?token=%s&descriptors=%s&api-version=7.0",
+		org, gitSecurityNamespace, url.QueryEscape(repoSecurityToken(repo)), url.QueryEscape(descriptor))
+	if err := azureRequest(ctx, "DELETE", apiURL, token, nil, http.StatusOK, nil); err != nil {
+		return fmt.Errorf("removing deny-push entry: %v", err)
+	}
+	return nil
+}
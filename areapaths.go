@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// classificationNode is an area or iteration node of a project.
+type classificationNode struct {
+	Name     string               `json:"name"`
+	Children []classificationNode `json:"children"`
+}
+
+// flatten returns the backslash-separated paths of n and its descendants,
+// as work items reference them ("Project\Team\Component").
+func (n classificationNode) flatten(prefix string) []string {
+	path := n.Name
+	if prefix != "" {
+		path = prefix + `\` + n.Name
+	}
+	paths := []string{path}
+	for _, c := range n.Children {
+		paths = append(paths, c.flatten(path)...)
+	}
+	return paths
+}
+
+// listClassificationPaths lists the project's area ("Areas") or iteration
+// ("Iterations") paths.
+func listClassificationPaths(ctx context.Context, org, project, group, token string) ([]string, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/wit/classificationnodes/%s?$depth=10&api-version=7.0", org, url.PathEscape(project), group)
+	var root classificationNode
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &root); err != nil {
+		return nil, err
+	}
+	paths := root.flatten("")
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// missingAreaPaths returns the levels of an area path (below the project
+// root) that do not exist yet, parents first.
+func missingAreaPaths(project, path string, existing []string) ([]string, error) {
+	have := map[string]bool{}
+	for _, p := range existing {
+		have[strings.ToLower(p)] = true
+	}
+	levels := strings.Split(path, `\`)
+	if len(levels) < 2 || !strings.EqualFold(levels[0], project) {
+		return nil, fmt.Errorf("area path %q is not under project %s", path, project)
+	}
+	var missing []string
+	for i := 2; i <= len(levels); i++ {
+		if full := strings.Join(levels[:i], `\`); !have[strings.ToLower(full)] {
+			missing = append(missing, full)
+		}
+	}
+	return missing, nil
+}
+
+// createAreaPath creates an area path (below the project root) one level
+// at a time, skipping levels that exist. It returns the paths it created.
+func createAreaPath(ctx context.Context, org, project, path, token string, existing []string) ([]string, error) {
+	missing, err := missingAreaPaths(project, path, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, full := range missing {
+		levels := strings.Split(full, `\`)
+		// The parent is addressed by its path below the project root.
+		apiURL := fmt.Sprintf("%s/%s/_apis/wit/classificationnodes/Areas", org, url.PathEscape(project))
+		for _, level := range levels[1 : len(levels)-1] {
+			apiURL += "/" + url.PathEscape(level)
+		}
+		apiURL += "?api-version=7.0"
+		payload := map[string]interface{}{"name": levels[len(levels)-1]}
+		if err := azureRequest(ctx, "POST", apiURL, token, payload, http.StatusCreated, nil); err != nil {
+			return created, fmt.Errorf("creating area %s: %v", full, err)
+		}
+		created = append(created, full)
+	}
+	return created, nil
+}
+
+// workItemPlacement is where work items created for a repository go.
+type workItemPlacement struct {
+	AreaPath      string
+	IterationPath string
+}
+
+// workItemAreas maps source repositories to work item placements. A
+// repository without an entry gets an area path named after it.
+type workItemAreas struct {
+	mu            sync.Mutex
+	byRepo        map[string]workItemPlacement
+	createMissing bool
+	// prepareMu serializes Prepare, so repositories migrated at the same
+	// time do not race to create the same parent area.
+	prepareMu sync.Mutex
+}
+
+func (a *workItemAreas) Set(repo string, p workItemPlacement) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.byRepo == nil {
+		a.byRepo = map[string]workItemPlacement{}
+	}
+	a.byRepo[repo] = p
+}
+
+func (a *workItemAreas) Remove(repo string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.byRepo, repo)
+}
+
+// SetCreateMissing turns automatic creation of missing area paths on or
+// off.
+func (a *workItemAreas) SetCreateMissing(create bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.createMissing = create
+}
+
+func (a *workItemAreas) CreateMissing() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.createMissing
+}
+
+// Enabled reports whether anything was configured; otherwise runs leave
+// classification nodes alone.
+func (a *workItemAreas) Enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.byRepo) > 0 || a.createMissing
+}
+
+// Repos returns the mapped repositories, sorted.
+func (a *workItemAreas) Repos() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var repos []string
+	for r := range a.byRepo {
+		repos = append(repos, r)
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// Placement returns the mapping for repo, defaulting the area path to
+// "<project>\<repo name>" and the iteration path to the project root.
+func (a *workItemAreas) Placement(project, repo string) workItemPlacement {
+	a.mu.Lock()
+	p, ok := a.byRepo[repo]
+	a.mu.Unlock()
+	if !ok || p.AreaPath == "" {
+		p.AreaPath = project + `\` + repoShortName(repo)
+	}
+	if p.IterationPath == "" {
+		p.IterationPath = project
+	}
+	return p
+}
+
+// Prepare resolves repo's placement in the target project and makes sure
+// its area path exists, creating it if allowed. It returns the placement
+// and any area paths it created.
+func (a *workItemAreas) Prepare(ctx context.Context, t *azureTarget, repo string) (workItemPlacement, []string, error) {
+	a.prepareMu.Lock()
+	defer a.prepareMu.Unlock()
+	p := a.Placement(t.project, repo)
+	existing, err := listClassificationPaths(ctx, t.org, t.project, "Areas", t.token)
+	if err != nil {
+		return p, nil, fmt.Errorf("listing area paths: %v", err)
+	}
+	for _, e := range existing {
+		if strings.EqualFold(e, p.AreaPath) {
+			return p, nil, nil
+		}
+	}
+
+	if !a.CreateMissing() {
+		// Fall back to the project root rather than an area that does not exist.
+		missing := p.AreaPath
+		p.AreaPath = t.project
+		return p, nil, fmt.Errorf("area path %s does not exist, using %s", missing, p.AreaPath)
+	}
+	created, err := createAreaPath(ctx, t.org, t.project, p.AreaPath, t.token, existing)
+	return p, created, err
+}
@@ -0,0 +1,98 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showAreaMapping opens the work item area mapping: pick a source
+// repository and the area and iteration paths its work items go to, from
+// the target project's classification nodes.
+func showAreaMapping(w fyne.Window, areas *workItemAreas, t *azureTarget, githubToken string, logMsg func(string)) {
+	ctx := context.Background()
+	areaPaths, err := listClassificationPaths(ctx, t.org, t.project, "Areas", t.token)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("listing area paths: %v", err), w)
+		return
+	}
+	iterationPaths, err := listClassificationPaths(ctx, t.org, t.project, "Iterations", t.token)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("listing iteration paths: %v", err), w)
+		return
+	}
+	var repoNames []string
+	if githubToken != "" {
+		repos, err := listGitHubRepos(ctx, "", githubToken)
+		if err != nil {
+			logMsg(fmt.Sprintf("Warning: could not list GitHub repositories: %v", err))
+		}
+		for _, r := range repos {
+			repoNames = append(repoNames, r.FullName)
+		}
+	}
+
+	repoSelect := widget.NewSelectEntry(repoNames)
+	repoSelect.SetPlaceHolder("owner/repo")
+	areaSelect := widget.NewSelect(areaPaths, nil)
+	areaSelect.PlaceHolder = "(area named after the repo)"
+	iterationSelect := widget.NewSelect(iterationPaths, nil)
+	iterationSelect.PlaceHolder = "(project root)"
+
+	mapped := areas.Repos()
+	selected := -1
+	list := widget.NewList(
+		func() int { return len(mapped) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			p := areas.Placement(t.project, mapped[i])
+			o.(*widget.Label).SetText(fmt.Sprintf("%s -> %s, %s", mapped[i], p.AreaPath, p.IterationPath))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+	refresh := func() {
+		mapped = areas.Repos()
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+	}
+
+	addBtn := widget.NewButton("Add Mapping", func() {
+		if repoSelect.Text == "" {
+			return
+		}
+		areas.Set(repoSelect.Text, workItemPlacement{AreaPath: areaSelect.Selected, IterationPath: iterationSelect.Selected})
+		refresh()
+	})
+	removeBtn := widget.NewButton("Remove Selected", func() {
+		if selected >= 0 && selected < len(mapped) {
+			areas.Remove(mapped[selected])
+			refresh()
+		}
+	})
+	createCheck := widget.NewCheck("Create missing area paths", areas.SetCreateMissing)
+	createCheck.Checked = areas.CreateMissing()
+
+	content := container.NewBorder(
+		container.NewVBox(
+			widget.NewForm(
+				widget.NewFormItem("Repository", repoSelect),
+				widget.NewFormItem("Area path", areaSelect),
+				widget.NewFormItem("Iteration path", iterationSelect),
+			),
+			container.NewHBox(addBtn, removeBtn),
+			createCheck,
+		),
+		nil, nil, nil,
+		list,
+	)
+	d := dialog.NewCustom("Work item areas", "Close", content, w)
+	d.Resize(fyne.NewSize(700, 500))
+	d.Show()
+}
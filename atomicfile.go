@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Persistent files are replaced, never rewritten in place: the new content
+// goes to path.tmp, is synced, and is renamed over path, with the previous
+// generation kept as path.bak. A crash at any point leaves at least one
+// complete copy for readFileRecover to find.
+
+// writeFileAtomic replaces path with data.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Keep the current generation as the backup.
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes directory entries (the renames) to disk. Not every
+// platform can sync a directory, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// readFileRecover reads a file written by writeFileAtomic. If path is
+// missing or fails validate (for example truncated by a crash), it falls
+// back to a complete leftover path.tmp and then to path.bak, in that order.
+func readFileRecover(path string, validate func([]byte) error) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err = validate(data); err == nil {
+			return data, nil
+		}
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	for _, candidate := range []string{path + ".tmp", path + ".bak"} {
+		if d, cerr := os.ReadFile(candidate); cerr == nil && validate(d) == nil {
+			return d, nil
+		}
+	}
+	return nil, err
+}
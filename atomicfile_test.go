@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// validJSON is a readFileRecover validator for JSON files.
+func validJSON(data []byte) error {
+	if !json.Valid(data) {
+		return errors.New("invalid JSON")
+	}
+	return nil
+}
+
+func TestWriteFileAtomicKeepsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, data := range []string{`{"gen":1}`, `{"gen":2}`} {
+		if err := writeFileAtomic(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for file, want := range map[string]string{path: `{"gen":2}`, path + ".bak": `{"gen":1}`} {
+		if got, err := os.ReadFile(file); err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(file), got, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the .tmp file is left over: %v", err)
+	}
+}
+
+// A main file cut short at any offset, as by a power cut mid-write, falls
+// back to the previous generation.
+func TestReadFileRecoverTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	previous := `{"repos":{"acme/api":"verified"}}`
+	current := `{"repos":{"acme/api":"verified","acme/web":"pushed","acme/docs":"failed"}}`
+	writeFileAtomic(path, []byte(previous), 0o644)
+	writeFileAtomic(path, []byte(current), 0o644)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		offset := rng.Intn(len(current))
+		if err := os.WriteFile(path, []byte(current[:offset]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readFileRecover(path, validJSON)
+		if err != nil || string(got) != previous {
+			t.Fatalf("truncated at %d: got %q, %v; want the backup", offset, got, err)
+		}
+	}
+}
+
+// A crash after the new generation was written but before it was renamed
+// leaves a complete .tmp, which is newer than the backup.
+func TestReadFileRecoverLeftoverTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	os.WriteFile(path+".bak", []byte(`{"gen":1}`), 0o644)
+	os.WriteFile(path+".tmp", []byte(`{"gen":3}`), 0o644)
+
+	// The main file renamed to the backup already, or corrupt.
+	for state, main := range map[string]string{"missing": "", "corrupt": `{"gen":2`} {
+		os.Remove(path)
+		if main != "" {
+			os.WriteFile(path, []byte(main), 0o644)
+		}
+		got, err := readFileRecover(path, validJSON)
+		if err != nil || string(got) != `{"gen":3}` {
+			t.Errorf("main file %s: got %q, %v; want the .tmp", state, got, err)
+		}
+	}
+
+	// A .tmp cut short itself is skipped for the backup.
+	os.WriteFile(path+".tmp", []byte(`{"ge`), 0o644)
+	if got, err := readFileRecover(path, validJSON); err != nil || string(got) != `{"gen":1}` {
+		t.Errorf("with a truncated .tmp: got %q, %v; want the .bak", got, err)
+	}
+}
+
+func TestReadFileRecoverNothingValid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if _, err := readFileRecover(path, validJSON); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("no files: err = %v, want not exist", err)
+	}
+	os.WriteFile(path, []byte(`{`), 0o644)
+	os.WriteFile(path+".bak", []byte(`}`), 0o644)
+	if got, err := readFileRecover(path, validJSON); err == nil {
+		t.Errorf("all corrupt: got %q, want an error", got)
+	}
+}
+
+// JSON Lines files, such as the audit log, are corrupt when their last line
+// was cut short.
+func TestReadFileRecoverJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	previous := "{\"event\":\"created\"}\n"
+	current := previous + "{\"event\":\"pushed\"}\n"
+	writeFileAtomic(path, []byte(previous), 0o644)
+	writeFileAtomic(path, []byte(current), 0o644)
+	os.WriteFile(path, []byte(current[:len(current)-5]), 0o644)
+	if got, err := readFileRecover(path, validateJSONLines); err != nil || string(got) != previous {
+		t.Errorf("got %q, %v; want the backup", got, err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditLogPath records destructive actions taken against the target, one
+// JSON object per line, relative to the working directory.
+const auditLogPath = "audit.log"
+
+var auditMu sync.Mutex
+
+// auditIdentities are the identities of the run in progress, recorded
+// with each entry; see setAuditIdentities.
+var auditIdentities runIdentities
+
+// setAuditIdentities sets the identities recorded with the entries
+// written from now on.
+func setAuditIdentities(ids runIdentities) {
+	auditMu.Lock()
+	auditIdentities = ids
+	auditMu.Unlock()
+}
+
+// auditEntry is one destructive action.
+type auditEntry struct {
+	Time   string `json:"time"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Detail string `json:"detail,omitempty"`
+	Actor  string `json:"actor,omitempty"` // the run's GitHub login
+	// The run's Azure DevOps identity, who made the change in the target,
+	// and the committer of the commits the tool made.
+	ADOActor  string `json:"ado_actor,omitempty"`
+	Committer string `json:"committer,omitempty"`
+}
+
+// writeAudit appends an entry to the audit log.
+func writeAudit(action, target, detail string) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	data, err := json.Marshal(auditEntry{
+		Time:      fileTimestamp(time.Now()),
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+		Actor:     auditIdentities.GitHub,
+		ADOActor:  auditIdentities.ADO,
+		Committer: auditIdentities.Committer,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The log is small, so it is rewritten whole rather than appended to,
+	// which keeps a crash from leaving a torn last line.
+	log, err := readFileRecover(auditLogPath, validateJSONLines)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// No intact copy: keep the complete entries of the damaged one.
+		damaged, _ := os.ReadFile(auditLogPath)
+		log = salvageJSONLines(damaged)
+	}
+	return writeFileAtomic(auditLogPath, append(append(log, data...), '\n'), 0644)
+}
+
+// validateJSONLines checks that every line of data is a JSON value.
+func validateJSONLines(data []byte) error {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return errors.New("invalid JSON line")
+		}
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return errors.New("truncated last line")
+	}
+	return nil
+}
+
+// salvageJSONLines returns the lines of data that are complete JSON values.
+func salvageJSONLines(data []byte) []byte {
+	var out []byte
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		// The last line is torn unless data ended with a newline.
+		if i == len(lines)-1 || !json.Valid(line) {
+			continue
+		}
+		out = append(append(out, line...), '\n')
+	}
+	return out
+}
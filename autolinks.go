@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// gitHubAutolink is a repository's autolink reference: text starting with
+// KeyPrefix becomes a link built from URLTemplate, where <num> stands for
+// the rest of the reference.
+type gitHubAutolink struct {
+	ID             int    `json:"id"`
+	KeyPrefix      string `json:"key_prefix"`
+	URLTemplate    string `json:"url_template"`
+	IsAlphanumeric bool   `json:"is_alphanumeric"`
+}
+
+// listAutolinks lists a repository's ("owner/repo") autolink references,
+// following pagination. The API needs admin access to the repository.
+func listAutolinks(ctx context.Context, fullName, token string) ([]gitHubAutolink, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/autolinks?per_page=100", fullName)
+	var all []gitHubAutolink
+	for apiURL != "" {
+		var page []gitHubAutolink
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, page...)
+		apiURL = next
+	}
+	return all, nil
+}
+
+// autolinkExample renders what a reference expands to, such as
+// "JIRA-123 -> https://jira.example.com/browse/JIRA-123". A template
+// without <num> links every reference to the same URL, which is noted.
+func autolinkExample(a gitHubAutolink) string {
+	ref := "123"
+	if a.IsAlphanumeric {
+		ref = "ABC123"
+	}
+	if !strings.Contains(a.URLTemplate, "<num>") {
+		return fmt.Sprintf("%s%s -> %s (the template has no <num>, every reference links here)", a.KeyPrefix, ref, a.URLTemplate)
+	}
+	return fmt.Sprintf("%s%s -> %s", a.KeyPrefix, ref, strings.ReplaceAll(a.URLTemplate, "<num>", ref))
+}
+
+// migrationDoc collects, per repository, GitHub behavior that does not
+// carry over to the target, for the run's MIGRATION.md.
+type migrationDoc struct {
+	mu        sync.Mutex
+	autolinks map[string][]gitHubAutolink
+}
+
+// AddAutolinks records repo's autolink references.
+func (d *migrationDoc) AddAutolinks(repo string, links []gitHubAutolink) {
+	if len(links) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.autolinks == nil {
+		d.autolinks = map[string][]gitHubAutolink{}
+	}
+	d.autolinks[repo] = links
+}
+
+// Empty reports whether there is nothing to document.
+func (d *migrationDoc) Empty() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.autolinks) == 0
+}
+
+// Markdown renders the document. wikiURL is the target project's wiki, or
+// "" if it has none.
+func (d *migrationDoc) Markdown(wikiURL string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var repos []string
+	for r := range d.autolinks {
+		repos = append(repos, r)
+	}
+	sort.Strings(repos)
+
+	var b strings.Builder
+	b.WriteString("# MIGRATION\n\n")
+	b.WriteString("GitHub features of the migrated repositories that Azure DevOps does not provide as is.\n")
+	for _, repo := range repos {
+		links := d.autolinks[repo]
+		sort.Slice(links, func(i, j int) bool { return links[i].KeyPrefix < links[j].KeyPrefix })
+		fmt.Fprintf(&b, "\n## %s\n\n### Autolink references\n\n", repo)
+		b.WriteString("| Prefix | URL template | Reference | Example |\n|---|---|---|---|\n")
+		for _, a := range links {
+			kind := "numeric"
+			if a.IsAlphanumeric {
+				kind = "alphanumeric"
+			}
+			fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s |\n", cellEscape(a.KeyPrefix), cellEscape(a.URLTemplate), kind, cellEscape(autolinkExample(a)))
+		}
+	}
+
+	b.WriteString("\n## Recreating autolinks in Azure DevOps\n\n")
+	b.WriteString("Azure Repos has no autolink references; text like the prefixes above stays plain text.\n\n")
+	b.WriteString("- References to Azure Boards work items link automatically when written as `#<id>` (or `AB#<id>` from GitHub).\n")
+	b.WriteString("- For external trackers such as Jira, link commits and pull requests from the tracker's side (its Azure DevOps integration), or keep the table above where the team can find it.\n")
+	if wikiURL != "" {
+		fmt.Fprintf(&b, "- The project has a wiki: add a page with the prefix table above, e.g. under %s, and link it from each repository's README.\n", wikiURL)
+	}
+	return b.String()
+}
+
+// cellEscape keeps s inside one Markdown table cell.
+func cellEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// save writes the document next to the report of run id and returns its
+// path.
+func (d *migrationDoc) save(id, wikiURL string) (string, error) {
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(reportsDir, id+"-MIGRATION.md")
+	return path, writeFileAtomic(path, []byte(d.Markdown(wikiURL)), 0644)
+}
+
+// projectWikiURL returns the web URL of the project's first wiki, or "" if
+// it has none.
+func projectWikiURL(ctx context.Context, org, project, token string) (string, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/wiki/wikis?api-version=7.0", org, url.PathEscape(project))
+	var result struct {
+		Value []struct {
+			RemoteURL string `json:"remoteUrl"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return "", err
+	}
+	if len(result.Value) == 0 {
+		return "", nil
+	}
+	return result.Value[0].RemoteURL, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAutolinkExample(t *testing.T) {
+	tests := []struct {
+		name string
+		link gitHubAutolink
+		want string
+	}{
+		{"numeric path", gitHubAutolink{KeyPrefix: "TICKET-", URLTemplate: "https://tickets.example.com/t/<num>"},
+			"TICKET-123 -> https://tickets.example.com/t/123"},
+		{"alphanumeric", gitHubAutolink{KeyPrefix: "JIRA-", URLTemplate: "https://jira.example.com/browse/JIRA-<num>", IsAlphanumeric: true},
+			"JIRA-ABC123 -> https://jira.example.com/browse/JIRA-ABC123"},
+		{"query string", gitHubAutolink{KeyPrefix: "BUG#", URLTemplate: "https://bugs.example.com/show_bug.cgi?id=<num>&format=full"},
+			"BUG#123 -> https://bugs.example.com/show_bug.cgi?id=123&format=full"},
+		{"twice", gitHubAutolink{KeyPrefix: "CR-", URLTemplate: "https://review.example.com/<num>/#/c/<num>"},
+			"CR-123 -> https://review.example.com/123/#/c/123"},
+		{"no <num>", gitHubAutolink{KeyPrefix: "DOCS-", URLTemplate: "https://docs.example.com/"},
+			"DOCS-123 -> https://docs.example.com/ (the template has no <num>, every reference links here)"},
+	}
+	for _, tt := range tests {
+		if got := autolinkExample(tt.link); got != tt.want {
+			t.Errorf("%s: autolinkExample = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMigrationDocMarkdown(t *testing.T) {
+	var d migrationDoc
+	if !d.Empty() {
+		t.Fatal("a new document is not empty")
+	}
+	d.AddAutolinks("acme/web", nil)
+	if !d.Empty() {
+		t.Fatal("a repository without autolinks was documented")
+	}
+	d.AddAutolinks("acme/api", []gitHubAutolink{
+		{KeyPrefix: "TICKET-", URLTemplate: "https://tickets.example.com/t/<num>"},
+		{KeyPrefix: "OPS|", URLTemplate: "https://ops.example.com/?q=a|<num>", IsAlphanumeric: true},
+	})
+	md := d.Markdown("https://dev.azure.com/acme/proj/_wiki")
+	for _, want := range []string{
+		"## acme/api",
+		// Sorted by prefix, with pipes kept inside their cells.
+		"| `OPS\\|` | `https://ops.example.com/?q=a\\|<num>` | alphanumeric | OPS\\|ABC123 -> https://ops.example.com/?q=a\\|ABC123 |\n" +
+			"| `TICKET-` | `https://tickets.example.com/t/<num>` | numeric | TICKET-123 -> https://tickets.example.com/t/123 |",
+		"under https://dev.azure.com/acme/proj/_wiki",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(d.Markdown(""), "The project has a wiki") {
+		t.Error("Markdown without a wiki mentions one")
+	}
+}
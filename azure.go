@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// azureRepo is the subset of the Azure DevOps repository API object the
+// migration uses.
+type azureRepo struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	RemoteURL     string `json:"remoteUrl"`
+	DefaultBranch string `json:"defaultBranch"`
+	IsDisabled    bool   `json:"isDisabled"`
+	Size          int64  `json:"size"` // bytes
+	Project       struct {
+		ID string `json:"id"`
+	} `json:"project"`
+}
+
+// branchRef turns a branch name as reported by GitHub ("trunk",
+// "release/2024") into a full ref name.
+func branchRef(branch string) string {
+	return "refs/heads/" + strings.TrimPrefix(branch, "refs/heads/")
+}
+
+// azureRequest sends an authenticated request to the Azure DevOps REST API
+// and decodes the JSON response into out (if non-nil). Any status other
+// than wantStatus is returned as an error.
+func azureRequest(ctx context.Context, method, apiURL, token string, payload interface{}, wantStatus int, out interface{}) error {
+	return azureRequestContent(ctx, method, apiURL, token, "application/json", payload, wantStatus, out)
+}
+
+// azureRequestContent is azureRequest with the payload sent as
+// contentType, such as the JSON Patch work items take.
+func azureRequestContent(ctx context.Context, method, apiURL, token, contentType string, payload interface{}, wantStatus int, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		jsonPayload, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, body)
+	if err != nil {
+		return err
+	}
+
+	if err := setAzureAuth(req, token); err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		return azureAPIError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// createAzureRepo creates a new repository in Azure DevOps.
+func createAzureRepo(ctx context.Context, repoName, org, project, token string) (*azureRepo, error) {
+	// Construct URL. org should be the URL of your Azure DevOps organization.
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories?api-version=7.0", org, url.PathEscape(project))
+
+	// Create JSON payload
+	payload := map[string]interface{}{
+		"name": repoName,
+	}
+	jsonPayload, _ := json.Marshal(payload)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setAzureAuth(req, token); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, azureAPIError(resp)
+	}
+
+	// Parse response to get repository id and URL
+	var result azureRepo
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ensureAzureRepo returns the project's repository name, creating it first
+// if it does not exist; created reports whether it did. One created in the
+// meantime, by another run, is taken as existing. A name held by a
+// repository in the recycle bin stays a conflict.
+func ensureAzureRepo(ctx context.Context, org, project, name, token string) (repo *azureRepo, created bool, err error) {
+	repo, err = getAzureRepo(ctx, org, project, name, token)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return repo, false, err
+	}
+	repo, err = createAzureRepo(ctx, name, org, project, token)
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		if existing, gerr := getAzureRepo(ctx, org, project, name, token); gerr == nil {
+			return existing, false, nil
+		}
+	}
+	return repo, err == nil, err
+}
+
+// authRemoteURL returns an ADO remote URL with the PAT as its password,
+// for authentication. The userinfo ADO puts in remote URLs, the
+// organization as in https://org@dev.azure.com/org/..., is replaced, and
+// the host is left as it is, so on-premises collections authenticate too.
+// For the Entra ID session it sets the access token current now; a remote
+// used past its expiry needs the URL again.
+func authRemoteURL(ctx context.Context, remoteURL, token string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return remoteURL
+	}
+	value := azureGitToken(ctx, token)
+	registerSecret(value)
+	u.User = url.UserPassword(tokenUsername, value)
+	return u.String()
+}
+
+// getAzureRepo looks up a repository by name. org is the organization URL.
+func getAzureRepo(ctx context.Context, org, project, name, token string) (*azureRepo, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s?api-version=7.0", org, url.PathEscape(project), url.PathEscape(name))
+	var repo azureRepo
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// setAzureDefaultBranch sets the repository's default branch. branch is the
+// name GitHub reports as default_branch and may contain slashes.
+func setAzureDefaultBranch(ctx context.Context, org, project, repoID, branch, token string) error {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s?api-version=7.0", org, url.PathEscape(project), repoID)
+	payload := map[string]interface{}{
+		"defaultBranch": branchRef(branch),
+	}
+	return azureRequest(ctx, "PATCH", apiURL, token, payload, http.StatusOK, nil)
+}
+
+// azureTarget migrates repositories into an Azure DevOps project.
+type azureTarget struct {
+	org     string // organization URL
+	project string
+	token   string
+	runID   string       // recorded with the repositories the run creates
+	logMsg  func(string) // optional, reports progress such as retries
+}
+
+func (t *azureTarget) Name() string { return "Azure DevOps" }
+
+// CreateRepo creates the repository. A name conflict with a repository in
+// the recycle bin is returned as a *recycledNameError. A conflict with a
+// live repository adopts it if an earlier run created it or it is empty,
+// so a run interrupted right after creating can be rerun.
+func (t *azureTarget) CreateRepo(ctx context.Context, name string) (string, error) {
+	t.record(createdRepo{Event: "creating", Name: name})
+	repo, err := createAzureRepo(ctx, name, t.org, t.project, t.token)
+	if err == nil {
+		t.record(createdRepo{Event: "created", Name: repo.Name, ID: repo.ID, RemoteURL: repo.RemoteURL})
+		return authRemoteURL(ctx, repo.RemoteURL, t.token), nil
+	}
+	t.record(createdRepo{Event: "failed", Name: name})
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		return "", err
+	}
+	if rerr := t.checkRecycleBin(ctx, name, err); rerr != nil {
+		return "", rerr
+	}
+	return t.adoptExisting(ctx, name, err)
+}
+
+func (t *azureTarget) SetDefaultBranch(ctx context.Context, name, branch string) error {
+	repo, err := getAzureRepo(ctx, t.org, t.project, name, t.token)
+	if err != nil {
+		return fmt.Errorf("looking up Azure repo: %v", err)
+	}
+	if repo.DefaultBranch == branchRef(branch) {
+		return nil
+	}
+	return t.setDefaultBranchRetry(ctx, name, repo.ID, branch)
+}
+
+// defaultBranchRetryWindow bounds how long a default branch update rejected
+// with 400 is retried. Right after a large push ADO rejects it until it has
+// indexed the new refs.
+const defaultBranchRetryWindow = 2 * time.Minute
+
+// branchNotSetError is a default branch update that still failed after
+// retrying for Waited.
+type branchNotSetError struct {
+	Branch string
+	Waited time.Duration
+	Err    error
+}
+
+func (e *branchNotSetError) Error() string {
+	return fmt.Sprintf("default branch not set — set manually to %s (retried for %s: %v)", branchRef(e.Branch), formatDuration(e.Waited), e.Err)
+}
+
+func (e *branchNotSetError) Unwrap() error { return e.Err }
+
+// setDefaultBranchRetry sets the default branch, retrying with backoff while
+// ADO answers 400. Other errors are returned at once, and ctx's error if it
+// is done while waiting.
+func (t *azureTarget) setDefaultBranchRetry(ctx context.Context, name, repoID, branch string) error {
+	start := time.Now()
+	delay := 5 * time.Second
+	for attempt := 1; ; attempt++ {
+		err := setAzureDefaultBranch(ctx, t.org, t.project, repoID, branch, t.token)
+		if err == nil {
+			if attempt > 1 {
+				t.log(fmt.Sprintf("Default branch for %s accepted after %d attempts (%s).", name, attempt, formatDuration(time.Since(start))))
+			}
+			return nil
+		}
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			return fmt.Errorf("setting default branch: %v", err)
+		}
+		waited := time.Since(start)
+		if waited+delay > defaultBranchRetryWindow {
+			return &branchNotSetError{Branch: branch, Waited: waited, Err: err}
+		}
+		t.log(fmt.Sprintf("Default branch for %s rejected (%v), ADO may still be indexing; retrying in %s (%s of %s used).",
+			name, err, formatDuration(delay), formatDuration(waited), formatDuration(defaultBranchRetryWindow)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > 30*time.Second {
+			delay = 30 * time.Second
+		}
+	}
+}
+
+func (t *azureTarget) log(msg string) {
+	if t.logMsg != nil {
+		t.logMsg(msg)
+	}
+}
+
+func (t *azureTarget) MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error) {
+	return applyArchivedState(ctx, t.org, t.project, name, t.token, mode)
+}
+
+// listAzureRepos lists the repositories of a project.
+func listAzureRepos(ctx context.Context, org, project, token string) ([]azureRepo, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories?api-version=7.0", org, url.PathEscape(project))
+	var result struct {
+		Value []azureRepo `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
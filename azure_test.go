@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBranchRef(t *testing.T) {
+	tests := map[string]string{
+		"main":                    "refs/heads/main",
+		"trunk":                   "refs/heads/trunk",
+		"production":              "refs/heads/production",
+		"release/2024":            "refs/heads/release/2024",
+		"refs/heads/release/2024": "refs/heads/release/2024",
+	}
+	for branch, want := range tests {
+		if got := branchRef(branch); got != want {
+			t.Errorf("branchRef(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+// The default branch is PATCHed as GitHub reports it, slashes and all.
+func TestSetAzureDefaultBranch(t *testing.T) {
+	for _, branch := range []string{"trunk", "production", "release/2024"} {
+		var method, path, got string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			var body struct {
+				DefaultBranch string `json:"defaultBranch"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			got = body.DefaultBranch
+		}))
+		err := setAzureDefaultBranch(t.Context(), srv.URL, "proj", "repo-id", branch, "pat")
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", branch, err)
+		}
+		if method != "PATCH" || path != "/proj/_apis/git/repositories/repo-id" {
+			t.Errorf("%s: sent %s %s", branch, method, path)
+		}
+		if want := "refs/heads/" + branch; got != want {
+			t.Errorf("%s: defaultBranch = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+// A project name with a space is escaped in the request path, and a
+// created repository the response does not describe is an error.
+func TestCreateAzureRepo(t *testing.T) {
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	body = `{"id":"repo-id","name":"api"}`
+	repo, err := createAzureRepo(t.Context(), "api", srv.URL, "Platform Team", "pat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.ID != "repo-id" {
+		t.Errorf("created repository %+v, want ID repo-id", repo)
+	}
+	if want := "/Platform%20Team/_apis/git/repositories"; path != want {
+		t.Errorf("POST %s, want %s", path, want)
+	}
+
+	body = `{"id":`
+	if repo, err := createAzureRepo(t.Context(), "api", srv.URL, "Platform Team", "pat"); err == nil {
+		t.Errorf("malformed response: got %+v and no error", repo)
+	}
+}
+
+// A default branch update waiting out ADO's indexing stops when the run
+// is cancelled rather than sleeping through the retry window.
+func TestSetDefaultBranchRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"not indexed yet"}`, http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	// The retry is announced just before the wait; cancel then.
+	target := &azureTarget{org: srv.URL, project: "proj", token: "pat", logMsg: func(string) { cancel() }}
+	start := time.Now()
+	err := target.setDefaultBranchRetry(ctx, "api", "repo-id", "main")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if waited := time.Since(start); waited > 2*time.Second {
+		t.Errorf("returned after %s, want at once", waited)
+	}
+}
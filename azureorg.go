@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// azureOrgNamePattern matches an organization name entered on its own.
+var azureOrgNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// normalizeAzureOrg turns what users enter as the Azure DevOps
+// organization into the API base URL the requests are built on:
+//
+//	acme                                     https://dev.azure.com/acme
+//	dev.azure.com/acme, https://dev.azure.com/acme/Project/
+//	                                         https://dev.azure.com/acme
+//	https://acme.visualstudio.com/           https://dev.azure.com/acme
+//	https://tfs.example.com/tfs/Collection/  https://tfs.example.com/tfs/Collection
+//
+// Legacy visualstudio.com URLs are translated to dev.azure.com, which note
+// says. Anything else with a host is taken as an on-premises collection
+// URL and kept as entered, without a trailing slash.
+func normalizeAzureOrg(input string) (base, note string, err error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return "", "", errors.New("the organization is empty")
+	}
+	if azureOrgNamePattern.MatchString(s) {
+		return "https://dev.azure.com/" + s, "", nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return "", "", fmt.Errorf("%q is not an organization name or URL", input)
+	}
+	host := strings.ToLower(u.Hostname())
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+
+	switch {
+	case host == "dev.azure.com":
+		if len(segments) == 0 {
+			return "", "", fmt.Errorf("%q has no organization; use https://dev.azure.com/<organization>", input)
+		}
+		return "https://dev.azure.com/" + segments[0], "", nil
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		org := strings.TrimSuffix(host, ".visualstudio.com")
+		if org == "" || strings.Contains(org, ".") {
+			return "", "", fmt.Errorf("%q is not an organization URL", input)
+		}
+		base = "https://dev.azure.com/" + org
+		return base, fmt.Sprintf("%s is a legacy visualstudio.com URL; using %s", strings.TrimSpace(input), base), nil
+	default:
+		// An on-premises server: the collection URL, such as
+		// https://tfs.example.com/tfs/DefaultCollection.
+		base = u.Scheme + "://" + u.Host
+		if len(segments) > 0 {
+			base += "/" + strings.Join(segments, "/")
+		}
+		return base, "", nil
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeAzureOrg(t *testing.T) {
+	tests := []struct {
+		input, base string
+		note        bool
+	}{
+		// A bare organization name.
+		{"acme", "https://dev.azure.com/acme", false},
+		{"  acme-eu  ", "https://dev.azure.com/acme-eu", false},
+		// dev.azure.com, with and without scheme, trailing slash or project.
+		{"dev.azure.com/acme", "https://dev.azure.com/acme", false},
+		{"https://dev.azure.com/acme", "https://dev.azure.com/acme", false},
+		{"https://dev.azure.com/acme/", "https://dev.azure.com/acme", false},
+		{"https://dev.azure.com/acme/Project/", "https://dev.azure.com/acme", false},
+		{"HTTPS://DEV.AZURE.COM/acme", "https://dev.azure.com/acme", false},
+		// Legacy visualstudio.com, translated with a note.
+		{"https://acme.visualstudio.com", "https://dev.azure.com/acme", true},
+		{"https://acme.visualstudio.com/", "https://dev.azure.com/acme", true},
+		{"acme.visualstudio.com/DefaultCollection", "https://dev.azure.com/acme", true},
+		// On-premises collection URLs, kept as entered.
+		{"https://tfs.example.com/tfs/DefaultCollection/", "https://tfs.example.com/tfs/DefaultCollection", false},
+		{"http://tfs.example.com:8080/tfs/Collection", "http://tfs.example.com:8080/tfs/Collection", false},
+		{"tfs.example.com/DefaultCollection", "https://tfs.example.com/DefaultCollection", false},
+	}
+	for _, tt := range tests {
+		base, note, err := normalizeAzureOrg(tt.input)
+		if err != nil {
+			t.Errorf("normalizeAzureOrg(%q): %v", tt.input, err)
+			continue
+		}
+		if base != tt.base || (note != "") != tt.note {
+			t.Errorf("normalizeAzureOrg(%q) = %q, note %q; want %q, note %v", tt.input, base, note, tt.base, tt.note)
+		}
+	}
+}
+
+func TestNormalizeAzureOrgInvalid(t *testing.T) {
+	for _, input := range []string{"", "   ", "https://dev.azure.com/", "dev.azure.com", "https://.visualstudio.com", "https://a.b.visualstudio.com", "://"} {
+		if base, _, err := normalizeAzureOrg(input); err == nil {
+			t.Errorf("normalizeAzureOrg(%q) = %q, want an error", input, base)
+		}
+	}
+}
+
+// Remote URLs get the PAT as the password whatever their host, and
+// whatever userinfo ADO gave them.
+func TestAuthRemoteURL(t *testing.T) {
+	tests := []string{
+		"https://acme@dev.azure.com/acme/proj/_git/api",
+		"https://dev.azure.com/acme/proj/_git/api",
+		"https://acme.visualstudio.com/proj/_git/api",
+		"https://tfs.example.com/tfs/DefaultCollection/proj/_git/api",
+		"http://tfs.example.com:8080/tfs/Collection/proj/_git/api",
+	}
+	for _, remote := range tests {
+		got := authRemoteURL(t.Context(), remote, "pat-0123456789")
+		u, err := url.Parse(got)
+		if err != nil {
+			t.Fatalf("authRemoteURL(%q) = %q: %v", remote, got, err)
+		}
+		want, _ := url.Parse(remote)
+		password, _ := u.User.Password()
+		if password != "pat-0123456789" || u.Host != want.Host || u.Path != want.Path {
+			t.Errorf("authRemoteURL(%q) = %q, want the PAT as the password on the same host and path", remote, got)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// azureSource lists and clones the repositories of an Azure DevOps
+// project, for migrating them to GitHub. Repositories are named
+// "project/name", so they read like GitHub's "owner/name" in the log, the
+// report and the migration state.
+type azureSource struct {
+	org     string // organization URL
+	project string
+	token   string
+}
+
+func init() {
+	registerSource(&sourceFactory{
+		Name: "Azure DevOps",
+		Fields: []providerField{
+			{Key: "token", Label: "Azure PAT", Secret: true},
+			{Key: "org", Label: "Azure Org URL", PlaceHolder: "Azure Organization URL or name (e.g. https://dev.azure.com/yourOrg)"},
+			{Key: "project", Label: "Azure Project"},
+		},
+		New: func(cfg map[string]string) (sourceProvider, error) {
+			org, _, err := normalizeAzureOrg(cfg["org"])
+			if err != nil {
+				return nil, err
+			}
+			return &azureSource{org: org, project: strings.TrimSpace(cfg["project"]), token: cfg["token"]}, nil
+		},
+	})
+}
+
+func (s *azureSource) Name() string { return "Azure DevOps" }
+
+// ListRepos lists the project's repositories. Disabled ones are left out;
+// they cannot be cloned until someone enables them again.
+func (s *azureSource) ListRepos(ctx context.Context) ([]string, error) {
+	repos, err := listAzureRepos(ctx, s.org, s.project, s.token)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range repos {
+		if !r.IsDisabled {
+			names = append(names, s.project+"/"+r.Name)
+		}
+	}
+	return names, nil
+}
+
+// repoName returns the name of repo in the project.
+func (s *azureSource) repoName(repo string) string {
+	return strings.TrimPrefix(repo, s.project+"/")
+}
+
+// CloneURL returns the repository's clone URL with the token as the
+// password.
+func (s *azureSource) CloneURL(ctx context.Context, repo string) string {
+	u, _ := url.Parse(fmt.Sprintf("%s/%s/_git/%s", s.org, url.PathEscape(s.project), url.PathEscape(s.repoName(repo))))
+	u.User = url.UserPassword("gitui", azureGitToken(ctx, s.token))
+	return u.String()
+}
+
+// RepoMetadata describes the repository in the shape of GitHub's object:
+// its default branch, whether it is disabled (as archived), and its size.
+// Azure DevOps has no visibility per repository.
+func (s *azureSource) RepoMetadata(ctx context.Context, repo string) (*gitHubRepo, error) {
+	r, err := getAzureRepo(ctx, s.org, s.project, s.repoName(repo), s.token)
+	if err != nil {
+		return nil, err
+	}
+	return &gitHubRepo{
+		FullName:      s.project + "/" + r.Name,
+		Name:          r.Name,
+		DefaultBranch: strings.TrimPrefix(r.DefaultBranch, "refs/heads/"),
+		Archived:      r.IsDisabled,
+		Size:          int((r.Size + 1023) / 1024),
+	}, nil
+}
+
+// Host returns the organization's host, which clones from it are limited
+// by.
+func (s *azureSource) Host() string {
+	u, _ := url.Parse(s.org)
+	return u.Host
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultMigrationBranchPrefix prefixes the branches the migration pushes
+// changes of its own to, unless the window's "Migration branches" setting
+// gives another, for projects whose policies restrict branch names.
+const defaultMigrationBranchPrefix = "migration/"
+
+// badgeBranchName is the branch, after the prefix, README badge rewrites
+// are committed to, so the default branch stays identical to the source
+// until someone merges it.
+const badgeBranchName = "readme-badges"
+
+// migrationBranch returns the full name of a migration branch, checking
+// that prefix makes a valid branch name.
+func migrationBranch(ctx context.Context, prefix, name string) (string, error) {
+	branch := prefix + name
+	if _, err := runGit(ctx, nil, "check-ref-format", "--branch", branch); err != nil {
+		return "", fmt.Errorf("migration branch prefix %q does not make a valid branch name (%s)", prefix, branch)
+	}
+	return branch, nil
+}
+
+// branchPolicySignatures are the error codes and phrases of a push Azure
+// DevOps rejected because of a branch policy or branch security, such as
+// a naming convention the migration branch does not follow.
+var branchPolicySignatures = []string{
+	"TF402455", // pushes to this branch are not permitted
+	"TF401027", // no permission, e.g. to create branches outside a namespace
+	"TF402441", // a pushed ref name is not allowed
+	"rejected by policy",
+}
+
+// matchBranchPolicy returns the first signature found in text, or "".
+func matchBranchPolicy(text string) string {
+	lower := strings.ToLower(text)
+	for _, sig := range branchPolicySignatures {
+		if strings.Contains(lower, strings.ToLower(sig)) {
+			return sig
+		}
+	}
+	return ""
+}
+
+// manualDir is where run id keeps the changes to repo it could not push,
+// for someone to apply by hand.
+func manualDir(id, repo string) string {
+	return filepath.Join(reportsDir, id+"-manual", repo)
+}
+
+// saveManualChange writes the commit on branch of the clone in dir to
+// manualDir(id, repo): the changed file as a whole, and the commit as a
+// patch for git am. It returns the directory.
+func saveManualChange(ctx context.Context, dir, id, repo, branch, file, content string) (string, error) {
+	out := manualDir(id, repo)
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return "", err
+	}
+	patch, err := runGit(ctx, nil, "-C", dir, "format-patch", "-1", "--stdout", "refs/heads/"+branch)
+	if err != nil {
+		return "", fmt.Errorf("git format-patch: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(out, file), []byte(content), 0644); err != nil {
+		return "", err
+	}
+	patchFile := strings.ReplaceAll(branch, "/", "-") + ".patch"
+	if err := os.WriteFile(filepath.Join(out, patchFile), []byte(patch), 0644); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// actionsBadgePattern matches GitHub Actions status badge images, both
+// .../actions/workflows/<file>/badge.svg and the older
+// .../workflows/<name>/badge.svg, with an optional query.
+var actionsBadgePattern = regexp.MustCompile(`https://github\.com/([\w.-]+/[\w.-]+)/(?:actions/)?workflows/([^/\s)"]+)/badge\.svg(?:\?[^\s)"]*)?`)
+
+// actionsPagePattern matches links to a workflow's runs, which badges
+// usually link to.
+var actionsPagePattern = regexp.MustCompile(`https://github\.com/([\w.-]+/[\w.-]+)/actions(?:/workflows/([^/\s)"?#]+))?(?:\?[^\s)"]*)?`)
+
+// markdownImagePattern matches the URL of a Markdown image.
+var markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(([^)\s]+)`)
+
+// buildDefinition is the subset of an ADO build (pipeline) definition used
+// to map workflows to badges.
+type buildDefinition struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Process struct {
+		YamlFilename string `json:"yamlFilename"`
+	} `json:"process"`
+	Links struct {
+		Badge struct {
+			Href string `json:"href"`
+		} `json:"badge"`
+		Web struct {
+			Href string `json:"href"`
+		} `json:"web"`
+	} `json:"_links"`
+}
+
+// listBuildDefinitions lists the pipeline definitions built from a
+// repository of the project.
+func listBuildDefinitions(ctx context.Context, org, project, repoID, token string) ([]buildDefinition, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/build/definitions?repositoryId=%s&repositoryType=TfsGit&includeAllProperties=true&api-version=7.0",
+		org, url.PathEscape(project), url.QueryEscape(repoID))
+	var result struct {
+		Value []buildDefinition `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// workflowKey reduces a workflow file or name to what a converted pipeline
+// is matched by: "ci.yml", "CI" and "pipelines/ci.yaml" all become "ci".
+func workflowKey(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		s = u
+	}
+	s = path.Base(s)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, ".yml"), ".yaml")
+	return strings.ToLower(s)
+}
+
+// definitionFor returns the definition converted from workflow, matched by
+// YAML file name or definition name.
+func definitionFor(defs []buildDefinition, workflow string) *buildDefinition {
+	key := workflowKey(workflow)
+	for i := range defs {
+		if defs[i].Process.YamlFilename != "" && workflowKey(defs[i].Process.YamlFilename) == key {
+			return &defs[i]
+		}
+	}
+	for i := range defs {
+		if workflowKey(defs[i].Name) == key {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+// rewriteBadges replaces the Actions badges of repo (owner/name) in readme
+// with the badges of the matching pipeline definitions, along with links to
+// the workflows' runs. It returns the new text, how many badges were
+// rewritten, and the badge URLs it left untouched: Actions badges without a
+// matching pipeline and third-party badges.
+func rewriteBadges(readme, repo string, defs []buildDefinition) (string, int, []string) {
+	rewritten := 0
+	var untouched []string
+	out := actionsBadgePattern.ReplaceAllStringFunc(readme, func(m string) string {
+		sub := actionsBadgePattern.FindStringSubmatch(m)
+		def := definitionFor(defs, sub[2])
+		if !strings.EqualFold(sub[1], repo) || def == nil || def.Links.Badge.Href == "" {
+			untouched = append(untouched, m)
+			return m
+		}
+		rewritten++
+		return def.Links.Badge.Href
+	})
+	out = actionsPagePattern.ReplaceAllStringFunc(out, func(m string) string {
+		sub := actionsPagePattern.FindStringSubmatch(m)
+		if !strings.EqualFold(sub[1], repo) || sub[2] == "" {
+			return m
+		}
+		if def := definitionFor(defs, sub[2]); def != nil && def.Links.Web.Href != "" {
+			return def.Links.Web.Href
+		}
+		return m
+	})
+
+	for _, m := range markdownImagePattern.FindAllStringSubmatch(out, -1) {
+		u := m[1]
+		if actionsBadgePattern.MatchString(u) || !looksLikeBadge(u) || containsString(untouched, u) {
+			continue
+		}
+		if !isDefinitionBadge(defs, u) {
+			untouched = append(untouched, u)
+		}
+	}
+	return out, rewritten, untouched
+}
+
+// looksLikeBadge reports whether an image URL is a status badge.
+func looksLikeBadge(u string) bool {
+	u = strings.ToLower(u)
+	return strings.Contains(u, "badge") || strings.Contains(u, "shields.io") || strings.Contains(u, "/status")
+}
+
+func isDefinitionBadge(defs []buildDefinition, u string) bool {
+	for _, d := range defs {
+		if d.Links.Badge.Href == u {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// badgeReport is the outcome of the README badge rewrite for a repository.
+type badgeReport struct {
+	Readme    string   `json:"readme,omitempty"`
+	Branch    string   `json:"branch,omitempty"` // set if a commit was pushed
+	Rewritten int      `json:"rewritten"`
+	Untouched []string `json:"untouched,omitempty"`
+	// Manual is where the rewrite was saved when a branch policy rejected
+	// its push, to be applied by hand.
+	Manual string `json:"manual,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// findReadme returns the name of the README at the root of the default
+// branch of the bare clone in dir, or "" if there is none.
+func findReadme(ctx context.Context, dir string) string {
+	out, err := runGit(ctx, nil, "-C", dir, "ls-tree", "--name-only", "HEAD")
+	if err != nil {
+		return ""
+	}
+	for _, name := range strings.Split(out, "\n") {
+		if strings.EqualFold(name, "README.md") {
+			return name
+		}
+	}
+	return ""
+}
+
+// gitInput runs git in dir with input on stdin and returns its stdout.
+func gitInput(dir, input string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdin = strings.NewReader(input)
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok {
+		// Name the subcommand, past any -c options.
+		sub := args[0]
+		for i := 0; i+1 < len(args) && args[i] == "-c"; i += 2 {
+			sub = args[i+2]
+		}
+		return "", fmt.Errorf("git %s: %v: %s", sub, err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+// commitReadme commits content as readme on top of HEAD of the bare clone
+// in dir, on branch, through signer. Only the root tree changes, so it is
+// rebuilt from HEAD's with mktree rather than through a working tree.
+func commitReadme(ctx context.Context, dir, readme, content, branch string, signer *commitSigner, logMsg func(string)) error {
+	blob, err := gitInput(dir, content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	tree, err := runGit(ctx, nil, "-C", dir, "ls-tree", "HEAD")
+	if err != nil {
+		return fmt.Errorf("git ls-tree: %v", err)
+	}
+	var entries []string
+	for _, line := range strings.Split(strings.TrimSpace(tree), "\n") {
+		// "<mode> <type> <sha>\t<name>"
+		meta, name, _ := strings.Cut(line, "\t")
+		if name == readme {
+			fields := strings.Fields(meta)
+			line = fmt.Sprintf("%s blob %s\t%s", fields[0], blob, name)
+		}
+		entries = append(entries, line)
+	}
+	newTree, err := gitInput(dir, strings.Join(entries, "\n")+"\n", "mktree")
+	if err != nil {
+		return err
+	}
+	commit, err := signer.commitTree(ctx, dir, newTree, "HEAD", "Point README badges at Azure Pipelines\n", logMsg)
+	if err != nil {
+		return err
+	}
+	if _, err := runGit(ctx, nil, "-C", dir, "update-ref", "refs/heads/"+branch, commit); err != nil {
+		return fmt.Errorf("git update-ref: %v", err)
+	}
+	return nil
+}
+
+// badgeStep rewrites the Actions badges in the README of repo, migrated to
+// the target repository name, and pushes the change to branch, committed
+// through signer. If a branch policy rejects the push, the change is saved
+// with saveManualChange instead, as a warning. The clone in dir must have
+// "target" as the target remote.
+func badgeStep(ctx context.Context, t *azureTarget, dir, repo, name, branch string, signer *commitSigner, stream io.Writer, logMsg func(string)) *badgeReport {
+	r := &badgeReport{Readme: findReadme(ctx, dir)}
+	if r.Readme == "" {
+		return nil
+	}
+	content, err := runGit(ctx, nil, "-C", dir, "show", "HEAD:"+r.Readme)
+	if err != nil {
+		r.Error = fmt.Sprintf("reading %s: %v", r.Readme, err)
+		return r
+	}
+	target, err := getAzureRepo(ctx, t.org, t.project, name, t.token)
+	if err != nil {
+		r.Error = fmt.Sprintf("looking up Azure repo: %v", err)
+		return r
+	}
+	defs, err := listBuildDefinitions(ctx, t.org, t.project, target.ID, t.token)
+	if err != nil {
+		r.Error = fmt.Sprintf("listing pipelines: %v", err)
+		return r
+	}
+
+	var rewritten string
+	rewritten, r.Rewritten, r.Untouched = rewriteBadges(content, repo, defs)
+	for _, u := range r.Untouched {
+		logMsg(fmt.Sprintf("Badge left as is in %s: %s", repo, u))
+	}
+	if r.Rewritten == 0 {
+		return r
+	}
+	if err := commitReadme(ctx, dir, r.Readme, rewritten, branch, signer, logMsg); err != nil {
+		r.Error = fmt.Sprintf("committing %s: %v", r.Readme, err)
+		return r
+	}
+	if _, output, err := pushRefs(ctx, dir, "target", stream, "refs/heads/"+branch); err != nil {
+		sig := matchBranchPolicy(output + err.Error())
+		if sig == "" {
+			r.Error = fmt.Sprintf("pushing %s: %v: %s", branch, err, lastLine(output))
+			return r
+		}
+		saved, saveErr := saveManualChange(ctx, dir, t.runID, name, branch, r.Readme, rewritten)
+		if saveErr != nil {
+			r.Error = fmt.Sprintf("pushing %s was rejected by a branch policy (%s), and saving the change failed: %v", branch, sig, saveErr)
+			return r
+		}
+		r.Manual = saved
+		logMsg(fmt.Sprintf("Warning: a branch policy rejected %s of %s (%s); the README badge rewrite is saved in %s to apply by hand.", branch, repo, sig, saved))
+		return r
+	}
+	r.Branch = branch
+	logMsg(fmt.Sprintf("Rewrote %d README badge(s) of %s on branch %s.", r.Rewritten, repo, branch))
+	return r
+}
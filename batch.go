@@ -0,0 +1,156 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultUIRefreshHz is how often batched UI updates are flushed unless
+// GITUI_UI_REFRESH_HZ says otherwise.
+const defaultUIRefreshHz = 4
+
+// uiRefreshHz returns the configured UI flush rate.
+func uiRefreshHz() int {
+	if hz, err := strconv.Atoi(os.Getenv("GITUI_UI_REFRESH_HZ")); err == nil && hz > 0 {
+		return hz
+	}
+	return defaultUIRefreshHz
+}
+
+// uiBatcher coalesces widget updates so a burst of log lines or status
+// changes repaints the UI at most hz times per second. Each key keeps only
+// its latest pending update.
+type uiBatcher struct {
+	mu      sync.Mutex
+	pending map[interface{}]func()
+	order   []interface{}
+	flushes int
+}
+
+// newUIBatcher starts a batcher that flushes hz times per second for the
+// life of the process.
+func newUIBatcher(hz int) *uiBatcher {
+	b := &uiBatcher{pending: map[interface{}]func(){}}
+	go func() {
+		for range time.Tick(time.Second / time.Duration(hz)) {
+			b.Flush()
+		}
+	}()
+	return b
+}
+
+// Schedule queues fn as the next update for key, replacing any update for
+// the same key that has not been flushed yet.
+func (b *uiBatcher) Schedule(key interface{}, fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[key]; !ok {
+		b.order = append(b.order, key)
+	}
+	b.pending[key] = fn
+}
+
+// Flush runs all pending updates in the order their keys were first
+// scheduled.
+func (b *uiBatcher) Flush() {
+	b.mu.Lock()
+	if len(b.order) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	fns := make([]func(), 0, len(b.order))
+	for _, key := range b.order {
+		fns = append(fns, b.pending[key])
+	}
+	b.pending = map[interface{}]func(){}
+	b.order = nil
+	b.flushes++
+	b.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// Flushes returns how many non-empty flushes have run.
+func (b *uiBatcher) Flushes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushes
+}
+
+// logModel accumulates log lines with their levels so appending is cheap,
+// and pushes the text of those its filter lets through to the UI through a
+// batcher.
+type logModel struct {
+	mu     sync.Mutex
+	lines  []logLine
+	min    slog.Level
+	filter string // lower-cased; "" shows every line of min and above
+	ui     *uiBatcher
+	apply  func(text string)
+}
+
+// logLine is a line of the log and its level.
+type logLine struct {
+	level slog.Level
+	text  string
+}
+
+// newLogModel returns a model showing lines of every level, debug
+// included; SetFilter narrows it.
+func newLogModel(ui *uiBatcher, apply func(text string)) *logModel {
+	return &logModel{min: slog.LevelDebug, ui: ui, apply: apply}
+}
+
+// Append adds line (without trailing newline) to the log at level.
+func (m *logModel) Append(level slog.Level, line string) {
+	m.mu.Lock()
+	m.lines = append(m.lines, logLine{level, line})
+	m.mu.Unlock()
+	m.ui.Schedule(m, func() { m.apply(m.String()) })
+}
+
+// SetFilter shows only the lines of level min and above that contain text,
+// ignoring case.
+func (m *logModel) SetFilter(min slog.Level, text string) {
+	m.mu.Lock()
+	m.min, m.filter = min, strings.ToLower(strings.TrimSpace(text))
+	m.mu.Unlock()
+	m.ui.Schedule(m, func() { m.apply(m.String()) })
+}
+
+// String returns the lines the filter lets through.
+func (m *logModel) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	for _, l := range m.lines {
+		if l.level < m.min || (m.filter != "" && !strings.Contains(strings.ToLower(l.text), m.filter)) {
+			continue
+		}
+		b.WriteString(l.text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// WriteTo writes every line, whatever the filter, to w.
+func (m *logModel) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for _, l := range m.lines {
+		n, err := io.WriteString(w, l.text+"\n")
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// A burst of 100k log lines repaints the log once per flush, not once per
+// line, and the last repaint has every line.
+func TestLogModelCoalescesAppends(t *testing.T) {
+	const lines, flushEvery = 100000, 5000
+	ui := &uiBatcher{pending: map[interface{}]func(){}} // flushed by hand
+	refreshes := 0
+	var shown string
+	m := newLogModel(ui, func(text string) {
+		refreshes++
+		shown = text
+	})
+
+	for i := 0; i < lines; i++ {
+		m.Append(slog.LevelInfo, fmt.Sprintf("line %d", i))
+		if i%flushEvery == flushEvery-1 {
+			ui.Flush()
+		}
+	}
+	ui.Flush()
+
+	if want := lines / flushEvery; refreshes != want || ui.Flushes() != want {
+		t.Errorf("%d refreshes in %d flushes, want %d", refreshes, ui.Flushes(), want)
+	}
+	if got := strings.Count(shown, "\n"); got != lines {
+		t.Errorf("the last refresh shows %d lines, want %d", got, lines)
+	}
+}
+
+// BenchmarkLogModelAppend measures appending to the log between 4 Hz
+// flushes, taken as one flush per 10,000 lines, and reports the UI
+// refreshes per line.
+func BenchmarkLogModelAppend(b *testing.B) {
+	ui := &uiBatcher{pending: map[interface{}]func(){}}
+	refreshes := 0
+	m := newLogModel(ui, func(string) { refreshes++ })
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Append(slog.LevelInfo, "remote: Counting objects: 100% (1024/1024), done.")
+		if i%10000 == 9999 {
+			ui.Flush()
+		}
+	}
+	ui.Flush()
+	b.ReportMetric(float64(refreshes)/float64(b.N), "refreshes/line")
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// branchFilter selects the branches to migrate by glob pattern (path.Match
+// syntax, so "release/*" does not match "release/1/hotfix"). A branch is
+// migrated if it matches an include pattern, or there are none, and no
+// exclude pattern.
+type branchFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// parseBranchFilter parses patterns separated by commas or whitespace;
+// "!pattern" excludes.
+func parseBranchFilter(text string) (branchFilter, error) {
+	var f branchFilter
+	for _, p := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+		exclude := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		if _, err := path.Match(p, ""); err != nil {
+			return branchFilter{}, fmt.Errorf("bad branch pattern %q: %v", p, err)
+		}
+		if exclude {
+			f.Exclude = append(f.Exclude, p)
+		} else {
+			f.Include = append(f.Include, p)
+		}
+	}
+	return f, nil
+}
+
+// Empty reports whether the filter lets every branch through.
+func (f branchFilter) Empty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Matches reports whether branch (without refs/heads/) is migrated.
+func (f branchFilter) Matches(branch string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, branch); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(f.Include) == 0 || matchAny(f.Include)) && !matchAny(f.Exclude)
+}
+
+// matching returns the branches f lets through.
+func (f branchFilter) matching(branches []string) []string {
+	var out []string
+	for _, b := range branches {
+		if f.Matches(b) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// defaultBranchPreviewMax caps how many branches the preview fetches per
+// repository unless GITUI_BRANCH_PREVIEW_MAX says otherwise; beyond it the
+// preview shows "500+ branches".
+const defaultBranchPreviewMax = 500
+
+func branchPreviewMax() int {
+	if n, err := strconv.Atoi(os.Getenv("GITUI_BRANCH_PREVIEW_MAX")); err == nil && n > 0 {
+		return n
+	}
+	return defaultBranchPreviewMax
+}
+
+// branchPage is a cached page of a repository's branch list.
+type branchPage struct {
+	ETag     string
+	Branches []string
+	Next     string
+}
+
+// branchLists fetches repositories' branch lists for the filter preview,
+// lazily and once per session: pages are cached with their ETags, and
+// conditional requests answered 304 do not count against GitHub's rate
+// limit. Fetches run one at a time.
+type branchLists struct {
+	mu    sync.Mutex
+	pages map[string]*branchPage // page URL -> page
+}
+
+// branchList is a repository's branches, up to the preview cap.
+type branchList struct {
+	Branches  []string
+	Truncated bool // the repository has more than the cap
+}
+
+// CountText renders the number of branches, e.g. "42" or "500+".
+func (l branchList) CountText() string {
+	if l.Truncated {
+		return fmt.Sprintf("%d+", len(l.Branches))
+	}
+	return strconv.Itoa(len(l.Branches))
+}
+
+// Get returns repo's ("owner/repo") branches, following pagination up to
+// max branches.
+func (c *branchLists) Get(ctx context.Context, repo, token string, max int) (branchList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pages == nil {
+		c.pages = map[string]*branchPage{}
+	}
+	return collectBranches(repo, max, func(apiURL string) (*branchPage, error) {
+		return c.fetch(ctx, apiURL, token)
+	})
+}
+
+// Cached returns repo's branch list if the preview fetched it this
+// session, without any request.
+func (c *branchLists) Cached(repo string, max int) (branchList, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list, err := collectBranches(repo, max, func(apiURL string) (*branchPage, error) {
+		if page, ok := c.pages[apiURL]; ok {
+			return page, nil
+		}
+		return nil, fmt.Errorf("not fetched: %s", apiURL)
+	})
+	return list, err == nil
+}
+
+// collectBranches pages through repo's branches with page, up to max.
+func collectBranches(repo string, max int, page func(apiURL string) (*branchPage, error)) (branchList, error) {
+	var list branchList
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/branches?per_page=100", repo)
+	for apiURL != "" {
+		if len(list.Branches) >= max {
+			list.Truncated = true
+			break
+		}
+		p, err := page(apiURL)
+		if err != nil {
+			return list, err
+		}
+		list.Branches = append(list.Branches, p.Branches...)
+		apiURL = p.Next
+	}
+	if len(list.Branches) > max {
+		list.Branches, list.Truncated = list.Branches[:max], true
+	}
+	return list, nil
+}
+
+// fetch returns a page of branches, revalidating a cached copy with its
+// ETag. c.mu must be held.
+func (c *branchLists) fetch(ctx context.Context, apiURL, token string) (*branchPage, error) {
+	cached := c.pages[apiURL]
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("GitHub", resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var branches []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &branches); err != nil {
+		return nil, err
+	}
+	page := &branchPage{ETag: resp.Header.Get("ETag"), Next: nextPageURL(resp.Header.Get("Link"))}
+	for _, b := range branches {
+		page.Branches = append(page.Branches, b.Name)
+	}
+	c.pages[apiURL] = page
+	return page, nil
+}
+
+// applyBranchFilter deletes the branches f does not let through from the
+// bare clone in dir, so the push and the verification only see the rest.
+// defaultBranch is always kept. It returns the branches kept and removed.
+func applyBranchFilter(ctx context.Context, dir string, f branchFilter, defaultBranch string) (kept, removed []string, err error) {
+	out, err := runGit(ctx, nil, "-C", dir, "for-each-ref", "--format=%(refname:lstrip=2)", "refs/heads")
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing branches: %v", err)
+	}
+	for _, b := range strings.Fields(out) {
+		if f.Matches(b) || b == defaultBranch {
+			kept = append(kept, b)
+			continue
+		}
+		if _, err := runGit(ctx, nil, branchDeleteArgs(dir, b)...); err != nil {
+			return kept, removed, fmt.Errorf("removing %s: %v", b, err)
+		}
+		removed = append(removed, b)
+	}
+	return kept, removed, nil
+}
+
+// previewDifferences compares the branches the preview showed as matching
+// with the ones actually kept, and describes the differences: branches
+// created or deleted since the preview, beyond its cap, or the default
+// branch kept although the filter excludes it.
+func previewDifferences(preview branchList, f branchFilter, kept []string, defaultBranch string) []string {
+	listed := map[string]bool{}
+	for _, b := range preview.Branches {
+		listed[b] = true
+	}
+	previewed := map[string]bool{}
+	for _, b := range f.matching(preview.Branches) {
+		previewed[b] = true
+	}
+	var diffs []string
+	actual := map[string]bool{}
+	for _, b := range kept {
+		actual[b] = true
+		switch {
+		case previewed[b]:
+			// As previewed.
+		case b == defaultBranch && !f.Matches(b):
+			diffs = append(diffs, fmt.Sprintf("%s is migrated although the filter excludes it: it is the default branch", b))
+		case !listed[b] && preview.Truncated:
+			diffs = append(diffs, fmt.Sprintf("%s is migrated but was beyond the preview's %d branches", b, len(preview.Branches)))
+		default:
+			diffs = append(diffs, fmt.Sprintf("%s is migrated but was not in the preview", b))
+		}
+	}
+	for b := range previewed {
+		if !actual[b] {
+			diffs = append(diffs, fmt.Sprintf("%s was in the preview but is not in the clone", b))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testBareRepo returns a bare repository with one commit on each of
+// branches, the first of which is its HEAD.
+func testBareRepo(t *testing.T, branches ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	work, bare := filepath.Join(dir, "work"), filepath.Join(dir, "repo.git")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com", "GIT_CONFIG_GLOBAL=/dev/null")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "-q", "--bare", bare)
+	git("init", "-q", work)
+	git("-C", work, "commit", "-q", "--allow-empty", "-m", "initial")
+	for _, b := range branches {
+		git("-C", work, "push", "-q", bare, "HEAD:refs/heads/"+b)
+	}
+	git("-C", bare, "symbolic-ref", "HEAD", "refs/heads/"+branches[0])
+	return bare
+}
+
+// Default branches other than main or master, one with a slash, are kept
+// whatever the filter says.
+func TestApplyBranchFilterKeepsDefaultBranch(t *testing.T) {
+	tests := []struct {
+		defaultBranch string
+		filter        string
+		kept          []string
+	}{
+		{"trunk", "feature/*", []string{"feature/x", "trunk"}},
+		{"production", "!production", []string{"feature/x", "main", "production", "release/2024", "trunk"}},
+		{"release/2024", "main", []string{"main", "release/2024"}},
+	}
+	for _, tt := range tests {
+		dir := testBareRepo(t, tt.defaultBranch, "main", "trunk", "production", "release/2024", "feature/x")
+		f, err := parseBranchFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept, _, err := applyBranchFilter(t.Context(), dir, f, tt.defaultBranch)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.defaultBranch, err)
+		}
+		if !reflect.DeepEqual(kept, tt.kept) {
+			t.Errorf("default %s, filter %q: kept %v, want %v", tt.defaultBranch, tt.filter, kept, tt.kept)
+		}
+	}
+}
+
+func TestPreviewDifferencesNamesExcludedDefaultBranch(t *testing.T) {
+	f, _ := parseBranchFilter("!release/*")
+	preview := branchList{Branches: []string{"main", "release/2024"}}
+	diffs := previewDifferences(preview, f, []string{"main", "release/2024"}, "release/2024")
+	want := []string{"release/2024 is migrated although the filter excludes it: it is the default branch"}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("previewDifferences = %q, want %q", diffs, want)
+	}
+}
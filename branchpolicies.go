@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf16"
+)
+
+// GitHub branch protection on a repository's default branch is translated
+// into Azure DevOps branch policies on the migrated default branch by a
+// content feature, once the repository is migrated:
+//
+//   - required reviews become the minimum number of reviewers policy;
+//   - required status checks become build validation, for each check that
+//     names a pipeline of the repository;
+//   - required conversation resolution becomes the comment requirements
+//     policy;
+//   - without force pushes allowed, force pushing to the branch is denied
+//     to Project Valid Users.
+//
+// Every policy created is blocking. Code owner reviews, push restrictions
+// and signed commits have no equivalent policy and are left out.
+
+// Well-known Azure DevOps policy type IDs.
+const (
+	minReviewersPolicy = "fa4e907d-c16b-4a4c-9dfa-4906e5d171dd"
+	buildPolicy        = "0609b952-1397-4640-95ec-e00a01b2c241"
+	commentsPolicy     = "c6a1889d-b943-4856-b76f-9e46bb6b0df2"
+)
+
+// denyForcePushBit is the Git Repositories Force push permission.
+const denyForcePushBit = 8
+
+// gitHubProtection is the subset of the GitHub branch protection API
+// object the migration uses.
+type gitHubProtection struct {
+	RequiredPullRequestReviews *struct {
+		RequiredApprovingReviewCount int  `json:"required_approving_review_count"`
+		DismissStaleReviews          bool `json:"dismiss_stale_reviews"`
+	} `json:"required_pull_request_reviews"`
+	RequiredStatusChecks *struct {
+		Strict   bool     `json:"strict"`
+		Contexts []string `json:"contexts"`
+		Checks   []struct {
+			Context string `json:"context"`
+		} `json:"checks"`
+	} `json:"required_status_checks"`
+	AllowForcePushes struct {
+		Enabled bool `json:"enabled"`
+	} `json:"allow_force_pushes"`
+	RequiredConversationResolution struct {
+		Enabled bool `json:"enabled"`
+	} `json:"required_conversation_resolution"`
+}
+
+// statusChecks returns the names of the required status checks.
+func (p *gitHubProtection) statusChecks() []string {
+	if p.RequiredStatusChecks == nil {
+		return nil
+	}
+	if len(p.RequiredStatusChecks.Checks) == 0 {
+		return p.RequiredStatusChecks.Contexts
+	}
+	var names []string
+	for _, c := range p.RequiredStatusChecks.Checks {
+		names = append(names, c.Context)
+	}
+	return names
+}
+
+// getGitHubProtection returns the protection of repo's branch, or nil if
+// it is not protected. Reading it takes admin rights on the repository.
+func getGitHubProtection(ctx context.Context, repo, branch, token string) (*gitHubProtection, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/branches/%s/protection", repo, url.PathEscape(branch))
+	var p gitHubProtection
+	_, err := gitHubGet(ctx, apiURL, token, &p)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// policyScope is the scope of a branch policy.
+type policyScope struct {
+	RepositoryID string `json:"repositoryId"`
+	RefName      string `json:"refName"`
+	MatchKind    string `json:"matchKind"`
+}
+
+// azurePolicy is the subset of the policy configuration API object the
+// migration uses; the settings of each policy type share the one struct.
+type azurePolicy struct {
+	ID         int  `json:"id"`
+	IsEnabled  bool `json:"isEnabled"`
+	IsBlocking bool `json:"isBlocking"`
+	Type       struct {
+		ID string `json:"id"`
+	} `json:"type"`
+	Settings struct {
+		MinimumApproverCount    int           `json:"minimumApproverCount"`
+		CreatorVoteCounts       bool          `json:"creatorVoteCounts"`
+		ResetOnSourcePush       bool          `json:"resetOnSourcePush"`
+		BuildDefinitionID       int           `json:"buildDefinitionId"`
+		QueueOnSourceUpdateOnly bool          `json:"queueOnSourceUpdateOnly"`
+		ValidDuration           float64       `json:"validDuration"`
+		Scope                   []policyScope `json:"scope"`
+	} `json:"settings"`
+}
+
+// branchPolicies returns the policies of repo's branch.
+func branchPolicies(ctx context.Context, t *azureTarget, repo *azureRepo, branch string) ([]azurePolicy, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/policy/configurations?repositoryId=%s&refName=%s&api-version=7.0",
+		t.org, url.PathEscape(t.project), repo.ID, url.QueryEscape(branchRef(branch)))
+	var result struct {
+		Value []azurePolicy `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, t.token, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	// Policies scoped to the whole project or by prefix are listed too;
+	// only those on exactly this branch are the migration's to change.
+	var policies []azurePolicy
+	for _, p := range result.Value {
+		for _, s := range p.Settings.Scope {
+			if strings.EqualFold(s.RepositoryID, repo.ID) && strings.EqualFold(s.RefName, branchRef(branch)) && strings.EqualFold(s.MatchKind, "exact") {
+				policies = append(policies, p)
+				break
+			}
+		}
+	}
+	return policies, nil
+}
+
+// savePolicy creates a blocking policy of policyType with settings on the
+// branch, or replaces policy id's configuration if id is not 0.
+func savePolicy(ctx context.Context, t *azureTarget, id int, policyType string, settings map[string]interface{}, repo *azureRepo, branch string) error {
+	settings["scope"] = []policyScope{{RepositoryID: repo.ID, RefName: branchRef(branch), MatchKind: "exact"}}
+	payload := map[string]interface{}{
+		"isEnabled":  true,
+		"isBlocking": true,
+		"type":       map[string]string{"id": policyType},
+		"settings":   settings,
+	}
+	apiURL := fmt.Sprintf("%s/%s/_apis/policy/configurations?api-version=7.0", t.org, url.PathEscape(t.project))
+	if id == 0 {
+		return azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusOK, nil)
+	}
+	apiURL = fmt.Sprintf("%s/%s/_apis/policy/configurations/%d?api-version=7.0", t.org, url.PathEscape(t.project), id)
+	return azureRequest(ctx, "PUT", apiURL, t.token, payload, http.StatusOK, nil)
+}
+
+// branchSecurityToken is the Git Repositories security token for a branch
+// of repo: each segment of the branch name is hex-encoded UTF-16LE.
+func branchSecurityToken(repo *azureRepo, branch string) string {
+	var segments []string
+	for _, s := range strings.Split(branch, "/") {
+		var b []byte
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u), byte(u>>8))
+		}
+		segments = append(segments, hex.EncodeToString(b))
+	}
+	return repoSecurityToken(repo) + "/refs/heads/" + strings.Join(segments, "/")
+}
+
+// forcePushDenied reports whether descriptor is denied force pushing to
+// the branch whose security token is token.
+func forcePushDenied(ctx context.Context, t *azureTarget, token, descriptor string) (bool, error) {
+	apiURL := fmt.Sprintf("%s/_apis/accesscontrollists/%s?token=%s&descriptors=%s&api-version=7.0",
+		t.org, gitSecurityNamespace, url.QueryEscape(token), url.QueryEscape(descriptor))
+	var result struct {
+		Value []struct {
+			AcesDictionary map[string]struct {
+				Deny int `json:"deny"`
+			} `json:"acesDictionary"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, t.token, nil, http.StatusOK, &result); err != nil {
+		return false, err
+	}
+	for _, acl := range result.Value {
+		for _, ace := range acl.AcesDictionary {
+			if ace.Deny&denyForcePushBit != 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// denyForcePush denies descriptor force pushing to the branch whose
+// security token is token.
+func denyForcePush(ctx context.Context, t *azureTarget, token, descriptor string) error {
+	apiURL := fmt.Sprintf("%s/_apis/accesscontrolentries/%s?api-version=7.0", t.org, gitSecurityNamespace)
+	payload := map[string]interface{}{
+		"token": token,
+		"merge": true,
+		"accessControlEntries": []map[string]interface{}{
+			{"descriptor": descriptor, "allow": 0, "deny": denyForcePushBit},
+		},
+	}
+	return azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusOK, nil)
+}
+
+// policyOp returns the operation that makes the branch's policy of
+// policyType match settings, described by detail, or nil if existing, the
+// branch's current policy of the type, already does. same compares an
+// existing policy's settings.
+func policyOp(t *azureTarget, what, detail string, existing *azurePolicy, same func(*azurePolicy) bool, policyType string, settings map[string]interface{}, repo *azureRepo, branch string) *contentOp {
+	op := &contentOp{Change: "+", What: what, Detail: detail}
+	id := 0
+	if existing != nil {
+		if existing.IsEnabled && existing.IsBlocking && same(existing) {
+			return nil
+		}
+		op.Change, id = "~", existing.ID
+	}
+	op.apply = func(ctx context.Context) error { return savePolicy(ctx, t, id, policyType, settings, repo, branch) }
+	return op
+}
+
+// branchPoliciesFeature previews translating the branch protection of each
+// repository's GitHub default branch into policies on the migrated one.
+func branchPoliciesFeature() *contentFeature {
+	return &contentFeature{
+		Name: "Branch protection as branch policies",
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			meta, err := getGitHubRepo(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("fetching GitHub metadata: %v", err)
+			}
+			protection, err := getGitHubProtection(ctx, repo.Source, meta.DefaultBranch, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("reading the protection of %s: %v", meta.DefaultBranch, err)
+			}
+			if protection == nil {
+				return nil, nil
+			}
+			target, err := getAzureRepo(ctx, t.org, t.project, repo.Target, t.token)
+			if err != nil {
+				return nil, fmt.Errorf("looking up Azure repo: %v", err)
+			}
+			branch := strings.TrimPrefix(target.DefaultBranch, "refs/heads/")
+			if branch == "" {
+				return nil, errors.New("the Azure repo has no default branch")
+			}
+			policies, err := branchPolicies(ctx, t, target, branch)
+			if err != nil {
+				return nil, fmt.Errorf("listing the policies of %s: %v", branch, err)
+			}
+			existing := func(policyType string, match func(*azurePolicy) bool) *azurePolicy {
+				for i := range policies {
+					if p := &policies[i]; p.Type.ID == policyType && match(p) {
+						return p
+					}
+				}
+				return nil
+			}
+			anyPolicy := func(*azurePolicy) bool { return true }
+
+			var ops []*contentOp
+			if reviews := protection.RequiredPullRequestReviews; reviews != nil {
+				// GitHub can require a pull request with no approvals;
+				// Azure DevOps wants at least one reviewer, so the author's
+				// own approval counts then.
+				count, creatorVotes := reviews.RequiredApprovingReviewCount, false
+				if count == 0 {
+					count, creatorVotes = 1, true
+				}
+				settings := map[string]interface{}{
+					"minimumApproverCount": count,
+					"creatorVoteCounts":    creatorVotes,
+					"resetOnSourcePush":    reviews.DismissStaleReviews,
+				}
+				same := func(p *azurePolicy) bool {
+					return p.Settings.MinimumApproverCount == count && p.Settings.CreatorVoteCounts == creatorVotes &&
+						p.Settings.ResetOnSourcePush == reviews.DismissStaleReviews
+				}
+				detail := fmt.Sprintf("%d reviewer(s), author's vote counts: %t, reset on push: %t", count, creatorVotes, reviews.DismissStaleReviews)
+				if op := policyOp(t, "minimum reviewers policy on "+branch, detail, existing(minReviewersPolicy, anyPolicy), same,
+					minReviewersPolicy, settings, target, branch); op != nil {
+					ops = append(ops, op)
+				}
+			}
+
+			var untranslated []string
+			if checks := protection.statusChecks(); len(checks) > 0 {
+				defs, err := listBuildDefinitions(ctx, t.org, t.project, target.ID, t.token)
+				if err != nil {
+					return nil, fmt.Errorf("listing pipelines: %v", err)
+				}
+				byName := map[string]int{}
+				for _, d := range defs {
+					byName[strings.ToLower(d.Name)] = d.ID
+				}
+				// A strict check must pass against the latest target
+				// branch, so its build expires as the branch is updated.
+				strict := protection.RequiredStatusChecks.Strict
+				for _, check := range checks {
+					id, ok := byName[strings.ToLower(check)]
+					if !ok {
+						untranslated = append(untranslated, check)
+						continue
+					}
+					settings := map[string]interface{}{
+						"buildDefinitionId":       id,
+						"displayName":             check,
+						"queueOnSourceUpdateOnly": !strict,
+						"manualQueueOnly":         false,
+						"validDuration":           0,
+					}
+					same := func(p *azurePolicy) bool {
+						return p.Settings.QueueOnSourceUpdateOnly == !strict && p.Settings.ValidDuration == 0
+					}
+					byDefinition := func(p *azurePolicy) bool { return p.Settings.BuildDefinitionID == id }
+					detail := fmt.Sprintf("pipeline %d, expires when %s is updated: %t", id, branch, strict)
+					if op := policyOp(t, fmt.Sprintf("build validation %q on %s", check, branch), detail, existing(buildPolicy, byDefinition), same,
+						buildPolicy, settings, target, branch); op != nil {
+						ops = append(ops, op)
+					}
+				}
+			}
+
+			if protection.RequiredConversationResolution.Enabled {
+				if op := policyOp(t, "comment requirements policy on "+branch, "all comments resolved", existing(commentsPolicy, anyPolicy), anyPolicy,
+					commentsPolicy, map[string]interface{}{}, target, branch); op != nil {
+					ops = append(ops, op)
+				}
+			}
+
+			if !protection.AllowForcePushes.Enabled {
+				descriptor, err := projectValidUsers(ctx, t.org, t.project, t.token)
+				if err != nil {
+					return nil, fmt.Errorf("resolving Project Valid Users: %v", err)
+				}
+				token := branchSecurityToken(target, branch)
+				denied, err := forcePushDenied(ctx, t, token, descriptor)
+				if err != nil {
+					return nil, fmt.Errorf("reading the permissions of %s: %v", branch, err)
+				}
+				if !denied {
+					ops = append(ops, &contentOp{
+						Change: "+",
+						What:   "deny force push on " + branch,
+						Detail: "to Project Valid Users",
+						apply:  func(ctx context.Context) error { return denyForcePush(ctx, t, token, descriptor) },
+					})
+				}
+			}
+
+			if len(untranslated) > 0 {
+				note := "no pipeline named after required check(s) " + strings.Join(untranslated, ", ")
+				if len(ops) == 0 {
+					return nil, errors.New(note)
+				}
+				last := ops[len(ops)-1]
+				last.Detail += "; " + note
+			}
+			return ops, nil
+		},
+	}
+}
@@ -0,0 +1,94 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showBranchPreview opens the branch filter preview: pick a source
+// repository to see how many of its branches filterEntry's patterns
+// select. Branch lists are only fetched for the repositories previewed.
+func showBranchPreview(w fyne.Window, lists *branchLists, filterEntry *widget.Entry, org, githubToken string, logMsg func(string)) {
+	if githubToken == "" {
+		dialog.ShowInformation("Branch preview", "Fill in the GitHub PAT first.", w)
+		return
+	}
+	// Closing the dialog cancels a fetch in progress.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var repoNames []string
+	repos, err := listGitHubRepos(ctx, org, githubToken)
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not list GitHub repositories: %v", err))
+	}
+	for _, r := range repos {
+		repoNames = append(repoNames, r.FullName)
+	}
+
+	// Edits here are the main form's filter.
+	patternsEntry := widget.NewEntry()
+	patternsEntry.SetText(filterEntry.Text)
+	patternsEntry.OnChanged = filterEntry.SetText
+
+	repoSelect := widget.NewSelectEntry(repoNames)
+	repoSelect.SetPlaceHolder("owner/repo")
+	summary := widget.NewLabel("Pick a repository to preview.")
+	summary.Wrapping = fyne.TextWrapWord
+	var shown []string
+	list := widget.NewList(
+		func() int { return len(shown) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) { o.(*widget.Label).SetText(shown[i]) },
+	)
+
+	var previewBtn *widget.Button
+	previewBtn = widget.NewButton("Preview", func() {
+		repo := strings.TrimSpace(repoSelect.Text)
+		if repo == "" {
+			return
+		}
+		filter, err := parseBranchFilter(patternsEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		previewBtn.Disable()
+		summary.SetText(fmt.Sprintf("Fetching the branches of %s...", repo))
+		go func() {
+			defer previewBtn.Enable()
+			branches, err := lists.Get(ctx, repo, githubToken, branchPreviewMax())
+			if err != nil {
+				summary.SetText(fmt.Sprintf("Could not list the branches of %s: %v", repo, err))
+				return
+			}
+			shown = filter.matching(branches.Branches)
+			summary.SetText(fmt.Sprintf("%d of %s branches of %s match. The clone's actual branches decide at push time.",
+				len(shown), branches.CountText(), repo))
+			list.Refresh()
+		}()
+	})
+
+	content := container.NewBorder(
+		container.NewVBox(
+			widget.NewForm(
+				widget.NewFormItem("Repository", container.NewBorder(nil, nil, nil, previewBtn, repoSelect)),
+				widget.NewFormItem("Branches", patternsEntry),
+			),
+			summary,
+		),
+		nil, nil, nil,
+		list,
+	)
+	d := dialog.NewCustom("Branch filter preview", "Close", content, w)
+	d.SetOnClosed(cancel)
+	d.Resize(fyne.NewSize(700, 500))
+	d.Show()
+}
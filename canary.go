@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A push verified right after it lands can still go wrong: background
+// maintenance on the target has been seen to serve incomplete ref listings
+// for a while, and a problem that shows up later would go unnoticed. Canary
+// checks list the target's refs again a while after each repository is
+// verified, and compare them with what was pushed.
+
+// defaultCanaryMinutes is how long after a repository is verified its
+// canary check runs, unless configured.
+const defaultCanaryMinutes = 30
+
+// parseCanaryDelay parses the canary check delay, in minutes; blank means
+// GITUI_CANARY_MINUTES, and that blank defaultCanaryMinutes. 0 turns canary
+// checks off.
+func parseCanaryDelay(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = os.Getenv("GITUI_CANARY_MINUTES")
+	}
+	if text == "" {
+		return defaultCanaryMinutes * time.Minute, nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 || n > 24*60 {
+		return 0, fmt.Errorf("canary check delay must be a number of minutes from 1 to %d, or 0 for none", 24*60)
+	}
+	return time.Duration(n) * time.Minute, nil
+}
+
+// canaryCheck is a scheduled check of one repository.
+type canaryCheck struct {
+	report   *runReport
+	result   *repoReport
+	url      string            // the target's authenticated URL, kept in memory only
+	expected map[string]string // the refs that were verified
+	due      time.Time
+}
+
+// canaryChecks schedules canary checks and runs them in the background,
+// outliving the run that scheduled them. Each outcome updates the
+// repository's status, its run report and the migration state, and is
+// passed to OnResult; OnChange is called whenever the pending checks
+// change. The checks are lost if the app exits first, so the pending ones
+// are listed for the operator.
+type canaryChecks struct {
+	Delay    time.Duration
+	OnResult func(result *repoReport, problems []string, err error)
+	OnChange func()
+
+	mu      sync.Mutex
+	pending map[*repoReport]*canaryCheck
+}
+
+// Schedule checks result, of the run report, Delay from now: the refs of
+// the repository at url should still be expected. It does nothing if
+// canary checks are off.
+func (c *canaryChecks) Schedule(report *runReport, result *repoReport, url string, expected map[string]string) {
+	if c == nil || c.Delay <= 0 {
+		return
+	}
+	check := &canaryCheck{report: report, result: result, url: url, expected: expected, due: time.Now().Add(c.Delay)}
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = map[*repoReport]*canaryCheck{}
+	}
+	c.pending[result] = check
+	c.mu.Unlock()
+	time.AfterFunc(c.Delay, func() { c.run(check) })
+	c.changed()
+}
+
+func (c *canaryChecks) changed() {
+	if c.OnChange != nil {
+		c.OnChange()
+	}
+}
+
+// run checks the repository and records the outcome. The check comes
+// after its run has ended, so it is not cancelled with it.
+func (c *canaryChecks) run(check *canaryCheck) {
+	out, err := runGit(context.Background(), nil, "ls-remote", "--heads", "--tags", check.url)
+	var problems []string
+	if err == nil {
+		problems = checkPushedRefs(check.expected, parseRefs(out))
+	} else {
+		err = fmt.Errorf("listing the target's refs: %v: %s", err, lastLine(redactText(out, nil)))
+	}
+	result := check.result
+	now := fileTimestamp(time.Now())
+	switch {
+	case err != nil:
+		result.Canary = fmt.Sprintf("could not run at %s: %v", now, err)
+	case len(problems) > 0:
+		result.Status, result.Verified = statusLateFailure, false
+		result.Canary = fmt.Sprintf("failed at %s, %d ref(s) differ: %s", now, len(problems), strings.Join(problems, "; "))
+	default:
+		result.Status = statusConfirmed
+		result.Canary = "confirmed at " + now
+	}
+	if err == nil {
+		recordLateOutcome(check.report.ID, result)
+		check.report.save()
+	}
+	c.mu.Lock()
+	delete(c.pending, result)
+	c.mu.Unlock()
+	if c.OnResult != nil {
+		c.OnResult(result, problems, err)
+	}
+	c.changed()
+}
+
+// Pending lists the pending checks, soonest first, as "repo at 15:04".
+func (c *canaryChecks) Pending() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	var checks []*canaryCheck
+	for _, check := range c.pending {
+		checks = append(checks, check)
+	}
+	c.mu.Unlock()
+	sort.Slice(checks, func(i, j int) bool { return checks[i].due.Before(checks[j].due) })
+	var lines []string
+	for _, check := range checks {
+		lines = append(lines, fmt.Sprintf("%s at %s", check.result.Source, check.due.Local().Format("15:04")))
+	}
+	return lines
+}
+
+// Describe says whether and when canary checks run, for the log.
+func (c *canaryChecks) Describe() string {
+	if c == nil || c.Delay <= 0 {
+		return "Canary checks are off."
+	}
+	return fmt.Sprintf("Each verified repository is checked again %s later; keep the app open until the checks are done.", formatDuration(c.Delay))
+}
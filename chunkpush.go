@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Branches with long histories can be pushed in chunks of commits, so no
+// single push is big enough to hit the target's pack size limit or a
+// proxy's timeout: each branch is pushed to a commit chunk-size commits
+// further along its first-parent history at a time, and the usual push of
+// all branches then sends what is left.
+//
+// maxChunkSize caps the chunk size setting and how far tuning grows it.
+const maxChunkSize = 100000
+
+// chunkGrowAfter is how many chunk pushes to a host must succeed in a row
+// before its chunk size is grown.
+const chunkGrowAfter = 3
+
+// parseChunkSize parses the chunk size setting, in commits; blank means
+// GITUI_CHUNK_SIZE, and that blank 0, which pushes branches whole.
+func parseChunkSize(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = os.Getenv("GITUI_CHUNK_SIZE")
+	}
+	if text == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 || n > maxChunkSize {
+		return 0, fmt.Errorf("chunk size must be a number of commits from 1 to %d, or 0 to push branches whole", maxChunkSize)
+	}
+	return n, nil
+}
+
+// chunkFailureOutput are git and curl messages of a push that was too big
+// for the target or the network: size limits, and timeouts and dropped
+// connections in the middle of sending a pack.
+var chunkFailureOutput = []string{
+	"http 413",
+	"returned error: 413",
+	"request entity too large",
+	"exceeded the maximum",
+	"exceeds the maximum",
+	"pack exceeds",
+	"too large",
+	"timed out",
+	"timeout",
+	"the remote end hung up unexpectedly",
+	"rpc failed",
+	"early eof",
+	"connection reset",
+}
+
+// chunkFailure returns the message in a failed push's output that says it
+// was too big, or "" if it failed for some other reason.
+func chunkFailure(output string) string {
+	lower := strings.ToLower(output)
+	for _, s := range chunkFailureOutput {
+		if !strings.Contains(lower, s) {
+			continue
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if strings.Contains(strings.ToLower(line), s) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return ""
+}
+
+// chunkTuner picks the chunk size for pushes to each host. It starts from
+// Configured, or the size learned for the host on earlier runs; halves it
+// when a chunk fails as too big, to push that chunk again; and grows it
+// by half after chunkGrowAfter chunks in a row succeed. Learned sizes go
+// to OnLearn, to be kept for future runs. With Fixed it always uses
+// Configured and never adjusts it.
+type chunkTuner struct {
+	Configured int // commits; 0 pushes branches whole
+	Fixed      bool
+	OnLearn    func(learned map[string]int)
+
+	mu      sync.Mutex
+	learned map[string]int
+	streak  map[string]int
+}
+
+// newChunkTuner returns a tuner starting from configured commits, or from
+// learned, the sizes kept from earlier runs by host.
+func newChunkTuner(configured int, fixed bool, learned map[string]int) *chunkTuner {
+	t := &chunkTuner{Configured: configured, Fixed: fixed, learned: map[string]int{}, streak: map[string]int{}}
+	for host, size := range learned {
+		if size > 0 && size <= maxChunkSize {
+			t.learned[host] = size
+		}
+	}
+	return t
+}
+
+// parseLearnedChunkSizes parses the learned chunk sizes as kept in the
+// preferences; anything unreadable is dropped.
+func parseLearnedChunkSizes(text string) map[string]int {
+	learned := map[string]int{}
+	if text != "" {
+		json.Unmarshal([]byte(text), &learned)
+	}
+	return learned
+}
+
+// formatLearnedChunkSizes formats learned chunk sizes for the preferences.
+func formatLearnedChunkSizes(learned map[string]int) string {
+	data, _ := json.Marshal(learned)
+	return string(data)
+}
+
+// Size returns the chunk size for host; 0 pushes branches whole.
+func (t *chunkTuner) Size(host string) int {
+	if t == nil || t.Configured == 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size(host)
+}
+
+func (t *chunkTuner) size(host string) int {
+	if size, ok := t.learned[host]; ok && !t.Fixed {
+		return size
+	}
+	return t.Configured
+}
+
+// Describe says how branches are pushed, for the log.
+func (t *chunkTuner) Describe() string {
+	switch {
+	case t == nil || t.Configured == 0:
+		return "Branches are pushed whole."
+	case t.Fixed:
+		return fmt.Sprintf("Branches are pushed in chunks of %d commits (fixed).", t.Configured)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var learned []string
+	for host, size := range t.learned {
+		learned = append(learned, fmt.Sprintf("%s %d", host, size))
+	}
+	if len(learned) == 0 {
+		return fmt.Sprintf("Branches are pushed in chunks of %d commits, tuned by how pushes go.", t.Configured)
+	}
+	return fmt.Sprintf("Branches are pushed in chunks of %d commits, tuned by how pushes go; learned so far: %s.", t.Configured, strings.Join(learned, ", "))
+}
+
+// set records size as learned for host, logging why, and passes the
+// learned sizes on to be kept. t.mu is held.
+func (t *chunkTuner) set(host string, size int, why string, logMsg func(string)) {
+	logMsg(fmt.Sprintf("Chunk size for %s: %d -> %d commits (%s).", host, t.size(host), size, why))
+	t.learned[host] = size
+	if t.OnLearn != nil {
+		learned := map[string]int{}
+		for h, s := range t.learned {
+			learned[h] = s
+		}
+		t.OnLearn(learned)
+	}
+}
+
+// failed halves host's chunk size after a chunk failed with trigger. It
+// returns false if the size is fixed or already a single commit, so there
+// is nothing smaller to try.
+func (t *chunkTuner) failed(host, trigger string, logMsg func(string)) bool {
+	if t.Fixed {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streak[host] = 0
+	size := t.size(host)
+	if size <= 1 {
+		return false
+	}
+	t.set(host, size/2, "a chunk push failed: "+trigger, logMsg)
+	return true
+}
+
+// succeeded counts a chunk that pushed, growing host's chunk size after
+// chunkGrowAfter in a row.
+func (t *chunkTuner) succeeded(host string, logMsg func(string)) {
+	if t.Fixed {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streak[host]++
+	if t.streak[host] < chunkGrowAfter {
+		return
+	}
+	t.streak[host] = 0
+	size := t.size(host)
+	grown := size + (size+1)/2
+	if grown > maxChunkSize {
+		grown = maxChunkSize
+	}
+	if grown != size {
+		t.set(host, grown, fmt.Sprintf("%d chunk pushes in a row succeeded", chunkGrowAfter), logMsg)
+	}
+}
+
+// pushBranches pushes every branch of the clone in dir to remote in
+// chunks, up to the last full chunk; pushing all branches afterwards
+// sends the rest. It does nothing if branches are pushed whole. A chunk
+// that fails as too big is pushed again smaller; one that fails any other
+// way, or is a single commit already, fails the push.
+func (t *chunkTuner) pushBranches(ctx context.Context, dir, remote string, stream io.Writer, logMsg func(string)) error {
+	if t == nil || t.Configured == 0 {
+		return nil
+	}
+	host := remoteHost(ctx, dir, remote)
+	out, err := runGit(ctx, nil, "-C", dir, "for-each-ref", "--format=%(refname)", "refs/heads")
+	if err != nil {
+		return fmt.Errorf("listing branches: %v, output: %s", err, out)
+	}
+	// A branch an earlier attempt got part way with goes on from there;
+	// chunks behind it would be refused as not fast-forward.
+	remoteOut, err := runGit(ctx, nil, "-C", dir, "ls-remote", "--heads", remote)
+	if err != nil {
+		return fmt.Errorf("listing the branches of %s: %v, output: %s", remote, err, remoteOut)
+	}
+	remoteTips := map[string]string{}
+	for _, line := range strings.Split(remoteOut, "\n") {
+		if f := strings.Fields(line); len(f) == 2 {
+			remoteTips[f[1]] = f[0]
+		}
+	}
+	for _, ref := range strings.Fields(out) {
+		list, err := runGit(ctx, nil, "-C", dir, "rev-list", "--first-parent", "--reverse", ref)
+		if err != nil {
+			return fmt.Errorf("listing the commits of %s: %v, output: %s", ref, err, list)
+		}
+		commits := strings.Fields(list)
+		start := 0
+		if tip, ok := remoteTips[ref]; ok {
+			// Not on the branch's first-parent line, the tip is left to
+			// the push of all branches to sort out.
+			start = len(commits)
+			for i, c := range commits {
+				if c == tip {
+					start = i + 1
+				}
+			}
+		}
+		for pos := start; ; {
+			size := t.Size(host)
+			if len(commits)-pos <= size {
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			end := pos + size
+			refs, output, err := pushRefs(ctx, dir, remote, stream, commits[end-1]+":"+ref)
+			if err == nil {
+				logMsg(fmt.Sprintf("Pushed %s up to commit %d of %d, in a chunk of %d.", strings.TrimPrefix(ref, "refs/heads/"), end, len(commits), size))
+				t.succeeded(host, logMsg)
+				pos = end
+				continue
+			}
+			trigger := chunkFailure(output)
+			if trigger == "" || ctx.Err() != nil || !t.failed(host, trigger, logMsg) {
+				why := trigger
+				for _, r := range refs {
+					if !r.OK {
+						why = orDefault(why, r.Summary)
+					}
+				}
+				if why == "" {
+					why = fmt.Sprintf("%v, output: %s", err, output)
+				}
+				return fmt.Errorf("pushing a chunk of %s: %s", ref, why)
+			}
+		}
+	}
+	return nil
+}
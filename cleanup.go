@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retainedCopies tracks local copies that were kept instead of deleted
+// because their repository failed verification, so the user can delete them
+// explicitly later.
+type retainedCopies struct {
+	mu   sync.Mutex
+	dirs map[string]string // repo -> local directory
+}
+
+func (r *retainedCopies) Add(repo, dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dirs == nil {
+		r.dirs = map[string]string{}
+	}
+	r.dirs[repo] = dir
+}
+
+// Repos returns the repositories with a retained copy, sorted.
+func (r *retainedCopies) Repos() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var repos []string
+	for repo := range r.dirs {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// Take removes repo from the set and returns its directory.
+func (r *retainedCopies) Take(repo string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dir, ok := r.dirs[repo]
+	delete(r.dirs, repo)
+	return dir, ok
+}
+
+// failedClonesDir holds the clones of repositories whose migration failed,
+// kept as evidence and for a quick retry.
+var failedClonesDir = filepath.Join("clones", "failed")
+
+// failedCloneMarker is written into a kept failure clone to record which
+// repository it is and when it failed.
+const failedCloneMarker = "gitui-failed.json"
+
+// defaultFailedCloneDays is how long failure clones are kept by default.
+const defaultFailedCloneDays = 7
+
+// cleanupPolicy decides what happens to a repository's local clone once its
+// migration is over.
+type cleanupPolicy struct {
+	// Copies decides what becomes of the clones of verified repositories.
+	Copies localCopies
+	// KeepFailed keeps clones of failed repositories in failedClonesDir
+	// instead of deleting them.
+	KeepFailed bool
+	// FailedExpiry is how long kept failure clones are kept; older ones are
+	// offered for deletion at startup. Zero keeps them until deleted by hand.
+	FailedExpiry time.Duration
+}
+
+// parseExpiryDays parses a number of days to keep failure clones; 0 keeps
+// them until deleted by hand.
+func parseExpiryDays(text string) (time.Duration, error) {
+	days, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("expiry must be a number of days, 0 to keep failure clones: %q", text)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// failedClone is a kept clone of a failed repository.
+type failedClone struct {
+	Repo   string    `json:"repo"`
+	Failed time.Time `json:"failed"`
+	Error  string    `json:"error,omitempty"`
+	Dir    string    `json:"-"`
+}
+
+// Failed applies the policy to the clone in dir of repo, which failed with
+// err, and returns the decision for the report and where the clone is
+// kept, "" if it was deleted.
+func (p cleanupPolicy) Failed(dir, repo string, err error) (string, string) {
+	if !p.KeepFailed {
+		if rerr := os.RemoveAll(dir); rerr != nil {
+			return fmt.Sprintf("deleting failed clone: %v", rerr), dir
+		}
+		return "deleted (failed)", ""
+	}
+	dest := filepath.Join(failedClonesDir, strings.ReplaceAll(repo, "/", "_"))
+	if rerr := os.MkdirAll(failedClonesDir, 0755); rerr != nil {
+		return fmt.Sprintf("keeping failed clone: %v", rerr), dir
+	}
+	// A clone kept from an earlier failure makes way for the newer one.
+	os.RemoveAll(dest)
+	if rerr := os.Rename(dir, dest); rerr != nil {
+		return fmt.Sprintf("kept (failed) in %s, could not move it: %v", dir, rerr), dir
+	}
+	marker := failedClone{Repo: repo, Failed: time.Now().UTC()}
+	if err != nil {
+		marker.Error = err.Error()
+	}
+	data, _ := json.MarshalIndent(marker, "", "  ")
+	if werr := os.WriteFile(filepath.Join(dest, failedCloneMarker), data, 0644); werr != nil {
+		return fmt.Sprintf("kept (failed) in %s, without expiry: %v", dest, werr), dest
+	}
+	if p.FailedExpiry <= 0 {
+		return fmt.Sprintf("kept (failed) in %s", dest), dest
+	}
+	return fmt.Sprintf("kept (failed) in %s until %s", dest, marker.Failed.Add(p.FailedExpiry).Format("2006-01-02")), dest
+}
+
+// scanFailedClones lists the kept failure clones, split into those older
+// than expiry and the rest. A zero expiry expires none. Directories without
+// a marker are not the tool's and are left out.
+func scanFailedClones(now time.Time, expiry time.Duration) (expired, kept []failedClone, err error) {
+	entries, err := os.ReadDir(failedClonesDir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(failedClonesDir, e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, failedCloneMarker))
+		if err != nil {
+			continue
+		}
+		var c failedClone
+		if json.Unmarshal(data, &c) != nil {
+			continue
+		}
+		c.Dir = dir
+		if expiry > 0 && now.Sub(c.Failed) > expiry {
+			expired = append(expired, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	return expired, kept, nil
+}
@@ -0,0 +1,62 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// confirmDeleteRetained asks the user to confirm deleting the local copies
+// of unverified repositories, and deletes them if they agree.
+func confirmDeleteRetained(w fyne.Window, r *retainedCopies, logMsg func(string)) {
+	repos := r.Repos()
+	if len(repos) == 0 {
+		dialog.ShowInformation("Nothing to delete", "No local copies are pending verification.", w)
+		return
+	}
+	msg := fmt.Sprintf("These repositories did not pass verification:\n\n%s\n\nDelete their local copies anyway?", strings.Join(repos, "\n"))
+	dialog.ShowConfirm("Delete unverified copies", msg, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		for _, repo := range repos {
+			dir, ok := r.Take(repo)
+			if !ok {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				logMsg(fmt.Sprintf("Error deleting local copy of %s: %v", repo, err))
+				r.Add(repo, dir)
+				continue
+			}
+			logMsg(fmt.Sprintf("Deleted unverified local copy of %s (%s).", repo, dir))
+		}
+	}, w)
+}
+
+// confirmDeleteExpiredClones asks the user to confirm deleting failure
+// clones past their expiry, and deletes them if they agree.
+func confirmDeleteExpiredClones(w fyne.Window, expired []failedClone, logMsg func(string)) {
+	var lines []string
+	for _, c := range expired {
+		lines = append(lines, fmt.Sprintf("%s (failed %s)", c.Repo, c.Failed.Local().Format("2006-01-02")))
+	}
+	msg := fmt.Sprintf("These clones of failed repositories are past their expiry:\n\n%s\n\nDelete them?", strings.Join(lines, "\n"))
+	dialog.ShowConfirm("Delete expired failure clones", msg, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		for _, c := range expired {
+			if err := os.RemoveAll(c.Dir); err != nil {
+				logMsg(fmt.Sprintf("Error deleting failure clone of %s: %v", c.Repo, err))
+				continue
+			}
+			logMsg(fmt.Sprintf("Deleted expired failure clone of %s (%s).", c.Repo, c.Dir))
+		}
+	}, w)
+}
@@ -0,0 +1,21 @@
+//go:build nogui
+
+package main
+
+import "os"
+
+// Built with -tags nogui, gitui is gitui-cli: the subcommands and headless
+// migrations without the window, and so without Fyne and the OpenGL and X11
+// libraries it links, for CI runners and servers that have no display:
+//
+//	go build -tags nogui -o gitui-cli .
+//
+// Flags without a subcommand are those of --headless. Go programs can start
+// headless runs of this binary through the pkg/migrator package, a client
+// of the binary rather than an importable engine.
+func main() {
+	if code, ok := runSubcommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	os.Exit(runHeadless(os.Args[1:]))
+}
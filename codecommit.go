@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit/types"
+)
+
+// codecommitAPIInterval spaces out CodeCommit API calls; its rate limits
+// are much lower than GitHub's or Azure DevOps'.
+const codecommitAPIInterval = 250 * time.Millisecond
+
+// codecommitNamePattern matches valid CodeCommit repository names.
+var codecommitNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+
+// codecommitTarget migrates repositories into AWS CodeCommit. Pushes use
+// HTTPS with a SigV4-signed password, so neither git-remote-codecommit nor
+// IAM git credentials are needed.
+type codecommitTarget struct {
+	region  string
+	creds   aws.CredentialsProvider
+	client  *codecommit.Client
+	limiter <-chan time.Time
+}
+
+// newCodeCommitTarget resolves credentials for region: the explicit key and
+// secret if given, otherwise the standard AWS credential chain.
+func newCodeCommitTarget(region, accessKey, secretKey string) (*codecommitTarget, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		// The SDK retries throttling errors with backoff.
+		config.WithRetryMaxAttempts(10),
+		config.WithAppID("gitui-migrator/" + version),
+	}
+	if accessKey != "" || secretKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %v", err)
+	}
+	return &codecommitTarget{
+		region:  region,
+		creds:   cfg.Credentials,
+		client:  codecommit.NewFromConfig(cfg),
+		limiter: time.Tick(codecommitAPIInterval),
+	}, nil
+}
+
+func (t *codecommitTarget) Name() string { return "AWS CodeCommit" }
+
+// CreateRepo creates the repository, or reuses one with the same name, and
+// returns a signed HTTPS push URL.
+func (t *codecommitTarget) CreateRepo(ctx context.Context, name string) (string, error) {
+	if !codecommitNamePattern.MatchString(name) || strings.HasSuffix(name, ".git") {
+		return "", fmt.Errorf("%q is not a valid CodeCommit repository name (up to 100 letters, digits, '.', '_' or '-', not ending in .git)", name)
+	}
+
+	// The SDK's client does not go through apiClient, whose transport
+	// refuses writes in read-only mode, so its writes are refused here.
+	if err := checkReadOnly(http.MethodPost); err != nil {
+		return "", err
+	}
+	<-t.limiter
+	_, err := t.client.CreateRepository(ctx, &codecommit.CreateRepositoryInput{
+		RepositoryName: aws.String(name),
+	})
+	var exists *types.RepositoryNameExistsException
+	var quota *types.RepositoryLimitExceededException
+	switch {
+	case errors.As(err, &exists):
+		// Reuse the existing repository; the push is idempotent.
+	case errors.As(err, &quota):
+		return "", fmt.Errorf("CodeCommit repository quota reached in %s (1,000 per account and region by default)", t.region)
+	case err != nil:
+		return "", err
+	}
+	pushURL, err := t.pushURL(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	setCredentialRefresher(pushURL, func(ctx context.Context) (string, error) {
+		return t.pushURL(ctx, name)
+	})
+	return pushURL, nil
+}
+
+// pushURL returns the HTTPS clone URL with signed credentials, as the AWS
+// CLI credential helper computes them. The signature is only accepted for
+// about 15 minutes, so runGit has it signed again as pushes go on (see
+// setCredentialRefresher).
+func (t *codecommitTarget) pushURL(ctx context.Context, name string) (string, error) {
+	creds, err := t.creds.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("retrieving AWS credentials: %v", err)
+	}
+	host := fmt.Sprintf("git-codecommit.%s.amazonaws.com", t.region)
+	path := "/v1/repos/" + name
+
+	user := creds.AccessKeyID
+	if creds.SessionToken != "" {
+		user += "%" + creds.SessionToken
+	}
+	password := signCodeCommitRequest(creds.SecretAccessKey, t.region, host, path, time.Now().UTC())
+
+	u := url.URL{Scheme: "https", Host: host, Path: path, User: url.UserPassword(user, password)}
+	return u.String(), nil
+}
+
+// signCodeCommitRequest computes the SigV4 git password for path on host.
+func signCodeCommitRequest(secret, region, host, path string, now time.Time) string {
+	timestamp := now.Format("20060102T150405")
+	date := now.Format("20060102")
+
+	canonical := fmt.Sprintf("GIT\n%s\n\nhost:%s\n\nhost\n", path, host)
+	hash := sha256.Sum256([]byte(canonical))
+	scope := fmt.Sprintf("%s/%s/codecommit/aws4_request", date, region)
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", timestamp, scope, hex.EncodeToString(hash[:]))
+
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "codecommit")
+	key = hmacSHA256(key, "aws4_request")
+	return timestamp + "Z" + hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (t *codecommitTarget) SetDefaultBranch(ctx context.Context, name, branch string) error {
+	if err := checkReadOnly(http.MethodPost); err != nil {
+		return err
+	}
+	<-t.limiter
+	_, err := t.client.UpdateDefaultBranch(ctx, &codecommit.UpdateDefaultBranchInput{
+		RepositoryName:    aws.String(name),
+		DefaultBranchName: aws.String(strings.TrimPrefix(branch, "refs/heads/")),
+	})
+	return err
+}
+
+// MakeReadOnly does nothing: CodeCommit has no archived or disabled state,
+// and restricting pushes is a matter of IAM policy.
+func (t *codecommitTarget) MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error) {
+	return "left writable (CodeCommit has no read-only mode)", nil
+}
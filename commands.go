@@ -0,0 +1,28 @@
+package main
+
+import "context"
+
+// runSubcommand runs the subcommands that open no window, for the window's
+// executable and gitui-cli alike, and reports whether args name one.
+func runSubcommand(args []string) (code int, ok bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	// An interrupt ends them with exitCancelled.
+	switch args[0] {
+	case "plan":
+		return interruptible(func(ctx context.Context) int {
+			return runPlanCommand(ctx, args[1:])
+		}), true
+	case "report":
+		return interruptible(func(context.Context) int {
+			return runReportCommand(args[1:])
+		}), true
+	case "note":
+		return runNoteCommand(args[1:]), true
+	case "--headless", "-headless":
+		// A headless migration cancels its run on an interrupt.
+		return runHeadless(args[1:]), true
+	}
+	return 0, false
+}
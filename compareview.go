@@ -0,0 +1,83 @@
+//go:build !nogui
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showCompareRuns opens the "Compare runs" view: pick two stored run
+// reports and see which repositories changed between them. The comparison
+// is shown as Markdown and can be saved to a file.
+func showCompareRuns(w fyne.Window, logMsg func(string)) {
+	reports, err := listReports()
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	if len(reports) < 2 {
+		dialog.ShowInformation("Compare runs", fmt.Sprintf("At least two run reports are needed in %s.", reportsDir), w)
+		return
+	}
+
+	var labels []string
+	byLabel := map[string]*runReport{}
+	for _, r := range reports {
+		labels = append(labels, r.Label())
+		byLabel[r.Label()] = r
+	}
+
+	output := widget.NewMultiLineEntry()
+	output.Wrapping = fyne.TextWrapWord
+	var markdown string
+
+	firstSelect := widget.NewSelect(labels, nil)
+	secondSelect := widget.NewSelect(labels, nil)
+	compare := func(string) {
+		a, b := byLabel[firstSelect.Selected], byLabel[secondSelect.Selected]
+		if a == nil || b == nil {
+			return
+		}
+		var buf bytes.Buffer
+		writeDiffMarkdown(&buf, a, b, diffReports(a, b))
+		markdown = buf.String()
+		output.SetText(markdown)
+	}
+	firstSelect.OnChanged = compare
+	secondSelect.OnChanged = compare
+	// Default to the two most recent runs.
+	firstSelect.SetSelectedIndex(len(labels) - 2)
+	secondSelect.SetSelectedIndex(len(labels) - 1)
+
+	saveBtn := widget.NewButton("Export Markdown...", func() {
+		dialog.ShowFileSave(func(f fyne.URIWriteCloser, err error) {
+			if err != nil || f == nil {
+				return
+			}
+			defer f.Close()
+			if _, err := f.Write([]byte(markdown)); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			logMsg(fmt.Sprintf("Run comparison saved to %s.", f.URI().Path()))
+		}, w)
+	})
+
+	content := container.NewBorder(
+		widget.NewForm(
+			widget.NewFormItem("First run", firstSelect),
+			widget.NewFormItem("Second run", secondSelect),
+		),
+		saveBtn, nil, nil,
+		container.NewVScroll(output),
+	)
+	d := dialog.NewCustom("Compare runs", "Close", content, w)
+	d.Resize(fyne.NewSize(700, 500))
+	d.Show()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// conditionalAccessSignatures are the error codes and phrases Azure DevOps
+// and Entra ID use when a conditional access policy or IP allow-list
+// rejects a PAT from an unapproved network or device.
+var conditionalAccessSignatures = []string{
+	"VS403463",    // ADO: the conditional access policy has failed
+	"AADSTS53003", // Entra ID: blocked by conditional access
+	"AADSTS53000", // Entra ID: device not compliant
+	"AADSTS50005", // Entra ID: unsupported platform
+	"TF400813",    // ADO: not authorized, also returned for blocked IPs
+	"conditional access",
+	"IP address is not allowed",
+}
+
+// conditionalAccessError is a request Azure DevOps rejected most likely
+// because of the organization's conditional access policy or IP
+// restrictions, rather than the token's permissions.
+type conditionalAccessError struct {
+	Org       string // organization URL, if known
+	Signature string // what identified it
+	Err       error  // the underlying API error or git failure
+}
+
+func (e *conditionalAccessError) Error() string {
+	msg := fmt.Sprintf("Azure DevOps rejected the request (%s), most likely because of the organization's conditional access policy or IP restrictions: "+
+		"PATs are only accepted from approved networks or devices. Run from an approved network or ask an organization admin", e.Signature)
+	if e.Org != "" {
+		msg += fmt.Sprintf(" (policies: %s/_settings/organizationPolicy)", e.Org)
+	}
+	return msg + fmt.Sprintf(": %v", e.Err)
+}
+
+func (e *conditionalAccessError) Unwrap() error { return e.Err }
+
+// matchConditionalAccess returns the first signature found in text, or "".
+func matchConditionalAccess(text string) string {
+	lower := strings.ToLower(text)
+	for _, sig := range conditionalAccessSignatures {
+		if strings.Contains(lower, strings.ToLower(sig)) {
+			return sig
+		}
+	}
+	return ""
+}
+
+// azureOrgURL returns the organization URL of an Azure DevOps URL:
+// https://dev.azure.com/<org> or https://<org>.visualstudio.com.
+func azureOrgURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	base := "https://" + strings.ToLower(u.Host)
+	if u.Scheme == "http" {
+		base = "http://" + strings.ToLower(u.Host)
+	}
+	if strings.HasSuffix(strings.ToLower(u.Hostname()), ".visualstudio.com") {
+		return base
+	}
+	if org, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/"); org != "" {
+		return base + "/" + org
+	}
+	return ""
+}
+
+// azureAPIError describes an unexpected Azure DevOps response. A 403 whose
+// body is not JSON and carries a conditional access signature becomes a
+// *conditionalAccessError wrapping the *apiError.
+func azureAPIError(resp *http.Response) error {
+	apiErr := newAPIError("Azure", resp)
+	if resp.StatusCode != http.StatusForbidden || strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return apiErr
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	sig := matchConditionalAccess(string(body))
+	if sig == "" {
+		return apiErr
+	}
+	return &conditionalAccessError{Org: azureOrgURL(resp.Request.URL), Signature: sig, Err: apiErr}
+}
+
+// conditionalAccessPushError wraps err, a failed git command against the
+// Azure DevOps remote remoteURL, if output carries a conditional access
+// signature.
+func conditionalAccessPushError(err error, output, remoteURL string) error {
+	sig := matchConditionalAccess(output)
+	if err == nil || sig == "" {
+		return err
+	}
+	u, _ := url.Parse(remoteURL)
+	return &conditionalAccessError{Org: azureOrgURL(u), Signature: sig, Err: err}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// migrationConfig is a migration described in a file (migrate.yaml) rather
+// than typed into the window. Credentials never live in it; they come from
+// the GITHUB_PAT and ADO_PAT environment variables.
+type migrationConfig struct {
+	GitHub struct {
+		// Org whose repositories are migrated; empty means the token
+		// user's own repositories.
+		Org string `yaml:"org"`
+	} `yaml:"github"`
+	ADO struct {
+		Org     string `yaml:"org"` // organization URL
+		Project string `yaml:"project"`
+	} `yaml:"ado"`
+
+	// Repos and Exclude are glob patterns on the repository name. An empty
+	// Repos list selects everything.
+	Repos           []string `yaml:"repos"`
+	Exclude         []string `yaml:"exclude"`
+	IncludeArchived bool     `yaml:"include_archived"`
+	IncludeForks    bool     `yaml:"include_forks"`
+
+	// Rename maps a source repository ("name" or "owner/name") to the
+	// target repository name, e.g. to resolve collisions.
+	Rename map[string]string `yaml:"rename"`
+	// Collisions are collision strategies, tried in order, that resolve
+	// the remaining collisions automatically, such as [keep, owner-prefix,
+	// hash] or "template:{owner}.{name}". Without them collisions are
+	// errors to resolve with Rename.
+	Collisions []string `yaml:"collisions"`
+
+	// SkipDeepAnalysis are glob patterns (on "name" or "owner/name") of
+	// repositories left out of "plan --deep", such as giant repositories
+	// that are already well understood.
+	SkipDeepAnalysis []string `yaml:"skip_deep_analysis"`
+
+	// Waves group the repositories into cutover waves for the runbook
+	// ("plan --runbook"). Repositories no wave matches are unassigned.
+	Waves []waveConfig `yaml:"waves"`
+
+	LFS         string `yaml:"lfs"`      // "fail" (default) or "continue"
+	Archived    string `yaml:"archived"` // "disable" (default), "deny-push" or "leave"
+	DeleteAfter bool   `yaml:"delete_after"`
+
+	// LicensePolicy decides which GitHub licenses may be hosted in the
+	// target; see licensePolicyConfig.
+	LicensePolicy *licensePolicyConfig `yaml:"license_policy"`
+}
+
+// loadConfig reads a migration config file.
+func loadConfig(path string) (*migrationConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg migrationConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// lfsPolicy returns the configured missing-LFS-object policy.
+func (c *migrationConfig) lfsPolicy() (lfsPolicy, error) {
+	switch c.LFS {
+	case "", "fail":
+		return lfsFailOnMissing, nil
+	case "continue":
+		return lfsContinueOnMissing, nil
+	}
+	return 0, fmt.Errorf("lfs: unknown policy %q (want fail or continue)", c.LFS)
+}
+
+// collisionPolicy returns the configured collision strategies.
+func (c *migrationConfig) collisionPolicy() (collisionPolicy, error) {
+	return parseCollisionPolicy(strings.Join(c.Collisions, ","))
+}
+
+// archiveMode returns the configured handling of archived repositories.
+func (c *migrationConfig) archiveMode() (archiveMode, error) {
+	switch c.Archived {
+	case "", "disable":
+		return archiveDisable, nil
+	case "deny-push":
+		return archiveDenyPush, nil
+	case "leave":
+		return archiveLeaveWritable, nil
+	}
+	return 0, fmt.Errorf("archived: unknown mode %q (want disable, deny-push or leave)", c.Archived)
+}
+
+// licensePolicy returns the configured license policy; nil if there is
+// none.
+func (c *migrationConfig) licensePolicy() (*licensePolicy, error) {
+	if c.LicensePolicy == nil {
+		return nil, nil
+	}
+	return c.LicensePolicy.policy()
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Post-migration content features change the target project around a
+// repository that is already migrated, in ways that are tedious to undo.
+// Each can be previewed on its own for the migrated repositories, without
+// touching git, and the preview then applied. Apply previews again first
+// and only carries out operations that are still exactly the ones
+// previewed; anything that changed in between is shown for review instead.
+
+// contentOp is one change a content feature would make in the target.
+type contentOp struct {
+	Change string // "+" creates, "~" changes, "-" removes
+	What   string
+	Detail string // settings, or the change made to them
+	apply  func(ctx context.Context) error
+}
+
+// String renders op as a line of a diff.
+func (op *contentOp) String() string {
+	if op.Detail == "" {
+		return op.Change + " " + op.What
+	}
+	return fmt.Sprintf("%s %s (%s)", op.Change, op.What, op.Detail)
+}
+
+// migratedRepo is a repository whose latest outcome in the run reports is
+// a migration, and the target repository it went to.
+type migratedRepo struct {
+	Source string
+	Target string
+}
+
+// contentFeature is a post-migration feature that can be previewed.
+type contentFeature struct {
+	Name string
+	// OptIn features are not selected in the preview until chosen: they
+	// create a lot, such as a work item per issue.
+	OptIn bool
+	// Preview returns the changes the feature would make for repo, without
+	// making any.
+	Preview func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error)
+}
+
+// migratedRepos returns the repositories the run reports record as
+// migrated, the latest outcome of each counting, in the order first seen.
+// Split repositories went to several targets and are left out.
+func migratedRepos() ([]migratedRepo, error) {
+	reports, err := listReports()
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	latest := map[string]*repoReport{}
+	for _, r := range reports {
+		for _, repo := range r.Repos {
+			if _, seen := latest[repo.Source]; !seen {
+				order = append(order, repo.Source)
+			}
+			latest[repo.Source] = repo
+		}
+	}
+	// Repositories renamed since are under their new names in the state.
+	state, err := loadMigrationState()
+	if err != nil {
+		return nil, err
+	}
+	var repos []migratedRepo
+	for _, source := range order {
+		if r := latest[source]; succeeded(r.Status) && r.Target != "" {
+			target := r.Target
+			if s := state[strings.ToLower(source)]; s != nil && s.Target != "" {
+				target = s.Target
+			}
+			repos = append(repos, migratedRepo{Source: source, Target: target})
+		}
+	}
+	return repos, nil
+}
+
+// contentPlanEntry is what one feature would do for one repository.
+type contentPlanEntry struct {
+	Feature string
+	Repo    migratedRepo
+	Ops     []*contentOp
+	Error   string // why the preview failed
+}
+
+// fingerprint identifies the previewed operations, to tell whether a later
+// preview still matches.
+func (e *contentPlanEntry) fingerprint() string {
+	lines := []string{e.Error}
+	for _, op := range e.Ops {
+		lines = append(lines, op.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// contentPlan is a preview of content features for migrated repositories.
+type contentPlan struct {
+	Created time.Time
+	Target  string // org/project
+	Entries []*contentPlanEntry
+}
+
+// previewContent previews features for repos.
+func previewContent(ctx context.Context, t *azureTarget, features []*contentFeature, repos []migratedRepo, githubToken string) *contentPlan {
+	p := &contentPlan{Created: time.Now(), Target: t.org + "/" + t.project}
+	for _, repo := range repos {
+		for _, f := range features {
+			p.Entries = append(p.Entries, previewEntry(ctx, t, f, repo, githubToken))
+		}
+	}
+	return p
+}
+
+func previewEntry(ctx context.Context, t *azureTarget, f *contentFeature, repo migratedRepo, githubToken string) *contentPlanEntry {
+	e := &contentPlanEntry{Feature: f.Name, Repo: repo}
+	// A held repository has nothing to apply; this also keeps a hold
+	// placed after the preview from being applied over.
+	if hold, err := holdOf(repo.Source); hold != nil || err != nil {
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Error = "not touched, " + hold.String()
+		}
+		return e
+	}
+	ops, err := f.Preview(ctx, t, repo, githubToken)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.Ops = ops
+	return e
+}
+
+// Changes returns the number of operations in the plan.
+func (p *contentPlan) Changes() int {
+	n := 0
+	for _, e := range p.Entries {
+		n += len(e.Ops)
+	}
+	return n
+}
+
+// Markdown renders the plan for review, as a diff per repository and
+// feature.
+func (p *contentPlan) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Content preview for %s\n\n", p.Target)
+	fmt.Fprintf(&b, "- Previewed: %s\n- Changes: %d\n", fileTimestamp(p.Created), p.Changes())
+	repo := ""
+	for _, e := range p.Entries {
+		if e.Repo.Source != repo {
+			repo = e.Repo.Source
+			fmt.Fprintf(&b, "\n## %s → %s\n", e.Repo.Source, e.Repo.Target)
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", e.Feature)
+		switch {
+		case e.Error != "":
+			fmt.Fprintf(&b, "Preview failed: %s\n", e.Error)
+		case len(e.Ops) == 0:
+			b.WriteString("No changes.\n")
+		default:
+			b.WriteString("```diff\n")
+			for _, op := range e.Ops {
+				b.WriteString(op.String() + "\n")
+			}
+			b.WriteString("```\n")
+		}
+	}
+	return b.String()
+}
+
+// save writes the plan to reportsDir and returns its path.
+func (p *contentPlan) save() (string, error) {
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(reportsDir, p.Created.UTC().Format("20060102T150405Z")+"-PREVIEW.md")
+	return path, writeFileAtomic(path, []byte(p.Markdown()), 0644)
+}
+
+// applyContentPlan carries out plan. Each entry is previewed again first
+// and applied only if the operations are still exactly those previewed;
+// the entries that drifted are returned, freshly previewed, to be reviewed
+// and applied again. An entry stops at its first failed operation.
+func applyContentPlan(ctx context.Context, t *azureTarget, features []*contentFeature, plan *contentPlan, githubToken string, logMsg func(string)) (applied, failed int, drifted *contentPlan) {
+	byName := map[string]*contentFeature{}
+	for _, f := range features {
+		byName[f.Name] = f
+	}
+	drifted = &contentPlan{Created: time.Now(), Target: plan.Target}
+	for _, e := range plan.Entries {
+		f := byName[e.Feature]
+		if f == nil || len(e.Ops) == 0 {
+			continue
+		}
+		now := previewEntry(ctx, t, f, e.Repo, githubToken)
+		if now.fingerprint() != e.fingerprint() {
+			logMsg(fmt.Sprintf("%s of %s changed since the preview; not applied.", e.Feature, e.Repo.Source))
+			drifted.Entries = append(drifted.Entries, now)
+			continue
+		}
+		for _, op := range now.Ops {
+			if err := op.apply(ctx); err != nil {
+				logMsg(fmt.Sprintf("Error: %s of %s: %s: %v", e.Feature, e.Repo.Source, op, err))
+				failed++
+				break
+			}
+			applied++
+			logMsg(fmt.Sprintf("%s of %s: %s", e.Feature, e.Repo.Source, op))
+			if err := writeAudit("apply-content", t.project+"/"+e.Repo.Target, e.Feature+": "+op.String()); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not write the audit log: %v", err))
+			}
+		}
+	}
+	return applied, failed, drifted
+}
+
+// areaPathFeature previews creating the work item area path each
+// repository's work items go to, as mapped in areas.
+func areaPathFeature(areas *workItemAreas) *contentFeature {
+	return &contentFeature{
+		Name: "Work item area paths",
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			path := areas.Placement(t.project, repo.Source).AreaPath
+			existing, err := listClassificationPaths(ctx, t.org, t.project, "Areas", t.token)
+			if err != nil {
+				return nil, fmt.Errorf("listing area paths: %v", err)
+			}
+			missing, err := missingAreaPaths(t.project, path, existing)
+			if err != nil {
+				return nil, err
+			}
+			var ops []*contentOp
+			for _, full := range missing {
+				full := full
+				ops = append(ops, &contentOp{
+					Change: "+",
+					What:   "area path " + full,
+					apply: func(ctx context.Context) error {
+						// Parents come first, so each level exists by the
+						// time its children are created.
+						if _, err := createAreaPath(ctx, t.org, t.project, full, t.token, existing); err != nil {
+							return err
+						}
+						existing = append(existing, full)
+						return nil
+					},
+				})
+			}
+			return ops, nil
+		},
+	}
+}
+
+// archivedStateFeature previews making the target repositories of archived
+// GitHub repositories read-only with mode, as the migration does for the
+// ones it verifies.
+func archivedStateFeature(mode archiveMode) *contentFeature {
+	return &contentFeature{
+		Name: "Archived repositories read-only",
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			if mode == archiveLeaveWritable {
+				return nil, nil
+			}
+			meta, err := getGitHubRepo(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("fetching GitHub metadata: %v", err)
+			}
+			if !meta.Archived {
+				return nil, nil
+			}
+			target, err := getAzureRepo(ctx, t.org, t.project, repo.Target, t.token)
+			if err != nil {
+				return nil, fmt.Errorf("looking up Azure repo: %v", err)
+			}
+			if mode == archiveDisable {
+				if target.IsDisabled {
+					return nil, nil
+				}
+				return []*contentOp{{
+					Change: "~",
+					What:   "repository " + repo.Target,
+					Detail: "isDisabled: false → true",
+					apply: func(ctx context.Context) error {
+						return setAzureRepoDisabled(ctx, t.org, t.project, target.ID, true, t.token)
+					},
+				}}, nil
+			}
+			return []*contentOp{{
+				Change: "+",
+				What:   "access control entry on repository " + repo.Target,
+				Detail: fmt.Sprintf(`deny Contribute, Force push, Create branch, Create tag to [%s]\Project Valid Users`, t.project),
+				apply: func(ctx context.Context) error {
+					_, err := applyArchivedState(ctx, t.org, t.project, repo.Target, t.token, archiveDenyPush)
+					return err
+				},
+			}}, nil
+		},
+	}
+}
@@ -0,0 +1,138 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showContentPreview opens the content preview: pick features and migrated
+// repositories, preview what the features would change in t's project,
+// and apply the preview. Previews are saved next to the run reports.
+func showContentPreview(w fyne.Window, t *azureTarget, features []*contentFeature, githubToken string, logMsg func(string)) {
+	repos, err := migratedRepos()
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not read the run reports: %v", err))
+	}
+	if len(repos) == 0 {
+		dialog.ShowInformation("Content preview", "No run report records a migrated repository yet.", w)
+		return
+	}
+
+	// Closing the dialog cancels a preview or apply in progress.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var featureNames, defaultFeatures, repoNames []string
+	for _, f := range features {
+		featureNames = append(featureNames, f.Name)
+		if !f.OptIn {
+			defaultFeatures = append(defaultFeatures, f.Name)
+		}
+	}
+	bySource := map[string]migratedRepo{}
+	for _, r := range repos {
+		repoNames = append(repoNames, r.Source)
+		bySource[r.Source] = r
+	}
+	featureChecks := widget.NewCheckGroup(featureNames, nil)
+	featureChecks.SetSelected(defaultFeatures)
+	repoChecks := widget.NewCheckGroup(repoNames, nil)
+	repoChecks.SetSelected(repoNames)
+
+	output := widget.NewMultiLineEntry()
+	output.Wrapping = fyne.TextWrapWord
+	output.SetText("Nothing is changed until the preview is applied. Apply previews again first and only applies what is unchanged.")
+
+	selected := func() ([]*contentFeature, []migratedRepo) {
+		var fs []*contentFeature
+		for _, f := range features {
+			for _, name := range featureChecks.Selected {
+				if f.Name == name {
+					fs = append(fs, f)
+				}
+			}
+		}
+		var rs []migratedRepo
+		for _, name := range repoChecks.Selected {
+			rs = append(rs, bySource[name])
+		}
+		return fs, rs
+	}
+
+	var plan *contentPlan
+	var previewBtn, applyBtn *widget.Button
+	show := func(p *contentPlan) {
+		plan = p
+		text := p.Markdown()
+		if path, err := p.save(); err != nil {
+			logMsg(fmt.Sprintf("Error saving the content preview: %v", err))
+		} else {
+			logMsg(fmt.Sprintf("Content preview saved to %s.", path))
+		}
+		output.SetText(text)
+		if p.Changes() > 0 && !isReadOnly() {
+			applyBtn.Enable()
+		} else {
+			applyBtn.Disable()
+		}
+	}
+	previewBtn = widget.NewButton("Preview", func() {
+		fs, rs := selected()
+		if len(fs) == 0 || len(rs) == 0 {
+			return
+		}
+		previewBtn.Disable()
+		applyBtn.Disable()
+		output.SetText(fmt.Sprintf("Previewing %d feature(s) for %d repositories...", len(fs), len(rs)))
+		go func() {
+			defer previewBtn.Enable()
+			show(previewContent(ctx, t, fs, rs, githubToken))
+		}()
+	})
+	applyBtn = widget.NewButton("Apply", func() {
+		if plan == nil {
+			return
+		}
+		p := plan
+		dialog.ShowConfirm("Apply content changes",
+			fmt.Sprintf("Make the %d previewed change(s) in %s?", p.Changes(), p.Target),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				previewBtn.Disable()
+				applyBtn.Disable()
+				go func() {
+					defer previewBtn.Enable()
+					applied, failed, drifted := applyContentPlan(ctx, t, features, p, githubToken, logMsg)
+					logMsg(fmt.Sprintf("Applied %d content change(s), %d failed.", applied, failed))
+					if len(drifted.Entries) == 0 {
+						plan = nil
+						output.SetText(fmt.Sprintf("Applied %d change(s), %d failed. See the log.", applied, failed))
+						return
+					}
+					logMsg(fmt.Sprintf("%d preview entries changed before they were applied; review the new preview and apply again.", len(drifted.Entries)))
+					show(drifted)
+				}()
+			}, w)
+	})
+	applyBtn.Disable()
+
+	choices := container.NewGridWithColumns(2,
+		container.NewBorder(widget.NewLabel("Features"), nil, nil, nil, container.NewVScroll(featureChecks)),
+		container.NewBorder(widget.NewLabel("Migrated repositories"), nil, nil, nil, container.NewVScroll(repoChecks)),
+	)
+	split := container.NewVSplit(choices, output)
+	split.Offset = 0.35
+	content := container.NewBorder(nil, container.NewHBox(previewBtn, applyBtn), nil, nil, split)
+	d := dialog.NewCustom("Content preview", "Close", content, w)
+	d.SetOnClosed(cancel)
+	d.Resize(fyne.NewSize(800, 600))
+	d.Show()
+}
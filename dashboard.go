@@ -0,0 +1,396 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// The dashboard is an opt-in local HTTP server for wallboards: / serves a
+// page rendering the run as a live table, /api/status the current state as
+// JSON and /ws streams progress events, starting with a snapshot so late
+// joiners see the current state. Everything but the page needs the bearer
+// token.
+
+// defaultDashboardAddr is where the dashboard listens unless told
+// otherwise; only this machine can reach it.
+const defaultDashboardAddr = "127.0.0.1:8765"
+
+// dashboardRepo is a repository's progress in the current run.
+type dashboardRepo struct {
+	Name            string    `json:"name"`
+	Phase           string    `json:"phase,omitempty"`
+	Status          string    `json:"status,omitempty"` // set once finished
+	Started         time.Time `json:"started,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+}
+
+// dashboardRun is the current run's progress.
+type dashboardRun struct {
+	ID       string    `json:"id"`
+	Target   string    `json:"target"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Total    int       `json:"total"`
+	Done     int       `json:"done"`
+	Failed   int       `json:"failed"`
+}
+
+// dashboardEvent is one message of the stream. Type is "snapshot" (Run and
+// Repos, sent on connect), "run" (Run started or finished), "phase" (Repo
+// entered Phase) or "repo" (Repo finished, with Run's updated counts).
+type dashboardEvent struct {
+	Type  string          `json:"type"`
+	Time  time.Time       `json:"time"`
+	Run   *dashboardRun   `json:"run,omitempty"`
+	Repo  *dashboardRepo  `json:"repo,omitempty"`
+	Repos []dashboardRepo `json:"repos,omitempty"`
+}
+
+// dashboardHub keeps the state of the current run and fans its events out
+// to connected clients. A nil *dashboardHub ignores everything, so the
+// migration reports to it whether or not the dashboard is enabled.
+type dashboardHub struct {
+	mu      sync.Mutex
+	run     *dashboardRun
+	repos   []*dashboardRepo
+	byName  map[string]*dashboardRepo
+	clients map[chan dashboardEvent]struct{}
+}
+
+// dashboardClientBuffer is how many events a client may fall behind before
+// it is disconnected rather than slowing the migration down.
+const dashboardClientBuffer = 256
+
+func newDashboardHub() *dashboardHub {
+	return &dashboardHub{clients: map[chan dashboardEvent]struct{}{}}
+}
+
+// RunStarted resets the state for a new run over repos.
+func (h *dashboardHub) RunStarted(id, target string, repos []string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.run = &dashboardRun{ID: id, Target: target, Started: time.Now(), Total: len(repos)}
+	h.repos = nil
+	h.byName = map[string]*dashboardRepo{}
+	for _, name := range repos {
+		r := &dashboardRepo{Name: name}
+		h.repos = append(h.repos, r)
+		h.byName[name] = r
+	}
+	h.broadcast(h.snapshot())
+}
+
+// RepoPhase records that repo entered phase.
+func (h *dashboardHub) RepoPhase(repo, phase string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := h.repo(repo)
+	if r.Started.IsZero() {
+		r.Started = time.Now()
+	}
+	r.Phase = phase
+	snap := *r
+	h.broadcast(dashboardEvent{Type: "phase", Time: time.Now(), Repo: &snap})
+}
+
+// RepoFinished records repo's outcome.
+func (h *dashboardHub) RepoFinished(repo, status string, d time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := h.repo(repo)
+	r.Phase = ""
+	r.Status = status
+	r.DurationSeconds = d.Seconds()
+	if h.run != nil {
+		h.run.Done++
+		if status == statusFailed {
+			h.run.Failed++
+		}
+	}
+	snap := *r
+	h.broadcast(dashboardEvent{Type: "repo", Time: time.Now(), Run: h.runCopy(), Repo: &snap})
+}
+
+// RunFinished marks the run as finished.
+func (h *dashboardHub) RunFinished() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.run == nil {
+		return
+	}
+	h.run.Finished = time.Now()
+	h.broadcast(dashboardEvent{Type: "run", Time: time.Now(), Run: h.runCopy()})
+}
+
+// repo returns repo's entry, adding it if the run did not list it (a
+// repository that moved on GitHub is tracked under its new name).
+func (h *dashboardHub) repo(name string) *dashboardRepo {
+	if r, ok := h.byName[name]; ok {
+		return r
+	}
+	if h.byName == nil {
+		h.byName = map[string]*dashboardRepo{}
+	}
+	r := &dashboardRepo{Name: name}
+	h.repos = append(h.repos, r)
+	h.byName[name] = r
+	return r
+}
+
+func (h *dashboardHub) runCopy() *dashboardRun {
+	if h.run == nil {
+		return nil
+	}
+	snap := *h.run
+	return &snap
+}
+
+// snapshot returns the current state as a snapshot event.
+func (h *dashboardHub) snapshot() dashboardEvent {
+	ev := dashboardEvent{Type: "snapshot", Time: time.Now(), Run: h.runCopy(), Repos: []dashboardRepo{}}
+	for _, r := range h.repos {
+		ev.Repos = append(ev.Repos, *r)
+	}
+	return ev
+}
+
+// Snapshot returns the current state.
+func (h *dashboardHub) Snapshot() dashboardEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.snapshot()
+}
+
+// broadcast sends ev to every client, dropping those that fell too far
+// behind. h.mu must be held.
+func (h *dashboardHub) broadcast(ev dashboardEvent) {
+	for c := range h.clients {
+		select {
+		case c <- ev:
+		default:
+			delete(h.clients, c)
+			close(c)
+		}
+	}
+}
+
+// subscribe returns a channel of events starting with a snapshot of the
+// current state, and a function to unsubscribe.
+func (h *dashboardHub) subscribe() (<-chan dashboardEvent, func()) {
+	c := make(chan dashboardEvent, dashboardClientBuffer)
+	h.mu.Lock()
+	c <- h.snapshot()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.clients[c]; ok {
+			delete(h.clients, c)
+			close(c)
+		}
+	}
+}
+
+// newDashboardToken returns a random bearer token, for when none is
+// configured.
+func newDashboardToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// dashboardAuthorized reports whether req carries token, as a bearer token
+// or, for browsers' WebSocket connections which cannot set headers, as the
+// access_token query parameter.
+func dashboardAuthorized(req *http.Request, token string) bool {
+	got := req.URL.Query().Get("access_token")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// isLoopbackAddr reports whether addr (host:port) only accepts connections
+// from this machine.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// dashboardHandler serves the page, the status API and the event stream of
+// hub, requiring token for the latter two.
+func dashboardHandler(hub *dashboardHub, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, dashboardPage)
+	})
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, req *http.Request) {
+		if !dashboardAuthorized(req, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hub.Snapshot())
+	})
+	// The token stands in for origin checks: a page from elsewhere cannot
+	// know it.
+	stream := websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		events, unsubscribe := hub.subscribe()
+		defer unsubscribe()
+		// Clients only listen; reading notices when they go away.
+		gone := make(chan struct{})
+		go func() {
+			var discard string
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			close(gone)
+		}()
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := websocket.JSON.Send(ws, ev); err != nil {
+					return
+				}
+			case <-gone:
+				return
+			}
+		}
+	}}
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, req *http.Request) {
+		if !dashboardAuthorized(req, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		stream.ServeHTTP(w, req)
+	})
+	return mux
+}
+
+// startDashboard serves hub's dashboard on addr until the program exits.
+// It returns the page's URL, with the token in the fragment so the page
+// can authenticate without the token reaching server logs.
+func startDashboard(addr, token string, hub *dashboardHub) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	srv := &http.Server{Handler: dashboardHandler(hub, token), ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	return fmt.Sprintf("http://%s/#token=%s", ln.Addr(), token), nil
+}
+
+// dashboardPage renders the event stream as a live table.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Migration progress</title>
+<style>
+body { font-family: sans-serif; margin: 2em; background: #111; color: #eee; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.8em; border-bottom: 1px solid #333; }
+.failed { color: #f66; }
+.running { color: #6cf; }
+.done { color: #6d6; }
+#state { color: #999; }
+</style>
+</head>
+<body>
+<h1 id="run">Waiting for a run...</h1>
+<p id="state">Connecting...</p>
+<table>
+<thead><tr><th>Repository</th><th>Phase</th><th>Status</th><th>Duration</th></tr></thead>
+<tbody id="repos"></tbody>
+</table>
+<script>
+const token = new URLSearchParams(location.hash.slice(1)).get("token") || "";
+let repos = new Map();
+
+function renderRun(run) {
+  if (!run) return;
+  let text = "Run " + run.id + " to " + run.target + ": " + run.done + " of " + run.total + " done";
+  if (run.failed) text += ", " + run.failed + " failed";
+  if (run.finished && !run.finished.startsWith("0001")) text += " (finished)";
+  document.getElementById("run").textContent = text;
+}
+
+function renderRepos() {
+  const body = document.getElementById("repos");
+  body.replaceChildren();
+  for (const r of repos.values()) {
+    const tr = document.createElement("tr");
+    const status = r.status || (r.phase ? "running" : "queued");
+    tr.className = r.status === "failed" ? "failed" : r.status ? "done" : r.phase ? "running" : "";
+    const duration = r.duration_seconds ? Math.round(r.duration_seconds) + "s" : "";
+    for (const v of [r.name, r.phase || "", status, duration]) {
+      const td = document.createElement("td");
+      td.textContent = v;
+      tr.appendChild(td);
+    }
+    body.appendChild(tr);
+  }
+}
+
+function connect() {
+  const proto = location.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(proto + "//" + location.host + "/ws?access_token=" + encodeURIComponent(token));
+  ws.onopen = () => { document.getElementById("state").textContent = "Live"; };
+  ws.onclose = () => {
+    document.getElementById("state").textContent = "Disconnected, retrying...";
+    setTimeout(connect, 3000);
+  };
+  ws.onmessage = (msg) => {
+    const ev = JSON.parse(msg.data);
+    if (ev.type === "snapshot") {
+      repos = new Map((ev.repos || []).map((r) => [r.name, r]));
+    } else if (ev.repo) {
+      repos.set(ev.repo.name, ev.repo);
+    }
+    renderRun(ev.run);
+    renderRepos();
+  };
+}
+connect();
+</script>
+</body>
+</html>
+`
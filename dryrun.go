@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dryRun logs what Migrate would do with repo, given its GitHub metadata
+// (nil if it could not be fetched), without cloning, creating or pushing.
+// It returns why the repository would not migrate cleanly, such as a
+// target name that is already taken, or "".
+func (r *migrationRun) dryRun(repo string, meta *gitHubRepo, result *repoReport, logMsg func(string)) string {
+	size := "size unknown"
+	if meta != nil {
+		size = formatKB(meta.Size)
+		if suspectSize(meta.Size, meta.DefaultBranch) {
+			size += " as GitHub reports it, which can lag"
+		}
+	}
+	logMsg(fmt.Sprintf("Dry run: would clone %s from %s as a bare clone (%s) and scan its history for secrets.", repo, r.Source.Name(), size))
+
+	var collisions []string
+	create := func(name, what string) {
+		if existing, ok := r.Existing[strings.ToLower(name)]; ok {
+			collisions = append(collisions, existing)
+			logMsg(fmt.Sprintf("Dry run: %s would collide: %s already exists in the target project as %s; the create would be refused, and an existing repository is only taken over if an earlier run created it or it is empty.",
+				repo, name, existing))
+			return
+		}
+		logMsg(fmt.Sprintf("Dry run: would create %s %s in %s.", what, name, r.Target.Name()))
+	}
+
+	if parts := r.Splits.Get(repo); parts != nil {
+		r.Names.Release(result.Target)
+		for _, p := range parts {
+			name, strategy, err := r.Names.Name(repo, p.Target)
+			if err != nil {
+				logMsg(fmt.Sprintf("Dry run: %s would fail to split: %v", repo, err))
+				return err.Error()
+			}
+			if name != p.Target {
+				logMsg(fmt.Sprintf("Dry run: split part %s of %s would be named %s (collision strategy %s).", p.Subdir, repo, name, strategy))
+			}
+			create(name, "split part "+p.Subdir+" as")
+		}
+		logMsg(fmt.Sprintf("Dry run: would rewrite the history of %s into %d parts and push each.", repo, len(parts)))
+	} else {
+		create(result.Target, "repository")
+		if !r.Filter.Empty() {
+			logMsg(fmt.Sprintf("Dry run: would push the branches of %s the branch filter selects (include %s; exclude %s), and its tags.",
+				repo, orDefault(strings.Join(r.Filter.Include, ", "), "all"), orDefault(strings.Join(r.Filter.Exclude, ", "), "none")))
+		} else if r.SkipLFS {
+			logMsg(fmt.Sprintf("Dry run: would push all branches and tags of %s, without LFS objects, and verify them.", repo))
+		} else {
+			logMsg(fmt.Sprintf("Dry run: would push all branches and tags of %s, with its LFS objects, and verify them.", repo))
+		}
+		if meta != nil && meta.DefaultBranch != "" {
+			logMsg(fmt.Sprintf("Dry run: would set the default branch of %s to %s.", result.Target, meta.DefaultBranch))
+		}
+		if meta != nil && meta.Archived {
+			logMsg(fmt.Sprintf("Dry run: %s is archived on GitHub; %s would be made read-only (%s).", repo, result.Target, archiveModeNames[r.Archive]))
+		}
+	}
+	if len(collisions) > 0 {
+		return "target exists: " + strings.Join(collisions, ", ")
+	}
+	return ""
+}
+
+// dryRunSummary sums up a dry run's report: what would migrate, and what
+// would not and why.
+func dryRunSummary(r *runReport) []string {
+	var ready int
+	var problems []string
+	for _, repo := range r.Repos {
+		switch {
+		case repo.Status == statusDryRun && repo.Reason == "":
+			ready++
+		case repo.Status == statusDryRun:
+			problems = append(problems, fmt.Sprintf("%s: %s", repo.Source, repo.Reason))
+		case repo.Reason != "":
+			problems = append(problems, fmt.Sprintf("%s: %s (%s)", repo.Source, repo.Status, repo.Reason))
+		case repo.Error != "":
+			problems = append(problems, fmt.Sprintf("%s: %s: %s", repo.Source, repo.Status, repo.Error))
+		default:
+			problems = append(problems, fmt.Sprintf("%s: %s", repo.Source, repo.Status))
+		}
+	}
+	lines := []string{fmt.Sprintf("Dry run: %d of %d repositories would migrate; nothing was changed.", ready, len(r.Repos))}
+	for _, p := range problems {
+		lines = append(lines, "Dry run: would not migrate cleanly: "+p)
+	}
+	return lines
+}
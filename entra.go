@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// "Sign in with Microsoft" authenticates to Azure DevOps with Microsoft
+// Entra ID instead of a PAT, through the device code flow: the user enters
+// a short code at microsoft.com/devicelogin and the tool polls until Entra
+// ID hands out an access token and a refresh token. The access token lives
+// about an hour, less than a large batch takes, so it is refreshed as it
+// nears expiry. The refresh token is kept in memory only; a new session
+// signs in again.
+//
+// The session goes where a PAT would as entraToken, which is resolved to
+// the current access token each time a request or a git remote is
+// authenticated: REST requests send it as a bearer token, git as the
+// password of basic authentication.
+
+// entraClientID is the client ID of the Entra ID application, a public
+// client with the Azure DevOps user_impersonation permission, that signs
+// users in; set at build time with -ldflags "-X main.entraClientID=...".
+// GITUI_ENTRA_CLIENT_ID overrides it, for an organization's own app.
+var entraClientID = ""
+
+// azureDevOpsScope asks for Azure DevOps, by its resource ID, and for a
+// refresh token.
+const azureDevOpsScope = "499b84ac-1321-427f-aa17-267ca6975798/.default offline_access"
+
+// entraToken stands in for the token of the Entra ID session. No PAT looks
+// like it.
+const entraToken = "entra-id-session"
+
+// entraRefreshMargin is how long before it expires an access token is
+// refreshed, so that one handed to a long request or push does not expire
+// on the way.
+const entraRefreshMargin = 10 * time.Minute
+
+// entraConfig returns the application's client ID, or "" if there is none,
+// and the tenant to sign in to: GITUI_ENTRA_TENANT, or any work or school
+// account's.
+func entraConfig() (clientID, tenant string) {
+	clientID = os.Getenv("GITUI_ENTRA_CLIENT_ID")
+	if clientID == "" {
+		clientID = entraClientID
+	}
+	tenant = os.Getenv("GITUI_ENTRA_TENANT")
+	if tenant == "" {
+		tenant = "organizations"
+	}
+	return clientID, tenant
+}
+
+// entraEndpoint returns the URL of tenant's OAuth endpoint ("devicecode"
+// or "token").
+func entraEndpoint(tenant, endpoint string) string {
+	return fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/%s", url.PathEscape(tenant), endpoint)
+}
+
+// entraSession is the signed-in user's tokens.
+type entraSession struct {
+	mu       sync.Mutex
+	clientID string
+	tenant   string
+	token    oauthToken
+	expires  time.Time
+}
+
+var (
+	entraMu      sync.Mutex
+	entraCurrent *entraSession // nil until signed in
+)
+
+// setEntraSession makes s the session entraToken resolves to.
+func setEntraSession(s *entraSession) {
+	entraMu.Lock()
+	entraCurrent = s
+	entraMu.Unlock()
+}
+
+// newEntraSession starts a session with the tokens of a sign-in.
+func newEntraSession(clientID, tenant string, token *oauthToken) *entraSession {
+	s := &entraSession{clientID: clientID, tenant: tenant}
+	s.set(token)
+	return s
+}
+
+// set replaces the session's tokens. Entra ID may leave the refresh token
+// out of a refresh, in which case the old one stays good.
+func (s *entraSession) set(token *oauthToken) {
+	refresh := s.token.RefreshToken
+	s.token = *token
+	if s.token.RefreshToken == "" {
+		s.token.RefreshToken = refresh
+	}
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	registerSecret(s.token.AccessToken)
+	registerSecret(s.token.RefreshToken)
+}
+
+// accessToken returns an access token good for at least
+// entraRefreshMargin, refreshing the session's if need be.
+func (s *entraSession) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Until(s.expires) > entraRefreshMargin {
+		return s.token.AccessToken, nil
+	}
+	if s.token.RefreshToken == "" {
+		return "", errors.New("the Microsoft sign-in expired; sign in again")
+	}
+	form := url.Values{
+		"client_id":     {s.clientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.token.RefreshToken},
+		"scope":         {azureDevOpsScope},
+	}
+	var result struct {
+		oauthToken
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := postOAuthForm(ctx, "Microsoft Entra ID", entraEndpoint(s.tenant, "token"), form, &result); err != nil {
+		return "", fmt.Errorf("refreshing the Microsoft sign-in: %v", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("refreshing the Microsoft sign-in: %s: %s; sign in again", result.Error, result.Description)
+	}
+	s.set(&result.oauthToken)
+	return s.token.AccessToken, nil
+}
+
+// resolveAzureToken returns what token authenticates with: token itself
+// for a PAT, or the current access token of the Entra ID session, with
+// bearer set, for entraToken.
+func resolveAzureToken(ctx context.Context, token string) (value string, bearer bool, err error) {
+	if token != entraToken {
+		return token, false, nil
+	}
+	entraMu.Lock()
+	s := entraCurrent
+	entraMu.Unlock()
+	if s == nil {
+		return "", true, errors.New("not signed in with Microsoft; sign in again or use a PAT")
+	}
+	value, err = s.accessToken(ctx)
+	return value, true, err
+}
+
+// setAzureAuth authenticates req with token: a PAT with basic
+// authentication and an empty username, the Entra ID session as a bearer
+// token.
+func setAzureAuth(req *http.Request, token string) error {
+	value, bearer, err := resolveAzureToken(req.Context(), token)
+	if err != nil {
+		return err
+	}
+	if bearer {
+		req.Header.Set("Authorization", "Bearer "+value)
+	} else {
+		req.SetBasicAuth("", value)
+	}
+	return nil
+}
+
+// azureGitToken returns what git authenticates to Azure Repos with for
+// token. If the Entra ID session cannot give an access token, entraToken
+// is left for git to fail authenticating with.
+func azureGitToken(ctx context.Context, token string) string {
+	if value, _, err := resolveAzureToken(ctx, token); err == nil {
+		return value
+	}
+	return token
+}
+
+// requestEntraDeviceCode starts the device flow for clientID in tenant.
+func requestEntraDeviceCode(ctx context.Context, clientID, tenant string) (*deviceCode, error) {
+	var result struct {
+		deviceCode
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	form := url.Values{"client_id": {clientID}, "scope": {azureDevOpsScope}}
+	if err := postOAuthForm(ctx, "Microsoft Entra ID", entraEndpoint(tenant, "devicecode"), form, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%s: %s", result.Error, result.Description)
+	}
+	return &result.deviceCode, nil
+}
+
+// signInWithMicrosoftHeadless runs the device flow with the code logged,
+// for "gitui --headless --ado-sign-in", and returns entraToken once the
+// user approves.
+func signInWithMicrosoftHeadless(ctx context.Context, logMsg func(string)) (string, error) {
+	clientID, tenant := entraConfig()
+	if clientID == "" {
+		return "", errors.New("this build has no Entra ID application to sign in with; set GITUI_ENTRA_CLIENT_ID, or ADO_PAT instead")
+	}
+	code, err := requestEntraDeviceCode(ctx, clientID, tenant)
+	if err != nil {
+		return "", fmt.Errorf("could not start signing in with Microsoft: %v", err)
+	}
+	logMsg(fmt.Sprintf("To sign in to Azure DevOps, open %s and enter the code %s.", code.VerificationURI, code.UserCode))
+	token, err := pollDeviceToken(ctx, "Microsoft Entra ID", entraEndpoint(tenant, "token"), clientID, code)
+	if err != nil {
+		return "", fmt.Errorf("signing in with Microsoft failed: %v", err)
+	}
+	setEntraSession(newEntraSession(clientID, tenant, token))
+	logMsg("Signed in with Microsoft.")
+	return entraToken, nil
+}
+
+// refreshRemoteToken hands git the Entra ID session's current access
+// token for remote of the clone in dir, so that a push starting after the
+// token the remote was added with has expired still authenticates. Remotes
+// of other targets, and of a PAT, are left alone.
+func refreshRemoteToken(ctx context.Context, dir, remote string, target targetProvider) {
+	if az, ok := target.(*azureTarget); !ok || az.token != entraToken {
+		return
+	}
+	u, err := url.Parse(remoteURL(ctx, dir, remote))
+	if err != nil {
+		return
+	}
+	u.User = url.UserPassword(tokenUsername, azureGitToken(ctx, entraToken))
+	stripCredentials(u.String())
+}
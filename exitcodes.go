@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Exit codes of the command-line subcommands, for wrapper pipelines.
+const (
+	exitOK        = 0   // everything succeeded (warnings too, unless --strict)
+	exitRunError  = 1   // the run itself failed or could not start
+	exitPartial   = 2   // the run finished but some repositories failed
+	exitWarnings  = 3   // with --strict: no failures, but some warnings
+	exitCancelled = 130 // interrupted, as shells report SIGINT
+)
+
+// exitCodeDocs describes the exit codes for --help, in order.
+var exitCodeDocs = []struct {
+	Code    int
+	Meaning string
+	Strict  bool // only with --strict
+}{
+	{exitOK, "success", false},
+	{exitRunError, "run-level failure: bad flags, config or credentials, or the run could not start", false},
+	{exitPartial, "some repositories failed", false},
+	{exitWarnings, "no failures, but warnings (with --strict)", true},
+	{exitCancelled, "cancelled (interrupted)", false},
+}
+
+// setUsage makes fs's --help print synopsis, the flags and the exit codes.
+// The --strict code is listed only for subcommands with that flag.
+func setUsage(fs *flag.FlagSet, synopsis string) {
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "usage: gitui %s\n", synopsis)
+		if hasFlags(fs) {
+			fmt.Fprint(out, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+		fmt.Fprint(out, "\nExit codes:\n")
+		strict := fs.Lookup("strict") != nil
+		for _, d := range exitCodeDocs {
+			if d.Strict && !strict {
+				continue
+			}
+			fmt.Fprintf(out, "  %3d  %s\n", d.Code, d.Meaning)
+		}
+	}
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
+
+// parseFlags parses args into fs and returns the exit code to stop with,
+// or -1 to carry on. --help is a success, anything else unparsable a
+// run-level failure.
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	switch err := fs.Parse(args); {
+	case err == flag.ErrHelp:
+		return exitOK
+	case err != nil:
+		return exitRunError
+	}
+	return -1
+}
+
+// runWarnings lists why a run that had no failures is not clean, such as
+// refs that did not push or repositories left unverified or skipped.
+func runWarnings(r *runReport) []string {
+	var warnings []string
+	for _, repo := range r.Repos {
+		switch {
+		case repo.Status == statusFailed, repo.Status == statusWontMigrate, repo.Status == statusHeld:
+			// A failure, or decided on; not a warning.
+		case repo.Status == statusDryRun:
+			if repo.Reason != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: dry run: %s", repo.Source, repo.Reason))
+			}
+		case repo.Status == statusLicenseBlocked:
+			warnings = append(warnings, fmt.Sprintf("%s: %s: %s", repo.Source, repo.Status, repo.Reason))
+		case len(repo.FailedRefs) > 0:
+			warnings = append(warnings, fmt.Sprintf("%s: %d ref(s) did not push", repo.Source, len(repo.FailedRefs)))
+		case repo.Status == statusUnverified, repo.Status == statusRetained, repo.Status == statusLateFailure, !succeeded(repo.Status):
+			warnings = append(warnings, fmt.Sprintf("%s: %s", repo.Source, repo.Status))
+		}
+		for _, w := range repo.ServerWarnings {
+			warnings = append(warnings, fmt.Sprintf("%s: the server warned while pushing: %s", repo.Source, w))
+		}
+		if repo.LFSSkipped {
+			warnings = append(warnings, fmt.Sprintf("%s: uses Git LFS, objects not migrated (only pointer files)", repo.Source))
+		}
+		if repo.Badges != nil && repo.Badges.Manual != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: README badge rewrite needs applying by hand from %s", repo.Source, repo.Badges.Manual))
+		}
+	}
+	return warnings
+}
+
+// runExitCode returns the exit code for a finished run: exitPartial if any
+// repository failed, exitWarnings if strict and there are warnings, and
+// exitOK otherwise.
+func runExitCode(r *runReport, strict bool) int {
+	for _, repo := range r.Repos {
+		if repo.Status == statusFailed {
+			return exitPartial
+		}
+	}
+	if strict && len(runWarnings(r)) > 0 {
+		return exitWarnings
+	}
+	return exitOK
+}
+
+// writeRunSummary writes the outcome of a run, the failed repositories and
+// the warnings to w; the subcommands write it to stderr whatever their
+// output format, so it always reaches the pipeline log.
+func writeRunSummary(w io.Writer, r *runReport) {
+	r.summarize()
+	s := r.Summary
+	fmt.Fprintf(w, "Run %s: %d repositories, %d migrated (%d verified), %d failed.\n",
+		r.Label(), s.Repos, s.Migrated, s.Verified, s.Failed)
+	if r.Note != "" {
+		fmt.Fprintf(w, "  Note: %s\n", r.Note)
+	}
+	if r.GitHubLogin != "" {
+		fmt.Fprintf(w, "  GitHub identity: %s\n", r.GitHubLogin)
+	}
+	if r.Identities != nil {
+		fmt.Fprintf(w, "  Azure DevOps identity: %s\n", orDefault(r.Identities.ADO, "unknown"))
+		fmt.Fprintf(w, "  Committer: %s\n", r.Identities.Committer)
+	}
+	for _, repo := range r.Repos {
+		if repo.Status == statusFailed {
+			fmt.Fprintf(w, "  failed: %s: %s\n", repo.Source, strings.TrimSpace(repo.Error))
+		}
+		if repo.Status == statusWontMigrate {
+			fmt.Fprintf(w, "  won't migrate: %s: %s\n", repo.Source, repo.Reason)
+		}
+		if repo.Status == statusHeld {
+			fmt.Fprintf(w, "  on hold: %s: %s\n", repo.Source, repo.Reason)
+		}
+	}
+	for _, warning := range runWarnings(r) {
+		fmt.Fprintf(w, "  warning: %s\n", warning)
+	}
+}
+
+// interruptible runs cmd under a context an interrupt (Ctrl+C, or SIGINT
+// from a pipeline) cancels, and returns exitCancelled if one came,
+// whatever cmd returned.
+func interruptible(cmd func(ctx context.Context) int) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	code := cmd(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Cancelled.")
+		return exitCancelled
+	}
+	return code
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		repos  []*repoReport
+		strict bool
+		want   int
+	}{
+		{"clean", []*repoReport{{Source: "acme/api", Status: statusMigrated}}, true, exitOK},
+		{"a failure", []*repoReport{{Source: "acme/api", Status: statusMigrated}, {Source: "acme/web", Status: statusFailed}}, false, exitPartial},
+		{"a failure and warnings", []*repoReport{{Source: "acme/api", Status: statusUnverified}, {Source: "acme/web", Status: statusFailed}}, true, exitPartial},
+		{"tags that did not push", []*repoReport{{Source: "acme/api", Status: statusWarnings, FailedRefs: []string{"refs/tags/v1"}}}, false, exitOK},
+		{"tags that did not push, strict", []*repoReport{{Source: "acme/api", Status: statusWarnings, FailedRefs: []string{"refs/tags/v1"}}}, true, exitWarnings},
+		{"unverified, strict", []*repoReport{{Source: "acme/api", Status: statusUnverified}}, true, exitWarnings},
+		{"skipped, strict", []*repoReport{{Source: "acme/api", Status: statusSecretsBlocked}}, true, exitWarnings},
+	}
+	for _, tt := range tests {
+		if got := runExitCode(&runReport{Repos: tt.repos}, tt.strict); got != tt.want {
+			t.Errorf("%s: exit code %d, want %d (warnings %q)", tt.name, got, tt.want, runWarnings(&runReport{Repos: tt.repos}))
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want int
+	}{
+		{nil, -1},
+		{[]string{"--strict"}, -1},
+		{[]string{"--help"}, exitOK},
+		{[]string{"--no-such-flag"}, exitRunError},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Bool("strict", false, "")
+		if got := parseFlags(fs, tt.args); got != tt.want {
+			t.Errorf("parseFlags(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+// --help lists the exit codes, the --strict one only where that flag is.
+func TestSetUsageExitCodes(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var out bytes.Buffer
+		fs.SetOutput(&out)
+		if strict {
+			fs.Bool("strict", false, "")
+		}
+		setUsage(fs, "test [flags]")
+		fs.Usage()
+		for _, d := range exitCodeDocs {
+			if listed := strings.Contains(out.String(), d.Meaning); listed != (!d.Strict || strict) {
+				t.Errorf("strict flag %v: exit code %d listed %v:\n%s", strict, d.Code, listed, out.String())
+			}
+		}
+	}
+}
+
+// An interrupt cancels the subcommand's context and, once it has
+// returned, makes its exit code exitCancelled.
+func TestInterruptible(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sends itself SIGINT")
+	}
+	if got := interruptible(func(context.Context) int { return exitRunError }); got != exitRunError {
+		t.Errorf("without an interrupt: %d, want %d", got, exitRunError)
+	}
+	got := interruptible(func(ctx context.Context) int {
+		p, _ := os.FindProcess(os.Getpid())
+		if err := p.Signal(os.Interrupt); err != nil {
+			t.Fatal(err)
+		}
+		<-ctx.Done()
+		return exitOK
+	})
+	if got != exitCancelled {
+		t.Errorf("interrupted: %d, want %d", got, exitCancelled)
+	}
+}
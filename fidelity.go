@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxListedOIDs caps the differing object IDs the fidelity report lists;
+// the counts are always complete.
+const maxListedOIDs = 200
+
+// fidelityReport is the outcome of a fidelity test: a repository migrated
+// to a scratch ADO repository and cloned back, with both clones' refs and
+// object inventories compared.
+type fidelityReport struct {
+	Repo     string
+	Scratch  string // the scratch ADO repository
+	Started  time.Time
+	Finished time.Time
+
+	SourceRefs, TargetRefs       int
+	SourceObjects, TargetObjects int
+	RefDifferences               []string
+	MissingOnTarget              []string // object IDs only in the source clone
+	ExtraOnTarget                []string // object IDs only in the clone back
+	Mismatched                   []string // same ID, different type or size
+	Cleanup                      string
+	Error                        string
+}
+
+// Passed reports whether the test completed with identical refs and
+// objects.
+func (r *fidelityReport) Passed() bool {
+	return r.Error == "" && len(r.RefDifferences) == 0 && len(r.MissingOnTarget) == 0 &&
+		len(r.ExtraOnTarget) == 0 && len(r.Mismatched) == 0
+}
+
+// objectInventory lists every object of the clone in dir, as
+// object ID -> "type size".
+func objectInventory(ctx context.Context, dir string) (map[string]string, error) {
+	out, err := runGit(ctx, nil, "-C", dir, "cat-file", "--batch-all-objects", "--batch-check")
+	if err != nil {
+		return nil, fmt.Errorf("listing objects: %v: %s", err, lastLine(out))
+	}
+	objects := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		oid, rest, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok {
+			objects[oid] = rest
+		}
+	}
+	return objects, nil
+}
+
+// compareRefs returns one line per ref that differs between two clones.
+func compareRefs(source, target map[string]string) []string {
+	var diffs []string
+	for ref, sha := range source {
+		switch got, ok := target[ref]; {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s missing on target", ref))
+		case got != sha:
+			diffs = append(diffs, fmt.Sprintf("%s is %s on target, %s in the source", ref, got, sha))
+		}
+	}
+	for ref := range target {
+		if _, ok := source[ref]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s only on target", ref))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// compareInventories fills in the object counts and differences of r.
+func (r *fidelityReport) compareInventories(source, target map[string]string) {
+	r.SourceObjects, r.TargetObjects = len(source), len(target)
+	for oid, meta := range source {
+		switch got, ok := target[oid]; {
+		case !ok:
+			r.MissingOnTarget = append(r.MissingOnTarget, oid)
+		case got != meta:
+			r.Mismatched = append(r.Mismatched, fmt.Sprintf("%s is %s on target, %s in the source", oid, got, meta))
+		}
+	}
+	for oid := range target {
+		if _, ok := source[oid]; !ok {
+			r.ExtraOnTarget = append(r.ExtraOnTarget, oid)
+		}
+	}
+	sort.Strings(r.MissingOnTarget)
+	sort.Strings(r.ExtraOnTarget)
+	sort.Strings(r.Mismatched)
+}
+
+// deleteAzureRepo deletes a repository, which moves it to the project's
+// recycle bin.
+func deleteAzureRepo(ctx context.Context, org, project, repoID, token string) error {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s?api-version=7.0", org, url.PathEscape(project), repoID)
+	return azureRequest(ctx, "DELETE", apiURL, token, nil, http.StatusNoContent, nil)
+}
+
+// fidelityTest migrates repo ("owner/name") into a new scratch repository
+// of t's project the way a run pushes it, clones the scratch repository
+// back, and compares the complete ref sets and object inventories of both
+// clones. The scratch repository is then deleted and purged if confirm
+// agrees. LFS objects are not part of the comparison.
+func fidelityTest(ctx context.Context, t *azureTarget, repo, githubToken string, confirm func(scratch string) bool, stream io.Writer, logMsg func(string)) *fidelityReport {
+	r := &fidelityReport{Repo: repo, Started: time.Now()}
+	defer func() { r.Finished = time.Now() }()
+
+	work, err := os.MkdirTemp("", "gitui-fidelity-")
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	defer os.RemoveAll(work)
+	sourceDir, backDir := filepath.Join(work, "source.git"), filepath.Join(work, "back.git")
+
+	logMsg(fmt.Sprintf("Fidelity test: cloning %s.", repo))
+	githubRepoURL := fmt.Sprintf("https://%s@github.com/%s.git", githubToken, repo)
+	if output, err := runGitTransfer(ctx, "github.com", stream, cloneArgs(githubRepoURL, sourceDir)...); err != nil {
+		r.Error = fmt.Sprintf("cloning %s: %v: %s", repo, err, lastLine(output))
+		return r
+	}
+
+	r.Scratch = fmt.Sprintf("gitui-fidelity-%s-%s", repoShortName(repo), r.Started.UTC().Format("20060102T150405Z"))
+	scratch, err := createAzureRepo(ctx, r.Scratch, t.org, t.project, t.token)
+	if err != nil {
+		r.Error = fmt.Sprintf("creating scratch repo %s: %v", r.Scratch, err)
+		r.Scratch = ""
+		return r
+	}
+	logMsg(fmt.Sprintf("Fidelity test: created scratch repo %s.", r.Scratch))
+	defer func() {
+		if !confirm(r.Scratch) {
+			r.Cleanup = "kept " + r.Scratch
+			logMsg(fmt.Sprintf("Fidelity test: kept scratch repo %s.", r.Scratch))
+			return
+		}
+		if err := deleteAzureRepo(ctx, t.org, t.project, scratch.ID, t.token); err != nil {
+			r.Cleanup = fmt.Sprintf("could not delete %s: %v", r.Scratch, err)
+		} else if err := purgeDeletedAzureRepo(ctx, t.org, t.project, scratch.ID, t.token); err != nil {
+			r.Cleanup = fmt.Sprintf("deleted %s, but could not purge it from the recycle bin: %v", r.Scratch, err)
+		} else {
+			r.Cleanup = "deleted and purged " + r.Scratch
+		}
+		logMsg(fmt.Sprintf("Fidelity test: %s.", r.Cleanup))
+	}()
+
+	remote := authRemoteURL(ctx, scratch.RemoteURL, t.token)
+	if output, err := runGit(ctx, stream, "-C", sourceDir, "remote", "add", "target", remote); err != nil {
+		r.Error = fmt.Sprintf("adding the scratch remote: %v: %s", err, lastLine(output))
+		return r
+	}
+	logMsg(fmt.Sprintf("Fidelity test: pushing %s to %s.", repo, r.Scratch))
+	if _, output, err := pushRefs(ctx, sourceDir, "target", stream, "--all"); err != nil {
+		r.Error = fmt.Sprintf("pushing branches: %v: %s", err, lastLine(output))
+		return r
+	}
+	if _, output, err := pushRefs(ctx, sourceDir, "target", stream, "--tags"); err != nil {
+		r.Error = fmt.Sprintf("pushing tags: %v: %s", err, lastLine(output))
+		return r
+	}
+
+	logMsg(fmt.Sprintf("Fidelity test: cloning %s back.", r.Scratch))
+	if output, err := runGitTransfer(ctx, gitHost(remote), stream, "clone", "--bare", "--progress", remote, backDir); err != nil {
+		r.Error = fmt.Sprintf("cloning %s back: %v: %s", r.Scratch, err, lastLine(output))
+		return r
+	}
+
+	sourceRefs, err := localRefs(ctx, sourceDir)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	backRefs, err := localRefs(ctx, backDir)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.SourceRefs, r.TargetRefs = len(sourceRefs), len(backRefs)
+	r.RefDifferences = compareRefs(sourceRefs, backRefs)
+
+	sourceObjects, err := objectInventory(ctx, sourceDir)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	backObjects, err := objectInventory(ctx, backDir)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.compareInventories(sourceObjects, backObjects)
+	return r
+}
+
+// Markdown renders the report for an approval document.
+func (r *fidelityReport) Markdown() string {
+	var b strings.Builder
+	result := "PASS"
+	if !r.Passed() {
+		result = "FAIL"
+	}
+	fmt.Fprintf(&b, "# Fidelity test of %s: %s\n\n", r.Repo, result)
+	fmt.Fprintf(&b, "- Started: %s\n- Finished: %s\n", fileTimestamp(r.Started), fileTimestamp(r.Finished))
+	if r.Scratch != "" {
+		fmt.Fprintf(&b, "- Scratch repository: %s\n", r.Scratch)
+	}
+	if r.Cleanup != "" {
+		fmt.Fprintf(&b, "- Cleanup: %s\n", r.Cleanup)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "\nThe test did not complete: %s\n", r.Error)
+		return b.String()
+	}
+	b.WriteString("\nThe repository was cloned from GitHub, pushed to the scratch repository the way a migration pushes it, " +
+		"and cloned back. Both clones' branches and tags and their object inventories " +
+		"(`git cat-file --batch-all-objects --batch-check`) were compared. LFS objects are not included.\n\n")
+	b.WriteString("| | Source clone | Clone back |\n|---|---|---|\n")
+	fmt.Fprintf(&b, "| Branches and tags | %d | %d |\n", r.SourceRefs, r.TargetRefs)
+	fmt.Fprintf(&b, "| Objects | %d | %d |\n", r.SourceObjects, r.TargetObjects)
+
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", title, len(lines))
+		for i, l := range lines {
+			if i == maxListedOIDs {
+				fmt.Fprintf(&b, "- ... and %d more\n", len(lines)-i)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", l)
+		}
+	}
+	section("Ref differences", r.RefDifferences)
+	section("Objects missing on the target", r.MissingOnTarget)
+	section("Objects only on the target", r.ExtraOnTarget)
+	section("Objects that differ", r.Mismatched)
+	if r.Passed() {
+		b.WriteString("\nEvery ref and every object is identical.\n")
+	}
+	return b.String()
+}
+
+// save writes the report to reportsDir and returns its path.
+func (r *fidelityReport) save() (string, error) {
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(reportsDir, r.Started.UTC().Format("20060102T150405Z")+"-FIDELITY.md")
+	return path, writeFileAtomic(path, []byte(r.Markdown()), 0644)
+}
@@ -0,0 +1,92 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showFidelityTest opens the fidelity test: pick one source repository to
+// migrate into a scratch repository of t's project and compare object for
+// object. The report is saved next to the run reports, and git's output
+// goes to a tab of tails.
+func showFidelityTest(w fyne.Window, t *azureTarget, org, githubToken string, tails *tailView, logMsg func(string)) {
+	// Closing the dialog cancels a test in progress.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var repoNames []string
+	repos, err := listGitHubRepos(ctx, org, githubToken)
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not list GitHub repositories: %v", err))
+	}
+	for _, r := range repos {
+		repoNames = append(repoNames, r.FullName)
+	}
+
+	repoSelect := widget.NewSelectEntry(repoNames)
+	repoSelect.SetPlaceHolder("owner/repo")
+	output := widget.NewMultiLineEntry()
+	output.Wrapping = fyne.TextWrapWord
+	output.SetText("The repository is pushed to a new scratch repository in " + t.project + ", cloned back and compared. " +
+		"You are asked before the scratch repository is deleted.")
+
+	confirmDelete := func(scratch string) bool {
+		answer := make(chan bool)
+		dialog.ShowConfirm("Delete scratch repository",
+			fmt.Sprintf("Delete %s and purge it from the recycle bin? This cannot be undone.", scratch),
+			func(ok bool) { answer <- ok }, w)
+		return <-answer
+	}
+
+	var runBtn *widget.Button
+	runBtn = widget.NewButton("Run Test", func() {
+		repo := strings.TrimSpace(repoSelect.Text)
+		if repo == "" {
+			return
+		}
+		if hold, err := holdOf(repo); err != nil {
+			output.SetText(fmt.Sprintf("Not testing %s, the holds could not be checked: %v", repo, err))
+			return
+		} else if hold != nil {
+			output.SetText(fmt.Sprintf("Not testing %s: %s.", repo, hold))
+			return
+		}
+		runBtn.Disable()
+		output.SetText(fmt.Sprintf("Testing %s...", repo))
+		go func() {
+			defer runBtn.Enable()
+			tail := tails.Start("fidelity " + repo)
+			r := fidelityTest(ctx, t, repo, githubToken, confirmDelete, tail, logMsg)
+			if r.Passed() {
+				tail.Finish("passed")
+			} else {
+				tail.Finish("failed")
+			}
+			text := r.Markdown()
+			if path, err := r.save(); err != nil {
+				logMsg(fmt.Sprintf("Error saving fidelity report: %v", err))
+			} else {
+				logMsg(fmt.Sprintf("Fidelity report saved to %s.", path))
+				text += fmt.Sprintf("\nSaved to %s.\n", path)
+			}
+			output.SetText(text)
+		}()
+	})
+
+	content := container.NewBorder(
+		widget.NewForm(widget.NewFormItem("Repository", container.NewBorder(nil, nil, nil, runBtn, repoSelect))),
+		nil, nil, nil,
+		container.NewVScroll(output),
+	)
+	d := dialog.NewCustom("Fidelity test", "Close", content, w)
+	d.SetOnClosed(cancel)
+	d.Resize(fyne.NewSize(700, 500))
+	d.Show()
+}
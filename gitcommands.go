@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// The arguments of the git commands a migration runs are built here, both
+// for running them and for the preview of what a run would run. The
+// tests run a migration, record what runGit ran and hold the preview to
+// it, so the preview cannot drift from what actually runs.
+
+// cloneArgs clones sourceURL bare into dir.
+func cloneArgs(sourceURL, dir string) []string {
+	return []string{"clone", "--bare", "--progress", sourceURL, dir}
+}
+
+// headArgs reads the default branch of the clone in dir.
+func headArgs(dir string) []string {
+	return []string{"-C", dir, "symbolic-ref", "--short", "HEAD"}
+}
+
+// branchDeleteArgs deletes branch from the clone in dir.
+func branchDeleteArgs(dir, branch string) []string {
+	return []string{"-C", dir, "update-ref", "-d", "refs/heads/" + branch}
+}
+
+// remoteAddArgs adds remote, at url, to the clone in dir.
+func remoteAddArgs(dir, remote, url string) []string {
+	return []string{"-C", dir, "remote", "add", remote, url}
+}
+
+// lfsFetchArgs fetches every LFS object of the clone in dir from remote.
+func lfsFetchArgs(dir, remote string) []string {
+	return []string{"-C", dir, "lfs", "fetch", "--all", remote}
+}
+
+// lfsPushArgs pushes every LFS object of the clone in dir to remote;
+// allowIncomplete lets the push go on without objects the source is
+// missing.
+func lfsPushArgs(dir, remote string, allowIncomplete bool) []string {
+	args := []string{"-C", dir}
+	if allowIncomplete {
+		args = append(args, "-c", "lfs.allowincompletepush=true")
+	}
+	return append(args, "lfs", "push", "--all", remote)
+}
+
+// pushArgs pushes refspecs of the clone in dir to remote.
+func pushArgs(dir, remote string, refspecs ...string) []string {
+	return append([]string{"-C", dir, "push", "--porcelain", "--progress", remote}, refspecs...)
+}
+
+// lsRemoteArgs lists the branches and tags of remote.
+func lsRemoteArgs(dir, remote string) []string {
+	return []string{"-C", dir, "ls-remote", "--heads", "--tags", remote}
+}
+
+// compareFetchArgs fetches the branches and tags of remote into the clone
+// in dir, under verifyRefPrefix, to compare them with its own.
+func compareFetchArgs(dir, remote string) []string {
+	return []string{"-C", dir, "fetch", "--no-tags", remote,
+		"+refs/heads/*:" + verifyRefPrefix + "heads/*", "+refs/tags/*:" + verifyRefPrefix + "tags/*"}
+}
+
+// Placeholders of the git command preview for what is only known as the
+// run goes: where the clone is made, and the URL of the repository the
+// target creates.
+const (
+	previewCloneDir  = "$CLONE_DIR"
+	previewTargetURL = "$TARGET_URL"
+)
+
+// gitStep is a git command of the preview.
+type gitStep struct {
+	// Args are the command's arguments; a step without any is a note on
+	// what the run does at that point besides running git.
+	Args []string
+	// When is the condition it runs under, "" if it always runs. A step
+	// that runs once per branch, tag or chunk has placeholders in <>.
+	When string
+}
+
+// String formats the command for a shell.
+func (s gitStep) String() string {
+	words := []string{"git"}
+	for _, a := range s.Args {
+		words = append(words, shellWord(a))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellWord quotes s for a POSIX shell, leaving the preview's placeholder
+// variables to be expanded.
+func shellWord(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.HasPrefix(s, "$") {
+		return `"` + s + `"`
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@+%,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// gitCommands returns the git commands Migrate runs to move repo to the
+// target, with the run's options, in order; credentials are left out, as
+// runGit leaves them out, and the URL of the repository the target creates
+// is $TARGET_URL. Of the commands that only read the clone, just the one
+// reading its default branch is shown; listing refs and the like are not.
+// Repositories split by a split plan are pushed part
+// by part instead, which the preview does not cover.
+func (r *migrationRun) gitCommands(ctx context.Context, repo string) []gitStep {
+	dir := previewCloneDir
+	steps := []gitStep{
+		{Args: cloneArgs(stripCredentials(r.Source.CloneURL(ctx, repo)), dir)},
+		{Args: headArgs(dir)},
+	}
+	if !r.Filter.Empty() {
+		steps = append(steps, gitStep{Args: branchDeleteArgs(dir, "<branch>"), When: fmt.Sprintf(
+			"for each branch the branch filter leaves out (include %s; exclude %s), except the default branch",
+			orDefault(strings.Join(r.Filter.Include, ", "), "all"), orDefault(strings.Join(r.Filter.Exclude, ", "), "none"))})
+	}
+	steps = append(steps, gitStep{Args: remoteAddArgs(dir, "target", previewTargetURL)})
+	if !r.SkipLFS {
+		steps = append(steps,
+			gitStep{Args: lfsFetchArgs(dir, "origin"), When: "if the repository uses Git LFS"},
+			gitStep{Args: lfsPushArgs(dir, "target", false), When: "if the repository uses Git LFS"},
+		)
+		if r.LFS == lfsContinueOnMissing {
+			steps = append(steps, gitStep{Args: lfsPushArgs(dir, "target", true),
+				When: "instead of the LFS push above, if GitHub cannot serve some LFS objects"})
+		}
+	}
+	if az, ok := r.Target.(*azureTarget); ok && az.token == entraToken {
+		steps = append(steps, gitStep{When: "before each push, the Entra ID access token for $TARGET_URL is signed again for git's credential helper"})
+	}
+	if r.Chunks != nil && r.Chunks.Configured > 0 {
+		how := strings.TrimSuffix(r.Chunks.Describe(), ".")
+		steps = append(steps, gitStep{Args: pushArgs(dir, "target", "<commit>:refs/heads/<branch>"),
+			When: "for each chunk along each branch's first-parent history (" + strings.ToLower(how[:1]) + how[1:] + ")"})
+	}
+	steps = append(steps,
+		gitStep{Args: pushArgs(dir, "target", "--all")},
+		gitStep{Args: pushArgs(dir, "target", "--tags")},
+		gitStep{Args: pushArgs(dir, "target", "<tag>:<tag>"), When: "for each tag the tag push could not push, once"},
+		gitStep{Args: lsRemoteArgs(dir, "target")},
+		gitStep{Args: lsRemoteArgs(dir, "origin")},
+		gitStep{Args: compareFetchArgs(dir, "target")},
+	)
+	return steps
+}
+
+// gitCommandsText formats steps as the preview shows them, redacting
+// known, the run's tokens, wherever they would still appear.
+func gitCommandsText(steps []gitStep, known []string) string {
+	var b strings.Builder
+	for _, s := range steps {
+		switch {
+		case len(s.Args) == 0:
+			fmt.Fprintf(&b, "# %s.\n", s.When)
+			continue
+		case s.When != "":
+			fmt.Fprintf(&b, "# %s:\n", s.When)
+		}
+		fmt.Fprintln(&b, redactText(s.String(), known))
+	}
+	return b.String()
+}
+
+// gitCommandsScript formats steps as a shell script to migrate repo by
+// hand. It carries no credentials: git's own credential helper must supply
+// them. Steps that run only under a condition are left commented out.
+func gitCommandsScript(repo string, steps []gitStep, known []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n")
+	fmt.Fprintf(&b, "# The git commands gitui runs to migrate %s, for running by hand.\n", repo)
+	fmt.Fprintf(&b, "#\n")
+	fmt.Fprintf(&b, "# CREDENTIALS ARE NOT INCLUDED. Set up your own credential helper\n")
+	fmt.Fprintf(&b, "# (git config credential.helper) for github.com and the target first.\n")
+	fmt.Fprintf(&b, "# Create the target repository yourself and set TARGET_URL to its URL.\n")
+	fmt.Fprintf(&b, "# Steps that depend on the repository are commented out; run those that\n")
+	fmt.Fprintf(&b, "# apply, filling in what is in <>.\n")
+	fmt.Fprintf(&b, "set -e\n")
+	fmt.Fprintf(&b, ": \"${TARGET_URL:?set TARGET_URL to the URL of the target repository}\"\n")
+	fmt.Fprintf(&b, "CLONE_DIR=\"$(mktemp -d)/%s.git\"\n", repoShortName(repo))
+	for _, s := range steps {
+		line := redactText(s.String(), known)
+		if len(s.Args) == 0 {
+			fmt.Fprintf(&b, "\n# %s.\n", s.When)
+			continue
+		}
+		if s.When == "" {
+			fmt.Fprintf(&b, "\n%s\n", line)
+			continue
+		}
+		fmt.Fprintf(&b, "\n# %s:\n# %s\n", s.When, line)
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// previewedCommand reports whether args is a command the preview shows:
+// one that changes the clone or talks to a remote, or reads the default
+// branch.
+func previewedCommand(args []string) bool {
+	if len(args) > 2 && args[0] == "-C" {
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "clone", "symbolic-ref", "update-ref", "push", "ls-remote", "fetch":
+		return true
+	case "remote":
+		return len(args) > 1 && args[1] == "add"
+	case "lfs":
+		return len(args) > 1 && (args[1] == "fetch" || args[1] == "push")
+	}
+	return false
+}
+
+// stepPattern matches the commands step stands for, its <placeholders>
+// matching anything.
+func stepPattern(step gitStep) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(strings.Join(step.Args, "\x00"))
+	return regexp.MustCompile("^" + regexp.MustCompile(`<[^>]+>`).ReplaceAllString(quoted, `.+`) + "$")
+}
+
+// The preview lists every git command a migration runs, and the run runs
+// every command the preview lists unconditionally.
+func TestGitCommandsMatchRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fakes use a shell hook")
+	}
+	bare := testBareRepo(t, "main", "release/2024")
+	src := &fakeSource{repos: map[string]string{"acme/one": bare}}
+	target := &fakeTarget{dir: t.TempDir()}
+	useFakeProviders(t, src, target)
+
+	var mu sync.Mutex
+	var ran [][]string
+	recordGit = func(args []string) {
+		mu.Lock()
+		ran = append(ran, append([]string(nil), args...))
+		mu.Unlock()
+	}
+	defer func() { recordGit = nil }()
+	if got := runHeadless([]string{"--ado-org", "acme", "--ado-project", "p", "--repos", "acme/one", "--local-copies", "discard"}); got != exitOK {
+		t.Fatalf("runHeadless = %d, want %d", got, exitOK)
+	}
+	recordGit = nil
+
+	// Put the preview's placeholders in for the clone directory and the
+	// created repository's URL.
+	var cloneDir string
+	for _, args := range ran {
+		if len(args) > 0 && args[0] == "clone" {
+			cloneDir = args[len(args)-1]
+		}
+	}
+	if cloneDir == "" {
+		t.Fatalf("no clone among the commands run: %q", ran)
+	}
+	targetURL := filepath.Join(target.dir, "one.git")
+	var run []string
+	for _, args := range ran {
+		if !previewedCommand(args) {
+			continue
+		}
+		words := make([]string, len(args))
+		for i, a := range args {
+			a = strings.ReplaceAll(a, cloneDir, previewCloneDir)
+			words[i] = strings.ReplaceAll(a, targetURL, previewTargetURL)
+		}
+		run = append(run, strings.Join(words, "\x00"))
+	}
+
+	steps := (&migrationRun{Source: src, Target: target}).gitCommands(t.Context(), "acme/one")
+	ranStep := make([]bool, len(steps))
+	for _, cmd := range run {
+		found := false
+		for i, step := range steps {
+			if len(step.Args) > 0 && stepPattern(step).MatchString(cmd) {
+				found, ranStep[i] = true, true
+			}
+		}
+		if !found {
+			t.Errorf("the run ran git %s, which the preview does not list", strings.ReplaceAll(cmd, "\x00", " "))
+		}
+	}
+	for i, step := range steps {
+		if step.When == "" && !ranStep[i] {
+			t.Errorf("the preview lists %s, which the run did not run", step)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showGitCommands previews the git commands a run with the current options
+// would run for a repository of repos, credentials redacted, and copies
+// them as a shell script for running by hand. run returns the run the
+// options make and the tokens to redact.
+func showGitCommands(w fyne.Window, repos []string, run func() (*migrationRun, []string, error), logMsg func(string)) {
+	if len(repos) == 0 {
+		dialog.ShowInformation("Git commands", "Fetch or check the repositories to migrate first.", w)
+		return
+	}
+	r, known, err := run()
+	if err != nil {
+		logMsg(fmt.Sprintf("Error: %v", err))
+		dialog.ShowError(err, w)
+		return
+	}
+	output := widget.NewMultiLineEntry()
+	output.TextStyle = fyne.TextStyle{Monospace: true}
+	output.Wrapping = fyne.TextWrapOff
+	note := widget.NewLabel("These are the commands the run would run, built by the same code. $CLONE_DIR is the clone's temporary directory and $TARGET_URL the URL the target returns when it creates the repository. Commented commands run only under the condition given.")
+	note.Wrapping = fyne.TextWrapWord
+
+	var repo string
+	var steps []gitStep
+	repoSelect := widget.NewSelect(repos, func(selected string) {
+		repo = selected
+		steps = r.gitCommands(context.Background(), repo)
+		if r.Splits.Get(repo) != nil {
+			output.SetText(fmt.Sprintf("# %s is split by the split plan; its parts are pushed from rewritten histories instead.\n", repo))
+			return
+		}
+		output.SetText(gitCommandsText(steps, known))
+	})
+	copyBtn := widget.NewButton("Copy as Shell Script", func() {
+		if repo == "" {
+			return
+		}
+		w.Clipboard().SetContent(gitCommandsScript(repo, steps, known))
+		logMsg(fmt.Sprintf("Copied the git commands for %s as a shell script, without credentials; git's credential helper must supply them.", repo))
+	})
+	repoSelect.SetSelectedIndex(0)
+
+	content := container.NewBorder(
+		container.NewVBox(note, widget.NewForm(widget.NewFormItem("Repository", repoSelect))),
+		copyBtn, nil, nil,
+		output,
+	)
+	d := dialog.NewCustom("Git commands", "Close", content, w)
+	d.Resize(fyne.NewSize(860, 560))
+	d.Show()
+}
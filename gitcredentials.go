@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens never reach git in a URL. runGit takes the credentials out of
+// every URL it is given and hands them to git through a credential helper,
+// this executable run as "gitui credential-helper", which reads them from
+// the environment of the git command. Remote URLs saved in clones, git's
+// own messages and the process list carry no token.
+
+// gitCredentialsEnv is the environment variable that passes the
+// credentials to the helper.
+const gitCredentialsEnv = "GITUI_GIT_CREDENTIALS"
+
+// credentialHelperArg is the argument that runs the executable as the
+// credential helper.
+const credentialHelperArg = "credential-helper"
+
+// tokenUsername is the username sent with a token given as a URL's
+// username, as this tool writes GitHub and Azure DevOps URLs. Both take
+// any username with a token for the password.
+const tokenUsername = "x-access-token"
+
+// gitCredential is a username and password for the repositories under a
+// credential scope.
+type gitCredential struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// credentialRefresher signs credentials that expire again: CodeCommit's
+// signed passwords are accepted for about 15 minutes, less than a large
+// push with its retries can take.
+type credentialRefresher struct {
+	refresh func(ctx context.Context) (string, error) // returns the remote URL freshly signed
+	signed  time.Time
+}
+
+// credentialRefreshAge is how old expiring credentials may be when a git
+// command starts before they are signed again.
+const credentialRefreshAge = 5 * time.Minute
+
+var (
+	gitCredentialsMu        sync.Mutex
+	gitCredentials          = map[string]gitCredential{}        // by credentialScope
+	gitCredentialRefreshers = map[string]*credentialRefresher{} // by credentialScope
+)
+
+// credentialScope returns the key of the credentials for repositories at
+// host and path: the host and the path's first segment, the owner,
+// organization or group, so that a GitHub-to-GitHub migration can use one
+// token for the source and another for the target. A CodeCommit password
+// is signed for one repository's path, so there the scope is the
+// repository.
+func credentialScope(host, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if strings.HasPrefix(strings.ToLower(host), "git-codecommit.") {
+		return strings.ToLower(host) + "/" + path
+	}
+	first, _, _ := strings.Cut(path, "/")
+	return strings.ToLower(host) + "/" + first
+}
+
+// setCredentialRefresher makes runGit sign the credentials of remoteURL
+// again with refresh once they are credentialRefreshAge old.
+func setCredentialRefresher(remoteURL string, refresh func(ctx context.Context) (string, error)) {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return
+	}
+	gitCredentialsMu.Lock()
+	gitCredentialRefreshers[credentialScope(u.Host, u.Path)] = &credentialRefresher{refresh: refresh, signed: time.Now()}
+	gitCredentialsMu.Unlock()
+}
+
+// refreshCredentials signs again the expiring credentials that are
+// credentialRefreshAge old at now. One that cannot be signed is left as
+// it is; git's error says it was refused.
+func refreshCredentials(ctx context.Context, now time.Time) {
+	var due []*credentialRefresher
+	gitCredentialsMu.Lock()
+	for _, r := range gitCredentialRefreshers {
+		if now.Sub(r.signed) >= credentialRefreshAge {
+			r.signed = now
+			due = append(due, r)
+		}
+	}
+	gitCredentialsMu.Unlock()
+	for _, r := range due {
+		if remoteURL, err := r.refresh(ctx); err == nil {
+			stripCredentials(remoteURL)
+		}
+	}
+}
+
+// stripCredentials returns arg with the credentials of an http(s) URL
+// removed, and keeps them for the credential helper. A URL with only a
+// username has a token for it, which is sent as the password. Anything
+// else is returned as it is.
+func stripCredentials(arg string) string {
+	if !strings.HasPrefix(arg, "https://") && !strings.HasPrefix(arg, "http://") {
+		return arg
+	}
+	u, err := url.Parse(arg)
+	if err != nil || u.User == nil {
+		return arg
+	}
+	user := u.User.Username()
+	password, ok := u.User.Password()
+	if !ok {
+		user, password = tokenUsername, user
+		u.User = nil
+	} else {
+		u.User = url.User(user)
+	}
+	if password == "" {
+		return arg
+	}
+	registerSecret(password)
+	gitCredentialsMu.Lock()
+	gitCredentials[credentialScope(u.Host, u.Path)] = gitCredential{User: user, Password: password}
+	gitCredentialsMu.Unlock()
+	return u.String()
+}
+
+// credentialArgs returns args with credentials stripped from them, after
+// the options that make the credential helper git's only one, and the
+// environment passing it the credentials kept so far. With none kept,
+// args are left as they are and env is nil.
+func credentialArgs(ctx context.Context, args []string) (withHelper, env []string) {
+	refreshCredentials(ctx, time.Now())
+	stripped := make([]string, len(args))
+	for i, arg := range args {
+		stripped[i] = stripCredentials(arg)
+	}
+	gitCredentialsMu.Lock()
+	encoded, err := json.Marshal(gitCredentials)
+	empty := len(gitCredentials) == 0
+	gitCredentialsMu.Unlock()
+	exe, exeErr := os.Executable()
+	if empty || err != nil || exeErr != nil {
+		return stripped, nil
+	}
+	helper := "!'" + strings.ReplaceAll(exe, "'", `'\''`) + "' " + credentialHelperArg
+	withHelper = append([]string{
+		// An empty helper clears the user's, which might answer first
+		// with other credentials.
+		"-c", "credential.helper=",
+		"-c", "credential.helper=" + helper,
+		"-c", "credential.useHttpPath=true",
+	}, stripped...)
+	return withHelper, append(os.Environ(), gitCredentialsEnv+"="+string(encoded))
+}
+
+// runCredentialHelper answers git's request for credentials on in with
+// those in the environment matching its host and path, if any. Only "get"
+// is answered; git's "store" and "erase" are read and ignored.
+func runCredentialHelper(action string, in io.Reader, out io.Writer) error {
+	attrs := map[string]string{}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			attrs[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil || action != "get" {
+		return err
+	}
+	var credentials map[string]gitCredential
+	if err := json.Unmarshal([]byte(os.Getenv(gitCredentialsEnv)), &credentials); err != nil {
+		return nil // not started by runGit; git asks elsewhere
+	}
+	c, ok := credentials[credentialScope(attrs["host"], attrs["path"])]
+	if !ok {
+		return nil
+	}
+	_, err := fmt.Fprintf(out, "username=%s\npassword=%s\n", c.User, c.Password)
+	return err
+}
+
+func init() {
+	// git runs the executable as its credential helper; answer and exit
+	// before anything else starts.
+	if len(os.Args) == 3 && os.Args[1] == credentialHelperArg {
+		if err := runCredentialHelper(os.Args[2], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "gitui credential-helper:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// giteaTarget migrates repositories into a Gitea or Forgejo organization.
+type giteaTarget struct {
+	baseURL string // e.g. https://git.example.com
+	org     string
+	token   string
+}
+
+// giteaRepo is the subset of the Gitea repository API object the migration
+// uses.
+type giteaRepo struct {
+	Name          string `json:"name"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+	Archived      bool   `json:"archived"`
+}
+
+// request sends an authenticated request to the Gitea API and decodes the
+// JSON response into out (if non-nil).
+func (t *giteaTarget) request(ctx context.Context, method, apiPath string, payload interface{}, wantStatus int, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		jsonPayload, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(t.baseURL, "/")+"/api/v1"+apiPath, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+t.token)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		return newAPIError("Gitea", resp)
+	}
+	if out == nil {
+		return nil
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func (t *giteaTarget) repoPath(name string) string {
+	return fmt.Sprintf("/repos/%s/%s", url.PathEscape(t.org), url.PathEscape(name))
+}
+
+func (t *giteaTarget) Name() string { return "Gitea/Forgejo" }
+
+// CreateRepo creates a private repository in the organization, or reuses
+// one that already exists, and returns its clone URL with the token as the
+// password.
+func (t *giteaTarget) CreateRepo(ctx context.Context, name string) (string, error) {
+	var repo giteaRepo
+	err := t.request(ctx, "GET", t.repoPath(name), nil, http.StatusOK, &repo)
+	if apiErr, ok := err.(*apiError); ok && apiErr.StatusCode == http.StatusNotFound {
+		payload := map[string]interface{}{
+			"name":    name,
+			"private": true,
+		}
+		err = t.request(ctx, "POST", fmt.Sprintf("/orgs/%s/repos", url.PathEscape(t.org)), payload, http.StatusCreated, &repo)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cloneURL, err := url.Parse(repo.CloneURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing clone URL: %v", err)
+	}
+	cloneURL.User = url.UserPassword("gitui", t.token)
+	return cloneURL.String(), nil
+}
+
+func (t *giteaTarget) SetDefaultBranch(ctx context.Context, name, branch string) error {
+	payload := map[string]interface{}{
+		"default_branch": strings.TrimPrefix(branch, "refs/heads/"),
+	}
+	return t.request(ctx, "PATCH", t.repoPath(name), payload, http.StatusOK, nil)
+}
+
+// MakeReadOnly archives the repository, which keeps it browsable, for any
+// mode other than archiveLeaveWritable.
+func (t *giteaTarget) MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error) {
+	if mode == archiveLeaveWritable {
+		return "left writable", nil
+	}
+	payload := map[string]interface{}{
+		"archived": true,
+	}
+	if err := t.request(ctx, "PATCH", t.repoPath(name), payload, http.StatusOK, nil); err != nil {
+		return "", err
+	}
+	return "archived", nil
+}
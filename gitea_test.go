@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitea is a Gitea API with one organization, enough of it for
+// giteaTarget: repositories are looked up, created, and PATCHed.
+type fakeGitea struct {
+	*httptest.Server
+	org, token string
+
+	mu       sync.Mutex
+	repos    map[string]map[string]interface{} // by name
+	requests []string                          // "METHOD path"
+}
+
+func newFakeGitea(t *testing.T, org, token string, existing ...string) *fakeGitea {
+	g := &fakeGitea{org: org, token: token, repos: map[string]map[string]interface{}{}}
+	for _, name := range existing {
+		g.repos[name] = map[string]interface{}{"name": name}
+	}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *fakeGitea) serve(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "token "+g.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	repoPrefix := "/api/v1/repos/" + g.org + "/"
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/api/v1/orgs/"+g.org+"/repos":
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		name, _ := body["name"].(string)
+		if g.repos[name] != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		g.repos[name] = body
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(g.repoJSON(name))
+	case strings.HasPrefix(r.URL.Path, repoPrefix):
+		name := strings.TrimPrefix(r.URL.Path, repoPrefix)
+		repo := g.repos[name]
+		if repo == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "PATCH" {
+			json.NewDecoder(r.Body).Decode(&repo)
+		}
+		json.NewEncoder(w).Encode(g.repoJSON(name))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// repoJSON returns the API object of repository name.
+func (g *fakeGitea) repoJSON(name string) giteaRepo {
+	repo := g.repos[name]
+	branch, _ := repo["default_branch"].(string)
+	archived, _ := repo["archived"].(bool)
+	return giteaRepo{Name: name, CloneURL: g.URL + "/" + g.org + "/" + name + ".git", DefaultBranch: branch, Archived: archived}
+}
+
+func (g *fakeGitea) target() *giteaTarget {
+	return &giteaTarget{baseURL: g.URL + "/", org: g.org, token: g.token}
+}
+
+func TestGiteaCreateRepo(t *testing.T) {
+	g := newFakeGitea(t, "mirror", "gitea-token", "existing")
+
+	pushURL, err := g.target().CreateRepo(t.Context(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(pushURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password, _ := u.User.Password(); password != "gitea-token" || u.Path != "/mirror/api.git" {
+		t.Errorf("push URL %s: want the token as the password and path /mirror/api.git", pushURL)
+	}
+	if private, _ := g.repos["api"]["private"].(bool); !private {
+		t.Errorf("created %v, want a private repository", g.repos["api"])
+	}
+
+	// An existing repository is reused, not created again.
+	g.requests = nil
+	if _, err := g.target().CreateRepo(t.Context(), "existing"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"GET /api/v1/repos/mirror/existing"}; strings.Join(g.requests, ",") != strings.Join(want, ",") {
+		t.Errorf("requests %v, want %v", g.requests, want)
+	}
+}
+
+func TestGiteaBadToken(t *testing.T) {
+	g := newFakeGitea(t, "mirror", "gitea-token")
+	target := g.target()
+	target.token = "wrong"
+	_, err := target.CreateRepo(t.Context(), "api")
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Service != "Gitea" {
+		t.Errorf("err = %v, want a Gitea 401", err)
+	}
+}
+
+func TestGiteaSetDefaultBranchAndArchive(t *testing.T) {
+	g := newFakeGitea(t, "mirror", "gitea-token", "api")
+	target := g.target()
+
+	if err := target.SetDefaultBranch(t.Context(), "api", "refs/heads/release/2024"); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.repoJSON("api").DefaultBranch; got != "release/2024" {
+		t.Errorf("default branch %q, want release/2024", got)
+	}
+
+	if outcome, err := target.MakeReadOnly(t.Context(), "api", archiveLeaveWritable); err != nil || g.repoJSON("api").Archived {
+		t.Errorf("leave writable: %q, %v; archived %v", outcome, err, g.repoJSON("api").Archived)
+	}
+	if outcome, err := target.MakeReadOnly(t.Context(), "api", archiveDisable); err != nil || outcome != "archived" || !g.repoJSON("api").Archived {
+		t.Errorf("disable: %q, %v; archived %v", outcome, err, g.repoJSON("api").Archived)
+	}
+}
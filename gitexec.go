@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// recordGit, if set, is given the arguments of each git command runGit
+// runs, credentials and all. The tests use it to hold the git command
+// preview to what a run runs.
+var recordGit func(args []string)
+
+// runGit runs git with args under ctx, streaming its combined output line
+// by line to stream (if non-nil) while also capturing it for error
+// messages. Cancelling ctx kills git.
+func runGit(ctx context.Context, stream io.Writer, args ...string) (string, error) {
+	if recordGit != nil {
+		recordGit(args)
+	}
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if stream != nil {
+		w = io.MultiWriter(&buf, stream)
+	}
+	// exec.Cmd writes stdout and stderr from separate goroutines when they
+	// are different writers, so share one locked writer for both.
+	sw := &syncWriter{w: w}
+
+	args, env := credentialArgs(ctx, args)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = env
+	cmd.Stdout = sw
+	cmd.Stderr = sw
+	// A killed git can leave helpers (git-remote-https, index-pack)
+	// holding the output open; stop waiting for them after a while.
+	cmd.WaitDelay = 10 * time.Second
+	watcher, ok := stream.(gitWatcher)
+	if !ok {
+		err := cmd.Run()
+		return buf.String(), err
+	}
+	if err := cmd.Start(); err != nil {
+		return buf.String(), err
+	}
+	stop := watcher.Watch(cmd.Process.Pid, gitDir(args))
+	err := cmd.Wait()
+	stop()
+	return buf.String(), err
+}
+
+// syncWriter serializes writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// lineWriter splits written bytes into lines and hands each to fn. Git
+// progress output rewrites a line with '\r', so that counts as a line end
+// too; empty lines are dropped.
+type lineWriter struct {
+	fn      func(line string)
+	pending []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' || b == '\r' {
+			if len(lw.pending) > 0 {
+				lw.fn(string(lw.pending))
+				lw.pending = lw.pending[:0]
+			}
+			continue
+		}
+		lw.pending = append(lw.pending, b)
+	}
+	return len(p), nil
+}
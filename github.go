@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitHubRepo is the subset of the GitHub repository API object the
+// migration uses.
+type gitHubRepo struct {
+	FullName      string `json:"full_name"`
+	Name          string `json:"name"`
+	DefaultBranch string `json:"default_branch"`
+	Archived      bool   `json:"archived"`
+	Fork          bool   `json:"fork"`
+	Size          int    `json:"size"` // KB
+	// License is nil if GitHub detected none; listings include it too.
+	License    *gitHubLicense `json:"license"`
+	Visibility string         `json:"visibility"` // public, private or internal
+	Private    bool           `json:"private"`
+	HasWiki    bool           `json:"has_wiki"`
+}
+
+// repoVisibilities are the visibilities a GitHub listing can be narrowed
+// to; "all" keeps every repository. Only enterprise organizations have
+// internal repositories.
+var repoVisibilities = []string{"all", "public", "private", "internal"}
+
+// visibilityNames are the UI labels for each of repoVisibilities, in order.
+var visibilityNames = []string{
+	"All repositories",
+	"Public only",
+	"Private only",
+	"Internal only",
+}
+
+// parseVisibility checks a visibility filter; empty means all.
+func parseVisibility(text string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(text))
+	if v == "" {
+		return "all", nil
+	}
+	if !containsString(repoVisibilities, v) {
+		return "", fmt.Errorf("visibility %q is not one of %s", text, strings.Join(repoVisibilities, ", "))
+	}
+	return v, nil
+}
+
+// visibilityOf returns r's visibility. Older GitHub Enterprise Server
+// versions list only the private flag.
+func visibilityOf(r gitHubRepo) string {
+	switch {
+	case r.Visibility != "":
+		return r.Visibility
+	case r.Private:
+		return "private"
+	}
+	return "public"
+}
+
+// matchesVisibility reports whether r passes the visibility filter.
+func matchesVisibility(r gitHubRepo, visibility string) bool {
+	return visibility == "" || visibility == "all" || visibilityOf(r) == visibility
+}
+
+// gitHubGet sends an authenticated GET to the GitHub API, decodes the JSON
+// response into out, and returns the rel="next" page URL, if any.
+func gitHubGet(ctx context.Context, apiURL, token string, out interface{}) (string, error) {
+	next, _, _, err := gitHubGetETag(ctx, apiURL, token, "", out)
+	return next, err
+}
+
+// gitHubGetETag is gitHubGet that also returns the response's ETag. With
+// etag set, the request is conditional: if the resource has not changed,
+// notModified is set, out is left alone and next is "".
+func gitHubGetETag(ctx context.Context, apiURL, token, etag string, out interface{}) (next, newETag string, notModified bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	// Authenticate with GitHub PAT
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return "", etag, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError("GitHub", resp)
+		apiErr.RateLimitReset = gitHubRateLimitReset(resp)
+		return "", "", false, apiErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", false, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return "", "", false, err
+	}
+	return nextPageURL(resp.Header.Get("Link")), resp.Header.Get("ETag"), false, nil
+}
+
+// gitHubRateLimitReset returns when the rate limit that refused resp
+// resets, or the zero time if resp was not refused by a rate limit. GitHub
+// answers an exhausted limit with 403 (or 429) and X-RateLimit-Remaining 0;
+// other 403s, such as a missing scope or SSO authorization, are not rate
+// limits.
+func gitHubRateLimitReset(resp *http.Response) time.Time {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(reset, 0)
+}
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPageURL extracts the rel="next" URL from a GitHub Link header.
+func nextPageURL(link string) string {
+	if m := linkNextPattern.FindStringSubmatch(link); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// getGitHubRepo fetches metadata for a single repository ("owner/repo").
+func getGitHubRepo(ctx context.Context, fullName, token string) (*gitHubRepo, error) {
+	var repo gitHubRepo
+	if _, err := gitHubGet(ctx, "https://api.github.com/repos/"+fullName, token, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// listGitHubRepos lists every repository of org, following pagination. An
+// empty org lists the repositories the token's user can access.
+func listGitHubRepos(ctx context.Context, org, token string) ([]gitHubRepo, error) {
+	return listGitHubReposPaged(ctx, org, token, nil)
+}
+
+// listGitHubReposPaged lists like listGitHubRepos, following the Link
+// header's next page to the end. onPage, if set, gets the repositories
+// listed so far after every page, so a listing of hundreds shows progress.
+func listGitHubReposPaged(ctx context.Context, org, token string, onPage func([]gitHubRepo)) ([]gitHubRepo, error) {
+	apiURL := "https://api.github.com/user/repos?per_page=100"
+	if org != "" {
+		apiURL = fmt.Sprintf("https://api.github.com/orgs/%s/repos?per_page=100", org)
+	}
+
+	var all []gitHubRepo
+	for apiURL != "" {
+		var page []gitHubRepo
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, page...)
+		if onPage != nil {
+			onPage(all)
+		}
+		apiURL = next
+	}
+	return all, nil
+}
+
+// errSourceRemoved means a repository that was listed earlier in the run no
+// longer exists on GitHub.
+var errSourceRemoved = errors.New("source removed after planning")
+
+// repoNotFound reports whether git output says the remote repository does
+// not exist (or the token can no longer see it).
+func repoNotFound(output string) bool {
+	// "remote: Repository not found." or "fatal: repository '...' not found"
+	return strings.Contains(output, "not found")
+}
+
+// resolveMovedRepo looks up a repository whose clone failed as not found.
+// The API follows the 301 GitHub serves for transferred and renamed
+// repositories, so the result is its current full name, which differs from
+// fullName if it moved. It returns errSourceRemoved if the repository is
+// gone.
+func resolveMovedRepo(ctx context.Context, fullName, token string) (string, error) {
+	repo, err := getGitHubRepo(ctx, fullName, token)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return "", errSourceRemoved
+	}
+	if err != nil {
+		return "", err
+	}
+	return repo.FullName, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// "Sign in with GitHub" gets a token through the OAuth device flow rather
+// than a pasted PAT, which many enterprises no longer allow: the user
+// enters a short code at github.com/login/device, approves the OAuth app,
+// and the tool polls until GitHub hands out the token. The token is an
+// OAuth app token, subject to the organization's OAuth app policy, and
+// lives no longer than the user's authorization of the app.
+
+// gitHubClientID is the client ID of the OAuth app, with device flow
+// enabled, that signs users in; set at build time with
+// -ldflags "-X main.gitHubClientID=...". GITUI_GITHUB_CLIENT_ID overrides
+// it, for an organization's own app.
+var gitHubClientID = ""
+
+// gitHubDeviceScopes are the scopes asked for: private repositories, the
+// organizations the user belongs to, and webhooks to migrate.
+const gitHubDeviceScopes = "repo read:org read:repo_hook"
+
+// deviceClientID returns the OAuth app client ID, or "" if there is none.
+func deviceClientID() string {
+	if id := os.Getenv("GITUI_GITHUB_CLIENT_ID"); id != "" {
+		return id
+	}
+	return gitHubClientID
+}
+
+// deviceCode is the answer to a device flow request, from GitHub or
+// Microsoft Entra ID.
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"` // seconds
+	Interval        int    `json:"interval"`   // seconds between polls
+}
+
+// oauthToken is a token granted at the end of a device flow, or by a
+// refresh.
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"` // Entra ID only
+	ExpiresIn    int    `json:"expires_in"`    // seconds; 0 if it does not expire
+}
+
+// postOAuthForm posts form to service's OAuth endpoint and decodes the JSON
+// answer into out. Errors the endpoint reports in the body, such as a
+// pending authorization, are decoded like answers: GitHub sends them as
+// 200s, Entra ID as 400s.
+func postOAuthForm(ctx context.Context, service, endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return newAPIError(service, resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// requestDeviceCode starts the device flow for clientID.
+func requestDeviceCode(ctx context.Context, clientID string) (*deviceCode, error) {
+	var result struct {
+		deviceCode
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	form := url.Values{"client_id": {clientID}, "scope": {gitHubDeviceScopes}}
+	if err := postOAuthForm(ctx, "GitHub", "https://github.com/login/device/code", form, &result); err != nil {
+		return nil, err
+	}
+	// Errors come back as 200s with an error field.
+	if result.Error != "" {
+		return nil, fmt.Errorf("%s: %s", result.Error, result.Description)
+	}
+	return &result.deviceCode, nil
+}
+
+// pollDeviceToken polls service's tokenURL until the user approves code,
+// and returns the token. It stops when ctx is done, the code expires, or
+// the user denies access.
+func pollDeviceToken(ctx context.Context, service, tokenURL, clientID string, code *deviceCode) (*oauthToken, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	form := url.Values{
+		"client_id":   {clientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if code.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, fmt.Errorf("the code %s expired before it was entered", code.UserCode)
+		}
+		var result struct {
+			oauthToken
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+			Interval    int    `json:"interval"`
+		}
+		if err := postOAuthForm(ctx, service, tokenURL, form, &result); err != nil {
+			return nil, err
+		}
+		switch result.Error {
+		case "":
+			return &result.oauthToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			// GitHub sends the interval to keep to from now on.
+			if result.Interval > 0 {
+				interval = time.Duration(result.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		case "expired_token":
+			return nil, fmt.Errorf("the code %s expired before it was entered", code.UserCode)
+		case "access_denied", "authorization_declined":
+			return nil, errors.New("access was denied")
+		default:
+			return nil, fmt.Errorf("%s: %s", result.Error, result.Description)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// personalRepos is the source choice for the token user's own repositories
+// rather than an organization's.
+const personalRepos = "Personal repositories"
+
+// gitHubIdentity is who a GitHub token belongs to and which organizations
+// it can see.
+type gitHubIdentity struct {
+	Login string
+	Orgs  []string
+}
+
+// getGitHubIdentity looks up the token's user and the organizations it can
+// see. Organizations enforcing SAML SSO are missing until the token is
+// authorized for them.
+func getGitHubIdentity(ctx context.Context, token string) (*gitHubIdentity, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, err := gitHubGet(ctx, "https://api.github.com/user", token, &user); err != nil {
+		return nil, fmt.Errorf("looking up the token's user: %v", err)
+	}
+	id := &gitHubIdentity{Login: user.Login}
+
+	apiURL := "https://api.github.com/user/orgs?per_page=100"
+	for apiURL != "" {
+		var page []struct {
+			Login string `json:"login"`
+		}
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return id, fmt.Errorf("listing the token's organizations: %v", err)
+		}
+		for _, o := range page {
+			id.Orgs = append(id.Orgs, o.Login)
+		}
+		apiURL = next
+	}
+	sort.Slice(id.Orgs, func(i, j int) bool { return strings.ToLower(id.Orgs[i]) < strings.ToLower(id.Orgs[j]) })
+	return id, nil
+}
+
+// Options returns the source choices: personalRepos, then the
+// organizations.
+func (id *gitHubIdentity) Options() []string {
+	return append([]string{personalRepos}, id.Orgs...)
+}
+
+// sourceOrg returns the organization a source choice names, "" for the
+// token user's own repositories.
+func sourceOrg(choice string) string {
+	choice = strings.TrimSpace(choice)
+	if choice == personalRepos {
+		return ""
+	}
+	return choice
+}
+
+// orgWarning returns why listing org with the token will likely come back
+// empty, or "" if the token can see it.
+func (id *gitHubIdentity) orgWarning(org string) string {
+	if id == nil || org == "" {
+		return ""
+	}
+	if strings.EqualFold(org, id.Login) {
+		return fmt.Sprintf("%s is the token's own user account, not an organization; choose %q to list its repositories.", org, personalRepos)
+	}
+	for _, o := range id.Orgs {
+		if strings.EqualFold(o, org) {
+			return ""
+		}
+	}
+	visible := "none"
+	if len(id.Orgs) > 0 {
+		visible = strings.Join(id.Orgs, ", ")
+	}
+	return fmt.Sprintf("The token of %s cannot see the organization %s (visible: %s). Check the spelling, or authorize the token for the organization's SSO; otherwise the repository list will be empty.",
+		id.Login, org, visible)
+}
+
+// ownerWarning returns why a run listing org ("" for the token user's own
+// repositories) looks like it uses the wrong token, when the profile
+// expects the repositories of expected, a GitHub login or organization.
+// It returns "" if expected is empty, or is the token's user or the owner
+// of the repositories listed.
+func (id *gitHubIdentity) ownerWarning(expected, org string) string {
+	expected = strings.TrimSpace(expected)
+	if expected == "" || strings.EqualFold(expected, id.Login) {
+		return ""
+	}
+	owner := org
+	if owner == "" {
+		owner = id.Login
+	}
+	if strings.EqualFold(expected, owner) {
+		return ""
+	}
+	warning := fmt.Sprintf("The GitHub token belongs to %s and would migrate the repositories of %s, but this profile expects %s.", id.Login, owner, expected)
+	if org == "" {
+		warning += " Personal repositories include forks, not the organization's repositories."
+	}
+	return warning
+}
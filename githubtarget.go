@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// gitHubTarget migrates repositories into a GitHub organization, or into
+// the token user's account if org is empty: the reverse of the usual
+// direction, for teams moving off Azure DevOps.
+type gitHubTarget struct {
+	org   string
+	token string
+
+	mu    sync.Mutex
+	owner string // org, or the token's user once looked up
+}
+
+func init() {
+	registerTarget(&targetFactory{
+		Name: "GitHub",
+		Fields: []providerField{
+			{Key: "token", Label: "GitHub PAT", Secret: true},
+			{Key: "org", Label: "GitHub Org", PlaceHolder: "Organization; empty for your own account", Optional: true},
+		},
+		New: func(cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+			return &gitHubTarget{org: strings.TrimSpace(cfg["org"]), token: cfg["token"]}, nil
+		},
+	})
+}
+
+// gitHubRequest sends an authenticated request to the GitHub API and
+// decodes the JSON response into out (if non-nil).
+func gitHubRequest(ctx context.Context, method, apiURL, token string, payload interface{}, wantStatus int, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		jsonPayload, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		apiErr := newAPIError("GitHub", resp)
+		apiErr.RateLimitReset = gitHubRateLimitReset(resp)
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func (t *gitHubTarget) Name() string { return "GitHub" }
+
+// repoOwner returns the owner repositories are created under.
+func (t *gitHubTarget) repoOwner(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.owner != "" {
+		return t.owner, nil
+	}
+	if t.org != "" {
+		t.owner = t.org
+		return t.owner, nil
+	}
+	id, err := getGitHubIdentity(ctx, t.token)
+	if id == nil {
+		return "", err
+	}
+	t.owner = id.Login
+	return t.owner, nil
+}
+
+// gitHubNameChars matches what GitHub replaces with a hyphen in a new
+// repository's name.
+var gitHubNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// gitHubName returns the name GitHub gives a repository created as name:
+// Azure DevOps allows spaces and other characters GitHub does not.
+func gitHubName(name string) string {
+	return gitHubNameChars.ReplaceAllString(name, "-")
+}
+
+// repoURL returns the API URL of repository name.
+func (t *gitHubTarget) repoURL(ctx context.Context, name string) (string, error) {
+	owner, err := t.repoOwner(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s", url.PathEscape(owner), url.PathEscape(gitHubName(name))), nil
+}
+
+// CreateRepo creates a private repository, or reuses one that already
+// exists, as the Gitea target does, and returns its clone URL with the
+// token.
+func (t *gitHubTarget) CreateRepo(ctx context.Context, name string) (string, error) {
+	repoURL, err := t.repoURL(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	var repo gitHubRepo
+	err = gitHubRequest(ctx, "GET", repoURL, t.token, nil, http.StatusOK, &repo)
+	if apiErr, ok := err.(*apiError); ok && apiErr.StatusCode == http.StatusNotFound {
+		createURL := "https://api.github.com/user/repos"
+		if t.org != "" {
+			createURL = fmt.Sprintf("https://api.github.com/orgs/%s/repos", url.PathEscape(t.org))
+		}
+		payload := map[string]interface{}{
+			"name":    gitHubName(name),
+			"private": true,
+		}
+		err = gitHubRequest(ctx, "POST", createURL, t.token, payload, http.StatusCreated, &repo)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://%s@github.com/%s.git", t.token, repo.FullName), nil
+}
+
+func (t *gitHubTarget) SetDefaultBranch(ctx context.Context, name, branch string) error {
+	repoURL, err := t.repoURL(ctx, name)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"default_branch": strings.TrimPrefix(branch, "refs/heads/"),
+	}
+	return gitHubRequest(ctx, "PATCH", repoURL, t.token, payload, http.StatusOK, nil)
+}
+
+// MakeReadOnly archives the repository, which keeps it browsable, for any
+// mode other than archiveLeaveWritable.
+func (t *gitHubTarget) MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error) {
+	if mode == archiveLeaveWritable {
+		return "left writable", nil
+	}
+	repoURL, err := t.repoURL(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	payload := map[string]interface{}{
+		"archived": true,
+	}
+	if err := gitHubRequest(ctx, "PATCH", repoURL, t.token, payload, http.StatusOK, nil); err != nil {
+		return "", err
+	}
+	return "archived", nil
+}
+
+// gitHubRepoNames returns the names of owner's repositories among repos by
+// lower-cased name, like targetRepoNames does for Azure DevOps.
+func gitHubRepoNames(repos []gitHubRepo, owner string) map[string]string {
+	names := map[string]string{}
+	for _, r := range repos {
+		if strings.EqualFold(r.FullName, owner+"/"+r.Name) {
+			names[strings.ToLower(r.Name)] = r.Name
+		}
+	}
+	return names
+}
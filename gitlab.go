@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gitLabSource lists and clones the projects of a GitLab group, subgroups
+// included, or those the token's user is a member of if group is empty.
+// It works against gitlab.com and self-managed instances alike.
+type gitLabSource struct {
+	baseURL string // e.g. https://gitlab.com
+	group   string // full path, e.g. "platform/backend"
+	token   string
+}
+
+// defaultGitLabURL is the GitLab instance used when none is given.
+const defaultGitLabURL = "https://gitlab.com"
+
+func init() {
+	registerSource(&sourceFactory{
+		Name: "GitLab",
+		Fields: []providerField{
+			{Key: "token", Label: "GitLab PAT", Secret: true},
+			{Key: "url", Label: "GitLab URL", PlaceHolder: "GitLab URL (default " + defaultGitLabURL + ")", Optional: true},
+			{Key: "group", Label: "GitLab Group", PlaceHolder: "Group path; empty for your own projects", Optional: true},
+		},
+		New: func(cfg map[string]string) (sourceProvider, error) {
+			base := strings.TrimSuffix(strings.TrimSpace(orDefault(cfg["url"], defaultGitLabURL)), "/")
+			if u, err := url.Parse(base); err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("GitLab URL %q is not a URL", cfg["url"])
+			}
+			return &gitLabSource{baseURL: base, group: strings.Trim(strings.TrimSpace(cfg["group"]), "/"), token: cfg["token"]}, nil
+		},
+	})
+}
+
+// gitLabProject is the subset of the GitLab project API object the
+// migration uses.
+type gitLabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+	Archived          bool   `json:"archived"`
+	Visibility        string `json:"visibility"` // public, internal or private
+	Statistics        *struct {
+		RepositorySize int64 `json:"repository_size"` // bytes
+	} `json:"statistics"`
+}
+
+// get sends an authenticated GET to the GitLab API, decodes the JSON
+// response into out, and returns the next page's number, "" on the last.
+func (s *gitLabSource) get(ctx context.Context, apiPath string, out interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.baseURL+"/api/v4"+apiPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("PRIVATE-TOKEN", s.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("GitLab", resp)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("X-Next-Page"), json.Unmarshal(body, out)
+}
+
+func (s *gitLabSource) Name() string { return "GitLab" }
+
+// ListRepos lists the group's projects, or the user's, as their full
+// paths, following the pages to the end. Archived projects are listed;
+// they are made read-only on the target like GitHub's.
+func (s *gitLabSource) ListRepos(ctx context.Context) ([]string, error) {
+	apiPath := "/projects?membership=true&simple=true&per_page=100"
+	if s.group != "" {
+		apiPath = fmt.Sprintf("/groups/%s/projects?include_subgroups=true&simple=true&per_page=100", url.PathEscape(s.group))
+	}
+	var names []string
+	for page := "1"; page != ""; {
+		var projects []gitLabProject
+		next, err := s.get(ctx, apiPath+"&page="+page, &projects)
+		if err != nil {
+			return names, err
+		}
+		for _, p := range projects {
+			names = append(names, p.PathWithNamespace)
+		}
+		page = next
+	}
+	return names, nil
+}
+
+// CloneURL returns the project's clone URL with the token as the oauth2
+// user's password, which GitLab accepts for any PAT.
+func (s *gitLabSource) CloneURL(ctx context.Context, repo string) string {
+	u, _ := url.Parse(s.baseURL)
+	u.User = url.UserPassword("oauth2", s.token)
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + repo + ".git"
+	return u.String()
+}
+
+// RepoMetadata describes the project the way the migration describes a
+// GitHub repository: default branch, archived flag, visibility and size.
+// The size is only known to tokens that can read the project's
+// statistics; it is 0 otherwise.
+func (s *gitLabSource) RepoMetadata(ctx context.Context, repo string) (*gitHubRepo, error) {
+	var p gitLabProject
+	if _, err := s.get(ctx, "/projects/"+url.PathEscape(repo)+"?statistics=true", &p); err != nil {
+		return nil, err
+	}
+	meta := &gitHubRepo{
+		FullName:      p.PathWithNamespace,
+		Name:          repoShortName(p.PathWithNamespace),
+		DefaultBranch: p.DefaultBranch,
+		Archived:      p.Archived,
+		Visibility:    p.Visibility,
+	}
+	if p.Statistics != nil {
+		meta.Size = int((p.Statistics.RepositorySize + 1023) / 1024)
+	}
+	return meta, nil
+}
+
+// User returns the username of the token's user.
+func (s *gitLabSource) User(ctx context.Context) (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if _, err := s.get(ctx, "/user", &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// Host returns the instance's host, which clones from it are limited by.
+func (s *gitLabSource) Host() string {
+	u, _ := url.Parse(s.baseURL)
+	return u.Host
+}
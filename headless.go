@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headlessResult is the --json output for one repository.
+type headlessResult struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// headlessRepos resolves the --repos list: "all" is every repository the
+// source lists, anything else names repositories, with owner as the owner
+// of those given without one.
+func headlessRepos(ctx context.Context, list, owner string, from sourceProvider) ([]string, error) {
+	if strings.TrimSpace(list) == "all" {
+		return from.ListRepos(ctx)
+	}
+	var repos []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !strings.Contains(name, "/") {
+			name = owner + "/" + name
+		}
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			repos = append(repos, name)
+		}
+	}
+	return repos, nil
+}
+
+// headlessSecrets parses the --secrets policy. There is nobody to ask, so
+// secretsConfirm is not offered.
+func headlessSecrets(text string) (secretPolicy, error) {
+	switch text {
+	case "report":
+		return secretsReportOnly, nil
+	case "block":
+		return secretsBlock, nil
+	}
+	return 0, fmt.Errorf("secrets: unknown policy %q (want report or block)", text)
+}
+
+// runHeadless implements "gitui --headless": it migrates repositories the
+// way the window does, without opening one, for CI runners that have no
+// display, or with --reverse, from Azure DevOps to GitHub. Tokens come from
+// GITHUB_PAT (or GITLAB_PAT, migrating from GitLab) and ADO_PAT, or for
+// Azure DevOps from signing in with Microsoft; --github-pat and --ado-pat
+// override the variables where a CI system cannot set them, at the cost of
+// showing in process listings. It returns the process exit code.
+func runHeadless(args []string) int {
+	fs := flag.NewFlagSet("--headless", flag.ContinueOnError)
+	sourceFlag := fs.String("source", "github", "where to migrate from: github, or gitlab with GITLAB_PAT")
+	githubOrg := fs.String("github-org", "", "GitHub organization to migrate from; empty for the token user's own repositories")
+	visibilityFlag := fs.String("visibility", "all", "with --repos all from GitHub, only the repositories of this visibility: "+strings.Join(repoVisibilities, ", "))
+	reverse := fs.Bool("reverse", false, "migrate the other way: from the Azure DevOps project into --github-org, or the token user's account if it is empty")
+	gitlabURL := fs.String("gitlab-url", defaultGitLabURL, "GitLab instance to migrate from, with --source gitlab")
+	gitlabGroup := fs.String("gitlab-group", "", "GitLab group to migrate from, subgroups included, with --source gitlab; empty for the token user's projects")
+	adoOrg := fs.String("ado-org", "", "Azure DevOps organization URL, e.g. https://dev.azure.com/myorg")
+	adoProject := fs.String("ado-project", "", "Azure DevOps project to migrate into")
+	githubPat := fs.String("github-pat", "", "GitHub token, instead of GITHUB_PAT; the variable is safer, as flags show in process listings")
+	adoPat := fs.String("ado-pat", "", "Azure DevOps PAT, instead of ADO_PAT; the variable is safer, as flags show in process listings")
+	adoSignIn := fs.Bool("ado-sign-in", false, "instead of ADO_PAT, sign in to Azure DevOps with Microsoft Entra ID: the log says where to enter a code, and the token is refreshed for as long as the run takes")
+	reposFlag := fs.String("repos", "", `comma-separated repositories ("name" or "owner/name"), or "all" for every repository the token lists`)
+	retryFailed := fs.Bool("retry-failed", false, "instead of --repos, migrate again the repositories whose last outcome in "+migrationStatePath+" was a failure, under the names they had")
+	resume := fs.Bool("resume", false, "like --retry-failed, but also migrate the repositories an earlier run left unfinished (pending, cloned, pushed or cancelled)")
+	copiesFlag := fs.String("local-copies", "archive", "local copies of verified repositories: discard, run (keep in the run directory) or archive (keep in --copies-dir)")
+	copiesDir := fs.String("copies-dir", defaultCopiesDir, "archive directory of --local-copies archive")
+	deleteAfter := fs.Bool("delete-after", false, "same as --local-copies discard")
+	jsonFlag := fs.Bool("json", false, "print one JSON object per repository (name, status, duration_seconds, error) to stdout; the log goes to stderr")
+	attemptsFlag := fs.String("attempts", "", fmt.Sprintf("times a clone, push or repository creation that fails transiently is tried, 1 to %d (default %d, or GITUI_ATTEMPTS)", maxAttempts, defaultAttempts))
+	backoffFlag := fs.String("retry-backoff", "", fmt.Sprintf("wait between attempts, doubling from DELAY up to MAX, made up to JITTER%% shorter or longer: DELAY[-MAX] [JITTER%%] (default %q, or GITUI_RETRY_BACKOFF)",
+		retryPolicy{Delay: defaultRetryDelay, MaxDelay: maxRetryDelay, Jitter: defaultRetryJitter}.String()))
+	chunkSizeFlag := fs.String("chunk-size", "", fmt.Sprintf("push branches in chunks of this many commits, tuned on failures, up to %d (default 0, whole, or GITUI_CHUNK_SIZE); sizes learned are not kept", maxChunkSize))
+	fixedChunkSize := fs.Bool("fixed-chunk-size", false, "never tune the chunk size")
+	concurrencyFlag := fs.String("concurrency", "", fmt.Sprintf("repositories migrated at once, 1 to %d (default %d, or GITUI_CONCURRENCY)", maxConcurrency, defaultConcurrency))
+	lfsFlag := fs.String("lfs", "fail", "missing LFS objects: fail or continue")
+	skipLFS := fs.Bool("skip-lfs", false, "push repositories that use Git LFS with only their pointer files, leaving the LFS objects out (warned about)")
+	archivedFlag := fs.String("archived", "disable", "archived repositories: disable, deny-push or leave")
+	secretsFlag := fs.String("secrets", "report", "secrets in history: report, or block the repository")
+	collisionsFlag := fs.String("collisions", "", fmt.Sprintf("how colliding target names are resolved, strategies tried in order (default %q)", defaultCollisionPolicy.String()))
+	licensePolicyFlag := fs.String("license-policy", "", "license policy file (YAML: allow, deny, review, other, gate, private_only); under the confirm gate flagged repositories are skipped, there being nobody to ask")
+	dryRun := fs.Bool("dry-run", false, "log what would be done with each repository, and which target names are taken, without creating, cloning or pushing anything")
+	tag := fs.String("tag", "", "tag recorded with the run")
+	note := fs.String("note", "", "operator note recorded with the run (see gitui note)")
+	strict := fs.Bool("strict", false, "exit with the warnings code if anything is not clean")
+	setUsage(fs, "--headless --ado-org URL --ado-project NAME --repos LIST|all|--retry-failed|--resume [flags]")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	cfg := &migrationConfig{LFS: *lfsFlag, Archived: *archivedFlag}
+	lfs, err := cfg.lfsPolicy()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	archive, err := cfg.archiveMode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	secrets, err := headlessSecrets(*secretsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	concurrency, err := parseConcurrency(*concurrencyFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	collisions, err := parseCollisionPolicy(*collisionsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	attempts, err := parseAttempts(*attemptsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	retry, err := parseBackoff(*backoffFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	retry.Attempts = attempts
+	chunkSize, err := parseChunkSize(*chunkSizeFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	copies := localCopies{Dir: *copiesDir}
+	if copies.Mode, err = parseCopyMode(*copiesFlag); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	if *deleteAfter {
+		copies.Mode = copiesDiscard
+	}
+	var licenses *licensePolicy
+	if *licensePolicyFlag != "" {
+		if licenses, err = loadLicensePolicy(*licensePolicyFlag); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+	}
+	given := 0
+	for _, set := range []bool{strings.TrimSpace(*reposFlag) != "", *retryFailed, *resume} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		fmt.Fprintln(os.Stderr, `Error: one of --repos (repository names, or "all"), --retry-failed or --resume is required`)
+		return exitRunError
+	}
+	tokenVar := "GITHUB_PAT"
+	switch *sourceFlag {
+	case "github":
+	case "gitlab":
+		tokenVar = "GITLAB_PAT"
+	default:
+		fmt.Fprintf(os.Stderr, "Error: source: unknown source %q (want github or gitlab)\n", *sourceFlag)
+		return exitRunError
+	}
+	if *reverse && *sourceFlag != "github" {
+		fmt.Fprintln(os.Stderr, "Error: --reverse migrates from Azure DevOps to GitHub; it takes no --source")
+		return exitRunError
+	}
+	sourceToken, adoToken := os.Getenv(tokenVar), os.Getenv("ADO_PAT")
+	tokenSource := tokenVar
+	if *sourceFlag == "github" {
+		tokenSource += " (or --github-pat)"
+		if *githubPat != "" {
+			sourceToken = *githubPat
+		}
+	}
+	if *adoPat != "" {
+		adoToken = *adoPat
+	}
+	registerSecret(sourceToken)
+	registerSecret(adoToken)
+	if sourceToken == "" || (adoToken == "" && !*adoSignIn) {
+		fmt.Fprintf(os.Stderr, "Error: %s and ADO_PAT (or --ado-pat or --ado-sign-in) must be set\n", tokenSource)
+		return exitRunError
+	}
+
+	// The log has the window's timestamps. With --json, stdout is left to
+	// the results.
+	var out io.Writer = os.Stdout
+	if *jsonFlag {
+		out = os.Stderr
+	}
+	var outMu sync.Mutex
+	clock := &logClock{}
+	logMsg := func(msg string) {
+		outMu.Lock()
+		defer outMu.Unlock()
+		fmt.Fprintf(out, "[%s] %s\n", clock.Stamp(time.Now()), redactLog(msg))
+	}
+	setAPIRetry(retry, logMsg)
+	setGitHubRateLimitNotify(func(resource string, until time.Time) {
+		logMsg(gitHubRateLimitMessage(resource, until))
+	})
+	results := json.NewEncoder(os.Stdout)
+
+	// An interrupt cancels the run: git commands in flight are killed and
+	// the queued repositories recorded as cancelled.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *adoSignIn {
+		if adoToken, err = signInWithMicrosoftHeadless(ctx, logMsg); err != nil {
+			logMsg(fmt.Sprintf("Error: %v", err))
+			return exitRunError
+		}
+	}
+
+	runStart := time.Now()
+	logMsg("Starting migration...")
+	logMsg("Run timestamps: " + zoneSummary(runStart))
+	logMsg(transfers.Describe())
+	chunks := newChunkTuner(chunkSize, *fixedChunkSize, nil)
+	logMsg(chunks.Describe())
+	logMsg("Local copies: " + copies.String() + ".")
+	migrateLegacyCopies(ctx, copies, logMsg)
+
+	// The source and whose repositories it lists. Nothing about GitLab
+	// is checked that GitLab has no API for.
+	var from sourceProvider
+	var login, owner, gitHubToken string
+	if *sourceFlag == "gitlab" {
+		from, err = newSource("GitLab", map[string]string{"token": sourceToken, "url": *gitlabURL, "group": *gitlabGroup})
+		if err != nil {
+			logMsg(fmt.Sprintf("Error: %v", err))
+			return exitRunError
+		}
+		if login, err = from.(*gitLabSource).User(ctx); err != nil {
+			logMsg(fmt.Sprintf("Error: could not look up the GitLab token's user: %v", err))
+			return exitRunError
+		}
+		logMsg("GitLab identity: " + login)
+		owner = orDefault(strings.Trim(strings.TrimSpace(*gitlabGroup), "/"), login)
+	} else {
+		id, err := getGitHubIdentity(ctx, sourceToken)
+		if id == nil {
+			logMsg(fmt.Sprintf("Error: could not look up the GitHub token's user: %v", err))
+			return exitRunError
+		}
+		login = id.Login
+		logMsg("GitHub identity: " + login)
+		org := strings.TrimSpace(*githubOrg)
+		if *reverse {
+			// The project's repositories, named project/name.
+			from, err = newSource("Azure DevOps", map[string]string{"token": adoToken, "org": *adoOrg, "project": *adoProject})
+			owner = strings.TrimSpace(*adoProject)
+		} else {
+			from, err = newSource("GitHub", map[string]string{"token": sourceToken, "org": org, "visibility": *visibilityFlag})
+			owner, gitHubToken = orDefault(org, login), sourceToken
+		}
+		if err != nil {
+			logMsg(fmt.Sprintf("Error: %v", err))
+			return exitRunError
+		}
+	}
+
+	var target targetProvider
+	if *reverse {
+		target, err = newTarget("GitHub", map[string]string{"token": sourceToken, "org": *githubOrg}, logMsg)
+	} else {
+		target, err = newTarget("Azure DevOps", map[string]string{"token": adoToken, "org": *adoOrg, "project": *adoProject}, logMsg)
+	}
+	if err != nil {
+		logMsg(fmt.Sprintf("Error: %v", err))
+		return exitRunError
+	}
+	az, _ := target.(*azureTarget)
+	if az != nil {
+		if sc, err := getProjectSourceControl(ctx, az.org, az.project, az.token); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not check the version control of %s: %v", az.project, err))
+		} else if err := sc.check(az.project); err != nil {
+			logMsg(fmt.Sprintf("Error: %v", err))
+			return exitRunError
+		} else {
+			logMsg(fmt.Sprintf("Azure DevOps project %s: %s.", az.project, sc))
+		}
+		// There is no read-only mode to fall back to.
+		if err := probeAzureWrite(ctx, az.org, az.project, az.token); err != nil && *dryRun {
+			logMsg(fmt.Sprintf("Warning: the Azure token cannot write, a real run would stop here: %v", err))
+		} else if err != nil {
+			logMsg(fmt.Sprintf("Error: the Azure token cannot write: %v", err))
+			return exitRunError
+		}
+	}
+
+	// The three identities go in the log, the report and every audit
+	// record. Generated commits use the default bot identity. In a
+	// reverse run the Azure DevOps token's user reads the source.
+	adoUser := az
+	if src, ok := from.(*azureSource); ok {
+		adoUser = &azureTarget{org: src.org, project: src.project, token: src.token}
+	}
+	ids, adoID := resolveIdentities(ctx, login, adoUser, nil, logMsg)
+	if *sourceFlag == "gitlab" {
+		ids.Source = from.Name()
+	}
+	for _, line := range ids.lines() {
+		logMsg("Identity: " + line)
+	}
+	if az == nil {
+		// The target's permissions are GitHub's; creating the first
+		// repository tries them.
+	} else if warning := azurePermissionWarning(ctx, az.org, az.project, az.token, adoID); warning != "" {
+		logMsg("Warning: " + warning)
+	}
+	setAuditIdentities(ids)
+	defer setAuditIdentities(runIdentities{})
+
+	privateProject := true
+	if licenses != nil {
+		// Repositories created on GitHub are private.
+		if licenses.PrivateOnly && az != nil {
+			if privateProject, err = azureProjectPrivate(ctx, az.org, az.project, az.token); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not read the visibility of %s, taking it to be private: %v", az.project, err))
+			}
+		}
+		logMsg("License policy: " + licenses.String() + ".")
+	}
+
+	// Retried repositories keep the names they were given, which an
+	// earlier attempt may have created.
+	var repos []string
+	kept := map[string]string{}
+	if *retryFailed || *resume {
+		state, err := loadMigrationState()
+		if err != nil {
+			logMsg(fmt.Sprintf("Error reading %s: %v", migrationStatePath, err))
+			return exitRunError
+		}
+		retry := state.Failed()
+		if *resume {
+			retry = state.Resumable()
+		}
+		for _, s := range retry {
+			repos = append(repos, s.Source)
+			if s.Target != "" {
+				kept[s.Source] = s.Target
+			}
+			if s.Status != statusFailed {
+				logMsg(fmt.Sprintf("Resuming %s, left %s by run %s.", s.Source, s.Status, s.Run))
+			}
+		}
+		if *resume {
+			logMsg(fmt.Sprintf("Resuming %d repositories that failed or were left unfinished in their last run.", len(repos)))
+		} else {
+			logMsg(fmt.Sprintf("Retrying %d repositories that failed in their last run.", len(repos)))
+		}
+	} else if repos, err = headlessRepos(ctx, *reposFlag, owner, from); err != nil {
+		logMsg(fmt.Sprintf("Error fetching repositories: %v", err))
+		return exitRunError
+	}
+	if len(repos) == 0 && *retryFailed {
+		logMsg("No repository failed in its last run; nothing to retry.")
+		return exitOK
+	}
+	if len(repos) == 0 && *resume {
+		logMsg("No repository failed or was left unfinished in its last run; nothing to resume.")
+		return exitOK
+	}
+	if len(repos) == 0 {
+		logMsg("Error: no repositories to migrate.")
+		return exitRunError
+	}
+
+	doc := &migrationDoc{}
+	report := newRunReport(runStart, strings.TrimSpace(*tag), target.Name())
+	report.Note = strings.TrimSpace(*note)
+	if *sourceFlag == "github" {
+		report.GitHubLogin = login
+	}
+	report.Identities = &ids
+	if !*dryRun {
+		if err := writeAudit("run-start", target.Name(), "run "+report.ID); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not write the audit log: %v", err))
+		}
+	}
+	if az != nil {
+		az.runID = report.ID
+	}
+
+	var existing map[string]string
+	if *dryRun {
+		logMsg("Dry run: nothing will be created, cloned or pushed.")
+		if az == nil {
+			owner := orDefault(strings.TrimSpace(*githubOrg), login)
+			if list, err := listGitHubRepos(ctx, strings.TrimSpace(*githubOrg), sourceToken); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not list the repositories of %s, collisions with them are not checked: %v", owner, err))
+			} else {
+				existing = gitHubRepoNames(list, owner)
+			}
+		} else if list, err := listAzureRepos(ctx, az.org, az.project, az.token); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not list the repositories of %s, collisions with them are not checked: %v", az.project, err))
+		} else {
+			existing = targetRepoNames(list)
+		}
+	}
+
+	names := newNameAllocator(collisions)
+	targetNameOf, strategyOf, err := targetNames(repos, names, kept)
+	if err != nil {
+		logMsg(fmt.Sprintf("Error: %v", err))
+		return exitRunError
+	}
+	report.CollisionPolicy = collisions.String()
+	if licenses != nil {
+		report.LicensePolicy = licenses.String()
+	}
+	for _, repo := range repos {
+		if s, ok := strategyOf[repo]; ok {
+			logMsg(fmt.Sprintf("Migrating %s as %s (collision strategy %s).", repo, targetNameOf[repo], s))
+		}
+	}
+	wontMigrate, err := wontMigrateDecisions()
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not read earlier run reports: %v", err))
+	}
+	resultOf := map[string]*repoReport{}
+	for _, repo := range repos {
+		result := &repoReport{Source: repo, Target: targetNameOf[repo], NameStrategy: strategyOf[repo]}
+		report.Repos = append(report.Repos, result)
+		resultOf[repo] = result
+	}
+	if !*dryRun {
+		if err := recordPending(report.ID, report.Repos); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not record the run in %s, --resume will not know what it leaves unfinished: %v", migrationStatePath, err))
+		}
+	}
+
+	run := &migrationRun{
+		GitHubToken: gitHubToken,
+		Source:      from,
+		Target:      target,
+		Report:      report,
+		Doc:         doc,
+		Secrets:     secrets,
+		Recycle:     recycleFail,
+		LFS:         lfs,
+		SkipLFS:     *skipLFS,
+		Archive:     archive,
+		Cleanup: cleanupPolicy{
+			Copies:       copies,
+			KeepFailed:   true,
+			FailedExpiry: defaultFailedCloneDays * 24 * time.Hour,
+		},
+		Retry:          retry,
+		Chunks:         chunks,
+		Splits:         &splitPlans{},
+		Areas:          &workItemAreas{},
+		Branches:       &branchLists{},
+		Retained:       &retainedCopies{},
+		Names:          names,
+		WontMigrate:    wontMigrate,
+		DryRun:         *dryRun,
+		Existing:       existing,
+		Licenses:       licenses,
+		PrivateProject: privateProject,
+		// No ConfirmLicense: nobody can confirm a flagged repository.
+	}
+	emit := func(result *repoReport) {
+		if !*jsonFlag {
+			return
+		}
+		outMu.Lock()
+		defer outMu.Unlock()
+		results.Encode(headlessResult{Name: result.Source, Status: result.Status, DurationSeconds: result.DurationSeconds, Error: result.Error})
+	}
+
+	logMsg(fmt.Sprintf("Migrating %d repositories, %d at a time.", len(repos), concurrency))
+	m := &migrator{
+		Migrate: func(ctx context.Context, repo string) {
+			// Git's own progress output is left out of the log.
+			run.Migrate(ctx, repo, resultOf[repo], &logScope{}, io.Discard, logMsg)
+			emit(resultOf[repo])
+		},
+		Cancelled: func(repo string) {
+			resultOf[repo].Status = statusCancelled
+			emit(resultOf[repo])
+		},
+	}
+	m.Run(ctx, repos, concurrency)
+	if ctx.Err() != nil {
+		logMsg("Migration cancelled.")
+	}
+	logMsg(fmt.Sprintf("Migration completed in %s.", formatDuration(time.Since(runStart))))
+
+	// A dry run saves nothing. With --strict, names already taken in the
+	// target fail the pipeline.
+	if *dryRun {
+		for _, line := range dryRunSummary(report) {
+			logMsg(line)
+		}
+		if ctx.Err() != nil {
+			return exitCancelled
+		}
+		return runExitCode(report, *strict)
+	}
+
+	if !doc.Empty() {
+		var wikiURL string
+		if az != nil {
+			if wikiURL, err = projectWikiURL(ctx, az.org, az.project, az.token); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not look up the project wiki: %v", err))
+			}
+		}
+		if path, err := doc.save(report.ID, wikiURL); err != nil {
+			logMsg(fmt.Sprintf("Error saving MIGRATION.md: %v", err))
+		} else {
+			report.MigrationDoc = path
+			logMsg(fmt.Sprintf("MIGRATION.md saved to %s.", path))
+		}
+	}
+	report.Finished = fileTimestamp(time.Now())
+	if path, err := report.save(); err != nil {
+		logMsg(fmt.Sprintf("Error saving run report: %v", err))
+	} else {
+		logMsg(fmt.Sprintf("Run report saved to %s.", path))
+	}
+	writeRunSummary(os.Stderr, report)
+
+	if ctx.Err() != nil {
+		return exitCancelled
+	}
+	return runExitCode(report, *strict)
+}
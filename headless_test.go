@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSource serves repositories from local bare repositories, described
+// like the sources other than GitHub so no GitHub API is asked about them.
+type fakeSource struct {
+	repos map[string]string // "owner/name" to the bare repository
+}
+
+func (s *fakeSource) Name() string { return "Fake Git" }
+
+func (s *fakeSource) ListRepos(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range s.repos {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *fakeSource) CloneURL(ctx context.Context, repo string) string { return s.repos[repo] }
+
+func (s *fakeSource) RepoMetadata(ctx context.Context, repo string) (*gitHubRepo, error) {
+	return &gitHubRepo{DefaultBranch: "main"}, nil
+}
+
+func (s *fakeSource) Host() string { return "fake.example.com" }
+
+// fakeTarget creates repositories as bare repositories under dir. Those
+// named in fail are refused; pushes to those named in warn get a warning
+// from the server, and those named in noTags have their tags rejected;
+// creating one named in block waits for an interrupt.
+type fakeTarget struct {
+	dir                       string
+	fail, warn, noTags, block map[string]bool
+	created, defaulted        []string
+}
+
+func (t *fakeTarget) Name() string { return "Fake Target" }
+
+func (t *fakeTarget) CreateRepo(ctx context.Context, name string) (string, error) {
+	if t.fail[name] {
+		return "", errors.New("the target refused the repository")
+	}
+	if t.block[name] {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		defer signal.Stop(c)
+		p, _ := os.FindProcess(os.Getpid())
+		if err := p.Signal(os.Interrupt); err != nil {
+			return "", err
+		}
+		<-c
+		// Give the run's own handler the moment it needs to cancel.
+		time.Sleep(100 * time.Millisecond)
+		return "", errors.New("interrupted")
+	}
+	path := filepath.Join(t.dir, name+".git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", path).CombinedOutput(); err != nil {
+		return "", errors.New(string(out))
+	}
+	if t.warn[name] {
+		hook := "#!/bin/sh\necho 'warning: the repository is approaching its size limit' >&2\n"
+		if err := os.WriteFile(filepath.Join(path, "hooks", "pre-receive"), []byte(hook), 0o755); err != nil {
+			return "", err
+		}
+	}
+	if t.noTags[name] {
+		hook := "#!/bin/sh\ncase \"$1\" in refs/tags/*) exit 1;; esac\n"
+		if err := os.WriteFile(filepath.Join(path, "hooks", "update"), []byte(hook), 0o755); err != nil {
+			return "", err
+		}
+	}
+	t.created = append(t.created, name)
+	return path, nil
+}
+
+func (t *fakeTarget) SetDefaultBranch(ctx context.Context, name, branch string) error {
+	t.defaulted = append(t.defaulted, name+" "+branch)
+	return nil
+}
+
+func (t *fakeTarget) MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error) {
+	return "left as is", nil
+}
+
+// fakeGitHubIdentity answers the token user lookups headless runs make.
+type fakeGitHubIdentity struct{}
+
+func (fakeGitHubIdentity) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := "[]"
+	if req.URL.Path == "/user" {
+		body = `{"login":"octocat"}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// useFakeProviders makes headless runs migrate from src to target in place
+// of the GitHub source and the Azure DevOps target, in a directory of
+// their own.
+func useFakeProviders(t *testing.T, src *fakeSource, target *fakeTarget) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_AUTHOR_NAME", "t")
+	t.Setenv("GIT_AUTHOR_EMAIL", "t@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "t")
+	t.Setenv("GIT_COMMITTER_EMAIL", "t@example.com")
+	t.Setenv("GITHUB_PAT", "ghp_test")
+	t.Setenv("ADO_PAT", "ado_test")
+
+	source, dest := findSource("GitHub"), findTarget("Azure DevOps")
+	newSource, newTarget, transport := source.New, dest.New, apiClient.Transport
+	source.New = func(map[string]string) (sourceProvider, error) { return src, nil }
+	dest.New = func(map[string]string, func(string)) (targetProvider, error) { return target, nil }
+	apiClient.Transport = fakeGitHubIdentity{}
+	t.Cleanup(func() {
+		source.New, dest.New, apiClient.Transport = newSource, newTarget, transport
+	})
+}
+
+func TestHeadlessRepos(t *testing.T) {
+	got, err := headlessRepos(t.Context(), " api, acme/web,,API ,other/api", "acme", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"acme/api", "acme/web", "other/api"}; !slices.Equal(got, want) {
+		t.Errorf("headlessRepos = %q, want %q", got, want)
+	}
+}
+
+// A run given bad flags or no credentials exits before it starts.
+func TestRunHeadlessRefuses(t *testing.T) {
+	t.Setenv("GITHUB_PAT", "ghp_test")
+	t.Setenv("ADO_PAT", "ado_test")
+	base := []string{"--ado-org", "acme", "--ado-project", "p", "--repos", "acme/one"}
+	for _, tt := range []struct {
+		name string
+		args []string
+	}{
+		{"no repos", []string{"--ado-org", "acme", "--ado-project", "p"}},
+		{"bad lfs", append(base, "--lfs", "maybe")},
+		{"bad archived", append(base, "--archived", "delete")},
+		{"bad secrets", append(base, "--secrets", "confirm")},
+		{"bad concurrency", append(base, "--concurrency", "0")},
+		{"no such flag", append(base, "--no-such-flag")},
+	} {
+		if got := runHeadless(tt.args); got != exitRunError {
+			t.Errorf("%s: runHeadless = %d, want %d", tt.name, got, exitRunError)
+		}
+	}
+	t.Setenv("ADO_PAT", "")
+	if got := runHeadless(base); got != exitRunError {
+		t.Errorf("runHeadless without ADO_PAT = %d, want %d", got, exitRunError)
+	}
+	if got := runHeadless([]string{"--help"}); got != exitOK {
+		t.Errorf("runHeadless --help = %d, want %d", got, exitOK)
+	}
+}
+
+func TestRunHeadlessExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fakes use a shell hook and send themselves SIGINT")
+	}
+	bare := testBareRepo(t, "main", "release/2024")
+	tests := []struct {
+		name   string
+		args   []string
+		target fakeTarget
+		want   int
+	}{
+		{"success", nil, fakeTarget{}, exitOK},
+		{"warnings", nil, fakeTarget{warn: map[string]bool{"two": true}}, exitOK},
+		{"strict warnings", []string{"--strict"}, fakeTarget{warn: map[string]bool{"two": true}}, exitWarnings},
+		{"failure", nil, fakeTarget{fail: map[string]bool{"two": true}}, exitPartial},
+		{"bad flag", []string{"--lfs", "maybe"}, fakeTarget{}, exitRunError},
+		{"cancelled", nil, fakeTarget{block: map[string]bool{"one": true}}, exitCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &fakeSource{repos: map[string]string{"acme/one": bare, "acme/two": bare}}
+			target := tt.target
+			target.dir = t.TempDir()
+			useFakeProviders(t, src, &target)
+			args := append([]string{"--ado-org", "https://dev.azure.com/acme", "--ado-project", "p",
+				"--repos", "acme/one,acme/two", "--concurrency", "1", "--attempts", "1", "--local-copies", "discard"}, tt.args...)
+			if got := runHeadless(args); got != tt.want {
+				t.Errorf("runHeadless(%s) = %d, want %d", strings.Join(tt.args, " "), got, tt.want)
+			}
+		})
+	}
+}
+
+// A successful run creates each repository and sets its default branch.
+func TestRunHeadlessMigrates(t *testing.T) {
+	bare := testBareRepo(t, "main", "release/2024")
+	target := &fakeTarget{dir: t.TempDir()}
+	useFakeProviders(t, &fakeSource{repos: map[string]string{"acme/one": bare}}, target)
+	if got := runHeadless([]string{"--ado-org", "acme", "--ado-project", "p", "--repos", "acme/one", "--local-copies", "discard"}); got != exitOK {
+		t.Fatalf("runHeadless = %d, want %d", got, exitOK)
+	}
+	if len(target.created) != 1 || target.created[0] != "one" {
+		t.Errorf("created %v, want [one]", target.created)
+	}
+	if len(target.defaulted) != 1 || target.defaulted[0] != "one main" {
+		t.Errorf("default branches set %v, want [one main]", target.defaulted)
+	}
+	out, err := exec.Command("git", "-C", filepath.Join(target.dir, "one.git"), "for-each-ref", "--format=%(refname)").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(out)); len(got) != 2 || got[1] != "refs/heads/release/2024" {
+		t.Errorf("target refs = %v, want main and release/2024", got)
+	}
+}
+
+// What the server warned of while pushing reaches the migration state,
+// not only the run report.
+func TestRunHeadlessRecordsServerWarnings(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake target uses a shell hook")
+	}
+	bare := testBareRepo(t, "main")
+	target := &fakeTarget{dir: t.TempDir(), warn: map[string]bool{"one": true}}
+	useFakeProviders(t, &fakeSource{repos: map[string]string{"acme/one": bare}}, target)
+	if got := runHeadless([]string{"--ado-org", "acme", "--ado-project", "p", "--repos", "acme/one", "--local-copies", "discard"}); got != exitOK {
+		t.Fatalf("runHeadless = %d, want %d", got, exitOK)
+	}
+	state, err := loadMigrationState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := state["acme/one"]
+	if s == nil || len(s.ServerWarnings) != 1 || !strings.Contains(s.ServerWarnings[0], "approaching its size limit") {
+		t.Errorf("migration state of acme/one = %+v, want the server's warning", s)
+	}
+}
+
+// Tags that did not push leave the repository migrated with warnings even
+// when its local copy is deleted.
+func TestRunHeadlessKeepsWarningsOnCleanup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake target uses a shell hook")
+	}
+	bare := testBareRepo(t, "main")
+	if out, err := exec.Command("git", "-C", bare, "tag", "v1", "main").CombinedOutput(); err != nil {
+		t.Fatalf("tagging: %v\n%s", err, out)
+	}
+	target := &fakeTarget{dir: t.TempDir(), noTags: map[string]bool{"one": true}}
+	useFakeProviders(t, &fakeSource{repos: map[string]string{"acme/one": bare}}, target)
+	if got := runHeadless([]string{"--ado-org", "acme", "--ado-project", "p", "--repos", "acme/one", "--local-copies", "discard", "--strict"}); got != exitWarnings {
+		t.Errorf("runHeadless --strict = %d, want %d", got, exitWarnings)
+	}
+	state, err := loadMigrationState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := state["acme/one"]; s == nil || s.Status != statusWarnings {
+		t.Errorf("migration state of acme/one = %+v, want status %q", s, statusWarnings)
+	}
+}
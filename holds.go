@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// holdsPath lists the repositories on hold, relative to the working
+// directory. A held repository is not touched by any run or action,
+// whatever a plan, filter or selection says, until its hold is released.
+const holdsPath = "holds.json"
+
+var holdsMu sync.Mutex
+
+// repoHold is a repository on hold.
+type repoHold struct {
+	Repo   string `json:"repo"` // owner/name
+	Reason string `json:"reason"`
+	SetBy  string `json:"set_by"`
+	Since  string `json:"since"`
+}
+
+// String describes the hold for lists.
+func (h *repoHold) String() string {
+	return fmt.Sprintf("on hold: %s (%s, %s)", h.Reason, h.SetBy, h.Since)
+}
+
+// repoHolds are the holds, keyed by lower-case repository name since
+// GitHub names are case-insensitive.
+type repoHolds map[string]*repoHold
+
+// Get returns repo's hold, or nil if it is not held.
+func (hs repoHolds) Get(repo string) *repoHold {
+	return hs[strings.ToLower(repo)]
+}
+
+// Sorted returns the holds ordered by repository.
+func (hs repoHolds) Sorted() []*repoHold {
+	var list []*repoHold
+	for _, h := range hs {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Repo) < strings.ToLower(list[j].Repo) })
+	return list
+}
+
+// loadHolds reads the holds; no file means none.
+func loadHolds() (repoHolds, error) {
+	data, err := readFileRecover(holdsPath, func(data []byte) error {
+		var list []*repoHold
+		return json.Unmarshal(data, &list)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return repoHolds{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*repoHold
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	holds := repoHolds{}
+	for _, h := range list {
+		holds[strings.ToLower(h.Repo)] = h
+	}
+	return holds, nil
+}
+
+// holdOf returns repo's hold, read afresh so a hold placed during a run
+// applies to what the run has not reached yet.
+func holdOf(repo string) (*repoHold, error) {
+	holds, err := loadHolds()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", holdsPath, err)
+	}
+	return holds.Get(repo), nil
+}
+
+func saveHolds(holds repoHolds) error {
+	list := holds.Sorted()
+	if list == nil {
+		list = []*repoHold{}
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(holdsPath, append(data, '\n'), 0644)
+}
+
+// holdUser names whoever places or releases a hold. Holds are managed
+// outside runs, so this is the local account rather than a GitHub login.
+func holdUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// placeHold puts repo on hold for reason, replacing any earlier hold, and
+// records it in the audit log.
+func placeHold(repo, reason string) error {
+	repo, reason = strings.TrimSpace(repo), strings.TrimSpace(reason)
+	if repo == "" || reason == "" {
+		return errors.New("a hold needs a repository and a reason")
+	}
+	holdsMu.Lock()
+	defer holdsMu.Unlock()
+	holds, err := loadHolds()
+	if err != nil {
+		return err
+	}
+	h := &repoHold{Repo: repo, Reason: reason, SetBy: holdUser(), Since: fileTimestamp(time.Now())}
+	holds[strings.ToLower(repo)] = h
+	if err := saveHolds(holds); err != nil {
+		return err
+	}
+	return writeAudit("place-hold", repo, fmt.Sprintf("%s; by %s", reason, h.SetBy))
+}
+
+// releaseHold releases repo's hold and records who released it, and the
+// hold it was, in the audit log.
+func releaseHold(repo string) error {
+	holdsMu.Lock()
+	defer holdsMu.Unlock()
+	holds, err := loadHolds()
+	if err != nil {
+		return err
+	}
+	h := holds.Get(repo)
+	if h == nil {
+		return fmt.Errorf("%s is not on hold", repo)
+	}
+	delete(holds, strings.ToLower(repo))
+	if err := saveHolds(holds); err != nil {
+		return err
+	}
+	return writeAudit("release-hold", h.Repo, fmt.Sprintf("released by %s; held since %s by %s: %s", holdUser(), h.Since, h.SetBy, h.Reason))
+}
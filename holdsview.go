@@ -0,0 +1,92 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showHolds opens the list of repositories on hold, to place holds and to
+// release them. repos are offered when placing one. Releasing asks first;
+// both are recorded in the audit log. onChanged is called after either.
+func showHolds(w fyne.Window, repos []string, logMsg func(string), onChanged func()) {
+	var holds []*repoHold
+	load := func() {
+		hs, err := loadHolds()
+		if err != nil {
+			logMsg(fmt.Sprintf("Error reading the holds: %v", err))
+		}
+		holds = hs.Sorted()
+	}
+	load()
+
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(holds) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Release...", nil), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			h := holds[id]
+			row := o.(*fyne.Container)
+			label := row.Objects[0].(*widget.Label)
+			label.Truncation = fyne.TextTruncateEllipsis
+			label.SetText(h.Repo + " — " + h.String())
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				dialog.ShowConfirm("Release hold",
+					fmt.Sprintf("Release the hold on %s?\n\n%s\n\nRuns and actions will be able to touch it again. The release is recorded in %s.", h.Repo, h.String(), auditLogPath),
+					func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := releaseHold(h.Repo); err != nil {
+							logMsg(fmt.Sprintf("Error releasing the hold on %s: %v", h.Repo, err))
+							return
+						}
+						logMsg(fmt.Sprintf("Released the hold on %s.", h.Repo))
+						load()
+						list.Refresh()
+						onChanged()
+					}, w)
+			}
+		},
+	)
+
+	repoEntry := widget.NewSelectEntry(repos)
+	repoEntry.SetPlaceHolder("owner/repo")
+	reasonEntry := widget.NewEntry()
+	reasonEntry.SetPlaceHolder("Why, e.g. legal hold LH-12")
+	holdBtn := widget.NewButton("Place Hold", func() {
+		repo, reason := strings.TrimSpace(repoEntry.Text), strings.TrimSpace(reasonEntry.Text)
+		if err := placeHold(repo, reason); err != nil {
+			logMsg(fmt.Sprintf("Error placing a hold on %s: %v", repo, err))
+			return
+		}
+		logMsg(fmt.Sprintf("%s is on hold: %s.", repo, reason))
+		repoEntry.SetText("")
+		reasonEntry.SetText("")
+		load()
+		list.Refresh()
+		onChanged()
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("Repository", repoEntry),
+		widget.NewFormItem("Reason", reasonEntry),
+	)
+	content := container.NewBorder(
+		widget.NewLabel("Repositories on hold are not touched by any run or action until released."),
+		container.NewVBox(form, holdBtn),
+		nil, nil,
+		list,
+	)
+	d := dialog.NewCustom("Holds", "Close", content, w)
+	d.Resize(fyne.NewSize(700, 450))
+	d.Show()
+}
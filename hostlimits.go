@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultMaxConnsPerHost caps the API connections open to one host at a
+// time unless GITUI_MAX_CONNS_PER_HOST says otherwise. Corporate firewalls
+// flag bursts of dozens of TLS connections to dev.azure.com.
+const defaultMaxConnsPerHost = 4
+
+// maxConnsPerHost returns the configured per-host API connection limit.
+func maxConnsPerHost() int {
+	if n, err := strconv.Atoi(os.Getenv("GITUI_MAX_CONNS_PER_HOST")); err == nil && n > 0 {
+		return n
+	}
+	return defaultMaxConnsPerHost
+}
+
+// transferGate serializes git transfers (clone, push, LFS) per host in
+// polite mode, so there is at most one transfer to each host at a time
+// while transfers to different hosts still overlap. When polite mode is
+// off it lets everything through.
+type transferGate struct {
+	mu     sync.Mutex
+	polite bool
+	hosts  map[string]*sync.Mutex
+}
+
+// transfers is the gate all git transfers go through.
+var transfers = &transferGate{}
+
+// SetPolite turns polite mode on or off for transfers that start later.
+func (g *transferGate) SetPolite(polite bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.polite = polite
+}
+
+// Acquire waits until a transfer involving hosts may start and returns the
+// function that ends it. Hosts are taken in sorted order so transfers that
+// involve two hosts cannot deadlock.
+func (g *transferGate) Acquire(hosts ...string) (release func()) {
+	g.mu.Lock()
+	if !g.polite {
+		g.mu.Unlock()
+		return func() {}
+	}
+	if g.hosts == nil {
+		g.hosts = map[string]*sync.Mutex{}
+	}
+	sort.Strings(hosts)
+	var locks []*sync.Mutex
+	for i, h := range hosts {
+		if h == "" || (i > 0 && h == hosts[i-1]) {
+			continue
+		}
+		if g.hosts[h] == nil {
+			g.hosts[h] = &sync.Mutex{}
+		}
+		locks = append(locks, g.hosts[h])
+	}
+	g.mu.Unlock()
+
+	for _, l := range locks {
+		l.Lock()
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// Describe summarizes the effective limits for the run log.
+func (g *transferGate) Describe() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	git := "git transfers unlimited"
+	if g.polite {
+		git = "git transfers one at a time per host (polite mode)"
+	}
+	return fmt.Sprintf("Connection limits: %d API connections per host, %s", maxConnsPerHost(), git)
+}
+
+// gitHost returns the host of a git remote URL, without credentials.
+func gitHost(remoteURL string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// runGitTransfer runs a git command that transfers data to or from host,
+// through the transfer gate, under ctx. Pushes are refused in read-only
+// mode.
+func runGitTransfer(ctx context.Context, host string, stream io.Writer, args ...string) (string, error) {
+	if isReadOnly() && containsString(args, "push") {
+		return "", fmt.Errorf("git push to %s: %w", host, errReadOnly)
+	}
+	release := transfers.Acquire(host)
+	defer release()
+	return runGit(ctx, stream, args...)
+}
+
+// remoteURL returns the URL of a remote of the clone in dir, or "".
+func remoteURL(ctx context.Context, dir, remote string) string {
+	out, err := runGit(ctx, nil, "-C", dir, "remote", "get-url", remote)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// remoteHost returns the host of a remote of the clone in dir.
+func remoteHost(ctx context.Context, dir, remote string) string {
+	return gitHost(remoteURL(ctx, dir, remote))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// version is the release version, set at build time with
+// -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+// projectURL identifies the tool to API providers in the User-Agent.
+const projectURL = "https://github.com/singhparavjot/gitui"
+
+// userAgent returns the User-Agent sent with every API request;
+// GITUI_USER_AGENT overrides it.
+func userAgent() string {
+	if ua := os.Getenv("GITUI_USER_AGENT"); ua != "" {
+		return ua
+	}
+	return fmt.Sprintf("gitui-migrator/%s (+%s)", version, projectURL)
+}
+
+// apiClient is the HTTP client for all REST API traffic. Its transport is
+// shared so the per-host connection limit holds across all requests,
+// retries transient failures, and paces requests to GitHub by its rate
+// limit.
+var apiClient = &http.Client{Transport: userAgentTransport{retryTransport{gitHubRateLimitTransport{apiTransport()}}}}
+
+// apiTransport is the default transport limited to maxConnsPerHost
+// connections per host.
+func apiTransport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxConnsPerHost = maxConnsPerHost()
+	t.MaxIdleConnsPerHost = t.MaxConnsPerHost
+	return t
+}
+
+// userAgentTransport sets the User-Agent on each request, and refuses
+// writes in read-only mode.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkReadOnly(req.Method); err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent())
+	return t.base.RoundTrip(req)
+}
+
+// requestID returns the provider's id for the request that produced resp,
+// which its support needs to look the request up: X-GitHub-Request-Id for
+// GitHub, ActivityId for Azure DevOps.
+func requestID(resp *http.Response) string {
+	for _, h := range []string{"X-GitHub-Request-Id", "ActivityId", "X-VSS-ActivityId", "X-Request-Id"} {
+		if id := resp.Header.Get(h); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// newAPIError describes an unexpected response from service.
+func newAPIError(service string, resp *http.Response) *apiError {
+	return &apiError{
+		Service:    service,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RequestID:  requestID(resp),
+	}
+}
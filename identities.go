@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// A run involves up to three identities, easily confused: the GitHub
+// token's user, who reads the source; the Azure DevOps token's user, who
+// creates and changes things in the target; and the committer of the
+// commits the tool makes itself, such as badge rewrites and split
+// histories. runIdentities records all three with the run.
+type runIdentities struct {
+	GitHub    string `json:"github,omitempty"`    // login
+	Source    string `json:"source,omitempty"`    // the source GitHub's login is on, if not GitHub
+	ADO       string `json:"ado,omitempty"`       // display name <account>
+	Committer string `json:"committer,omitempty"` // name <email>
+}
+
+// lines describes the identities, one per line, for the log and the
+// Identities panel.
+func (ids runIdentities) lines() []string {
+	return []string{
+		orDefault(ids.Source, "GitHub") + " token: " + orDefault(ids.GitHub, "unknown"),
+		"Azure DevOps token: " + orDefault(ids.ADO, "unknown"),
+		"Committer of generated commits: " + orDefault(ids.Committer, "unknown"),
+	}
+}
+
+// azureIdentity is who an Azure DevOps token belongs to.
+type azureIdentity struct {
+	ID          string
+	DisplayName string
+	Account     string // usually the sign-in e-mail address
+}
+
+func (id *azureIdentity) String() string {
+	if id.Account == "" || strings.EqualFold(id.Account, id.DisplayName) {
+		return id.DisplayName
+	}
+	return fmt.Sprintf("%s <%s>", id.DisplayName, id.Account)
+}
+
+// getAzureIdentity looks up the token's user in the organization's
+// connection data.
+func getAzureIdentity(ctx context.Context, org, token string) (*azureIdentity, error) {
+	var data struct {
+		AuthenticatedUser struct {
+			ID                  string `json:"id"`
+			ProviderDisplayName string `json:"providerDisplayName"`
+			Properties          struct {
+				Account struct {
+					Value string `json:"$value"`
+				} `json:"Account"`
+			} `json:"properties"`
+		} `json:"authenticatedUser"`
+	}
+	apiURL := fmt.Sprintf("%s/_apis/connectionData", org)
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &data); err != nil {
+		return nil, fmt.Errorf("looking up the Azure DevOps token's user: %v", err)
+	}
+	u := data.AuthenticatedUser
+	if u.ID == "" {
+		return nil, errors.New("looking up the Azure DevOps token's user: the organization did not say who it is; the token may be invalid")
+	}
+	return &azureIdentity{ID: u.ID, DisplayName: u.ProviderDisplayName, Account: u.Properties.Account.Value}, nil
+}
+
+// projectPermissions are the project-level Git permissions the features
+// that change the target beyond pushing need: branch policies, and
+// repository permissions.
+var projectPermissions = []struct {
+	Bit  int
+	Name string
+	For  string
+}{
+	{2048, "Edit policies", "branch policies"},
+	{8192, "Manage permissions", "repository permissions"},
+}
+
+// errPermissionsUncheckable is returned when the token's scopes do not
+// allow its permissions to be checked.
+var errPermissionsUncheckable = errors.New("the token's scopes do not allow checking its permissions")
+
+// missingProjectPermissions returns the projectPermissions the token's
+// user lacks on the Git repositories of project, as "name (for what)". A
+// token whose scopes keep it from checking gets errPermissionsUncheckable,
+// a scope problem rather than a permission one.
+func missingProjectPermissions(ctx context.Context, org, project, token string) ([]string, error) {
+	var p struct {
+		ID string `json:"id"`
+	}
+	apiURL := fmt.Sprintf("%s/_apis/projects/%s?api-version=7.0", org, url.PathEscape(project))
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &p); err != nil {
+		return nil, fmt.Errorf("looking up project: %v", err)
+	}
+	var missing []string
+	for _, perm := range projectPermissions {
+		var result struct {
+			Value []bool `json:"value"`
+		}
+		apiURL = fmt.Sprintf("%s/_apis/permissions/%s/%d?tokens=%s&api-version=7.0",
+			org, gitRepositoriesNamespace, perm.Bit, url.QueryEscape("repoV2/"+p.ID))
+		err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result)
+		var apiErr *apiError
+		switch {
+		case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+			return nil, fmt.Errorf("%w: %v", errPermissionsUncheckable, err)
+		case err != nil:
+			return nil, fmt.Errorf("checking permissions: %v", err)
+		case len(result.Value) == 0 || !result.Value[0]:
+			missing = append(missing, fmt.Sprintf("%s (for %s)", perm.Name, perm.For))
+		}
+	}
+	return missing, nil
+}
+
+// azurePermissionWarning returns the warning about the project-level
+// permissions the Azure DevOps identity id (nil if unknown) lacks, or "" if
+// it has them. It is worded apart from token scope problems: a PAT with
+// every scope still cannot do what its user is not allowed to.
+func azurePermissionWarning(ctx context.Context, org, project, token string, id *azureIdentity) string {
+	who := "the Azure DevOps identity"
+	if id != nil {
+		who += " " + id.String()
+	}
+	missing, err := missingProjectPermissions(ctx, org, project, token)
+	switch {
+	case errors.Is(err, errPermissionsUncheckable):
+		return fmt.Sprintf("Token scope problem: the Azure DevOps PAT cannot check the project permissions of %s (%v); give it the Security (read) scope to check them.", who, err)
+	case err != nil:
+		return fmt.Sprintf("Could not check the project permissions of %s: %v", who, err)
+	case len(missing) > 0:
+		them := "it"
+		if len(missing) > 1 {
+			them = "them"
+		}
+		return fmt.Sprintf("Project permission problem, not a token scope one: %s lacks %s in project %s. A project administrator must grant %s to the user; a new PAT will not help.",
+			who, strings.Join(missing, " and "), project, them)
+	}
+	return ""
+}
+
+// resolveIdentities fills in the identities of a run the GitHub token's
+// user githubLogin starts: the Azure DevOps token's user if az is set, and
+// the committer signer gives generated commits. It also returns the Azure
+// DevOps user, nil if it could not be looked up, which is logged.
+func resolveIdentities(ctx context.Context, githubLogin string, az *azureTarget, signer *commitSigner, logMsg func(string)) (runIdentities, *azureIdentity) {
+	ids := runIdentities{GitHub: githubLogin}
+	var adoID *azureIdentity
+	if az != nil {
+		var err error
+		if adoID, err = getAzureIdentity(ctx, az.org, az.token); err != nil {
+			logMsg("Warning: " + err.Error())
+		} else {
+			ids.ADO = adoID.String()
+		}
+	}
+	name, email := signer.identity()
+	ids.Committer = fmt.Sprintf("%s <%s>", name, email)
+	return ids, adoID
+}
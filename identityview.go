@@ -0,0 +1,40 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// confirmIdentityDialog returns a function that shows warning and asks for
+// the token's login to be typed before the run goes on, so a wrong token
+// cannot be waved through with one click. It blocks until answered.
+func confirmIdentityDialog(w fyne.Window) func(warning, login string) bool {
+	return func(warning, login string) bool {
+		answer := make(chan bool)
+		loginEntry := widget.NewEntry()
+		loginEntry.SetPlaceHolder(login)
+		loginEntry.Validator = func(s string) error {
+			if strings.TrimSpace(s) != login {
+				return fmt.Errorf("type %s to continue", login)
+			}
+			return nil
+		}
+		message := widget.NewLabel(warning)
+		message.Wrapping = fyne.TextWrapWord
+		d := dialog.NewForm("Unexpected GitHub identity", "Migrate as "+login, "Cancel",
+			[]*widget.FormItem{
+				widget.NewFormItem("", message),
+				widget.NewFormItem("Type the login", loginEntry),
+			},
+			func(ok bool) { answer <- ok }, w)
+		d.Resize(fyne.NewSize(560, 0))
+		d.Show()
+		return <-answer
+	}
+}
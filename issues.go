@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub issues are migrated as Azure Boards work items by a content
+// feature, opted into in the content preview, once the repository itself
+// is migrated. Each issue becomes an Issue work item with its title, body,
+// labels (as tags), comments and a link back to GitHub; a closed issue's
+// work item is moved to the process's completed state. Which work item
+// each issue became is recorded in issueMapPath, so an issue is migrated
+// once however often the preview is applied.
+
+// issueMapPath maps GitHub issues to the work items they were migrated as,
+// relative to the working directory.
+const issueMapPath = "issue-work-items.json"
+
+// issueWorkItemType is the work item type issues are created as. The
+// Basic, Agile and CMMI processes have it; Scrum does not.
+const issueWorkItemType = "Issue"
+
+var issueMapMu sync.Mutex
+
+// issueLink records that a GitHub issue was migrated as a work item.
+type issueLink struct {
+	Source   string `json:"source"` // owner/name
+	Issue    int    `json:"issue"`
+	WorkItem int    `json:"work_item"`
+	URL      string `json:"url"` // the work item's, in the web UI
+	Created  string `json:"created"`
+}
+
+// loadIssueMap reads the issue mapping; no file means none yet.
+func loadIssueMap() ([]issueLink, error) {
+	data, err := readFileRecover(issueMapPath, func(data []byte) error {
+		var links []issueLink
+		return json.Unmarshal(data, &links)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []issueLink
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// recordIssueLink adds link to the issue mapping.
+func recordIssueLink(link issueLink) error {
+	issueMapMu.Lock()
+	defer issueMapMu.Unlock()
+	links, err := loadIssueMap()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(append(links, link), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(issueMapPath, append(data, '\n'), 0644)
+}
+
+// migratedIssues returns the work item each of source's issues was
+// migrated as, by issue number.
+func migratedIssues(source string) (map[int]int, error) {
+	issueMapMu.Lock()
+	links, err := loadIssueMap()
+	issueMapMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	done := map[int]int{}
+	for _, l := range links {
+		if strings.EqualFold(l.Source, source) {
+			done[l.Issue] = l.WorkItem
+		}
+	}
+	return done, nil
+}
+
+// gitHubIssue is the subset of the GitHub issue API object the migration
+// uses.
+type gitHubIssue struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	State     string `json:"state"` // open or closed
+	HTMLURL   string `json:"html_url"`
+	CreatedAt string `json:"created_at"`
+	Comments  int    `json:"comments"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	// PullRequest is set on the pull requests the issues API lists too.
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+// gitHubComment is an issue comment.
+type gitHubComment struct {
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// listGitHubIssues lists every issue of repo, open and closed, in order of
+// number. Pull requests are left out.
+func listGitHubIssues(ctx context.Context, repo, token string) ([]gitHubIssue, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/issues?state=all&direction=asc&per_page=100", repo)
+	var issues []gitHubIssue
+	for apiURL != "" {
+		var page []gitHubIssue
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return issues, err
+		}
+		for _, i := range page {
+			if i.PullRequest == nil {
+				issues = append(issues, i)
+			}
+		}
+		apiURL = next
+	}
+	return issues, nil
+}
+
+// listGitHubComments lists the comments on repo's issue number, oldest
+// first.
+func listGitHubComments(ctx context.Context, repo string, number int, token string) ([]gitHubComment, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/comments?per_page=100", repo, number)
+	var comments []gitHubComment
+	for apiURL != "" {
+		var page []gitHubComment
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return comments, err
+		}
+		comments = append(comments, page...)
+		apiURL = next
+	}
+	return comments, nil
+}
+
+// issueHTML renders GitHub's Markdown text for a work item's HTML fields:
+// escaped, with its line breaks kept, it reads as it did on GitHub. There
+// is no Markdown renderer here.
+func issueHTML(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// jsonPatchOp is an operation of the JSON Patch documents work items are
+// created and updated with.
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// azureWorkItem is the subset of the work item API object the migration
+// uses.
+type azureWorkItem struct {
+	ID    int `json:"id"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"_links"`
+}
+
+// issueWorkItemFields returns the operations that create issue's work
+// item, placed at placement unless it is nil.
+func issueWorkItemFields(repo string, issue gitHubIssue, placement *workItemPlacement) []jsonPatchOp {
+	description := fmt.Sprintf(`<p>Migrated from GitHub issue <a href="%s">%s#%d</a>, opened by @%s on %s.</p>%s`,
+		html.EscapeString(issue.HTMLURL), html.EscapeString(repo), issue.Number, html.EscapeString(issue.User.Login), issue.CreatedAt, issueHTML(issue.Body))
+	ops := []jsonPatchOp{
+		{Op: "add", Path: "/fields/System.Title", Value: issue.Title},
+		{Op: "add", Path: "/fields/System.Description", Value: description},
+		{Op: "add", Path: "/relations/-", Value: map[string]string{"rel": "Hyperlink", "url": issue.HTMLURL}},
+	}
+	var tags []string
+	for _, l := range issue.Labels {
+		// Tags are separated by semicolons.
+		tags = append(tags, strings.ReplaceAll(l.Name, ";", ","))
+	}
+	if len(tags) > 0 {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/fields/System.Tags", Value: strings.Join(tags, "; ")})
+	}
+	if placement != nil {
+		ops = append(ops,
+			jsonPatchOp{Op: "add", Path: "/fields/System.AreaPath", Value: placement.AreaPath},
+			jsonPatchOp{Op: "add", Path: "/fields/System.IterationPath", Value: placement.IterationPath})
+	}
+	return ops
+}
+
+// completedState returns the project's state of workItemType in the
+// Completed category, which closed issues are moved to.
+func completedState(ctx context.Context, org, project, workItemType, token string) (string, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/wit/workitemtypes/%s/states?api-version=7.0", org, url.PathEscape(project), url.PathEscape(workItemType))
+	var result struct {
+		Value []struct {
+			Name     string `json:"name"`
+			Category string `json:"category"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return "", fmt.Errorf("the project's process has no %s work item type", workItemType)
+		}
+		return "", err
+	}
+	for _, s := range result.Value {
+		if s.Category == "Completed" {
+			return s.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%s work items have no completed state", workItemType)
+}
+
+// createIssueWorkItem migrates issue of repo as a work item in t's project
+// and records the mapping as soon as the work item exists. Its comments
+// follow, then the completed state if the issue is closed. placement is
+// nil to leave the project's default area and iteration.
+func createIssueWorkItem(ctx context.Context, t *azureTarget, repo string, issue gitHubIssue, placement *workItemPlacement, doneState, githubToken string) error {
+	var comments []gitHubComment
+	if issue.Comments > 0 {
+		var err error
+		if comments, err = listGitHubComments(ctx, repo, issue.Number, githubToken); err != nil {
+			return fmt.Errorf("listing the comments on #%d: %v", issue.Number, err)
+		}
+	}
+
+	apiURL := fmt.Sprintf("%s/%s/_apis/wit/workitems/$%s?api-version=7.0", t.org, url.PathEscape(t.project), url.PathEscape(issueWorkItemType))
+	var item azureWorkItem
+	if err := azureRequestContent(ctx, "POST", apiURL, t.token, "application/json-patch+json", issueWorkItemFields(repo, issue, placement), http.StatusOK, &item); err != nil {
+		return err
+	}
+	if err := recordIssueLink(issueLink{Source: repo, Issue: issue.Number, WorkItem: item.ID, URL: item.Links.HTML.Href, Created: fileTimestamp(time.Now())}); err != nil {
+		return fmt.Errorf("work item %d created, but not recorded in %s: %v", item.ID, issueMapPath, err)
+	}
+
+	commentsURL := fmt.Sprintf("%s/%s/_apis/wit/workItems/%d/comments?api-version=7.0-preview.3", t.org, url.PathEscape(t.project), item.ID)
+	for i, c := range comments {
+		text := fmt.Sprintf("<p>@%s commented on GitHub on %s:</p>%s", html.EscapeString(c.User.Login), c.CreatedAt, issueHTML(c.Body))
+		if err := azureRequest(ctx, "POST", commentsURL, t.token, map[string]string{"text": text}, http.StatusOK, nil); err != nil {
+			return fmt.Errorf("work item %d created, but only %d of its %d comments: %v", item.ID, i, len(comments), err)
+		}
+	}
+
+	if issue.State == "closed" {
+		itemURL := fmt.Sprintf("%s/%s/_apis/wit/workitems/%d?api-version=7.0", t.org, url.PathEscape(t.project), item.ID)
+		state := []jsonPatchOp{{Op: "add", Path: "/fields/System.State", Value: doneState}}
+		if err := azureRequestContent(ctx, "PATCH", itemURL, t.token, "application/json-patch+json", state, http.StatusOK, nil); err != nil {
+			return fmt.Errorf("work item %d created, but not moved to %s: %v", item.ID, doneState, err)
+		}
+	}
+	return nil
+}
+
+// issuesFeature previews migrating each repository's GitHub issues not yet
+// migrated as work items, placed as areas maps them if it maps any.
+func issuesFeature(areas *workItemAreas) *contentFeature {
+	return &contentFeature{
+		Name:  "GitHub issues as work items",
+		OptIn: true,
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			issues, err := listGitHubIssues(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("listing GitHub issues: %v", err)
+			}
+			done, err := migratedIssues(repo.Source)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %v", issueMapPath, err)
+			}
+			var todo []gitHubIssue
+			for _, issue := range issues {
+				if _, ok := done[issue.Number]; !ok {
+					todo = append(todo, issue)
+				}
+			}
+			if len(todo) == 0 {
+				return nil, nil
+			}
+			doneState, err := completedState(ctx, t.org, t.project, issueWorkItemType, t.token)
+			if err != nil {
+				return nil, err
+			}
+			var placement *workItemPlacement
+			if areas.Enabled() {
+				p := areas.Placement(t.project, repo.Source)
+				placement = &p
+			}
+
+			var ops []*contentOp
+			for _, issue := range todo {
+				issue := issue
+				detail := fmt.Sprintf("%s, %d label(s), %d comment(s)", issue.State, len(issue.Labels), issue.Comments)
+				if issue.State == "closed" {
+					detail += ", to " + doneState
+				}
+				ops = append(ops, &contentOp{
+					Change: "+",
+					What:   fmt.Sprintf("%s work item for #%d %q", issueWorkItemType, issue.Number, issue.Title),
+					Detail: detail,
+					apply: func(ctx context.Context) error {
+						return createIssueWorkItem(ctx, t, repo.Source, issue, placement, doneState, githubToken)
+					},
+				})
+			}
+			return ops, nil
+		},
+	}
+}
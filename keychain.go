@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Credentials the user asks to remember are kept in the OS keychain: the
+// macOS Keychain, the Windows Credential Manager or the Secret Service on
+// Linux. They never go to the preferences file, profiles or reports; only
+// whether to remember them is a preference.
+
+// keyringService is what the tool's keychain entries are filed under.
+const keyringService = "gitui-migrator"
+
+// credentialKey returns the keychain key of a provider's secret field,
+// such as "github.token" or "azure-devops.token".
+func credentialKey(provider, field string) string {
+	return strings.ReplaceAll(strings.ToLower(provider), " ", "-") + "." + field
+}
+
+// saveCredentials stores values in the keychain by key. A key whose value
+// is empty is deleted, so a token cleared in the window is not loaded back.
+func saveCredentials(values map[string]string) error {
+	var errs []error
+	for key, value := range values {
+		var err error
+		if value == "" {
+			err = keyring.Delete(keyringService, key)
+			if errors.Is(err, keyring.ErrNotFound) {
+				err = nil
+			}
+		} else {
+			err = keyring.Set(keyringService, key, value)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadCredentials returns the values of keys stored in the keychain; keys
+// not stored are left out.
+func loadCredentials(keys []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, key := range keys {
+		value, err := keyring.Get(keyringService, key)
+		if errors.Is(err, keyring.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return values, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// clearCredentials deletes keys from the keychain.
+func clearCredentials(keys []string) error {
+	values := map[string]string{}
+	for _, key := range keys {
+		values[key] = ""
+	}
+	return saveCredentials(values)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lfsPolicy decides what happens when GitHub cannot serve some of a
+// repository's LFS objects (purged, or over the bandwidth quota).
+type lfsPolicy int
+
+const (
+	// lfsFailOnMissing fails the repository. This is the default.
+	lfsFailOnMissing lfsPolicy = iota
+	// lfsContinueOnMissing pushes the pointers as-is, so history stays
+	// intact, and records which objects were missing.
+	lfsContinueOnMissing
+)
+
+// lfsPolicyNames are the UI labels for each lfsPolicy, in order.
+var lfsPolicyNames = []string{
+	"Fail repo on missing LFS objects",
+	"Continue and record missing LFS objects",
+}
+
+// maxLFSCommits caps how many referencing commits are listed per missing
+// object.
+const maxLFSCommits = 10
+
+// lfsMissing is an LFS object GitHub could not serve, with the commits that
+// reference it.
+type lfsMissing struct {
+	OID     string
+	Commits []string
+}
+
+func (m lfsMissing) String() string {
+	if len(m.Commits) == 0 {
+		return m.OID
+	}
+	return fmt.Sprintf("%s (referenced by %s)", m.OID, strings.Join(m.Commits, ", "))
+}
+
+var lfsOIDPattern = regexp.MustCompile(`\[([0-9a-f]{64})\]`)
+
+// repoUsesLFS reports whether the bare clone in dir routes any paths
+// through the LFS filter: on any branch or tag, in any .gitattributes, now
+// or earlier in its history, since old commits' objects are pushed too. It
+// needs only git, not git-lfs.
+func repoUsesLFS(ctx context.Context, dir string) bool {
+	out, err := runGit(ctx, nil, "-C", dir, "log", "--all", "-1", "--format=%h", "-G", "filter=lfs", "--", ".gitattributes", "*/.gitattributes")
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
+// lfsPatterns returns the patterns the default branch's top-level
+// .gitattributes routes through LFS, for the log; nil if it has none.
+func lfsPatterns(ctx context.Context, dir string) []string {
+	out, err := runGit(ctx, nil, "-C", dir, "show", "HEAD:.gitattributes")
+	if err != nil {
+		return nil
+	}
+	var patterns []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && !strings.HasPrefix(fields[0], "#") && containsString(fields[1:], "filter=lfs") {
+			patterns = append(patterns, fields[0])
+		}
+	}
+	return patterns
+}
+
+// lfsObjectStats counts the LFS objects fetched into the bare clone in dir
+// and adds up their size.
+func lfsObjectStats(dir string) (count int, size int64) {
+	filepath.Walk(filepath.Join(dir, "lfs", "objects"), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+			size += info.Size()
+		}
+		return nil
+	})
+	return count, size
+}
+
+// errLFSNotFound fails a repository that uses LFS when git-lfs is not
+// installed: pushing it anyway would leave only the pointer files.
+var errLFSNotFound = errors.New("git-lfs not found, repo uses LFS")
+
+// lfsInstalled reports whether git-lfs is installed.
+func lfsInstalled() bool {
+	_, err := runGit(context.Background(), nil, "lfs", "version")
+	return err == nil
+}
+
+// parseMissingLFS returns the OIDs git lfs fetch reported as failed.
+func parseMissingLFS(output string) []string {
+	seen := map[string]bool{}
+	var oids []string
+	for _, m := range lfsOIDPattern.FindAllStringSubmatch(output, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			oids = append(oids, m[1])
+		}
+	}
+	return oids
+}
+
+// lfsCommitsReferencing lists commits that add or remove the pointer for oid.
+func lfsCommitsReferencing(ctx context.Context, dir, oid string) []string {
+	out, err := runGit(ctx, nil, "-C", dir, "log", "--all", "--format=%h", "-S", "oid sha256:"+oid)
+	if err != nil {
+		return nil
+	}
+	commits := strings.Fields(out)
+	if len(commits) > maxLFSCommits {
+		commits = append(commits[:maxLFSCommits], "...")
+	}
+	return commits
+}
+
+// migrateLFS fetches every LFS object from origin and pushes them to remote.
+// Under lfsContinueOnMissing, objects GitHub cannot serve are returned
+// instead of failing, and the push is allowed to be incomplete.
+func migrateLFS(ctx context.Context, dir, remote string, policy lfsPolicy, stream io.Writer) ([]lfsMissing, error) {
+	var missing []lfsMissing
+	output, err := runGitTransfer(ctx, remoteHost(ctx, dir, "origin"), stream, lfsFetchArgs(dir, "origin")...)
+	if err != nil {
+		oids := parseMissingLFS(output)
+		if policy != lfsContinueOnMissing || len(oids) == 0 {
+			return nil, fmt.Errorf("git lfs fetch: %v, output: %s", err, output)
+		}
+		for _, oid := range oids {
+			missing = append(missing, lfsMissing{OID: oid, Commits: lfsCommitsReferencing(ctx, dir, oid)})
+		}
+	}
+
+	pushURL := remoteURL(ctx, dir, remote)
+	if output, err := runGitTransfer(ctx, gitHost(pushURL), stream, lfsPushArgs(dir, remote, len(missing) > 0)...); err != nil {
+		return missing, fmt.Errorf("git lfs push: %w, output: %s", conditionalAccessPushError(err, output, pushURL), output)
+	}
+	return missing, nil
+}
+
+// lfsLock is an active LFS file lock on GitHub.
+type lfsLock struct {
+	Path     string `json:"path"`
+	LockedAt string `json:"locked_at"`
+	Owner    struct {
+		Name string `json:"name"`
+	} `json:"owner"`
+}
+
+func (l lfsLock) String() string {
+	return fmt.Sprintf("%s (locked by %s at %s)", l.Path, l.Owner.Name, l.LockedAt)
+}
+
+// getLFSLocks lists active LFS locks for a repository ("owner/repo") using
+// the LFS locks API, so teams can release them before cutover.
+func getLFSLocks(ctx context.Context, fullName, token string) ([]lfsLock, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://github.com/%s.git/info/lfs/locks", fullName), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth("x-access-token", token)
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("GitHub LFS", resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Locks []lfsLock `json:"locks"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return result.Locks, nil
+}
+
+// lfsStep runs the LFS part of a repository migration, logging active locks
+// and any missing objects. token is GitHub's, for the locks; without one
+// they are not listed. It returns an error only if the repository should be
+// marked failed.
+func lfsStep(ctx context.Context, dir, remote, fullName, token string, policy lfsPolicy, stream io.Writer, logMsg func(string)) error {
+	if !repoUsesLFS(ctx, dir) {
+		return nil
+	}
+	if !lfsInstalled() {
+		return errLFSNotFound
+	}
+	logMsg(fmt.Sprintf("%s uses Git LFS, migrating LFS objects...", fullName))
+	if patterns := lfsPatterns(ctx, dir); len(patterns) > 0 {
+		logMsg(fmt.Sprintf("LFS patterns of %s: %s", fullName, strings.Join(patterns, " ")))
+	}
+
+	if token == "" {
+		// Locks are listed from GitHub only.
+	} else if locks, err := getLFSLocks(ctx, fullName, token); err != nil {
+		logMsg(fmt.Sprintf("Warning: could not list LFS locks for %s: %v", fullName, err))
+	} else if len(locks) > 0 {
+		logMsg(fmt.Sprintf("Warning: %s has %d active LFS lock(s), release them before cutover:", fullName, len(locks)))
+		for _, l := range locks {
+			logMsg("  " + l.String())
+		}
+	}
+
+	missing, err := migrateLFS(ctx, dir, remote, policy, stream)
+	if err != nil {
+		return err
+	}
+	count, size := lfsObjectStats(dir)
+	logMsg(fmt.Sprintf("Pushed %d LFS object(s) of %s, %s.", count, fullName, formatBytes(size)))
+	if len(missing) > 0 {
+		logMsg(fmt.Sprintf("Warning: %d LFS object(s) of %s are missing on GitHub, pointers were pushed as-is:", len(missing), fullName))
+		for _, m := range missing {
+			logMsg("  " + m.String())
+		}
+	}
+	return nil
+}
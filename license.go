@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gitHubLicense is the license GitHub detected in a repository.
+type gitHubLicense struct {
+	Key    string `json:"key"`
+	SPDXID string `json:"spdx_id"` // "NOASSERTION" if GitHub could not tell which
+	Name   string `json:"name"`
+}
+
+// Decisions of the license policy on a repository.
+const (
+	licenseAllowed     = "allowed"
+	licenseDenied      = "denied"
+	licenseNeedsReview = "needs review"
+)
+
+// licenseGate is what a run does with a repository the license policy
+// flags, that is one denied or needing review.
+type licenseGate int
+
+const (
+	// licenseGateWarn logs a warning and migrates it.
+	licenseGateWarn licenseGate = iota
+	// licenseGateConfirm migrates it only once someone confirms it, who is
+	// recorded; without anyone to ask, as in headless mode, it is blocked.
+	licenseGateConfirm
+	// licenseGateBlock skips it.
+	licenseGateBlock
+)
+
+var licenseGateNames = []string{"warn", "confirm", "block"}
+
+// licensePolicyConfig is the license policy as written, in YAML, both in
+// the window's License Policy editor and under license_policy in a
+// migration config:
+//
+//	deny: [GPL-3.0, AGPL-*]
+//	review: [LGPL-*, MPL-2.0]
+//	allow: [MIT, Apache-2.0, BSD-*]
+//	other: review       # unlisted or undetected licenses: allow, deny or review
+//	gate: confirm       # warn, confirm or block
+//	private_only: true  # only applies when the target project is private
+type licensePolicyConfig struct {
+	Allow       []string `yaml:"allow"`
+	Deny        []string `yaml:"deny"`
+	Review      []string `yaml:"review"`
+	Other       string   `yaml:"other"`
+	Gate        string   `yaml:"gate"`
+	PrivateOnly bool     `yaml:"private_only"`
+}
+
+// licensePolicy decides, by SPDX identifier, which repositories may be
+// hosted in the target. Patterns are globs and match case-insensitively;
+// deny wins over review, and review over allow.
+type licensePolicy struct {
+	Allow, Deny, Review []string
+	Other               string // the decision for licenses no pattern matches
+	Gate                licenseGate
+	PrivateOnly         bool
+}
+
+// policy validates the config.
+func (c *licensePolicyConfig) policy() (*licensePolicy, error) {
+	p := &licensePolicy{Allow: c.Allow, Deny: c.Deny, Review: c.Review, PrivateOnly: c.PrivateOnly}
+	for _, pattern := range append(append(append([]string(nil), c.Allow...), c.Deny...), c.Review...) {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return nil, fmt.Errorf("license policy: bad pattern %q: %v", pattern, err)
+		}
+	}
+	switch c.Other {
+	case "", "review":
+		p.Other = licenseNeedsReview
+	case "allow":
+		p.Other = licenseAllowed
+	case "deny":
+		p.Other = licenseDenied
+	default:
+		return nil, fmt.Errorf("license policy: other: unknown decision %q (want allow, deny or review)", c.Other)
+	}
+	switch c.Gate {
+	case "", "warn":
+		p.Gate = licenseGateWarn
+	case "confirm":
+		p.Gate = licenseGateConfirm
+	case "block":
+		p.Gate = licenseGateBlock
+	default:
+		return nil, fmt.Errorf("license policy: gate: unknown gate %q (want warn, confirm or block)", c.Gate)
+	}
+	return p, nil
+}
+
+// parseLicensePolicy parses a license policy written in YAML; blank text
+// is no policy, and nil.
+func parseLicensePolicy(text string) (*licensePolicy, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	var c licensePolicyConfig
+	if err := yaml.Unmarshal([]byte(text), &c); err != nil {
+		return nil, fmt.Errorf("license policy: %v", err)
+	}
+	return c.policy()
+}
+
+// loadLicensePolicy reads a license policy file.
+func loadLicensePolicy(path string) (*licensePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseLicensePolicy(string(data))
+}
+
+// String summarizes the policy for reports and support bundles.
+func (p *licensePolicy) String() string {
+	if p == nil {
+		return "none"
+	}
+	s := fmt.Sprintf("deny [%s], review [%s], allow [%s], other %s, gate %s",
+		strings.Join(p.Deny, ", "), strings.Join(p.Review, ", "), strings.Join(p.Allow, ", "), p.Other, licenseGateNames[p.Gate])
+	if p.PrivateOnly {
+		s += ", private projects only"
+	}
+	return s
+}
+
+// licenseID is the identifier the policy matches for a detected license:
+// its SPDX identifier, "none" if GitHub detected none, and "other" if it
+// found a license it could not identify.
+func licenseID(l *gitHubLicense) string {
+	switch {
+	case l == nil:
+		return "none"
+	case l.SPDXID == "" || l.SPDXID == "NOASSERTION":
+		return "other"
+	}
+	return l.SPDXID
+}
+
+// matchLicense reports whether id matches any of patterns.
+func matchLicense(patterns []string, id string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(id)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// decide returns the decision on license id (see licenseID), and why,
+// for a target project that is private or not.
+func (p *licensePolicy) decide(id string, privateProject bool) (string, string) {
+	switch {
+	case p.PrivateOnly && !privateProject:
+		return licenseAllowed, "the target project is public"
+	case matchLicense(p.Deny, id):
+		return licenseDenied, "listed under deny"
+	case matchLicense(p.Review, id):
+		return licenseNeedsReview, "listed under review"
+	case matchLicense(p.Allow, id):
+		return licenseAllowed, "listed under allow"
+	}
+	return p.Other, "not listed"
+}
+
+// licenseRecord is the license decision on a repository, for the
+// compliance record in the run report.
+type licenseRecord struct {
+	License     string `json:"license"` // SPDX identifier, "none" or "other"
+	Name        string `json:"name,omitempty"`
+	Visibility  string `json:"visibility,omitempty"` // on GitHub
+	Decision    string `json:"decision"`
+	Why         string `json:"why"`
+	Gate        string `json:"gate,omitempty"` // for flagged repositories
+	ConfirmedBy string `json:"confirmed_by,omitempty"`
+	ConfirmedAt string `json:"confirmed_at,omitempty"`
+}
+
+// Flagged reports whether the gate applies.
+func (rec *licenseRecord) Flagged() bool {
+	return rec.Decision != licenseAllowed
+}
+
+func (rec *licenseRecord) String() string {
+	return fmt.Sprintf("license %s %s (%s)", rec.License, rec.Decision, rec.Why)
+}
+
+// licenseDecision applies the policy to a repository with GitHub metadata
+// meta; nil meta, whose license is unknown, needs review unless the policy
+// denies unlisted licenses.
+func (p *licensePolicy) licenseDecision(meta *gitHubRepo, privateProject bool) *licenseRecord {
+	if meta == nil {
+		rec := &licenseRecord{License: "unknown", Decision: licenseNeedsReview, Why: "metadata could not be fetched"}
+		if p.Other == licenseDenied {
+			rec.Decision = licenseDenied
+		}
+		if p.PrivateOnly && !privateProject {
+			rec.Decision, rec.Why = licenseAllowed, "the target project is public"
+		}
+		return rec
+	}
+	rec := &licenseRecord{License: licenseID(meta.License), Visibility: meta.Visibility}
+	if meta.License != nil {
+		rec.Name = meta.License.Name
+	}
+	rec.Decision, rec.Why = p.decide(rec.License, privateProject)
+	return rec
+}
+
+// licenseStep applies the run's license policy to repo. It returns the
+// record for the report and whether to go on migrating; if not, the
+// reason.
+func (r *migrationRun) licenseStep(repo string, meta *gitHubRepo, logMsg func(string)) (*licenseRecord, bool, string) {
+	rec := r.Licenses.licenseDecision(meta, r.PrivateProject)
+	if !rec.Flagged() {
+		return rec, true, ""
+	}
+	rec.Gate = licenseGateNames[r.Licenses.Gate]
+	switch {
+	case r.Licenses.Gate == licenseGateWarn:
+		logMsg(fmt.Sprintf("Warning: %s: %s; migrating it, the license gate only warns.", repo, rec))
+		return rec, true, ""
+	case r.Licenses.Gate == licenseGateBlock:
+		logMsg(fmt.Sprintf("Skipping %s: %s, and the license gate blocks it.", repo, rec))
+		return rec, false, rec.String()
+	case r.DryRun:
+		logMsg(fmt.Sprintf("Dry run: %s: %s; a run would ask to confirm it.", repo, rec))
+		return rec, true, ""
+	case r.ConfirmLicense == nil:
+		logMsg(fmt.Sprintf("Skipping %s: %s, and there is nobody to confirm it.", repo, rec))
+		return rec, false, rec.String() + ", not confirmed"
+	}
+	by, ok := r.ConfirmLicense(repo, rec)
+	if !ok {
+		logMsg(fmt.Sprintf("Skipping %s: %s, not confirmed.", repo, rec))
+		return rec, false, rec.String() + ", not confirmed"
+	}
+	rec.ConfirmedBy, rec.ConfirmedAt = by, fileTimestamp(time.Now())
+	logMsg(fmt.Sprintf("%s: %s; migrating it, confirmed by %s.", repo, rec, by))
+	if err := writeAudit("license-confirm", repo, fmt.Sprintf("%s; confirmed by %s", rec, by)); err != nil {
+		logMsg(fmt.Sprintf("Warning: could not write the audit log: %v", err))
+	}
+	return rec, true, ""
+}
+
+// azureProjectPrivate reports whether an Azure DevOps project is private.
+func azureProjectPrivate(ctx context.Context, org, project, token string) (bool, error) {
+	var p struct {
+		Visibility string `json:"visibility"`
+	}
+	apiURL := fmt.Sprintf("%s/_apis/projects/%s?api-version=7.0", org, url.PathEscape(project))
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &p); err != nil {
+		return true, err
+	}
+	return !strings.EqualFold(p.Visibility, "public"), nil
+}
+
+// licensePolicySummary summarizes a license policy as written, for support
+// bundles.
+func licensePolicySummary(text string) string {
+	p, err := parseLicensePolicy(text)
+	if err != nil {
+		return "invalid (" + err.Error() + ")"
+	}
+	return p.String()
+}
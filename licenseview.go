@@ -0,0 +1,81 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// confirmLicenseDialog returns a function that asks whether to migrate a
+// repository the license policy flags, and who is confirming it, which
+// the report records. It blocks until answered.
+func confirmLicenseDialog(w fyne.Window) func(repo string, rec *licenseRecord) (string, bool) {
+	return func(repo string, rec *licenseRecord) (string, bool) {
+		answer := make(chan bool)
+		byEntry := widget.NewEntry()
+		byEntry.SetText(holdUser())
+		byEntry.Validator = func(s string) error {
+			if strings.TrimSpace(s) == "" {
+				return fmt.Errorf("say who is confirming")
+			}
+			return nil
+		}
+		license := rec.License
+		if rec.Name != "" {
+			license = fmt.Sprintf("%s (%s)", rec.License, rec.Name)
+		}
+		message := widget.NewLabel(fmt.Sprintf("%s is licensed %s, which the license policy %s (%s).\n\nMigrate it anyway? Who confirmed it is recorded in the run report.",
+			repo, license, map[string]string{licenseDenied: "denies", licenseNeedsReview: "flags for review"}[rec.Decision], rec.Why))
+		message.Wrapping = fyne.TextWrapWord
+		d := dialog.NewForm("License policy", "Migrate", "Skip",
+			[]*widget.FormItem{
+				widget.NewFormItem("", message),
+				widget.NewFormItem("Confirmed by", byEntry),
+			},
+			func(ok bool) { answer <- ok }, w)
+		d.Resize(fyne.NewSize(560, 0))
+		d.Show()
+		if !<-answer {
+			return "", false
+		}
+		return strings.TrimSpace(byEntry.Text), true
+	}
+}
+
+// showLicensePolicy opens the license policy editor. The policy is saved
+// with save once it parses; blank clears it.
+func showLicensePolicy(w fyne.Window, text string, save func(string), logMsg func(string)) {
+	entry := widget.NewMultiLineEntry()
+	entry.SetText(text)
+	entry.SetPlaceHolder("deny: [GPL-3.0, AGPL-*]\nreview: [LGPL-*, MPL-2.0]\nallow: [MIT, Apache-2.0, BSD-*]\nother: review\ngate: confirm\nprivate_only: true")
+	entry.Validator = func(text string) error {
+		_, err := parseLicensePolicy(text)
+		return err
+	}
+	help := widget.NewLabel("Repositories are matched by the SPDX identifier of the license GitHub detected (\"none\" if it detected none, \"other\" if it could not identify it); patterns may use * and are not case-sensitive. deny wins over review, review over allow, and other decides the rest. The gate says what happens to denied repositories and those needing review: warn migrates them, confirm asks for each, block skips them.")
+	help.Wrapping = fyne.TextWrapWord
+	d := dialog.NewForm("License policy", "Save", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("", help),
+			widget.NewFormItem("Policy (YAML)", entry),
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			policy, err := parseLicensePolicy(entry.Text)
+			if err != nil {
+				logMsg(fmt.Sprintf("Error: %v", err))
+				return
+			}
+			save(entry.Text)
+			logMsg("License policy: " + policy.String() + ".")
+		}, w)
+	d.Resize(fyne.NewSize(640, 480))
+	d.Show()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// listingsDir holds the saved GitHub listing of each organization,
+// relative to the working directory. A listing is saved after every page,
+// so one interrupted part way through a large organization (an expired
+// token, a closed window) can be resumed from the page it stopped at.
+const listingsDir = "listings"
+
+// listingState is an organization's listing as far as it got.
+type listingState struct {
+	Org      string `json:"org"`
+	Started  string `json:"started"`
+	Updated  string `json:"updated"`
+	Pages    int    `json:"pages"`
+	Complete bool   `json:"complete"`
+	Next     string `json:"next,omitempty"` // the page to fetch next
+	// The last page fetched, revalidated by its ETag on resuming, and how
+	// many of Repos it added.
+	LastURL   string       `json:"last_url,omitempty"`
+	LastETag  string       `json:"last_etag,omitempty"`
+	LastCount int          `json:"last_count"`
+	Repos     []gitHubRepo `json:"repos"`
+}
+
+// String describes the listing for the log and the resume question.
+func (st *listingState) String() string {
+	if st.Complete {
+		return fmt.Sprintf("complete listing of %s, %d repositories (%s)", st.Org, len(st.Repos), st.Updated)
+	}
+	return fmt.Sprintf("interrupted listing of %s, %d repositories from %d page(s), last updated %s", st.Org, len(st.Repos), st.Pages, st.Updated)
+}
+
+// listingPath is where org's listing is saved.
+func listingPath(org string) string {
+	return filepath.Join(listingsDir, strings.ToLower(org)+".json")
+}
+
+// loadListing reads org's saved listing; nil if there is none.
+func loadListing(org string) (*listingState, error) {
+	data, err := readFileRecover(listingPath(org), func(data []byte) error {
+		var st listingState
+		return json.Unmarshal(data, &st)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st listingState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (st *listingState) save() error {
+	st.Updated = fileTimestamp(time.Now())
+	if err := os.MkdirAll(listingsDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(listingPath(st.Org), append(data, '\n'), 0644)
+}
+
+// addPage records a page fetched from apiURL. Repositories already listed
+// are skipped: one that moved across a page boundary while listing would
+// otherwise appear twice.
+func (st *listingState) addPage(apiURL, etag, next string, page []gitHubRepo) {
+	seen := map[string]bool{}
+	for _, r := range st.Repos {
+		seen[strings.ToLower(r.FullName)] = true
+	}
+	added := 0
+	for _, r := range page {
+		if !seen[strings.ToLower(r.FullName)] {
+			st.Repos = append(st.Repos, r)
+			added++
+		}
+	}
+	st.Pages++
+	st.LastURL, st.LastETag, st.LastCount, st.Next = apiURL, etag, added, next
+}
+
+// listGitHubOrgResumable lists org's repositories like listGitHubRepos,
+// saving the listing after every page. With resume, a saved listing that
+// did not complete is continued instead of started over: its last page is
+// fetched again conditionally on its ETag, and if it changed it is
+// replaced before listing goes on from where it now leads. Pages before it
+// are not checked again. onPage, if set, gets the repositories listed so
+// far after every page, so they can be used while listing goes on.
+func listGitHubOrgResumable(ctx context.Context, org, token string, resume bool, onPage func([]gitHubRepo)) ([]gitHubRepo, error) {
+	st := &listingState{Org: org, Started: fileTimestamp(time.Now())}
+	apiURL := fmt.Sprintf("https://api.github.com/orgs/%s/repos?per_page=100", org)
+	if resume {
+		saved, err := loadListing(org)
+		if err != nil {
+			return nil, fmt.Errorf("reading the saved listing of %s: %v", org, err)
+		}
+		if saved != nil && !saved.Complete && saved.Pages > 0 {
+			st = saved
+			var page []gitHubRepo
+			next, etag, notModified, err := gitHubGetETag(ctx, st.LastURL, token, st.LastETag, &page)
+			if err != nil {
+				return st.Repos, err
+			}
+			if !notModified {
+				st.Repos = st.Repos[:len(st.Repos)-st.LastCount]
+				st.Pages--
+				st.addPage(st.LastURL, etag, next, page)
+			}
+			if err := st.save(); err != nil {
+				return st.Repos, err
+			}
+			if onPage != nil {
+				onPage(st.Repos)
+			}
+			apiURL = st.Next
+		}
+	}
+	for apiURL != "" {
+		var page []gitHubRepo
+		next, etag, _, err := gitHubGetETag(ctx, apiURL, token, "", &page)
+		if err != nil {
+			return st.Repos, err
+		}
+		st.addPage(apiURL, etag, next, page)
+		if err := st.save(); err != nil {
+			return st.Repos, fmt.Errorf("saving the listing of %s: %v", org, err)
+		}
+		if onPage != nil {
+			onPage(st.Repos)
+		}
+		apiURL = next
+	}
+	st.Complete = true
+	if err := st.save(); err != nil {
+		return st.Repos, fmt.Errorf("saving the listing of %s: %v", org, err)
+	}
+	return st.Repos, nil
+}
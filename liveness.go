@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Some proxies swallow git's sideband progress, so a healthy push can show
+// no progress for an hour. Transfers are therefore also watched for signs
+// of life: the bytes git and its helpers (git-remote-https, index-pack)
+// read and write, from /proc on Linux, and elsewhere the growth of the
+// repository directory, pack temp files included. A transfer is only
+// called stalled once progress and those signs have all gone flat.
+
+// livenessConfig are the thresholds of the liveness heuristic.
+type livenessConfig struct {
+	// Quiet is how long without a progress line before the transfer is
+	// shown by the bytes it moves instead.
+	Quiet time.Duration
+	// Stall is how long without progress or bytes moving before the
+	// transfer is shown, and logged, as stalled.
+	Stall time.Duration
+	// Sample is how often the signs of life are sampled.
+	Sample time.Duration
+}
+
+var defaultLiveness = livenessConfig{Quiet: 30 * time.Second, Stall: 10 * time.Minute, Sample: 5 * time.Second}
+
+// String formats the thresholds the way parseLiveness reads them.
+func (c livenessConfig) String() string {
+	return fmt.Sprintf("quiet=%s, stall=%s, sample=%s", c.Quiet, c.Stall, c.Sample)
+}
+
+// parseLiveness parses the liveness thresholds, such as "quiet=1m,
+// stall=30m"; those left out keep their defaults. Blank means
+// GITUI_LIVENESS, and that blank the defaults.
+func parseLiveness(text string) (livenessConfig, error) {
+	c := defaultLiveness
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = os.Getenv("GITUI_LIVENESS")
+	}
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' }) {
+		key, value, ok := strings.Cut(part, "=")
+		d, err := time.ParseDuration(value)
+		if !ok || err != nil || d <= 0 {
+			return c, fmt.Errorf("liveness: %q is not a threshold like quiet=30s", part)
+		}
+		switch key {
+		case "quiet":
+			c.Quiet = d
+		case "stall":
+			c.Stall = d
+		case "sample":
+			c.Sample = d
+		default:
+			return c, fmt.Errorf("liveness: unknown threshold %q (want quiet, stall or sample)", key)
+		}
+	}
+	if c.Stall < c.Quiet {
+		return c, fmt.Errorf("liveness: stall (%s) must not be shorter than quiet (%s)", c.Stall, c.Quiet)
+	}
+	return c, nil
+}
+
+// progressLinePattern matches git's progress lines, such as "Writing
+// objects:  45% (123/456), 1.20 MiB | 1.00 MiB/s".
+var progressLinePattern = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d+)%`)
+
+// parseProgressLine returns what a git progress line says, such as
+// "Writing objects 45%", and whether it is one.
+func parseProgressLine(line string) (string, bool) {
+	m := progressLinePattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s %s%%", m[1], m[2]), true
+}
+
+// transferLiveness tracks one git transfer's signs of life.
+type transferLiveness struct {
+	config livenessConfig
+
+	mu           sync.Mutex
+	started      time.Time
+	progress     string    // the last progress line's gist
+	lastProgress time.Time // when it came
+	counter      int64     // the last sample, -1 if none could be taken
+	moved        int64     // bytes moved, summed over the samples' growth
+	lastActivity time.Time // the last progress line or counter change
+	stalled      bool
+}
+
+func newTransferLiveness(config livenessConfig, now time.Time, counter int64) *transferLiveness {
+	return &transferLiveness{config: config, started: now, counter: counter, lastActivity: now}
+}
+
+// Progress records a progress line of the transfer.
+func (l *transferLiveness) Progress(now time.Time, gist string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.progress, l.lastProgress, l.lastActivity = gist, now, now
+}
+
+// Sample records the transfer's byte counter (see transferCounter); -1
+// means it could not be read. Any change is a sign of life, but only
+// growth counts as bytes moved: the counter drops when a helper process
+// exits and its bytes leave the sum.
+func (l *transferLiveness) Sample(now time.Time, counter int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if counter < 0 {
+		return
+	}
+	if l.counter >= 0 && counter != l.counter {
+		l.lastActivity = now
+		if counter > l.counter {
+			l.moved += counter - l.counter
+		}
+	}
+	l.counter = counter
+}
+
+// State describes the transfer at now: its progress while git reports it;
+// then, if git goes quiet, the bytes it moves; and stalled once neither
+// has changed for config.Stall. newlyStalled is true the first time it is
+// stalled.
+func (l *transferLiveness) State(now time.Time) (state string, newlyStalled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	idle := now.Sub(l.lastActivity)
+	if idle >= l.config.Stall {
+		newlyStalled = !l.stalled
+		l.stalled = true
+		return fmt.Sprintf("stalled, no progress or data moved for %s", formatDuration(idle)), newlyStalled
+	}
+	l.stalled = false
+	if !l.lastProgress.IsZero() && now.Sub(l.lastProgress) < l.config.Quiet {
+		return l.progress, false
+	}
+	if now.Sub(l.started) < l.config.Quiet {
+		return "", false
+	}
+	if l.counter >= 0 {
+		return fmt.Sprintf("transferring (progress unavailable), %s moved", formatMB(l.moved)), false
+	}
+	return "transferring (progress unavailable)", false
+}
+
+// formatMB formats a byte count in MB.
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// transferCounter returns a counter that grows while the git process pid
+// transfers data: on Linux the bytes it and its descendants have read and
+// written, otherwise the size of dir, the repository it works in. It
+// returns -1 if neither can be read.
+func transferCounter(pid int, dir string) int64 {
+	if runtime.GOOS == "linux" {
+		if n, ok := procTreeIO(pid); ok {
+			return n
+		}
+	}
+	if dir == "" {
+		return -1
+	}
+	return dirSize(dir)
+}
+
+// procTreeIO sums rchar and wchar, which count socket traffic as well as
+// file I/O, over pid and its descendants.
+func procTreeIO(pid int) (int64, bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return 0, false
+	}
+	var total int64
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(line, ":")
+		if key == "rchar" || key == "wchar" {
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			total += n
+		}
+	}
+	children, _ := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/children", pid))
+	for _, path := range children {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, field := range strings.Fields(string(data)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				if n, ok := procTreeIO(child); ok {
+					total += n
+				}
+			}
+		}
+	}
+	return total, true
+}
+
+// gitDir returns the repository directory of a git command: the -C
+// directory, or a clone's destination.
+func gitDir(args []string) string {
+	for i, a := range args {
+		if a == "-C" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	if containsString(args, "clone") && len(args) > 0 {
+		return args[len(args)-1]
+	}
+	return ""
+}
+
+// gitWatcher is a git output stream that also watches the git processes
+// streaming to it: runGit calls Watch once git has started, and the
+// returned function once it has exited.
+type gitWatcher interface {
+	Watch(pid int, dir string) (stop func())
+}
+
+// watchLiveness samples the transfer of git process pid working in dir
+// every config.Sample until stop is called, calling report with the state
+// whenever it changes and stalled the first time it stalls.
+func watchLiveness(config livenessConfig, pid int, dir string, l *transferLiveness, report func(state string), stalled func(state string)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(config.Sample)
+		defer ticker.Stop()
+		last := ""
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				l.Sample(now, transferCounter(pid, dir))
+				state, newlyStalled := l.State(now)
+				if state != last {
+					report(state)
+					last = state
+				}
+				if newlyStalled {
+					stalled(state)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
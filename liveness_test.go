@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProcess starts a shell running script, killed when t ends.
+func fakeProcess(t *testing.T, script string) int {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	if err := cmd.Start(); err != nil {
+		t.Skip("no shell to run fake processes:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd.Process.Pid
+}
+
+// The heuristic over time: progress while git reports it, bytes moved once
+// it goes quiet, and stalled, reported once, when neither changes.
+func TestTransferLivenessState(t *testing.T) {
+	config := livenessConfig{Quiet: 30 * time.Second, Stall: 10 * time.Minute, Sample: 5 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	l := newTransferLiveness(config, start, 0)
+
+	check := func(now time.Duration, want string, wantStalled bool) {
+		t.Helper()
+		state, stalled := l.State(at(now))
+		if state != want || stalled != wantStalled {
+			t.Errorf("at %s: State = %q, %v, want %q, %v", now, state, stalled, want, wantStalled)
+		}
+	}
+	check(10*time.Second, "", false)
+	l.Progress(at(15*time.Second), "Writing objects 45%")
+	check(20*time.Second, "Writing objects 45%", false)
+	// Quiet, but bytes keep moving, as behind a proxy that swallows
+	// progress.
+	l.Sample(at(time.Minute), 1<<20)
+	l.Sample(at(5*time.Minute), 3<<20)
+	check(5*time.Minute, "transferring (progress unavailable), 3.0 MB moved", false)
+	// A counter that drops, a helper exiting, is a sign of life but not
+	// bytes moved.
+	l.Sample(at(6*time.Minute), 1<<20)
+	check(15*time.Minute, "transferring (progress unavailable), 3.0 MB moved", false)
+	check(16*time.Minute, "stalled, no progress or data moved for 10m 0s", true)
+	check(17*time.Minute, "stalled, no progress or data moved for 11m 0s", false)
+	// Unreadable samples change nothing.
+	l.Sample(at(18*time.Minute), -1)
+	check(18*time.Minute, "stalled, no progress or data moved for 12m 0s", false)
+	l.Progress(at(19*time.Minute), "Writing objects 90%")
+	check(19*time.Minute, "Writing objects 90%", false)
+}
+
+// Without a counter, a quiet transfer says so without a byte count.
+func TestTransferLivenessNoCounter(t *testing.T) {
+	start := time.Now()
+	l := newTransferLiveness(defaultLiveness, start, -1)
+	if state, _ := l.State(start.Add(time.Minute)); state != "transferring (progress unavailable)" {
+		t.Errorf("State = %q, want the transfer without a byte count", state)
+	}
+}
+
+// A busy process's counter grows, its children's I/O included; an idle
+// one's stays flat.
+func TestTransferCounterFakeProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("byte counters come from /proc")
+	}
+	idle := fakeProcess(t, "sleep 60")
+	busy := fakeProcess(t, "while :; do head -c 65536 /dev/zero | cat >/dev/null; done")
+	sample := func(pid int) int64 {
+		n := transferCounter(pid, "")
+		if n < 0 {
+			t.Fatalf("transferCounter(%d) = %d, want a counter", pid, n)
+		}
+		return n
+	}
+	// Starting up reads the shell and sleep themselves in.
+	time.Sleep(200 * time.Millisecond)
+	idleBefore, busyBefore := sample(idle), sample(busy)
+	time.Sleep(200 * time.Millisecond)
+	if n := sample(idle); n != idleBefore {
+		t.Errorf("the idle process's counter went from %d to %d", idleBefore, n)
+	}
+	if n := sample(busy); n <= busyBefore {
+		t.Errorf("the busy process's counter went from %d to %d, want growth", busyBefore, n)
+	}
+	if n := transferCounter(1<<30, ""); n != -1 {
+		t.Errorf("transferCounter of no process and no directory = %d, want -1", n)
+	}
+}
+
+// Watching fake processes: the idle one is reported stalled once, the
+// busy one as moving bytes and never stalled.
+func TestWatchLivenessFakeProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("byte counters come from /proc")
+	}
+	config := livenessConfig{Quiet: 50 * time.Millisecond, Stall: 300 * time.Millisecond, Sample: 20 * time.Millisecond}
+	watch := func(script string) (states []string, stalls int) {
+		pid := fakeProcess(t, script)
+		var mu sync.Mutex
+		now := time.Now()
+		l := newTransferLiveness(config, now, transferCounter(pid, ""))
+		stop := watchLiveness(config, pid, "", l,
+			func(state string) { mu.Lock(); states = append(states, state); mu.Unlock() },
+			func(string) { mu.Lock(); stalls++; mu.Unlock() })
+		time.Sleep(time.Second)
+		stop()
+		mu.Lock()
+		defer mu.Unlock()
+		return states, stalls
+	}
+
+	states, stalls := watch("sleep 60")
+	if stalls != 1 {
+		t.Errorf("idle process: %d stalls reported, want 1 (states %q)", stalls, states)
+	}
+	if len(states) == 0 || !strings.HasPrefix(states[len(states)-1], "stalled") {
+		t.Errorf("idle process: states %q, want it to end stalled", states)
+	}
+
+	states, stalls = watch("while :; do head -c 65536 /dev/zero | cat >/dev/null; done")
+	if stalls != 0 {
+		t.Errorf("busy process: %d stalls reported, want none (states %q)", stalls, states)
+	}
+	if len(states) == 0 || !strings.HasSuffix(states[len(states)-1], "MB moved") {
+		t.Errorf("busy process: states %q, want bytes moved", states)
+	}
+}
+
+func TestParseLiveness(t *testing.T) {
+	t.Setenv("GITUI_LIVENESS", "")
+	c, err := parseLiveness("quiet=1m, stall=30m")
+	if err != nil || c.Quiet != time.Minute || c.Stall != 30*time.Minute || c.Sample != defaultLiveness.Sample {
+		t.Errorf("parseLiveness = %v, %v", c, err)
+	}
+	for _, bad := range []string{"quiet", "quiet=-1s", "speed=1s", "quiet=20m"} {
+		if _, err := parseLiveness(bad); err == nil {
+			t.Errorf("parseLiveness(%q) succeeded, want an error", bad)
+		}
+	}
+}
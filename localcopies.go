@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// copyMode is what becomes of a repository's local copy, its bare clone,
+// once the repository is migrated and verified. Unverified copies are kept
+// where they are until verified or deleted by hand, whatever the mode, and
+// failed ones are kept, or not, by the failure clone settings.
+type copyMode int
+
+const (
+	// copiesDiscard deletes the copy.
+	copiesDiscard copyMode = iota
+	// copiesRunDir keeps it in the directory the app runs in.
+	copiesRunDir
+	// copiesArchive keeps it in the archive directory.
+	copiesArchive
+)
+
+var copyModeNames = []string{"Discard", "Keep in run directory", "Keep in archive directory"}
+
+// copyModeFlags are the modes as the --local-copies flag spells them.
+var copyModeFlags = []string{"discard", "run", "archive"}
+
+// defaultCopiesDir is the archive directory unless configured.
+const defaultCopiesDir = "local-copies"
+
+// legacyClonesDir is where copies were saved before local copies had a
+// mode; failedClonesDir, within it, is not a copy.
+const legacyClonesDir = "clones"
+
+// localCopies decides where repositories' local copies are kept.
+type localCopies struct {
+	Mode copyMode
+	Dir  string // the archive directory; blank means defaultCopiesDir
+}
+
+// parseCopyMode parses a --local-copies value.
+func parseCopyMode(text string) (copyMode, error) {
+	for i, name := range copyModeFlags {
+		if strings.EqualFold(strings.TrimSpace(text), name) {
+			return copyMode(i), nil
+		}
+	}
+	return copiesDiscard, fmt.Errorf("local copies: unknown mode %q (want %s)", text, strings.Join(copyModeFlags, ", "))
+}
+
+// String describes the setting, for the log and support bundles.
+func (c localCopies) String() string {
+	if c.Mode == copiesArchive {
+		return fmt.Sprintf("%s (%s)", copyModeNames[c.Mode], orDefault(c.Dir, defaultCopiesDir))
+	}
+	return copyModeNames[c.Mode]
+}
+
+// Path returns where repo's copy is kept, or "" if copies are discarded:
+// owner_name.git in the run or archive directory.
+func (c localCopies) Path(repo string) string {
+	name := strings.ReplaceAll(repo, "/", "_") + ".git"
+	switch c.Mode {
+	case copiesRunDir:
+		return name
+	case copiesArchive:
+		return filepath.Join(orDefault(c.Dir, defaultCopiesDir), name)
+	}
+	return ""
+}
+
+// Keep moves the copy in dir to where repo's copy is kept, replacing an
+// older one, and returns the path; under copiesDiscard it deletes it and
+// returns "".
+func (c localCopies) Keep(dir, repo string) (string, error) {
+	dest := c.Path(repo)
+	if dest == "" {
+		return "", os.RemoveAll(dir)
+	}
+	if filepath.Clean(dir) == filepath.Clean(dest) {
+		return dest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	os.RemoveAll(dest)
+	if err := os.Rename(dir, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// copySource returns the repository, "owner/name", a copy in dir was
+// cloned from GitHub, or "" if it is not such a copy.
+func copySource(ctx context.Context, dir string) string {
+	out, err := runGit(ctx, nil, "-C", dir, "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(strings.TrimSpace(out))
+	if err != nil || !strings.EqualFold(u.Host, "github.com") {
+		return ""
+	}
+	repo := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if strings.Count(repo, "/") != 1 {
+		return ""
+	}
+	return repo
+}
+
+// legacyCopies finds the copies the two old flows left: the window's under
+// clones/ (failure clones aside), and the classic window's name.git mirrors
+// in the run directory. It maps their directories to their repositories.
+func legacyCopies(ctx context.Context) map[string]string {
+	found := map[string]string{}
+	if entries, err := os.ReadDir(legacyClonesDir); err == nil {
+		for _, e := range entries {
+			dir := filepath.Join(legacyClonesDir, e.Name())
+			if !e.IsDir() || dir == failedClonesDir {
+				continue
+			}
+			if repo := copySource(ctx, dir); repo != "" {
+				found[dir] = repo
+			}
+		}
+	}
+	mirrors, _ := filepath.Glob("*.git")
+	for _, dir := range mirrors {
+		if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+			continue
+		}
+		// Copies already in the new layout stay.
+		if repo := copySource(ctx, dir); repo != "" && dir != strings.ReplaceAll(repo, "/", "_")+".git" {
+			found[dir] = repo
+		}
+	}
+	return found
+}
+
+// migrateLegacyCopies moves the copies the old flows left into the layout
+// of c, or the archive directory if c discards copies, as nothing kept by
+// hand should be deleted. Each move is logged and recorded in the
+// migration state, so retries find the copies.
+func migrateLegacyCopies(ctx context.Context, c localCopies, logMsg func(string)) {
+	legacy := legacyCopies(ctx)
+	if len(legacy) == 0 {
+		return
+	}
+	if c.Mode == copiesDiscard {
+		c.Mode = copiesArchive
+	}
+	logMsg(fmt.Sprintf("Moving the local copies earlier versions left (%d) to %s:", len(legacy), c))
+	for dir, repo := range legacy {
+		dest := c.Path(repo)
+		if _, err := os.Stat(dest); err == nil {
+			logMsg(fmt.Sprintf("  %s (%s): left in place, %s already exists.", dir, repo, dest))
+			continue
+		}
+		if _, err := c.Keep(dir, repo); err != nil {
+			logMsg(fmt.Sprintf("  %s (%s): could not move it: %v", dir, repo, err))
+			continue
+		}
+		logMsg(fmt.Sprintf("  %s -> %s (%s)", dir, dest, repo))
+		if err := recordCopy(repo, dest); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not record the copy of %s in %s: %v", repo, migrationStatePath, err))
+		}
+	}
+	if entries, err := os.ReadDir(legacyClonesDir); err == nil && len(entries) == 0 {
+		os.Remove(legacyClonesDir)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Built-in file log formats, written by slog's text and JSON handlers.
+// Anything else is a text/template over logRecord, e.g.
+//
+//	{{.Time.Format "2006-01-02T15:04:05Z07:00"}} level={{.Level}} repo={{.Repo}} msg={{.Message}}
+const (
+	logFormatLogfmt = "logfmt"
+	logFormatJSON   = "json"
+)
+
+// The log file is rotated once it reaches logMaxSize, keeping logBackups
+// (at least one) old files beside it as path.1, the newest, to path.N.
+const (
+	logMaxSize = 10 << 20 // bytes
+	logBackups = 5
+)
+
+// logRecord is one log line with its structured fields. Repo and Phase are
+// empty outside a repository's migration.
+type logRecord struct {
+	Time    time.Time
+	Level   string
+	Repo    string
+	Phase   string
+	Message string
+}
+
+// logLevel infers the level of a log message from its wording.
+func logLevel(msg string) string {
+	switch {
+	case strings.HasPrefix(msg, "Error"), strings.HasPrefix(msg, "Failed"):
+		return "ERROR"
+	case strings.HasPrefix(msg, "Warning"):
+		return "WARN"
+	case strings.HasPrefix(msg, "Debug"):
+		return "DEBUG"
+	}
+	return "INFO"
+}
+
+// logLevelNames are the log levels as the window offers them, from the
+// most verbose; logLevels are their slog levels.
+var (
+	logLevelNames = []string{"Debug", "Info", "Warning", "Error"}
+	logLevels     = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+)
+
+// parseLogLevel returns the slog level named level: debug, info, warn or
+// error, in any case.
+func parseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", level)
+	}
+	return l, nil
+}
+
+// messageLevel is the slog level of a log message.
+func messageLevel(msg string) slog.Level {
+	l, _ := parseLogLevel(logLevel(msg))
+	return l
+}
+
+// logFormatter renders a record as one line, without the newline.
+type logFormatter func(r logRecord) (string, error)
+
+// parseLogFormat returns the formatter for spec: nil for "logfmt" (the
+// default) and "json", which slog writes, or a template's. Templates are
+// executed once against a sample record, so mistakes such as unknown
+// fields are reported here rather than at the first write.
+func parseLogFormat(spec string) (logFormatter, error) {
+	switch strings.TrimSpace(spec) {
+	case "", logFormatLogfmt, logFormatJSON:
+		return nil, nil
+	}
+
+	tmpl, err := template.New("log").Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log format: %v", err)
+	}
+	format := func(r logRecord) (string, error) {
+		var b strings.Builder
+		err := tmpl.Execute(&b, r)
+		return strings.TrimRight(b.String(), "\n"), err
+	}
+	sample := logRecord{Time: time.Now(), Level: "INFO", Repo: "owner/repo", Phase: "clone", Message: "sample"}
+	if _, err := format(sample); err != nil {
+		return nil, fmt.Errorf("invalid log format: %v", err)
+	}
+	return format, nil
+}
+
+func formatLogfmt(r logRecord) (string, error) {
+	parts := []string{
+		"time=" + fileTimestamp(r.Time),
+		"level=" + r.Level,
+	}
+	if r.Repo != "" {
+		parts = append(parts, "repo="+logfmtValue(r.Repo))
+	}
+	if r.Phase != "" {
+		parts = append(parts, "phase="+logfmtValue(r.Phase))
+	}
+	parts = append(parts, "msg="+logfmtValue(r.Message))
+	return strings.Join(parts, " "), nil
+}
+
+// logfmtValue quotes v if it would otherwise not parse as a single value.
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\"=\n") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// logScope tracks the repository and phase the migration is in, so log
+// lines can carry them without every message spelling them out.
+type logScope struct {
+	mu    sync.Mutex
+	repo  string
+	phase string
+	// notify, if set, is told of every phase a repository enters.
+	notify func(repo, phase string)
+}
+
+// Set enters repo's phase; an empty repo leaves the repository scope.
+func (s *logScope) Set(repo, phase string) {
+	s.mu.Lock()
+	s.repo, s.phase = repo, phase
+	s.mu.Unlock()
+	s.changed(repo, phase)
+}
+
+// Phase moves the current repository on to phase.
+func (s *logScope) Phase(phase string) {
+	s.mu.Lock()
+	s.phase = phase
+	repo := s.repo
+	s.mu.Unlock()
+	s.changed(repo, phase)
+}
+
+func (s *logScope) changed(repo, phase string) {
+	if s.notify != nil && repo != "" {
+		s.notify(repo, phase)
+	}
+}
+
+func (s *logScope) Get() (repo, phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repo, s.phase
+}
+
+// fileLog appends records of at least its level to a rotating log file,
+// through slog's handler for the built-in formats or a template's
+// formatter. A nil *fileLog discards everything.
+type fileLog struct {
+	mu      sync.Mutex
+	out     *rotatingFile
+	handler slog.Handler // nil for a template
+	format  logFormatter
+	min     slog.Level
+}
+
+// openFileLog opens the log file at path, writing records of level min
+// and above in the format spec names.
+func openFileLog(path, spec string, min slog.Level) (*fileLog, error) {
+	format, err := parseLogFormat(spec)
+	if err != nil {
+		return nil, err
+	}
+	out, err := openRotatingFile(path, logMaxSize, logBackups)
+	if err != nil {
+		return nil, err
+	}
+	l := &fileLog{out: out, format: format, min: min}
+	if format == nil {
+		opts := &slog.HandlerOptions{
+			Level: min,
+			// Timestamps as the rest of the tool's files have them.
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.String(slog.TimeKey, fileTimestamp(a.Value.Time()))
+				}
+				return a
+			},
+		}
+		if strings.TrimSpace(spec) == logFormatJSON {
+			l.handler = slog.NewJSONHandler(out, opts)
+		} else {
+			l.handler = slog.NewTextHandler(out, opts)
+		}
+	}
+	return l, nil
+}
+
+func (l *fileLog) Write(r logRecord) {
+	if l == nil {
+		return
+	}
+	level, _ := parseLogLevel(r.Level)
+	if level < l.min {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handler != nil {
+		rec := slog.NewRecord(r.Time, level, r.Message, 0)
+		if r.Repo != "" {
+			rec.AddAttrs(slog.String("repo", r.Repo))
+		}
+		if r.Phase != "" {
+			rec.AddAttrs(slog.String("phase", r.Phase))
+		}
+		l.handler.Handle(context.Background(), rec)
+		return
+	}
+	line, err := l.format(r)
+	if err != nil {
+		// Fall back to logfmt rather than lose the line.
+		line, _ = formatLogfmt(r)
+	}
+	fmt.Fprintln(l.out, line)
+}
+
+func (l *fileLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.out.Close()
+}
+
+// rotatingFile is a log file that, once a write would take it past max
+// bytes, is renamed to path.1, the older ones moved up to path.N, and
+// started anew.
+type rotatingFile struct {
+	path    string
+	max     int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, max int64, backups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, max: max, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its
+// maximum.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.max {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
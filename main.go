@@ -1,10 +1,15 @@
+//go:build classic && !nogui
+
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -12,87 +17,502 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-func migrateRepo(gitHubOrg, adoOrg, adoProject, repoName, gitPat, adoPat string, deleteAfter bool, logBox *widget.Label) {
-	logMsg := func(msg string) {
-		logBox.SetText(logBox.Text + "\n" + msg)
+// migrateRepo migrates one repository of gitHubOrg into target's project.
+func migrateRepo(ctx context.Context, gitHubOrg string, target *azureTarget, repoName, gitPat string, copies localCopies, lfs lfsPolicy, archive archiveMode, secrets secretPolicy, confirmSecrets func(string, []secretFinding) bool, retained *retainedCopies, logMsg func(string), scope *logScope, tail *repoTail) (status, problem string) {
+	start := time.Now()
+	status = statusFailed
+	defer func() {
+		scope.Set("", "")
+		tail.Finish(status)
+	}()
+	// fail logs why the repository failed and keeps it for the progress
+	// table.
+	fail := func(msg string) {
+		problem = msg
+		logMsg(msg)
 	}
-
 	logMsg(fmt.Sprintf("Migrating repository: %s", repoName))
+	scope.Set(repoName, "clone")
 
 	// Clone the GitHub repository locally
-	cmd := exec.Command("git", "clone", "--mirror", fmt.Sprintf("https://%s@github.com/%s/%s.git", gitPat, gitHubOrg, repoName))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	dirName := fmt.Sprintf("%s.git", repoName)
+
+	// The git commands run under ctx, so cancelling the run kills them;
+	// a cancelled repository's local mirror is deleted.
+	cancelled := func() bool {
+		if ctx.Err() == nil {
+			return false
+		}
+		status = statusCancelled
+		os.RemoveAll(dirName)
+		logMsg(fmt.Sprintf("Cancelled %s, local repository deleted", repoName))
+		return true
+	}
+	source := gitHubOrg + "/" + repoName
+	output, err := runGitTransfer(ctx, "github.com", tail, "clone", "--mirror", "--progress", fmt.Sprintf("https://%s@github.com/%s.git", gitPat, source), dirName)
+	if err != nil && repoNotFound(output) {
+		// It may have been transferred or deleted since it was listed.
+		current, rerr := resolveMovedRepo(ctx, source, gitPat)
+		switch {
+		case rerr == errSourceRemoved:
+			status = statusSourceRemoved
+			fail(fmt.Sprintf("%s no longer exists on GitHub, it was removed after planning", source))
+			return
+		case rerr == nil && !strings.EqualFold(current, source):
+			logMsg(fmt.Sprintf("%s has moved to %s on GitHub, cloning it from there", source, current))
+			source = current
+			output, err = runGitTransfer(ctx, "github.com", tail, "clone", "--mirror", "--progress", fmt.Sprintf("https://%s@github.com/%s.git", gitPat, source), dirName)
+		}
+	}
+	if cancelled() {
+		return
+	}
 	if err != nil {
-		logMsg(fmt.Sprintf("Failed to clone repository: %s", err))
+		fail(fmt.Sprintf("Failed to clone repository: %s", err))
 		return
 	}
 
-	dirName := fmt.Sprintf("%s.git", repoName)
-	cmd = exec.Command("git", "-C", dirName, "remote", "add", "azure-devops", fmt.Sprintf("https://%s@dev.azure.com/%s/%s/_git/%s", adoPat, adoOrg, adoProject, repoName))
-	err = cmd.Run()
+	// Scan the history for secrets before anything is pushed.
+	scope.Phase("secret-scan")
+	_, _, proceed := secretStep(dirName, repoName, secrets, confirmSecrets, logMsg)
+	if cancelled() {
+		return
+	}
+	if !proceed {
+		status = statusSecretsBlocked
+		fail(fmt.Sprintf("Skipping %s: secrets found in history", repoName))
+		os.RemoveAll(dirName)
+		return
+	}
+
+	// Create the ADO repo. An existing one is only pushed into if a run
+	// of this tool created it or it is empty, as in the main window; the
+	// mirror push would delete the refs of anyone else's.
+	scope.Phase("create")
+	pushURL, err := target.CreateRepo(ctx, repoName)
+	if cancelled() {
+		return
+	}
 	if err != nil {
-		logMsg(fmt.Sprintf("Failed to add Azure DevOps remote: %s", err))
+		fail(fmt.Sprintf("Failed to create Azure DevOps repo %s: %s", repoName, err))
 		return
 	}
+	logMsg(fmt.Sprintf("Created ADO repo %s", finalRepoName(pushURL, repoName)))
 
-	cmd = exec.Command("git", "-C", dirName, "push", "--mirror", "azure-devops")
-	err = cmd.Run()
+	_, err = runGit(ctx, tail, "-C", dirName, "remote", "add", "azure-devops", pushURL)
+	if cancelled() {
+		return
+	}
 	if err != nil {
-		logMsg(fmt.Sprintf("Failed to push repository: %s", err))
+		fail(fmt.Sprintf("Failed to add Azure DevOps remote: %s", err))
 		return
 	}
 
-	if deleteAfter {
+	// Migrate LFS objects before the refs that point at them.
+	scope.Phase("lfs")
+	err = lfsStep(ctx, dirName, "azure-devops", source, gitPat, lfs, tail, logMsg)
+	if cancelled() {
+		return
+	}
+	if err != nil {
+		fail(fmt.Sprintf("Failed to migrate LFS objects: %s", err))
+		return
+	}
+
+	// A mirror push reports each ref. Failed branches fail the repository;
+	// failed tags or notes only warn, since the branches landed.
+	scope.Phase("push")
+	refs, _, err := pushRefs(ctx, dirName, "azure-devops", tail, "--mirror")
+	if cancelled() {
+		return
+	}
+	failed := failedRefs(refs)
+	if err != nil {
+		if len(refs) == 0 {
+			fail(fmt.Sprintf("Failed to push repository: %s", err))
+			return
+		}
+		for _, ref := range failed {
+			if isHeadRef(ref) {
+				fail(fmt.Sprintf("Failed to push repository: %s did not push", ref))
+				return
+			}
+		}
+		logMsg(fmt.Sprintf("Warning: %s of %s did not push", strings.Join(failed, ", "), repoName))
+	}
+
+	// Verify before anything is changed or deleted; the local mirror is the
+	// cheapest way to re-push whatever did not arrive.
+	scope.Phase("verify")
+	verified := verifyStep(ctx, dirName, "azure-devops", repoName, failed, logMsg)
+	if cancelled() {
+		return
+	}
+	// The mirror may be behind GitHub by now; compare with GitHub itself.
+	leftOut := func(ref string) bool { return containsString(failed, ref) }
+	if mismatches, ok := sourceVerifyStep(ctx, dirName, "origin", "azure-devops", repoName, leftOut, logMsg); ok && len(mismatches) > 0 {
+		problem = "Refs differ from GitHub: " + strings.Join(mismatches, "; ")
+		verified = false
+	}
+
+	scope.Phase("finalize")
+	meta, err := getGitHubRepo(ctx, source, gitPat)
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not fetch GitHub metadata for %s: %s", repoName, err))
+	} else {
+		// Use the GitHub-reported default branch, which need not be main or
+		// master. Empty repositories have none yet.
+		if meta.DefaultBranch != "" {
+			err = target.SetDefaultBranch(ctx, repoName, meta.DefaultBranch)
+			if err != nil {
+				logMsg(fmt.Sprintf("Warning: default branch for %s not set: %s", repoName, err))
+			} else {
+				logMsg(fmt.Sprintf("Default branch for %s set to %s", repoName, meta.DefaultBranch))
+			}
+		}
+
+		// Make archived repos read-only in ADO too. This comes last because a
+		// disabled repo can no longer be updated, and only once verified
+		// because an unverified repo may need a re-push.
+		if meta.Archived && verified {
+			state, err := target.MakeReadOnly(ctx, repoName, archive)
+			if err != nil {
+				logMsg(fmt.Sprintf("Warning: could not make archived repo %s read-only: %s", repoName, err))
+			} else {
+				logMsg(fmt.Sprintf("%s is archived on GitHub, ADO repo %s", repoName, state))
+			}
+		}
+	}
+
+	switch {
+	case copies.Mode != copiesDiscard:
+		status = statusMigrated
+		if !verified {
+			status = statusUnverified
+		} else if len(failed) > 0 {
+			status = statusWarnings
+		}
+		dest, err := copies.Keep(dirName, source)
+		if err != nil {
+			dest = dirName
+			logMsg(fmt.Sprintf("Warning: could not move the local repository of %s to %s: %s", repoName, copies.Path(source), err))
+		}
+		if err := recordCopy(source, dest); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not record the local repository of %s in %s: %s", repoName, migrationStatePath, err))
+		}
+		if verified {
+			logMsg(fmt.Sprintf("Successfully migrated repository: %s, local repository kept in %s (%s)", repoName, dest, formatDuration(time.Since(start))))
+		} else {
+			logMsg(fmt.Sprintf("Migrated %s but could not verify it, local repository kept in %s (%s)", repoName, dest, formatDuration(time.Since(start))))
+		}
+	case !verified:
+		status = statusRetained
+		retained.Add(repoName, dirName)
+		logMsg(fmt.Sprintf("Migrated %s but kept local repository %s pending verification (%s)", repoName, dirName, formatDuration(time.Since(start))))
+	default:
 		err = os.RemoveAll(dirName)
 		if err != nil {
-			logMsg(fmt.Sprintf("Failed to delete repository: %s", err))
+			status = statusMigrated
+			fail(fmt.Sprintf("Failed to delete repository: %s", err))
 			return
 		}
-		logMsg("Successfully migrated and deleted local repository: " + repoName)
+		status = statusCleanedUp
+		if len(failed) > 0 {
+			status = statusWarnings
+		}
+		logMsg(fmt.Sprintf("Successfully migrated and deleted local repository: %s (%s)", repoName, formatDuration(time.Since(start))))
+	}
+	return
+}
+
+// previewRepos logs what migrating repos would do, the way the main
+// window's dry run does: each repository's size as GitHub reports it, the
+// repository that would be created and whether its name is taken, and the
+// default branch and archived state that would be applied. Nothing is
+// cloned, created or pushed.
+func previewRepos(ctx context.Context, gitHubOrg string, target *azureTarget, repos []string, gitPat string, archive archiveMode, logMsg func(string)) {
+	run := &migrationRun{
+		GitHubToken: gitPat,
+		Source:      &gitHubSource{org: gitHubOrg, token: gitPat},
+		Target:      target,
+		Archive:     archive,
+		Splits:      &splitPlans{},
+		DryRun:      true,
+	}
+	logMsg("Dry run: nothing will be created, cloned or pushed.")
+	if list, err := listAzureRepos(ctx, target.org, target.project, target.token); err != nil {
+		logMsg(fmt.Sprintf("Warning: could not list the repositories of %s, collisions with them are not checked: %v", target.project, err))
 	} else {
-		logMsg("Successfully migrated repository: " + repoName)
+		run.Existing = targetRepoNames(list)
+	}
+	report := &runReport{}
+	for _, repo := range repos {
+		source := gitHubOrg + "/" + repo
+		result := &repoReport{Source: source, Target: repo, Status: statusDryRun}
+		report.Repos = append(report.Repos, result)
+		meta, err := getGitHubRepo(ctx, source, gitPat)
+		if err != nil {
+			logMsg(fmt.Sprintf("Warning: could not fetch GitHub metadata for %s: %v", repo, err))
+			meta = nil
+		}
+		result.Reason = run.dryRun(source, meta, result, logMsg)
+	}
+	for _, line := range dryRunSummary(report) {
+		logMsg(line)
 	}
 }
 
+// main opens the classic window, which is built with -tags classic; the
+// main window is new.go's.
 func main() {
 	myApp := app.New()
 	myWindow := myApp.NewWindow("GitHub to ADO Migrator")
 	myWindow.Resize(fyne.NewSize(600, 400))
+	// Listings, previews and runs stop when the window closes.
+	windowCtx, closeWindow := context.WithCancel(context.Background())
 
 	logBox := widget.NewLabel("Logs:")
 
+	// Widget updates are batched so high log volume doesn't stall the UI.
+	ui := newUIBatcher(uiRefreshHz())
+	tails := newTailView(myWindow, ui)
+	// Each repository's state, phase, transfer, time and outcome, so a
+	// large run can be followed without reading the log.
+	progress := newProgressView(ui)
+	retained := &retainedCopies{}
+	logs := newLogModel(ui, func(text string) {
+		logBox.SetText("Logs:\n" + text)
+	})
+	clock := &logClock{}
+	logMsg := func(msg string) {
+		logs.Append(messageLevel(msg), fmt.Sprintf("[%s] %s", clock.Stamp(time.Now()), redactLog(msg)))
+	}
+	tails.SetLiveness(defaultLiveness, progress.Transfer, func(repo, state string) {
+		logMsg(fmt.Sprintf("Warning: the git transfer of %s is %s; cancel the run if it does not recover.", repo, state))
+	})
+	setGitHubRateLimitNotify(func(resource string, until time.Time) {
+		logMsg(gitHubRateLimitMessage(resource, until))
+		progress.RateLimit(resource, until)
+	})
+
 	gitHubOrg := widget.NewEntry()
 	adoOrg := widget.NewEntry()
 	adoProject := widget.NewEntry()
-	repoList := widget.NewEntry()
 	gitPat := widget.NewPasswordEntry()
 	adoPat := widget.NewPasswordEntry()
-	deleteAfter := widget.NewCheck("Don't Save (Delete after Migration)", nil)
+	// Which of the org's repositories are listed, by visibility.
+	visibilitySelect := widget.NewSelect(visibilityNames, nil)
+	visibilitySelect.SetSelectedIndex(0)
+	// The repositories to migrate, checked from the org's listing; the
+	// listing is saved as it goes, like the main window's.
+	picker := newRepoPicker(func(onPage func([]string)) ([]string, error) {
+		org := strings.TrimSpace(gitHubOrg.Text)
+		if org == "" {
+			return nil, fmt.Errorf("enter the GitHub org first")
+		}
+		visibility := repoVisibilities[visibilitySelect.SelectedIndex()]
+		logMsg(fmt.Sprintf("Fetching repositories from %s...", org))
+		repos, err := listGitHubOrgResumable(windowCtx, org, strings.TrimSpace(gitPat.Text), false, func(repos []gitHubRepo) {
+			onPage(repoNames(repos, visibility))
+		})
+		return repoNames(repos, visibility), err
+	}, logMsg)
+	// Local copies work as in the main window; the archive directory is
+	// its default.
+	copiesSelect := widget.NewSelect(copyModeNames, nil)
+	copiesSelect.SetSelectedIndex(int(copiesArchive))
+	migrateLegacyCopies(context.Background(), localCopies{Mode: copiesArchive}, logMsg)
+	lfsMissingSelect := widget.NewSelect(lfsPolicyNames, nil)
+	lfsMissingSelect.SetSelectedIndex(int(lfsFailOnMissing))
+	archiveSelect := widget.NewSelect(archiveModeNames, nil)
+	archiveSelect.SetSelectedIndex(int(archiveDisable))
+	secretsSelect := widget.NewSelect(secretPolicyNames, nil)
+	secretsSelect.SetSelectedIndex(int(secretsReportOnly))
+	showUTC := widget.NewCheck("Show UTC in log", func(checked bool) {
+		clock.SetUTC(checked)
+	})
+
+	// How many repositories are migrated at the same time; the rest wait
+	// in the queue.
+	concurrencyEntry := widget.NewEntry()
+	concurrencyEntry.SetPlaceHolder(strconv.Itoa(defaultConcurrency))
+	concurrencyEntry.Validator = func(text string) error {
+		_, err := parseConcurrency(text)
+		return err
+	}
 
-	migrateButton := widget.NewButton("Migrate", func() {
-		repos := strings.Split(repoList.Text, ",")
-		for _, repo := range repos {
-			go migrateRepo(strings.TrimSpace(gitHubOrg.Text), strings.TrimSpace(adoOrg.Text), strings.TrimSpace(adoProject.Text), strings.TrimSpace(repo), strings.TrimSpace(gitPat.Text), strings.TrimSpace(adoPat.Text), deleteAfter.Checked, logBox)
+	// A dry run only reports what Migrate would do.
+	dryRun := widget.NewCheck("Dry run (preview only: nothing is cloned, created or pushed)", nil)
+
+	keepAwake := widget.NewCheck("Prevent sleep while migrating", nil)
+	keepAwake.SetChecked(true)
+	polite := widget.NewCheck("Polite mode (one clone or push per host at a time)", nil)
+	sleepIndicator := widget.NewLabel("Sleep prevented while migrating")
+	sleepIndicator.Hide()
+
+	var runMu sync.Mutex
+	var cancelRun context.CancelFunc // set while a run is active
+	var migrateButton, cancelButton *widget.Button
+	migrateButton = widget.NewButton("Migrate", func() {
+		repos := picker.Selected()
+		if len(repos) == 0 {
+			logMsg("Error: no repositories are checked.")
+			return
 		}
+		concurrency, err := parseConcurrency(concurrencyEntry.Text)
+		if err != nil {
+			logMsg(fmt.Sprintf("Error: %v", err))
+			return
+		}
+		// The organization is taken as a name or a URL, like the main
+		// window's, with legacy visualstudio.com URLs translated.
+		adoOrgURL, note, err := normalizeAzureOrg(adoOrg.Text)
+		if err != nil {
+			logMsg(fmt.Sprintf("Error: %v", err))
+			return
+		}
+		if note != "" {
+			logMsg(note + ".")
+		}
+		target := &azureTarget{org: adoOrgURL, project: strings.TrimSpace(adoProject.Text), token: strings.TrimSpace(adoPat.Text), logMsg: logMsg}
+		runMu.Lock()
+		if cancelRun != nil {
+			runMu.Unlock()
+			logMsg("Error: a migration is already running.")
+			return
+		}
+		ctx, cancel := context.WithCancel(windowCtx)
+		cancelRun = cancel
+		runMu.Unlock()
+		migrateButton.Disable()
+		cancelButton.Enable()
+		finish := func() {
+			runMu.Lock()
+			cancelRun = nil
+			runMu.Unlock()
+			cancel()
+			migrateButton.Enable()
+			cancelButton.Disable()
+		}
+		// The preview's API calls stop on Cancel too.
+		if dryRun.Checked {
+			go func() {
+				defer finish()
+				previewRepos(ctx, strings.TrimSpace(gitHubOrg.Text), target, repos, strings.TrimSpace(gitPat.Text), archiveMode(archiveSelect.SelectedIndex()), logMsg)
+				if ctx.Err() != nil {
+					logMsg("Dry run cancelled.")
+				}
+			}()
+			return
+		}
+		// The repositories the run creates are recorded under its start
+		// time, the way the main window records them under its report ID.
+		target.runID = time.Now().UTC().Format("20060102T150405Z")
+
+		logMsg("Run timestamps: " + zoneSummary(time.Now()))
+		transfers.SetPolite(polite.Checked)
+		logMsg(transfers.Describe())
+
+		// Hold the sleep inhibitor until every repository is done.
+		var wg sync.WaitGroup
+		wg.Add(len(repos))
+		if keepAwake.Checked {
+			if release, err := inhibitSleep("Migrating repositories"); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not prevent sleep: %s", err))
+			} else {
+				sleepIndicator.Show()
+				go func() {
+					wg.Wait()
+					release()
+					sleepIndicator.Hide()
+				}()
+			}
+		}
+		// A few repositories at a time; each runs git clones and pushes.
+		logMsg(fmt.Sprintf("Migrating %d repositories, %d at a time.", len(repos), concurrency))
+		progress.Start(repos)
+		m := &migrator{Migrate: func(ctx context.Context, repo string) {
+			defer wg.Done()
+			repoStart := time.Now()
+			scope := &logScope{notify: progress.Phase}
+			status, problem := migrateRepo(ctx, strings.TrimSpace(gitHubOrg.Text), target, repo, strings.TrimSpace(gitPat.Text), localCopies{Mode: copyMode(copiesSelect.SelectedIndex())}, lfsPolicy(lfsMissingSelect.SelectedIndex()), archiveMode(archiveSelect.SelectedIndex()), secretPolicy(secretsSelect.SelectedIndex()), confirmSecretsDialog(myWindow), retained, logMsg, scope, tails.Start(repo))
+			if problem != "" {
+				progress.SetError(repo, problem)
+			}
+			progress.Finish(repo, status, time.Since(repoStart))
+		}}
+		m.Cancelled = func(repo string) {
+			defer wg.Done()
+			logMsg(fmt.Sprintf("Cancelled %s before it started", repo))
+			progress.Finish(repo, statusCancelled, 0)
+		}
+		go func() {
+			m.Run(ctx, repos, concurrency)
+			if ctx.Err() != nil {
+				logMsg("Migration cancelled.")
+			}
+			finish()
+		}()
+	})
+	// Cancel kills the git commands in flight, deletes their local
+	// repositories and leaves the queued repositories unstarted.
+	cancelButton = widget.NewButton("Cancel", func() {
+		runMu.Lock()
+		if cancelRun != nil {
+			cancelRun()
+			logMsg("Cancelling the migration...")
+		}
+		runMu.Unlock()
+	})
+	cancelButton.Disable()
+
+	deleteRetained := widget.NewButton("Delete Unverified Copies...", func() {
+		confirmDeleteRetained(myWindow, retained, logMsg)
 	})
 
 	form := container.NewVBox(
 		widget.NewLabel("GitHub Org"), gitHubOrg,
 		widget.NewLabel("ADO Org"), adoOrg,
 		widget.NewLabel("ADO Project"), adoProject,
-		widget.NewLabel("Repo Names (comma-separated)"), repoList,
 		widget.NewLabel("GitHub PAT"), gitPat,
 		widget.NewLabel("ADO PAT"), adoPat,
-		deleteAfter,
-		migrateButton,
+		widget.NewLabel("Visibility"), visibilitySelect,
+		widget.NewLabel("Repositories"), picker.Content(),
+		widget.NewLabel("Missing LFS objects"), lfsMissingSelect,
+		widget.NewLabel("Archived repos"), archiveSelect,
+		widget.NewLabel("Secrets in history"), secretsSelect,
+		widget.NewLabel("Local copies"), copiesSelect,
+		widget.NewLabel(fmt.Sprintf("Concurrent repositories (1 to %d)", maxConcurrency)), concurrencyEntry,
+		showUTC,
+		dryRun,
+		keepAwake,
+		polite,
+		container.NewHBox(migrateButton, cancelButton),
+		sleepIndicator,
+		deleteRetained,
 		logBox,
 	)
 
-	myWindow.SetContent(form)
+	myWindow.SetContent(container.NewAppTabs(
+		container.NewTabItem("Migrate", container.NewVScroll(form)),
+		container.NewTabItem("Progress", progress.Content()),
+		container.NewTabItem("Live output", tails.Content()),
+	))
 	myWindow.SetCloseIntercept(func() {
+		closeWindow()
 		myApp.Quit()
 	})
 	myWindow.ShowAndRun()
 }
+
+// repoNames returns the names of those of repos of visibility, without
+// their owner, as the classic window migrates them from its one org.
+func repoNames(repos []gitHubRepo, visibility string) []string {
+	var names []string
+	for _, r := range repos {
+		if matchesVisibility(r, visibility) {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,626 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// migrationRun is what each repository of a run is migrated with, and the
+// run report the repositories are recorded in. The window fills it from
+// its settings and headless mode from flags; both then hand repositories
+// to Migrate, from as many goroutines as they migrate at once.
+type migrationRun struct {
+	GitHubToken string
+	Source      sourceProvider
+	Target      targetProvider
+	Report      *runReport
+	Doc         *migrationDoc
+
+	Secrets        secretPolicy
+	ConfirmSecrets func(repo string, findings []secretFinding) bool
+	Recycle        recyclePolicy
+	ConfirmPurge   func(deletedAzureRepo) bool
+	LFS            lfsPolicy
+	SkipLFS        bool // push the pointers of repositories using LFS without their objects
+	Archive        archiveMode
+	Filter         branchFilter
+	Badges         bool
+	BadgeBranch    string
+	Pipelines      bool // convert GitHub Actions workflows
+	PipelineBranch string
+	Signer         *commitSigner
+	Cleanup        cleanupPolicy
+	Retry          retryPolicy   // for clones, branch pushes and creating repositories
+	Chunks         *chunkTuner   // nil pushes branches whole
+	Canary         *canaryChecks // nil checks nothing once verified
+
+	Splits   *splitPlans
+	Areas    *workItemAreas
+	Branches *branchLists // branch previews, compared with the filtered clone
+	Retained *retainedCopies
+	Names    *nameAllocator // target names, split parts' included
+	// WontMigrate maps the repositories marked won't migrate to the reason.
+	WontMigrate map[string]string
+
+	// Licenses, if set, is checked against every repository's license
+	// before it is cloned. PrivateProject is whether the target project is
+	// private, and ConfirmLicense asks someone to confirm a flagged
+	// repository under the confirm gate, returning who did; nil blocks them.
+	Licenses       *licensePolicy
+	PrivateProject bool
+	ConfirmLicense func(repo string, rec *licenseRecord) (string, bool)
+
+	// DryRun logs what would be done instead of cloning, creating or
+	// pushing anything. Existing has the target's repositories by
+	// lower-cased name, to flag the names that would collide; nil if they
+	// could not be listed.
+	DryRun   bool
+	Existing map[string]string
+
+	// mu guards the run-wide parts of Report the repositories add to.
+	mu sync.Mutex
+}
+
+// Migrate migrates repo and records the outcome in result, its entry in
+// the report. Messages go to logMsg, the phases to scope, and git's output
+// to stream; the git commands run under ctx. It returns the final status.
+func (r *migrationRun) Migrate(ctx context.Context, repo string, result *repoReport, scope *logScope, stream io.Writer, logMsg func(string)) string {
+	repoStart := time.Now()
+	logMsg(fmt.Sprintf("Migrating repository: %s", repo))
+	if note, err := repoNote(repo); err != nil {
+		logMsg(fmt.Sprintf("Warning: could not read the note on %s: %v", repo, err))
+	} else if note != "" {
+		result.Note = note
+		logMsg(fmt.Sprintf("Note on %s: %s", repo, note))
+	}
+
+	// Record the outcome for the run report. Once pushing has started,
+	// what the server said goes with it.
+	var pushes *serverMessages
+	finish := func(status string, err error) string {
+		if ctx.Err() != nil && status == statusFailed {
+			// The run was cancelled and its git commands killed.
+			status, err = statusCancelled, nil
+		}
+		result.Status = status
+		result.DurationSeconds = time.Since(repoStart).Seconds()
+		if err != nil {
+			result.Error = err.Error()
+		}
+		scope.Set("", "")
+		if pushes != nil {
+			recordServerMessages(repo, result, pushes, logMsg)
+		}
+		if !r.DryRun {
+			if err := recordOutcome(r.Report.ID, result); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not record the outcome of %s in %s: %v", repo, migrationStatePath, err))
+			}
+		}
+		return status
+	}
+	// Holds are the last word, whatever put the repository in the run.
+	// If they cannot be read, nothing is touched.
+	hold, err := holdOf(repo)
+	if err != nil {
+		logMsg(fmt.Sprintf("Error: not migrating %s, the holds could not be checked: %v", repo, err))
+		return finish(statusFailed, err)
+	}
+	if hold != nil {
+		logMsg(fmt.Sprintf("Skipping %s: %s.", repo, hold))
+		result.Reason = hold.Reason
+		return finish(statusHeld, nil)
+	}
+	if reason, ok := r.WontMigrate[repo]; ok {
+		logMsg(fmt.Sprintf("Skipping %s: marked won't migrate (%s).", repo, reason))
+		result.Reason = reason
+		return finish(statusWontMigrate, nil)
+	}
+	scope.Set(repo, "metadata")
+
+	// Fetch repository metadata (default branch, archived flag).
+	meta, err := r.sourceMetadata(ctx, repo)
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not fetch %s metadata for %s: %v", r.sourceName(), repo, err))
+	}
+	if r.Licenses != nil {
+		rec, ok, reason := r.licenseStep(repo, meta, logMsg)
+		result.License = rec
+		if !ok {
+			result.Reason = reason
+			return finish(statusLicenseBlocked, nil)
+		}
+	}
+	if r.DryRun {
+		result.Reason = r.dryRun(repo, meta, result, logMsg)
+		return finish(statusDryRun, nil)
+	}
+
+	// Autolink references do not carry over; document them. Only GitHub
+	// has them.
+	if r.fromGitHub() {
+		if links, err := listAutolinks(ctx, repo, r.GitHubToken); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not list autolink references of %s: %v", repo, err))
+		} else if len(links) > 0 {
+			r.Doc.AddAutolinks(repo, links)
+			logMsg(fmt.Sprintf("%s has %d autolink reference(s), documented in MIGRATION.md.", repo, len(links)))
+		}
+	}
+
+	// The clone URL carries the source's credentials; runGit hands them
+	// to git through its credential helper, off the command line.
+	githubRepoURL := r.Source.CloneURL(ctx, repo)
+	result.SourceURL = repoURL(githubRepoURL)
+
+	// Create a temporary directory for the bare clone.
+	tempDir, err := ioutil.TempDir("", strings.ReplaceAll(repo, "/", "_"))
+	if err != nil {
+		logMsg(fmt.Sprintf("Error creating temporary directory for %s: %v", repo, err))
+		return finish(statusFailed, err)
+	}
+	logMsg(fmt.Sprintf("Cloning repository into %s", tempDir))
+
+	// Clone the repository as a bare clone. A clone cut off by the
+	// network is started again from an empty directory.
+	scope.Phase("clone")
+	clone := func() (string, error) {
+		return r.Retry.do(ctx, "the clone of "+repo, logMsg, func() (string, error) {
+			os.RemoveAll(tempDir)
+			return runGitTransfer(ctx, r.sourceHost(), stream, cloneArgs(githubRepoURL, tempDir)...)
+		})
+	}
+	output, err := clone()
+	if err != nil && repoNotFound(output) && r.fromGitHub() {
+		// The repository listed fine a moment ago; it may have
+		// been transferred or deleted since.
+		current, rerr := resolveMovedRepo(ctx, repo, r.GitHubToken)
+		switch {
+		case rerr == errSourceRemoved:
+			logMsg(fmt.Sprintf("Error: %s no longer exists on GitHub, it was removed after planning.", repo))
+			os.RemoveAll(tempDir)
+			return finish(statusSourceRemoved, rerr)
+		case rerr == nil && !strings.EqualFold(current, repo):
+			if hold, herr := holdOf(current); hold != nil || herr != nil {
+				os.RemoveAll(tempDir)
+				result.CurrentSource = current
+				if herr != nil {
+					logMsg(fmt.Sprintf("Error: not migrating %s, moved to %s, the holds could not be checked: %v", repo, current, herr))
+					return finish(statusFailed, herr)
+				}
+				logMsg(fmt.Sprintf("Skipping %s: it has moved to %s, which is %s.", repo, current, hold))
+				result.Reason = hold.Reason
+				return finish(statusHeld, nil)
+			}
+			logMsg(fmt.Sprintf("%s has moved to %s on GitHub, cloning it from there.", repo, current))
+			result.CurrentSource = current
+			repo = current
+			scope.Set(repo, "clone")
+			githubRepoURL = r.Source.CloneURL(ctx, repo)
+			result.SourceURL = repoURL(githubRepoURL)
+			output, err = clone()
+		}
+	}
+	if err != nil {
+		logMsg(fmt.Sprintf("Error cloning %s: %v, output: %s", repo, err, output))
+		// Clean up tempDir if clone fails.
+		os.RemoveAll(tempDir)
+		result.Cleanup = "deleted (clone failed)"
+		if ctx.Err() != nil {
+			result.Cleanup = "deleted (cancelled)"
+		}
+		return finish(statusFailed, err)
+	}
+	result.Bytes = dirSize(tempDir)
+	r.checkpoint(result, statusCloned, logMsg)
+
+	// From here on a failure leaves a clone behind, which the
+	// cleanup policy keeps or deletes; a cancelled run deletes it.
+	failClone := func(err error) string {
+		if ctx.Err() != nil {
+			os.RemoveAll(tempDir)
+			result.Cleanup = "deleted (cancelled)"
+			return finish(statusCancelled, nil)
+		}
+		result.Cleanup, result.Copy = r.Cleanup.Failed(tempDir, repo, err)
+		logMsg(fmt.Sprintf("Clone of failed %s: %s.", repo, result.Cleanup))
+		return finish(statusFailed, err)
+	}
+
+	if ctx.Err() != nil {
+		return failClone(ctx.Err())
+	}
+
+	// Scan the history for secrets before anything is created or
+	// pushed in the target.
+	scope.Phase("secret-scan")
+	findings, complete, proceed := secretStep(tempDir, repo, r.Secrets, r.ConfirmSecrets, logMsg)
+	if len(findings) > 0 || !complete {
+		r.mu.Lock()
+		r.Report.Security = append(r.Report.Security, &securityEntry{Repo: repo, Complete: complete, Findings: findings})
+		r.mu.Unlock()
+	}
+	if !proceed {
+		logMsg(fmt.Sprintf("Skipping %s: secrets found in history.", repo))
+		os.RemoveAll(tempDir)
+		result.Cleanup = "deleted (secrets found)"
+		return finish(statusSecretsBlocked, nil)
+	}
+
+	// A repository with a split plan is migrated as its parts
+	// instead of as a whole.
+	if parts := r.Splits.Get(repo); parts != nil {
+		scope.Phase("split")
+		if !filterRepoAvailable() {
+			err := errors.New("git filter-repo is required to split repositories")
+			logMsg(fmt.Sprintf("Error splitting %s: %v", repo, err))
+			return failClone(err)
+		}
+		// The parts are named like any other target. The repository's
+		// own name goes unused, so a part may take it.
+		r.Names.Release(result.Target)
+		strategies := map[string]string{}
+		var named []splitPart
+		for _, p := range parts {
+			name, strategy, err := r.Names.Name(repo, p.Target)
+			if err != nil {
+				logMsg(fmt.Sprintf("Error splitting %s: %v", repo, err))
+				return failClone(err)
+			}
+			if name != p.Target {
+				logMsg(fmt.Sprintf("Creating split part %s of %s as %s (collision strategy %s).", p.Subdir, repo, name, strategy))
+				strategies[p.Subdir] = strategy
+			}
+			named = append(named, splitPart{Subdir: p.Subdir, Target: name})
+		}
+		branch := ""
+		if meta != nil {
+			branch = meta.DefaultBranch
+		}
+		splitReports := migrateSplit(ctx, r.Target, tempDir, repo, named, branch, stream, logMsg)
+		for i := range splitReports {
+			splitReports[i].NameStrategy = strategies[splitReports[i].Subdir]
+		}
+		r.mu.Lock()
+		r.Report.Splits = append(r.Report.Splits, splitReports...)
+		r.mu.Unlock()
+		status := statusSplit
+		for _, split := range splitReports {
+			if split.Status == statusFailed {
+				status = statusFailed
+			}
+		}
+		if status == statusFailed {
+			result.Cleanup, result.Copy = r.Cleanup.Failed(tempDir, repo, nil)
+		} else {
+			os.RemoveAll(tempDir)
+			result.Cleanup = "deleted"
+		}
+		return finish(status, nil)
+	}
+	if projects := monorepoProjects(ctx, tempDir); len(projects) >= 3 {
+		logMsg(fmt.Sprintf("%s looks like a monorepo (%d projects: %s); a split plan can migrate them as separate repositories.",
+			repo, len(projects), strings.Join(projects, ", ")))
+	}
+
+	// The branch filter is applied to the clone's real refs; the
+	// preview, if any, may have been taken earlier.
+	if !r.Filter.Empty() {
+		head, _ := runGit(ctx, nil, headArgs(tempDir)...)
+		head = strings.TrimSpace(head)
+		kept, removed, err := applyBranchFilter(ctx, tempDir, r.Filter, head)
+		if err != nil {
+			logMsg(fmt.Sprintf("Error filtering branches of %s: %v", repo, err))
+			return failClone(err)
+		}
+		result.FilteredBranches = removed
+		logMsg(fmt.Sprintf("Branch filter: migrating %d branch(es) of %s, leaving out %d.", len(kept), repo, len(removed)))
+		if preview, ok := r.Branches.Cached(repo, branchPreviewMax()); ok {
+			for _, d := range previewDifferences(preview, r.Filter, kept, head) {
+				logMsg(fmt.Sprintf("Branch preview differs for %s: %s.", repo, d))
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return failClone(ctx.Err())
+	}
+
+	// Create the new repo in the target, under the name planned
+	// even if the source moved.
+	scope.Phase("create")
+	name := result.Target
+	var targetRepoURL string
+	createRepo := func() error {
+		_, err := r.Retry.do(ctx, "creating "+name, logMsg, func() (string, error) {
+			var err error
+			targetRepoURL, err = r.Target.CreateRepo(ctx, name)
+			return "", err
+		})
+		return err
+	}
+	err = createRepo()
+	var recycled *recycledNameError
+	if az, ok := r.Target.(*azureTarget); ok && errors.As(err, &recycled) {
+		logMsg(fmt.Sprintf("Warning: %v", err))
+		name, err = az.resolveRecycledName(ctx, recycled, r.Recycle, r.ConfirmPurge, logMsg)
+		if err == nil {
+			result.Target = name
+			err = createRepo()
+		}
+	}
+	if err != nil {
+		logMsg(fmt.Sprintf("Error creating %s repo for %s: %v", r.Target.Name(), repo, err))
+		return failClone(err)
+	}
+	// Keep the name as the target spelled it; it may normalize case.
+	if final := finalRepoName(targetRepoURL, name); final != name {
+		logMsg(fmt.Sprintf("%s named the repo %s (requested %s).", r.Target.Name(), final, name))
+		name = final
+		result.Target = name
+	}
+	logMsg(fmt.Sprintf("Created %s repo: %s", r.Target.Name(), name))
+	result.TargetURL = repoURL(targetRepoURL)
+
+	// Make sure the area path for this repository's work items exists.
+	if az, ok := r.Target.(*azureTarget); ok && r.Areas.Enabled() {
+		placement, created, err := r.Areas.Prepare(ctx, az, repo)
+		if err != nil {
+			logMsg(fmt.Sprintf("Warning: work item area for %s: %v", repo, err))
+		}
+		for _, c := range created {
+			logMsg(fmt.Sprintf("Created area path %s.", c))
+		}
+		r.mu.Lock()
+		r.Report.CreatedAreaPaths = append(r.Report.CreatedAreaPaths, created...)
+		r.mu.Unlock()
+		result.AreaPath, result.IterationPath = placement.AreaPath, placement.IterationPath
+	}
+
+	// Add the target remote.
+	scope.Phase("push")
+	if output, err := runGit(ctx, stream, remoteAddArgs(tempDir, "target", targetRepoURL)...); err != nil {
+		logMsg(fmt.Sprintf("Error adding target remote for %s: %v, output: %s", repo, err, output))
+		return failClone(err)
+	}
+
+	// Migrate LFS objects before the refs that point at them.
+	scope.Phase("lfs")
+	if r.SkipLFS {
+		if repoUsesLFS(ctx, tempDir) {
+			logMsg(fmt.Sprintf("Warning: %s uses Git LFS, but LFS objects are not included; the target gets only the pointer files.", repo))
+			result.LFSSkipped = true
+		}
+	} else if err := lfsStep(ctx, tempDir, "target", repo, r.lfsLockToken(), r.LFS, stream, logMsg); err != nil {
+		logMsg(fmt.Sprintf("Error migrating LFS objects for %s: %v", repo, err))
+		return failClone(err)
+	}
+
+	// Push all branches. A branch that does not land fails the repo.
+	// Pushing again after a dropped connection sends what is missing.
+	// What the server says while the pushes run is kept, whatever
+	// becomes of them.
+	scope.Phase("push")
+	refreshRemoteToken(ctx, tempDir, "target", r.Target)
+	pushes = newServerMessages(stream)
+	if err := r.Chunks.pushBranches(ctx, tempDir, "target", pushes, logMsg); err != nil {
+		logMsg(fmt.Sprintf("Error pushing branches for %s in chunks: %v", repo, err))
+		return failClone(err)
+	}
+	var refs []refOutcome
+	if output, err := r.Retry.do(ctx, "the branch push of "+repo, logMsg, func() (string, error) {
+		var output string
+		var err error
+		refs, output, err = pushRefs(ctx, tempDir, "target", pushes, "--all")
+		return output, err
+	}); err != nil {
+		if failed := failedRefs(refs); len(failed) > 0 {
+			logMsg(fmt.Sprintf("Error pushing branches for %s: %s did not push", repo, strings.Join(failed, ", ")))
+		} else {
+			logMsg(fmt.Sprintf("Error pushing branches for %s: %v, output: %s", repo, err, output))
+		}
+		return failClone(err)
+	}
+
+	// Push tags. The branches are in, so tags that will not push
+	// are warnings rather than a failed repo.
+	refreshRemoteToken(ctx, tempDir, "target", r.Target)
+	tagFailures, err := pushTags(ctx, tempDir, "target", pushes)
+	if err != nil {
+		logMsg(fmt.Sprintf("Error pushing tags for %s: %v", repo, err))
+		return failClone(err)
+	}
+	for _, failure := range tagFailures {
+		logMsg(fmt.Sprintf("Warning: %s of %s did not push: %s", failure.Ref, repo, failure.Summary))
+	}
+	result.FailedRefs = failedRefs(tagFailures)
+	r.checkpoint(result, statusPushed, logMsg)
+
+	// Verify before anything is changed or deleted; the local
+	// clone is the cheapest way to re-push whatever did not arrive.
+	scope.Phase("verify")
+	verified := verifyStep(ctx, tempDir, "target", repo, result.FailedRefs, logMsg)
+
+	// A push that went through is not everything arriving: compare the
+	// target with the source as it is now, not only with the clone.
+	cloned, _ := localRefs(ctx, tempDir)
+	leftOut := func(ref string) bool {
+		if containsString(result.FailedRefs, ref) || containsString(result.FilteredBranches, strings.TrimPrefix(ref, "refs/heads/")) {
+			return true
+		}
+		// Branches made since the clone that the filter excludes.
+		return cloned[ref] == "" && strings.HasPrefix(ref, "refs/heads/") && !r.Filter.Empty() && !r.Filter.Matches(strings.TrimPrefix(ref, "refs/heads/"))
+	}
+	if mismatches, ok := sourceVerifyStep(ctx, tempDir, "origin", "target", repo, leftOut, logMsg); ok && len(mismatches) > 0 {
+		result.SourceMismatches = mismatches
+		verified = false
+	}
+
+	// Counts and dates side by side, in terms stakeholders
+	// check; a discrepancy flags the repo even if SHAs matched.
+	if comparison, err := compareWithTarget(ctx, tempDir, "target", result.FailedRefs, stream); err != nil {
+		logMsg(fmt.Sprintf("Warning: could not compare %s with the target: %v", repo, err))
+	} else {
+		result.Comparison = comparison
+		logMsg(fmt.Sprintf("%s, source and target:", repo))
+		for _, line := range comparison.lines() {
+			logMsg(line)
+		}
+		for _, m := range comparison.Mismatches {
+			logMsg(fmt.Sprintf("Warning: %s: %s", repo, m))
+		}
+		if len(comparison.Mismatches) > 0 {
+			verified = false
+		}
+	}
+	result.Verified = verified
+
+	scope.Phase("finalize")
+	if meta != nil {
+		// Use the GitHub-reported default branch, which need not be
+		// main or master. Empty repositories have none yet.
+		if meta.DefaultBranch != "" {
+			if err := r.Target.SetDefaultBranch(ctx, name, meta.DefaultBranch); err != nil {
+				logMsg(fmt.Sprintf("Warning: default branch for %s not set: %v", repo, err))
+				result.DefaultBranch = err.Error()
+			} else {
+				logMsg(fmt.Sprintf("Default branch for %s set to %s.", repo, meta.DefaultBranch))
+				result.DefaultBranch = branchRef(meta.DefaultBranch)
+			}
+		}
+
+		// Converted pipelines and badges go on branches of their own,
+		// so the default branch still matches the source; this has to
+		// happen before a read-only repo refuses the push.
+		if az, ok := r.Target.(*azureTarget); ok && r.Pipelines && verified {
+			result.Pipelines = pipelinesStep(ctx, az, tempDir, repo, name, meta.DefaultBranch, r.PipelineBranch, r.Signer, stream, logMsg)
+			if result.Pipelines != nil && result.Pipelines.Error != "" {
+				logMsg(fmt.Sprintf("Warning: workflows of %s not converted: %s", repo, result.Pipelines.Error))
+			}
+		}
+		if az, ok := r.Target.(*azureTarget); ok && r.Badges && verified {
+			result.Badges = badgeStep(ctx, az, tempDir, repo, name, r.BadgeBranch, r.Signer, stream, logMsg)
+			if result.Badges != nil && result.Badges.Error != "" {
+				logMsg(fmt.Sprintf("Warning: README badges of %s not rewritten: %s", repo, result.Badges.Error))
+			}
+		}
+
+		// Make archived repos read-only in the target too. This comes last
+		// because a disabled repo can no longer be updated, and only
+		// once verified because an unverified repo may need a re-push.
+		if meta.Archived && verified {
+			if state, err := r.Target.MakeReadOnly(ctx, name, r.Archive); err != nil {
+				logMsg(fmt.Sprintf("Warning: could not make archived repo %s read-only: %v", repo, err))
+			} else {
+				logMsg(fmt.Sprintf("%s is archived on GitHub, %s repo %s.", repo, r.Target.Name(), state))
+			}
+		}
+	}
+
+	if verified {
+		logMsg(fmt.Sprintf("Successfully migrated %s to %s in %s.", repo, r.Target.Name(), formatDuration(time.Since(repoStart))))
+	} else {
+		logMsg(fmt.Sprintf("Migrated %s to %s in %s, but it could not be verified.", repo, r.Target.Name(), formatDuration(time.Since(repoStart))))
+	}
+
+	status := statusMigrated
+	switch {
+	case !verified:
+		status = statusUnverified
+	case len(result.FailedRefs) > 0:
+		status = statusWarnings
+	}
+
+	// Check the verified refs again later, from what is known now: the
+	// clone may be gone by then.
+	if verified && r.Canary != nil {
+		if expected, err := localRefs(ctx, tempDir); err != nil {
+			logMsg(fmt.Sprintf("Warning: no canary check for %s, its refs could not be listed: %v", repo, err))
+		} else {
+			for _, ref := range result.FailedRefs {
+				delete(expected, ref)
+			}
+			r.Canary.Schedule(r.Report, result, remoteURL(ctx, tempDir, "target"), expected)
+		}
+	}
+
+	// The local copy is kept or discarded as the local copies setting
+	// says; an unverified one is never discarded.
+	copies := r.Cleanup.Copies
+	if copies.Mode == copiesDiscard && !verified {
+		r.Retained.Add(repo, tempDir)
+		status = statusRetained
+		result.Cleanup, result.Copy = "retained pending verification in "+tempDir, tempDir
+		logMsg(fmt.Sprintf("Kept local clone of %s in %s pending verification.", repo, tempDir))
+	} else if dest, err := copies.Keep(tempDir, repo); err != nil && copies.Mode == copiesDiscard {
+		result.Cleanup, result.Copy = fmt.Sprintf("deleting: %v", err), tempDir
+		logMsg(fmt.Sprintf("Error removing local clone for %s: %v", repo, err))
+	} else if err != nil {
+		result.Cleanup, result.Copy = fmt.Sprintf("left in %s, could not move it: %v", tempDir, err), tempDir
+		logMsg(fmt.Sprintf("Error moving clone for %s to %s: %v", repo, copies.Path(repo), err))
+	} else if copies.Mode == copiesDiscard {
+		// Refs that did not push keep the warnings status; the deletion
+		// is in the cleanup either way.
+		if status == statusMigrated {
+			status = statusCleanedUp
+		}
+		result.Cleanup = "deleted"
+		logMsg(fmt.Sprintf("Removed local clone for %s.", repo))
+	} else {
+		result.Cleanup, result.Copy = "saved to "+dest, dest
+		logMsg(fmt.Sprintf("Local clone for %s saved to %s.", repo, dest))
+	}
+	return finish(status, nil)
+}
+
+// checkpoint records that result has reached status in the migration
+// state, for Resume after a crash; a failure to record it is a warning.
+func (r *migrationRun) checkpoint(result *repoReport, status string, logMsg func(string)) {
+	if err := recordCheckpoint(r.Report.ID, result, status); err != nil {
+		logMsg(fmt.Sprintf("Warning: could not record %s as %s in %s: %v", result.Source, status, migrationStatePath, err))
+	}
+}
+
+// fromGitHub reports whether the run migrates from GitHub, whose API the
+// metadata, autolink and LFS lock steps use.
+func (r *migrationRun) fromGitHub() bool {
+	return isGitHub(r.Source)
+}
+
+// sourceName is the source's display name, for messages.
+func (r *migrationRun) sourceName() string {
+	if r.Source == nil {
+		return "GitHub"
+	}
+	return r.Source.Name()
+}
+
+// sourceMetadata fetches repo's metadata from the source.
+func (r *migrationRun) sourceMetadata(ctx context.Context, repo string) (*gitHubRepo, error) {
+	if d, ok := r.Source.(describedSource); ok {
+		return d.RepoMetadata(ctx, repo)
+	}
+	return getGitHubRepo(ctx, repo, r.GitHubToken)
+}
+
+// sourceHost is the host the run clones from.
+func (r *migrationRun) sourceHost() string {
+	if d, ok := r.Source.(describedSource); ok {
+		return d.Host()
+	}
+	return "github.com"
+}
+
+// lfsLockToken is the token LFS locks are listed with, "" if the source
+// has no locks API the migration knows.
+func (r *migrationRun) lfsLockToken() string {
+	if !r.fromGitHub() {
+		return ""
+	}
+	return r.GitHubToken
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// migrationStatePath has the last outcome of every repository any run
+// has migrated, relative to the working directory. It is updated as each
+// repository finishes, so even a run that was killed leaves it current,
+// and Resume picks up the repositories that failed last time. A run
+// also records each repository as pending when it starts, and as cloned and
+// pushed on the way, so Resume can tell what a crashed run left unfinished.
+const migrationStatePath = "migration-state.json"
+
+var migrationStateMu sync.Mutex
+
+// repoState is a repository's last outcome.
+type repoState struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Run     string `json:"run"`
+	Updated string `json:"updated"`
+	// Copy is where the repository's local copy is kept, if anywhere,
+	// whichever flow made it.
+	Copy string `json:"copy,omitempty"`
+	// Note is the operator's note on the repository; see setRepoNote.
+	Note string `json:"note,omitempty"`
+	// ServerWarnings are what the target warned of while it was pushed
+	// to, such as a size limit drawing near.
+	ServerWarnings []string `json:"server_warnings,omitempty"`
+}
+
+// migrationState is the last outcomes, keyed by lower-case source.
+type migrationState map[string]*repoState
+
+// loadMigrationState reads the outcomes; no file means none yet.
+func loadMigrationState() (migrationState, error) {
+	data, err := readFileRecover(migrationStatePath, func(data []byte) error {
+		var list []*repoState
+		return json.Unmarshal(data, &list)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return migrationState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*repoState
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	state := migrationState{}
+	for _, s := range list {
+		state[strings.ToLower(s.Source)] = s
+	}
+	return state, nil
+}
+
+// Sorted returns the outcomes ordered by source.
+func (state migrationState) Sorted() []*repoState {
+	var list []*repoState
+	for _, s := range state {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Source) < strings.ToLower(list[j].Source) })
+	return list
+}
+
+// Failed returns the repositories whose last outcome was a failure,
+// ordered by source.
+func (state migrationState) Failed() []*repoState {
+	var failed []*repoState
+	for _, s := range state.Sorted() {
+		if s.Status == statusFailed {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
+// unfinished reports whether a repository whose last recorded status is
+// status was left before its outcome: pending, cloned or pushed by a run
+// that crashed or lost its connection, or cancelled.
+func unfinished(status string) bool {
+	switch status {
+	case statusPending, statusCloned, statusPushed, statusCancelled:
+		return true
+	}
+	return false
+}
+
+// Resumable returns the repositories Resume migrates again: those that
+// failed and those left unfinished, ordered by source. The rest, migrated
+// or skipped on purpose, are left alone.
+func (state migrationState) Resumable() []*repoState {
+	var resumable []*repoState
+	for _, s := range state.Sorted() {
+		if s.Status == statusFailed || unfinished(s.Status) {
+			resumable = append(resumable, s)
+		}
+	}
+	return resumable
+}
+
+// recordOutcome records result, a finished repository of run, as its last
+// outcome.
+func recordOutcome(run string, result *repoReport) error {
+	migrationStateMu.Lock()
+	defer migrationStateMu.Unlock()
+	state, err := loadMigrationState()
+	if err != nil {
+		return err
+	}
+	note := ""
+	if s := state[strings.ToLower(result.Source)]; s != nil {
+		note = s.Note
+	}
+	state[strings.ToLower(result.Source)] = &repoState{
+		Source:         result.Source,
+		Target:         result.Target,
+		Status:         result.Status,
+		Error:          result.Error,
+		Run:            run,
+		Updated:        fileTimestamp(time.Now()),
+		Copy:           result.Copy,
+		Note:           note,
+		ServerWarnings: result.ServerWarnings,
+	}
+	return state.save()
+}
+
+// recordPending records the repositories of run, before any of them
+// starts, as pending, keeping their local copies and notes. Migrate
+// replaces each with its outcome as it finishes.
+func recordPending(run string, results []*repoReport) error {
+	migrationStateMu.Lock()
+	defer migrationStateMu.Unlock()
+	state, err := loadMigrationState()
+	if err != nil {
+		return err
+	}
+	now := fileTimestamp(time.Now())
+	for _, result := range results {
+		s := state[strings.ToLower(result.Source)]
+		if s == nil {
+			s = &repoState{Source: result.Source}
+			state[strings.ToLower(result.Source)] = s
+		}
+		s.Target, s.Status, s.Error, s.Run, s.Updated = result.Target, statusPending, "", run, now
+	}
+	return state.save()
+}
+
+// recordCheckpoint records that result, a repository of run still in
+// progress, has reached status (statusCloned or statusPushed), keeping the
+// rest of its state.
+func recordCheckpoint(run string, result *repoReport, status string) error {
+	migrationStateMu.Lock()
+	defer migrationStateMu.Unlock()
+	state, err := loadMigrationState()
+	if err != nil {
+		return err
+	}
+	s := state[strings.ToLower(result.Source)]
+	if s == nil {
+		s = &repoState{Source: result.Source}
+		state[strings.ToLower(result.Source)] = s
+	}
+	s.Target, s.Status, s.Error, s.Run, s.Updated = result.Target, status, "", run, fileTimestamp(time.Now())
+	return state.save()
+}
+
+// recordLateOutcome records result, of run, as its last outcome, unless
+// a later run has recorded one since.
+func recordLateOutcome(run string, result *repoReport) error {
+	migrationStateMu.Lock()
+	state, err := loadMigrationState()
+	migrationStateMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if s := state[strings.ToLower(result.Source)]; s != nil && s.Run != run {
+		return nil
+	}
+	return recordOutcome(run, result)
+}
+
+// recordCopy records that repo's local copy is kept in dir, keeping the
+// rest of its last outcome.
+func recordCopy(repo, dir string) error {
+	migrationStateMu.Lock()
+	defer migrationStateMu.Unlock()
+	state, err := loadMigrationState()
+	if err != nil {
+		return err
+	}
+	s := state[strings.ToLower(repo)]
+	if s == nil {
+		s = &repoState{Source: repo, Updated: fileTimestamp(time.Now())}
+		state[strings.ToLower(repo)] = s
+	}
+	s.Copy = dir
+	return state.save()
+}
+
+// save writes the outcomes; migrationStateMu is held.
+func (state migrationState) save() error {
+	data, err := json.MarshalIndent(state.Sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(migrationStatePath, append(data, '\n'), 0644)
+}
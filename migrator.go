@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultConcurrency is how many repositories are migrated at once unless
+// the window says otherwise. Each one runs git clones and pushes, which are
+// heavy on disk and network.
+const defaultConcurrency = 3
+
+// maxConcurrency caps the concurrency setting.
+const maxConcurrency = 16
+
+// parseConcurrency parses the concurrency setting; blank means
+// defaultConcurrency, and GITUI_CONCURRENCY overrides the default.
+func parseConcurrency(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = os.Getenv("GITUI_CONCURRENCY")
+	}
+	if text == "" {
+		return defaultConcurrency, nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 1 || n > maxConcurrency {
+		return 0, fmt.Errorf("concurrency must be a number from 1 to %d", maxConcurrency)
+	}
+	return n, nil
+}
+
+// migrator runs a migration over repositories through a fixed-size pool of
+// workers, in the order given.
+type migrator struct {
+	// Migrate migrates one repository. Its git commands should run under
+	// ctx, so cancelling the run kills them.
+	Migrate func(ctx context.Context, repo string)
+	// Cancelled is called for each repository that was still queued when
+	// the run was cancelled.
+	Cancelled func(repo string)
+}
+
+// Run migrates repos with concurrency workers and returns once every
+// repository is migrated or, after ctx is cancelled, once the ones in
+// flight have stopped.
+func (m *migrator) Run(ctx context.Context, repos []string, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range queue {
+				m.Migrate(ctx, repo)
+			}
+		}()
+	}
+
+	// Nothing more is handed out once the run is cancelled.
+	next := 0
+feed:
+	for ; next < len(repos) && ctx.Err() == nil; next++ {
+		select {
+		case queue <- repos[next]:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	if m.Cancelled != nil {
+		for _, repo := range repos[next:] {
+			m.Cancelled(repo)
+		}
+	}
+	wg.Wait()
+}
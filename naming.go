@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Collision strategies derive a target name for a repository whose name
+// is taken. A collisionPolicy tries them in order until one gives a name
+// that is free, case-insensitively, as ADO compares names.
+const (
+	strategyKeep        = "keep"         // the name as it is
+	strategyOwnerPrefix = "owner-prefix" // owner-name
+	strategyOwnerSuffix = "owner-suffix" // name-owner
+	strategyCounter     = "counter"      // name-2, name-3, ...
+	strategyHash        = "hash"         // name-abc123, from the source's full name
+	strategyTemplate    = "template"     // "template:" and a template over {owner}, {name} and {hash}
+)
+
+// collisionPolicy resolves target name collisions.
+type collisionPolicy struct {
+	Strategies []string
+	Template   string // for strategyTemplate
+}
+
+// defaultCollisionPolicy is how collisions were always resolved: the name,
+// then with the owner appended, then numbered.
+var defaultCollisionPolicy = collisionPolicy{Strategies: []string{strategyKeep, strategyOwnerSuffix, strategyCounter}}
+
+// parseCollisionPolicy parses strategies separated by commas, such as
+// "keep, owner-prefix, template:{owner}.{name}"; blank is
+// defaultCollisionPolicy.
+func parseCollisionPolicy(text string) (collisionPolicy, error) {
+	if strings.TrimSpace(text) == "" {
+		return defaultCollisionPolicy, nil
+	}
+	var c collisionPolicy
+	for _, s := range strings.Split(text, ",") {
+		s = strings.TrimSpace(s)
+		if tmpl := strings.TrimPrefix(s, strategyTemplate+":"); tmpl != s {
+			if c.Template != "" {
+				return c, fmt.Errorf("collisions: only one template allowed")
+			}
+			if !strings.Contains(tmpl, "{name}") && !strings.Contains(tmpl, "{hash}") {
+				return c, fmt.Errorf("collisions: template %q needs {name} or {hash}", tmpl)
+			}
+			c.Strategies = append(c.Strategies, strategyTemplate)
+			c.Template = tmpl
+			continue
+		}
+		switch s {
+		case strategyKeep, strategyOwnerPrefix, strategyOwnerSuffix, strategyCounter, strategyHash:
+			c.Strategies = append(c.Strategies, s)
+		default:
+			return c, fmt.Errorf("collisions: unknown strategy %q (want keep, owner-prefix, owner-suffix, counter, hash or template:...)", s)
+		}
+	}
+	return c, nil
+}
+
+// String renders the policy in the form parseCollisionPolicy reads.
+func (c collisionPolicy) String() string {
+	var parts []string
+	for _, s := range c.Strategies {
+		if s == strategyTemplate {
+			s += ":" + c.Template
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sourceHash is the short hash of source for strategyHash: the same
+// source always gets the same suffix.
+func sourceHash(source string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(source)))
+	return hex.EncodeToString(sum[:])[:6]
+}
+
+// candidate returns the name strategy gives source (owner/name) wanting
+// name, or "" if it gives none. counter is tried with n = 2, 3, ...
+func (c collisionPolicy) candidate(strategy, source, name string, n int) string {
+	owner := strings.TrimSuffix(source, "/"+repoShortName(source))
+	switch strategy {
+	case strategyKeep:
+		return name
+	case strategyOwnerPrefix:
+		return owner + "-" + name
+	case strategyOwnerSuffix:
+		return name + "-" + owner
+	case strategyCounter:
+		return fmt.Sprintf("%s-%d", name, n)
+	case strategyHash:
+		return name + "-" + sourceHash(source)
+	case strategyTemplate:
+		return strings.NewReplacer("{owner}", owner, "{name}", name, "{hash}", sourceHash(source)).Replace(c.Template)
+	}
+	return ""
+}
+
+// resolve returns the first free name the strategies give source wanting
+// name, and the strategy that gave it. A policy without keep renames even
+// names that are free, such as to prefix every repository with its owner.
+func (c collisionPolicy) resolve(source, name string, taken map[string]bool) (string, string, error) {
+	for _, s := range c.Strategies {
+		if s == strategyCounter {
+			for n := 2; ; n++ {
+				if candidate := c.candidate(s, source, name, n); !taken[strings.ToLower(candidate)] {
+					return candidate, s, nil
+				}
+			}
+		}
+		if candidate := c.candidate(s, source, name, 0); !taken[strings.ToLower(candidate)] {
+			return candidate, s, nil
+		}
+	}
+	return "", "", fmt.Errorf("target name %s is taken and collision strategies %q give no free name", name, c.String())
+}
+
+// nameAllocator hands out target names that are unique within a run or
+// plan, resolving collisions with its policy. Names are handed out first
+// come, first served, so the first of two colliding sources keeps its
+// name.
+type nameAllocator struct {
+	policy collisionPolicy
+
+	mu    sync.Mutex
+	taken map[string]bool
+}
+
+func newNameAllocator(policy collisionPolicy) *nameAllocator {
+	return &nameAllocator{policy: policy, taken: map[string]bool{}}
+}
+
+// Reserve takes name without resolving anything, for a name a repository
+// already has.
+func (a *nameAllocator) Reserve(name string) {
+	a.mu.Lock()
+	a.taken[strings.ToLower(name)] = true
+	a.mu.Unlock()
+}
+
+// Release frees name for another repository.
+func (a *nameAllocator) Release(name string) {
+	a.mu.Lock()
+	delete(a.taken, strings.ToLower(name))
+	a.mu.Unlock()
+}
+
+// Name takes the target name for source wanting name, and returns it with
+// the strategy that gave it.
+func (a *nameAllocator) Name(source, name string) (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	got, strategy, err := a.policy.resolve(source, name, a.taken)
+	if err == nil {
+		a.taken[strings.ToLower(got)] = true
+	}
+	return got, strategy, err
+}
+
+// targetNames assigns each source repository its target name with names,
+// in order, so of sources whose names collide case-insensitively ("Tools"
+// and "tools", from different owners) the first keeps its name. Sources in
+// kept, such as retried ones, keep the name given there. It also returns
+// the strategy behind each name that is not the source's own.
+func targetNames(sources []string, names *nameAllocator, kept map[string]string) (map[string]string, map[string]string, error) {
+	for _, name := range kept {
+		names.Reserve(name)
+	}
+	targets, strategies := map[string]string{}, map[string]string{}
+	for _, s := range sources {
+		if name, ok := kept[s]; ok {
+			targets[s] = name
+			continue
+		}
+		name, strategy, err := names.Name(s, repoShortName(s))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", s, err)
+		}
+		targets[s] = name
+		if name != repoShortName(s) {
+			strategies[s] = strategy
+		}
+	}
+	return targets, strategies, nil
+}
@@ -1,273 +1,1996 @@
+//go:build !classic && !nogui
+
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
 
-// getGitHubRepos fetches the authenticated user's repositories from GitHub.
-func getGitHubRepos(user, token string) []string {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/user/repos", nil)
+func main() {
+	// Subcommands run without opening a window.
+	if code, ok := runSubcommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	// File log settings; both can also be changed in the window.
+	flags := flag.NewFlagSet("gitui", flag.ExitOnError)
+	logFileFlag := flags.String("log-file", "", "also write the log to this file")
+	logFormatFlag := flags.String("log-format", logFormatLogfmt, "file log format: logfmt, json, or a template over .Time, .Level, .Repo, .Phase and .Message")
+	logLevelFlag := flags.String("log-level", "info", "lowest level written to the log file: debug, info, warn or error")
+	dashboardFlag := flags.Bool("dashboard", false, "serve a live progress dashboard (page, /api/status and /ws)")
+	dashboardAddrFlag := flags.String("dashboard-addr", defaultDashboardAddr, "address the dashboard listens on")
+	dashboardTokenFlag := flags.String("dashboard-token", os.Getenv("GITUI_DASHBOARD_TOKEN"), "bearer token for the dashboard; random if empty")
+	flags.Parse(os.Args[1:])
+	if _, err := parseLogFormat(*logFormatFlag); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+	fileLevel, err := parseLogLevel(*logLevelFlag)
 	if err != nil {
-		return nil
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
 	}
 
-	// Authenticate with GitHub PAT
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	// Create the Fyne app and window.
+	// The ID gives the app persistent preferences, such as the last source.
+	a := app.NewWithID("io.github.singhparavjot.gitui")
+	mw := newMainWindow(a, windowOptions{
+		logFile:        *logFileFlag,
+		logFormat:      *logFormatFlag,
+		logLevel:       fileLevel,
+		dashboard:      *dashboardFlag,
+		dashboardAddr:  *dashboardAddrFlag,
+		dashboardToken: *dashboardTokenFlag,
+	})
+	mw.win.ShowAndRun()
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil
+// windowOptions are the window's command-line settings.
+type windowOptions struct {
+	logFile, logFormat string
+	logLevel           slog.Level
+	dashboard          bool
+	dashboardAddr      string
+	dashboardToken     string
+}
+
+// mainWindow is the migration window: the settings of a run, the run they
+// start, and the views of its log, progress and results. newMainWindow
+// builds its sections in the order they depend on each other.
+type mainWindow struct {
+	app fyne.App
+	win fyne.Window
+	ui  *uiBatcher
+
+	// The log, shown in the window and, during a run, written to a file.
+	logs            *logModel
+	logEntry        *widget.Entry
+	logViewLevel    *widget.Select
+	logFilterEntry  *widget.Entry
+	saveLogsBtn     *widget.Button
+	compactCheckbox *widget.Check
+	utcCheckbox     *widget.Check
+	clock           *logClock
+	dashboard       *dashboardHub
+	runLogMu        sync.Mutex
+	runLog          *fileLog
+
+	tails    *tailView // live per-repository git output
+	progress *progressView
+	results  *resultsView
+	retained *retainedCopies // local clones kept because their push could not be verified
+	areas    *workItemAreas  // where work items for each repository go in the Azure project
+	splits   *splitPlans     // monorepos to migrate as several target repositories
+	branches *branchLists    // what the branch preview fetched
+	canary   *canaryChecks
+
+	// The source.
+	githubTokenEntry   *widget.Entry
+	githubIdentityMu   sync.Mutex
+	githubIdentity     *gitHubIdentity
+	githubOrgWarning   *widget.Label
+	githubOrgSelect    *widget.SelectEntry
+	expectedOwnerEntry *widget.Entry
+	confirmIdentity    func(warning, login string) bool
+	checkGitHubBtn     *widget.Button
+	signInGitHubBtn    *widget.Button
+	skipArchivedCheck  *widget.Check
+	visibilitySelect   *widget.Select
+	picker             *repoPicker
+
+	// The target.
+	targetEntries       map[string]map[string]*widget.Entry
+	targetForms         map[string]*widget.Form
+	targetFormsBox      *fyne.Container
+	targetTypeSelect    *widget.Select
+	azureTokenEntry     *widget.Entry
+	azureOrgEntry       *widget.Entry
+	azureProjectEntry   *widget.Entry
+	recyclePolicySelect *widget.Select
+	confirmPurge        func(deletedAzureRepo) bool
+	badgesCheckbox      *widget.Check
+	pipelinesCheckbox   *widget.Check
+	branchPrefixEntry   *widget.Entry
+
+	// The tokens, remembered in the OS keychain between sessions if asked
+	// to, by credentialKey.
+	credentialEntries    map[string]*widget.Entry
+	credentialKeys       []string
+	rememberCheck        *widget.Check
+	forgetCredentialsBtn *widget.Button
+
+	// How a run migrates.
+	runTagEntry         *widget.Entry
+	branchFilterEntry   *widget.Entry
+	copiesSelect        *widget.Select
+	copiesDirEntry      *widget.Entry
+	keepFailedCheckbox  *widget.Check
+	failedDaysEntry     *widget.Entry
+	includeLFSCheck     *widget.Check
+	lfsPolicySelect     *widget.Select
+	archiveSelect       *widget.Select
+	releaseModeSelect   *widget.Select
+	releaseFeedEntry    *widget.Entry
+	secretPolicySelect  *widget.Select
+	confirmSecrets      func(repo string, findings []secretFinding) bool
+	confirmLicense      func(repo string, rec *licenseRecord) (string, bool)
+	botNameEntry        *widget.Entry
+	botEmailEntry       *widget.Entry
+	signingSelect       *widget.Select
+	signingKeyEntry     *widget.Entry
+	requireSigningCheck *widget.Check
+	logFileEntry        *widget.Entry
+	logFormatEntry      *widget.Entry
+	logFormatError      *widget.Label
+	logLevelSelect      *widget.Select
+	keepAwakeCheckbox   *widget.Check
+	concurrencyEntry    *widget.Entry
+	attemptsEntry       *widget.Entry
+	backoffEntry        *widget.Entry
+	chunkSizeEntry      *widget.Entry
+	chunkFixedCheck     *widget.Check
+	livenessEntry       *widget.Entry
+	canaryEntry         *widget.Entry
+	collisionsEntry     *widget.Entry
+	politeCheckbox      *widget.Check
+	dryRunCheckbox      *widget.Check
+	readOnlyCheckbox    *widget.Check
+	sleepIndicator      *widget.Label
+	canaryLabel         *widget.Label
+	identitiesLabel     *widget.Label
+
+	// The run and what starts and stops it.
+	runMu           sync.Mutex
+	cancelRun       context.CancelFunc // set while a run is active
+	validationBlock string             // why the last Validate failed, which blocks Migrate
+	migrateBtn      *widget.Button
+	resumeBtn       *widget.Button
+	cancelBtn       *widget.Button
+	validateBtn     *widget.Button
+	validationNote  *widget.Label
+	releaseBtn      *widget.Button
+	readOnlyNote    *widget.Label
+}
+
+// newMainWindow builds the window of a.
+func newMainWindow(a fyne.App, opts windowOptions) *mainWindow {
+	mw := &mainWindow{app: a, win: a.NewWindow("GitHub to Azure Migration")}
+	mw.win.Resize(fyne.NewSize(600, 500))
+	mw.buildLog(opts)
+
+	// Clones kept from failed repositories in earlier runs: offer to delete
+	// those past their expiry, leave the rest for retries.
+	if expired, kept, err := scanFailedClones(time.Now(), time.Duration(a.Preferences().IntWithFallback("cleanup.failedDays", defaultFailedCloneDays))*24*time.Hour); err != nil {
+		mw.appendLog(fmt.Sprintf("Warning: could not scan %s: %v", failedClonesDir, err))
+	} else {
+		if len(kept) > 0 {
+			mw.appendLog(fmt.Sprintf("%d clone(s) of failed repositories kept in %s.", len(kept), failedClonesDir))
+		}
+		if len(expired) > 0 {
+			confirmDeleteExpiredClones(mw.win, expired, mw.appendLog)
+		}
+	}
+
+	mw.tails = newTailView(mw.win, mw.ui)
+	mw.progress = newProgressView(mw.ui)
+	mw.retained = &retainedCopies{}
+	mw.areas = &workItemAreas{}
+	mw.splits = &splitPlans{}
+	mw.branches = &branchLists{}
+
+	mw.buildSource()
+	mw.buildTarget()
+	mw.buildCredentials()
+	mw.buildRunSettings(opts)
+	mw.buildResults()
+	mw.buildRunButtons()
+	mw.buildReadOnly()
+
+	mw.win.SetCloseIntercept(func() {
+		mw.saveRemembered()
+		pending := mw.canary.Pending()
+		if len(pending) == 0 {
+			mw.win.Close()
+			return
+		}
+		dialog.ShowConfirm("Canary checks pending",
+			fmt.Sprintf("%d canary check(s) have not run yet and will be lost if the app closes:\n%s\n\nClose anyway?", len(pending), strings.Join(pending, "\n")),
+			func(ok bool) {
+				if ok {
+					mw.win.Close()
+				}
+			}, mw.win)
+	})
+
+	mw.win.SetContent(container.NewAppTabs(
+		container.NewTabItem("Migrate", mw.layout()),
+		container.NewTabItem("Progress", mw.progress.Content()),
+		container.NewTabItem("Results", mw.results.Content()),
+		container.NewTabItem("Live output", mw.tails.Content()),
+	))
+	return mw
+}
+
+// buildLog makes the log view, and starts the dashboard if asked to, so
+// where it serves is the first thing logged.
+func (mw *mainWindow) buildLog(opts windowOptions) {
+	// Create a binding for the logs.
+	logBinding := binding.NewString()
+	mw.logEntry = widget.NewMultiLineEntry()
+	mw.logEntry.Bind(logBinding)
+	mw.logEntry.SetPlaceHolder("Logs will appear here...")
+	mw.logEntry.Wrapping = fyne.TextWrapWord
+	mw.logEntry.Disable() // make read-only
+
+	// Widget updates are batched so high log volume doesn't stall the UI.
+	mw.ui = newUIBatcher(uiRefreshHz())
+	mw.logs = newLogModel(mw.ui, func(text string) {
+		// Update binding (thread-safe)
+		logBinding.Set(text)
+	})
+	// The log shows the lines of a level and above that contain the
+	// filter text; saving writes all of them.
+	mw.logViewLevel = widget.NewSelect(logLevelNames, nil)
+	mw.logFilterEntry = widget.NewEntry()
+	mw.logFilterEntry.SetPlaceHolder("Filter log")
+	applyLogFilter := func() {
+		mw.logs.SetFilter(logLevels[mw.logViewLevel.SelectedIndex()], mw.logFilterEntry.Text)
+	}
+	mw.logViewLevel.OnChanged = func(string) { applyLogFilter() }
+	mw.logFilterEntry.OnChanged = func(string) { applyLogFilter() }
+	mw.logViewLevel.SetSelected("Info")
+
+	mw.clock = &logClock{}
+	mw.compactCheckbox = widget.NewCheck("Compact log (no dates, short repo names)", func(checked bool) {
+		mw.clock.SetCompact(checked)
+	})
+	// Checkbox for showing UTC instead of local time in the log.
+	mw.utcCheckbox = widget.NewCheck("Show UTC in log", func(checked bool) {
+		mw.clock.SetUTC(checked)
+	})
+	mw.saveLogsBtn = widget.NewButton("Save logs...", func() {
+		dialog.ShowFileSave(func(f fyne.URIWriteCloser, err error) {
+			if err != nil || f == nil {
+				return
+			}
+			defer f.Close()
+			if _, err := mw.logs.WriteTo(f); err != nil {
+				dialog.ShowError(err, mw.win)
+				return
+			}
+			mw.appendLog(fmt.Sprintf("Log saved to %s.", f.URI().Path()))
+		}, mw.win)
+	})
+
+	if !opts.dashboard {
+		return
+	}
+	mw.dashboard = newDashboardHub()
+	token := opts.dashboardToken
+	if token == "" {
+		token = newDashboardToken()
+	}
+	if pageURL, err := startDashboard(opts.dashboardAddr, token, mw.dashboard); err != nil {
+		mw.appendLog(fmt.Sprintf("Error starting the dashboard: %v", err))
+	} else {
+		mw.appendLog(fmt.Sprintf("Dashboard serving at %s", pageURL))
+		if !isLoopbackAddr(opts.dashboardAddr) {
+			mw.appendLog(fmt.Sprintf("Warning: the dashboard listens on %s, reachable from other machines; anyone with the token can follow the run.", opts.dashboardAddr))
+		}
+	}
+}
+
+// logIn logs msg with the repository and phase of scope, if any; each
+// repository in flight has its own. Lines go to the window and, during a
+// run, to the log file, with the tokens in the window's fields redacted.
+func (mw *mainWindow) logIn(scope *logScope, msg string) {
+	msg = redactLog(msg, mw.enteredTokens()...)
+	now := time.Now()
+	var repo, phase string
+	if scope != nil {
+		repo, phase = scope.Get()
+	}
+	mw.runLogMu.Lock()
+	mw.runLog.Write(logRecord{Time: now, Level: logLevel(msg), Repo: repo, Phase: phase, Message: msg})
+	mw.runLogMu.Unlock()
+
+	if mw.compactCheckbox.Checked && repo != "" {
+		msg = strings.ReplaceAll(msg, repo, repoShortName(repo))
+	}
+	// Prepend timestamp
+	mw.logs.Append(messageLevel(msg), fmt.Sprintf("[%s] %s", mw.clock.Stamp(now), msg))
+}
+
+// appendLog logs msg outside any repository.
+func (mw *mainWindow) appendLog(msg string) { mw.logIn(nil, msg) }
+
+// enteredTokens returns the tokens in the window's fields; none until the
+// fields exist.
+func (mw *mainWindow) enteredTokens() []string {
+	var tokens []string
+	for _, entry := range mw.credentialEntries {
+		tokens = append(tokens, strings.TrimSpace(entry.Text))
+	}
+	return tokens
+}
+
+// buildSource makes the source settings: the GitHub token, whose
+// repositories to migrate, and the picker they are checked in.
+func (mw *mainWindow) buildSource() {
+	prefs := mw.app.Preferences()
+	mw.githubTokenEntry = widget.NewEntry()
+	mw.githubTokenEntry.SetPlaceHolder("GitHub PAT Token")
+
+	// Whose repositories to migrate: the token user's or an organization's.
+	// The choices are the organizations the token can see, but any name can
+	// be typed, e.g. for an SSO organization the token is not yet
+	// authorized for.
+	mw.githubOrgWarning = widget.NewLabel("")
+	mw.githubOrgWarning.Wrapping = fyne.TextWrapWord
+	mw.githubOrgWarning.Hide()
+	mw.githubOrgSelect = widget.NewSelectEntry([]string{personalRepos})
+	mw.githubOrgSelect.SetPlaceHolder("Organization, or " + personalRepos)
+	mw.githubOrgSelect.OnChanged = func(choice string) {
+		warning := mw.orgWarning(sourceOrg(choice))
+		if warning == "" {
+			mw.githubOrgWarning.Hide()
+			return
+		}
+		mw.githubOrgWarning.SetText("Warning: " + warning)
+		mw.githubOrgWarning.Show()
+	}
+	mw.githubOrgSelect.SetText(prefs.StringWithFallback("github.source", personalRepos))
+	mw.githubTokenEntry.OnSubmitted = func(string) { mw.loadGitHubOrgs() }
+
+	// Whose repositories this profile migrates, checked against the
+	// token's user before each run.
+	mw.expectedOwnerEntry = widget.NewEntry()
+	mw.expectedOwnerEntry.SetPlaceHolder("GitHub login or organization (optional)")
+	mw.expectedOwnerEntry.SetText(prefs.String("github.expectedOwner"))
+	mw.expectedOwnerEntry.OnChanged = func(owner string) {
+		prefs.SetString("github.expectedOwner", strings.TrimSpace(owner))
+	}
+	mw.confirmIdentity = confirmIdentityDialog(mw.win)
+	mw.checkGitHubBtn = widget.NewButton("Check", mw.loadGitHubOrgs)
+	// Or sign in through the browser, for organizations that do not allow
+	// long-lived PATs.
+	mw.signInGitHubBtn = widget.NewButton("Sign in with GitHub", func() {
+		signInWithGitHub(mw.app, mw.win, func(token string) {
+			mw.githubTokenEntry.SetText(token)
+			mw.loadGitHubOrgs()
+		}, mw.appendLog)
+	})
+
+	// Archived repositories are rarely wanted on the target.
+	mw.skipArchivedCheck = widget.NewCheck("Leave archived repositories out of the list", func(checked bool) {
+		prefs.SetBool("github.skipArchived", checked)
+	})
+	mw.skipArchivedCheck.SetChecked(prefs.BoolWithFallback("github.skipArchived", true))
+	// An organization's admin can list all of its repositories, or narrow
+	// them to one visibility.
+	mw.visibilitySelect = widget.NewSelect(visibilityNames, func(selected string) {
+		prefs.SetString("github.visibility", selected)
+	})
+	mw.visibilitySelect.SetSelected(prefs.StringWithFallback("github.visibility", visibilityNames[0]))
+
+	// The repositories to migrate, checked from what the source lists.
+	// A large organization's listing is saved as it goes; an interrupted
+	// one can be resumed instead of listed again from the first page.
+	confirmResume := confirmResumeDialog(mw.win)
+	mw.picker = newRepoPicker(func(onPage func([]string)) ([]string, error) {
+		source := strings.TrimSpace(mw.githubOrgSelect.Text)
+		org := sourceOrg(source)
+		from, err := newSource("GitHub", map[string]string{
+			"token":         strings.TrimSpace(mw.githubTokenEntry.Text),
+			"org":           org,
+			"skip_archived": strconv.FormatBool(mw.skipArchivedCheck.Checked),
+			"visibility":    repoVisibilities[mw.visibilitySelect.SelectedIndex()],
+		})
+		if err != nil {
+			return nil, err
+		}
+		prefs.SetString("github.source", source)
+		mw.appendLog(fmt.Sprintf("Fetching repositories from %s...", from.Name()))
+		var repos []string
+		if gs, ok := from.(*gitHubSource); ok {
+			st, lerr := gs.InterruptedListing()
+			if lerr != nil {
+				mw.appendLog(fmt.Sprintf("Warning: could not read the saved listing, listing from the first page: %v", lerr))
+			}
+			resume := st != nil && confirmResume(st)
+			if resume {
+				mw.appendLog(fmt.Sprintf("Resuming the %s.", st))
+			}
+			repos, err = gs.ListReposResumable(context.Background(), resume, onPage)
+			if err != nil && org != "" {
+				mw.appendLog(fmt.Sprintf("The listing of %s is saved as far as it got; Fetch Repos again to resume it.", org))
+			}
+		} else {
+			repos, err = from.ListRepos(context.Background())
+		}
+		if err != nil || len(repos) == 0 {
+			if warning := mw.orgWarning(org); warning != "" {
+				mw.appendLog("Warning: " + warning)
+			}
+		}
+		return repos, err
+	}, mw.appendLog)
+	if org := sourceOrg(mw.githubOrgSelect.Text); org != "" {
+		if st, err := loadListing(org); err == nil && st != nil && !st.Complete {
+			mw.appendLog(fmt.Sprintf("An %s was saved; Fetch Repos offers to resume it.", st))
+		}
+	}
+}
+
+// orgWarning returns what the GitHub token's identity says against
+// migrating org, "" if nothing or before it is looked up.
+func (mw *mainWindow) orgWarning(org string) string {
+	mw.githubIdentityMu.Lock()
+	defer mw.githubIdentityMu.Unlock()
+	return mw.githubIdentity.orgWarning(org)
+}
+
+// loadGitHubOrgs looks up in the background what the GitHub token can
+// see, and offers its organizations as the source.
+func (mw *mainWindow) loadGitHubOrgs() {
+	token := strings.TrimSpace(mw.githubTokenEntry.Text)
+	if token == "" {
+		return
+	}
+	go func() {
+		id, err := getGitHubIdentity(context.Background(), token)
+		if err != nil {
+			mw.appendLog(fmt.Sprintf("Warning: could not look up what the GitHub token can see: %v", err))
+		}
+		if id == nil {
+			return
+		}
+		mw.githubIdentityMu.Lock()
+		mw.githubIdentity = id
+		mw.githubIdentityMu.Unlock()
+		mw.githubOrgSelect.SetOptions(id.Options())
+		mw.appendLog(fmt.Sprintf("GitHub token of %s sees %d organization(s).", id.Login, len(id.Orgs)))
+		// Re-check the current choice against what the token sees.
+		mw.githubOrgSelect.OnChanged(mw.githubOrgSelect.Text)
+	}()
+}
+
+// buildTarget makes the target settings, rendered from each registered
+// provider's fields; only the selected target's form is shown.
+func (mw *mainWindow) buildTarget() {
+	prefs := mw.app.Preferences()
+	mw.targetEntries = map[string]map[string]*widget.Entry{}
+	mw.targetForms = map[string]*widget.Form{}
+	mw.targetFormsBox = container.NewVBox()
+	for _, f := range targetFactories {
+		entries := map[string]*widget.Entry{}
+		form := widget.NewForm()
+		for _, field := range f.Fields {
+			entry := widget.NewEntry()
+			if field.Secret {
+				entry = widget.NewPasswordEntry()
+			}
+			entry.SetPlaceHolder(field.PlaceHolder)
+			entries[field.Key] = entry
+			form.Append(field.Label, entry)
+		}
+		mw.targetEntries[f.Name], mw.targetForms[f.Name] = entries, form
+		mw.targetFormsBox.Add(form)
+	}
+	mw.azureTokenEntry = mw.targetEntries["Azure DevOps"]["token"]
+	mw.azureOrgEntry = mw.targetEntries["Azure DevOps"]["org"]
+	mw.azureProjectEntry = mw.targetEntries["Azure DevOps"]["project"]
+	// Or sign in with Microsoft Entra ID, for organizations that do not
+	// allow PATs; the session stands in for the PAT.
+	signInMicrosoftBtn := widget.NewButton("Sign in with Microsoft", func() {
+		signInWithMicrosoft(mw.app, mw.win, mw.azureTokenEntry.SetText, mw.appendLog)
+	})
+	for _, item := range mw.targetForms["Azure DevOps"].Items {
+		if item.Widget == mw.azureTokenEntry {
+			item.Widget = container.NewBorder(nil, nil, nil, signInMicrosoftBtn, mw.azureTokenEntry)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	// What to do when a name is held by a repository in the ADO recycle bin.
+	mw.recyclePolicySelect = widget.NewSelect(recyclePolicyNames, nil)
+	mw.recyclePolicySelect.SetSelectedIndex(int(recycleFail))
+	mw.confirmPurge = confirmPurgeDialog(mw.win)
+
+	// Point GitHub Actions badges in READMEs at the pipelines built from
+	// the migrated repos, on a branch of their own.
+	mw.badgesCheckbox = widget.NewCheck("Rewrite Actions badges to Azure Pipelines (branch "+defaultMigrationBranchPrefix+badgeBranchName+")", nil)
+	// Convert GitHub Actions workflows to Azure Pipelines YAML, likewise on
+	// a branch of their own.
+	mw.pipelinesCheckbox = widget.NewCheck("Convert Actions workflows to Azure Pipelines (branch "+defaultMigrationBranchPrefix+pipelineBranchName+")", nil)
+
+	// Prefix of the branches the migration pushes its own changes to, for
+	// projects whose policies require branch names to follow a convention.
+	mw.branchPrefixEntry = widget.NewEntry()
+	mw.branchPrefixEntry.SetPlaceHolder(defaultMigrationBranchPrefix)
+	mw.branchPrefixEntry.SetText(prefs.String("migration.branchPrefix"))
+	mw.branchPrefixEntry.OnChanged = func(prefix string) {
+		mw.badgesCheckbox.Text = "Rewrite Actions badges to Azure Pipelines (branch " + orDefault(prefix, defaultMigrationBranchPrefix) + badgeBranchName + ")"
+		mw.badgesCheckbox.Refresh()
+		mw.pipelinesCheckbox.Text = "Convert Actions workflows to Azure Pipelines (branch " + orDefault(prefix, defaultMigrationBranchPrefix) + pipelineBranchName + ")"
+		mw.pipelinesCheckbox.Refresh()
+		prefs.SetString("migration.branchPrefix", prefix)
+	}
+	mw.branchPrefixEntry.OnChanged(mw.branchPrefixEntry.Text)
+
+	// Azure DevOps settings beyond the provider's own fields.
+	azureForm := mw.targetForms["Azure DevOps"]
+	// Show the API base the organization entry normalizes to.
+	for _, item := range azureForm.Items {
+		if item.Widget != mw.azureOrgEntry {
+			continue
+		}
+		orgItem := item
+		mw.azureOrgEntry.Validator = func(text string) error {
+			if strings.TrimSpace(text) == "" {
+				return nil
+			}
+			_, _, err := normalizeAzureOrg(text)
+			return err
+		}
+		mw.azureOrgEntry.OnChanged = func(text string) {
+			orgItem.HintText = ""
+			if base, note, err := normalizeAzureOrg(text); err == nil {
+				orgItem.HintText = "API base: " + base
+				if note != "" {
+					orgItem.HintText += " (legacy visualstudio.com URL translated)"
+				}
+			}
+			azureForm.Refresh()
+		}
+	}
+	azureForm.Append("Recycled names", mw.recyclePolicySelect)
+	azureForm.Append("", mw.pipelinesCheckbox)
+	azureForm.Append("", mw.badgesCheckbox)
+	azureForm.Append("Migration branches", mw.branchPrefixEntry)
+	mw.targetTypeSelect = widget.NewSelect(targetTypes(), func(selected string) {
+		for name, form := range mw.targetForms {
+			if name == selected {
+				form.Show()
+			} else {
+				form.Hide()
+			}
+		}
+	})
+	mw.targetTypeSelect.SetSelectedIndex(0)
+}
+
+// targetConfig returns the values of target's fields.
+func (mw *mainWindow) targetConfig(target string) map[string]string {
+	cfg := map[string]string{}
+	for key, entry := range mw.targetEntries[target] {
+		cfg[key] = strings.TrimSpace(entry.Text)
+	}
+	return cfg
+}
+
+// azureOrgBase returns the organization as the API base URL.
+func (mw *mainWindow) azureOrgBase() string {
+	if base, _, err := normalizeAzureOrg(mw.azureOrgEntry.Text); err == nil {
+		return base
+	}
+	return strings.TrimSpace(mw.azureOrgEntry.Text)
+}
+
+// enteredAzure returns the Azure DevOps project the target fields name,
+// or nil if its PAT, organization or project is empty.
+func (mw *mainWindow) enteredAzure() *azureTarget {
+	t := &azureTarget{org: mw.azureOrgBase(), project: strings.TrimSpace(mw.azureProjectEntry.Text), token: strings.TrimSpace(mw.azureTokenEntry.Text)}
+	if t.org == "" || t.project == "" || t.token == "" {
 		return nil
 	}
+	return t
+}
 
-	body, _ := ioutil.ReadAll(resp.Body)
+// buildCredentials makes the keychain settings for the GitHub PAT and
+// every target's secret fields, and loads what was remembered.
+func (mw *mainWindow) buildCredentials() {
+	prefs := mw.app.Preferences()
+	entries := map[string]*widget.Entry{credentialKey("GitHub", "token"): mw.githubTokenEntry}
+	for _, f := range targetFactories {
+		for _, field := range f.Fields {
+			if field.Secret {
+				entries[credentialKey(f.Name, field.Key)] = mw.targetEntries[f.Name][field.Key]
+			}
+		}
+	}
+	for key := range entries {
+		mw.credentialKeys = append(mw.credentialKeys, key)
+	}
+	sort.Strings(mw.credentialKeys)
+	mw.credentialEntries = entries
 
-	// Parse JSON response
-	var repos []struct {
-		FullName string `json:"full_name"`
+	mw.rememberCheck = widget.NewCheck("Remember credentials in the OS keychain", nil)
+	if prefs.Bool("credentials.remember") {
+		mw.rememberCheck.SetChecked(true)
+		values, err := loadCredentials(mw.credentialKeys)
+		if err != nil {
+			mw.appendLog(fmt.Sprintf("Warning: could not read credentials from the keychain: %v", err))
+		}
+		for key, value := range values {
+			mw.credentialEntries[key].SetText(value)
+		}
+		if len(values) > 0 {
+			mw.appendLog(fmt.Sprintf("Loaded %d credential(s) from the keychain.", len(values)))
+		}
+		mw.loadGitHubOrgs()
 	}
-	json.Unmarshal(body, &repos)
+	// Set after loading, which would otherwise save the empty entries.
+	mw.rememberCheck.OnChanged = func(on bool) {
+		prefs.SetBool("credentials.remember", on)
+		mw.saveRemembered()
+	}
+	mw.forgetCredentialsBtn = widget.NewButton("Forget Saved Credentials", func() {
+		dialog.ShowConfirm("Forget saved credentials",
+			"Delete the tokens saved in the OS keychain and stop remembering them? The ones typed in now stay for this session.",
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				mw.rememberCheck.SetChecked(false)
+				if err := clearCredentials(mw.credentialKeys); err != nil {
+					mw.appendLog(fmt.Sprintf("Error: could not delete the credentials from the keychain: %v", err))
+					return
+				}
+				mw.appendLog("Saved credentials deleted from the keychain.")
+			}, mw.win)
+	})
+}
 
-	// Extract repository names (format: owner/repo)
-	var repoList []string
-	for _, repo := range repos {
-		repoList = append(repoList, repo.FullName)
+// saveRemembered stores the tokens as they are now, if remembering.
+func (mw *mainWindow) saveRemembered() {
+	if !mw.rememberCheck.Checked {
+		return
+	}
+	values := map[string]string{}
+	for key, entry := range mw.credentialEntries {
+		values[key] = strings.TrimSpace(entry.Text)
+		// A Microsoft session does not outlive the window.
+		if values[key] == entraToken {
+			values[key] = ""
+		}
+	}
+	if err := saveCredentials(values); err != nil {
+		mw.appendLog(fmt.Sprintf("Warning: could not save credentials to the keychain: %v", err))
 	}
+}
 
-	return repoList
+// rememberedEntry returns an entry whose text is remembered in prefs
+// under key and checked with parse as it is typed.
+func rememberedEntry[T any](prefs fyne.Preferences, key, placeHolder string, parse func(string) (T, error)) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder(placeHolder)
+	entry.SetText(prefs.String(key))
+	entry.Validator = func(text string) error {
+		_, err := parse(text)
+		return err
+	}
+	entry.OnChanged = func(text string) {
+		prefs.SetString(key, strings.TrimSpace(text))
+	}
+	return entry
 }
 
-// createAzureRepo creates a new repository in Azure DevOps.
-func createAzureRepo(repoName, org, project, token string) (string, error) {
-	// Construct URL. org should be the URL of your Azure DevOps organization.
-	url := fmt.Sprintf("%s/%s/_apis/git/repositories?api-version=7.0", org, project)
+// buildRunSettings makes the settings of how a run migrates.
+func (mw *mainWindow) buildRunSettings(opts windowOptions) {
+	prefs := mw.app.Preferences()
+	// Optional label for the run, so its report is easy to find later.
+	mw.runTagEntry = widget.NewEntry()
+	mw.runTagEntry.SetPlaceHolder("Run tag (optional, e.g. wave-2)")
+
+	// Branches to migrate; the preview fetches branch lists on demand.
+	mw.branchFilterEntry = widget.NewEntry()
+	mw.branchFilterEntry.SetPlaceHolder("Branches to migrate, e.g. main, release/*, !wip/* (empty: all)")
+	mw.branchFilterEntry.Validator = func(text string) error {
+		_, err := parseBranchFilter(text)
+		return err
+	}
+
+	// What becomes of each repository's local copy once it is verified,
+	// and where kept copies go; both are remembered.
+	mw.copiesDirEntry = widget.NewEntry()
+	mw.copiesDirEntry.SetPlaceHolder(defaultCopiesDir)
+	mw.copiesDirEntry.SetText(prefs.String("cleanup.copiesDir"))
+	mw.copiesDirEntry.OnChanged = func(dir string) {
+		prefs.SetString("cleanup.copiesDir", strings.TrimSpace(dir))
+	}
+	mw.copiesSelect = widget.NewSelect(copyModeNames, func(selected string) {
+		prefs.SetString("cleanup.copies", selected)
+		if selected == copyModeNames[copiesArchive] {
+			mw.copiesDirEntry.Enable()
+		} else {
+			mw.copiesDirEntry.Disable()
+		}
+	})
+	mw.copiesSelect.SetSelected(prefs.StringWithFallback("cleanup.copies", copyModeNames[copiesArchive]))
+	if mw.copiesSelect.SelectedIndex() < 0 {
+		mw.copiesSelect.SetSelectedIndex(int(copiesArchive))
+	}
+	// Copies the old flows left are moved into the layout once.
+	migrateLegacyCopies(context.Background(), mw.currentCopies(), mw.appendLog)
+
+	// Clones of failed repositories are kept as evidence and for a retry,
+	// for a number of days; both settings are remembered.
+	mw.keepFailedCheckbox = widget.NewCheck("Keep clones of failed repositories (in "+failedClonesDir+")", func(checked bool) {
+		prefs.SetBool("cleanup.keepFailed", checked)
+	})
+	mw.keepFailedCheckbox.SetChecked(prefs.BoolWithFallback("cleanup.keepFailed", true))
+	mw.failedDaysEntry = widget.NewEntry()
+	mw.failedDaysEntry.SetPlaceHolder("Days to keep failure clones (0: until deleted by hand)")
+	mw.failedDaysEntry.SetText(strconv.Itoa(prefs.IntWithFallback("cleanup.failedDays", defaultFailedCloneDays)))
+	mw.failedDaysEntry.Validator = func(text string) error {
+		_, err := parseExpiryDays(text)
+		return err
+	}
+	mw.failedDaysEntry.OnChanged = func(text string) {
+		if d, err := parseExpiryDays(text); err == nil {
+			prefs.SetInt("cleanup.failedDays", int(d/(24*time.Hour)))
+		}
+	}
+
+	// What to do when GitHub cannot serve some LFS objects.
+	mw.lfsPolicySelect = widget.NewSelect(lfsPolicyNames, nil)
+	mw.lfsPolicySelect.SetSelectedIndex(int(lfsFailOnMissing))
+
+	// Unchecked, repositories using LFS are pushed with only their pointer
+	// files, with a warning, for when the large objects are not wanted.
+	mw.includeLFSCheck = widget.NewCheck("Include LFS objects", func(checked bool) {
+		if checked {
+			mw.lfsPolicySelect.Enable()
+		} else {
+			mw.lfsPolicySelect.Disable()
+		}
+	})
+	mw.includeLFSCheck.SetChecked(true)
+
+	// How repos archived on GitHub are made read-only in Azure.
+	mw.archiveSelect = widget.NewSelect(archiveModeNames, nil)
+	mw.archiveSelect.SetSelectedIndex(int(archiveDisable))
+
+	// How the content preview recreates GitHub releases, and the feed
+	// universal packages are published to.
+	mw.releaseModeSelect = widget.NewSelect(releaseModeNames, nil)
+	mw.releaseModeSelect.SetSelectedIndex(int(releasesAsFolder))
+	mw.releaseFeedEntry = widget.NewEntry()
+	mw.releaseFeedEntry.SetPlaceHolder("Azure Artifacts feed, for universal packages")
+
+	// What to do when a repository's history contains secrets.
+	mw.secretPolicySelect = widget.NewSelect(secretPolicyNames, nil)
+	mw.secretPolicySelect.SetSelectedIndex(int(secretsReportOnly))
+
+	mw.confirmSecrets = confirmSecretsDialog(mw.win)
+
+	// Which licenses may be hosted in the target, and what to do about
+	// the rest; edited with License Policy..., and asked about per
+	// repository under the confirm gate.
+	mw.confirmLicense = confirmLicenseDialog(mw.win)
+
+	// Identity and signing of commits the tool makes itself.
+	mw.botNameEntry = widget.NewEntry()
+	mw.botNameEntry.SetPlaceHolder(defaultBotName)
+	mw.botEmailEntry = widget.NewEntry()
+	mw.botEmailEntry.SetPlaceHolder(defaultBotEmail)
+	mw.signingSelect = widget.NewSelect(signingFormatNames, nil)
+	mw.signingSelect.SetSelectedIndex(int(signNone))
+	mw.signingKeyEntry = widget.NewEntry()
+	mw.signingKeyEntry.SetPlaceHolder("GPG key id or SSH key file (.pub signs via the agent); empty for git's default")
+	mw.requireSigningCheck = widget.NewCheck("Require signing (fail instead of committing unsigned)", nil)
+
+	// Log file and its format, validated as they are typed.
+	mw.logFileEntry = widget.NewEntry()
+	mw.logFileEntry.SetPlaceHolder("Log file (optional)")
+	mw.logFileEntry.SetText(opts.logFile)
+	mw.logFormatError = widget.NewLabel("")
+	mw.logFormatError.Hide()
+	mw.logFormatEntry = widget.NewEntry()
+	mw.logFormatEntry.SetPlaceHolder("logfmt, json, or a template such as {{.Time.Format \"2006-01-02T15:04:05Z07:00\"}} level={{.Level}} msg={{.Message}}")
+	mw.logFormatEntry.OnChanged = func(spec string) {
+		if _, err := parseLogFormat(spec); err != nil {
+			mw.logFormatError.SetText(err.Error())
+			mw.logFormatError.Show()
+		} else {
+			mw.logFormatError.Hide()
+		}
+	}
+	mw.logFormatEntry.SetText(opts.logFormat)
+	mw.logLevelSelect = widget.NewSelect(logLevelNames, nil)
+	for i, l := range logLevels {
+		if l == opts.logLevel {
+			mw.logLevelSelect.SetSelectedIndex(i)
+		}
+	}
+
+	// Keep the machine awake during runs, with an indicator saying so.
+	mw.keepAwakeCheckbox = widget.NewCheck("Prevent sleep while migrating", nil)
+	mw.keepAwakeCheckbox.SetChecked(true)
+
+	// How many repositories are migrated at the same time.
+	mw.concurrencyEntry = rememberedEntry(prefs, "migration.concurrency", strconv.Itoa(defaultConcurrency), parseConcurrency)
+
+	// How many times a clone, push or creation that fails transiently is
+	// tried.
+	mw.attemptsEntry = rememberedEntry(prefs, "migration.attempts", strconv.Itoa(defaultAttempts), parseAttempts)
+	// How long to wait between those attempts, and between attempts of
+	// API requests.
+	mw.backoffEntry = rememberedEntry(prefs, "migration.backoff", retryPolicy{Delay: defaultRetryDelay, MaxDelay: maxRetryDelay, Jitter: defaultRetryJitter}.String(), parseBackoff)
+
+	// Branches pushed in chunks of commits, for targets and networks that
+	// refuse or time out on big pushes. The size is tuned per host unless
+	// fixed, and what is learned is kept for later runs.
+	mw.chunkSizeEntry = rememberedEntry(prefs, "migration.chunkSize", "0 (push branches whole)", parseChunkSize)
+	mw.chunkFixedCheck = widget.NewCheck("Fixed chunk size (no tuning)", func(checked bool) {
+		prefs.SetBool("migration.chunkFixed", checked)
+	})
+	mw.chunkFixedCheck.SetChecked(prefs.Bool("migration.chunkFixed"))
+
+	// When a git transfer that shows no progress is shown by the data it
+	// moves instead, and when one that moves nothing either is stalled.
+	mw.livenessEntry = rememberedEntry(prefs, "migration.liveness", defaultLiveness.String(), parseLiveness)
+
+	// How long after a repository is verified its refs are checked again.
+	mw.canaryEntry = rememberedEntry(prefs, "migration.canaryMinutes", fmt.Sprintf("%d (0 for none)", defaultCanaryMinutes), parseCanaryDelay)
+
+	// How target names that collide are resolved, strategies in order.
+	mw.collisionsEntry = rememberedEntry(prefs, "migration.collisions", defaultCollisionPolicy.String(), parseCollisionPolicy)
+
+	// Polite mode: one git transfer per host at a time, for shared networks.
+	mw.politeCheckbox = widget.NewCheck("Polite mode (one clone or push per host at a time)", nil)
+	mw.sleepIndicator = widget.NewLabel("Sleep prevented while migrating")
+	mw.sleepIndicator.Hide()
+
+	// A dry run goes through the whole run but only logs what it would
+	// create, clone and push. It writes nothing, so it is allowed in
+	// read-only mode.
+	mw.dryRunCheckbox = widget.NewCheck("Dry run (log what would happen; create, clone and push nothing)", nil)
+
+	// Canary checks outlive the run that scheduled them; the pending ones
+	// are listed so the window is not closed on them.
+	mw.canaryLabel = widget.NewLabel("")
+	mw.canaryLabel.Wrapping = fyne.TextWrapWord
+	mw.canaryLabel.Hide()
+	mw.canary = &canaryChecks{}
+
+	// Who the GitHub token, the Azure DevOps token and generated commits
+	// act as, resolved by Validate and at the start of each run.
+	mw.identitiesLabel = widget.NewLabel("Validate to resolve the identities the run acts as.")
+	mw.identitiesLabel.Wrapping = fyne.TextWrapWord
+}
+
+// currentCopies returns the local copies setting.
+func (mw *mainWindow) currentCopies() localCopies {
+	return localCopies{Mode: copyMode(mw.copiesSelect.SelectedIndex()), Dir: strings.TrimSpace(mw.copiesDirEntry.Text)}
+}
+
+// currentSigner returns how the commits the tool makes are signed.
+func (mw *mainWindow) currentSigner() *commitSigner {
+	return &commitSigner{
+		Name:    strings.TrimSpace(mw.botNameEntry.Text),
+		Email:   strings.TrimSpace(mw.botEmailEntry.Text),
+		Format:  signingFormat(mw.signingSelect.SelectedIndex()),
+		Key:     strings.TrimSpace(mw.signingKeyEntry.Text),
+		Require: mw.requireSigningCheck.Checked,
+	}
+}
+
+// isRunning reports whether a run is active.
+func (mw *mainWindow) isRunning() bool {
+	mw.runMu.Lock()
+	defer mw.runMu.Unlock()
+	return mw.cancelRun != nil
+}
+
+// runMigration migrates the repositories of the source, or with retry,
+// only retry's repositories under the target names they had. It runs on
+// the calling goroutine. The results view shows its report.
+func (mw *mainWindow) runMigration(retry []*repoReport) {
+	mw.runMu.Lock()
+	if mw.cancelRun != nil {
+		mw.runMu.Unlock()
+		mw.appendLog("Error: a migration is already running.")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	mw.cancelRun = cancel
+	mw.runMu.Unlock()
+	mw.updateRunButtons()
+	mw.saveRemembered()
+	defer func() {
+		cancel()
+		mw.runMu.Lock()
+		mw.cancelRun = nil
+		mw.runMu.Unlock()
+		mw.updateRunButtons()
+	}()
+
+	prefs := mw.app.Preferences()
+	appendLog := mw.appendLog
+	runStart := time.Now()
+	dryRun := mw.dryRunCheckbox.Checked
+
+	if mw.keepAwakeCheckbox.Checked {
+		if release, err := inhibitSleep("Migrating repositories"); err != nil {
+			appendLog(fmt.Sprintf("Warning: could not prevent sleep: %v", err))
+		} else {
+			mw.sleepIndicator.Show()
+			defer func() {
+				release()
+				mw.sleepIndicator.Hide()
+			}()
+		}
+	}
+
+	if path := strings.TrimSpace(mw.logFileEntry.Text); path != "" {
+		flog, err := openFileLog(path, mw.logFormatEntry.Text, logLevels[mw.logLevelSelect.SelectedIndex()])
+		if err != nil {
+			appendLog(fmt.Sprintf("Error opening log file: %v", err))
+			return
+		}
+		mw.runLogMu.Lock()
+		mw.runLog = flog
+		mw.runLogMu.Unlock()
+		defer func() {
+			mw.runLogMu.Lock()
+			mw.runLog.Close()
+			mw.runLog = nil
+			mw.runLogMu.Unlock()
+		}()
+	}
 
-	// Create JSON payload
-	payload := map[string]interface{}{
-		"name": repoName,
+	appendLog("Starting migration...")
+	if dryRun {
+		appendLog("Dry run: nothing will be created, cloned or pushed.")
 	}
-	jsonPayload, _ := json.Marshal(payload)
+	appendLog("Run timestamps: " + zoneSummary(runStart))
+	transfers.SetPolite(mw.politeCheckbox.Checked)
+	appendLog(transfers.Describe())
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	githubToken := strings.TrimSpace(mw.githubTokenEntry.Text)
+
+	if githubToken == "" {
+		appendLog("Error: GitHub PAT is required.")
+		return
+	}
+
+	// Who the token belongs to, checked against the profile and
+	// recorded with the run.
+	id, err := getGitHubIdentity(ctx, githubToken)
+	if id == nil {
+		appendLog(fmt.Sprintf("Error: could not look up the GitHub token's user: %v", err))
+		return
+	}
+	appendLog("GitHub identity: " + id.Login)
+	if warning := id.ownerWarning(mw.expectedOwnerEntry.Text, sourceOrg(mw.githubOrgSelect.Text)); warning != "" {
+		appendLog("WARNING: " + warning)
+		if !mw.confirmIdentity(warning, id.Login) {
+			appendLog("Migration cancelled: unexpected GitHub identity.")
+			return
+		}
+		appendLog(fmt.Sprintf("Migrating as %s, confirmed.", id.Login))
+	}
+
+	target, err := newTarget(mw.targetTypeSelect.Selected, mw.targetConfig(mw.targetTypeSelect.Selected), appendLog)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	if az, ok := target.(*azureTarget); ok && !mw.checkAzureTarget(ctx, az, dryRun) {
+		return
+	}
+
+	// The source, for cloning the checked repositories.
+	source := strings.TrimSpace(mw.githubOrgSelect.Text)
+	org := sourceOrg(source)
+	prefs.SetString("github.source", source)
+	from, err := newSource("GitHub", map[string]string{"token": githubToken, "org": org})
 	if err != nil {
-		return "", err
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
 	}
+	var repos []string
+	if retry != nil {
+		for _, r := range retry {
+			repos = append(repos, r.Source)
+		}
+		appendLog(fmt.Sprintf("Retrying %d repositories.", len(repos)))
+	} else {
+		repos = mw.picker.Selected()
+		if len(repos) == 0 {
+			appendLog("Error: no repositories selected; fetch the repositories and check the ones to migrate.")
+			return
+		}
+		appendLog(fmt.Sprintf("Migrating %d selected repositories.", len(repos)))
+	}
+
+	signer := mw.currentSigner()
 
-	// Authenticate with Azure PAT (using empty username)
-	req.SetBasicAuth("", token)
-	req.Header.Set("Content-Type", "application/json")
+	// The three identities go in the log, the panel, the report and
+	// every audit record.
+	az, _ := target.(*azureTarget)
+	ids, _ := resolveIdentities(ctx, id.Login, az, signer, appendLog)
+	for _, line := range ids.lines() {
+		appendLog("Identity: " + line)
+	}
+	mw.identitiesLabel.SetText(strings.Join(ids.lines(), "\n"))
+	setAuditIdentities(ids)
+	defer setAuditIdentities(runIdentities{})
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	expiry, err := parseExpiryDays(mw.failedDaysEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	filter, err := parseBranchFilter(mw.branchFilterEntry.Text)
 	if err != nil {
-		return "", err
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	badgeBranch, err := migrationBranch(ctx, orDefault(mw.branchPrefixEntry.Text, defaultMigrationBranchPrefix), badgeBranchName)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	pipelineBranch, err := migrationBranch(ctx, orDefault(mw.branchPrefixEntry.Text, defaultMigrationBranchPrefix), pipelineBranchName)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	concurrency, err := parseConcurrency(mw.concurrencyEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	collisions, err := parseCollisionPolicy(mw.collisionsEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	attempts, err := parseAttempts(mw.attemptsEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	retries, err := parseBackoff(mw.backoffEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	retries.Attempts = attempts
+	setAPIRetry(retries, appendLog)
+	setGitHubRateLimitNotify(func(resource string, until time.Time) {
+		appendLog(gitHubRateLimitMessage(resource, until))
+		mw.progress.RateLimit(resource, until)
+	})
+	chunkSize, err := parseChunkSize(mw.chunkSizeEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	liveness, err := parseLiveness(mw.livenessEntry.Text)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	mw.tails.SetLiveness(liveness, mw.progress.Transfer, func(repo, state string) {
+		appendLog(fmt.Sprintf("Warning: the git transfer of %s is %s; cancel the run if it does not recover.", repo, state))
+	})
+	chunks := newChunkTuner(chunkSize, mw.chunkFixedCheck.Checked, parseLearnedChunkSizes(prefs.String("migration.chunkLearned")))
+	if !dryRun {
+		chunks.OnLearn = func(learned map[string]int) {
+			prefs.SetString("migration.chunkLearned", formatLearnedChunkSizes(learned))
+		}
+	}
+	appendLog(chunks.Describe())
+	if mw.canary.Delay, err = parseCanaryDelay(mw.canaryEntry.Text); err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
 	}
-	defer resp.Body.Close()
+	if !dryRun {
+		appendLog(mw.canary.Describe())
+	}
+	licenses, err := parseLicensePolicy(prefs.String("migration.licensePolicy"))
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	// The policy may only apply to private projects; one whose
+	// visibility cannot be read is taken to be private.
+	privateProject := true
+	if az, ok := target.(*azureTarget); ok && licenses != nil && licenses.PrivateOnly {
+		if privateProject, err = azureProjectPrivate(ctx, az.org, az.project, az.token); err != nil {
+			appendLog(fmt.Sprintf("Warning: could not read the visibility of %s, taking it to be private: %v", az.project, err))
+		}
+	}
+	if licenses != nil {
+		appendLog("License policy: " + licenses.String() + ".")
+	}
+	cleanup := cleanupPolicy{Copies: mw.currentCopies(), KeepFailed: mw.keepFailedCheckbox.Checked, FailedExpiry: expiry}
 
-	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("Azure API error: %s", resp.Status)
+	doc := &migrationDoc{}
+	report := newRunReport(runStart, strings.TrimSpace(mw.runTagEntry.Text), target.Name())
+	report.GitHubLogin = id.Login
+	report.Identities = &ids
+	if !dryRun {
+		if err := writeAudit("run-start", target.Name(), "run "+report.ID); err != nil {
+			appendLog(fmt.Sprintf("Warning: could not write the audit log: %v", err))
+		}
+	}
+	if az, ok := target.(*azureTarget); ok {
+		az.runID = report.ID
 	}
 
-	// Parse response to get repository URL
-	var result struct {
-		RemoteUrl string `json:"remoteUrl"`
+	// A dry run flags the target names already taken. ADO compares
+	// names case-insensitively.
+	var existing map[string]string
+	if az, ok := target.(*azureTarget); ok && dryRun {
+		if list, err := listAzureRepos(ctx, az.org, az.project, az.token); err != nil {
+			appendLog(fmt.Sprintf("Warning: could not list the repositories of %s, collisions with them are not checked: %v", az.project, err))
+		} else {
+			existing = targetRepoNames(list)
+		}
+	} else if dryRun {
+		appendLog(fmt.Sprintf("Warning: collisions with existing %s repositories are not checked.", target.Name()))
 	}
-	body, _ := ioutil.ReadAll(resp.Body)
-	json.Unmarshal(body, &result)
 
-	// Insert PAT into URL for authentication (if desired)
-	remoteURL := strings.Replace(result.RemoteUrl, "dev.azure.com", fmt.Sprintf("%s@dev.azure.com", token), 1)
+	// Target names are unique case-insensitively, so "Tools" and
+	// "tools" from different owners cannot both keep their name.
+	// A retry keeps the name the repository was given, which an
+	// earlier attempt may have created.
+	kept := map[string]string{}
+	for _, r := range retry {
+		if r.Target != "" {
+			kept[r.Source] = r.Target
+		}
+	}
+	names := newNameAllocator(collisions)
+	targetNameOf, strategyOf, err := targetNames(repos, names, kept)
+	if err != nil {
+		appendLog(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	report.CollisionPolicy = collisions.String()
+	if licenses != nil {
+		report.LicensePolicy = licenses.String()
+	}
+	for _, repo := range repos {
+		if s, ok := strategyOf[repo]; ok {
+			appendLog(fmt.Sprintf("Migrating %s as %s (collision strategy %s).", repo, targetNameOf[repo], s))
+		}
+	}
 
-	return remoteURL, nil
-}
+	mw.dashboard.RunStarted(report.ID, target.Name(), repos)
+	defer mw.dashboard.RunFinished()
 
-func main() {
-	// Create the Fyne app and window.
-	a := app.New()
-	w := a.NewWindow("GitHub to Azure Migration")
-	w.Resize(fyne.NewSize(600, 500))
+	// Process each repository.
+	// Repositories marked won't migrate after an earlier run stay
+	// skipped; retrying them is how the decision is undone.
+	wontMigrate := map[string]string{}
+	if retry == nil {
+		if wontMigrate, err = wontMigrateDecisions(); err != nil {
+			appendLog(fmt.Sprintf("Warning: could not read earlier run reports: %v", err))
+		}
+	}
 
-	// Create a binding for the logs.
-	logBinding := binding.NewString()
-	logEntry := widget.NewMultiLineEntry()
-	logEntry.Bind(logBinding)
-	logEntry.SetPlaceHolder("Logs will appear here...")
-	logEntry.Wrapping = fyne.TextWrapWord
-	logEntry.Disable() // make read-only
-
-	// Helper function to append log messages.
-	appendLog := func(msg string) {
-		// Prepend timestamp
-		timestamp := time.Now().Format("15:04:05")
-		newLog := fmt.Sprintf("[%s] %s\n", timestamp, msg)
-		current, _ := logBinding.Get()
-		// Update binding (thread-safe)
-		logBinding.Set(current + newLog)
+	// Report entries are made up front, in order, so the report lists
+	// repositories in the order given whatever order they finish in.
+	resultOf := map[string]*repoReport{}
+	for _, repo := range repos {
+		result := &repoReport{Source: repo, Target: targetNameOf[repo], NameStrategy: strategyOf[repo]}
+		report.Repos = append(report.Repos, result)
+		resultOf[repo] = result
+	}
+	// Every repository is pending until it finishes, so a run that
+	// dies leaves the ones it did not get to for Resume.
+	if !dryRun {
+		if err := recordPending(report.ID, report.Repos); err != nil {
+			appendLog(fmt.Sprintf("Warning: could not record the run in %s, Resume will not know what it leaves unfinished: %v", migrationStatePath, err))
+		}
+	}
+	run := &migrationRun{
+		GitHubToken:    githubToken,
+		Source:         from,
+		Target:         target,
+		Report:         report,
+		Doc:            doc,
+		Secrets:        secretPolicy(mw.secretPolicySelect.SelectedIndex()),
+		ConfirmSecrets: mw.confirmSecrets,
+		Recycle:        recyclePolicy(mw.recyclePolicySelect.SelectedIndex()),
+		ConfirmPurge:   mw.confirmPurge,
+		LFS:            lfsPolicy(mw.lfsPolicySelect.SelectedIndex()),
+		SkipLFS:        !mw.includeLFSCheck.Checked,
+		Archive:        archiveMode(mw.archiveSelect.SelectedIndex()),
+		Filter:         filter,
+		Pipelines:      mw.pipelinesCheckbox.Checked,
+		PipelineBranch: pipelineBranch,
+		Badges:         mw.badgesCheckbox.Checked,
+		BadgeBranch:    badgeBranch,
+		Signer:         signer,
+		Cleanup:        cleanup,
+		Retry:          retries,
+		Chunks:         chunks,
+		Canary:         mw.canary,
+		Splits:         mw.splits,
+		Areas:          mw.areas,
+		Branches:       mw.branches,
+		Retained:       mw.retained,
+		Names:          names,
+		WontMigrate:    wontMigrate,
+		DryRun:         dryRun,
+		Existing:       existing,
+		Licenses:       licenses,
+		PrivateProject: privateProject,
+		ConfirmLicense: mw.confirmLicense,
+	}
+	migrateOne := func(ctx context.Context, repo string) {
+		repoStart := time.Now()
+		var scope *logScope
+		scope = &logScope{notify: func(current, phase string) {
+			mw.dashboard.RepoPhase(current, phase)
+			mw.progress.Phase(repo, phase)
+			mw.logIn(scope, fmt.Sprintf("Debug: %s entered the %s phase.", current, phase))
+		}}
+		tail := mw.tails.Start(repo)
+		status := run.Migrate(ctx, repo, resultOf[repo], scope, tail, func(msg string) { mw.logIn(scope, msg) })
+		tail.Finish(status)
+		mw.dashboard.RepoFinished(repo, status, time.Since(repoStart))
+		if msg := resultOf[repo].Error; msg != "" {
+			mw.progress.SetError(repo, msg)
+		}
+		mw.progress.Finish(repo, status, time.Since(repoStart))
+	}
+
+	appendLog(fmt.Sprintf("Migrating %d repositories, %d at a time.", len(repos), concurrency))
+	mw.progress.Start(repos)
+	m := &migrator{
+		Migrate: migrateOne,
+		Cancelled: func(repo string) {
+			resultOf[repo].Status = statusCancelled
+			mw.dashboard.RepoFinished(repo, statusCancelled, 0)
+			mw.progress.Finish(repo, statusCancelled, 0)
+		},
+	}
+	m.Run(ctx, repos, concurrency)
+	if ctx.Err() != nil {
+		appendLog("Migration cancelled.")
+	}
+
+	appendLog(fmt.Sprintf("Migration completed in %s.", formatDuration(time.Since(runStart))))
+
+	// A dry run leaves no report behind; the log is its outcome.
+	if dryRun {
+		for _, line := range dryRunSummary(report) {
+			appendLog(line)
+		}
+		return
+	}
+
+	if !doc.Empty() {
+		wikiURL := ""
+		if az, ok := target.(*azureTarget); ok {
+			var err error
+			if wikiURL, err = projectWikiURL(ctx, az.org, az.project, az.token); err != nil {
+				appendLog(fmt.Sprintf("Warning: could not look up the project wiki: %v", err))
+			}
+		}
+		if path, err := doc.save(report.ID, wikiURL); err != nil {
+			appendLog(fmt.Sprintf("Error saving MIGRATION.md: %v", err))
+		} else {
+			report.MigrationDoc = path
+			appendLog(fmt.Sprintf("MIGRATION.md saved to %s.", path))
+		}
 	}
 
-	// Create input fields for GitHub and Azure details.
-	githubTokenEntry := widget.NewEntry()
-	githubTokenEntry.SetPlaceHolder("GitHub PAT Token")
+	report.Finished = fileTimestamp(time.Now())
+	if path, err := report.save(); err != nil {
+		appendLog(fmt.Sprintf("Error saving run report: %v", err))
+	} else {
+		appendLog(fmt.Sprintf("Run report saved to %s; Save Report in Results exports it as CSV, JSON or HTML.", path))
+	}
+	mw.results.Show(report)
+	mw.logRunOutcome(report)
+}
 
-	azureTokenEntry := widget.NewEntry()
-	azureTokenEntry.SetPlaceHolder("Azure DevOps PAT Token")
+// checkAzureTarget checks, before a run into az, that its project has
+// git and that the token can write to it. A TFVC-only project fails here
+// rather than on every repository, and a token without write scope
+// switches to read-only mode rather than failing on the first creation;
+// a dry run only warns of that. It reports whether the run may go on.
+func (mw *mainWindow) checkAzureTarget(ctx context.Context, az *azureTarget, dryRun bool) bool {
+	if sc, err := getProjectSourceControl(ctx, az.org, az.project, az.token); err != nil {
+		mw.appendLog(fmt.Sprintf("Warning: could not check the version control of %s: %v", az.project, err))
+	} else if err := sc.check(az.project); err != nil {
+		mw.appendLog(fmt.Sprintf("Error: %v", err))
+		return false
+	} else {
+		mw.appendLog(fmt.Sprintf("Azure DevOps project %s: %s.", az.project, sc))
+	}
 
-	azureOrgEntry := widget.NewEntry()
-	azureOrgEntry.SetPlaceHolder("Azure Organization URL (e.g. https://dev.azure.com/yourOrg)")
+	if err := probeAzureWrite(ctx, az.org, az.project, az.token); err != nil && dryRun {
+		mw.appendLog(fmt.Sprintf("Warning: the Azure token cannot write, a real run would switch to read-only mode: %v", err))
+	} else if err != nil {
+		mw.appendLog(fmt.Sprintf("Switching to read-only mode, the Azure token cannot write: %v", err))
+		mw.readOnlyCheckbox.SetChecked(true)
+		return false
+	}
+	return true
+}
 
-	azureProjectEntry := widget.NewEntry()
-	azureProjectEntry.SetPlaceHolder("Azure Project Name")
+// logRunOutcome logs the summary of a finished run and what in it needs
+// someone's attention.
+func (mw *mainWindow) logRunOutcome(report *runReport) {
+	appendLog := mw.appendLog
+	appendLog(fmt.Sprintf("%d migrated, %d of them with warnings; %d failed.",
+		report.Summary.Migrated, report.Summary.Warnings, report.Summary.Failed))
+	for _, r := range report.Repos {
+		if r.Pipelines != nil && r.Pipelines.Manual != "" {
+			appendLog(fmt.Sprintf("Apply by hand: Azure Pipelines converted from the workflows of %s, from %s.", r.Source, r.Pipelines.Manual))
+		}
+		if r.Badges != nil && r.Badges.Manual != "" {
+			appendLog(fmt.Sprintf("Apply by hand: README badges of %s, from %s.", r.Source, r.Badges.Manual))
+		}
+	}
+	for _, r := range report.Repos {
+		for _, warning := range r.ServerWarnings {
+			appendLog(fmt.Sprintf("Server warning for %s: %s", r.Source, warning))
+		}
+	}
+	for _, r := range report.Repos {
+		if r.LFSSkipped {
+			appendLog(fmt.Sprintf("LFS objects not migrated: %s uses Git LFS, only its pointer files were pushed.", r.Source))
+		}
+	}
+	for _, r := range report.Repos {
+		switch {
+		case r.CurrentSource != "":
+			appendLog(fmt.Sprintf("Source changed during the run: %s is now %s.", r.Source, r.CurrentSource))
+		case r.Status == statusSourceRemoved:
+			appendLog(fmt.Sprintf("Source changed during the run: %s was removed.", r.Source))
+		}
+	}
+	if report.Summary.SecretFindings > 0 {
+		appendLog(fmt.Sprintf("Secret scan: %d possible secret(s) in %d repositories, see the security section of the report.",
+			report.Summary.SecretFindings, report.Summary.ReposWithSecrets))
+	}
+}
 
-	// Checkbox for "Don't save local clone"
-	dontSaveCheckbox := widget.NewCheck("Don't save local clone", nil)
+// buildResults makes the view of the latest run's results, with bulk
+// actions on selected repositories, and reports the canary checks as they
+// come in.
+func (mw *mainWindow) buildResults() {
+	mw.results = newResultsView(mw.win, mw.tails, mw.runMigration, mw.appendLog)
+	if reports, err := listReports(); err == nil && len(reports) > 0 {
+		mw.results.Show(reports[len(reports)-1])
+	}
+	mw.canary.OnResult = func(result *repoReport, problems []string, err error) {
+		switch {
+		case err != nil:
+			mw.appendLog(fmt.Sprintf("Warning: the canary check of %s could not run: %v", result.Source, err))
+		case len(problems) > 0:
+			mw.appendLog(fmt.Sprintf("Error: late verification of %s failed, %d ref(s) differ on the target:", result.Source, len(problems)))
+			for _, p := range problems {
+				mw.appendLog("  " + p)
+			}
+			if err := writeAudit("late-verification-failed", result.Target, strings.Join(problems, "; ")); err != nil {
+				mw.appendLog(fmt.Sprintf("Warning: could not write the audit log: %v", err))
+			}
+			dialog.ShowError(fmt.Errorf("late verification of %s failed: %d ref(s) on the target no longer match what was pushed; see the log", result.Source, len(problems)), mw.win)
+		default:
+			mw.appendLog(fmt.Sprintf("Canary check of %s: its refs on the target still match what was pushed.", result.Source))
+		}
+		mw.results.refresh()
+	}
+	mw.canary.OnChange = func() {
+		pending := mw.canary.Pending()
+		if len(pending) == 0 {
+			mw.canaryLabel.Hide()
+			return
+		}
+		mw.canaryLabel.SetText(fmt.Sprintf("Canary checks pending, keep the app open: %s", strings.Join(pending, ", ")))
+		mw.canaryLabel.Show()
+	}
+}
 
+// buildRunButtons makes the buttons that validate the settings and start,
+// resume and cancel runs.
+func (mw *mainWindow) buildRunButtons() {
 	// Migrate button
-	migrateBtn := widget.NewButton("Migrate", func() {
+	mw.migrateBtn = widget.NewButton("Migrate", func() {
 		// Run the migration in a separate goroutine so the UI remains responsive.
-		go func() {
-			appendLog("Starting migration...")
+		go mw.runMigration(nil)
+	})
+	mw.resumeBtn = widget.NewButton("Resume", mw.resume)
+	// Nothing is migrated until repositories are checked.
+	// Cancel kills the git commands in flight and leaves the queued
+	// repositories cancelled.
+	mw.cancelBtn = widget.NewButton("Cancel", func() {
+		mw.runMu.Lock()
+		if mw.cancelRun != nil {
+			mw.cancelRun()
+			mw.appendLog("Cancelling the migration...")
+		}
+		mw.runMu.Unlock()
+	})
+	mw.validationNote = widget.NewLabel("")
+	mw.validationNote.Wrapping = fyne.TextWrapWord
+	mw.validationNote.Hide()
+	mw.validateBtn = widget.NewButton("Validate", mw.validate)
+	mw.picker.OnChanged = func(int) { mw.updateRunButtons() }
+	mw.dryRunCheckbox.OnChanged = func(bool) { mw.updateRunButtons() }
+	mw.updateRunButtons()
+}
 
-			githubToken := strings.TrimSpace(githubTokenEntry.Text)
-			azureToken := strings.TrimSpace(azureTokenEntry.Text)
-			azureOrg := strings.TrimSpace(azureOrgEntry.Text)
-			azureProject := strings.TrimSpace(azureProjectEntry.Text)
+// resume migrates again the repositories whose last outcome, in any
+// earlier run, was a failure, and those a crashed, disconnected or
+// cancelled run left unfinished, under the names they had. Those already
+// migrated are skipped.
+func (mw *mainWindow) resume() {
+	state, err := loadMigrationState()
+	if err != nil {
+		mw.appendLog(fmt.Sprintf("Error reading %s: %v", migrationStatePath, err))
+		return
+	}
+	resumable := state.Resumable()
+	if len(resumable) == 0 {
+		mw.appendLog(fmt.Sprintf("Nothing to resume: no repository failed or was left unfinished in its last run (%s).", migrationStatePath))
+		return
+	}
+	var retry []*repoReport
+	for _, s := range resumable {
+		if s.Status != statusFailed {
+			mw.appendLog(fmt.Sprintf("Resuming %s, left %s by run %s.", s.Source, s.Status, s.Run))
+		}
+		retry = append(retry, &repoReport{Source: s.Source, Target: s.Target})
+		if s.Copy != "" {
+			mw.appendLog(fmt.Sprintf("The local copy of %s from its last run is in %s.", s.Source, s.Copy))
+		}
+	}
+	go mw.runMigration(retry)
+}
 
-			if githubToken == "" || azureToken == "" || azureOrg == "" || azureProject == "" {
-				appendLog("Error: All fields are required.")
-				return
+// validate tries the inputs against GitHub, the target and the local git,
+// and shows the identities the run would act as. A failure blocks
+// Migrate, with the reason, until it passes.
+func (mw *mainWindow) validate() {
+	githubToken := strings.TrimSpace(mw.githubTokenEntry.Text)
+	targetType := mw.targetTypeSelect.Selected
+	azureToken := strings.TrimSpace(mw.azureTokenEntry.Text)
+	azureOrg := mw.azureOrgBase()
+	azureProject := strings.TrimSpace(mw.azureProjectEntry.Text)
+	signer := mw.currentSigner()
+	go func() {
+		mw.appendLog("Validating the inputs...")
+		githubCheck, login := checkGitHubToken(context.Background(), githubToken)
+		checks := []preflightCheck{githubCheck}
+		var az *azureTarget
+		if targetType == "Azure DevOps" {
+			projectCheck := checkAzureProject(context.Background(), azureOrg, azureProject, azureToken)
+			checks = append(checks, projectCheck)
+			if projectCheck.Err == nil {
+				az = &azureTarget{org: azureOrg, project: azureProject, token: azureToken}
 			}
+		} else {
+			mw.appendLog(fmt.Sprintf("Validate: %s target not checked, only Azure DevOps projects are.", targetType))
+		}
+		checks = append(checks, checkGit())
+		for _, c := range checks {
+			mw.appendLog("Validate: " + c.String())
+		}
 
-			// Fetch GitHub repositories.
-			appendLog("Fetching repositories from GitHub...")
-			repos := getGitHubRepos("", githubToken)
-			if repos == nil || len(repos) == 0 {
-				appendLog("No repositories found or error occurred while fetching repos.")
-				return
+		// The identities, and whether the Azure DevOps one may do
+		// what later features need; a warning, not a failure.
+		ids, adoID := resolveIdentities(context.Background(), login, az, signer, mw.appendLog)
+		panel := ids.lines()
+		for _, line := range panel {
+			mw.appendLog("Validate: identity: " + line)
+		}
+		if az != nil {
+			if warning := azurePermissionWarning(context.Background(), az.org, az.project, az.token, adoID); warning != "" {
+				mw.appendLog("Validate: WARNING: " + warning)
+				panel = append(panel, "Warning: "+warning)
 			}
-			appendLog(fmt.Sprintf("Found %d repositories.", len(repos)))
-
-			// Process each repository.
-			for _, repo := range repos {
-				appendLog(fmt.Sprintf("Migrating repository: %s", repo))
-				// Create new repo in Azure DevOps.
-				azureRepoURL, err := createAzureRepo(repo, azureOrg, azureProject, azureToken)
-				if err != nil {
-					appendLog(fmt.Sprintf("Error creating Azure repo for %s: %v", repo, err))
-					continue
-				}
-				appendLog(fmt.Sprintf("Created Azure repo: %s", azureRepoURL))
+		}
+		mw.identitiesLabel.SetText(strings.Join(panel, "\n"))
+		block := ""
+		if f := firstFailure(checks); f != nil {
+			block = fmt.Sprintf("%s: %v", f.Name, f.Err)
+			mw.validationNote.SetText(fmt.Sprintf("Migrate is blocked, validation failed (%s). Fix it and validate again.", block))
+			mw.validationNote.Show()
+			mw.appendLog("Validation failed; Migrate is blocked until validation passes.")
+		} else {
+			mw.validationNote.Hide()
+			mw.appendLog("Validation passed.")
+		}
+		mw.runMu.Lock()
+		mw.validationBlock = block
+		mw.runMu.Unlock()
+		mw.updateRunButtons()
+	}()
+}
 
-				// Construct GitHub repo URL with token for authentication.
-				// Note: Including the token in the URL can be a security risk in production.
-				githubRepoURL := fmt.Sprintf("https://%s@github.com/%s.git", githubToken, repo)
+// updateRunButtons enables what may start or stop a run.
+func (mw *mainWindow) updateRunButtons() {
+	running := mw.isRunning()
+	mw.runMu.Lock()
+	blocked := mw.validationBlock != ""
+	mw.runMu.Unlock()
+	if running || blocked || (isReadOnly() && !mw.dryRunCheckbox.Checked) || len(mw.picker.Selected()) == 0 {
+		mw.migrateBtn.Disable()
+	} else {
+		mw.migrateBtn.Enable()
+	}
+	if running || blocked || isReadOnly() {
+		mw.resumeBtn.Disable()
+	} else {
+		mw.resumeBtn.Enable()
+	}
+	if running {
+		mw.cancelBtn.Enable()
+	} else {
+		mw.cancelBtn.Disable()
+	}
+	mw.results.SetRetryEnabled(!running && !isReadOnly())
+}
 
-				// Create a temporary directory for the bare clone.
-				tempDir, err := ioutil.TempDir("", strings.ReplaceAll(repo, "/", "_"))
-				if err != nil {
-					appendLog(fmt.Sprintf("Error creating temporary directory for %s: %v", repo, err))
-					continue
-				}
-				appendLog(fmt.Sprintf("Cloning repository into %s", tempDir))
-
-				// Clone the repository as a bare clone.
-				cloneCmd := exec.Command("git", "clone", "--bare", githubRepoURL, tempDir)
-				if output, err := cloneCmd.CombinedOutput(); err != nil {
-					appendLog(fmt.Sprintf("Error cloning %s: %v, output: %s", repo, err, string(output)))
-					// Clean up tempDir if clone fails.
-					os.RemoveAll(tempDir)
-					continue
-				}
+// buildReadOnly makes the read-only audit mode, in which only listing,
+// analysis, verification and reports are available; apiClient refuses
+// any other request regardless. It disables the maintenance action
+// that brings archived repositories back into use, made here too.
+func (mw *mainWindow) buildReadOnly() {
+	mw.releaseBtn = widget.NewButton("Re-enable Archived Repos...", mw.showReenableArchived)
+	mw.readOnlyNote = widget.NewLabel("Read-only mode: Migrate and Re-enable are disabled and no write requests are sent. Comparing runs and reports still work.")
+	mw.readOnlyNote.Wrapping = fyne.TextWrapWord
+	mw.readOnlyNote.Hide()
+	mw.readOnlyCheckbox = widget.NewCheck("Read-only audit mode (tokens without write scope)", func(checked bool) {
+		setReadOnly(checked)
+		mw.updateRunButtons()
+		if checked {
+			mw.releaseBtn.Disable()
+			mw.readOnlyNote.Show()
+		} else {
+			mw.releaseBtn.Enable()
+			mw.readOnlyNote.Hide()
+		}
+	})
+}
 
-				// Add Azure remote.
-				remoteAddCmd := exec.Command("git", "-C", tempDir, "remote", "add", "azure", azureRepoURL)
-				if output, err := remoteAddCmd.CombinedOutput(); err != nil {
-					appendLog(fmt.Sprintf("Error adding Azure remote for %s: %v, output: %s", repo, err, string(output)))
-					os.RemoveAll(tempDir)
-					continue
+// showReenableArchived brings repos that were archived back into use in
+// Azure.
+func (mw *mainWindow) showReenableArchived() {
+	namesEntry := widget.NewMultiLineEntry()
+	namesEntry.SetPlaceHolder("Azure repo names, one per line")
+	dialog.ShowForm("Re-enable archived repos", "Re-enable", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Repos", namesEntry)},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			azureToken := strings.TrimSpace(mw.azureTokenEntry.Text)
+			azureOrg := mw.azureOrgBase()
+			azureProject := strings.TrimSpace(mw.azureProjectEntry.Text)
+			go func() {
+				for _, name := range strings.Split(namesEntry.Text, "\n") {
+					name = strings.TrimSpace(name)
+					if name == "" {
+						continue
+					}
+					if err := releaseArchivedState(context.Background(), azureOrg, azureProject, name, azureToken); err != nil {
+						mw.appendLog(fmt.Sprintf("Error re-enabling %s: %v", name, err))
+					} else {
+						mw.appendLog(fmt.Sprintf("Re-enabled %s for pushes.", name))
+					}
 				}
+			}()
+		}, mw.win)
+}
 
-				// Push all branches.
-				pushAllCmd := exec.Command("git", "-C", tempDir, "push", "azure", "--all")
-				if output, err := pushAllCmd.CombinedOutput(); err != nil {
-					appendLog(fmt.Sprintf("Error pushing branches for %s: %v, output: %s", repo, err, string(output)))
-					os.RemoveAll(tempDir)
-					continue
-				}
+// showSplitPlans edits the split plans: which subdirectories of a
+// monorepo become which target repositories.
+func (mw *mainWindow) showSplitPlans() {
+	plansEntry := widget.NewMultiLineEntry()
+	plansEntry.SetPlaceHolder("owner/repo subdir target, one per line; * as subdir for the rest")
+	plansEntry.SetText(mw.splits.String())
+	plansEntry.SetMinRowsVisible(8)
+	dialog.ShowForm("Split plans (rewrites history)", "Save", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Parts", plansEntry)},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			plans, err := parseSplitPlans(plansEntry.Text)
+			if err != nil {
+				dialog.ShowError(err, mw.win)
+				return
+			}
+			mw.splits.Set(plans)
+			mw.appendLog(fmt.Sprintf("Split plans set for %d repositories. Split repositories get rewritten history.", len(plans)))
+		}, mw.win)
+}
 
-				// Push tags.
-				pushTagsCmd := exec.Command("git", "-C", tempDir, "push", "azure", "--tags")
-				if output, err := pushTagsCmd.CombinedOutput(); err != nil {
-					appendLog(fmt.Sprintf("Error pushing tags for %s: %v, output: %s", repo, err, string(output)))
-					os.RemoveAll(tempDir)
-					continue
+// showContentPreview previews, then applies, what the post-migration
+// features would change for repositories already migrated.
+func (mw *mainWindow) showContentPreview() {
+	target := mw.enteredAzure()
+	githubToken := strings.TrimSpace(mw.githubTokenEntry.Text)
+	if target == nil || githubToken == "" {
+		dialog.ShowInformation("Content preview", "Fill in the GitHub PAT and the Azure PAT, organization and project first.", mw.win)
+		return
+	}
+	features := []*contentFeature{
+		areaPathFeature(mw.areas),
+		archivedStateFeature(archiveMode(mw.archiveSelect.SelectedIndex())),
+		branchPoliciesFeature(),
+		issuesFeature(mw.areas),
+		openPullsFeature(),
+		closedPullsFeature(),
+		wikiFeature(),
+		releasesFeature(releaseMode(mw.releaseModeSelect.SelectedIndex()), strings.TrimSpace(mw.releaseFeedEntry.Text)),
+		webhooksFeature(),
+	}
+	showContentPreview(mw.win, target, features, githubToken, mw.appendLog)
+}
+
+// showGitCommandsPreview previews the git commands a run would run per
+// repository, from the same code that runs them: for the checked
+// repositories, or all listed if none is checked.
+func (mw *mainWindow) showGitCommandsPreview() {
+	repos := mw.picker.Selected()
+	if len(repos) == 0 {
+		repos = mw.picker.Listed()
+	}
+	showGitCommands(mw.win, repos, func() (*migrationRun, []string, error) {
+		githubToken := strings.TrimSpace(mw.githubTokenEntry.Text)
+		known := []string{githubToken}
+		for _, f := range targetFactories {
+			for _, field := range f.Fields {
+				if field.Secret {
+					known = append(known, mw.targetEntries[f.Name][field.Key].Text)
 				}
+			}
+		}
+		from, err := newSource("GitHub", map[string]string{"token": githubToken, "org": sourceOrg(mw.githubOrgSelect.Text)})
+		if err != nil {
+			return nil, known, err
+		}
+		filter, err := parseBranchFilter(mw.branchFilterEntry.Text)
+		if err != nil {
+			return nil, known, err
+		}
+		chunkSize, err := parseChunkSize(mw.chunkSizeEntry.Text)
+		if err != nil {
+			return nil, known, err
+		}
+		return &migrationRun{
+			Source:  from,
+			Filter:  filter,
+			LFS:     lfsPolicy(mw.lfsPolicySelect.SelectedIndex()),
+			SkipLFS: !mw.includeLFSCheck.Checked,
+			Chunks:  newChunkTuner(chunkSize, mw.chunkFixedCheck.Checked, parseLearnedChunkSizes(mw.app.Preferences().String("migration.chunkLearned"))),
+			Splits:  mw.splits,
+		}, known, nil
+	}, mw.appendLog)
+}
 
-				appendLog(fmt.Sprintf("Successfully migrated %s to Azure.", repo))
+// toolButtons makes the buttons of the actions besides running: on
+// clones and repositories already migrated, previews, and checks.
+func (mw *mainWindow) toolButtons() []fyne.CanvasObject {
+	// azureRequired runs fn with the Azure DevOps project the fields name,
+	// or says what is missing.
+	azureRequired := func(title string, fn func(*azureTarget)) func() {
+		return func() {
+			target := mw.enteredAzure()
+			if target == nil {
+				dialog.ShowInformation(title, "Fill in the Azure PAT, organization and project first.", mw.win)
+				return
+			}
+			fn(target)
+		}
+	}
+	return []fyne.CanvasObject{
+		// Delete clones that were kept because verification failed.
+		widget.NewButton("Delete Unverified Clones...", func() {
+			confirmDeleteRetained(mw.win, mw.retained, mw.appendLog)
+		}),
+		mw.releaseBtn,
+		// Map repositories to work item area and iteration paths.
+		widget.NewButton("Work Item Areas...", azureRequired("Work item areas", func(target *azureTarget) {
+			go showAreaMapping(mw.win, mw.areas, target, strings.TrimSpace(mw.githubTokenEntry.Text), mw.appendLog)
+		})),
+		// Repositories on hold, which nothing touches until released.
+		widget.NewButton("Holds...", func() {
+			showHolds(mw.win, mw.picker.Listed(), mw.appendLog, mw.picker.ReloadHolds)
+		}),
+		// Operator notes on the checked repositories, kept across runs.
+		widget.NewButton("Note on Selected...", func() {
+			editRepoNotes(mw.win, mw.picker.Selected(), mw.appendLog, mw.picker.ReloadNotes)
+		}),
+		// The license policy runs are checked against.
+		widget.NewButton("License Policy...", func() {
+			showLicensePolicy(mw.win, mw.app.Preferences().String("migration.licensePolicy"), func(text string) {
+				mw.app.Preferences().SetString("migration.licensePolicy", text)
+			}, mw.appendLog)
+		}),
+		widget.NewButton("Content Preview...", mw.showContentPreview),
+		// Rename migrated repositories in bulk from a mapping CSV.
+		widget.NewButton("Rename Repositories...", azureRequired("Rename repositories", func(target *azureTarget) {
+			showRenames(mw.win, target, mw.appendLog)
+		})),
+		widget.NewButton("Split Plans...", mw.showSplitPlans),
+		// Preview what the branch filter selects in a repository.
+		widget.NewButton("Branch Preview...", func() {
+			org := sourceOrg(mw.githubOrgSelect.Text)
+			go showBranchPreview(mw.win, mw.branches, mw.branchFilterEntry, org, strings.TrimSpace(mw.githubTokenEntry.Text), mw.appendLog)
+		}),
+		widget.NewButton("Git Commands...", mw.showGitCommandsPreview),
+		// Prove object-for-object fidelity on one repository, through a
+		// scratch repository.
+		widget.NewButton("Fidelity Test...", func() {
+			target := mw.enteredAzure()
+			githubToken := strings.TrimSpace(mw.githubTokenEntry.Text)
+			if target == nil || githubToken == "" {
+				dialog.ShowInformation("Fidelity test", "Fill in the GitHub PAT and the Azure PAT, organization and project first.", mw.win)
+				return
+			}
+			go showFidelityTest(mw.win, target, sourceOrg(mw.githubOrgSelect.Text), githubToken, mw.tails, mw.appendLog)
+		}),
+		// Compare two stored run reports.
+		widget.NewButton("Compare Runs...", func() {
+			showCompareRuns(mw.win, mw.appendLog)
+		}),
+	}
+}
 
-				// If "Don't save local clone" is checked, remove the temporary clone.
-				if dontSaveCheckbox.Checked {
-					err = os.RemoveAll(tempDir)
-					if err != nil {
-						appendLog(fmt.Sprintf("Error removing local clone for %s: %v", repo, err))
-					} else {
-						appendLog(fmt.Sprintf("Removed local clone for %s.", repo))
-					}
-				} else {
-					// Otherwise, move the clone to a designated folder.
-					destDir := filepath.Join(".", "clones", strings.ReplaceAll(repo, "/", "_"))
-					err = os.MkdirAll(filepath.Dir(destDir), 0755)
-					if err == nil {
-						err = os.Rename(tempDir, destDir)
-					}
-					if err != nil {
-						appendLog(fmt.Sprintf("Error moving clone for %s to %s: %v", repo, destDir, err))
-					} else {
-						appendLog(fmt.Sprintf("Local clone for %s saved to %s.", repo, destDir))
-					}
-				}
+// profileSettings returns the settings profiles share with teammates.
+// Credentials are never among them, nor what is each person's own:
+// signing keys and log files.
+func (mw *mainWindow) profileSettings() []profileSetting {
+	prefs := mw.app.Preferences()
+	settings := []profileSetting{
+		selectSetting("target", "Target", mw.targetTypeSelect),
+		entrySetting("source", "Source", &mw.githubOrgSelect.Entry),
+		entrySetting("expected_owner", "Expected owner", mw.expectedOwnerEntry),
+		checkSetting("skip_archived", "Leave archived repositories out", mw.skipArchivedCheck),
+		selectSetting("visibility", "Visibility", mw.visibilitySelect),
+	}
+	for _, f := range targetFactories {
+		for _, field := range f.Fields {
+			key := strings.ToLower(strings.ReplaceAll(f.Name, " ", "_")) + "." + field.Key
+			if !field.Secret && !credentialSetting(key) {
+				settings = append(settings, entrySetting(key, f.Name+" "+field.Label, mw.targetEntries[f.Name][field.Key]))
 			}
+		}
+	}
+	return append(settings,
+		selectSetting("recycle_bin", "Recycled names", mw.recyclePolicySelect),
+		checkSetting("pipelines", "Convert Actions workflows", mw.pipelinesCheckbox),
+		checkSetting("badges", "Rewrite Actions badges", mw.badgesCheckbox),
+		entrySetting("migration_branch_prefix", "Migration branches", mw.branchPrefixEntry),
+		checkSetting("include_lfs", "Include LFS objects", mw.includeLFSCheck),
+		selectSetting("lfs", "Missing LFS objects", mw.lfsPolicySelect),
+		selectSetting("archived", "Archived repos", mw.archiveSelect),
+		selectSetting("releases", "Releases", mw.releaseModeSelect),
+		entrySetting("release_feed", "Release feed", mw.releaseFeedEntry),
+		selectSetting("secrets", "Secrets in history", mw.secretPolicySelect),
+		entrySetting("commit_name", "Commit as (name)", mw.botNameEntry),
+		entrySetting("commit_email", "Commit as (e-mail)", mw.botEmailEntry),
+		selectSetting("signing", "Sign commits", mw.signingSelect),
+		checkSetting("require_signing", "Require signing", mw.requireSigningCheck),
+		entrySetting("branches", "Branches", mw.branchFilterEntry),
+		entrySetting("concurrency", "Concurrent repositories", mw.concurrencyEntry),
+		entrySetting("collisions", "Name collisions", mw.collisionsEntry),
+		entrySetting("attempts", "Attempts per transfer", mw.attemptsEntry),
+		entrySetting("retry_backoff", "Retry backoff", mw.backoffEntry),
+		entrySetting("chunk_size", "Push chunk size", mw.chunkSizeEntry),
+		checkSetting("chunk_fixed", "Fixed chunk size", mw.chunkFixedCheck),
+		entrySetting("liveness", "Transfer liveness", mw.livenessEntry),
+		entrySetting("canary_minutes", "Canary re-check (minutes)", mw.canaryEntry),
+		entrySetting("log_format", "Log file format", mw.logFormatEntry),
+		selectSetting("log_level", "Log file level", mw.logLevelSelect),
+		selectSetting("local_copies", "Local copies", mw.copiesSelect),
+		entrySetting("local_copies_dir", "Archive directory", mw.copiesDirEntry),
+		checkSetting("keep_failed", "Keep failure clones", mw.keepFailedCheckbox),
+		entrySetting("failed_days", "Keep failure clones (days)", mw.failedDaysEntry),
+		checkSetting("keep_awake", "Prevent sleep while migrating", mw.keepAwakeCheckbox),
+		checkSetting("polite", "Polite mode", mw.politeCheckbox),
+		profileSetting{Key: "license_policy", Label: "License policy",
+			Get: func() string { return prefs.String("migration.licensePolicy") },
+			Set: func(text string) { prefs.SetString("migration.licensePolicy", text) },
+			Validate: func(text string) error {
+				_, err := parseLicensePolicy(text)
+				return err
+			},
+		},
+	)
+}
 
-			appendLog("Migration completed.")
-		}()
+// createSupportBundle bundles what a bug report needs, with credentials
+// redacted.
+func (mw *mainWindow) createSupportBundle() {
+	prefs := mw.app.Preferences()
+	settings := []string{
+		"target: " + mw.targetTypeSelect.Selected,
+		"source: " + mw.githubOrgSelect.Text,
+		"expected_owner: " + mw.expectedOwnerEntry.Text,
+	}
+	tokens := []string{mw.githubTokenEntry.Text}
+	for _, f := range targetFactories {
+		for _, field := range f.Fields {
+			value := mw.targetEntries[f.Name][field.Key].Text
+			if field.Secret {
+				tokens = append(tokens, value)
+			} else if f.Name == mw.targetTypeSelect.Selected {
+				settings = append(settings, fmt.Sprintf("%s %s: %s", f.Name, field.Key, value))
+			}
+		}
+	}
+	// Access key IDs are not secret, but identify the account.
+	tokens = append(tokens, mw.targetEntries["AWS CodeCommit"]["key"].Text)
+	profile := strings.Join(append(settings,
+		"recycle_bin: "+mw.recyclePolicySelect.Selected,
+		"lfs: "+mw.lfsPolicySelect.Selected,
+		fmt.Sprintf("include_lfs: %t", mw.includeLFSCheck.Checked),
+		"archived: "+mw.archiveSelect.Selected,
+		"secrets: "+mw.secretPolicySelect.Selected,
+		"signing: "+mw.signingSelect.Selected,
+		"branches: "+mw.branchFilterEntry.Text,
+		"migration_branch_prefix: "+mw.branchPrefixEntry.Text,
+		"collisions: "+mw.collisionsEntry.Text,
+		"attempts: "+mw.attemptsEntry.Text,
+		"retry_backoff: "+mw.backoffEntry.Text,
+		"liveness: "+mw.livenessEntry.Text,
+		fmt.Sprintf("canary_minutes: %s (pending: %s)", mw.canaryEntry.Text, strings.Join(mw.canary.Pending(), ", ")),
+		fmt.Sprintf("chunk_size: %s (fixed: %t, learned: %s)", mw.chunkSizeEntry.Text, mw.chunkFixedCheck.Checked, prefs.String("migration.chunkLearned")),
+		"license_policy: "+licensePolicySummary(prefs.String("migration.licensePolicy")),
+		"run_tag: "+mw.runTagEntry.Text,
+		"log_format: "+mw.logFormatEntry.Text,
+		"log_level: "+mw.logLevelSelect.Selected,
+		"local_copies: "+mw.currentCopies().String(),
+		fmt.Sprintf("keep_failed: %t (%s days)", mw.keepFailedCheckbox.Checked, mw.failedDaysEntry.Text),
+		fmt.Sprintf("polite: %t", mw.politeCheckbox.Checked),
+	), "\n") + "\n"
+	in := supportInputs{Profile: profile, Tokens: tokens}
+	if path := strings.TrimSpace(mw.logFileEntry.Text); path != "" {
+		in.LogFiles = append(in.LogFiles, path)
+	}
+	azureCfg := mw.targetConfig("Azure DevOps")
+	azureCfg["org"] = mw.azureOrgBase()
+	targetType := mw.targetTypeSelect.Selected
+	go func() {
+		if targetType == "Azure DevOps" && azureCfg["token"] != "" && azureCfg["org"] != "" && azureCfg["project"] != "" {
+			sc, err := getProjectSourceControl(context.Background(), azureCfg["org"], azureCfg["project"], azureCfg["token"])
+			if err != nil {
+				in.Checks = append(in.Checks, fmt.Sprintf("Azure DevOps project version control: could not check (%v)", err))
+			} else {
+				in.Checks = append(in.Checks, fmt.Sprintf("Azure DevOps project version control: %s", sc))
+			}
+		}
+		path, err := createSupportBundle(in)
+		if err != nil {
+			mw.appendLog(fmt.Sprintf("Error creating support bundle: %v", err))
+			dialog.ShowError(err, mw.win)
+			return
+		}
+		mw.appendLog(fmt.Sprintf("Support bundle written to %s", path))
+		dialog.ShowInformation("Support bundle", fmt.Sprintf("Written to %s.\nCredentials were redacted and the archive checked for them. Nothing was uploaded; attach it to your bug report.", path), mw.win)
+	}()
+}
+
+// layout lays out the Migrate tab.
+func (mw *mainWindow) layout() fyne.CanvasObject {
+	// Named profiles kept on disk, to pick from at launch.
+	savedProfilesRow := newSavedProfilesRow(mw.win, mw.profileSettings, mw.appendLog)
+	exportProfileBtn := widget.NewButton("Export Profile...", func() {
+		showProfileExport(mw.win, mw.profileSettings(), mw.appendLog)
+	})
+	importProfileBtn := widget.NewButton("Import Profile...", func() {
+		showProfileImport(mw.win, mw.profileSettings(), mw.appendLog)
 	})
 
-	// Layout the UI.
-	form := container.NewVBox(
+	objects := []fyne.CanvasObject{
 		widget.NewLabel("GitHub to Azure Migration Tool"),
+		savedProfilesRow,
 		widget.NewForm(
-			widget.NewFormItem("GitHub PAT", githubTokenEntry),
-			widget.NewFormItem("Azure PAT", azureTokenEntry),
-			widget.NewFormItem("Azure Org URL", azureOrgEntry),
-			widget.NewFormItem("Azure Project", azureProjectEntry),
+			widget.NewFormItem("GitHub PAT", container.NewBorder(nil, nil, nil, container.NewHBox(mw.checkGitHubBtn, mw.signInGitHubBtn), mw.githubTokenEntry)),
+			widget.NewFormItem("Source", mw.githubOrgSelect),
+			widget.NewFormItem("Expected owner", mw.expectedOwnerEntry),
+			widget.NewFormItem("Target", mw.targetTypeSelect),
 		),
-		dontSaveCheckbox,
-		migrateBtn,
-		widget.NewLabel("Logs:"),
-		logEntry,
-	)
-
-	// Set the content and show the window.
-	w.SetContent(form)
-	w.ShowAndRun()
+		mw.githubOrgWarning,
+		mw.targetFormsBox,
+		container.NewBorder(nil, nil, nil, mw.forgetCredentialsBtn, mw.rememberCheck),
+		widget.NewLabel("Repositories:"),
+		mw.skipArchivedCheck,
+		widget.NewForm(widget.NewFormItem("Visibility", mw.visibilitySelect)),
+		mw.picker.Content(),
+		widget.NewForm(
+			widget.NewFormItem("", mw.includeLFSCheck),
+			widget.NewFormItem("Missing LFS objects", mw.lfsPolicySelect),
+			widget.NewFormItem("Archived repos", mw.archiveSelect),
+			widget.NewFormItem("Releases", mw.releaseModeSelect),
+			widget.NewFormItem("Release feed", mw.releaseFeedEntry),
+			widget.NewFormItem("Secrets in history", mw.secretPolicySelect),
+			widget.NewFormItem("Commit as", container.NewGridWithColumns(2, mw.botNameEntry, mw.botEmailEntry)),
+			widget.NewFormItem("Sign commits", mw.signingSelect),
+			widget.NewFormItem("Signing key", mw.signingKeyEntry),
+			widget.NewFormItem("", mw.requireSigningCheck),
+			widget.NewFormItem("Branches", mw.branchFilterEntry),
+			widget.NewFormItem("Concurrent repositories", mw.concurrencyEntry),
+			widget.NewFormItem("Name collisions", mw.collisionsEntry),
+			widget.NewFormItem("Attempts per transfer", mw.attemptsEntry),
+			widget.NewFormItem("Retry backoff", mw.backoffEntry),
+			widget.NewFormItem("Push chunk size (commits)", mw.chunkSizeEntry),
+			widget.NewFormItem("Transfer liveness", mw.livenessEntry),
+			widget.NewFormItem("Canary re-check (minutes)", mw.canaryEntry),
+			widget.NewFormItem("", mw.chunkFixedCheck),
+			widget.NewFormItem("Run tag", mw.runTagEntry),
+			widget.NewFormItem("Log file", mw.logFileEntry),
+			widget.NewFormItem("Log file format", mw.logFormatEntry),
+			widget.NewFormItem("Log file level", mw.logLevelSelect),
+		),
+		mw.logFormatError,
+		widget.NewForm(
+			widget.NewFormItem("Local copies", mw.copiesSelect),
+			widget.NewFormItem("Archive directory", mw.copiesDirEntry),
+		),
+		mw.keepFailedCheckbox,
+		widget.NewForm(widget.NewFormItem("Keep failure clones (days)", mw.failedDaysEntry)),
+		mw.utcCheckbox,
+		mw.compactCheckbox,
+		mw.keepAwakeCheckbox,
+		mw.politeCheckbox,
+		mw.readOnlyCheckbox,
+		mw.dryRunCheckbox,
+		container.NewBorder(nil, nil, mw.validateBtn, container.NewHBox(mw.resumeBtn, mw.cancelBtn), mw.migrateBtn),
+		mw.readOnlyNote,
+		mw.validationNote,
+		widget.NewLabel("Identities:"),
+		mw.identitiesLabel,
+		mw.sleepIndicator,
+		mw.canaryLabel,
+	}
+	objects = append(objects, mw.toolButtons()...)
+	return container.NewVBox(append(objects,
+		container.NewGridWithColumns(2, exportProfileBtn, importProfileBtn),
+		widget.NewButton("Create Support Bundle", mw.createSupportBundle),
+		container.NewBorder(nil, nil, widget.NewLabel("Logs:"), container.NewHBox(mw.logViewLevel, mw.saveLogsBtn), mw.logFilterEntry),
+		mw.logEntry,
+	)...)
 }
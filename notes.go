@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Operator notes keep the context of a long program next to what it is
+// about ("waiting on team X to close PRs", "re-run after the legal hold
+// lifts") instead of in a spreadsheet that drifts. A repository's note is
+// kept in the migration state, keyed by source, so it survives re-planning
+// and re-runs; each run's report carries the repository's note as it was,
+// and a note of its own on the run.
+
+// repoNotes returns the notes, by lower-case source.
+func repoNotes() (map[string]string, error) {
+	state, err := loadMigrationState()
+	if err != nil {
+		return nil, err
+	}
+	notes := map[string]string{}
+	for key, s := range state {
+		if s.Note != "" {
+			notes[key] = s.Note
+		}
+	}
+	return notes, nil
+}
+
+// repoNote returns repo's note, "" if it has none.
+func repoNote(repo string) (string, error) {
+	notes, err := repoNotes()
+	if err != nil {
+		return "", err
+	}
+	return notes[strings.ToLower(repo)], nil
+}
+
+// setRepoNote sets repo's note, or clears it if note is blank, and records
+// the change in the audit log.
+func setRepoNote(repo, note string) error {
+	repo, note = strings.TrimSpace(repo), strings.TrimSpace(note)
+	if repo == "" {
+		return fmt.Errorf("a note needs a repository")
+	}
+	migrationStateMu.Lock()
+	defer migrationStateMu.Unlock()
+	state, err := loadMigrationState()
+	if err != nil {
+		return err
+	}
+	s := state[strings.ToLower(repo)]
+	if s == nil {
+		if note == "" {
+			return nil
+		}
+		s = &repoState{Source: repo, Updated: fileTimestamp(time.Now())}
+		state[strings.ToLower(repo)] = s
+	}
+	s.Note = note
+	if err := state.save(); err != nil {
+		return err
+	}
+	if note == "" {
+		return writeAudit("clear-note", s.Source, "by "+holdUser())
+	}
+	return writeAudit("set-note", s.Source, fmt.Sprintf("%s; by %s", note, holdUser()))
+}
+
+// setRunNote sets the note of the run r, or clears it, and saves its
+// report.
+func setRunNote(r *runReport, note string) error {
+	r.Note = strings.TrimSpace(note)
+	if _, err := r.save(); err != nil {
+		return err
+	}
+	return writeAudit("set-run-note", r.ID, orDefault(r.Note, "cleared"))
+}
+
+// noteMatches reports whether the note, or the name it is on, contains
+// search, ignoring case.
+func noteMatches(name, note, search string) bool {
+	search = strings.ToLower(strings.TrimSpace(search))
+	return strings.Contains(strings.ToLower(name), search) || strings.Contains(strings.ToLower(note), search)
+}
+
+// addOperatorNotes adds the repositories' notes to the plan entries, for
+// the plan and the runbook.
+func (p *migrationPlan) addOperatorNotes(notes map[string]string) {
+	for i := range p.Entries {
+		if n := notes[strings.ToLower(p.Entries[i].Source)]; n != "" {
+			p.Entries[i].Notes = append(p.Entries[i].Notes, "note: "+n)
+		}
+	}
+}
+
+// runNoteCommand implements "gitui note": "list" prints the repositories'
+// notes, those matching --search only; "set REPO TEXT" sets one and
+// "clear REPO" clears it; "run RUN [TEXT]" prints or sets a run's note.
+func runNoteCommand(args []string) int {
+	const usage = "usage: gitui note list [--search TEXT] | gitui note set owner/name TEXT | gitui note clear owner/name | gitui note run RUN [TEXT]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return exitRunError
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("note list", flag.ContinueOnError)
+		search := fs.String("search", "", "only the notes containing this text, or on repositories whose name does")
+		setUsage(fs, "note list [--search TEXT]")
+		if code := parseFlags(fs, args[1:]); code >= 0 {
+			return code
+		}
+		state, err := loadMigrationState()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		for _, s := range state.Sorted() {
+			if s.Note != "" && noteMatches(s.Source, s.Note, *search) {
+				fmt.Printf("%s\t%s\n", s.Source, s.Note)
+			}
+		}
+		return exitOK
+	case "set", "clear":
+		want := 3
+		if args[0] == "clear" {
+			want = 2
+		}
+		if len(args) != want {
+			fmt.Fprintln(os.Stderr, usage)
+			return exitRunError
+		}
+		note := ""
+		if args[0] == "set" {
+			if note = strings.TrimSpace(args[2]); note == "" {
+				fmt.Fprintln(os.Stderr, "Error: the note is blank; use note clear to clear it")
+				return exitRunError
+			}
+		}
+		if err := setRepoNote(args[1], note); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		return exitOK
+	case "run":
+		if len(args) != 2 && len(args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			return exitRunError
+		}
+		r, err := findReport(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		if len(args) == 2 {
+			if r.Note != "" {
+				fmt.Println(r.Note)
+			}
+			return exitOK
+		}
+		if err := setRunNote(r, args[2]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "unknown note command %q\n%s\n", args[0], usage)
+	return exitRunError
+}
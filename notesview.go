@@ -0,0 +1,86 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// editRepoNotes asks for the operator note of repos, filled in with the
+// note they share, if any, and sets it; a blank note clears it. onChanged
+// is called after.
+func editRepoNotes(w fyne.Window, repos []string, logMsg func(string), onChanged func()) {
+	if len(repos) == 0 {
+		dialog.ShowInformation("Notes", "Select the repositories to note first.", w)
+		return
+	}
+	notes, err := repoNotes()
+	if err != nil {
+		logMsg(fmt.Sprintf("Error reading the notes: %v", err))
+		return
+	}
+	shared := notes[strings.ToLower(repos[0])]
+	for _, r := range repos[1:] {
+		if notes[strings.ToLower(r)] != shared {
+			shared = ""
+			break
+		}
+	}
+	noteEntry := widget.NewMultiLineEntry()
+	noteEntry.SetPlaceHolder("e.g. waiting on team X to close PRs; blank clears the note")
+	noteEntry.SetText(shared)
+	title := "Note on " + repos[0]
+	if len(repos) > 1 {
+		title = fmt.Sprintf("Note on %d repositories", len(repos))
+	}
+	d := dialog.NewForm(title, "Save", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Note", noteEntry)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			note := strings.TrimSpace(noteEntry.Text)
+			for _, r := range repos {
+				if err := setRepoNote(r, note); err != nil {
+					logMsg(fmt.Sprintf("Error saving the note on %s: %v", r, err))
+					return
+				}
+			}
+			if note == "" {
+				logMsg(fmt.Sprintf("Cleared the note on %d repositories.", len(repos)))
+			} else {
+				logMsg(fmt.Sprintf("Noted %d repositories: %s", len(repos), note))
+			}
+			onChanged()
+		}, w)
+	d.Resize(fyne.NewSize(520, 260))
+	d.Show()
+}
+
+// editRunNote asks for the operator note of the run r and saves it in its
+// report; a blank note clears it. onChanged is called after.
+func editRunNote(w fyne.Window, r *runReport, logMsg func(string), onChanged func()) {
+	noteEntry := widget.NewMultiLineEntry()
+	noteEntry.SetPlaceHolder("e.g. re-run after the legal hold lifts; blank clears the note")
+	noteEntry.SetText(r.Note)
+	d := dialog.NewForm("Note on run "+r.Label(), "Save", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Note", noteEntry)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := setRunNote(r, noteEntry.Text); err != nil {
+				logMsg(fmt.Sprintf("Error saving the note on run %s: %v", r.Label(), err))
+				return
+			}
+			logMsg(fmt.Sprintf("Note on run %s: %s", r.Label(), orDefault(r.Note, "cleared")))
+			onChanged()
+		}, w)
+	d.Resize(fyne.NewSize(520, 260))
+	d.Show()
+}
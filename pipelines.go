@@ -0,0 +1,790 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pipelineBranchName is the branch, after the migration branch prefix,
+// pipelines converted from GitHub Actions workflows are committed to, so
+// the default branch stays identical to the source until someone reviews
+// and merges them.
+const pipelineBranchName = "pipelines"
+
+// workflowsDir holds a repository's GitHub Actions workflows.
+const workflowsDir = ".github/workflows"
+
+// The converter covers what most CI workflows are made of: push and
+// schedule triggers, jobs on hosted runners with their
+// dependencies and simple matrices, run steps, and the common actions
+// below. Everything else is listed as unconverted, in the run report and
+// at the top of the generated file, for someone to finish by hand.
+
+// gitHubWorkflow is the subset of a GitHub Actions workflow the converter
+// reads.
+type gitHubWorkflow struct {
+	Name string            `yaml:"name"`
+	On   yaml.Node         `yaml:"on"`
+	Env  map[string]string `yaml:"env"`
+	Jobs yaml.Node         `yaml:"jobs"`
+}
+
+// gitHubJob is a job of a workflow.
+type gitHubJob struct {
+	Name           string            `yaml:"name"`
+	RunsOn         yaml.Node         `yaml:"runs-on"`
+	Needs          yaml.Node         `yaml:"needs"`
+	If             string            `yaml:"if"`
+	Env            map[string]string `yaml:"env"`
+	TimeoutMinutes int               `yaml:"timeout-minutes"`
+	Strategy       struct {
+		Matrix yaml.Node `yaml:"matrix"`
+	} `yaml:"strategy"`
+	Container yaml.Node    `yaml:"container"`
+	Services  yaml.Node    `yaml:"services"`
+	Uses      string       `yaml:"uses"` // a reusable workflow
+	Steps     []gitHubStep `yaml:"steps"`
+}
+
+// gitHubStep is a step of a job.
+type gitHubStep struct {
+	Name             string            `yaml:"name"`
+	Uses             string            `yaml:"uses"`
+	Run              string            `yaml:"run"`
+	Shell            string            `yaml:"shell"`
+	If               string            `yaml:"if"`
+	With             map[string]string `yaml:"with"`
+	Env              map[string]string `yaml:"env"`
+	WorkingDirectory string            `yaml:"working-directory"`
+	ContinueOnError  bool              `yaml:"continue-on-error"`
+	TimeoutMinutes   int               `yaml:"timeout-minutes"`
+}
+
+// azurePipeline is an Azure Pipelines YAML definition, in the order its
+// keys are written.
+type azurePipeline struct {
+	Trigger   interface{}        `yaml:"trigger,omitempty"` // nil for every branch
+	Schedules []azureSchedule    `yaml:"schedules,omitempty"`
+	Variables map[string]string  `yaml:"variables,omitempty"`
+	Jobs      []azurePipelineJob `yaml:"jobs"`
+}
+
+// azureTrigger is a CI trigger.
+type azureTrigger struct {
+	Branches *azureFilter `yaml:"branches,omitempty"`
+	Tags     *azureFilter `yaml:"tags,omitempty"`
+	Paths    *azureFilter `yaml:"paths,omitempty"`
+}
+
+// azureFilter includes and excludes branches, tags or paths.
+type azureFilter struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// azureSchedule is a scheduled trigger.
+type azureSchedule struct {
+	Cron        string      `yaml:"cron"`
+	DisplayName string      `yaml:"displayName"`
+	Branches    azureFilter `yaml:"branches"`
+	Always      bool        `yaml:"always"`
+}
+
+// azurePipelineJob is a job of a pipeline.
+type azurePipelineJob struct {
+	Job              string            `yaml:"job"`
+	DisplayName      string            `yaml:"displayName,omitempty"`
+	DependsOn        []string          `yaml:"dependsOn,omitempty"`
+	TimeoutInMinutes int               `yaml:"timeoutInMinutes,omitempty"`
+	Strategy         *azureStrategy    `yaml:"strategy,omitempty"`
+	Pool             map[string]string `yaml:"pool"`
+	Variables        map[string]string `yaml:"variables,omitempty"`
+	Steps            []azureStep       `yaml:"steps"`
+}
+
+// azureStrategy runs a job for each combination of a matrix.
+type azureStrategy struct {
+	Matrix map[string]map[string]string `yaml:"matrix"`
+}
+
+// azureStep is a step of a job: a checkout, a script or a task.
+type azureStep struct {
+	Checkout         string            `yaml:"checkout,omitempty"`
+	Script           string            `yaml:"script,omitempty"`
+	Bash             string            `yaml:"bash,omitempty"`
+	Pwsh             string            `yaml:"pwsh,omitempty"`
+	PowerShell       string            `yaml:"powershell,omitempty"`
+	Task             string            `yaml:"task,omitempty"`
+	DisplayName      string            `yaml:"displayName,omitempty"`
+	FetchDepth       *int              `yaml:"fetchDepth,omitempty"`
+	Submodules       string            `yaml:"submodules,omitempty"`
+	LFS              bool              `yaml:"lfs,omitempty"`
+	Inputs           map[string]string `yaml:"inputs,omitempty"`
+	WorkingDirectory string            `yaml:"workingDirectory,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+	ContinueOnError  bool              `yaml:"continueOnError,omitempty"`
+	TimeoutInMinutes int               `yaml:"timeoutInMinutes,omitempty"`
+}
+
+// expressionPattern matches a GitHub Actions expression.
+var expressionPattern = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+// contextVariables are the GitHub contexts with a predefined Azure
+// Pipelines variable to match.
+var contextVariables = map[string]string{
+	"github.sha":        "Build.SourceVersion",
+	"github.ref":        "Build.SourceBranch",
+	"github.ref_name":   "Build.SourceBranchName",
+	"github.run_id":     "Build.BuildId",
+	"github.run_number": "Build.BuildNumber",
+	"github.workspace":  "Build.SourcesDirectory",
+	"github.repository": "Build.Repository.Name",
+	"github.event_name": "Build.Reason",
+	"github.actor":      "Build.RequestedFor",
+	"runner.os":         "Agent.OS",
+	"runner.temp":       "Agent.TempDirectory",
+	"runner.tool_cache": "Agent.ToolsDirectory",
+}
+
+// variablePattern matches the secrets, variables, environment and matrix
+// values that become pipeline variables of the same name.
+var variablePattern = regexp.MustCompile(`^(?:secrets|vars|env|matrix)\.([A-Za-z_][A-Za-z0-9_]*)$`)
+
+// convertExpression returns the macro of a GitHub expression, or "" if it
+// has none.
+func convertExpression(expr string) string {
+	if v, ok := contextVariables[expr]; ok {
+		return "$(" + v + ")"
+	}
+	if m := variablePattern.FindStringSubmatch(expr); m != nil {
+		return "$(" + m[1] + ")"
+	}
+	return ""
+}
+
+// convertExpressions replaces the expressions in s with pipeline macros
+// and returns the ones it left as they are.
+func convertExpressions(s string) (string, []string) {
+	var left []string
+	out := expressionPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if macro := convertExpression(expressionPattern.FindStringSubmatch(m)[1]); macro != "" {
+			return macro
+		}
+		left = append(left, m)
+		return m
+	})
+	return out, left
+}
+
+// hashFilesPattern matches the hashFiles() cache keys are built with.
+var hashFilesPattern = regexp.MustCompile(`^hashFiles\((.*)\)$`)
+
+// cacheKey converts an actions/cache key to the Cache task's: the
+// expressions and the literal text between them become segments, and
+// hashFiles() the file patterns the task hashes itself.
+func cacheKey(key string) (string, []string) {
+	var segments, left []string
+	literal := func(s string) {
+		if s = strings.Trim(strings.TrimSpace(s), "-"); s != "" {
+			segments = append(segments, s)
+		}
+	}
+	last := 0
+	for _, loc := range expressionPattern.FindAllStringSubmatchIndex(key, -1) {
+		literal(key[last:loc[0]])
+		last = loc[1]
+		expr := key[loc[2]:loc[3]]
+		if m := hashFilesPattern.FindStringSubmatch(expr); m != nil {
+			for _, f := range strings.Split(m[1], ",") {
+				segments = append(segments, strings.Trim(strings.TrimSpace(f), `'"`))
+			}
+			continue
+		}
+		if macro := convertExpression(expr); macro != "" {
+			segments = append(segments, `"`+macro+`"`)
+			continue
+		}
+		left = append(left, key[loc[0]:loc[1]])
+		segments = append(segments, key[loc[0]:loc[1]])
+	}
+	literal(key[last:])
+	return strings.Join(segments, " | "), left
+}
+
+// hostedImages are the runner labels that name a Microsoft-hosted image
+// of the same name.
+var hostedImages = regexp.MustCompile(`^(ubuntu|windows|macos)-(latest|[0-9.]+)$`)
+
+// stringList reads a node that is a string or a list of strings.
+func stringList(n *yaml.Node) []string {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return []string{n.Value}
+	case yaml.SequenceNode:
+		var list []string
+		for _, c := range n.Content {
+			if c.Kind == yaml.ScalarNode {
+				list = append(list, c.Value)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// mapping returns the key and value nodes of a mapping node, in order.
+func mapping(n *yaml.Node) [][2]*yaml.Node {
+	var pairs [][2]*yaml.Node
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	return pairs
+}
+
+// filterOf reads the include and exclude lists of an event's filter.
+func filterOf(event *yaml.Node, include, exclude string) *azureFilter {
+	f := &azureFilter{}
+	for _, kv := range mapping(event) {
+		switch kv[0].Value {
+		case include:
+			f.Include = stringList(kv[1])
+		case exclude:
+			f.Exclude = stringList(kv[1])
+		}
+	}
+	if f.Include == nil && f.Exclude == nil {
+		return nil
+	}
+	return f
+}
+
+// pipelineConverter converts one workflow, collecting what it could not.
+type pipelineConverter struct {
+	file          string // of the workflow
+	defaultBranch string
+	notes         []string
+}
+
+func (c *pipelineConverter) note(format string, args ...interface{}) {
+	c.notes = append(c.notes, c.file+": "+fmt.Sprintf(format, args...))
+}
+
+// text converts the expressions in s, noting the ones it cannot.
+func (c *pipelineConverter) text(where, s string) string {
+	out, left := convertExpressions(s)
+	for _, e := range left {
+		c.note("%s: expression %s left as is", where, e)
+	}
+	return out
+}
+
+// texts converts the expressions in the values of m.
+func (c *pipelineConverter) texts(where string, m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := map[string]string{}
+	for k, v := range m {
+		out[k] = c.text(where, v)
+	}
+	return out
+}
+
+// triggers converts the workflow's events into p's trigger and schedules.
+// Without a push event the pipeline has no CI trigger.
+func (c *pipelineConverter) triggers(on *yaml.Node, p *azurePipeline) {
+	p.Trigger = "none"
+	events := map[string]*yaml.Node{}
+	var order []string
+	switch on.Kind {
+	case yaml.ScalarNode, yaml.SequenceNode:
+		for _, e := range stringList(on) {
+			events[e] = &yaml.Node{}
+			order = append(order, e)
+		}
+	case yaml.MappingNode:
+		for _, kv := range mapping(on) {
+			events[kv[0].Value] = kv[1]
+			order = append(order, kv[0].Value)
+		}
+	}
+	for _, name := range order {
+		event := events[name]
+		switch name {
+		case "push":
+			t := azureTrigger{
+				Branches: filterOf(event, "branches", "branches-ignore"),
+				Tags:     filterOf(event, "tags", "tags-ignore"),
+				Paths:    filterOf(event, "paths", "paths-ignore"),
+			}
+			if t.Branches == nil && t.Tags == nil && t.Paths == nil {
+				p.Trigger = nil
+			} else {
+				p.Trigger = t
+			}
+		case "pull_request", "pull_request_target":
+			// Azure Repos ignores pr triggers: pull requests are built
+			// by build validation policies on their target branches.
+			c.note("trigger %s needs the pipeline added as build validation to the target branches", name)
+		case "schedule":
+			for _, s := range event.Content {
+				for _, kv := range mapping(s) {
+					if kv[0].Value == "cron" {
+						p.Schedules = append(p.Schedules, azureSchedule{
+							Cron:        kv[1].Value,
+							DisplayName: "Converted schedule " + kv[1].Value,
+							Branches:    azureFilter{Include: []string{c.defaultBranch}},
+							Always:      true,
+						})
+					}
+				}
+			}
+		case "workflow_dispatch":
+			// Any pipeline can be run by hand.
+		default:
+			c.note("trigger %q has no equivalent", name)
+		}
+	}
+}
+
+// matrix converts a job's matrix of value lists into the explicit
+// combinations Azure Pipelines takes.
+func (c *pipelineConverter) matrix(job string, n *yaml.Node) *azureStrategy {
+	if n.Kind == 0 {
+		return nil
+	}
+	if n.Kind != yaml.MappingNode {
+		c.note("job %s: matrix %s left out", job, n.Value)
+		return nil
+	}
+	combos := []map[string]string{{}}
+	for _, kv := range mapping(n) {
+		key := kv[0].Value
+		if key == "include" || key == "exclude" || kv[1].Kind != yaml.SequenceNode {
+			c.note("job %s: matrix %s left out", job, key)
+			continue
+		}
+		var next []map[string]string
+		for _, combo := range combos {
+			for _, v := range stringList(kv[1]) {
+				m := map[string]string{key: v}
+				for k, old := range combo {
+					m[k] = old
+				}
+				next = append(next, m)
+			}
+		}
+		combos = next
+	}
+	if len(combos) == 1 && len(combos[0]) == 0 {
+		return nil
+	}
+	s := &azureStrategy{Matrix: map[string]map[string]string{}}
+	for _, combo := range combos {
+		var keys, values []string
+		for k := range combo {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			values = append(values, combo[k])
+		}
+		s.Matrix[jobID(strings.Join(values, "_"))] = combo
+	}
+	return s
+}
+
+// jobIDChars matches what a job name may not contain.
+var jobIDChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// jobID returns a valid job (or matrix) name for id.
+func jobID(id string) string {
+	id = jobIDChars.ReplaceAllString(id, "_")
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		id = "_" + id
+	}
+	return id
+}
+
+// pool converts a job's runs-on.
+func (c *pipelineConverter) pool(job string, runsOn *yaml.Node) map[string]string {
+	labels := stringList(runsOn)
+	if len(labels) == 1 {
+		if hostedImages.MatchString(labels[0]) {
+			return map[string]string{"vmImage": labels[0]}
+		}
+		if out, left := convertExpressions(labels[0]); len(left) == 0 && out != labels[0] {
+			return map[string]string{"vmImage": out}
+		}
+	}
+	c.note("job %s: runner %s has no hosted image; the Default pool is used", job, strings.Join(labels, ", "))
+	return map[string]string{"name": "Default"}
+}
+
+// step converts a step of job, or returns nil if it cannot.
+func (c *pipelineConverter) step(job string, i int, s gitHubStep) *azureStep {
+	where := fmt.Sprintf("job %s, step %d", job, i+1)
+	if s.Name != "" {
+		where += " (" + s.Name + ")"
+	}
+	out := &azureStep{
+		DisplayName:      c.text(where, s.Name),
+		WorkingDirectory: c.text(where, s.WorkingDirectory),
+		Env:              c.texts(where, s.Env),
+		ContinueOnError:  s.ContinueOnError,
+		TimeoutInMinutes: s.TimeoutMinutes,
+	}
+	if s.If != "" {
+		c.note("%s: condition %q left out", where, s.If)
+	}
+	if s.Run != "" {
+		script := c.text(where, s.Run)
+		switch s.Shell {
+		case "", "bash", "sh":
+			if s.Shell == "" {
+				out.Script = script
+			} else {
+				out.Bash = script
+			}
+		case "pwsh":
+			out.Pwsh = script
+		case "powershell":
+			out.PowerShell = script
+		default:
+			out.Script = script
+			c.note("%s: shell %s runs as the default shell", where, s.Shell)
+		}
+		return out
+	}
+
+	action, _, _ := strings.Cut(s.Uses, "@")
+	action = strings.ToLower(action)
+	// Cache keys are converted on their own, hashFiles() and all.
+	raw := map[string]string{}
+	for k, v := range s.With {
+		if action != "actions/cache" || k != "key" && k != "restore-keys" {
+			raw[k] = v
+		}
+	}
+	with := c.texts(where, raw)
+	switch action {
+	case "actions/checkout":
+		out.Checkout = "self"
+		if d, ok := with["fetch-depth"]; ok {
+			var depth int
+			if _, err := fmt.Sscan(d, &depth); err == nil {
+				out.FetchDepth = &depth
+			}
+		}
+		switch with["submodules"] {
+		case "true":
+			out.Submodules = "true"
+		case "recursive":
+			out.Submodules = "recursive"
+		}
+		out.LFS = with["lfs"] == "true"
+		if with["repository"] != "" {
+			c.note("%s: checkout of %s left out; only the pipeline's own repository is checked out", where, with["repository"])
+			return nil
+		}
+	case "actions/setup-go":
+		if with["go-version"] == "" {
+			c.note("%s: setup-go without go-version left out", where)
+			return nil
+		}
+		out.Task, out.Inputs = "GoTool@0", map[string]string{"version": with["go-version"]}
+	case "actions/setup-node":
+		if with["node-version"] == "" {
+			c.note("%s: setup-node without node-version left out", where)
+			return nil
+		}
+		out.Task, out.Inputs = "NodeTool@0", map[string]string{"versionSpec": with["node-version"]}
+	case "actions/setup-python":
+		if with["python-version"] == "" {
+			c.note("%s: setup-python without python-version left out", where)
+			return nil
+		}
+		out.Task, out.Inputs = "UsePythonVersion@0", map[string]string{"versionSpec": with["python-version"]}
+	case "actions/cache":
+		key, left := cacheKey(s.With["key"])
+		for _, e := range left {
+			c.note("%s: cache key expression %s left as is", where, e)
+		}
+		paths := strings.Fields(with["path"])
+		if len(paths) == 0 {
+			c.note("%s: cache without a path left out", where)
+			return nil
+		}
+		if len(paths) > 1 {
+			c.note("%s: cache of several paths keeps only %s", where, paths[0])
+		}
+		out.Task, out.Inputs = "Cache@2", map[string]string{"key": key, "path": paths[0]}
+		if restore := strings.TrimSpace(s.With["restore-keys"]); restore != "" {
+			var keys []string
+			for _, k := range strings.Split(restore, "\n") {
+				rk, _ := cacheKey(k)
+				keys = append(keys, rk)
+			}
+			out.Inputs["restoreKeys"] = strings.Join(keys, "\n")
+		}
+	case "actions/upload-artifact":
+		out.Task = "PublishPipelineArtifact@1"
+		out.Inputs = map[string]string{"targetPath": with["path"], "artifact": orDefault(with["name"], "artifact")}
+	case "actions/download-artifact":
+		out.Task = "DownloadPipelineArtifact@2"
+		out.Inputs = map[string]string{"path": orDefault(with["path"], "$(Pipeline.Workspace)")}
+		if with["name"] != "" {
+			out.Inputs["artifact"] = with["name"]
+		}
+	default:
+		c.note("%s: action %s has no equivalent", where, orDefault(s.Uses, "step"))
+		return nil
+	}
+	return out
+}
+
+// convert converts the workflow in data.
+func (c *pipelineConverter) convert(data []byte) (*azurePipeline, error) {
+	var wf gitHubWorkflow
+	if err := yaml.Unmarshal(data, &wf); err != nil {
+		return nil, err
+	}
+	p := &azurePipeline{Variables: c.texts("env", wf.Env)}
+	c.triggers(&wf.On, p)
+	for _, kv := range mapping(&wf.Jobs) {
+		id := kv[0].Value
+		var j gitHubJob
+		if err := kv[1].Decode(&j); err != nil {
+			return nil, fmt.Errorf("job %s: %v", id, err)
+		}
+		if j.Uses != "" {
+			c.note("job %s: reusable workflow %s left out", id, j.Uses)
+			continue
+		}
+		if j.If != "" {
+			c.note("job %s: condition %q left out", id, j.If)
+		}
+		if j.Container.Kind != 0 || j.Services.Kind != 0 {
+			c.note("job %s: containers and services left out", id)
+		}
+		job := azurePipelineJob{
+			Job:              jobID(id),
+			DisplayName:      c.text("job "+id, j.Name),
+			TimeoutInMinutes: j.TimeoutMinutes,
+			Strategy:         c.matrix(id, &j.Strategy.Matrix),
+			Pool:             c.pool(id, &j.RunsOn),
+			Variables:        c.texts("job "+id, j.Env),
+		}
+		for _, need := range stringList(&j.Needs) {
+			job.DependsOn = append(job.DependsOn, jobID(need))
+		}
+		for i, s := range j.Steps {
+			if step := c.step(id, i, s); step != nil {
+				job.Steps = append(job.Steps, *step)
+			}
+		}
+		p.Jobs = append(p.Jobs, job)
+	}
+	return p, nil
+}
+
+// convertWorkflow converts a workflow file to Azure Pipelines YAML,
+// headed by what it could not convert, and returns that too.
+func convertWorkflow(file string, data []byte, defaultBranch string) (string, []string, error) {
+	c := &pipelineConverter{file: file, defaultBranch: defaultBranch}
+	p, err := c.convert(data)
+	if err != nil {
+		return "", nil, err
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(p); err != nil {
+		return "", nil, err
+	}
+	head := fmt.Sprintf("# Converted from the GitHub Actions workflow %s.\n", file)
+	if len(c.notes) > 0 {
+		head += "# Not converted, to finish by hand:\n"
+		for _, n := range c.notes {
+			head += "#   " + strings.TrimPrefix(n, file+": ") + "\n"
+		}
+	}
+	return head + "\n" + out.String(), c.notes, nil
+}
+
+// pipelinesReport is the outcome of converting a repository's workflows.
+type pipelinesReport struct {
+	Files       []string `json:"files,omitempty"`
+	Branch      string   `json:"branch,omitempty"` // set if a commit was pushed
+	Unconverted []string `json:"unconverted,omitempty"`
+	// Manual is where the pipelines were saved when a branch policy
+	// rejected their push, to be applied by hand.
+	Manual string `json:"manual,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// treeWith writes the tree of base (a tree-ish, or "" for none) with the
+// blobs of files, by path, added or replaced, and returns its SHA.
+func treeWith(ctx context.Context, dir, base string, files map[string]string) (string, error) {
+	var entries []string
+	subtrees := map[string]string{}
+	if base != "" {
+		out, err := runGit(ctx, nil, "-C", dir, "ls-tree", base)
+		if err != nil {
+			return "", fmt.Errorf("git ls-tree: %v", err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			// "<mode> <type> <sha>\t<name>"
+			meta, name, ok := strings.Cut(line, "\t")
+			if !ok {
+				continue
+			}
+			fields := strings.Fields(meta)
+			if _, replaced := files[name]; replaced {
+				continue
+			}
+			if fields[1] == "tree" {
+				subtrees[name] = fields[2]
+			}
+			entries = append(entries, line)
+		}
+	}
+	nested := map[string]map[string]string{}
+	for p, blob := range files {
+		first, rest, ok := strings.Cut(p, "/")
+		if !ok {
+			entries = append(entries, fmt.Sprintf("100644 blob %s\t%s", blob, p))
+			continue
+		}
+		if nested[first] == nil {
+			nested[first] = map[string]string{}
+		}
+		nested[first][rest] = blob
+	}
+	for name, sub := range nested {
+		tree, err := treeWith(ctx, dir, subtrees[name], sub)
+		if err != nil {
+			return "", err
+		}
+		kept := entries[:0]
+		for _, e := range entries {
+			if !strings.HasSuffix(e, "\t"+name) {
+				kept = append(kept, e)
+			}
+		}
+		entries = append(kept, fmt.Sprintf("040000 tree %s\t%s", tree, name))
+	}
+	return gitInput(dir, strings.Join(entries, "\n")+"\n", "mktree")
+}
+
+// pipelinesStep converts the GitHub Actions workflows of repo, migrated to
+// the target repository name, and pushes the pipelines to branch,
+// committed through signer: azure-pipelines.yml for a lone workflow, or
+// else one file per workflow under .azure-pipelines, leaving any pipeline
+// the repository already has alone. If a branch policy rejects the push,
+// the change is saved with saveManualChange instead, as a warning. The
+// clone in dir must have "target" as the target remote.
+func pipelinesStep(ctx context.Context, t *azureTarget, dir, repo, name, defaultBranch, branch string, signer *commitSigner, stream io.Writer, logMsg func(string)) *pipelinesReport {
+	out, err := runGit(ctx, nil, "-C", dir, "ls-tree", "--name-only", "HEAD", workflowsDir+"/")
+	if err != nil {
+		return nil
+	}
+	var workflows []string
+	for _, f := range strings.Split(out, "\n") {
+		if strings.HasSuffix(f, ".yml") || strings.HasSuffix(f, ".yaml") {
+			workflows = append(workflows, f)
+		}
+	}
+	if len(workflows) == 0 {
+		return nil
+	}
+
+	r := &pipelinesReport{}
+	exists := func(file string) bool {
+		_, err := runGit(ctx, nil, "-C", dir, "cat-file", "-e", "HEAD:"+file)
+		return err == nil
+	}
+	files := map[string]string{} // path → blob
+	var contents []string
+	for _, wf := range workflows {
+		file := ".azure-pipelines/" + path.Base(wf)
+		if len(workflows) == 1 && !exists("azure-pipelines.yml") {
+			file = "azure-pipelines.yml"
+		}
+		if exists(file) {
+			r.Unconverted = append(r.Unconverted, fmt.Sprintf("%s: %s already exists", wf, file))
+			continue
+		}
+		data, err := runGit(ctx, nil, "-C", dir, "show", "HEAD:"+wf)
+		if err != nil {
+			r.Error = fmt.Sprintf("reading %s: %v", wf, err)
+			return r
+		}
+		converted, notes, err := convertWorkflow(wf, []byte(data), defaultBranch)
+		if err != nil {
+			r.Unconverted = append(r.Unconverted, fmt.Sprintf("%s: not valid YAML: %v", wf, err))
+			continue
+		}
+		r.Unconverted = append(r.Unconverted, notes...)
+		blob, err := gitInput(dir, converted, "hash-object", "-w", "--stdin")
+		if err != nil {
+			r.Error = fmt.Sprintf("writing %s: %v", file, err)
+			return r
+		}
+		files[file] = blob
+		contents = append(contents, converted)
+		r.Files = append(r.Files, file)
+	}
+	for _, u := range r.Unconverted {
+		logMsg(fmt.Sprintf("Not converted in %s: %s", repo, u))
+	}
+	if len(files) == 0 {
+		return r
+	}
+
+	tree, err := treeWith(ctx, dir, "HEAD^{tree}", files)
+	if err != nil {
+		r.Error = fmt.Sprintf("building the tree: %v", err)
+		return r
+	}
+	commit, err := signer.commitTree(ctx, dir, tree, "HEAD", "Convert GitHub Actions workflows to Azure Pipelines\n", logMsg)
+	if err != nil {
+		r.Error = fmt.Sprintf("committing the pipelines: %v", err)
+		return r
+	}
+	if _, err := runGit(ctx, nil, "-C", dir, "update-ref", "refs/heads/"+branch, commit); err != nil {
+		r.Error = fmt.Sprintf("git update-ref: %v", err)
+		return r
+	}
+	if _, output, err := pushRefs(ctx, dir, "target", stream, "refs/heads/"+branch); err != nil {
+		sig := matchBranchPolicy(output + err.Error())
+		if sig == "" {
+			r.Error = fmt.Sprintf("pushing %s: %v: %s", branch, err, lastLine(output))
+			return r
+		}
+		var saved string
+		for i, file := range r.Files {
+			if saved, err = saveManualChange(ctx, dir, t.runID, name, branch, path.Base(file), contents[i]); err != nil {
+				r.Error = fmt.Sprintf("pushing %s was rejected by a branch policy (%s), and saving the change failed: %v", branch, sig, err)
+				return r
+			}
+		}
+		r.Manual = saved
+		logMsg(fmt.Sprintf("Warning: a branch policy rejected %s of %s (%s); the converted pipelines are saved in %s to apply by hand.", branch, repo, sig, saved))
+		return r
+	}
+	r.Branch = branch
+	logMsg(fmt.Sprintf("Converted %d workflow(s) of %s to Azure Pipelines on branch %s; %d item(s) left to finish by hand.", len(r.Files), repo, branch, len(r.Unconverted)))
+	return r
+}
@@ -0,0 +1,270 @@
+// Package migrator starts gitui headless runs from other Go programs and
+// reports their results.
+//
+// It is a client of the gitui binary, not the migration engine. The engine
+// stays in gitui's package main, where the window and --headless share
+// it, and Go cannot import a main package. A Migrator runs
+// "gitui --headless --json" (gitui-cli, built with -tags nogui, or the
+// window's binary) the way a CI pipeline does, and decodes the result the
+// run prints for each repository. What the engine does is what a headless
+// run does: only its flags, passed through MigrationJob.Args, configure
+// it, and the run's state file, reports and audit log are written to the
+// working directory as for any headless run, so a later job can --resume
+// or --retry-failed.
+package migrator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes of gitui --headless.
+const (
+	ExitOK        = 0   // everything succeeded (warnings too, unless Strict)
+	ExitRunError  = 1   // the run itself failed or could not start
+	ExitPartial   = 2   // the run finished but some repositories failed
+	ExitWarnings  = 3   // with Strict: no failures, but some warnings
+	ExitCancelled = 130 // interrupted
+)
+
+// Statuses of a Result the callers most often test for; the run reports
+// others, such as skipped or held repositories, as they are worded in its
+// report.
+const (
+	StatusMigrated  = "migrated"
+	StatusWarnings  = "migrated with warnings"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusDryRun    = "dry run"
+)
+
+// MigrationJob is one headless run: which repositories to migrate, from
+// where into which Azure DevOps project.
+type MigrationJob struct {
+	// Repos are "name" or "owner/name", or the single entry "all" for
+	// every repository the token lists. Empty with Resume or RetryFailed.
+	Repos       []string
+	RetryFailed bool // migrate again the repositories that failed last time
+	Resume      bool // like RetryFailed, and those left unfinished too
+
+	GitHubOrg  string // empty for the token user's own repositories
+	ADOOrg     string // e.g. https://dev.azure.com/myorg
+	ADOProject string
+
+	// The tokens are handed to gitui in GITHUB_PAT and ADO_PAT, never on
+	// its command line. Empty ones are taken from this process's
+	// environment.
+	GitHubToken string
+	ADOToken    string
+
+	Concurrency int  // repositories at once; 0 for gitui's default
+	DryRun      bool // create, clone and push nothing
+	Strict      bool // exit with ExitWarnings if anything is not clean
+
+	// Args are further --headless flags, such as "--archived", "leave".
+	Args []string
+}
+
+// args returns the job as gitui's command line.
+func (j MigrationJob) args() ([]string, error) {
+	given := 0
+	for _, set := range []bool{len(j.Repos) > 0, j.RetryFailed, j.Resume} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return nil, errors.New("a job needs exactly one of Repos, RetryFailed or Resume")
+	}
+	if j.ADOOrg == "" || j.ADOProject == "" {
+		return nil, errors.New("a job needs ADOOrg and ADOProject")
+	}
+	args := []string{"--headless", "--json", "--ado-org", j.ADOOrg, "--ado-project", j.ADOProject}
+	if j.GitHubOrg != "" {
+		args = append(args, "--github-org", j.GitHubOrg)
+	}
+	switch {
+	case j.RetryFailed:
+		args = append(args, "--retry-failed")
+	case j.Resume:
+		args = append(args, "--resume")
+	default:
+		args = append(args, "--repos", strings.Join(j.Repos, ","))
+	}
+	if j.Concurrency > 0 {
+		args = append(args, "--concurrency", strconv.Itoa(j.Concurrency))
+	}
+	if j.DryRun {
+		args = append(args, "--dry-run")
+	}
+	if j.Strict {
+		args = append(args, "--strict")
+	}
+	return append(args, j.Args...), nil
+}
+
+// Result is the outcome of one repository.
+type Result struct {
+	Name     string        // the source repository, "owner/name"
+	Status   string        // as in the run's report, e.g. StatusMigrated
+	Duration time.Duration // how long its migration took
+	Error    string        // why it failed, if it did
+}
+
+// Failed reports whether the repository failed to migrate.
+func (r Result) Failed() bool {
+	return r.Status == StatusFailed
+}
+
+// result is gitui's --json output for one repository.
+type result struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// ExitError is returned when a run could not start or did not finish.
+type ExitError struct {
+	Code    int
+	LastLog string // the last line gitui logged, usually why
+}
+
+func (e *ExitError) Error() string {
+	if e.Code == ExitCancelled {
+		return "gitui: run cancelled"
+	}
+	if e.LastLog != "" {
+		return fmt.Sprintf("gitui: exit status %d: %s", e.Code, e.LastLog)
+	}
+	return fmt.Sprintf("gitui: exit status %d", e.Code)
+}
+
+// Migrator runs migration jobs with a gitui binary.
+type Migrator struct {
+	Binary string    // path of gitui or gitui-cli, or its name on PATH; required
+	Dir    string    // working directory of the runs; the current one if empty
+	Log    io.Writer // gitui's log; discarded if nil
+	Env    []string  // extra environment, KEY=value
+}
+
+// New returns a Migrator that runs binary.
+func New(binary string) *Migrator {
+	return &Migrator{Binary: binary}
+}
+
+// Run migrates job, calling onResult, if not nil, with each repository's
+// result as it finishes. Repositories that failed are results, not an
+// error: the error is an *ExitError only if the run failed as a whole or
+// was cancelled. Cancelling ctx interrupts gitui, which records the
+// repositories not yet migrated as cancelled.
+func (m *Migrator) Run(ctx context.Context, job MigrationJob, onResult func(Result)) ([]Result, error) {
+	args, err := job.args()
+	if err != nil {
+		return nil, err
+	}
+	if m.Binary == "" {
+		return nil, errors.New("a Migrator needs the gitui binary to run")
+	}
+	cmd := exec.CommandContext(ctx, m.Binary, args...)
+	cmd.Dir = m.Dir
+	cmd.Env = append(os.Environ(), m.Env...)
+	if job.GitHubToken != "" {
+		cmd.Env = append(cmd.Env, "GITHUB_PAT="+job.GitHubToken)
+	}
+	if job.ADOToken != "" {
+		cmd.Env = append(cmd.Env, "ADO_PAT="+job.ADOToken)
+	}
+	// An interrupt, like Ctrl+C, lets the run record what it cancelled;
+	// it is killed if it has not stopped a minute later.
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = time.Minute
+	logs := &lastLine{}
+	cmd.Stderr = logs
+	if m.Log != nil {
+		cmd.Stderr = io.MultiWriter(m.Log, logs)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var r result
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue // not a result
+		}
+		res := Result{
+			Name:     r.Name,
+			Status:   r.Status,
+			Duration: time.Duration(r.DurationSeconds * float64(time.Second)),
+			Error:    r.Error,
+		}
+		results = append(results, res)
+		if onResult != nil {
+			onResult(res)
+		}
+	}
+	err = cmd.Wait()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return results, err
+	}
+	switch code := exit.ExitCode(); code {
+	case ExitPartial, ExitWarnings:
+		return results, nil
+	case -1:
+		// Killed, having not stopped in time.
+		return results, &ExitError{Code: ExitCancelled, LastLog: logs.String()}
+	default:
+		return results, &ExitError{Code: code, LastLog: logs.String()}
+	}
+}
+
+// lastLine keeps the last complete line written to it.
+type lastLine struct {
+	last    string
+	partial []byte
+}
+
+func (l *lastLine) Write(p []byte) (int, error) {
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(l.partial[:i])); line != "" {
+			l.last = line
+		}
+		l.partial = l.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (l *lastLine) String() string {
+	if line := strings.TrimSpace(string(l.partial)); line != "" {
+		return line
+	}
+	return l.last
+}
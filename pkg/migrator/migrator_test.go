@@ -0,0 +1,144 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGitUI writes a shell script standing in for gitui-cli and returns a
+// Migrator that runs it.
+func fakeGitUI(t *testing.T, script string) *Migrator {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gitui is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "gitui-cli")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &Migrator{Binary: path, Dir: t.TempDir()}
+}
+
+var job = MigrationJob{
+	Repos:       []string{"acme/api", "acme/web"},
+	ADOOrg:      "https://dev.azure.com/acme",
+	ADOProject:  "Platform",
+	GitHubToken: "ghp_secret",
+	ADOToken:    "ado_secret",
+	Concurrency: 2,
+}
+
+// Each result line becomes a Result as it is printed; repositories that
+// failed are not an error of the run.
+func TestRunResults(t *testing.T) {
+	m := fakeGitUI(t, `
+printf '%s\n' "$@" > args
+echo "$GITHUB_PAT $ADO_PAT" > tokens
+echo '{"name":"acme/api","status":"migrated","duration_seconds":1.5}'
+echo 'not a result'
+echo '{"name":"acme/web","status":"failed","duration_seconds":0.25,"error":"push rejected"}'
+echo '[12:00:00] Migrated 1 of 2 repositories.' >&2
+exit 2
+`)
+	var seen []string
+	results, err := m.Run(t.Context(), job, func(r Result) { seen = append(seen, r.Name) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Result{
+		{Name: "acme/api", Status: StatusMigrated, Duration: 1500 * time.Millisecond},
+		{Name: "acme/web", Status: StatusFailed, Duration: 250 * time.Millisecond, Error: "push rejected"},
+	}
+	if !slices.Equal(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	if !slices.Equal(seen, []string{"acme/api", "acme/web"}) {
+		t.Errorf("onResult saw %v", seen)
+	}
+	if results[0].Failed() || !results[1].Failed() {
+		t.Errorf("Failed() wrong for %+v", results)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(m.Dir, "args"))
+	wantArgs := "--headless --json --ado-org https://dev.azure.com/acme --ado-project Platform --repos acme/api,acme/web --concurrency 2"
+	if got := strings.Join(strings.Fields(string(args)), " "); got != wantArgs {
+		t.Errorf("args = %q, want %q", got, wantArgs)
+	}
+	tokens, _ := os.ReadFile(filepath.Join(m.Dir, "tokens"))
+	if got := strings.TrimSpace(string(tokens)); got != "ghp_secret ado_secret" {
+		t.Errorf("tokens in the environment = %q", got)
+	}
+}
+
+// A run that fails as a whole is an *ExitError with what gitui logged last.
+func TestRunExitError(t *testing.T) {
+	m := fakeGitUI(t, `
+echo 'Error: GITHUB_PAT and ADO_PAT must be set' >&2
+exit 1
+`)
+	_, err := m.Run(t.Context(), job, nil)
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != ExitRunError {
+		t.Fatalf("err = %v, want exit status %d", err, ExitRunError)
+	}
+	if exit.LastLog != "Error: GITHUB_PAT and ADO_PAT must be set" {
+		t.Errorf("LastLog = %q", exit.LastLog)
+	}
+}
+
+// Cancelling the context interrupts gitui, which records the rest as
+// cancelled before it exits.
+func TestRunCancel(t *testing.T) {
+	m := fakeGitUI(t, `
+trap 'echo "{\"name\":\"acme/web\",\"status\":\"cancelled\"}"; exit 130' INT
+echo '{"name":"acme/api","status":"migrated","duration_seconds":1}'
+while :; do sleep 0.05; done
+`)
+	ctx, cancel := context.WithCancel(t.Context())
+	results, err := m.Run(ctx, job, func(r Result) {
+		if r.Name == "acme/api" {
+			cancel()
+		}
+	})
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != ExitCancelled {
+		t.Fatalf("err = %v, want cancelled", err)
+	}
+	if len(results) != 2 || results[1].Status != StatusCancelled {
+		t.Errorf("results = %+v, want acme/web cancelled", results)
+	}
+}
+
+// Which gitui runs is always the caller's choice, never a guess.
+func TestRunNeedsBinary(t *testing.T) {
+	if _, err := (&Migrator{}).Run(t.Context(), job, nil); err == nil {
+		t.Error("ran without a binary")
+	}
+}
+
+func TestJobArgs(t *testing.T) {
+	for _, j := range []MigrationJob{
+		{ADOOrg: "https://dev.azure.com/acme", ADOProject: "Platform"},
+		{Repos: []string{"all"}, Resume: true, ADOOrg: "https://dev.azure.com/acme", ADOProject: "Platform"},
+		{Repos: []string{"all"}, ADOOrg: "https://dev.azure.com/acme"},
+	} {
+		if _, err := j.args(); err == nil {
+			t.Errorf("%+v: no error", j)
+		}
+	}
+	args, err := MigrationJob{Resume: true, GitHubOrg: "acme", ADOOrg: "o", ADOProject: "p", DryRun: true, Strict: true, Args: []string{"--archived", "leave"}}.args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--headless --json --ado-org o --ado-project p --github-org acme --resume --dry-run --strict --archived leave"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+}
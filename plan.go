@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// planEntry is one repository the plan will migrate.
+type planEntry struct {
+	Source        string // owner/name on GitHub
+	Target        string // repository name in ADO
+	SizeKB        int
+	SizeFrom      string // "" for GitHub's figure; see checkSizes
+	DefaultBranch string
+	Archived      bool
+	License       string // SPDX identifier, "none" or "other"; see licenseID
+	Visibility    string // on GitHub
+	Notes         []string
+	Analysis      *repoAnalysis // nil unless the plan was deep-scanned
+}
+
+// migrationPlan is the fully resolved result of applying a config to the
+// repositories GitHub lists.
+type migrationPlan struct {
+	Config     *migrationConfig
+	Options    string
+	Entries    []planEntry
+	Filtered   []string // repositories left out, with the reason
+	Collisions []string // target name collisions and how they were resolved
+	Errors     []string // problems that must be fixed before migrating
+	Warnings   []string // worth knowing, but the plan can go ahead
+}
+
+// adoInvalidNameChars are characters Azure DevOps does not allow in
+// repository names.
+const adoInvalidNameChars = `/\:*?"<>|;#$,{}+=[]%&'` + "`"
+
+// validateADOName reports why name is not a valid Azure DevOps repository
+// name, or "" if it is.
+func validateADOName(name string) string {
+	switch {
+	case name == "":
+		return "empty name"
+	case len(name) > 64:
+		return "longer than 64 characters"
+	case strings.ContainsAny(name, adoInvalidNameChars):
+		return "contains a character ADO does not allow"
+	case strings.HasPrefix(name, "_") || strings.HasPrefix(name, "."):
+		return "starts with _ or ."
+	case strings.HasSuffix(name, "."):
+		return "ends with ."
+	}
+	return ""
+}
+
+// matchAny reports whether name matches any of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// buildPlan applies cfg to the listed repositories. existing is the target
+// project's current repositories, or nil if they could not be listed.
+func buildPlan(cfg *migrationConfig, repos []gitHubRepo, existing []azureRepo) *migrationPlan {
+	p := &migrationPlan{Config: cfg}
+
+	if cfg.ADO.Org == "" || cfg.ADO.Project == "" {
+		p.Errors = append(p.Errors, "ado.org and ado.project are required")
+	}
+	lfs, err := cfg.lfsPolicy()
+	if err != nil {
+		p.Errors = append(p.Errors, err.Error())
+	}
+	archive, err := cfg.archiveMode()
+	if err != nil {
+		p.Errors = append(p.Errors, err.Error())
+	}
+	p.Options = fmt.Sprintf("lfs missing: %s; archived: %s; delete after: %t",
+		lfsPolicyNames[lfs], archiveModeNames[archive], cfg.DeleteAfter)
+	collisions, err := cfg.collisionPolicy()
+	if err != nil {
+		p.Errors = append(p.Errors, err.Error())
+	}
+	autoResolve := len(cfg.Collisions) > 0 && err == nil
+	if autoResolve {
+		p.Options += "; collisions: " + collisions.String()
+	}
+
+	// Select repositories.
+	for _, r := range repos {
+		switch {
+		case len(cfg.Repos) > 0 && !matchAny(cfg.Repos, r.Name):
+			continue
+		case matchAny(cfg.Exclude, r.Name):
+			p.Filtered = append(p.Filtered, r.FullName+": excluded by pattern")
+		case r.Archived && !cfg.IncludeArchived:
+			p.Filtered = append(p.Filtered, r.FullName+": archived")
+		case r.Fork && !cfg.IncludeForks:
+			p.Filtered = append(p.Filtered, r.FullName+": fork")
+		default:
+			target := r.Name
+			if t, ok := cfg.Rename[r.FullName]; ok {
+				target = t
+			} else if t, ok := cfg.Rename[r.Name]; ok {
+				target = t
+			}
+			p.Entries = append(p.Entries, planEntry{
+				Source:        r.FullName,
+				Target:        target,
+				SizeKB:        r.Size,
+				DefaultBranch: r.DefaultBranch,
+				Archived:      r.Archived,
+				License:       licenseID(r.License),
+				Visibility:    r.Visibility,
+			})
+		}
+	}
+	sort.Slice(p.Entries, func(i, j int) bool { return p.Entries[i].Source < p.Entries[j].Source })
+
+	// With collision strategies, names are resolved the way a run resolves
+	// them, in order, and each rename is noted.
+	if autoResolve {
+		names := newNameAllocator(collisions)
+		for i := range p.Entries {
+			e := &p.Entries[i]
+			name, strategy, err := names.Name(e.Source, e.Target)
+			if err != nil {
+				p.Errors = append(p.Errors, fmt.Sprintf("%s: %v", e.Source, err))
+				continue
+			}
+			if name != e.Target {
+				e.Notes = append(e.Notes, fmt.Sprintf("renamed from %s by collision strategy %s", e.Target, strategy))
+				p.Collisions = append(p.Collisions, fmt.Sprintf("%s: %s -> %s (%s)", e.Source, e.Target, name, strategy))
+				e.Target = name
+			}
+		}
+	}
+
+	existingNames := map[string]string{}
+	for _, r := range existing {
+		existingNames[strings.ToLower(r.Name)] = r.Name
+	}
+
+	// ADO repository names are unique case-insensitively within a project.
+	byDefault := map[string][]int{}
+	byTarget := map[string][]int{}
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		name := repoShortName(e.Source)
+		byDefault[strings.ToLower(name)] = append(byDefault[strings.ToLower(name)], i)
+		byTarget[strings.ToLower(e.Target)] = append(byTarget[strings.ToLower(e.Target)], i)
+
+		if reason := validateADOName(e.Target); reason != "" {
+			p.Errors = append(p.Errors, fmt.Sprintf("%s: invalid target name %q: %s", e.Source, e.Target, reason))
+		}
+		if name, ok := existingNames[strings.ToLower(e.Target)]; ok {
+			if name != e.Target {
+				e.Notes = append(e.Notes, fmt.Sprintf("target %s already exists (differs only by case)", name))
+			} else {
+				e.Notes = append(e.Notes, fmt.Sprintf("target %s already exists", name))
+			}
+		}
+	}
+	targetsTaken := map[string]bool{}
+	for t := range byTarget {
+		targetsTaken[t] = true
+	}
+	for t := range existingNames {
+		targetsTaken[t] = true
+	}
+	for _, idx := range byTarget {
+		if len(idx) < 2 {
+			continue
+		}
+		var sources, suggestions []string
+		caseOnly := false
+		for n, i := range idx {
+			e := &p.Entries[i]
+			sources = append(sources, e.Source)
+			e.Notes = append(e.Notes, "unresolved collision")
+			if e.Target != p.Entries[idx[0]].Target {
+				caseOnly = true
+			}
+			// Suggest keeping the first name and suffixing the others.
+			if n > 0 {
+				suggested := disambiguatedName(e.Source, targetsTaken)
+				targetsTaken[strings.ToLower(suggested)] = true
+				suggestions = append(suggestions, fmt.Sprintf("rename %s to %s", e.Source, suggested))
+			}
+		}
+		msg := fmt.Sprintf("unresolved collision on %q: %s", p.Entries[idx[0]].Target, strings.Join(sources, ", "))
+		if caseOnly {
+			msg += " (the names differ only by case, which ADO treats as the same name)"
+		}
+		p.Errors = append(p.Errors, msg+"; suggested: "+strings.Join(suggestions, ", "))
+	}
+	for _, idx := range byDefault {
+		if len(idx) < 2 || autoResolve {
+			// Automatic renames are listed as they are made.
+			continue
+		}
+		var renames []string
+		for _, i := range idx {
+			renames = append(renames, fmt.Sprintf("%s -> %s", p.Entries[i].Source, p.Entries[i].Target))
+		}
+		p.Collisions = append(p.Collisions, strings.Join(renames, ", "))
+	}
+	sort.Strings(p.Collisions)
+	sort.Strings(p.Errors)
+	return p
+}
+
+// checkHolds notes the entries that are on hold and warns about them: a
+// run will skip them whatever the plan says.
+func (p *migrationPlan) checkHolds(holds repoHolds) {
+	var held []string
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		if h := holds.Get(e.Source); h != nil {
+			e.Notes = append(e.Notes, h.String())
+			held = append(held, fmt.Sprintf("%s (%s)", e.Source, h.Reason))
+		}
+	}
+	if len(held) > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%d repositories are on hold and will not be migrated: %s", len(held), strings.Join(held, ", ")))
+	}
+}
+
+// checkLicenses notes the entries the license policy flags and warns
+// about them, with what a run will do.
+func (p *migrationPlan) checkLicenses(policy *licensePolicy, privateProject bool) {
+	if policy == nil {
+		return
+	}
+	var flagged []string
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		rec := &licenseRecord{License: e.License}
+		rec.Decision, rec.Why = policy.decide(e.License, privateProject)
+		if rec.Flagged() {
+			e.Notes = append(e.Notes, rec.String())
+			flagged = append(flagged, fmt.Sprintf("%s (%s %s)", e.Source, rec.License, rec.Decision))
+		}
+	}
+	if len(flagged) == 0 {
+		return
+	}
+	var action string
+	switch policy.Gate {
+	case licenseGateWarn:
+		action = "will be migrated with a warning"
+	case licenseGateConfirm:
+		action = "need confirming, each, before they are migrated"
+	case licenseGateBlock:
+		action = "will be skipped"
+	}
+	p.Warnings = append(p.Warnings, fmt.Sprintf("%d repositories are flagged by the license policy and %s: %s", len(flagged), action, strings.Join(flagged, ", ")))
+}
+
+// deepScan runs the deep analysis on every entry not matched by the
+// config's skip_deep_analysis patterns, which are marked as excluded
+// instead. progress is told about each repository as it is scanned.
+func (p *migrationPlan) deepScan(ctx context.Context, token string, progress func(string)) {
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		if matchAny(p.Config.SkipDeepAnalysis, repoShortName(e.Source)) || matchAny(p.Config.SkipDeepAnalysis, e.Source) {
+			e.Analysis = &repoAnalysis{Excluded: true}
+			continue
+		}
+		progress(fmt.Sprintf("Analyzing %s (%d of %d)...", e.Source, i+1, len(p.Entries)))
+		a := analyzeRepo(ctx, e.Source, token)
+		if a.Error != "" {
+			e.Notes = append(e.Notes, "deep scan failed: "+a.Error)
+		}
+		if a.Bytes > 0 && e.SizeFrom != sizeMeasured {
+			kb, note := measuredKB(e.SizeKB, a.Bytes)
+			e.SizeKB, e.SizeFrom = kb, sizeMeasured
+			if note != "" {
+				e.Notes = append(e.Notes, note)
+			}
+		}
+		e.Analysis = &a
+	}
+}
+
+// deep reports whether the plan was deep-scanned.
+func (p *migrationPlan) deep() bool {
+	return len(p.Entries) > 0 && p.Entries[0].Analysis != nil
+}
+
+// totalKB is the estimated size of everything in the plan.
+func (p *migrationPlan) totalKB() int {
+	total := 0
+	for _, e := range p.Entries {
+		total += e.SizeKB
+	}
+	return total
+}
+
+// formatKB renders a size given in KB.
+func formatKB(kb int) string {
+	switch {
+	case kb >= 1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(kb)/(1024*1024))
+	case kb >= 1024:
+		return fmt.Sprintf("%.1f MB", float64(kb)/1024)
+	}
+	return fmt.Sprintf("%d KB", kb)
+}
+
+// writeText writes the plan as aligned text.
+func (p *migrationPlan) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Source: %s\n", orDefault(p.Config.GitHub.Org, "(token user's repositories)"))
+	fmt.Fprintf(w, "Target: %s/%s\n", p.Config.ADO.Org, p.Config.ADO.Project)
+	fmt.Fprintf(w, "Options: %s\n\n", p.Options)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if p.deep() {
+		fmt.Fprintln(tw, "SOURCE\tTARGET\tDEFAULT BRANCH\tSIZE\tLARGE BLOBS\tLFS\tSUBMODULES\tNOTES")
+	} else {
+		fmt.Fprintln(tw, "SOURCE\tTARGET\tDEFAULT BRANCH\tSIZE\tNOTES")
+	}
+	for _, e := range p.Entries {
+		if a := e.Analysis; a != nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Source, e.Target, e.DefaultBranch, e.sizeText(),
+				a.largeBlobsText(), a.lfsText(), a.submodulesText(), e.notes())
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Source, e.Target, e.DefaultBranch, e.sizeText(), e.notes())
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d repositories, estimated %s\n", len(p.Entries), formatKB(p.totalKB()))
+
+	writeTextSection(w, "Filtered out", p.Filtered)
+	writeTextSection(w, "Collisions", p.Collisions)
+	writeTextSection(w, "Warnings", p.Warnings)
+	writeTextSection(w, "Errors", p.Errors)
+}
+
+func writeTextSection(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, l := range lines {
+		fmt.Fprintf(w, "  %s\n", l)
+	}
+}
+
+// writeMarkdown writes the plan as Markdown for pasting into a PR.
+func (p *migrationPlan) writeMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "**Source:** %s  \n", orDefault(p.Config.GitHub.Org, "(token user's repositories)"))
+	fmt.Fprintf(w, "**Target:** %s/%s  \n", p.Config.ADO.Org, p.Config.ADO.Project)
+	fmt.Fprintf(w, "**Options:** %s\n\n", p.Options)
+	if p.deep() {
+		fmt.Fprintln(w, "| Source | Target | Default branch | Size | Large blobs | LFS | Submodules | Notes |")
+		fmt.Fprintln(w, "|---|---|---|---|---|---|---|---|")
+	} else {
+		fmt.Fprintln(w, "| Source | Target | Default branch | Size | Notes |")
+		fmt.Fprintln(w, "|---|---|---|---|---|")
+	}
+	for _, e := range p.Entries {
+		if a := e.Analysis; a != nil {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s | %s |\n", e.Source, e.Target, e.DefaultBranch, e.sizeText(),
+				a.largeBlobsText(), a.lfsText(), a.submodulesText(), e.notes())
+			continue
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", e.Source, e.Target, e.DefaultBranch, e.sizeText(), e.notes())
+	}
+	fmt.Fprintf(w, "\n%d repositories, estimated %s\n", len(p.Entries), formatKB(p.totalKB()))
+
+	writeMarkdownSection(w, "Filtered out", p.Filtered)
+	writeMarkdownSection(w, "Collisions", p.Collisions)
+	writeMarkdownSection(w, "Warnings", p.Warnings)
+	writeMarkdownSection(w, "Errors", p.Errors)
+}
+
+func writeMarkdownSection(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### %s\n\n", title)
+	for _, l := range lines {
+		fmt.Fprintf(w, "- %s\n", l)
+	}
+}
+
+func (e planEntry) notes() string {
+	notes := e.Notes
+	if e.Archived {
+		notes = append([]string{"archived"}, notes...)
+	}
+	return strings.Join(notes, "; ")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// runPlanCommand implements "gitui plan": it prints the resolved plan and
+// returns the process exit code, exitRunError if the plan has errors.
+func runPlanCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	configPath := fs.String("config", "migrate.yaml", "migration config file")
+	markdown := fs.Bool("markdown", false, "print Markdown instead of a text table")
+	runbook := fs.Bool("runbook", false, "print the cutover runbook (Markdown, per wave) instead of the plan")
+	deep := fs.Bool("deep", false, "clone each repository to scan for large blobs, LFS and submodules (skips skip_deep_analysis)")
+	savedListing := fs.Bool("saved-listing", false, "plan from the organization's saved listing (see Fetch Repos), even one still going or interrupted, instead of listing it again")
+	setUsage(fs, "plan [flags]")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	}
+	githubToken := os.Getenv("GITHUB_PAT")
+	if githubToken == "" && (!*savedListing || *deep || *runbook) {
+		fmt.Fprintln(os.Stderr, "Error: GITHUB_PAT must be set to list repositories")
+		return exitRunError
+	}
+	var repos []gitHubRepo
+	var listing *listingState
+	if *savedListing {
+		if cfg.GitHub.Org == "" {
+			fmt.Fprintln(os.Stderr, "Error: --saved-listing needs github.org; only organizations' listings are saved")
+			return exitRunError
+		}
+		if listing, err = loadListing(cfg.GitHub.Org); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading the saved listing:", err)
+			return exitRunError
+		}
+		if listing == nil {
+			fmt.Fprintf(os.Stderr, "Error: no saved listing of %s in %s\n", cfg.GitHub.Org, listingsDir)
+			return exitRunError
+		}
+		repos = listing.Repos
+	} else if repos, err = listGitHubReposPaged(ctx, cfg.GitHub.Org, githubToken, func(repos []gitHubRepo) {
+		fmt.Fprintf(os.Stderr, "Listed %d GitHub repositories...\n", len(repos))
+	}); err != nil {
+		if ctx.Err() != nil {
+			return exitCancelled
+		}
+		fmt.Fprintln(os.Stderr, "Error listing GitHub repositories:", err)
+		return exitRunError
+	}
+
+	// Existing target repos are optional; they need read access to ADO.
+	var existing []azureRepo
+	if adoToken := os.Getenv("ADO_PAT"); adoToken != "" && cfg.ADO.Org != "" && cfg.ADO.Project != "" {
+		existing, err = listAzureRepos(ctx, cfg.ADO.Org, cfg.ADO.Project, adoToken)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not list existing ADO repositories:", err)
+		}
+	}
+
+	p := buildPlan(cfg, repos, existing)
+	if listing != nil && !listing.Complete {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("planned from an %s; repositories listed after it are not in the plan", listing))
+	}
+	if holds, err := loadHolds(); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not read the holds:", err)
+	} else {
+		p.checkHolds(holds)
+	}
+	if licenses, err := cfg.licensePolicy(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitRunError
+	} else if licenses != nil {
+		// Without ADO access the project is taken to be private, which
+		// flags the most.
+		privateProject := true
+		if adoToken := os.Getenv("ADO_PAT"); adoToken != "" && licenses.PrivateOnly && cfg.ADO.Org != "" && cfg.ADO.Project != "" {
+			if privateProject, err = azureProjectPrivate(ctx, cfg.ADO.Org, cfg.ADO.Project, adoToken); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: could not read the project's visibility, taking it to be private:", err)
+			}
+		}
+		p.checkLicenses(licenses, privateProject)
+	}
+	if notes, err := repoNotes(); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not read the notes:", err)
+	} else {
+		p.addOperatorNotes(notes)
+	}
+	p.checkSizes(ctx, githubToken, measuredSizes(), func(msg string) { fmt.Fprintln(os.Stderr, msg) })
+	if *deep {
+		p.deepScan(ctx, githubToken, func(msg string) { fmt.Fprintln(os.Stderr, msg) })
+	}
+	// An interrupt (see runSubcommand) stops the API calls and clones
+	// above; a plan it cut short is not printed.
+	if ctx.Err() != nil {
+		return exitCancelled
+	}
+	switch {
+	case *runbook:
+		p.writeRunbook(os.Stdout, calibrate(), runbookPrechecks(ctx, p, githubToken))
+	case *markdown:
+		p.writeMarkdown(os.Stdout)
+	default:
+		p.writeText(os.Stdout)
+	}
+	if len(p.Errors) > 0 {
+		return exitRunError
+	}
+	return exitOK
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A profile is the window's settings, minus credentials, saved to a file
+// to share with teammates: each is exported by name, such as
+// "recycle_bin" or "azure_devops.org", with its value as text.
+
+// profileSchema is the version of the profile file format. It goes up
+// whenever a change would make older versions misread a profile; they
+// refuse newer files rather than guess at them.
+const profileSchema = 1
+
+// profileFile is a profile as exported.
+type profileFile struct {
+	Schema   int               `json:"schema"`
+	Name     string            `json:"name,omitempty"`
+	Exported string            `json:"exported,omitempty"`
+	Settings map[string]string `json:"settings"`
+}
+
+// errNotAProfile is returned for files that are not gitui profiles.
+var errNotAProfile = errors.New("not a gitui profile")
+
+// newProfile returns a profile of settings, leaving out credentials.
+func newProfile(name string, settings map[string]string) *profileFile {
+	p := &profileFile{Schema: profileSchema, Name: name, Exported: fileTimestamp(time.Now()), Settings: map[string]string{}}
+	for key, value := range settings {
+		if !credentialSetting(key) {
+			p.Settings[key] = value
+		}
+	}
+	return p
+}
+
+// marshal formats the profile for its file.
+func (p *profileFile) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// parseProfile parses a profile file. Files of a newer schema than this
+// version reads are refused.
+func parseProfile(data []byte) (*profileFile, error) {
+	var p profileFile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotAProfile, err)
+	}
+	switch {
+	case p.Schema == 0:
+		return nil, fmt.Errorf("%w: it has no schema version", errNotAProfile)
+	case p.Schema > profileSchema:
+		return nil, fmt.Errorf("the profile was exported by a newer version of gitui (profile schema %d, this version reads up to %d); update gitui to import it", p.Schema, profileSchema)
+	}
+	if p.Settings == nil {
+		p.Settings = map[string]string{}
+	}
+	return &p, nil
+}
+
+// credentialWords are the words in a setting's name that make it a
+// credential, which profiles never carry.
+var credentialWords = []string{"token", "pat", "secret", "password", "credential", "credentials", "key"}
+
+// credentialSetting reports whether the setting named key is, or may be,
+// a credential. Access key IDs count: they are not secret, but identify
+// the account.
+func credentialSetting(key string) bool {
+	words := strings.FieldsFunc(strings.ToLower(key), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	for _, w := range words {
+		if containsString(credentialWords, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropCredentials removes the credential settings a profile file should
+// not have had, as one edited by hand might, and returns their names.
+func (p *profileFile) dropCredentials() []string {
+	var dropped []string
+	for key := range p.Settings {
+		if credentialSetting(key) {
+			dropped = append(dropped, key)
+			delete(p.Settings, key)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// profileChange is a setting an imported profile would change.
+type profileChange struct {
+	Key    string
+	Mine   string // the current value
+	Theirs string // the profile's value
+}
+
+// Conflict reports whether the change would replace a value, rather
+// than fill in one left blank.
+func (c profileChange) Conflict() bool {
+	return c.Mine != ""
+}
+
+// diffProfile returns the settings in theirs that differ from mine, in
+// the order of keys; settings theirs lacks, or whose values differ only
+// in surrounding space, are left out.
+func diffProfile(keys []string, mine, theirs map[string]string) []profileChange {
+	var changes []profileChange
+	for _, key := range keys {
+		t, ok := theirs[key]
+		if !ok {
+			continue
+		}
+		m := strings.TrimSpace(mine[key])
+		if t = strings.TrimSpace(t); t != m {
+			changes = append(changes, profileChange{Key: key, Mine: m, Theirs: t})
+		}
+	}
+	return changes
+}
+
+// unknownSettings returns, sorted, the settings of the profile not among
+// keys, such as those of a newer version within the same schema.
+func (p *profileFile) unknownSettings(keys []string) []string {
+	var unknown []string
+	for key := range p.Settings {
+		if !containsString(keys, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
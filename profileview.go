@@ -0,0 +1,366 @@
+//go:build !nogui
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// profileSetting is a window setting profiles export and import.
+type profileSetting struct {
+	Key   string
+	Label string
+	Get   func() string
+	Set   func(string)
+	// Validate checks a value before it is set, as if typed in; nil takes
+	// any value.
+	Validate func(string) error
+}
+
+// entrySetting is the setting of an entry, validated by its validator.
+func entrySetting(key, label string, e *widget.Entry) profileSetting {
+	return profileSetting{Key: key, Label: label,
+		Get:      func() string { return strings.TrimSpace(e.Text) },
+		Set:      e.SetText,
+		Validate: e.Validator,
+	}
+}
+
+// selectSetting is the setting of a select, which must be one of its
+// options.
+func selectSetting(key, label string, s *widget.Select) profileSetting {
+	return profileSetting{Key: key, Label: label,
+		Get: func() string { return s.Selected },
+		Set: s.SetSelected,
+		Validate: func(value string) error {
+			if !containsString(s.Options, value) {
+				return fmt.Errorf("%q is not one of %s", value, strings.Join(s.Options, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// checkSetting is the setting of a check, true or false.
+func checkSetting(key, label string, c *widget.Check) profileSetting {
+	return profileSetting{Key: key, Label: label,
+		Get: func() string { return strconv.FormatBool(c.Checked) },
+		Set: func(value string) {
+			checked, _ := strconv.ParseBool(value)
+			c.SetChecked(checked)
+		},
+		Validate: func(value string) error {
+			_, err := strconv.ParseBool(value)
+			return err
+		},
+	}
+}
+
+// showProfileExport saves settings to a profile file, credentials left
+// out.
+func showProfileExport(w fyne.Window, settings []profileSetting, logMsg func(string)) {
+	d := dialog.NewFileSave(func(f fyne.URIWriteCloser, err error) {
+		if err != nil || f == nil {
+			return
+		}
+		defer f.Close()
+		values := map[string]string{}
+		for _, s := range settings {
+			values[s.Key] = s.Get()
+		}
+		name := strings.TrimSuffix(f.URI().Name(), f.URI().Extension())
+		data, err := newProfile(name, values).marshal()
+		if err == nil {
+			_, err = f.Write(data)
+		}
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		logMsg(fmt.Sprintf("Profile %s exported to %s, without credentials.", name, f.URI().Path()))
+	}, w)
+	d.SetFileName("gitui-profile.json")
+	d.Show()
+}
+
+// showProfileImport reads a profile file and shows the settings it would
+// change, to take or keep each. Credentials in the file are never
+// imported, and values are validated as if typed in.
+func showProfileImport(w fyne.Window, settings []profileSetting, logMsg func(string)) {
+	dialog.ShowFileOpen(func(f fyne.URIReadCloser, err error) {
+		if err != nil || f == nil {
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err == nil {
+			var p *profileFile
+			if p, err = parseProfile(data); err == nil {
+				importProfile(w, orDefault(p.Name, f.URI().Name()), p, settings, logMsg)
+				return
+			}
+		}
+		logMsg(fmt.Sprintf("Error: importing profile %s: %v", f.URI().Name(), err))
+		dialog.ShowError(err, w)
+	}, w)
+}
+
+// importProfile shows the changes profile p, named name, would make to
+// settings, each to keep or take, and sets those taken.
+func importProfile(w fyne.Window, name string, p *profileFile, settings []profileSetting, logMsg func(string)) {
+	if dropped := p.dropCredentials(); len(dropped) > 0 {
+		logMsg(fmt.Sprintf("Warning: profile %s carries credential settings, which are never imported: %s.", name, strings.Join(dropped, ", ")))
+	}
+	byKey := map[string]profileSetting{}
+	var keys []string
+	mine := map[string]string{}
+	for _, s := range settings {
+		byKey[s.Key] = s
+		keys = append(keys, s.Key)
+		mine[s.Key] = s.Get()
+	}
+	if unknown := p.unknownSettings(keys); len(unknown) > 0 {
+		logMsg(fmt.Sprintf("Warning: profile %s has settings this version does not know, which are ignored: %s.", name, strings.Join(unknown, ", ")))
+	}
+	changes := diffProfile(keys, mine, p.Settings)
+	if len(changes) == 0 {
+		logMsg(fmt.Sprintf("Profile %s matches the current settings; nothing to import.", name))
+		dialog.ShowInformation("Import profile", "The profile matches the current settings.", w)
+		return
+	}
+
+	const keep, take = "Keep mine", "Take theirs"
+	choices := map[string]*widget.RadioGroup{}
+	var radios []*widget.RadioGroup
+	invalid := 0
+	rows := container.NewGridWithColumns(4,
+		widget.NewLabelWithStyle("Setting", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Mine", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Theirs", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(""),
+	)
+	for _, c := range changes {
+		s := byKey[c.Key]
+		radio := widget.NewRadioGroup([]string{keep, take}, nil)
+		radio.Horizontal = true
+		radio.Required = true
+		// A blank setting takes the profile's value unless told not to;
+		// one set already is kept unless told otherwise.
+		radio.SetSelected(keep)
+		if !c.Conflict() {
+			radio.SetSelected(take)
+		}
+		theirs := widget.NewLabel(orDefault(c.Theirs, "(blank)"))
+		theirs.Wrapping = fyne.TextWrapWord
+		if s.Validate != nil {
+			if err := s.Validate(c.Theirs); err != nil {
+				theirs.SetText(fmt.Sprintf("%s (invalid: %v)", c.Theirs, err))
+				radio.SetSelected(keep)
+				radio.Disable()
+				invalid++
+			}
+		}
+		if !radio.Disabled() {
+			radios = append(radios, radio)
+		}
+		choices[c.Key] = radio
+		mineLabel := widget.NewLabel(orDefault(c.Mine, "(blank)"))
+		mineLabel.Wrapping = fyne.TextWrapWord
+		rows.Add(widget.NewLabel(s.Label))
+		rows.Add(mineLabel)
+		rows.Add(theirs)
+		rows.Add(radio)
+	}
+	setAll := func(choice string) {
+		for _, r := range radios {
+			r.SetSelected(choice)
+		}
+	}
+	note := widget.NewLabel(fmt.Sprintf("Profile %s would change %d setting(s). Credentials are never imported; enter them yourself.", name, len(changes)))
+	note.Wrapping = fyne.TextWrapWord
+	content := container.NewBorder(
+		container.NewVBox(note, container.NewHBox(
+			widget.NewButton("Keep all mine", func() { setAll(keep) }),
+			widget.NewButton("Take all theirs", func() { setAll(take) }),
+		)),
+		nil, nil, nil,
+		container.NewVScroll(rows),
+	)
+	d := dialog.NewCustomConfirm("Import profile", "Import", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		var taken []string
+		for _, c := range changes {
+			if choices[c.Key].Selected != take {
+				continue
+			}
+			byKey[c.Key].Set(c.Theirs)
+			taken = append(taken, c.Key)
+		}
+		sort.Strings(taken)
+		msg := fmt.Sprintf("Imported profile %s: took %d setting(s), kept %d.", name, len(taken), len(changes)-len(taken))
+		if len(taken) > 0 {
+			msg = fmt.Sprintf("Imported profile %s: took %s; kept %d setting(s).", name, strings.Join(taken, ", "), len(changes)-len(taken))
+		}
+		if invalid > 0 {
+			msg += fmt.Sprintf(" %d invalid value(s) were not imported.", invalid)
+		}
+		logMsg(msg)
+	}, w)
+	d.Resize(fyne.NewSize(820, 560))
+	d.Show()
+}
+
+// applySavedProfile sets the settings p has to its values, validated as if
+// typed in, and returns how many were set and the ones whose values were
+// invalid. Credentials a hand-edited file might have are never set.
+func applySavedProfile(p *savedProfile, settings []profileSetting) (applied int, invalid []string) {
+	for _, s := range settings {
+		value, ok := p.Settings[s.Key]
+		if !ok || credentialSetting(s.Key) {
+			continue
+		}
+		if s.Validate != nil {
+			if err := s.Validate(value); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s (%v)", s.Label, err))
+				continue
+			}
+		}
+		s.Set(value)
+		applied++
+	}
+	return applied, invalid
+}
+
+// newSavedProfilesRow returns the window's row of named profiles saved to
+// disk: a dropdown, filled in at launch, that sets the window to the
+// profile picked, and buttons to save the current settings under a name
+// and to delete the profile picked.
+func newSavedProfilesRow(w fyne.Window, settings func() []profileSetting, logMsg func(string)) fyne.CanvasObject {
+	path, err := savedProfilesPath()
+	if err != nil {
+		logMsg(fmt.Sprintf("Error: saved profiles are unavailable: %v", err))
+		return widget.NewLabel("Saved profiles are unavailable.")
+	}
+	// load reads the file afresh, so another window's saves are kept.
+	load := func() *savedProfilesFile {
+		profiles, err := loadSavedProfiles(path)
+		if err != nil {
+			logMsg(fmt.Sprintf("Error: reading saved profiles %s: %v", path, err))
+			return nil
+		}
+		return profiles
+	}
+	var names []string
+	if profiles := load(); profiles != nil {
+		names = profiles.Names()
+	}
+	picker := widget.NewSelect(names, nil)
+	picker.PlaceHolder = "Choose a saved profile"
+	// show lists names with selected picked, without applying it.
+	show := func(names []string, selected string) {
+		picker.Options = names
+		picker.Selected = selected
+		picker.Refresh()
+	}
+	picker.OnChanged = func(name string) {
+		profiles := load()
+		if profiles == nil {
+			return
+		}
+		p := profiles.Get(name)
+		if p == nil {
+			logMsg(fmt.Sprintf("Error: profile %s is no longer saved.", name))
+			show(profiles.Names(), "")
+			return
+		}
+		applied, invalid := applySavedProfile(p, settings())
+		msg := fmt.Sprintf("Loaded profile %s: set %d setting(s).", name, applied)
+		if len(invalid) > 0 {
+			msg += fmt.Sprintf(" Left alone, invalid: %s.", strings.Join(invalid, ", "))
+		}
+		logMsg(msg)
+	}
+
+	save := func(name string) {
+		profiles := load()
+		if profiles == nil {
+			return
+		}
+		values := map[string]string{}
+		for _, s := range settings() {
+			values[s.Key] = s.Get()
+		}
+		profiles.Put(name, values)
+		if err := profiles.save(path); err != nil {
+			logMsg(fmt.Sprintf("Error: saving profile %s: %v", name, err))
+			dialog.ShowError(err, w)
+			return
+		}
+		show(profiles.Names(), name)
+		logMsg(fmt.Sprintf("Profile %s saved to %s, without credentials.", name, path))
+	}
+	saveBtn := widget.NewButton("Save Profile...", func() {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(picker.Selected)
+		nameEntry.Validator = func(text string) error {
+			if strings.TrimSpace(text) == "" {
+				return errors.New("enter a name")
+			}
+			return nil
+		}
+		dialog.ShowForm("Save profile", "Save", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("Name", nameEntry)},
+			func(ok bool) {
+				name := strings.TrimSpace(nameEntry.Text)
+				if !ok || name == "" {
+					return
+				}
+				if containsString(picker.Options, name) {
+					dialog.ShowConfirm("Save profile", fmt.Sprintf("Replace the saved profile %s with the current settings?", name), func(ok bool) {
+						if ok {
+							save(name)
+						}
+					}, w)
+					return
+				}
+				save(name)
+			}, w)
+	})
+	deleteBtn := widget.NewButton("Delete", func() {
+		name := picker.Selected
+		if name == "" {
+			return
+		}
+		dialog.ShowConfirm("Delete profile", fmt.Sprintf("Delete the saved profile %s?", name), func(ok bool) {
+			if !ok {
+				return
+			}
+			profiles := load()
+			if profiles == nil {
+				return
+			}
+			if profiles.Remove(name) {
+				if err := profiles.save(path); err != nil {
+					logMsg(fmt.Sprintf("Error: deleting profile %s: %v", name, err))
+					dialog.ShowError(err, w)
+					return
+				}
+				logMsg(fmt.Sprintf("Profile %s deleted.", name))
+			}
+			show(profiles.Names(), "")
+		}, w)
+	})
+	return container.NewBorder(nil, nil, widget.NewLabel("Profile:"), container.NewHBox(saveBtn, deleteBtn), picker)
+}
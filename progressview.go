@@ -0,0 +1,274 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Repository states shown in the progress table.
+const (
+	progressQueued    = "queued"
+	progressCloning   = "cloning"
+	progressWorking   = "working"
+	progressPushing   = "pushing"
+	progressDone      = "done"
+	progressFailed    = "failed"
+	progressCancelled = "cancelled"
+)
+
+// progressState returns the state shown for a repository in phase.
+func progressState(phase string) string {
+	switch phase {
+	case "metadata", "clone":
+		return progressCloning
+	case "lfs", "push":
+		return progressPushing
+	}
+	return progressWorking
+}
+
+// progressColumns are the columns of the progress table.
+var progressColumns = []string{"Repository", "State", "Phase", "Transfer", "Time", "Outcome"}
+
+// progressRow is one repository of the run in progress.
+type progressRow struct {
+	Repo     string
+	State    string
+	Phase    string
+	Transfer string // the git transfer in flight, see transferLiveness
+	Status   string // the final status, once finished
+	Error    string // why it failed, if it did
+	Started  time.Time
+	Took     time.Duration
+}
+
+// progressView shows the state of each repository of the run in progress,
+// so what is in flight can be seen at a glance rather than pieced together
+// from interleaved log lines.
+type progressView struct {
+	ui *uiBatcher
+
+	mu     sync.Mutex
+	rows   []*progressRow
+	byRepo map[string]*progressRow
+	// pausedUntil is when the GitHub rate limit requests are paused for
+	// resets, per resource.
+	pausedUntil map[string]time.Time
+
+	count *widget.Label
+	table *widget.Table
+}
+
+func newProgressView(ui *uiBatcher) *progressView {
+	v := &progressView{ui: ui, byRepo: map[string]*progressRow{}, pausedUntil: map[string]time.Time{}}
+	v.count = widget.NewLabel("No run in progress.")
+	v.table = widget.NewTableWithHeaders(
+		func() (int, int) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			return len(v.rows), len(progressColumns)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			label.Truncation = fyne.TextTruncateEllipsis
+			label.SetText(v.cell(id))
+		},
+	)
+	v.table.ShowHeaderColumn = false
+	v.table.CreateHeader = func() fyne.CanvasObject { return widget.NewLabel("") }
+	v.table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		if id.Col >= 0 {
+			o.(*widget.Label).SetText(progressColumns[id.Col])
+		}
+	}
+	for col, width := range []float32{260, 100, 110, 300, 90, 360} {
+		v.table.SetColumnWidth(col, width)
+	}
+	return v
+}
+
+// cell returns the text of a table cell.
+func (v *progressView) cell(id widget.TableCellID) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if id.Row >= len(v.rows) {
+		return ""
+	}
+	r := v.rows[id.Row]
+	switch id.Col {
+	case 0:
+		return r.Repo
+	case 1:
+		return r.State
+	case 2:
+		return r.Phase
+	case 3:
+		return r.Transfer
+	case 4:
+		switch {
+		case r.Took > 0:
+			return formatDuration(r.Took)
+		case !r.Started.IsZero():
+			return formatDuration(time.Since(r.Started))
+		}
+		return ""
+	default:
+		if r.Error != "" {
+			return r.Status + ": " + r.Error
+		}
+		return r.Status
+	}
+}
+
+// Start shows repos, all queued.
+func (v *progressView) Start(repos []string) {
+	v.mu.Lock()
+	v.rows = nil
+	v.byRepo = map[string]*progressRow{}
+	for _, repo := range repos {
+		r := &progressRow{Repo: repo, State: progressQueued}
+		v.rows = append(v.rows, r)
+		v.byRepo[repo] = r
+	}
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// Phase records that repo entered phase.
+func (v *progressView) Phase(repo, phase string) {
+	v.mu.Lock()
+	r := v.row(repo)
+	if r.Started.IsZero() {
+		r.Started = time.Now()
+	}
+	r.State, r.Phase = progressState(phase), phase
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// Transfer records the state of repo's git transfer in flight; "" when
+// there is none. Repositories not in the run, such as a fidelity test's,
+// are left out.
+func (v *progressView) Transfer(repo, state string) {
+	v.mu.Lock()
+	r, ok := v.byRepo[repo]
+	if ok {
+		r.Transfer = state
+	}
+	v.mu.Unlock()
+	if ok {
+		v.refresh()
+	}
+}
+
+// Finish records repo's final status.
+func (v *progressView) Finish(repo, status string, took time.Duration) {
+	v.mu.Lock()
+	r := v.row(repo)
+	switch {
+	case status == statusCancelled:
+		r.State = progressCancelled
+	case succeeded(status), status == statusSplit, status == statusWontMigrate, status == statusHeld, status == statusLicenseBlocked, status == statusDryRun:
+		r.State = progressDone
+	default:
+		r.State = progressFailed
+	}
+	r.Phase, r.Transfer, r.Status, r.Took = "", "", status, took
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// SetError records why repo failed, for its outcome.
+func (v *progressView) SetError(repo, msg string) {
+	v.mu.Lock()
+	v.row(repo).Error = msg
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// RateLimit records that GitHub requests against resource's rate limit
+// are paused until until, or resumed if until is zero, and counts down to
+// the reset beside the counts.
+func (v *progressView) RateLimit(resource string, until time.Time) {
+	v.mu.Lock()
+	if until.IsZero() {
+		delete(v.pausedUntil, resource)
+	} else {
+		v.pausedUntil[resource] = until
+	}
+	v.mu.Unlock()
+	v.refresh()
+	if until.IsZero() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			v.mu.Lock()
+			current := v.pausedUntil[resource].Equal(until)
+			if current && !time.Now().Before(until) {
+				delete(v.pausedUntil, resource)
+			}
+			v.mu.Unlock()
+			v.refresh()
+			if !current || !time.Now().Before(until) {
+				return
+			}
+		}
+	}()
+}
+
+// row returns repo's row, adding it if Start did not list it. v.mu must
+// be held.
+func (v *progressView) row(repo string) *progressRow {
+	r, ok := v.byRepo[repo]
+	if !ok {
+		r = &progressRow{Repo: repo, State: progressQueued}
+		v.rows = append(v.rows, r)
+		v.byRepo[repo] = r
+	}
+	return r
+}
+
+// refresh schedules redrawing the table and the counts.
+func (v *progressView) refresh() {
+	v.ui.Schedule(v, func() {
+		counts := map[string]int{}
+		var paused []string
+		v.mu.Lock()
+		for _, r := range v.rows {
+			counts[r.State]++
+		}
+		for resource, until := range v.pausedUntil {
+			paused = append(paused, fmt.Sprintf("GitHub %s rate limit, resuming in %s", resource, formatDuration(time.Until(until).Round(time.Second))))
+		}
+		v.mu.Unlock()
+		sort.Strings(paused)
+		inFlight := counts[progressCloning] + counts[progressWorking] + counts[progressPushing]
+		text := fmt.Sprintf("%d in flight, %d queued, %d done, %d failed", inFlight, counts[progressQueued], counts[progressDone], counts[progressFailed])
+		if counts[progressCancelled] > 0 {
+			text += fmt.Sprintf(", %d cancelled", counts[progressCancelled])
+		}
+		if len(paused) > 0 {
+			text += " - paused for the " + strings.Join(paused, "; ")
+		}
+		v.count.SetText(text)
+		v.table.Refresh()
+	})
+}
+
+// Content returns the widget tree for the view.
+func (v *progressView) Content() fyne.CanvasObject {
+	return container.NewBorder(v.count, nil, nil, nil, v.table)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Providers are registered by name, each declaring the settings it needs
+// so the window can render a form for it without knowing the provider. The
+// built-in ones are listed in the tables below; a provider added in its own
+// file registers itself from an init function:
+//
+//	func init() {
+//		registerTarget(&targetFactory{
+//			Name: "Example Git",
+//			Fields: []providerField{
+//				{Key: "url", Label: "Server URL", PlaceHolder: "https://git.example.com"},
+//				{Key: "token", Label: "Token", Secret: true},
+//			},
+//			New: func(cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+//				return &exampleTarget{baseURL: cfg["url"], token: cfg["token"]}, nil
+//			},
+//		})
+//	}
+//
+// where exampleTarget implements targetProvider. Nothing else changes: the
+// provider appears in the Target list with its fields. providers_test.go
+// has the example in full.
+
+// providerField is a setting a provider needs.
+type providerField struct {
+	Key         string // key in the configuration passed to New
+	Label       string
+	PlaceHolder string
+	Secret      bool // a credential: masked, and redacted from support bundles
+	Optional    bool
+}
+
+// missingFields returns the labels of the required fields that are empty
+// in cfg.
+func missingFields(fields []providerField, cfg map[string]string) []string {
+	var missing []string
+	for _, f := range fields {
+		if !f.Optional && strings.TrimSpace(cfg[f.Key]) == "" {
+			missing = append(missing, f.Label)
+		}
+	}
+	return missing
+}
+
+// targetFactory registers a target provider: its settings, and how to
+// build it from their values, keyed by providerField.Key. logMsg is the
+// run's log.
+type targetFactory struct {
+	Name   string
+	Fields []providerField
+	New    func(cfg map[string]string, logMsg func(string)) (targetProvider, error)
+}
+
+// sourceProvider is a git hosting service that repositories are migrated
+// from.
+type sourceProvider interface {
+	// Name is the provider's display name.
+	Name() string
+	// ListRepos lists the repositories to migrate, as "owner/name".
+	ListRepos(ctx context.Context) ([]string, error)
+	// CloneURL returns an authenticated URL to clone repo from.
+	CloneURL(ctx context.Context, repo string) string
+}
+
+// describedSource is implemented by the sources other than GitHub. The
+// migration's metadata steps take GitHub's repository object, so such a
+// source describes its repositories in that shape; the steps that only
+// GitHub has an API for (autolinks, moved repositories, LFS locks) are
+// skipped for it.
+type describedSource interface {
+	// RepoMetadata returns repo's default branch, archived flag,
+	// visibility and size.
+	RepoMetadata(ctx context.Context, repo string) (*gitHubRepo, error)
+	// Host is the host clones come from, for the per-host limits.
+	Host() string
+}
+
+// isGitHub reports whether from is GitHub; nil counts as GitHub, the
+// default.
+func isGitHub(from sourceProvider) bool {
+	_, other := from.(describedSource)
+	return !other
+}
+
+// sourceFactory registers a source provider, like targetFactory.
+type sourceFactory struct {
+	Name   string
+	Fields []providerField
+	New    func(cfg map[string]string) (sourceProvider, error)
+}
+
+// targetFactories are the registered target providers, in the order the
+// UI offers them.
+var targetFactories = []*targetFactory{
+	{
+		Name: "Azure DevOps",
+		Fields: []providerField{
+			{Key: "token", Label: "Azure PAT", PlaceHolder: "Azure DevOps PAT Token", Secret: true},
+			{Key: "org", Label: "Azure Org URL", PlaceHolder: "Azure Organization URL or name (e.g. https://dev.azure.com/yourOrg)"},
+			{Key: "project", Label: "Azure Project", PlaceHolder: "Azure Project Name"},
+		},
+		New: func(cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+			org, note, err := normalizeAzureOrg(cfg["org"])
+			if err != nil {
+				return nil, err
+			}
+			if note != "" && logMsg != nil {
+				logMsg(note + ".")
+			}
+			return &azureTarget{org: org, project: cfg["project"], token: cfg["token"], logMsg: logMsg}, nil
+		},
+	},
+	{
+		Name: "Gitea/Forgejo",
+		Fields: []providerField{
+			{Key: "url", Label: "Gitea URL", PlaceHolder: "Gitea/Forgejo URL (e.g. https://git.example.com)"},
+			{Key: "org", Label: "Gitea Org", PlaceHolder: "Gitea Organization"},
+			{Key: "token", Label: "Gitea Token", PlaceHolder: "Gitea Access Token", Secret: true},
+		},
+		New: func(cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+			return &giteaTarget{baseURL: cfg["url"], org: cfg["org"], token: cfg["token"]}, nil
+		},
+	},
+	{
+		Name: "AWS CodeCommit",
+		Fields: []providerField{
+			{Key: "region", Label: "AWS Region", PlaceHolder: "AWS Region (e.g. us-east-1)"},
+			{Key: "key", Label: "Access Key ID", PlaceHolder: "Access Key ID (blank: default credential chain)", Optional: true},
+			{Key: "secret", Label: "Secret Key", PlaceHolder: "Secret Access Key", Secret: true, Optional: true},
+		},
+		New: func(cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+			return newCodeCommitTarget(cfg["region"], cfg["key"], cfg["secret"])
+		},
+	},
+}
+
+// sourceFactories are the registered source providers.
+var sourceFactories = []*sourceFactory{
+	{
+		Name: "GitHub",
+		Fields: []providerField{
+			{Key: "token", Label: "GitHub PAT", Secret: true},
+			{Key: "org", Label: "Source", Optional: true},
+			{Key: "skip_archived", Label: "Skip archived", PlaceHolder: "true or false", Optional: true},
+			{Key: "visibility", Label: "Visibility", PlaceHolder: strings.Join(repoVisibilities, ", "), Optional: true},
+		},
+		New: func(cfg map[string]string) (sourceProvider, error) {
+			visibility, err := parseVisibility(cfg["visibility"])
+			if err != nil {
+				return nil, err
+			}
+			return &gitHubSource{org: cfg["org"], token: cfg["token"], skipArchived: cfg["skip_archived"] == "true", visibility: visibility}, nil
+		},
+	},
+}
+
+// registerTarget adds a target provider. Names must be unique.
+func registerTarget(f *targetFactory) {
+	if findTarget(f.Name) != nil {
+		panic(fmt.Sprintf("target provider %q registered twice", f.Name))
+	}
+	targetFactories = append(targetFactories, f)
+}
+
+// registerSource adds a source provider. Names must be unique.
+func registerSource(f *sourceFactory) {
+	if findSource(f.Name) != nil {
+		panic(fmt.Sprintf("source provider %q registered twice", f.Name))
+	}
+	sourceFactories = append(sourceFactories, f)
+}
+
+// findTarget returns the target provider registered as name, or nil.
+func findTarget(name string) *targetFactory {
+	for _, f := range targetFactories {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// findSource returns the source provider registered as name, or nil.
+func findSource(name string) *sourceFactory {
+	for _, f := range sourceFactories {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// targetTypes returns the names of the registered target providers, in
+// order.
+func targetTypes() []string {
+	var names []string
+	for _, f := range targetFactories {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// newTarget builds the target provider registered as name from cfg,
+// checking its required fields first.
+func newTarget(name string, cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+	f := findTarget(name)
+	if f == nil {
+		return nil, fmt.Errorf("no target provider %q", name)
+	}
+	if missing := missingFields(f.Fields, cfg); len(missing) > 0 {
+		return nil, fmt.Errorf("%s: %s required", f.Name, strings.Join(missing, ", "))
+	}
+	return f.New(cfg, logMsg)
+}
+
+// newSource builds the source provider registered as name from cfg,
+// checking its required fields first.
+func newSource(name string, cfg map[string]string) (sourceProvider, error) {
+	f := findSource(name)
+	if f == nil {
+		return nil, fmt.Errorf("no source provider %q", name)
+	}
+	if missing := missingFields(f.Fields, cfg); len(missing) > 0 {
+		return nil, fmt.Errorf("%s: %s required", f.Name, strings.Join(missing, ", "))
+	}
+	return f.New(cfg)
+}
+
+// gitHubSource lists and clones the repositories of a GitHub organization,
+// or of the token's user if org is empty. With skipArchived, archived
+// repositories are left out of the list, and only those of visibility are
+// listed unless it is "all".
+type gitHubSource struct {
+	org          string
+	token        string
+	skipArchived bool
+	visibility   string
+}
+
+func (s *gitHubSource) Name() string { return "GitHub" }
+
+func (s *gitHubSource) ListRepos(ctx context.Context) ([]string, error) {
+	return s.ListReposResumable(ctx, false, nil)
+}
+
+// ListReposResumable lists like ListRepos. An organization's listing is
+// saved as it goes (see listGitHubOrgResumable) and, with resume, an
+// interrupted one is continued; onPage gets the repositories listed so
+// far. The token user's own repositories are listed page by page too,
+// but not saved.
+func (s *gitHubSource) ListReposResumable(ctx context.Context, resume bool, onPage func([]string)) ([]string, error) {
+	var pageFn func([]gitHubRepo)
+	if onPage != nil {
+		pageFn = func(repos []gitHubRepo) { onPage(s.names(repos)) }
+	}
+	if s.org == "" {
+		repos, err := listGitHubReposPaged(ctx, "", s.token, pageFn)
+		return s.names(repos), err
+	}
+	repos, err := listGitHubOrgResumable(ctx, s.org, s.token, resume, pageFn)
+	return s.names(repos), err
+}
+
+// names returns the full names of repos, leaving out archived ones with
+// skipArchived and those of another visibility.
+func (s *gitHubSource) names(repos []gitHubRepo) []string {
+	var names []string
+	for _, r := range repos {
+		if r.Archived && s.skipArchived || !matchesVisibility(r, s.visibility) {
+			continue
+		}
+		names = append(names, r.FullName)
+	}
+	return names
+}
+
+// InterruptedListing returns the organization's saved listing if it did
+// not complete, or nil.
+func (s *gitHubSource) InterruptedListing() (*listingState, error) {
+	if s.org == "" {
+		return nil, nil
+	}
+	st, err := loadListing(s.org)
+	if err != nil || st == nil || st.Complete {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (s *gitHubSource) CloneURL(ctx context.Context, repo string) string {
+	return fmt.Sprintf("https://%s@github.com/%s.git", s.token, repo)
+}
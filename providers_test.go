@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// exampleTarget is the provider of the example in providers.go: a git
+// server with a small REST API, registered from nothing but its factory.
+type exampleTarget struct {
+	baseURL string
+	token   string
+}
+
+func (t *exampleTarget) Name() string { return "Example Git" }
+
+// CreateRepo asks the server for the repository and returns its clone
+// URL with the token in it.
+func (t *exampleTarget) CreateRepo(ctx context.Context, name string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, t.baseURL+"/api/repos", strings.NewReader(fmt.Sprintf(`{"name":%q}`, name)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("creating %s: %s", name, resp.Status)
+	}
+	var created struct {
+		CloneURL string `json:"clone_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	return authRemoteURL(ctx, created.CloneURL, t.token), nil
+}
+
+func (t *exampleTarget) SetDefaultBranch(ctx context.Context, name, branch string) error { return nil }
+
+func (t *exampleTarget) MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error) {
+	return "left writable", nil
+}
+
+var exampleTargetFactory = &targetFactory{
+	Name: "Example Git",
+	Fields: []providerField{
+		{Key: "url", Label: "Server URL", PlaceHolder: "https://git.example.com"},
+		{Key: "token", Label: "Token", Secret: true},
+	},
+	New: func(cfg map[string]string, logMsg func(string)) (targetProvider, error) {
+		return &exampleTarget{baseURL: cfg["url"], token: cfg["token"]}, nil
+	},
+}
+
+// withProviders restores the registered providers when t ends.
+func withProviders(t *testing.T) {
+	t.Helper()
+	targets, sources := targetFactories, sourceFactories
+	targetFactories = append([]*targetFactory(nil), targets...)
+	sourceFactories = append([]*sourceFactory(nil), sources...)
+	t.Cleanup(func() { targetFactories, sourceFactories = targets, sources })
+}
+
+// A registered provider is listed, checked for its required settings and
+// built by name, and the provider it builds creates repositories.
+func TestRegisterTargetEndToEnd(t *testing.T) {
+	withProviders(t)
+	var created []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		var body struct{ Name string }
+		json.NewDecoder(r.Body).Decode(&body)
+		created = append(created, body.Name)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"clone_url":"https://git.example.com/%s.git"}`, body.Name)
+	}))
+	defer srv.Close()
+
+	registerTarget(exampleTargetFactory)
+	if types := targetTypes(); types[len(types)-1] != "Example Git" {
+		t.Errorf("targetTypes() = %v, want Example Git last", types)
+	}
+	if _, err := newTarget("Example Git", map[string]string{"url": srv.URL}, nil); err == nil || !strings.Contains(err.Error(), "Token required") {
+		t.Errorf("newTarget without a token: err = %v, want Token required", err)
+	}
+	target, err := newTarget("Example Git", map[string]string{"url": srv.URL, "token": "s3cret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushURL, err := target.CreateRepo(t.Context(), "widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://" + tokenUsername + ":s3cret@git.example.com/widgets.git"; pushURL != want {
+		t.Errorf("CreateRepo = %q, want %q", pushURL, want)
+	}
+	if len(created) != 1 || created[0] != "widgets" {
+		t.Errorf("server created %v, want [widgets]", created)
+	}
+	if got := redactLog("pushing to " + pushURL); strings.Contains(got, "s3cret") {
+		t.Errorf("the token is not redacted from the log: %q", got)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	withProviders(t)
+	for name, register := range map[string]func(){
+		"target": func() { registerTarget(&targetFactory{Name: "Azure DevOps"}) },
+		"source": func() { registerSource(&sourceFactory{Name: "GitHub"}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering a second %s of the same name did not panic", name)
+				}
+			}()
+			register()
+		}()
+	}
+}
+
+func TestNewProviderUnknown(t *testing.T) {
+	if _, err := newTarget("Nowhere", nil, nil); err == nil {
+		t.Error("newTarget of an unregistered name succeeded")
+	}
+	if _, err := newSource("Nowhere", nil); err == nil {
+		t.Error("newSource of an unregistered name succeeded")
+	}
+}
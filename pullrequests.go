@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pull requests are migrated by two content features, both opted into in
+// the content preview. Open pull requests are recreated in Azure DevOps:
+// the head is pushed if the target does not already have it (it may come
+// from a fork), and the new pull request has the original's title and
+// description, a link back to it, and its reviewers where the user mapping
+// knows them. Closed and merged pull requests are archived instead, as a
+// Markdown file committed to a branch of the target repository. Which
+// pull request each open one became is recorded in pullRequestMapPath, so
+// it is created once however often the preview is applied.
+
+// pullRequestMapPath maps GitHub pull requests to the Azure DevOps pull
+// requests they were migrated as, relative to the working directory.
+const pullRequestMapPath = "pull-requests.json"
+
+// userMapPath maps GitHub logins to Azure DevOps users (their email or
+// principal name), one "login,user" line each, for reviewers.
+const userMapPath = "user-map.csv"
+
+// Where closed pull requests are archived in the target repository.
+const (
+	pullArchiveBranch = "migration/pull-requests"
+	pullArchivePath   = "/closed-pull-requests.md"
+)
+
+// pullHeadPrefix is the branch a pull request's head is pushed to when the
+// target does not have it as the original branch.
+const pullHeadPrefix = "github-pr/"
+
+// maxPullDescription is the longest pull request description Azure DevOps
+// accepts.
+const maxPullDescription = 4000
+
+var pullRequestMapMu sync.Mutex
+
+// pullRequestLink records that a GitHub pull request was migrated.
+type pullRequestLink struct {
+	Source      string `json:"source"` // owner/name
+	Number      int    `json:"number"`
+	PullRequest int    `json:"pull_request"` // Azure DevOps ID
+	URL         string `json:"url"`
+	Created     string `json:"created"`
+}
+
+// loadPullRequestMap reads the pull request mapping; no file means none
+// yet.
+func loadPullRequestMap() ([]pullRequestLink, error) {
+	data, err := readFileRecover(pullRequestMapPath, func(data []byte) error {
+		var links []pullRequestLink
+		return json.Unmarshal(data, &links)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []pullRequestLink
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// recordPullRequestLink adds link to the pull request mapping.
+func recordPullRequestLink(link pullRequestLink) error {
+	pullRequestMapMu.Lock()
+	defer pullRequestMapMu.Unlock()
+	links, err := loadPullRequestMap()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(append(links, link), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(pullRequestMapPath, append(data, '\n'), 0644)
+}
+
+// migratedPullRequests returns the Azure DevOps pull request each of
+// source's pull requests was migrated as, by number.
+func migratedPullRequests(source string) (map[int]int, error) {
+	pullRequestMapMu.Lock()
+	links, err := loadPullRequestMap()
+	pullRequestMapMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	done := map[int]int{}
+	for _, l := range links {
+		if strings.EqualFold(l.Source, source) {
+			done[l.Number] = l.PullRequest
+		}
+	}
+	return done, nil
+}
+
+// loadUserMap reads the user mapping, by lower-case GitHub login; no file
+// means no mapping. Lines starting with # are skipped.
+func loadUserMap() (map[string]string, error) {
+	data, err := os.ReadFile(userMapPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	users := map[string]string{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", userMapPath, err)
+		}
+		if len(record) != 2 {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("%s line %d: want GitHub login, Azure DevOps user; got %d field(s)", userMapPath, line, len(record))
+		}
+		users[strings.ToLower(strings.TrimSpace(record[0]))] = strings.TrimSpace(record[1])
+	}
+	return users, nil
+}
+
+// azureUserID returns the identity ID of the organization's user, by email
+// or principal name.
+func azureUserID(ctx context.Context, org, user, token string) (string, error) {
+	apiURL := fmt.Sprintf("%s/_apis/identities?searchFilter=General&filterValue=%s&queryMembership=None&api-version=7.0", vsspsURL(org), url.QueryEscape(user))
+	var result struct {
+		Value []struct {
+			ID string `json:"id"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return "", err
+	}
+	if len(result.Value) == 0 {
+		return "", fmt.Errorf("no Azure DevOps user %s", user)
+	}
+	return result.Value[0].ID, nil
+}
+
+// gitHubPull is the subset of the GitHub pull request API object the
+// migration uses.
+type gitHubPull struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	State     string `json:"state"` // open or closed
+	Draft     bool   `json:"draft"`
+	HTMLURL   string `json:"html_url"`
+	CreatedAt string `json:"created_at"`
+	ClosedAt  string `json:"closed_at"`
+	MergedAt  string `json:"merged_at"` // "" if it was closed unmerged
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		Ref  string `json:"ref"`
+		SHA  string `json:"sha"`
+		Repo *struct {
+			FullName string `json:"full_name"`
+		} `json:"repo"` // nil if the fork was deleted
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	RequestedReviewers []struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewers"`
+}
+
+// listGitHubPulls lists repo's pull requests in state (open, closed or
+// all), oldest first.
+func listGitHubPulls(ctx context.Context, repo, state, token string) ([]gitHubPull, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/pulls?state=%s&direction=asc&per_page=100", repo, state)
+	var pulls []gitHubPull
+	for apiURL != "" {
+		var page []gitHubPull
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return pulls, err
+		}
+		pulls = append(pulls, page...)
+		apiURL = next
+	}
+	return pulls, nil
+}
+
+// pullReviewers returns the logins of pull's reviewers: those requested
+// and those who have reviewed it already, the author left out.
+func pullReviewers(ctx context.Context, repo string, pull gitHubPull, token string) ([]string, error) {
+	var reviews []struct {
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews?per_page=100", repo, pull.Number)
+	if _, err := gitHubGet(ctx, apiURL, token, &reviews); err != nil {
+		return nil, err
+	}
+	var logins []string
+	seen := map[string]bool{strings.ToLower(pull.User.Login): true}
+	add := func(login string) {
+		if login != "" && !seen[strings.ToLower(login)] {
+			seen[strings.ToLower(login)] = true
+			logins = append(logins, login)
+		}
+	}
+	for _, r := range pull.RequestedReviewers {
+		add(r.Login)
+	}
+	for _, r := range reviews {
+		add(r.User.Login)
+	}
+	return logins, nil
+}
+
+// azureBranchSHA returns the commit branch points to in the target
+// repository, "" if it has no such branch.
+func azureBranchSHA(ctx context.Context, t *azureTarget, repo, branch string) (string, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/refs?filter=%s&api-version=7.0", t.org, url.PathEscape(t.project), url.PathEscape(repo), url.QueryEscape("heads/"+branch))
+	var result struct {
+		Value []struct {
+			Name     string `json:"name"`
+			ObjectID string `json:"objectId"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, t.token, nil, http.StatusOK, &result); err != nil {
+		return "", err
+	}
+	// The filter matches by prefix.
+	for _, r := range result.Value {
+		if r.Name == branchRef(branch) {
+			return r.ObjectID, nil
+		}
+	}
+	return "", nil
+}
+
+// pushPullHead pushes the head of source's pull request number to branch
+// of the target repository, fetching it from GitHub into a scratch
+// repository first: a fork's head is not in the migrated clone.
+func pushPullHead(ctx context.Context, t *azureTarget, source, target string, number int, branch, githubToken string) error {
+	repo, err := getAzureRepo(ctx, t.org, t.project, target, t.token)
+	if err != nil {
+		return fmt.Errorf("looking up Azure repo: %v", err)
+	}
+	work, err := os.MkdirTemp("", "gitui-pull-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	if out, err := runGit(ctx, nil, "init", "--bare", "--quiet", work); err != nil {
+		return fmt.Errorf("git init: %v, output: %s", err, out)
+	}
+	sourceURL := fmt.Sprintf("https://%s@github.com/%s.git", githubToken, source)
+	if out, err := runGitTransfer(ctx, "github.com", nil, "-C", work, "fetch", "--no-tags", sourceURL, fmt.Sprintf("refs/pull/%d/head", number)); err != nil {
+		return fmt.Errorf("fetching the head of #%d: %v, output: %s", number, err, strings.ReplaceAll(lastLine(out), githubToken, "***"))
+	}
+	pushURL := authRemoteURL(ctx, repo.RemoteURL, t.token)
+	if out, err := runGitTransfer(ctx, gitHost(pushURL), nil, "-C", work, "push", pushURL, "FETCH_HEAD:"+branchRef(branch)); err != nil {
+		return fmt.Errorf("pushing the head of #%d: %v, output: %s", number, err, strings.ReplaceAll(lastLine(out), t.token, "***"))
+	}
+	return nil
+}
+
+// pullDescription is the description of pull's Azure DevOps pull request:
+// where it came from, then its own, cut to what Azure DevOps accepts.
+func pullDescription(repo string, pull gitHubPull) string {
+	d := fmt.Sprintf("Migrated from GitHub pull request [%s#%d](%s), opened by @%s on %s.\n\n%s", repo, pull.Number, pull.HTMLURL, pull.User.Login, pull.CreatedAt, pull.Body)
+	if len(d) <= maxPullDescription {
+		return d
+	}
+	const cut = "\n\n(cut short; see the original)"
+	return strings.ToValidUTF8(d[:maxPullDescription-len(cut)], "") + cut
+}
+
+// createAzurePull creates the pull request of pull in the target
+// repository from sourceBranch, with the reviewers' identity IDs, and
+// records the mapping.
+func createAzurePull(ctx context.Context, t *azureTarget, repo migratedRepo, pull gitHubPull, sourceBranch string, reviewerIDs []string) error {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/pullrequests?api-version=7.0", t.org, url.PathEscape(t.project), url.PathEscape(repo.Target))
+	var reviewers []map[string]string
+	for _, id := range reviewerIDs {
+		reviewers = append(reviewers, map[string]string{"id": id})
+	}
+	payload := map[string]interface{}{
+		"sourceRefName": branchRef(sourceBranch),
+		"targetRefName": branchRef(pull.Base.Ref),
+		"title":         pull.Title,
+		"description":   pullDescription(repo.Source, pull),
+		"isDraft":       pull.Draft,
+		"reviewers":     reviewers,
+	}
+	var created struct {
+		PullRequestID int `json:"pullRequestId"`
+	}
+	if err := azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusCreated, &created); err != nil {
+		return err
+	}
+	link := pullRequestLink{
+		Source:      repo.Source,
+		Number:      pull.Number,
+		PullRequest: created.PullRequestID,
+		URL:         fmt.Sprintf("%s/%s/_git/%s/pullrequest/%d", t.org, url.PathEscape(t.project), url.PathEscape(repo.Target), created.PullRequestID),
+		Created:     fileTimestamp(time.Now()),
+	}
+	if err := recordPullRequestLink(link); err != nil {
+		return fmt.Errorf("pull request %d created, but not recorded in %s: %v", created.PullRequestID, pullRequestMapPath, err)
+	}
+	return nil
+}
+
+// openPullsFeature previews recreating each repository's open GitHub pull
+// requests not yet migrated.
+func openPullsFeature() *contentFeature {
+	var mu sync.Mutex
+	ids := map[string]string{} // Azure DevOps user -> identity ID
+	return &contentFeature{
+		Name:  "Open pull requests",
+		OptIn: true,
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			pulls, err := listGitHubPulls(ctx, repo.Source, "open", githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("listing GitHub pull requests: %v", err)
+			}
+			done, err := migratedPullRequests(repo.Source)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %v", pullRequestMapPath, err)
+			}
+			users, err := loadUserMap()
+			if err != nil {
+				return nil, err
+			}
+
+			var ops []*contentOp
+			for _, pull := range pulls {
+				pull := pull
+				if _, ok := done[pull.Number]; ok {
+					continue
+				}
+				base, err := azureBranchSHA(ctx, t, repo.Target, pull.Base.Ref)
+				if err != nil {
+					return nil, fmt.Errorf("looking up %s: %v", pull.Base.Ref, err)
+				}
+				if base == "" {
+					return nil, fmt.Errorf("#%d targets %s, which the target repository does not have", pull.Number, pull.Base.Ref)
+				}
+
+				// The original branch is used if the target has it at
+				// the head; a fork's head, or a branch that moved on
+				// since the migration, is pushed to a branch of its own.
+				sourceBranch, push := pull.Head.Ref, false
+				if pull.Head.Repo == nil || !strings.EqualFold(pull.Head.Repo.FullName, repo.Source) {
+					sourceBranch, push = fmt.Sprintf("%s%d", pullHeadPrefix, pull.Number), true
+				} else if sha, err := azureBranchSHA(ctx, t, repo.Target, pull.Head.Ref); err != nil {
+					return nil, fmt.Errorf("looking up %s: %v", pull.Head.Ref, err)
+				} else if sha != pull.Head.SHA {
+					sourceBranch, push = fmt.Sprintf("%s%d", pullHeadPrefix, pull.Number), true
+				}
+
+				logins, err := pullReviewers(ctx, repo.Source, pull, githubToken)
+				if err != nil {
+					return nil, fmt.Errorf("listing the reviewers of #%d: %v", pull.Number, err)
+				}
+				var reviewerIDs, mapped, unmapped []string
+				for _, login := range logins {
+					user := users[strings.ToLower(login)]
+					if user == "" {
+						unmapped = append(unmapped, login)
+						continue
+					}
+					mu.Lock()
+					id, ok := ids[user]
+					mu.Unlock()
+					if !ok {
+						if id, err = azureUserID(ctx, t.org, user, t.token); err != nil {
+							return nil, fmt.Errorf("looking up reviewer %s: %v", user, err)
+						}
+						mu.Lock()
+						ids[user] = id
+						mu.Unlock()
+					}
+					reviewerIDs = append(reviewerIDs, id)
+					mapped = append(mapped, user)
+				}
+
+				details := []string{fmt.Sprintf("%s into %s", sourceBranch, pull.Base.Ref)}
+				if push {
+					details = append(details, fmt.Sprintf("head %s pushed to %s", shortSHA(pull.Head.SHA), sourceBranch))
+				}
+				if pull.Draft {
+					details = append(details, "draft")
+				}
+				if len(mapped) > 0 {
+					details = append(details, "reviewers "+strings.Join(mapped, ", "))
+				}
+				if len(unmapped) > 0 {
+					details = append(details, "not in "+userMapPath+": "+strings.Join(unmapped, ", "))
+				}
+				ops = append(ops, &contentOp{
+					Change: "+",
+					What:   fmt.Sprintf("pull request for #%d %q", pull.Number, pull.Title),
+					Detail: strings.Join(details, "; "),
+					apply: func(ctx context.Context) error {
+						if push {
+							if err := pushPullHead(ctx, t, repo.Source, repo.Target, pull.Number, sourceBranch, githubToken); err != nil {
+								return err
+							}
+						}
+						return createAzurePull(ctx, t, repo, pull, sourceBranch, reviewerIDs)
+					},
+				})
+			}
+			return ops, nil
+		},
+	}
+}
+
+// shortSHA abbreviates a commit ID for display.
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+// closedPullsMarkdown renders repo's closed pull requests as the archive
+// file.
+func closedPullsMarkdown(repo string, pulls []gitHubPull) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Closed pull requests of %s\n\n", repo)
+	b.WriteString("Archived from GitHub when the repository was migrated. Open pull requests were recreated as Azure DevOps pull requests.\n")
+	for _, p := range pulls {
+		outcome := "closed " + p.ClosedAt + " without merging"
+		if p.MergedAt != "" {
+			outcome = "merged " + p.MergedAt
+		}
+		fmt.Fprintf(&b, "\n## #%d %s\n\n", p.Number, p.Title)
+		fmt.Fprintf(&b, "- Author: @%s\n- Opened: %s\n- Outcome: %s\n- Branches: %s into %s\n- Original: %s\n", p.User.Login, p.CreatedAt, outcome, p.Head.Ref, p.Base.Ref, p.HTMLURL)
+		if body := strings.TrimSpace(p.Body); body != "" {
+			b.WriteString("\n" + body + "\n")
+		}
+	}
+	return b.String()
+}
+
+// azureFileContent returns the content of path on branch of the target
+// repository, "" if the branch or the file does not exist.
+func azureFileContent(ctx context.Context, t *azureTarget, repo, branch, path string) (string, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/items?path=%s&versionDescriptor.version=%s&versionDescriptor.versionType=branch&includeContent=true&api-version=7.0",
+		t.org, url.PathEscape(t.project), url.PathEscape(repo), url.QueryEscape(path), url.QueryEscape(branch))
+	var item struct {
+		Content string `json:"content"`
+	}
+	err := azureRequest(ctx, "GET", apiURL, t.token, nil, http.StatusOK, &item)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	return item.Content, err
+}
+
+// commitAzureFile commits content as path on branch of the target
+// repository, whose tip is oldSHA; an empty oldSHA creates the branch,
+// from nothing, with the file alone in it.
+func commitAzureFile(ctx context.Context, t *azureTarget, repo, branch, oldSHA, path, content, message string) error {
+	changeType := "edit"
+	if oldSHA == "" {
+		changeType, oldSHA = "add", strings.Repeat("0", 40)
+	}
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/pushes?api-version=7.0", t.org, url.PathEscape(t.project), url.PathEscape(repo))
+	payload := map[string]interface{}{
+		"refUpdates": []map[string]string{{"name": branchRef(branch), "oldObjectId": oldSHA}},
+		"commits": []map[string]interface{}{{
+			"comment": message,
+			"changes": []map[string]interface{}{{
+				"changeType": changeType,
+				"item":       map[string]string{"path": path},
+				"newContent": map[string]string{"content": content, "contentType": "rawtext"},
+			}},
+		}},
+	}
+	return azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusCreated, nil)
+}
+
+// closedPullsFeature previews archiving each repository's closed and
+// merged GitHub pull requests as pullArchivePath on pullArchiveBranch.
+func closedPullsFeature() *contentFeature {
+	return &contentFeature{
+		Name:  "Closed pull requests archive",
+		OptIn: true,
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			pulls, err := listGitHubPulls(ctx, repo.Source, "closed", githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("listing GitHub pull requests: %v", err)
+			}
+			if len(pulls) == 0 {
+				return nil, nil
+			}
+			content := closedPullsMarkdown(repo.Source, pulls)
+			tip, err := azureBranchSHA(ctx, t, repo.Target, pullArchiveBranch)
+			if err != nil {
+				return nil, fmt.Errorf("looking up %s: %v", pullArchiveBranch, err)
+			}
+			change := "+"
+			if tip != "" {
+				existing, err := azureFileContent(ctx, t, repo.Target, pullArchiveBranch, pullArchivePath)
+				if err != nil {
+					return nil, fmt.Errorf("reading %s: %v", pullArchivePath, err)
+				}
+				if existing == content {
+					return nil, nil
+				}
+				change = "~"
+			}
+			return []*contentOp{{
+				Change: change,
+				What:   fmt.Sprintf("%s on branch %s of %s", strings.TrimPrefix(pullArchivePath, "/"), pullArchiveBranch, repo.Target),
+				Detail: fmt.Sprintf("%d closed pull request(s)", len(pulls)),
+				apply: func(ctx context.Context) error {
+					return commitAzureFile(ctx, t, repo.Target, pullArchiveBranch, tip, pullArchivePath, content, "Archive the closed pull requests of "+repo.Source)
+				},
+			}}, nil
+		},
+	}
+}
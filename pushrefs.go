@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// refOutcome is what happened to one ref in a push.
+type refOutcome struct {
+	Ref     string
+	OK      bool
+	Summary string // git's status text, e.g. "[new tag]" or "[remote rejected] (...)"
+}
+
+// porcelainRefPattern matches git push --porcelain ref lines:
+// "<flag>\t<from>:<to>\t<summary>".
+var porcelainRefPattern = regexp.MustCompile(`^([ +\-*!=])\t([^\t]*):([^\t]+)\t(.*)$`)
+
+// parsePushPorcelain returns the per-ref outcomes in git push --porcelain
+// output. Other lines (progress, "To ...", "Done") are ignored.
+func parsePushPorcelain(output string) []refOutcome {
+	var refs []refOutcome
+	for _, line := range strings.Split(output, "\n") {
+		// Progress on stderr rewrites its line with '\r'; keep what follows.
+		line = strings.TrimRight(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		m := porcelainRefPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		refs = append(refs, refOutcome{Ref: m[3], OK: m[1] != "!", Summary: m[4]})
+	}
+	return refs
+}
+
+// failedRefs returns the refs that did not push, sorted.
+func failedRefs(refs []refOutcome) []string {
+	var failed []string
+	for _, r := range refs {
+		if !r.OK {
+			failed = append(failed, r.Ref)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// pushRefs pushes refspecs to remote and returns the per-ref outcomes. The
+// error is git's; a push that fails before any ref is reported (for
+// example while building the pack) returns no outcomes.
+func pushRefs(ctx context.Context, dir, remote string, stream io.Writer, refspecs ...string) ([]refOutcome, string, error) {
+	pushURL := remoteURL(ctx, dir, remote)
+	output, err := runGitTransfer(ctx, gitHost(pushURL), stream, pushArgs(dir, remote, refspecs...)...)
+	return parsePushPorcelain(output), output, conditionalAccessPushError(err, output, pushURL)
+}
+
+// pushTags pushes every tag and returns the ones that failed. A tag that
+// breaks the whole push, such as one pointing at a missing object, makes
+// git report nothing per ref, so the tags are then pushed one at a time to
+// find the broken ones. Failed tags are retried once on their own before
+// being given up on.
+func pushTags(ctx context.Context, dir, remote string, stream io.Writer) ([]refOutcome, error) {
+	refs, output, err := pushRefs(ctx, dir, remote, stream, "--tags")
+	if err != nil && len(refs) == 0 {
+		tags, lerr := runGit(ctx, nil, "-C", dir, "for-each-ref", "--format=%(refname)", "refs/tags")
+		if lerr != nil {
+			return nil, fmt.Errorf("%v, output: %s", err, output)
+		}
+		for _, tag := range strings.Fields(tags) {
+			one, out, terr := pushRefs(ctx, dir, remote, stream, tag+":"+tag)
+			if terr != nil && len(one) == 0 {
+				one = []refOutcome{{Ref: tag, Summary: lastLine(out)}}
+			}
+			refs = append(refs, one...)
+		}
+	}
+
+	// Retry only what failed.
+	var failed []refOutcome
+	for _, r := range refs {
+		if r.OK {
+			continue
+		}
+		retry, _, rerr := pushRefs(ctx, dir, remote, stream, r.Ref+":"+r.Ref)
+		if rerr != nil || len(retry) == 0 || !retry[0].OK {
+			failed = append(failed, r)
+		}
+	}
+	return failed, nil
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// isHeadRef reports whether ref is a branch, whose failure fails the
+// repository; tags and notes only warn.
+func isHeadRef(ref string) bool {
+	return strings.HasPrefix(ref, "refs/heads/")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub allows a token a number of requests an hour, and says on every
+// response how many are left and when the count resets. Rather than run the
+// limit down and have the rest of a large organization's requests refused,
+// requests pause while only gitHubRateLimitReserve are left and resume when
+// the limit resets; a request refused by an exhausted limit anyway, from
+// another tool sharing the token, is sent again after the reset.
+
+// gitHubRateLimitReserve is how many requests are left unused when
+// requests pause, for the requests already in flight and for the user's
+// other tools.
+const gitHubRateLimitReserve = 20
+
+// gitHubRateLimit is what the last response said of one limit.
+type gitHubRateLimit struct {
+	Remaining int
+	Reset     time.Time
+	paused    bool // a pause until Reset was reported
+}
+
+var (
+	gitHubRateLimitMu sync.Mutex
+	gitHubRateLimits  = map[string]*gitHubRateLimit{} // by gitHubRateLimitKey
+
+	// gitHubRateLimitNotify is told when requests pause for a limit to
+	// reset, and when they resume, with until zero.
+	gitHubRateLimitNotify func(resource string, until time.Time)
+)
+
+// setGitHubRateLimitNotify sets what is told of rate limit pauses; a run
+// sets it to log them and show the countdown.
+func setGitHubRateLimitNotify(notify func(resource string, until time.Time)) {
+	gitHubRateLimitMu.Lock()
+	gitHubRateLimitNotify = notify
+	gitHubRateLimitMu.Unlock()
+}
+
+// gitHubRateLimitResource returns which of GitHub's limits a request to
+// path counts against: search and GraphQL have their own.
+func gitHubRateLimitResource(path string) string {
+	switch {
+	case strings.Contains(path, "/search/"):
+		return "search"
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	}
+	return "core"
+}
+
+// gitHubRateLimitKey returns the key of the limit req counts against: the
+// host, the resource, and a digest of the credentials, since each token
+// has limits of its own.
+func gitHubRateLimitKey(req *http.Request) (key, resource string) {
+	resource = gitHubRateLimitResource(req.URL.Path)
+	sum := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return req.URL.Host + " " + resource + " " + hex.EncodeToString(sum[:8]), resource
+}
+
+// parseGitHubRateLimit returns the limit resp reports, if it is from
+// GitHub and reports one.
+func parseGitHubRateLimit(resp *http.Response) (gitHubRateLimit, bool) {
+	if resp.Header.Get("X-GitHub-Request-Id") == "" {
+		return gitHubRateLimit{}, false
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return gitHubRateLimit{}, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return gitHubRateLimit{}, false
+	}
+	return gitHubRateLimit{Remaining: remaining, Reset: time.Unix(reset, 0)}, true
+}
+
+// gitHubRateLimitTransport paces requests to GitHub by the rate limit its
+// responses report, as described above. Other providers' responses carry
+// no GitHub request id and pass through.
+type gitHubRateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t gitHubRateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, resource := gitHubRateLimitKey(req)
+	for resent := false; ; resent = true {
+		if err := waitGitHubRateLimit(req, key, resource); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		limit, ok := parseGitHubRateLimit(resp)
+		if !ok {
+			return resp, nil
+		}
+		recordGitHubRateLimit(key, limit)
+		// A refused request is sent again once, after the reset, if its
+		// body can be; the caller gets the refusal of any other.
+		if resent || gitHubRateLimitReset(resp).IsZero() || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// recordGitHubRateLimit keeps what a response said of the limit under key.
+// Responses can arrive out of order; within a window the lowest count is
+// the latest.
+func recordGitHubRateLimit(key string, limit gitHubRateLimit) {
+	gitHubRateLimitMu.Lock()
+	defer gitHubRateLimitMu.Unlock()
+	last, ok := gitHubRateLimits[key]
+	if ok && last.Reset.Equal(limit.Reset) {
+		if limit.Remaining < last.Remaining {
+			last.Remaining = limit.Remaining
+		}
+		return
+	}
+	if !ok || limit.Reset.After(last.Reset) {
+		gitHubRateLimits[key] = &limit
+	}
+}
+
+// waitGitHubRateLimit holds req while the limit under key is down to its
+// reserve and has not reset, and tells gitHubRateLimitNotify when the
+// first request starts waiting and when they resume.
+func waitGitHubRateLimit(req *http.Request, key, resource string) error {
+	gitHubRateLimitMu.Lock()
+	limit, ok := gitHubRateLimits[key]
+	if !ok || limit.Remaining > gitHubRateLimitReserve || !time.Now().Before(limit.Reset) {
+		gitHubRateLimitMu.Unlock()
+		return nil
+	}
+	// GitHub's clock and this one differ by up to a second or so.
+	until := limit.Reset.Add(time.Second)
+	notify := gitHubRateLimitNotify
+	first := !limit.paused
+	limit.paused = true
+	gitHubRateLimitMu.Unlock()
+
+	if first && notify != nil {
+		notify(resource, until)
+	}
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-time.After(time.Until(until)):
+	}
+
+	gitHubRateLimitMu.Lock()
+	resumed := gitHubRateLimits[key] == limit
+	if resumed {
+		// Until a response reports the new window, assume it is full.
+		delete(gitHubRateLimits, key)
+	}
+	gitHubRateLimitMu.Unlock()
+	if resumed && notify != nil {
+		notify(resource, time.Time{})
+	}
+	return nil
+}
+
+// gitHubRateLimitMessage returns the log line for a pause until until, or
+// for resuming if until is zero.
+func gitHubRateLimitMessage(resource string, until time.Time) string {
+	if until.IsZero() {
+		return fmt.Sprintf("GitHub API %s rate limit reset; resuming.", resource)
+	}
+	return fmt.Sprintf("Warning: the GitHub API %s rate limit is nearly used up; pausing GitHub requests until %s (%s).",
+		resource, until.Local().Format("15:04:05"), formatDuration(time.Until(until).Round(time.Second)))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// In read-only mode the tool only lists, analyzes, verifies and reports,
+// for auditors whose tokens have no write scope. It is enforced centrally:
+// apiClient refuses every request that is not a GET (or HEAD/OPTIONS), and
+// git transfers refuse to push.
+
+var readOnly atomic.Bool
+
+// errReadOnly is returned for any write attempted in read-only mode.
+var errReadOnly = errors.New("read-only mode: write requests are disabled")
+
+// setReadOnly turns read-only mode on or off.
+func setReadOnly(on bool) {
+	readOnly.Store(on)
+}
+
+func isReadOnly() bool {
+	return readOnly.Load()
+}
+
+// checkReadOnly returns errReadOnly if method is a write and read-only mode
+// is on.
+func checkReadOnly(method string) error {
+	if !isReadOnly() {
+		return nil
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	return fmt.Errorf("%s refused: %w", method, errReadOnly)
+}
+
+// gitRepositoriesNamespace is the Azure DevOps security namespace of Git
+// repositories, and createRepositoryBit its "Create repository" permission.
+const (
+	gitRepositoriesNamespace = "2e9eb7ed-3c0a-47d4-87c1-0ffdd275fd87"
+	createRepositoryBit      = 256
+)
+
+// probeAzureWrite checks, with GET requests only, whether token may create
+// repositories in the project. It returns nil if it may.
+func probeAzureWrite(ctx context.Context, org, project, token string) error {
+	var p struct {
+		ID string `json:"id"`
+	}
+	apiURL := fmt.Sprintf("%s/_apis/projects/%s?api-version=7.0", org, url.PathEscape(project))
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &p); err != nil {
+		return fmt.Errorf("looking up project: %v", err)
+	}
+	var result struct {
+		Value []bool `json:"value"`
+	}
+	apiURL = fmt.Sprintf("%s/_apis/permissions/%s/%d?tokens=%s&api-version=7.0",
+		org, gitRepositoriesNamespace, createRepositoryBit, url.QueryEscape("repoV2/"+p.ID))
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return fmt.Errorf("checking permissions: %v", err)
+	}
+	if len(result.Value) == 0 || !result.Value[0] {
+		return fmt.Errorf("the token cannot create repositories in %s", project)
+	}
+	return nil
+}
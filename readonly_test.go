@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	t.Cleanup(func() { setReadOnly(false) })
+	methods := []string{"GET", "HEAD", "OPTIONS", "POST", "PUT", "PATCH", "DELETE"}
+	for _, on := range []bool{false, true} {
+		setReadOnly(on)
+		for _, method := range methods {
+			err := checkReadOnly(method)
+			write := method != "GET" && method != "HEAD" && method != "OPTIONS"
+			if refused := errors.Is(err, errReadOnly); refused != (on && write) {
+				t.Errorf("read-only %v: checkReadOnly(%s) = %v", on, method, err)
+			}
+		}
+	}
+}
+
+// In read-only mode no write reaches a provider through apiClient; reads
+// go through.
+func TestAPIClientRefusesWritesInReadOnly(t *testing.T) {
+	t.Cleanup(func() { setReadOnly(false) })
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Method)
+	}))
+	defer srv.Close()
+
+	setReadOnly(true)
+	for _, method := range []string{"POST", "PATCH", "DELETE", "PUT"} {
+		req, _ := http.NewRequest(method, srv.URL, nil)
+		if _, err := apiClient.Do(req); !errors.Is(err, errReadOnly) {
+			t.Errorf("%s: err = %v, want errReadOnly", method, err)
+		}
+	}
+	resp, err := apiClient.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if len(received) != 1 || received[0] != "GET" {
+		t.Errorf("the server received %v, want only the GET", received)
+	}
+}
+
+// CodeCommit's SDK client bypasses apiClient; its writes are refused
+// before any request is made.
+func TestCodeCommitWritesRefusedInReadOnly(t *testing.T) {
+	t.Cleanup(func() { setReadOnly(false) })
+	setReadOnly(true)
+	target := &codecommitTarget{region: "us-east-1"}
+	if _, err := target.CreateRepo(t.Context(), "api"); !errors.Is(err, errReadOnly) {
+		t.Errorf("CreateRepo: err = %v, want errReadOnly", err)
+	}
+	if err := target.SetDefaultBranch(t.Context(), "api", "main"); !errors.Is(err, errReadOnly) {
+		t.Errorf("SetDefaultBranch: err = %v, want errReadOnly", err)
+	}
+}
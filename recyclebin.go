@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// recyclePolicy decides what happens when a repository cannot be created
+// because a deleted repository with the same name is still in the Azure
+// DevOps recycle bin (for 30 days after deletion).
+type recyclePolicy int
+
+const (
+	// recycleFail reports the conflict and fails the repository.
+	recycleFail recyclePolicy = iota
+	// recyclePurge permanently deletes the recycled repository, after
+	// confirmation, and retries.
+	recyclePurge
+	// recycleRename creates the repository under an alternative name.
+	recycleRename
+)
+
+// recyclePolicyNames are the UI labels for each recyclePolicy, in order.
+var recyclePolicyNames = []string{
+	"Fail on names in the ADO recycle bin",
+	"Purge the deleted repo (asks first) and retry",
+	"Use an alternative name",
+}
+
+// deletedAzureRepo is a repository in a project's recycle bin.
+type deletedAzureRepo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DeletedDate string `json:"deletedDate"`
+	DeletedBy   struct {
+		DisplayName string `json:"displayName"`
+	} `json:"deletedBy"`
+}
+
+// recycledNameError is returned by azureTarget.CreateRepo when the name is
+// taken by a repository in the recycle bin rather than a live one.
+type recycledNameError struct {
+	Name    string
+	Deleted deletedAzureRepo
+}
+
+func (e *recycledNameError) Error() string {
+	return fmt.Sprintf("%s was deleted on %s by %s and is still in the ADO recycle bin",
+		e.Name, e.Deleted.DeletedDate, orDefault(e.Deleted.DeletedBy.DisplayName, "unknown"))
+}
+
+// listDeletedAzureRepos lists the project's recycle bin.
+func listDeletedAzureRepos(ctx context.Context, org, project, token string) ([]deletedAzureRepo, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/recycleBin/repositories?api-version=7.0", org, url.PathEscape(project))
+	var result struct {
+		Value []deletedAzureRepo `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// purgeDeletedAzureRepo permanently deletes a repository from the recycle
+// bin. This cannot be undone.
+func purgeDeletedAzureRepo(ctx context.Context, org, project, repoID, token string) error {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/recycleBin/repositories/%s?api-version=7.0", org, url.PathEscape(project), repoID)
+	return azureRequest(ctx, "DELETE", apiURL, token, nil, http.StatusNoContent, nil)
+}
+
+// checkRecycleBin explains err, a 409 from repository creation: if name
+// belongs to a recycled repository it returns a *recycledNameError, and nil
+// if the name is not in the recycle bin.
+func (t *azureTarget) checkRecycleBin(ctx context.Context, name string, err error) error {
+	deleted, lerr := listDeletedAzureRepos(ctx, t.org, t.project, t.token)
+	if lerr != nil {
+		return fmt.Errorf("%v (could not check the recycle bin: %v)", err, lerr)
+	}
+	for _, d := range deleted {
+		if strings.EqualFold(d.Name, name) {
+			return &recycledNameError{Name: name, Deleted: d}
+		}
+	}
+	return nil
+}
+
+// resolveRecycledName applies policy to a recycle bin conflict and returns
+// the name to retry creation with.
+func (t *azureTarget) resolveRecycledName(ctx context.Context, conflict *recycledNameError, policy recyclePolicy, confirm func(deletedAzureRepo) bool, logMsg func(string)) (string, error) {
+	switch policy {
+	case recyclePurge:
+		if !confirm(conflict.Deleted) {
+			return "", fmt.Errorf("%v; purge declined", conflict)
+		}
+		target := fmt.Sprintf("%s/%s/%s (%s)", t.org, t.project, conflict.Deleted.Name, conflict.Deleted.ID)
+		if err := purgeDeletedAzureRepo(ctx, t.org, t.project, conflict.Deleted.ID, t.token); err != nil {
+			return "", fmt.Errorf("purging recycled repository: %v", err)
+		}
+		if err := writeAudit("purge-recycled-repo", target, conflict.Error()); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not write audit log: %v", err))
+		}
+		logMsg(fmt.Sprintf("Permanently deleted recycled repository %s, recorded in %s.", target, auditLogPath))
+		return conflict.Name, nil
+	case recycleRename:
+		name, err := t.alternativeName(ctx, conflict.Name)
+		if err != nil {
+			return "", err
+		}
+		logMsg(fmt.Sprintf("%s is taken by a repository in the recycle bin, using %s instead.", conflict.Name, name))
+		return name, nil
+	}
+	return "", conflict
+}
+
+// alternativeName finds a name derived from name that is neither a live
+// nor a recycled repository.
+func (t *azureTarget) alternativeName(ctx context.Context, name string) (string, error) {
+	live, err := listAzureRepos(ctx, t.org, t.project, t.token)
+	if err != nil {
+		return "", err
+	}
+	deleted, err := listDeletedAzureRepos(ctx, t.org, t.project, t.token)
+	if err != nil {
+		return "", err
+	}
+	taken := map[string]bool{}
+	for _, r := range live {
+		taken[strings.ToLower(r.Name)] = true
+	}
+	for _, r := range deleted {
+		taken[strings.ToLower(r.Name)] = true
+	}
+	for i := 1; i <= 10; i++ {
+		candidate := name + "-migrated"
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-migrated-%d", name, i)
+		}
+		if !taken[strings.ToLower(candidate)] {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free alternative name for %s", name)
+}
@@ -0,0 +1,24 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// confirmPurgeDialog returns a confirm function for recyclePurge that asks
+// the user in a dialog, blocking the calling migration goroutine until the
+// user answers.
+func confirmPurgeDialog(w fyne.Window) func(deletedAzureRepo) bool {
+	return func(d deletedAzureRepo) bool {
+		answer := make(chan bool)
+		dialog.ShowConfirm("Purge deleted repository",
+			fmt.Sprintf("%s is in the Azure DevOps recycle bin (deleted %s by %s).\n\nPermanently delete it so the name can be reused? This cannot be undone.",
+				d.Name, d.DeletedDate, orDefault(d.DeletedBy.DisplayName, "unknown")),
+			func(ok bool) { answer <- ok }, w)
+		return <-answer
+	}
+}
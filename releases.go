@@ -0,0 +1,492 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub releases, with the binaries attached to them, are migrated by a
+// content feature, opted into in the content preview, once the repository
+// itself is migrated: the tags came over with the history, but nothing
+// else of a release did. How a release is recreated is chosen per run
+// with releaseMode. Which releases were migrated, and how, is recorded in
+// releaseMapPath, so a release is migrated once per mode however often
+// the preview is applied.
+
+// releaseMode is how a GitHub release is recreated in Azure DevOps.
+type releaseMode int
+
+const (
+	// releasesAsFolder commits the release's notes and assets under
+	// releases/<tag>/ on releasesBranch, and creates the release's tag as
+	// an annotated tag if the target lacks it.
+	releasesAsFolder releaseMode = iota
+	// releasesAsPackages publishes the notes and assets as a universal
+	// package of an Azure Artifacts feed, versioned after the tag. It
+	// needs the Azure CLI with the azure-devops extension: universal
+	// packages are uploaded with its dedup protocol, which has no plain
+	// REST API.
+	releasesAsPackages
+)
+
+// releaseModeNames are the UI labels for each releaseMode, in order.
+var releaseModeNames = []string{
+	"Annotated tags and assets folder",
+	"Universal packages",
+}
+
+// releaseMapPath records the migrated releases, relative to the working
+// directory.
+const releaseMapPath = "releases.json"
+
+// releasesBranch is the target branch releasesAsFolder commits to.
+const releasesBranch = "migration/releases"
+
+var releaseMapMu sync.Mutex
+
+// releaseLink records that a GitHub release was migrated.
+type releaseLink struct {
+	Source  string `json:"source"` // owner/name
+	Tag     string `json:"tag"`
+	Mode    string `json:"mode"`    // one of releaseModeNames
+	Where   string `json:"where"`   // the folder, or the package and version
+	Created string `json:"created"` // when it was migrated
+}
+
+// loadReleaseMap reads the release mapping; no file means none yet.
+func loadReleaseMap() ([]releaseLink, error) {
+	data, err := readFileRecover(releaseMapPath, func(data []byte) error {
+		var links []releaseLink
+		return json.Unmarshal(data, &links)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []releaseLink
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// recordReleaseLink adds link to the release mapping.
+func recordReleaseLink(link releaseLink) error {
+	releaseMapMu.Lock()
+	defer releaseMapMu.Unlock()
+	links, err := loadReleaseMap()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(append(links, link), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(releaseMapPath, append(data, '\n'), 0644)
+}
+
+// migratedReleases returns the tags of source's releases migrated with
+// mode.
+func migratedReleases(source string, mode releaseMode) (map[string]bool, error) {
+	releaseMapMu.Lock()
+	links, err := loadReleaseMap()
+	releaseMapMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	for _, l := range links {
+		if strings.EqualFold(l.Source, source) && l.Mode == releaseModeNames[mode] {
+			done[l.Tag] = true
+		}
+	}
+	return done, nil
+}
+
+// gitHubRelease is the subset of the GitHub release API object the
+// migration uses.
+type gitHubRelease struct {
+	TagName         string `json:"tag_name"`
+	TargetCommitish string `json:"target_commitish"`
+	Name            string `json:"name"`
+	Body            string `json:"body"`
+	Draft           bool   `json:"draft"`
+	Prerelease      bool   `json:"prerelease"`
+	HTMLURL         string `json:"html_url"`
+	PublishedAt     string `json:"published_at"`
+	Author          struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Assets []gitHubAsset `json:"assets"`
+}
+
+// gitHubAsset is a file attached to a release.
+type gitHubAsset struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"` // the API's, which serves the file itself
+}
+
+// listGitHubReleases lists repo's published releases, oldest first.
+// Drafts are left out: they have no tag yet.
+func listGitHubReleases(ctx context.Context, repo, token string) ([]gitHubRelease, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=100", repo)
+	var releases []gitHubRelease
+	for apiURL != "" {
+		var page []gitHubRelease
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return releases, err
+		}
+		for _, r := range page {
+			if !r.Draft {
+				releases = append(releases, r)
+			}
+		}
+		apiURL = next
+	}
+	// GitHub lists the newest first.
+	for i, j := 0, len(releases)-1; i < j; i, j = i+1, j-1 {
+		releases[i], releases[j] = releases[j], releases[i]
+	}
+	return releases, nil
+}
+
+// downloadReleaseAsset downloads asset to path. GitHub redirects to
+// storage that refuses the token, which the client drops on the redirect
+// to another host.
+func downloadReleaseAsset(ctx context.Context, asset gitHubAsset, token, path string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/octet-stream")
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError("GitHub", resp)
+		apiErr.RateLimitReset = gitHubRateLimitReset(resp)
+		return apiErr
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// releaseNotes is the RELEASE.md kept with a release's assets.
+func releaseNotes(repo string, release gitHubRelease) string {
+	title := orDefault(release.Name, release.TagName)
+	kind := "release"
+	if release.Prerelease {
+		kind = "pre-release"
+	}
+	return fmt.Sprintf("# %s\n\nMigrated from the GitHub %s [%s](%s) of %s, published by @%s on %s.\n\n%s\n",
+		title, kind, release.TagName, release.HTMLURL, repo, release.Author.Login, release.PublishedAt, release.Body)
+}
+
+// fetchRelease writes release's notes and downloads its assets into dir.
+func fetchRelease(ctx context.Context, repo string, release gitHubRelease, dir, githubToken string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "RELEASE.md"), []byte(releaseNotes(repo, release)), 0644); err != nil {
+		return err
+	}
+	for _, a := range release.Assets {
+		if err := downloadReleaseAsset(ctx, a, githubToken, filepath.Join(dir, filepath.Base(a.Name))); err != nil {
+			return fmt.Errorf("downloading %s: %v", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// releasePathChars matches what a tag may contain that is not kept in the
+// name of its release's folder.
+var releasePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// releaseFolder is the folder of release tag on releasesBranch.
+func releaseFolder(tag string) string {
+	name := releasePathChars.ReplaceAllString(tag, "-")
+	if strings.Trim(name, ".") == "" {
+		name = "-" + name
+	}
+	return "releases/" + name
+}
+
+// azureTagExists reports whether repo has tag.
+func azureTagExists(ctx context.Context, t *azureTarget, repo, tag string) (bool, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/refs?filter=%s&api-version=7.0", t.org, url.PathEscape(t.project), url.PathEscape(repo), url.QueryEscape("tags/"+tag))
+	var result struct {
+		Value []struct {
+			Name string `json:"name"`
+		} `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, t.token, nil, http.StatusOK, &result); err != nil {
+		return false, err
+	}
+	// The filter matches by prefix.
+	for _, r := range result.Value {
+		if r.Name == "refs/tags/"+tag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// gitHubCommitSHA returns the commit ref names in repo.
+func gitHubCommitSHA(ctx context.Context, repo, ref, token string) (string, error) {
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	_, err := gitHubGet(ctx, fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", repo, url.PathEscape(ref)), token, &commit)
+	return commit.SHA, err
+}
+
+// createReleaseTag creates release's tag in the target repository as an
+// annotated tag, with the release's title and notes as its message, on
+// the commit the tag names on GitHub, or the release's target if GitHub
+// no longer has the tag either.
+func createReleaseTag(ctx context.Context, t *azureTarget, repo migratedRepo, release gitHubRelease, githubToken string) error {
+	sha, err := gitHubCommitSHA(ctx, repo.Source, release.TagName, githubToken)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+		sha, err = gitHubCommitSHA(ctx, repo.Source, release.TargetCommitish, githubToken)
+	}
+	if err != nil {
+		return fmt.Errorf("resolving %s: %v", release.TagName, err)
+	}
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/annotatedtags?api-version=7.0", t.org, url.PathEscape(t.project), url.PathEscape(repo.Target))
+	payload := map[string]interface{}{
+		"name":         release.TagName,
+		"taggedObject": map[string]string{"objectId": sha},
+		"message":      orDefault(release.Name, release.TagName) + "\n\n" + release.Body,
+	}
+	return azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusCreated, nil)
+}
+
+// commitReleaseFolder commits release's notes and assets to its folder on
+// releasesBranch of the target repository, which is created if need be,
+// and records the release. A scratch clone of only the branch's tip is
+// used: the assets are binaries the pushes API would have to carry in
+// one request body.
+func commitReleaseFolder(ctx context.Context, t *azureTarget, repo migratedRepo, release gitHubRelease, githubToken string) error {
+	target, err := getAzureRepo(ctx, t.org, t.project, repo.Target, t.token)
+	if err != nil {
+		return fmt.Errorf("looking up Azure repo: %v", err)
+	}
+	tip, err := azureBranchSHA(ctx, t, repo.Target, releasesBranch)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %v", releasesBranch, err)
+	}
+	work, err := os.MkdirTemp("", "gitui-release-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	if out, err := runGit(ctx, nil, "init", "--quiet", work); err != nil {
+		return fmt.Errorf("git init: %v, output: %s", err, out)
+	}
+	pushURL := authRemoteURL(ctx, target.RemoteURL, t.token)
+	if tip != "" {
+		refspec := "+" + branchRef(releasesBranch) + ":" + branchRef(releasesBranch)
+		if out, err := runGitTransfer(ctx, gitHost(pushURL), nil, "-C", work, "fetch", "--depth", "1", "--no-tags", pushURL, refspec); err != nil {
+			return fmt.Errorf("fetching %s: %v, output: %s", releasesBranch, err, strings.ReplaceAll(lastLine(out), t.token, "***"))
+		}
+		if out, err := runGit(ctx, nil, "-C", work, "checkout", "--quiet", releasesBranch); err != nil {
+			return fmt.Errorf("git checkout: %v, output: %s", err, lastLine(out))
+		}
+	} else if out, err := runGit(ctx, nil, "-C", work, "symbolic-ref", "HEAD", branchRef(releasesBranch)); err != nil {
+		return fmt.Errorf("git symbolic-ref: %v, output: %s", err, lastLine(out))
+	}
+
+	folder := releaseFolder(release.TagName)
+	dir := filepath.Join(work, filepath.FromSlash(folder))
+	os.RemoveAll(dir)
+	if err := fetchRelease(ctx, repo.Source, release, dir, githubToken); err != nil {
+		return err
+	}
+	if out, err := runGit(ctx, nil, "-C", work, "add", "--all", "--", folder); err != nil {
+		return fmt.Errorf("git add: %v, output: %s", err, lastLine(out))
+	}
+	args := append([]string{"-C", work}, (*commitSigner)(nil).identityArgs()...)
+	args = append(args, "commit", "--quiet", "--allow-empty", "-m", fmt.Sprintf("Add GitHub release %s", release.TagName))
+	if out, err := runGit(ctx, nil, args...); err != nil {
+		return fmt.Errorf("git commit: %v, output: %s", err, lastLine(out))
+	}
+	if out, err := runGitTransfer(ctx, gitHost(pushURL), nil, "-C", work, "push", pushURL, "HEAD:"+branchRef(releasesBranch)); err != nil {
+		return fmt.Errorf("pushing %s: %v, output: %s", releasesBranch, err, strings.ReplaceAll(lastLine(out), t.token, "***"))
+	}
+	return recordReleaseLink(releaseLink{Source: repo.Source, Tag: release.TagName, Mode: releaseModeNames[releasesAsFolder],
+		Where: releasesBranch + ":/" + folder, Created: fileTimestamp(time.Now())})
+}
+
+// packageNameChars matches what a universal package's name may not
+// contain.
+var packageNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// releasePackageName is the universal package repo's releases are
+// published as: its name, lower-cased as package names must be.
+func releasePackageName(repo string) string {
+	return strings.Trim(packageNameChars.ReplaceAllString(strings.ToLower(repo), "-"), "-._")
+}
+
+// semVer matches the SemVer 2.0 versions universal packages require.
+var semVer = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// versionChars matches what a SemVer pre-release identifier may not
+// contain.
+var versionChars = regexp.MustCompile(`[^0-9A-Za-z-]+`)
+
+// releaseVersion is the package version of release tag: the tag itself,
+// less a leading "v", if that is a SemVer version, or else a pre-release
+// of 0.0.0 named after the tag.
+func releaseVersion(tag string) string {
+	if v := strings.TrimPrefix(strings.TrimPrefix(tag, "v"), "V"); semVer.MatchString(v) {
+		return v
+	}
+	return "0.0.0-tag-" + strings.Trim(versionChars.ReplaceAllString(tag, "-"), "-")
+}
+
+// publishReleasePackage publishes release's notes and assets to feed as a
+// version of the repository's universal package, with the Azure CLI, and
+// records the release.
+func publishReleasePackage(ctx context.Context, t *azureTarget, repo migratedRepo, release gitHubRelease, feed, githubToken string) error {
+	work, err := os.MkdirTemp("", "gitui-release-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	if err := fetchRelease(ctx, repo.Source, release, work, githubToken); err != nil {
+		return err
+	}
+	name, version := releasePackageName(repo.Target), releaseVersion(release.TagName)
+	cmd := exec.Command("az", "artifacts", "universal", "publish",
+		"--organization", t.org, "--project", t.project, "--scope", "project",
+		"--feed", feed, "--name", name, "--version", version,
+		"--description", fmt.Sprintf("GitHub release %s of %s", release.TagName, repo.Source),
+		"--path", work)
+	// The CLI reads the PAT from the environment, keeping it off the
+	// command line. Signed in with Microsoft, it uses its own az login.
+	cmd.Env = os.Environ()
+	if t.token != entraToken {
+		cmd.Env = append(cmd.Env, "AZURE_DEVOPS_EXT_PAT="+t.token)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("az artifacts universal publish: %v, output: %s", err, lastLine(string(out)))
+	}
+	return recordReleaseLink(releaseLink{Source: repo.Source, Tag: release.TagName, Mode: releaseModeNames[releasesAsPackages],
+		Where: fmt.Sprintf("%s/%s@%s", feed, name, version), Created: fileTimestamp(time.Now())})
+}
+
+// releaseAssetsSize returns the total size of release's assets.
+func releaseAssetsSize(release gitHubRelease) int64 {
+	var size int64
+	for _, a := range release.Assets {
+		size += a.Size
+	}
+	return size
+}
+
+// releasesFeature previews migrating each repository's GitHub releases not
+// yet migrated with mode; feed is the Azure Artifacts feed packages are
+// published to.
+func releasesFeature(mode releaseMode, feed string) *contentFeature {
+	return &contentFeature{
+		Name:  "GitHub releases",
+		OptIn: true,
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			releases, err := listGitHubReleases(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("listing GitHub releases: %v", err)
+			}
+			done, err := migratedReleases(repo.Source, mode)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %v", releaseMapPath, err)
+			}
+			var todo []gitHubRelease
+			for _, r := range releases {
+				if !done[r.TagName] {
+					todo = append(todo, r)
+				}
+			}
+			if len(todo) == 0 {
+				return nil, nil
+			}
+			if mode == releasesAsPackages {
+				if feed == "" {
+					return nil, errors.New("no Azure Artifacts feed to publish universal packages to")
+				}
+				if _, err := exec.LookPath("az"); err != nil {
+					return nil, errors.New("publishing universal packages needs the Azure CLI (az) with the azure-devops extension")
+				}
+			}
+
+			var ops []*contentOp
+			for _, release := range todo {
+				release := release
+				detail := fmt.Sprintf("%d asset(s), %s", len(release.Assets), formatBytes(releaseAssetsSize(release)))
+				if release.Prerelease {
+					detail = "pre-release, " + detail
+				}
+				if mode == releasesAsPackages {
+					ops = append(ops, &contentOp{
+						Change: "+",
+						What:   fmt.Sprintf("package %s %s for release %s", releasePackageName(repo.Target), releaseVersion(release.TagName), release.TagName),
+						Detail: detail + ", feed " + feed,
+						apply: func(ctx context.Context) error {
+							return publishReleasePackage(ctx, t, repo, release, feed, githubToken)
+						},
+					})
+					continue
+				}
+				exists, err := azureTagExists(ctx, t, repo.Target, release.TagName)
+				if err != nil {
+					return nil, fmt.Errorf("looking up tag %s: %v", release.TagName, err)
+				}
+				if !exists {
+					ops = append(ops, &contentOp{
+						Change: "+",
+						What:   "annotated tag " + release.TagName,
+						Detail: "with the release's notes",
+						apply: func(ctx context.Context) error {
+							return createReleaseTag(ctx, t, repo, release, githubToken)
+						},
+					})
+				}
+				ops = append(ops, &contentOp{
+					Change: "+",
+					What:   fmt.Sprintf("%s/ on %s", releaseFolder(release.TagName), releasesBranch),
+					Detail: detail,
+					apply: func(ctx context.Context) error {
+						return commitReleaseFolder(ctx, t, repo, release, githubToken)
+					},
+				})
+			}
+			return ops, nil
+		},
+	}
+}
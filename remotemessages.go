@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Azure DevOps sends notices in the push sideband, as "remote: " lines
+// among git's own progress: a repository or pack approaching a size limit,
+// quota notices, oversized files. They are picked out of the push output
+// so a repository close to a hard limit is flagged before a later sync
+// hits it.
+
+// remoteNoise are the prefixes of server messages that are only progress.
+var remoteNoise = []string{
+	"analyzing objects", "storing packfile", "storing index", "found ",
+	"counting objects", "compressing objects", "enumerating objects",
+	"resolving deltas", "total ", "checking connectivity", "processing changes",
+	"azure repos",
+}
+
+// remoteNoiseArt matches lines without a word in them, such as the rows of
+// the banner Azure Repos prints.
+var remoteNoiseArt = regexp.MustCompile(`^[^A-Za-z]*([A-Za-z]{1,2}[^A-Za-z]+)*[A-Za-z]{0,2}$`)
+
+// remoteWarningPatterns classify the server messages that are warnings,
+// the first match winning.
+var remoteWarningPatterns = []struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}{
+	{"size limit", regexp.MustCompile(`(?i)\b(approach|near|exceed|reach|over)\w*\b.*\blimit|\blimit\b.*\b(approach|near|exceed|reach)`)},
+	{"quota", regexp.MustCompile(`(?i)\bquota\b`)},
+	{"large file", regexp.MustCompile(`(?i)\b(large|larger than|exceeds?)\b.*\b(file|blob)s?\b`)},
+	{"notice", regexp.MustCompile(`(?i)^(warning|warn|notice)\b|\bTF\d{5,6}\b`)},
+}
+
+// classifyRemoteMessage returns the kind of warning a server message is,
+// or "" if it is none.
+func classifyRemoteMessage(msg string) string {
+	for _, p := range remoteWarningPatterns {
+		if p.Pattern.MatchString(msg) {
+			return p.Kind
+		}
+	}
+	return ""
+}
+
+// remoteMessage returns the text of a "remote: " line, and false for any
+// other line and for progress.
+func remoteMessage(line string) (string, bool) {
+	msg, ok := strings.CutPrefix(strings.TrimSpace(line), "remote:")
+	if !ok {
+		return "", false
+	}
+	msg = strings.TrimSpace(msg)
+	if remoteNoiseArt.MatchString(msg) {
+		return "", false
+	}
+	lower := strings.ToLower(msg)
+	for _, prefix := range remoteNoise {
+		if strings.HasPrefix(lower, prefix) {
+			return "", false
+		}
+	}
+	return msg, true
+}
+
+// serverMessages is the git output stream of a repository's pushes. It
+// passes the output on to stream and keeps the server messages in it,
+// each once: the warnings, as "kind: message", and the rest.
+type serverMessages struct {
+	stream io.Writer
+	lines  *lineWriter
+
+	mu       sync.Mutex
+	seen     map[string]bool
+	warnings []string
+	other    []string
+}
+
+// newServerMessages collects the server messages of the pushes streaming
+// to stream, which may be nil.
+func newServerMessages(stream io.Writer) *serverMessages {
+	s := &serverMessages{stream: stream, seen: map[string]bool{}}
+	s.lines = &lineWriter{fn: s.add}
+	return s
+}
+
+func (s *serverMessages) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	s.lines.Write(p)
+	s.mu.Unlock()
+	if s.stream == nil {
+		return len(p), nil
+	}
+	return s.stream.Write(p)
+}
+
+// add keeps line if it is a server message; s.mu is held.
+func (s *serverMessages) add(line string) {
+	msg, ok := remoteMessage(line)
+	if !ok || s.seen[msg] {
+		return
+	}
+	s.seen[msg] = true
+	if kind := classifyRemoteMessage(msg); kind != "" {
+		s.warnings = append(s.warnings, kind+": "+msg)
+	} else {
+		s.other = append(s.other, msg)
+	}
+}
+
+// Watch passes the pushes on to stream to watch, if it watches them.
+func (s *serverMessages) Watch(pid int, dir string) (stop func()) {
+	if w, ok := s.stream.(gitWatcher); ok {
+		return w.Watch(pid, dir)
+	}
+	return func() {}
+}
+
+// Messages returns the warnings and the other server messages, in the
+// order they came.
+func (s *serverMessages) Messages() (warnings, other []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.warnings...), append([]string(nil), s.other...)
+}
+
+// writeSection writes the messages to stream, the repository's detail
+// log, as its "server messages" section.
+func (s *serverMessages) writeSection() {
+	warnings, other := s.Messages()
+	if s.stream == nil || len(warnings)+len(other) == 0 {
+		return
+	}
+	fmt.Fprintf(s.stream, "\n--- server messages ---\n")
+	for _, w := range warnings {
+		fmt.Fprintf(s.stream, "warning: %s\n", w)
+	}
+	for _, m := range other {
+		fmt.Fprintf(s.stream, "%s\n", m)
+	}
+}
+
+// recordServerMessages records the server messages of repo's pushes in
+// result, logging the warnings, and writes them to its detail log.
+func recordServerMessages(repo string, result *repoReport, s *serverMessages, logMsg func(string)) {
+	warnings, other := s.Messages()
+	result.ServerWarnings, result.ServerMessages = warnings, other
+	for _, w := range warnings {
+		logMsg(fmt.Sprintf("Warning: the server warned while pushing %s: %s", repo, w))
+	}
+	s.writeSection()
+}
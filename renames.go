@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Migrated repositories are renamed in bulk from a mapping CSV of current
+// name to new name, so the names the tool knows (the migration state, and
+// the mapping export made from it) stay in step with the project; renaming
+// them in the web UI leaves both stale.
+
+// repoMappingPath is the mapping export, source repository to target
+// repository, written from the migration state whenever a rename changes
+// it.
+const repoMappingPath = "repo-mapping.csv"
+
+// renameRow is a line of the mapping CSV.
+type renameRow struct {
+	Line    int
+	Current string
+	New     string
+}
+
+// parseRenameCSV parses a mapping CSV: current name, new name. Lines
+// starting with # and a header line are skipped.
+func parseRenameCSV(data []byte) ([]renameRow, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var rows []renameRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) != 2 {
+			return nil, fmt.Errorf("line %d: want current name, new name; got %d field(s)", line, len(record))
+		}
+		row := renameRow{Line: line, Current: strings.TrimSpace(record[0]), New: strings.TrimSpace(record[1])}
+		if len(rows) == 0 && isRenameHeader(row.Current) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no renames in the mapping")
+	}
+	return rows, nil
+}
+
+// isRenameHeader reports whether the first cell of a line is a header's.
+func isRenameHeader(cell string) bool {
+	switch strings.ToLower(cell) {
+	case "current", "current name", "old", "old name", "from":
+		return true
+	}
+	return false
+}
+
+// renameEntry is what a batch does to one repository.
+type renameEntry struct {
+	Row    renameRow
+	Repo   *azureRepo // the live repository, nil if not found
+	Source string     // the GitHub repository it was migrated from, if known
+	// Skip is why the repository is not renamed, "" if it is.
+	Skip string
+	// Warning marks a skip that is no error in the mapping, open pull
+	// requests for one.
+	Warning bool
+
+	Renamed string // when it was renamed
+	Error   string // why the rename failed
+}
+
+// renameBatch is a previewed batch of renames in a project.
+type renameBatch struct {
+	Created time.Time
+	Target  string
+	Entries []*renameEntry
+}
+
+// Renames returns how many repositories the batch renames.
+func (b *renameBatch) Renames() int {
+	n := 0
+	for _, e := range b.Entries {
+		if e.Skip == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// previewRenames checks rows against the live project and the migration
+// state. A rename is skipped if its repository does not exist, its new
+// name is invalid, taken by another repository or by another rename, or if
+// it has active pull requests, which keep the old name in their links and
+// in reviewers' clones.
+func previewRenames(ctx context.Context, t *azureTarget, rows []renameRow) (*renameBatch, error) {
+	live, err := listAzureRepos(ctx, t.org, t.project, t.token)
+	if err != nil {
+		return nil, fmt.Errorf("listing the repositories of %s: %v", t.project, err)
+	}
+	byName := map[string]*azureRepo{}
+	for i := range live {
+		byName[strings.ToLower(live[i].Name)] = &live[i]
+	}
+	state, err := loadMigrationState()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", migrationStatePath, err)
+	}
+	sources := map[string]string{}
+	for _, s := range state {
+		if s.Target != "" {
+			sources[strings.ToLower(s.Target)] = s.Source
+		}
+	}
+
+	batch := &renameBatch{Created: time.Now(), Target: fmt.Sprintf("%s/%s", t.org, t.project)}
+	seenCurrent := map[string]int{}
+	seenNew := map[string]int{}
+	for _, row := range rows {
+		e := &renameEntry{Row: row, Repo: byName[strings.ToLower(row.Current)], Source: sources[strings.ToLower(row.Current)]}
+		batch.Entries = append(batch.Entries, e)
+		if line, ok := seenCurrent[strings.ToLower(row.Current)]; ok {
+			e.Skip = fmt.Sprintf("renamed again on line %d", line)
+			continue
+		}
+		seenCurrent[strings.ToLower(row.Current)] = row.Line
+		if line, ok := seenNew[strings.ToLower(row.New)]; ok {
+			e.Skip = fmt.Sprintf("line %d renames another repository to %s", line, row.New)
+			continue
+		}
+		seenNew[strings.ToLower(row.New)] = row.Line
+		if problem := validateADOName(row.New); problem != "" {
+			e.Skip = "new name " + problem
+			continue
+		}
+		if e.Repo == nil {
+			e.Skip = "no such repository in the project"
+			continue
+		}
+		if row.New == e.Repo.Name {
+			e.Skip = "already has the new name"
+			continue
+		}
+		if other := byName[strings.ToLower(row.New)]; other != nil && other.ID != e.Repo.ID {
+			e.Skip = fmt.Sprintf("%s already exists; rename it in an earlier batch first", other.Name)
+			continue
+		}
+		n, err := activePullRequests(ctx, t, e.Repo.ID)
+		switch {
+		case err != nil:
+			e.Skip, e.Warning = fmt.Sprintf("could not check for active pull requests: %v", err), true
+		case n > 0:
+			e.Skip, e.Warning = fmt.Sprintf("%d active pull request(s) reference it; complete or abandon them first", n), true
+		}
+	}
+	return batch, nil
+}
+
+// activePullRequests counts the active pull requests of a repository, up to
+// 100.
+func activePullRequests(ctx context.Context, t *azureTarget, repoID string) (int, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s/pullrequests?searchCriteria.status=active&$top=100&api-version=7.0", t.org, url.PathEscape(t.project), repoID)
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, t.token, nil, http.StatusOK, &result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+// renameAzureRepo renames a repository, by ID.
+func renameAzureRepo(ctx context.Context, t *azureTarget, repoID, name string) error {
+	apiURL := fmt.Sprintf("%s/%s/_apis/git/repositories/%s?api-version=7.0", t.org, url.PathEscape(t.project), repoID)
+	return azureRequest(ctx, "PATCH", apiURL, t.token, map[string]string{"name": name}, http.StatusOK, nil)
+}
+
+// applyRenames carries out the renames of batch. Each repository is looked
+// up again first and left alone if its name changed since the preview.
+// Every rename is audited and recorded in the migration state, and the
+// mapping export rewritten, as it happens, so a batch that stops halfway
+// leaves both current. It returns how many were renamed and how many
+// failed.
+func applyRenames(ctx context.Context, t *azureTarget, batch *renameBatch, logMsg func(string)) (renamed, failed int) {
+	for _, e := range batch.Entries {
+		if e.Skip != "" {
+			continue
+		}
+		old, name := e.Repo.Name, e.Row.New
+		if current, err := getAzureRepo(ctx, t.org, t.project, e.Repo.ID, t.token); err != nil {
+			e.Error = fmt.Sprintf("looking it up: %v", err)
+		} else if current.Name != old {
+			e.Error = fmt.Sprintf("renamed to %s since the preview", current.Name)
+		} else if err := renameAzureRepo(ctx, t, e.Repo.ID, name); err != nil {
+			e.Error = err.Error()
+		}
+		if e.Error != "" {
+			failed++
+			logMsg(fmt.Sprintf("Error renaming %s to %s: %s", old, name, e.Error))
+			continue
+		}
+		renamed++
+		e.Renamed = fileTimestamp(time.Now())
+		logMsg(fmt.Sprintf("Renamed %s to %s at %s.", old, name, e.Renamed))
+		if err := writeAudit("rename-repo", batch.Target+"/"+name, "renamed from "+old); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not write the audit log: %v", err))
+		}
+		if err := recordRename(old, name); err != nil {
+			logMsg(fmt.Sprintf("Warning: could not record the rename of %s in %s: %v", old, migrationStatePath, err))
+		}
+	}
+	return renamed, failed
+}
+
+// recordRename renames the target old to name in the migration state, and
+// rewrites the mapping export from it.
+func recordRename(old, name string) error {
+	migrationStateMu.Lock()
+	defer migrationStateMu.Unlock()
+	state, err := loadMigrationState()
+	if err != nil {
+		return err
+	}
+	for _, s := range state {
+		if strings.EqualFold(s.Target, old) {
+			s.Target = name
+			s.Updated = fileTimestamp(time.Now())
+		}
+	}
+	if err := state.save(); err != nil {
+		return err
+	}
+	return writeRepoMapping(state)
+}
+
+// writeRepoMapping writes the mapping export from state.
+func writeRepoMapping(state migrationState) error {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"source", "target"})
+	for _, s := range state.Sorted() {
+		if s.Target != "" {
+			w.Write([]string{s.Source, s.Target})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return writeFileAtomic(repoMappingPath, b.Bytes(), 0644)
+}
+
+// Text formats the batch for the preview, and once applied, the outcome.
+func (b *renameBatch) Text() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "Renames in %s, previewed %s: %d of %d line(s) rename a repository.\n\n",
+		b.Target, fileTimestamp(b.Created), b.Renames(), len(b.Entries))
+	for _, e := range b.Entries {
+		from := e.Row.Current
+		if e.Source != "" {
+			from += " (from " + e.Source + ")"
+		}
+		switch {
+		case e.Renamed != "":
+			fmt.Fprintf(&out, "renamed   %s -> %s at %s\n", from, e.Row.New, e.Renamed)
+		case e.Error != "":
+			fmt.Fprintf(&out, "failed    %s -> %s: %s\n", from, e.Row.New, e.Error)
+		case e.Skip != "" && e.Warning:
+			fmt.Fprintf(&out, "warning   %s -> %s skipped: %s\n", from, e.Row.New, e.Skip)
+		case e.Skip != "":
+			fmt.Fprintf(&out, "skipped   %s -> %s (line %d): %s\n", from, e.Row.New, e.Row.Line, e.Skip)
+		default:
+			fmt.Fprintf(&out, "rename    %s -> %s\n", from, e.Row.New)
+		}
+	}
+	return out.String()
+}
+
+// save writes the batch, old and new names with the rename times, as CSV
+// to reportsDir and returns its path.
+func (b *renameBatch) save() (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"line", "source", "old_name", "new_name", "outcome", "renamed_at", "detail"})
+	for _, e := range b.Entries {
+		outcome, detail := "pending", ""
+		switch {
+		case e.Renamed != "":
+			outcome = "renamed"
+		case e.Error != "":
+			outcome, detail = "failed", e.Error
+		case e.Skip != "":
+			outcome, detail = "skipped", e.Skip
+		}
+		w.Write([]string{fmt.Sprint(e.Row.Line), e.Source, e.Row.Current, e.Row.New, outcome, e.Renamed, detail})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(reportsDir, b.Created.UTC().Format("20060102T150405Z")+"-RENAMES.csv")
+	return path, writeFileAtomic(path, buf.Bytes(), 0644)
+}
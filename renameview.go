@@ -0,0 +1,112 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showRenames opens the batch rename: load a mapping CSV of current name to
+// new name, preview the renames against t's project, and apply them. The
+// preview and the outcome are saved next to the run reports.
+func showRenames(w fyne.Window, t *azureTarget, logMsg func(string)) {
+	// Renames started run to the end even if the dialog is closed, so the
+	// state file and mapping keep up with the project.
+	ctx := context.Background()
+	output := widget.NewMultiLineEntry()
+	output.TextStyle = fyne.TextStyle{Monospace: true}
+	output.Wrapping = fyne.TextWrapOff
+	output.SetText("Load a mapping CSV: one line per repository, current name, new name. Nothing is renamed until the preview is applied.")
+
+	var batch *renameBatch
+	var loadBtn, applyBtn *widget.Button
+	show := func(b *renameBatch) {
+		batch = b
+		if path, err := b.save(); err != nil {
+			logMsg(fmt.Sprintf("Error saving the rename preview: %v", err))
+		} else {
+			logMsg(fmt.Sprintf("Rename preview saved to %s.", path))
+		}
+		output.SetText(b.Text())
+		if b.Renames() > 0 && !isReadOnly() {
+			applyBtn.Enable()
+		} else {
+			applyBtn.Disable()
+		}
+	}
+	loadBtn = widget.NewButton("Load Mapping CSV...", func() {
+		dialog.ShowFileOpen(func(rc fyne.URIReadCloser, err error) {
+			if err != nil || rc == nil {
+				return
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			rows, err := parseRenameCSV(data)
+			if err != nil {
+				logMsg(fmt.Sprintf("Error reading the mapping %s: %v", rc.URI().Name(), err))
+				dialog.ShowError(err, w)
+				return
+			}
+			loadBtn.Disable()
+			applyBtn.Disable()
+			output.SetText(fmt.Sprintf("Previewing %d rename(s) against %s...", len(rows), t.project))
+			go func() {
+				defer loadBtn.Enable()
+				b, err := previewRenames(ctx, t, rows)
+				if err != nil {
+					logMsg(fmt.Sprintf("Error: %v", err))
+					output.SetText(err.Error())
+					return
+				}
+				show(b)
+			}()
+		}, w)
+	})
+	applyBtn = widget.NewButton("Apply", func() {
+		if batch == nil {
+			return
+		}
+		b := batch
+		dialog.ShowConfirm("Rename repositories",
+			fmt.Sprintf("Rename %d repositories in %s? Clones and links using the old names stop working.", b.Renames(), b.Target),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				loadBtn.Disable()
+				applyBtn.Disable()
+				go func() {
+					defer loadBtn.Enable()
+					renamed, failed := applyRenames(ctx, t, b, logMsg)
+					logMsg(fmt.Sprintf("Renamed %d repositories, %d failed.", renamed, failed))
+					if renamed > 0 {
+						logMsg(fmt.Sprintf("%s and %s have the new names.", migrationStatePath, repoMappingPath))
+					}
+					if path, err := b.save(); err != nil {
+						logMsg(fmt.Sprintf("Error saving the rename report: %v", err))
+					} else {
+						logMsg(fmt.Sprintf("Rename report saved to %s.", path))
+					}
+					batch = nil
+					output.SetText(b.Text())
+				}()
+			}, w)
+	})
+	applyBtn.Disable()
+
+	content := container.NewBorder(nil, container.NewHBox(loadBtn, applyBtn), nil, nil, output)
+	d := dialog.NewCustom("Rename repositories", "Close", content, w)
+	d.Resize(fyne.NewSize(860, 560))
+	d.Show()
+}
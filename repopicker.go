@@ -0,0 +1,275 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// repoPicker is the checklist of source repositories a run migrates.
+// Fetching fills it; nothing is checked until the user checks it, so a
+// token that sees hundreds of repositories does not migrate them all.
+// Repositories on hold are listed greyed out with the reason and cannot
+// be checked. Operator notes are shown after the name, and the filter
+// searches them too.
+type repoPicker struct {
+	fetch  func(onPage func([]string)) ([]string, error)
+	logMsg func(string)
+	// OnChanged is called with the number of checked repositories.
+	OnChanged func(checked int)
+
+	mu      sync.Mutex
+	all     []string
+	checked map[string]bool
+	shown   []string // all, narrowed by the filter
+	holds   repoHolds
+	notes   map[string]string // by lower-case source
+	listing bool              // a fetch is listing; all is what it listed so far
+
+	filterEntry *widget.Entry
+	count       *widget.Label
+	list        *widget.List
+	fetchBtn    *widget.Button
+}
+
+// newRepoPicker creates the picker; fetch lists the source repositories,
+// calling onPage with those listed so far as it goes.
+func newRepoPicker(fetch func(onPage func([]string)) ([]string, error), logMsg func(string)) *repoPicker {
+	p := &repoPicker{fetch: fetch, logMsg: logMsg, checked: map[string]bool{}}
+	p.count = widget.NewLabel("No repositories fetched.")
+	p.filterEntry = widget.NewEntry()
+	p.filterEntry.SetPlaceHolder("Filter repositories and notes")
+	p.filterEntry.OnChanged = func(string) { p.refresh() }
+	p.list = widget.NewList(
+		func() int {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return len(p.shown)
+		},
+		func() fyne.CanvasObject { return widget.NewCheck("", nil) },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			p.mu.Lock()
+			repo := p.shown[id]
+			checked := p.checked[repo]
+			hold := p.holds.Get(repo)
+			note := p.notes[strings.ToLower(repo)]
+			p.mu.Unlock()
+			check := o.(*widget.Check)
+			check.OnChanged = nil // SetChecked must not count as a click
+			check.Text = repo
+			if note != "" {
+				check.Text += " — note: " + note
+			}
+			check.SetChecked(checked)
+			if hold != nil {
+				check.Text = repo + " — " + hold.String()
+				check.Disable()
+				return
+			}
+			check.Enable()
+			check.OnChanged = func(on bool) { p.setChecked([]string{repo}, on) }
+		},
+	)
+	p.fetchBtn = widget.NewButton("Fetch Repos", p.Fetch)
+	return p
+}
+
+// Fetch lists the source repositories into the picker, keeping the checks
+// of those listed again. Pages are shown as they arrive, so repositories
+// can be checked while a large listing goes on. If the listing fails, what
+// it listed so far stays; if it lists nothing, the picker is left as it
+// was.
+func (p *repoPicker) Fetch() {
+	p.fetchBtn.Disable()
+	go func() {
+		defer p.fetchBtn.Enable()
+		p.mu.Lock()
+		p.listing = true
+		p.mu.Unlock()
+		repos, err := p.fetch(func(repos []string) {
+			if len(repos) == 0 {
+				return
+			}
+			p.mu.Lock()
+			p.all = repos
+			p.mu.Unlock()
+			p.refresh()
+		})
+		p.mu.Lock()
+		p.listing = false
+		p.mu.Unlock()
+		if err != nil {
+			p.logMsg(fmt.Sprintf("Error fetching repositories: %v", err))
+			p.refresh()
+			return
+		}
+		if len(repos) == 0 {
+			p.logMsg("No repositories found; the previous list is kept.")
+			p.refresh()
+			return
+		}
+		p.mu.Lock()
+		listed := map[string]bool{}
+		for _, r := range repos {
+			listed[r] = true
+		}
+		for r := range p.checked {
+			if !listed[r] {
+				delete(p.checked, r)
+			}
+		}
+		p.all = repos
+		p.mu.Unlock()
+		p.logMsg(fmt.Sprintf("Found %d repositories.", len(repos)))
+		p.ReloadHolds()
+		p.ReloadNotes()
+	}()
+}
+
+// ReloadHolds reads the holds again and unchecks the repositories on hold.
+func (p *repoPicker) ReloadHolds() {
+	holds, err := loadHolds()
+	if err != nil {
+		p.logMsg(fmt.Sprintf("Warning: could not read the holds: %v", err))
+		holds = repoHolds{}
+	}
+	p.mu.Lock()
+	p.holds = holds
+	for r := range p.checked {
+		if holds.Get(r) != nil {
+			delete(p.checked, r)
+		}
+	}
+	p.mu.Unlock()
+	p.refresh()
+}
+
+// ReloadNotes reads the operator notes again.
+func (p *repoPicker) ReloadNotes() {
+	notes, err := repoNotes()
+	if err != nil {
+		p.logMsg(fmt.Sprintf("Warning: could not read the notes: %v", err))
+	}
+	p.mu.Lock()
+	p.notes = notes
+	p.mu.Unlock()
+	p.refresh()
+}
+
+// Selected returns the checked repositories, in listing order.
+func (p *repoPicker) Selected() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var repos []string
+	for _, r := range p.all {
+		if p.checked[r] {
+			repos = append(repos, r)
+		}
+	}
+	return repos
+}
+
+// Listed returns every fetched repository, in listing order.
+func (p *repoPicker) Listed() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.all...)
+}
+
+// setChecked checks or unchecks repos.
+func (p *repoPicker) setChecked(repos []string, on bool) {
+	p.mu.Lock()
+	for _, r := range repos {
+		if on && p.holds.Get(r) == nil {
+			p.checked[r] = true
+		} else {
+			delete(p.checked, r)
+		}
+	}
+	p.mu.Unlock()
+	p.refresh()
+}
+
+// refresh applies the filter and updates the list and the count.
+func (p *repoPicker) refresh() {
+	filter := p.filterEntry.Text
+	p.mu.Lock()
+	p.shown = nil
+	for _, r := range p.all {
+		if noteMatches(r, p.notes[strings.ToLower(r)], filter) {
+			p.shown = append(p.shown, r)
+		}
+	}
+	held, checked := 0, 0
+	for _, r := range p.all {
+		if p.holds.Get(r) != nil {
+			held++
+		}
+		if p.checked[r] {
+			checked++
+		}
+	}
+	total, shown, listing := len(p.all), len(p.shown), p.listing
+	p.mu.Unlock()
+
+	text := fmt.Sprintf("%d of %d selected", checked, total)
+	if listing {
+		text = fmt.Sprintf("%d of %d listed so far selected, still listing", checked, total)
+	}
+	if shown != total {
+		text += fmt.Sprintf(", %d shown", shown)
+	}
+	if held > 0 {
+		text += fmt.Sprintf(", %d on hold", held)
+	}
+	p.count.SetText(text)
+	p.list.Refresh()
+	if p.OnChanged != nil {
+		p.OnChanged(checked)
+	}
+}
+
+// shownRepos returns the repositories the filter lets through.
+func (p *repoPicker) shownRepos() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.shown...)
+}
+
+// Content returns the widget tree for the picker. Select All and Select
+// None apply to the repositories the filter shows.
+func (p *repoPicker) Content() fyne.CanvasObject {
+	selectAll := widget.NewButton("Select All", func() { p.setChecked(p.shownRepos(), true) })
+	selectNone := widget.NewButton("Select None", func() { p.setChecked(p.shownRepos(), false) })
+	// Lists have no height of their own in a VBox.
+	height := canvas.NewRectangle(nil)
+	height.SetMinSize(fyne.NewSize(0, 180))
+	return container.NewVBox(
+		container.NewBorder(nil, nil, p.fetchBtn, container.NewHBox(selectAll, selectNone), p.filterEntry),
+		p.count,
+		container.NewStack(height, p.list),
+	)
+}
+
+// confirmResumeDialog returns a function that asks whether to resume the
+// interrupted listing st or start over, and waits for the answer.
+func confirmResumeDialog(w fyne.Window) func(st *listingState) bool {
+	return func(st *listingState) bool {
+		answer := make(chan bool)
+		d := dialog.NewConfirm("Resume listing",
+			fmt.Sprintf("An %s was saved.\n\nResume it from where it stopped, or list %s again from the first page?", st, st.Org),
+			func(resume bool) { answer <- resume }, w)
+		d.SetConfirmText("Resume")
+		d.SetDismissText("Start Over")
+		d.Show()
+		return <-answer
+	}
+}
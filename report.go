@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reportsDir is where run reports are stored, relative to the working
+// directory.
+const reportsDir = "runs"
+
+// runReport is the stored record of one migration run.
+type runReport struct {
+	ID          string           `json:"id"`
+	Tag         string           `json:"tag,omitempty"`
+	Note        string           `json:"note,omitempty"` // the operator's note on the run
+	Started     string           `json:"started"`        // RFC3339 UTC
+	Finished    string           `json:"finished"`
+	Zone        string           `json:"zone"`
+	Target      string           `json:"target"`
+	GitHubLogin string           `json:"github_login,omitempty"` // who the GitHub token belongs to
+	Identities  *runIdentities   `json:"identities,omitempty"`   // all three identities of the run
+	Summary     reportSummary    `json:"summary"`
+	Repos       []*repoReport    `json:"repos"`
+	Security    []*securityEntry `json:"security,omitempty"`
+	// Splits has one entry per part of each monorepo migrated by a split
+	// plan.
+	Splits []splitReport `json:"splits,omitempty"`
+	// CreatedAreaPaths are work item area paths the run created.
+	CreatedAreaPaths []string `json:"created_area_paths,omitempty"`
+	// MigrationDoc is the run's MIGRATION.md, if it had anything to say.
+	MigrationDoc string `json:"migration_doc,omitempty"`
+	// CollisionPolicy is how colliding target names were resolved.
+	CollisionPolicy string `json:"collision_policy,omitempty"`
+	// LicensePolicy is the license policy the run applied, if any.
+	LicensePolicy string `json:"license_policy,omitempty"`
+}
+
+// reportSummary holds the run's headline counts.
+type reportSummary struct {
+	Repos            int `json:"repos"`
+	Migrated         int `json:"migrated"`
+	Verified         int `json:"verified"`
+	Warnings         int `json:"warnings"` // migrated, but some refs did not push
+	Failed           int `json:"failed"`
+	SecretFindings   int `json:"secret_findings"`
+	ReposWithSecrets int `json:"repos_with_secrets"`
+	// SourceChanges counts repositories that were transferred, renamed or
+	// deleted on GitHub between listing and cloning.
+	SourceChanges int `json:"source_changes"`
+	WontMigrate   int `json:"wont_migrate"`
+	Cancelled     int `json:"cancelled,omitempty"`
+	Held          int `json:"held,omitempty"`
+	// ServerWarnings counts repositories the target warned of while they
+	// were pushed, size limits and quotas among them.
+	ServerWarnings int `json:"server_warnings,omitempty"`
+}
+
+// securityEntry records a repository whose history the secret scan flagged
+// or could not finish.
+type securityEntry struct {
+	Repo     string          `json:"repo"`
+	Complete bool            `json:"complete"`
+	Findings []secretFinding `json:"findings"`
+}
+
+// repoReport is one repository's outcome within a run.
+type repoReport struct {
+	Source           string           `json:"source"`
+	CurrentSource    string           `json:"current_source,omitempty"` // set if Source moved mid-run
+	Target           string           `json:"target"`
+	SourceURL        string           `json:"source_url,omitempty"` // without credentials
+	TargetURL        string           `json:"target_url,omitempty"`
+	NameStrategy     string           `json:"name_strategy,omitempty"` // the collision strategy that renamed it
+	Status           string           `json:"status"`
+	DurationSeconds  float64          `json:"duration_seconds"`
+	Bytes            int64            `json:"bytes"`
+	Verified         bool             `json:"verified"`
+	FailedRefs       []string         `json:"failed_refs,omitempty"`       // tags that did not push
+	LFSSkipped       bool             `json:"lfs_skipped,omitempty"`       // uses LFS, whose objects were left out
+	FilteredBranches []string         `json:"filtered_branches,omitempty"` // left out by the branch filter
+	AreaPath         string           `json:"area_path,omitempty"`         // for work items created for the repo
+	IterationPath    string           `json:"iteration_path,omitempty"`
+	DefaultBranch    string           `json:"default_branch,omitempty"`    // the ref set, or why it was not
+	Comparison       *repoComparison  `json:"comparison,omitempty"`        // source and target counts
+	SourceMismatches []string         `json:"source_mismatches,omitempty"` // refs on target that differ from the source after the push
+	Pipelines        *pipelinesReport `json:"pipelines,omitempty"`         // converted from GitHub Actions
+	Badges           *badgeReport     `json:"badges,omitempty"`
+	Cleanup          string           `json:"cleanup,omitempty"`         // what became of the local clone
+	Copy             string           `json:"copy,omitempty"`            // where it is kept, if anywhere
+	Canary           string           `json:"canary,omitempty"`          // the outcome of the canary check
+	ServerWarnings   []string         `json:"server_warnings,omitempty"` // what the target warned of while pushing
+	ServerMessages   []string         `json:"server_messages,omitempty"` // what else it said
+	Error            string           `json:"error,omitempty"`
+	Reason           string           `json:"reason,omitempty"`  // why it won't be migrated
+	License          *licenseRecord   `json:"license,omitempty"` // set if there was a license policy
+	Note             string           `json:"note,omitempty"`    // the operator's note on it when it ran
+}
+
+// newRunReport starts the report for a run beginning at start.
+func newRunReport(start time.Time, tag, target string) *runReport {
+	return &runReport{
+		ID:      start.UTC().Format("20060102T150405Z"),
+		Tag:     tag,
+		Started: fileTimestamp(start),
+		Zone:    zoneSummary(start),
+		Target:  target,
+	}
+}
+
+// Label identifies the run in lists: its ID, and its tag if it has one.
+func (r *runReport) Label() string {
+	if r.Tag == "" {
+		return r.ID
+	}
+	return fmt.Sprintf("%s (%s)", r.ID, r.Tag)
+}
+
+// summarize recomputes the summary counts.
+func (r *runReport) summarize() {
+	s := reportSummary{Repos: len(r.Repos)}
+	for _, repo := range r.Repos {
+		switch {
+		case succeeded(repo.Status):
+			s.Migrated++
+			if len(repo.FailedRefs) > 0 {
+				s.Warnings++
+			}
+		case repo.Status == statusFailed:
+			s.Failed++
+		case repo.Status == statusWontMigrate:
+			s.WontMigrate++
+		case repo.Status == statusCancelled:
+			s.Cancelled++
+		case repo.Status == statusHeld:
+			s.Held++
+		}
+		if repo.Verified {
+			s.Verified++
+		}
+		if len(repo.ServerWarnings) > 0 {
+			s.ServerWarnings++
+		}
+		if repo.CurrentSource != "" || repo.Status == statusSourceRemoved {
+			s.SourceChanges++
+		}
+	}
+	for _, e := range r.Security {
+		if len(e.Findings) > 0 {
+			s.ReposWithSecrets++
+			s.SecretFindings += len(e.Findings)
+		}
+	}
+	r.Summary = s
+}
+
+// save writes the report to reportsDir and returns its path.
+func (r *runReport) save() (string, error) {
+	r.summarize()
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(reportsDir, r.ID+".json")
+	return path, writeFileAtomic(path, data, 0644)
+}
+
+// readReport reads a report file, recovering from an interrupted write.
+func readReport(path string) (*runReport, error) {
+	var r runReport
+	_, err := readFileRecover(path, func(data []byte) error {
+		r = runReport{}
+		return json.Unmarshal(data, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// listReports returns the stored reports, oldest first.
+func listReports() ([]*runReport, error) {
+	// A report whose write was interrupted may only exist as .tmp or .bak.
+	files, err := filepath.Glob(filepath.Join(reportsDir, "*.json*"))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var paths []string
+	for _, f := range files {
+		p := strings.TrimSuffix(strings.TrimSuffix(f, ".tmp"), ".bak")
+		if strings.HasSuffix(p, ".json") && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	var reports []*runReport
+	for _, p := range paths {
+		r, err := readReport(p)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// markWontMigrate gives the repositories of r in sources the terminal
+// status statusWontMigrate with reason, and saves r.
+func (r *runReport) markWontMigrate(sources []string, reason string) error {
+	marked := map[string]bool{}
+	for _, s := range sources {
+		marked[s] = true
+	}
+	for _, repo := range r.Repos {
+		if marked[repo.Source] {
+			repo.Status, repo.Reason = statusWontMigrate, reason
+		}
+	}
+	_, err := r.save()
+	return err
+}
+
+// wontMigrateDecisions returns the repositories whose latest outcome in
+// the stored reports is statusWontMigrate, with the reasons given.
+func wontMigrateDecisions() (map[string]string, error) {
+	reports, err := listReports()
+	if err != nil {
+		return nil, err
+	}
+	decisions := map[string]string{}
+	for _, r := range reports {
+		for _, repo := range r.Repos {
+			if repo.Status == statusWontMigrate {
+				decisions[repo.Source] = repo.Reason
+			} else {
+				delete(decisions, repo.Source)
+			}
+		}
+	}
+	return decisions, nil
+}
+
+// findReport resolves a run by file path, ID or tag.
+func findReport(ref string) (*runReport, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return readReport(ref)
+	}
+	reports, err := listReports()
+	if err != nil {
+		return nil, err
+	}
+	var match *runReport
+	for _, r := range reports {
+		if r.ID == ref || r.Tag == ref {
+			if match != nil {
+				return nil, fmt.Errorf("%q matches more than one run", ref)
+			}
+			match = r
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no run %q in %s", ref, reportsDir)
+	}
+	return match, nil
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// formatBytes renders a byte count for humans.
+func formatBytes(n int64) string {
+	return formatKB(int((n + 1023) / 1024))
+}
+
+// succeeded reports whether status is one of the migrated outcomes.
+func succeeded(status string) bool {
+	return strings.HasPrefix(status, statusMigrated)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// slowerFactor and slowerMin decide when a repository counts as having got
+// slower: more than 50% and more than 30 seconds longer.
+const (
+	slowerFactor = 1.5
+	slowerMin    = 30 * time.Second
+)
+
+// repoDiff compares one repository across two runs. A or B is nil if the
+// repository is missing from that run.
+type repoDiff struct {
+	Source     string
+	A, B       *repoReport
+	Changes    []string
+	Regression bool
+}
+
+// diffReports compares every repository that appears in either run and
+// returns the ones that changed, sorted by source name.
+func diffReports(a, b *runReport) []repoDiff {
+	byName := map[string]*repoDiff{}
+	for _, r := range a.Repos {
+		byName[r.Source] = &repoDiff{Source: r.Source, A: r}
+	}
+	for _, r := range b.Repos {
+		d, ok := byName[r.Source]
+		if !ok {
+			d = &repoDiff{Source: r.Source}
+			byName[r.Source] = d
+		}
+		d.B = r
+	}
+
+	var diffs []repoDiff
+	for _, d := range byName {
+		d.compare()
+		if len(d.Changes) > 0 {
+			diffs = append(diffs, *d)
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Source < diffs[j].Source })
+	return diffs
+}
+
+func (d *repoDiff) compare() {
+	switch {
+	case d.A == nil:
+		d.Changes = append(d.Changes, "only in second run ("+d.B.Status+")")
+		return
+	case d.B == nil:
+		d.Changes = append(d.Changes, "missing from second run")
+		d.Regression = true
+		return
+	}
+
+	a, b := d.A, d.B
+	if a.Status != b.Status {
+		change := fmt.Sprintf("status %s -> %s", a.Status, b.Status)
+		if (b.Status == statusWontMigrate || b.Status == statusHeld || b.Status == statusLicenseBlocked) && b.Reason != "" {
+			change += fmt.Sprintf(" (%s)", b.Reason)
+		}
+		d.Changes = append(d.Changes, change)
+		if succeeded(a.Status) && !succeeded(b.Status) && b.Status != statusWontMigrate && b.Status != statusHeld {
+			d.Regression = true
+		}
+	}
+	if a.Verified != b.Verified {
+		d.Changes = append(d.Changes, fmt.Sprintf("verified %t -> %t", a.Verified, b.Verified))
+		if a.Verified {
+			d.Regression = true
+		}
+	}
+	da := time.Duration(a.DurationSeconds * float64(time.Second))
+	db := time.Duration(b.DurationSeconds * float64(time.Second))
+	switch {
+	case db > time.Duration(float64(da)*slowerFactor) && db-da > slowerMin:
+		d.Changes = append(d.Changes, fmt.Sprintf("slower %s -> %s", formatDuration(da), formatDuration(db)))
+		d.Regression = true
+	case da > time.Duration(float64(db)*slowerFactor) && da-db > slowerMin:
+		d.Changes = append(d.Changes, fmt.Sprintf("faster %s -> %s", formatDuration(da), formatDuration(db)))
+	}
+	if a.Bytes != b.Bytes && a.Bytes > 0 && b.Bytes > 0 {
+		d.Changes = append(d.Changes, fmt.Sprintf("size %s -> %s", formatBytes(a.Bytes), formatBytes(b.Bytes)))
+	}
+}
+
+// writeDiffText writes the comparison as an aligned table.
+func writeDiffText(w io.Writer, a, b *runReport, diffs []repoDiff) {
+	fmt.Fprintf(w, "Comparing %s with %s\n\n", a.Label(), b.Label())
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No differences.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tREGRESSION\tCHANGES")
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Source, regressionMark(d), strings.Join(d.Changes, "; "))
+	}
+	tw.Flush()
+}
+
+// writeDiffMarkdown writes the comparison as Markdown for a retro document.
+func writeDiffMarkdown(w io.Writer, a, b *runReport, diffs []repoDiff) {
+	fmt.Fprintf(w, "## Comparing %s with %s\n\n", a.Label(), b.Label())
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No differences.")
+		return
+	}
+	regressions := 0
+	for _, d := range diffs {
+		if d.Regression {
+			regressions++
+		}
+	}
+	fmt.Fprintf(w, "%d repositories changed, %d regressed.\n\n", len(diffs), regressions)
+	fmt.Fprintln(w, "| Repository | Regression | Changes |")
+	fmt.Fprintln(w, "|---|---|---|")
+	for _, d := range diffs {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", d.Source, regressionMark(d), strings.Join(d.Changes, "; "))
+	}
+}
+
+func regressionMark(d repoDiff) string {
+	if d.Regression {
+		return "yes"
+	}
+	return ""
+}
+
+// runReportCommand implements "gitui report": "list" prints the stored
+// runs, "diff A B" compares two of them (by ID, tag or file path) and
+// "status RUN" exits with the run's outcome, for wrapper pipelines.
+func runReportCommand(args []string) int {
+	const usage = "usage: gitui report list | gitui report diff [--markdown] runA runB | gitui report status [--strict] run | gitui report export [--format csv|json|html] run"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return exitRunError
+	}
+	switch args[0] {
+	case "list":
+		reports, err := listReports()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		for _, r := range reports {
+			fmt.Printf("%s  %d repositories\n", r.Label(), len(r.Repos))
+		}
+		return exitOK
+	case "diff":
+		fs := flag.NewFlagSet("report diff", flag.ContinueOnError)
+		markdown := fs.Bool("markdown", false, "print Markdown instead of a text table")
+		setUsage(fs, "report diff [--markdown] runA runB")
+		if code := parseFlags(fs, args[1:]); code >= 0 {
+			return code
+		}
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return exitRunError
+		}
+		a, err := findReport(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		b, err := findReport(fs.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		diffs := diffReports(a, b)
+		if *markdown {
+			writeDiffMarkdown(os.Stdout, a, b, diffs)
+		} else {
+			writeDiffText(os.Stdout, a, b, diffs)
+		}
+		return exitOK
+	case "status":
+		fs := flag.NewFlagSet("report status", flag.ContinueOnError)
+		strict := fs.Bool("strict", false, "exit 3 if the run had warnings but no failures")
+		setUsage(fs, "report status [--strict] run")
+		if code := parseFlags(fs, args[1:]); code >= 0 {
+			return code
+		}
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitRunError
+		}
+		r, err := findReport(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		writeRunSummary(os.Stderr, r)
+		return runExitCode(r, *strict)
+	case "export":
+		fs := flag.NewFlagSet("report export", flag.ContinueOnError)
+		format := fs.String("format", "csv", "what to print: "+strings.Join(reportExportFormats, ", "))
+		setUsage(fs, "report export [--format csv|json|html] run")
+		if code := parseFlags(fs, args[1:]); code >= 0 {
+			return code
+		}
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitRunError
+		}
+		r, err := findReport(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		if err := exportReport(os.Stdout, r, *format); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitRunError
+		}
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "unknown report command %q\n%s\n", args[0], usage)
+	return exitRunError
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A run's report can be exported for compliance records: every
+// repository with its source and target URLs, branch and tag counts, size,
+// duration and outcome, as CSV, JSON or an HTML page. The JSON is the
+// stored report itself; CSV and HTML carry the repository rows and, in
+// HTML, the run's headline.
+
+// reportExportFormats are the formats a report exports to.
+var reportExportFormats = []string{"csv", "json", "html"}
+
+// reportExportColumns are the columns of a CSV export and of the HTML
+// table.
+var reportExportColumns = []string{
+	"source", "source_url", "target", "target_url", "status", "verified",
+	"source_branches", "source_tags", "target_branches", "target_tags",
+	"bytes", "duration_seconds", "error",
+}
+
+// repoURL returns rawURL without the credentials a clone URL may carry,
+// for the record.
+func repoURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// reportFormatOf returns the export format a file name's extension asks
+// for, JSON if it names none.
+func reportFormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".html", ".htm":
+		return "html"
+	}
+	return "json"
+}
+
+// exportRow returns repo's values in reportExportColumns order. Counts the
+// run did not compare are left empty.
+func exportRow(repo *repoReport) []string {
+	var sourceBranches, sourceTags, targetBranches, targetTags string
+	if c := repo.Comparison; c != nil {
+		sourceBranches, sourceTags = strconv.Itoa(c.Source.Branches), strconv.Itoa(c.Source.Tags)
+		targetBranches, targetTags = strconv.Itoa(c.Target.Branches), strconv.Itoa(c.Target.Tags)
+	}
+	errText := repo.Error
+	if errText == "" {
+		errText = repo.Reason
+	}
+	return []string{
+		repo.Source, repo.SourceURL, repo.Target, repo.TargetURL, repo.Status, strconv.FormatBool(repo.Verified),
+		sourceBranches, sourceTags, targetBranches, targetTags,
+		strconv.FormatInt(repo.Bytes, 10), strconv.FormatFloat(repo.DurationSeconds, 'f', 1, 64), errText,
+	}
+}
+
+// exportReport writes r to w in format: "csv", "json" or "html".
+func exportReport(w io.Writer, r *runReport, format string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(reportExportColumns)
+		for _, repo := range r.Repos {
+			cw.Write(exportRow(repo))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "html":
+		rows := make([][]string, len(r.Repos))
+		for i, repo := range r.Repos {
+			rows[i] = exportRow(repo)
+		}
+		var identities []string
+		if r.Identities != nil {
+			identities = r.Identities.lines()
+		} else if r.GitHubLogin != "" {
+			identities = []string{"GitHub token: " + r.GitHubLogin}
+		}
+		return reportHTML.Execute(w, struct {
+			Run        *runReport
+			Identities []string
+			Columns    []string
+			Rows       [][]string
+		}{r, identities, reportExportColumns, rows})
+	}
+	return fmt.Errorf("unknown report format %q (want %s)", format, strings.Join(reportExportFormats, ", "))
+}
+
+// reportHTML is the page an HTML export is: the run's headline and one
+// table row per repository.
+var reportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Migration report {{.Run.Label}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; font-size: 0.9em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+</style>
+</head>
+<body>
+<h1>Migration report {{.Run.Label}}</h1>
+<table>
+<tr><th>Started</th><td>{{.Run.Started}}</td></tr>
+<tr><th>Finished</th><td>{{.Run.Finished}}</td></tr>
+<tr><th>Target</th><td>{{.Run.Target}}</td></tr>
+{{- range .Identities}}
+<tr><th>Identity</th><td>{{.}}</td></tr>
+{{- end}}
+{{- with .Run.Note}}
+<tr><th>Note</th><td>{{.}}</td></tr>
+{{- end}}
+<tr><th>Repositories</th><td>{{.Run.Summary.Repos}}: {{.Run.Summary.Migrated}} migrated, {{.Run.Summary.Verified}} verified, {{.Run.Summary.Failed}} failed</td></tr>
+</table>
+<h2>Repositories</h2>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
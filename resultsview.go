@@ -0,0 +1,397 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// resultsColumns are the columns of the results table; the first marks
+// selected rows.
+var resultsColumns = []string{"", "Repository", "Target", "Status", "Error or reason", "Note"}
+
+// resultsView is the results table of the latest run, one row per
+// repository. Rows are selected by clicking, Ctrl+click toggles a row and
+// Shift+click selects a range; the toolbar acts on all selected rows.
+type resultsView struct {
+	window fyne.Window
+	tails  *tailView
+	logMsg func(string)
+	retry  func([]*repoReport)
+
+	mu       sync.Mutex
+	report   *runReport
+	notes    map[string]string // the operator notes now, by lower-case source
+	selected map[int]bool
+	anchor   int
+
+	title    *widget.Label
+	count    *widget.Label
+	table    *widget.Table
+	retryBtn *widget.Button
+}
+
+// newResultsView creates the view. retry migrates the given repositories
+// again, on the calling goroutine.
+func newResultsView(w fyne.Window, tails *tailView, retry func([]*repoReport), logMsg func(string)) *resultsView {
+	v := &resultsView{window: w, tails: tails, retry: retry, logMsg: logMsg, selected: map[int]bool{}, anchor: -1}
+	v.title = widget.NewLabel("No run yet.")
+	v.count = widget.NewLabel("")
+	v.table = widget.NewTableWithHeaders(
+		func() (int, int) {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			if v.report == nil {
+				return 0, len(resultsColumns)
+			}
+			return len(v.report.Repos), len(resultsColumns)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			label.Truncation = fyne.TextTruncateEllipsis
+			label.SetText(v.cell(id))
+		},
+	)
+	v.table.ShowHeaderColumn = false
+	v.table.CreateHeader = func() fyne.CanvasObject { return widget.NewLabel("") }
+	v.table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		if id.Col >= 0 {
+			o.(*widget.Label).SetText(resultsColumns[id.Col])
+		}
+	}
+	for col, width := range []float32{30, 260, 180, 220, 400, 260} {
+		v.table.SetColumnWidth(col, width)
+	}
+	v.table.OnSelected = func(id widget.TableCellID) {
+		v.table.UnselectAll()
+		v.click(id.Row, currentModifiers())
+	}
+	v.retryBtn = widget.NewButton("Retry", v.retrySelected)
+	return v
+}
+
+// currentModifiers returns the keyboard modifiers held down, where the
+// driver can tell.
+func currentModifiers() fyne.KeyModifier {
+	if d, ok := fyne.CurrentApp().Driver().(desktop.Driver); ok {
+		return d.CurrentKeyModifiers()
+	}
+	return 0
+}
+
+// cell returns the text of a table cell.
+func (v *resultsView) cell(id widget.TableCellID) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.report == nil || id.Row >= len(v.report.Repos) {
+		return ""
+	}
+	r := v.report.Repos[id.Row]
+	switch id.Col {
+	case 0:
+		if v.selected[id.Row] {
+			return "✔"
+		}
+		return ""
+	case 1:
+		return r.Source
+	case 2:
+		return r.Target
+	case 3:
+		return r.Status
+	case 5:
+		// The note as it is now; the report keeps it as it was.
+		return orDefault(v.notes[strings.ToLower(r.Source)], r.Note)
+	default:
+		if r.Status == statusWontMigrate || r.Status == statusHeld || r.Status == statusLicenseBlocked {
+			return r.Reason
+		}
+		if r.Error == "" && len(r.SourceMismatches) > 0 {
+			return "Refs differ from the source: " + strings.Join(r.SourceMismatches, "; ")
+		}
+		if r.Error == "" && len(r.ServerWarnings) > 0 {
+			return "Server warning: " + strings.Join(r.ServerWarnings, "; ")
+		}
+		return strings.TrimSpace(r.Error)
+	}
+}
+
+// click updates the selection for a click on row with mods held.
+func (v *resultsView) click(row int, mods fyne.KeyModifier) {
+	v.mu.Lock()
+	toggle := mods&(fyne.KeyModifierControl|fyne.KeyModifierSuper) != 0
+	switch {
+	case mods&fyne.KeyModifierShift != 0 && v.anchor >= 0:
+		if !toggle {
+			v.selected = map[int]bool{}
+		}
+		from, to := v.anchor, row
+		if from > to {
+			from, to = to, from
+		}
+		for i := from; i <= to; i++ {
+			v.selected[i] = true
+		}
+	case toggle:
+		v.selected[row] = !v.selected[row]
+		v.anchor = row
+	default:
+		v.selected = map[int]bool{row: true}
+		v.anchor = row
+	}
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// Show replaces the table's contents with r's repositories.
+func (v *resultsView) Show(r *runReport) {
+	v.mu.Lock()
+	v.report, v.selected, v.anchor = r, map[int]bool{}, -1
+	v.mu.Unlock()
+	v.reloadNotes()
+	title := "Run " + r.Label()
+	if r.GitHubLogin != "" {
+		title += ", GitHub identity " + r.GitHubLogin
+	}
+	if r.Identities != nil && r.Identities.ADO != "" {
+		title += ", Azure DevOps identity " + r.Identities.ADO
+	}
+	if r.Note != "" {
+		title += " — note: " + r.Note
+	}
+	v.title.SetText(title)
+	v.refresh()
+}
+
+// reloadNotes reads the operator notes again.
+func (v *resultsView) reloadNotes() {
+	notes, err := repoNotes()
+	if err != nil {
+		v.logMsg(fmt.Sprintf("Warning: could not read the notes: %v", err))
+	}
+	v.mu.Lock()
+	v.notes = notes
+	v.mu.Unlock()
+}
+
+// SetRetryEnabled enables or disables retrying, which writes to the target.
+func (v *resultsView) SetRetryEnabled(enabled bool) {
+	if enabled {
+		v.retryBtn.Enable()
+	} else {
+		v.retryBtn.Disable()
+	}
+}
+
+func (v *resultsView) refresh() {
+	v.mu.Lock()
+	n := 0
+	for _, ok := range v.selected {
+		if ok {
+			n++
+		}
+	}
+	v.mu.Unlock()
+	v.count.SetText(fmt.Sprintf("%d selected", n))
+	v.table.Refresh()
+}
+
+// selection returns the selected repositories, in table order.
+func (v *resultsView) selection() []*repoReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.report == nil {
+		return nil
+	}
+	var rows []int
+	for row, ok := range v.selected {
+		if ok && row < len(v.report.Repos) {
+			rows = append(rows, row)
+		}
+	}
+	sort.Ints(rows)
+	var repos []*repoReport
+	for _, row := range rows {
+		repos = append(repos, v.report.Repos[row])
+	}
+	return repos
+}
+
+// selectWhere selects the rows whose repository match.
+func (v *resultsView) selectWhere(match func(*repoReport) bool) {
+	v.mu.Lock()
+	v.selected = map[int]bool{}
+	if v.report != nil {
+		for i, r := range v.report.Repos {
+			if match(r) {
+				v.selected[i] = true
+			}
+		}
+	}
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// reportSources returns the source names of repos.
+func reportSources(repos []*repoReport) []string {
+	var names []string
+	for _, r := range repos {
+		names = append(names, r.Source)
+	}
+	return names
+}
+
+// retrySelected migrates the selected repositories again, through the same
+// creation, adoption and recycle bin handling as any run.
+func (v *resultsView) retrySelected() {
+	repos := v.selection()
+	if len(repos) == 0 {
+		return
+	}
+	// The run appends to its own report; retry copies of the entries.
+	var retry []*repoReport
+	for _, r := range repos {
+		retry = append(retry, &repoReport{Source: r.Source, Target: r.Target})
+	}
+	go v.retry(retry)
+}
+
+// exportLogs writes the captured output of each selected repository to a
+// file in a folder the user picks, with credentials redacted.
+func (v *resultsView) exportLogs() {
+	repos := v.selection()
+	if len(repos) == 0 {
+		return
+	}
+	dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil || dir == nil {
+			return
+		}
+		var written, missing int
+		for _, r := range repos {
+			output, ok := v.tails.Output(r.Source)
+			if !ok {
+				missing++
+				continue
+			}
+			name := strings.ReplaceAll(r.Source, "/", "_") + ".log"
+			text := fmt.Sprintf("%s (%s)\n%s\n", r.Source, r.Status, redactText(output, nil))
+			if err := os.WriteFile(filepath.Join(dir.Path(), name), []byte(text), 0644); err != nil {
+				dialog.ShowError(err, v.window)
+				return
+			}
+			written++
+		}
+		msg := fmt.Sprintf("Exported the logs of %d repositories to %s.", written, dir.Path())
+		if missing > 0 {
+			msg += fmt.Sprintf(" %d had no output this session.", missing)
+		}
+		v.logMsg(msg)
+	}, v.window)
+}
+
+// saveReport exports the run's report to a file the user picks, as CSV,
+// HTML or JSON by the file's extension.
+func (v *resultsView) saveReport() {
+	v.mu.Lock()
+	r := v.report
+	v.mu.Unlock()
+	if r == nil {
+		return
+	}
+	d := dialog.NewFileSave(func(f fyne.URIWriteCloser, err error) {
+		if err != nil || f == nil {
+			return
+		}
+		defer f.Close()
+		format := reportFormatOf(f.URI().Path())
+		if err := exportReport(f, r, format); err != nil {
+			dialog.ShowError(err, v.window)
+			return
+		}
+		v.logMsg(fmt.Sprintf("Report of run %s saved to %s (%s).", r.Label(), f.URI().Path(), strings.ToUpper(format)))
+	}, v.window)
+	d.SetFileName("migration-report-" + r.ID + ".csv")
+	d.Show()
+}
+
+// markWontMigrate asks for a reason and gives the selected repositories
+// the terminal status statusWontMigrate in the run's report.
+func (v *resultsView) markWontMigrate() {
+	repos := v.selection()
+	if len(repos) == 0 {
+		return
+	}
+	reasonEntry := widget.NewEntry()
+	reasonEntry.SetPlaceHolder("e.g. archived upstream, replaced by another repo")
+	reasonEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("a reason is required")
+		}
+		return nil
+	}
+	dialog.ShowForm(fmt.Sprintf("Won't migrate %d repositories", len(repos)), "Mark", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Reason", reasonEntry)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			reason := strings.TrimSpace(reasonEntry.Text)
+			v.mu.Lock()
+			err := v.report.markWontMigrate(reportSources(repos), reason)
+			v.mu.Unlock()
+			if err != nil {
+				v.logMsg(fmt.Sprintf("Error saving run report: %v", err))
+				return
+			}
+			v.logMsg(fmt.Sprintf("Marked %d repositories won't migrate: %s.", len(repos), reason))
+			v.refresh()
+		}, v.window)
+}
+
+// Content returns the widget tree for the view.
+func (v *resultsView) Content() fyne.CanvasObject {
+	toolbar := container.NewHBox(
+		widget.NewButton("Select Failed", func() {
+			v.selectWhere(func(r *repoReport) bool { return r.Status == statusFailed })
+		}),
+		widget.NewButton("Clear", func() { v.selectWhere(func(*repoReport) bool { return false }) }),
+		v.retryBtn,
+		widget.NewButton("Export Logs...", v.exportLogs),
+		widget.NewButton("Save Report...", v.saveReport),
+		widget.NewButton("Copy Names", func() {
+			if repos := v.selection(); len(repos) > 0 {
+				v.window.Clipboard().SetContent(strings.Join(reportSources(repos), "\n"))
+			}
+		}),
+		widget.NewButton("Won't Migrate...", v.markWontMigrate),
+		widget.NewButton("Note...", func() {
+			editRepoNotes(v.window, reportSources(v.selection()), v.logMsg, func() {
+				v.reloadNotes()
+				v.refresh()
+			})
+		}),
+		widget.NewButton("Run Note...", func() {
+			v.mu.Lock()
+			r := v.report
+			v.mu.Unlock()
+			if r != nil {
+				editRunNote(v.window, r, v.logMsg, func() { v.Show(r) })
+			}
+		}),
+		v.count,
+	)
+	return container.NewBorder(container.NewVBox(v.title, toolbar), nil, nil, nil, v.table)
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAttempts is how many times a clone, a push or a repository
+// creation is tried when it keeps failing transiently, unless the window,
+// a flag or GITUI_ATTEMPTS says otherwise.
+const defaultAttempts = 3
+
+// maxAttempts caps the attempts setting.
+const maxAttempts = 10
+
+// parseAttempts parses the attempts setting; blank means defaultAttempts,
+// and GITUI_ATTEMPTS overrides the default.
+func parseAttempts(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = os.Getenv("GITUI_ATTEMPTS")
+	}
+	if text == "" {
+		return defaultAttempts, nil
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 1 || n > maxAttempts {
+		return 0, fmt.Errorf("attempts must be a number from 1 to %d", maxAttempts)
+	}
+	return n, nil
+}
+
+// retryPolicy retries transient failures with exponential backoff: Delay
+// before the second attempt, doubling up to MaxDelay, each wait made up to
+// Jitter (a fraction) shorter or longer so that repositories failing
+// together do not retry together. Zero fields take the defaults.
+type retryPolicy struct {
+	Attempts int
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Jitter   float64
+}
+
+const (
+	defaultRetryDelay  = 5 * time.Second
+	maxRetryDelay      = time.Minute
+	defaultRetryJitter = 0.2
+)
+
+// parseBackoff parses the retry backoff setting, "DELAY[-MAX] [JITTER%]"
+// such as "5s-1m 20%", into a policy without attempts. Blank means the
+// defaults, and GITUI_RETRY_BACKOFF overrides them.
+func parseBackoff(text string) (retryPolicy, error) {
+	p := retryPolicy{Delay: defaultRetryDelay, MaxDelay: maxRetryDelay, Jitter: defaultRetryJitter}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = strings.TrimSpace(os.Getenv("GITUI_RETRY_BACKOFF"))
+	}
+	if text == "" {
+		return p, nil
+	}
+	invalid := fmt.Errorf("retry backoff must be DELAY[-MAX] [JITTER%%], such as %q", "5s-1m 20%")
+	fields := strings.Fields(text)
+	if len(fields) > 2 {
+		return p, invalid
+	}
+	delays := strings.SplitN(fields[0], "-", 2)
+	var err error
+	if p.Delay, err = time.ParseDuration(delays[0]); err != nil || p.Delay <= 0 {
+		return p, invalid
+	}
+	p.MaxDelay = p.Delay
+	if len(delays) == 2 {
+		if p.MaxDelay, err = time.ParseDuration(delays[1]); err != nil || p.MaxDelay < p.Delay {
+			return p, invalid
+		}
+	} else if p.Delay < maxRetryDelay {
+		p.MaxDelay = maxRetryDelay
+	}
+	if len(fields) == 2 {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64)
+		if err != nil || !strings.HasSuffix(fields[1], "%") || percent < 0 || percent > 100 {
+			return p, invalid
+		}
+		p.Jitter = percent / 100
+	}
+	return p, nil
+}
+
+// String describes the backoff in parseBackoff's syntax.
+func (p retryPolicy) String() string {
+	// time.Duration spells out zero units: "1m0s".
+	short := func(d time.Duration) string {
+		s := d.String()
+		if strings.HasSuffix(s, "m0s") {
+			s = strings.TrimSuffix(s, "0s")
+		}
+		if strings.HasSuffix(s, "h0m") {
+			s = strings.TrimSuffix(s, "0m")
+		}
+		return s
+	}
+	return fmt.Sprintf("%s-%s %g%%", short(p.Delay), short(p.MaxDelay), p.Jitter*100)
+}
+
+// backoff returns how long to wait after the given failed attempt (1 for
+// the first).
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay, max := p.Delay, p.MaxDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	if max <= 0 {
+		max = maxRetryDelay
+	}
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// fatalGitOutput are git messages for failures that trying again cannot
+// fix, whatever else the output says: refused credentials and missing
+// repositories.
+var fatalGitOutput = []string{
+	"authentication failed",
+	"could not read username",
+	"could not read password",
+	"repository not found",
+	"does not appear to be a git repository",
+	"returned error: 401",
+	"returned error: 403",
+	"returned error: 404",
+}
+
+// transientGitOutput are git and curl messages for network failures worth
+// trying again: dropped connections, timeouts, name resolution, and the
+// server answering 429 or 5xx.
+var transientGitOutput = []string{
+	"could not resolve host",
+	"connection timed out",
+	"operation timed out",
+	"connection reset",
+	"connection refused",
+	"failed to connect",
+	"early eof",
+	"the remote end hung up unexpectedly",
+	"rpc failed",
+	"unexpected disconnect",
+	"gnutls_handshake",
+	"ssl_read",
+	"ssl_connect",
+	"tls handshake",
+	"returned error: 429",
+	"returned error: 500",
+	"returned error: 502",
+	"returned error: 503",
+	"returned error: 504",
+}
+
+// transientFailure reports whether a failed step is worth trying again:
+// a git command whose output shows a network failure, an API request
+// answered 429 or 5xx, or one that timed out or lost its connection.
+// Refused credentials and missing repositories are fatal.
+func transientFailure(output string, err error) bool {
+	if err == nil || errors.Is(err, errReadOnly) {
+		return false
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	output = strings.ToLower(output + "\n" + err.Error())
+	for _, s := range fatalGitOutput {
+		if strings.Contains(output, s) {
+			return false
+		}
+	}
+	for _, s := range transientGitOutput {
+		if strings.Contains(output, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// do runs step, described by what (such as "the clone of owner/repo"), and
+// tries it again while it fails transiently, up to p.Attempts times in
+// all, logging each retry. It gives up early if ctx is cancelled. It
+// returns the last attempt's output and error.
+func (p retryPolicy) do(ctx context.Context, what string, logMsg func(string), step func() (string, error)) (string, error) {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		output, err := step()
+		if err == nil || attempt >= attempts || ctx.Err() != nil || !transientFailure(output, err) {
+			if err == nil && attempt > 1 {
+				logMsg(fmt.Sprintf("Attempt %d of %d succeeded: %s.", attempt, attempts, what))
+			}
+			return output, err
+		}
+		delay := p.backoff(attempt)
+		logMsg(fmt.Sprintf("Transient failure in %s (attempt %d of %d): %v; retrying in %s.", what, attempt, attempts, err, formatDuration(delay)))
+		select {
+		case <-ctx.Done():
+			return output, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+var (
+	apiRetryMu  sync.Mutex
+	apiRetry    = retryPolicy{Attempts: defaultAttempts}
+	apiRetryLog func(string)
+)
+
+// setAPIRetry sets the policy API requests are retried under, and where
+// the retries are logged; a run sets them from its settings.
+func setAPIRetry(p retryPolicy, logMsg func(string)) {
+	apiRetryMu.Lock()
+	apiRetry, apiRetryLog = p, logMsg
+	apiRetryMu.Unlock()
+}
+
+// retryTransport retries API requests that fail transiently under the
+// policy setAPIRetry set. Requests that may have been carried out are only
+// retried if doing them twice does no harm: a POST is retried when it
+// could not connect or the server answered 429 or 503, which it does
+// before doing anything, but not after a timeout or a 502.
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	apiRetryMu.Lock()
+	p, logMsg := apiRetry, apiRetryLog
+	apiRetryMu.Unlock()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= p.Attempts || (req.Body != nil && req.GetBody == nil) || !retryableRequest(req, resp, err) {
+			return resp, err
+		}
+		delay := p.backoff(attempt)
+		failure := ""
+		if resp != nil {
+			if after := retryAfter(resp); after > delay {
+				if p.MaxDelay > 0 && after > p.MaxDelay {
+					return resp, err // longer than the policy waits
+				}
+				delay = after
+			}
+			failure = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			failure = err.Error()
+		}
+		if logMsg != nil {
+			logMsg(fmt.Sprintf("Transient failure in %s %s%s (attempt %d of %d): %s; retrying in %s.",
+				req.Method, req.URL.Host, req.URL.Path, attempt, p.Attempts, failure, formatDuration(delay)))
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryableRequest reports whether req, which got resp or err, is worth
+// sending again.
+func retryableRequest(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := req.Method == "GET" || req.Method == "HEAD" || req.Method == "OPTIONS" || req.Method == "PUT" || req.Method == "DELETE"
+	if err != nil {
+		if req.Context().Err() != nil || errors.Is(err, errReadOnly) {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true // never reached the server
+		}
+		return idempotent && transientFailure("", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter returns how long resp's Retry-After header asks to wait, or
+// 0 if it has none.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// waveConfig is one cutover wave of a migration config.
+type waveConfig struct {
+	Name  string   `yaml:"name"`
+	Owner string   `yaml:"owner"`
+	Repos []string `yaml:"repos"` // glob patterns on "name" or "owner/name"
+}
+
+// Runbook estimates assume this throughput and per-repository overhead
+// (listing, creating, verifying) until past run reports calibrate them.
+const (
+	defaultBytesPerSecond = 2 << 20
+	repoOverhead          = 30 * time.Second
+)
+
+// largeRepoKB is the size from which a repository gets a pre-check of its
+// own.
+const largeRepoKB = 1 << 20
+
+// calibration is the transfer rate observed in past runs.
+type calibration struct {
+	BytesPerSecond float64
+	Samples        int // repositories the rate is based on; 0 means the default
+}
+
+// calibrate derives the transfer rate from the stored run reports.
+func calibrate() calibration {
+	reports, _ := listReports()
+	var bytes, seconds float64
+	samples := 0
+	for _, r := range reports {
+		for _, repo := range r.Repos {
+			overhead := repoOverhead.Seconds()
+			if !succeeded(repo.Status) || repo.Bytes == 0 || repo.DurationSeconds <= overhead {
+				continue
+			}
+			bytes += float64(repo.Bytes)
+			seconds += repo.DurationSeconds - overhead
+			samples++
+		}
+	}
+	if samples == 0 {
+		return calibration{BytesPerSecond: defaultBytesPerSecond}
+	}
+	return calibration{BytesPerSecond: bytes / seconds, Samples: samples}
+}
+
+// estimate returns the expected migration time of a repository of sizeKB.
+func (c calibration) estimate(sizeKB int) time.Duration {
+	transfer := float64(sizeKB) * 1024 / c.BytesPerSecond
+	return repoOverhead + time.Duration(math.Ceil(transfer))*time.Second
+}
+
+func (c calibration) String() string {
+	if c.Samples == 0 {
+		return fmt.Sprintf("uncalibrated, assuming %s/s", formatBytes(int64(c.BytesPerSecond)))
+	}
+	return fmt.Sprintf("%s/s measured over %d repositories in past runs", formatBytes(int64(c.BytesPerSecond)), c.Samples)
+}
+
+// runbookWave is a wave with the plan entries it migrates.
+type runbookWave struct {
+	waveConfig
+	Entries []planEntry
+}
+
+// waves assigns each entry to the first configured wave matching it, in
+// config order; entries no wave matches form a final "Unassigned" wave.
+func (p *migrationPlan) waves() []runbookWave {
+	waves := make([]runbookWave, len(p.Config.Waves))
+	for i, w := range p.Config.Waves {
+		waves[i].waveConfig = w
+		if waves[i].Name == "" {
+			waves[i].Name = fmt.Sprintf("Wave %d", i+1)
+		}
+	}
+	unassigned := runbookWave{waveConfig: waveConfig{Name: "Unassigned"}}
+	for _, e := range p.Entries {
+		placed := false
+		for i := range waves {
+			if matchAny(waves[i].Repos, repoShortName(e.Source)) || matchAny(waves[i].Repos, e.Source) {
+				waves[i].Entries = append(waves[i].Entries, e)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			unassigned.Entries = append(unassigned.Entries, e)
+		}
+	}
+	if len(unassigned.Entries) > 0 {
+		waves = append(waves, unassigned)
+	}
+	return waves
+}
+
+// countOpenPulls counts a repository's open pull requests, up to 100; more
+// is reported as "100+".
+func countOpenPulls(ctx context.Context, fullName, token string) (string, error) {
+	var pulls []struct {
+		Number int `json:"number"`
+	}
+	next, err := gitHubGet(ctx, fmt.Sprintf("https://api.github.com/repos/%s/pulls?state=open&per_page=100", fullName), token, &pulls)
+	if err != nil {
+		return "", err
+	}
+	if next != "" {
+		return "100+", nil
+	}
+	return fmt.Sprint(len(pulls)), nil
+}
+
+// runbookPrechecks gathers what the runbook needs beyond the plan: each
+// entry's open pull requests.
+func runbookPrechecks(ctx context.Context, p *migrationPlan, token string) map[string]string {
+	openPulls := map[string]string{}
+	for _, e := range p.Entries {
+		n, err := countOpenPulls(ctx, e.Source, token)
+		if err != nil {
+			n = "unknown (" + err.Error() + ")"
+		}
+		openPulls[e.Source] = n
+	}
+	return openPulls
+}
+
+// writeRunbook writes the cutover runbook as Markdown. Output depends only
+// on the plan, the calibration and openPulls, in a fixed order, so
+// regenerating it after a plan change diffs cleanly.
+func (p *migrationPlan) writeRunbook(w io.Writer, cal calibration, openPulls map[string]string) {
+	fmt.Fprintf(w, "# Cutover runbook: %s to %s/%s\n\n", orDefault(p.Config.GitHub.Org, "(token user's repositories)"), p.Config.ADO.Org, p.Config.ADO.Project)
+	fmt.Fprintf(w, "**Options:** %s  \n", p.Options)
+	fmt.Fprintf(w, "**Throughput:** %s\n", cal)
+	if len(p.Errors) > 0 {
+		fmt.Fprintln(w, "\n**The plan has errors; fix them before scheduling:**")
+		for _, e := range p.Errors {
+			fmt.Fprintf(w, "- %s\n", e)
+		}
+	}
+	if len(p.Warnings) > 0 {
+		fmt.Fprintln(w, "\n**Warnings:**")
+		for _, e := range p.Warnings {
+			fmt.Fprintf(w, "- %s\n", e)
+		}
+	}
+
+	for i, wave := range p.waves() {
+		var total time.Duration
+		for _, e := range wave.Entries {
+			total += cal.estimate(e.SizeKB)
+		}
+		fmt.Fprintf(w, "\n## %d. %s\n\n", i+1, wave.Name)
+		fmt.Fprintf(w, "**Owner:** %s  \n", orDefault(wave.Owner, "(unassigned)"))
+		fmt.Fprintf(w, "**Repositories:** %d, estimated %s run sequentially\n\n", len(wave.Entries), formatDuration(total))
+
+		fmt.Fprintln(w, "| Source | Target | Size | Estimated duration |")
+		fmt.Fprintln(w, "|---|---|---|---|")
+		for _, e := range wave.Entries {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", e.Source, e.Target, e.sizeText(), formatDuration(cal.estimate(e.SizeKB)))
+		}
+
+		fmt.Fprint(w, "\n### Pre-checks\n\n")
+		for _, e := range wave.Entries {
+			for _, c := range e.prechecks(openPulls[e.Source]) {
+				fmt.Fprintf(w, "- [ ] %s: %s\n", e.Source, c)
+			}
+		}
+		fmt.Fprintln(w, "- [ ] Announce the push freeze for this wave's repositories")
+
+		fmt.Fprint(w, "\n### Steps\n\n")
+		fmt.Fprintf(w, "1. Start the migration with run tag `%s` and the options above.\n", waveTag(wave.Name))
+		fmt.Fprintln(w, "2. Watch the log for warnings (failed tags, missing LFS objects, default branch).")
+		fmt.Fprintf(w, "3. Verify: the report of run `%s` (see `gitui report list`) shows every repository as verified; refs were compared with the source automatically.\n", waveTag(wave.Name))
+		fmt.Fprintln(w, "4. Spot-check the default branch and latest commit of the largest repositories in the target.")
+		fmt.Fprintln(w, "5. Point developers at the new remotes and lift the freeze.")
+
+		fmt.Fprint(w, "\n### Rollback\n\n")
+		fmt.Fprintln(w, "- The GitHub repositories are left in place and are not archived by the migration; point developers back to them.")
+		if p.Config.DeleteAfter {
+			fmt.Fprintln(w, "- delete_after is set: local clones are removed once verified, so re-pushing means cloning again.")
+		} else {
+			fmt.Fprintln(w, "- Local bare clones are kept and can be re-pushed or bundled (`git bundle create repo.bundle --all`).")
+		}
+		fmt.Fprintln(w, "- Delete the target repositories to retry; Azure DevOps keeps them in the recycle bin until purged, which holds their names.")
+	}
+}
+
+// prechecks lists what to confirm for the entry before cutover.
+func (e planEntry) prechecks(openPulls string) []string {
+	var checks []string
+	switch openPulls {
+	case "", "0":
+	default:
+		checks = append(checks, fmt.Sprintf("%s open pull request(s); merge or close them, pull requests are not migrated", openPulls))
+	}
+	switch {
+	case e.Analysis == nil:
+		checks = append(checks, "LFS and large files not checked (run plan --deep)")
+	case e.Analysis.Excluded:
+		checks = append(checks, "LFS and large files "+notScannedExcluded)
+	case e.Analysis.Error == "":
+		if e.Analysis.LFS {
+			checks = append(checks, "uses Git LFS; release active LFS locks")
+		}
+		if e.Analysis.LargeBlobs > 0 {
+			checks = append(checks, fmt.Sprintf("%d blob(s) over %s in history", e.Analysis.LargeBlobs, formatBytes(largeBlobSize)))
+		}
+	}
+	if e.SizeFrom == sizeUnknown {
+		checks = append(checks, "size unknown, its estimate assumes an empty repository; measure it (plan --deep) or schedule it early in the window")
+	}
+	if e.SizeKB >= largeRepoKB {
+		checks = append(checks, fmt.Sprintf("large repository (%s); schedule it early in the window", e.sizeText()))
+	}
+	if e.Archived {
+		checks = append(checks, "archived on GitHub; will be made read-only in the target")
+	}
+	for _, n := range e.Notes {
+		checks = append(checks, n)
+	}
+	return checks
+}
+
+// waveTag turns a wave name into a run tag, e.g. "Wave 2" becomes "wave-2".
+func waveTag(name string) string {
+	return url.PathEscape(strings.ToLower(strings.Join(strings.Fields(name), "-")))
+}
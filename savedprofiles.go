@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Named profiles are also kept on disk, in profiles.yaml, for recurring
+// migration waves: the settings of each, credentials left out as in an
+// exported profile, saved under a name and picked from the window's
+// dropdown to fill it in again.
+
+// savedProfilesFile is the file named profiles live in.
+type savedProfilesFile struct {
+	Schema   int            `yaml:"schema"`
+	Profiles []savedProfile `yaml:"profiles"`
+}
+
+// savedProfile is one named profile.
+type savedProfile struct {
+	Name     string            `yaml:"name"`
+	Saved    string            `yaml:"saved,omitempty"`
+	Settings map[string]string `yaml:"settings"`
+}
+
+// savedProfilesPath returns where profiles.yaml is: GITUI_PROFILES, or
+// gitui/profiles.yaml under XDG_CONFIG_HOME, ~/.config if that is unset.
+func savedProfilesPath() (string, error) {
+	if path := os.Getenv("GITUI_PROFILES"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gitui", "profiles.yaml"), nil
+}
+
+// parseSavedProfiles parses a profiles file. Files of a newer schema than
+// this version reads are refused, as exported profiles are.
+func parseSavedProfiles(data []byte) (*savedProfilesFile, error) {
+	var f savedProfilesFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Schema > profileSchema {
+		return nil, fmt.Errorf("the profiles were saved by a newer version of gitui (profile schema %d, this version reads up to %d)", f.Schema, profileSchema)
+	}
+	for i := range f.Profiles {
+		if f.Profiles[i].Settings == nil {
+			f.Profiles[i].Settings = map[string]string{}
+		}
+	}
+	return &f, nil
+}
+
+// loadSavedProfiles reads the profiles at path; a missing file has none.
+func loadSavedProfiles(path string) (*savedProfilesFile, error) {
+	data, err := readFileRecover(path, func(data []byte) error {
+		_, err := parseSavedProfiles(data)
+		return err
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return &savedProfilesFile{Schema: profileSchema}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSavedProfiles(data)
+}
+
+// save writes the profiles to path, creating its directory.
+func (f *savedProfilesFile) save(path string) error {
+	f.Schema = profileSchema
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// Names returns the profiles' names, sorted.
+func (f *savedProfilesFile) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for _, p := range f.Profiles {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the profile called name, or nil.
+func (f *savedProfilesFile) Get(name string) *savedProfile {
+	for i := range f.Profiles {
+		if f.Profiles[i].Name == name {
+			return &f.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// Put saves settings, credentials left out, as the profile called name,
+// replacing any of that name.
+func (f *savedProfilesFile) Put(name string, settings map[string]string) {
+	p := newProfile(name, settings)
+	saved := savedProfile{Name: name, Saved: fileTimestamp(time.Now()), Settings: p.Settings}
+	if existing := f.Get(name); existing != nil {
+		*existing = saved
+		return
+	}
+	f.Profiles = append(f.Profiles, saved)
+}
+
+// Remove deletes the profile called name, reporting whether there was one.
+func (f *savedProfilesFile) Remove(name string) bool {
+	for i, p := range f.Profiles {
+		if p.Name == name {
+			f.Profiles = append(f.Profiles[:i], f.Profiles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
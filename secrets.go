@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretPolicy decides what happens when a repository's history contains
+// something that looks like a secret.
+type secretPolicy int
+
+const (
+	// secretsReportOnly records findings and migrates anyway.
+	secretsReportOnly secretPolicy = iota
+	// secretsConfirm asks before migrating each repository with findings.
+	secretsConfirm
+	// secretsBlock skips repositories with findings.
+	secretsBlock
+)
+
+// secretPolicyNames are the UI labels for each secretPolicy, in order.
+var secretPolicyNames = []string{
+	"Report secrets in history",
+	"Ask before migrating repos with secrets",
+	"Don't migrate repos with secrets",
+}
+
+// secretRule is a high-signal pattern for one kind of credential. The rules
+// are deliberately few: a finding should almost always be real.
+type secretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+var secretRules = []secretRule{
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_secret_access_key\s*[:=]\s*["']?[A-Za-z0-9/+]{40}\b`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b`)},
+	{"GitHub fine-grained token", regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{82}\b`)},
+	{"Private key", regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY( BLOCK)?-----`)},
+}
+
+// maxScanBlobSize skips blobs too large to be source or config files.
+const maxScanBlobSize = 1 << 20
+
+// defaultSecretScanTimeout bounds the scan of one repository, so a huge
+// history cannot hold up the run.
+const defaultSecretScanTimeout = 2 * time.Minute
+
+// secretScanTimeout returns the configured per-repository scan time limit.
+func secretScanTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("GITUI_SECRET_SCAN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return defaultSecretScanTimeout
+}
+
+// secretFinding locates a match. It never holds the matched value.
+type secretFinding struct {
+	Rule   string `json:"rule"`
+	Commit string `json:"commit"`
+	Path   string `json:"path"`
+}
+
+func (f secretFinding) String() string {
+	commit := f.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return fmt.Sprintf("%s in %s (commit %s)", f.Rule, f.Path, commit)
+}
+
+// scanBlob is a blob to scan and the first path it was seen at.
+type scanBlob struct {
+	SHA  string
+	Path string
+}
+
+// scanSecrets scans every blob reachable from any ref of the clone in dir.
+// Blobs are split across parallel git cat-file processes. If ctx expires the
+// findings so far are returned with ctx's error.
+func scanSecrets(ctx context.Context, dir string) ([]secretFinding, error) {
+	blobs, err := listScanBlobs(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := runtime.NumCPU()
+	if workers > 8 {
+		workers = 8
+	}
+	var mu sync.Mutex
+	var found []secretFinding
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		var chunk []scanBlob
+		for j := i; j < len(blobs); j += workers {
+			chunk = append(chunk, blobs[j])
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(chunk []scanBlob) {
+			defer wg.Done()
+			f := scanBlobs(ctx, dir, chunk)
+			mu.Lock()
+			found = append(found, f...)
+			mu.Unlock()
+		}(chunk)
+	}
+	wg.Wait()
+
+	// Attribute each finding to the oldest commit that introduced the blob.
+	for i := range found {
+		if ctx.Err() != nil {
+			break
+		}
+		out, err := exec.CommandContext(ctx, "git", "-C", dir, "log", "--all", "--format=%H", "--find-object="+found[i].Commit).Output()
+		if err == nil {
+			lines := strings.Fields(string(out))
+			if len(lines) > 0 {
+				found[i].Commit = lines[len(lines)-1]
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Path != found[j].Path {
+			return found[i].Path < found[j].Path
+		}
+		return found[i].Rule < found[j].Rule
+	})
+	return found, ctx.Err()
+}
+
+// listScanBlobs lists the distinct blobs in history small enough to scan.
+func listScanBlobs(ctx context.Context, dir string) ([]scanBlob, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-list", "--objects", "--all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing objects: %v", err)
+	}
+	// Commits are listed without a path; trees and blobs with one.
+	var candidates []scanBlob
+	var shas bytes.Buffer
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		sha, path, ok := strings.Cut(line, " ")
+		if !ok || seen[sha] {
+			continue
+		}
+		seen[sha] = true
+		candidates = append(candidates, scanBlob{SHA: sha, Path: path})
+		shas.WriteString(sha + "\n")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "cat-file", "--batch-check=%(objecttype) %(objectsize)")
+	cmd.Stdin = &shas
+	out, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("checking objects: %v", err)
+	}
+	var blobs []scanBlob
+	for i, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		kind, sizeStr, _ := strings.Cut(line, " ")
+		size, _ := strconv.Atoi(sizeStr)
+		if i < len(candidates) && kind == "blob" && size <= maxScanBlobSize {
+			blobs = append(blobs, candidates[i])
+		}
+	}
+	return blobs, nil
+}
+
+// scanBlobs reads blobs through one git cat-file --batch process and
+// returns their findings, with the blob SHA in Commit until attributed.
+func scanBlobs(ctx context.Context, dir string, blobs []scanBlob) []secretFinding {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil
+	}
+	if err := cmd.Start(); err != nil {
+		return nil
+	}
+	go func() {
+		for _, b := range blobs {
+			fmt.Fprintln(stdin, b.SHA)
+		}
+		stdin.Close()
+	}()
+
+	var found []secretFinding
+	r := bufio.NewReader(stdout)
+	for _, b := range blobs {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue
+		}
+		size, _ := strconv.Atoi(fields[2])
+		content := make([]byte, size+1) // trailing newline
+		if _, err := io.ReadFull(r, content); err != nil {
+			break
+		}
+		content = content[:size]
+		head := content
+		if len(head) > 8000 {
+			head = head[:8000]
+		}
+		if bytes.IndexByte(head, 0) >= 0 {
+			continue // binary
+		}
+		for _, rule := range secretRules {
+			if rule.Pattern.Match(content) {
+				found = append(found, secretFinding{Rule: rule.Name, Commit: b.SHA, Path: b.Path})
+			}
+		}
+	}
+	cmd.Wait()
+	return found
+}
+
+// secretStep scans the clone in dir and applies policy. confirm is asked
+// whether to migrate a repository with findings under secretsConfirm. It
+// returns the findings, whether the scan finished in time, and whether the
+// migration should go ahead.
+func secretStep(dir, repo string, policy secretPolicy, confirm func(repo string, findings []secretFinding) bool, logMsg func(string)) (findings []secretFinding, complete, proceed bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretScanTimeout())
+	defer cancel()
+
+	findings, err := scanSecrets(ctx, dir)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		logMsg(fmt.Sprintf("Warning: secret scan of %s timed out after %s, results are partial.", repo, secretScanTimeout()))
+	case err != nil:
+		logMsg(fmt.Sprintf("Warning: secret scan of %s failed: %v", repo, err))
+	default:
+		complete = true
+	}
+	if len(findings) == 0 {
+		return nil, complete, true
+	}
+
+	logMsg(fmt.Sprintf("Warning: %d possible secret(s) in the history of %s:", len(findings), repo))
+	for _, f := range findings {
+		logMsg("  " + f.String())
+	}
+	switch policy {
+	case secretsBlock:
+		return findings, complete, false
+	case secretsConfirm:
+		return findings, complete, confirm(repo, findings)
+	}
+	return findings, complete, true
+}
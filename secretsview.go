@@ -0,0 +1,28 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// confirmSecretsDialog returns a secretStep confirm function that asks the
+// user in a dialog. It blocks the calling migration goroutine until the user
+// answers.
+func confirmSecretsDialog(w fyne.Window) func(repo string, findings []secretFinding) bool {
+	return func(repo string, findings []secretFinding) bool {
+		answer := make(chan bool)
+		var lines []string
+		for _, f := range findings {
+			lines = append(lines, f.String())
+		}
+		dialog.ShowConfirm("Secrets in history",
+			fmt.Sprintf("%s has %d possible secret(s) in its history:\n\n%s\n\nMigrate it anyway?", repo, len(findings), strings.Join(lines, "\n")),
+			func(ok bool) { answer <- ok }, w)
+		return <-answer
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signingFormat is how commits the tool makes itself are signed.
+type signingFormat int
+
+const (
+	signNone signingFormat = iota
+	signGPG
+	signSSH
+)
+
+// signingFormatNames are the UI labels for each signingFormat, in order.
+var signingFormatNames = []string{
+	"Don't sign",
+	"GPG",
+	"SSH",
+}
+
+// The identity of commits the tool makes unless configured otherwise.
+const (
+	defaultBotName  = "Migration Bot"
+	defaultBotEmail = "migration-bot@localhost"
+)
+
+// commitSigner makes the tool's own commits (such as README badge rewrites)
+// under a bot identity, signed if configured. A nil *commitSigner commits
+// unsigned as the default bot.
+type commitSigner struct {
+	Name   string
+	Email  string
+	Format signingFormat
+	// Key is the GPG key id, or the SSH key file; a .pub file signs through
+	// the SSH agent. Empty uses git's configured or default key.
+	Key string
+	// Require fails the commit instead of falling back to unsigned.
+	Require bool
+}
+
+// identity returns the bot's name and email.
+func (s *commitSigner) identity() (name, email string) {
+	name, email = defaultBotName, defaultBotEmail
+	if s != nil && s.Name != "" {
+		name = s.Name
+	}
+	if s != nil && s.Email != "" {
+		email = s.Email
+	}
+	return name, email
+}
+
+// identityArgs returns the git -c options for the bot identity.
+func (s *commitSigner) identityArgs() []string {
+	name, email := s.identity()
+	return []string{"-c", "user.name=" + name, "-c", "user.email=" + email}
+}
+
+// signing reports whether commits are to be signed.
+func (s *commitSigner) signing() bool {
+	return s != nil && s.Format != signNone
+}
+
+// commitTree creates a commit of tree on parent in the clone in dir and
+// returns its SHA. A signed commit is verified locally before it is used.
+// If signing fails the commit is made unsigned with a warning, unless
+// signing is required.
+func (s *commitSigner) commitTree(ctx context.Context, dir, tree, parent, message string, logMsg func(string)) (string, error) {
+	args := append(s.identityArgs(), "commit-tree", tree, "-p", parent)
+	if !s.signing() {
+		return gitInput(dir, message, args...)
+	}
+
+	commit, err := gitInput(dir, message, append(append(s.signArgs(), args...), "-S")...)
+	if err == nil {
+		err = s.verify(ctx, dir, commit)
+	}
+	if err == nil {
+		return commit, nil
+	}
+	if s.Require {
+		return "", fmt.Errorf("signing commit: %v", err)
+	}
+	logMsg(fmt.Sprintf("WARNING: could not sign the commit, committing it UNSIGNED; branch policies requiring signed commits will block it: %v", err))
+	return gitInput(dir, message, args...)
+}
+
+// signArgs returns the git options selecting the signature format and key.
+func (s *commitSigner) signArgs() []string {
+	var args []string
+	if s.Format == signSSH {
+		args = append(args, "-c", "gpg.format=ssh")
+	}
+	if s.Key != "" {
+		args = append(args, "-c", "user.signingkey="+s.Key)
+	}
+	return args
+}
+
+// verify checks commit's signature. SSH signatures are checked against the
+// signing key's public half, since there is no allowed signers file.
+func (s *commitSigner) verify(ctx context.Context, dir, commit string) error {
+	args := []string{"-C", dir}
+	if s.Format == signSSH {
+		pub, err := s.publicKey(ctx)
+		if err != nil {
+			return err
+		}
+		f, err := os.CreateTemp("", "allowed-signers")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		_, email := s.identity()
+		fmt.Fprintf(f, "%s %s\n", email, pub)
+		f.Close()
+		args = append(args, "-c", "gpg.format=ssh", "-c", "gpg.ssh.allowedSignersFile="+f.Name())
+	}
+	args = append(args, "verify-commit", commit)
+	if output, err := runGit(ctx, nil, args...); err != nil {
+		return fmt.Errorf("signature does not verify: %v, output: %s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// publicKey returns the public SSH key signing uses.
+func (s *commitSigner) publicKey(ctx context.Context) (string, error) {
+	key := s.Key
+	if key == "" {
+		out, err := runGit(ctx, nil, "config", "user.signingkey")
+		if err != nil {
+			return "", fmt.Errorf("no SSH signing key configured")
+		}
+		key = strings.TrimSpace(out)
+	}
+	if strings.HasPrefix(key, "key::") {
+		return strings.TrimPrefix(key, "key::"), nil
+	}
+	if !strings.HasSuffix(key, ".pub") {
+		key += ".pub"
+	}
+	data, err := os.ReadFile(key)
+	if err != nil {
+		return "", fmt.Errorf("reading public key: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,103 @@
+//go:build !nogui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// signInWithMicrosoft runs the device flow from w and, once the user
+// approves, makes the session current and passes entraToken to onToken.
+func signInWithMicrosoft(a fyne.App, w fyne.Window, onToken func(token string), logMsg func(string)) {
+	clientID, tenant := entraConfig()
+	if clientID == "" {
+		dialog.ShowInformation("Sign in with Microsoft", "This build has no Entra ID application to sign in with. Set GITUI_ENTRA_CLIENT_ID to the client ID of a public client application with the Azure DevOps permission, or use a PAT.", w)
+		return
+	}
+	showDeviceFlow(a, w, "Microsoft",
+		func(ctx context.Context) (*deviceCode, error) { return requestEntraDeviceCode(ctx, clientID, tenant) },
+		func(ctx context.Context, code *deviceCode) (*oauthToken, error) {
+			return pollDeviceToken(ctx, "Microsoft Entra ID", entraEndpoint(tenant, "token"), clientID, code)
+		},
+		func(token *oauthToken) {
+			setEntraSession(newEntraSession(clientID, tenant, token))
+			onToken(entraToken)
+		}, logMsg)
+}
+
+// signInWithGitHub runs the device flow from w: it shows the code to enter
+// and where, opens the page, and passes the token to onToken once the
+// user approves. Closing the dialog stops waiting.
+func signInWithGitHub(a fyne.App, w fyne.Window, onToken func(token string), logMsg func(string)) {
+	clientID := deviceClientID()
+	if clientID == "" {
+		dialog.ShowInformation("Sign in with GitHub", "This build has no GitHub OAuth app to sign in with. Set GITUI_GITHUB_CLIENT_ID to the client ID of an OAuth app with device flow enabled, or use a PAT.", w)
+		return
+	}
+	showDeviceFlow(a, w, "GitHub",
+		func(ctx context.Context) (*deviceCode, error) { return requestDeviceCode(ctx, clientID) },
+		func(ctx context.Context, code *deviceCode) (*oauthToken, error) {
+			return pollDeviceToken(ctx, "GitHub", "https://github.com/login/oauth/access_token", clientID, code)
+		},
+		func(token *oauthToken) { onToken(token.AccessToken) }, logMsg)
+}
+
+// showDeviceFlow runs a device flow with service from w: it starts it, shows
+// the code to enter and where, opens the page, and passes the token poll
+// returns to onToken once the user approves. Closing the dialog stops
+// waiting.
+func showDeviceFlow(a fyne.App, w fyne.Window, service string, start func(ctx context.Context) (*deviceCode, error),
+	poll func(ctx context.Context, code *deviceCode) (*oauthToken, error), onToken func(*oauthToken), logMsg func(string)) {
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		code, err := start(ctx)
+		if err != nil {
+			cancel()
+			logMsg(fmt.Sprintf("Error: could not start signing in with %s: %v", service, err))
+			dialog.ShowError(err, w)
+			return
+		}
+		codeLabel := widget.NewLabelWithStyle(code.UserCode, fyne.TextAlignCenter, fyne.TextStyle{Bold: true, Monospace: true})
+		copyBtn := widget.NewButton("Copy Code", func() { w.Clipboard().SetContent(code.UserCode) })
+		openBtn := widget.NewButton("Open "+code.VerificationURI, func() {
+			if u, err := url.Parse(code.VerificationURI); err == nil {
+				a.OpenURL(u)
+			}
+		})
+		status := widget.NewLabel("Waiting for the code to be entered and the app approved...")
+		status.Wrapping = fyne.TextWrapWord
+		content := container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Enter this code on %s to sign in:", service)),
+			codeLabel,
+			container.NewGridWithColumns(2, copyBtn, openBtn),
+			status,
+		)
+		d := dialog.NewCustom("Sign in with "+service, "Cancel", content, w)
+		d.SetOnClosed(cancel)
+		d.Resize(fyne.NewSize(480, 0))
+		d.Show()
+		w.Clipboard().SetContent(code.UserCode)
+		openBtn.OnTapped()
+
+		token, err := poll(ctx, code)
+		if ctx.Err() != nil {
+			logMsg(fmt.Sprintf("Signing in with %s cancelled.", service))
+			return
+		}
+		d.Hide()
+		if err != nil {
+			logMsg(fmt.Sprintf("Error: signing in with %s failed: %v", service, err))
+			dialog.ShowError(fmt.Errorf("signing in with %s failed: %v", service, err), w)
+			return
+		}
+		logMsg(fmt.Sprintf("Signed in with %s.", service))
+		onToken(token)
+	}()
+}
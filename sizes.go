@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// GitHub's size field lags reality: a repository force-pushed or
+// un-archived recently can report 0 KB with content in it, and one whose
+// history was rewritten can report gigabytes it no longer has. Suspicious
+// sizes are cross-checked with ls-remote before the plan and runbook
+// decide anything by them, and once a clone of a repository has been
+// measured, in a past run or the deep scan, its measured size is used
+// instead.
+
+// Where a plan entry's size comes from, other than GitHub's figure.
+const (
+	sizeMeasured = "measured" // a clone of it was measured
+	sizeUnknown  = "unknown"  // GitHub's figure is wrong and no clone was measured
+)
+
+// suspectSize reports whether GitHub's figure of reportedKB, for a
+// repository whose default branch is defaultBranch, should be checked:
+// 0 KB while it has a default branch, or large enough that decisions hang
+// on it.
+func suspectSize(reportedKB int, defaultBranch string) bool {
+	return reportedKB == 0 && defaultBranch != "" || reportedKB >= largeRepoKB
+}
+
+// resolveSize decides the size to plan a repository with from GitHub's
+// figure and the number of refs ls-remote found in it, -1 if it could not
+// run. It returns the size, where it comes from ("" for GitHub's figure),
+// and a note explaining a departure from GitHub's figure.
+func resolveSize(reportedKB, refs int) (sizeKB int, from, note string) {
+	switch {
+	case refs < 0:
+		return reportedKB, "", ""
+	case reportedKB == 0 && refs > 0:
+		return 0, sizeUnknown, fmt.Sprintf("GitHub reports 0 KB but it has %d ref(s); its size is unknown until cloned", refs)
+	case reportedKB > 0 && refs == 0:
+		return 0, "", fmt.Sprintf("GitHub reports %s but it has no refs; it is empty", formatKB(reportedKB))
+	}
+	return reportedKB, "", ""
+}
+
+// measuredKB returns the size to plan with, in KB, of a clone measured at
+// measured bytes, and a note if GitHub's figure of reportedKB is far off.
+func measuredKB(reportedKB int, measured int64) (int, string) {
+	kb := int((measured + 1023) / 1024)
+	if reportedKB == 0 || kb > 2*reportedKB || reportedKB > 2*kb {
+		return kb, fmt.Sprintf("GitHub reports %s; its clone measured %s", formatKB(reportedKB), formatKB(kb))
+	}
+	return kb, ""
+}
+
+// countRemoteRefs counts the branches and tags of source (owner/name) with
+// ls-remote, which transfers no objects.
+func countRemoteRefs(ctx context.Context, source, token string) (int, error) {
+	out, err := runGit(ctx, nil, "ls-remote", "--heads", "--tags", fmt.Sprintf("https://%s@github.com/%s.git", token, source))
+	if err != nil {
+		return -1, fmt.Errorf("%v: %s", err, strings.ReplaceAll(lastLine(out), token, "***"))
+	}
+	n := 0
+	for ref := range parseRefs(out) {
+		if !strings.HasSuffix(ref, "^{}") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// measuredSizes returns the latest size of each repository's clone, by
+// source, that the run reports measured.
+func measuredSizes() map[string]int64 {
+	sizes := map[string]int64{}
+	reports, _ := listReports()
+	for _, r := range reports {
+		for _, repo := range r.Repos {
+			if repo.Bytes > 0 {
+				sizes[repo.Source] = repo.Bytes
+			}
+		}
+	}
+	return sizes
+}
+
+// checkSizes replaces GitHub's figure for the entries whose clones were
+// measured, and checks the suspicious ones of the rest with ls-remote,
+// if token is set. progress is told about each check.
+func (p *migrationPlan) checkSizes(ctx context.Context, token string, measured map[string]int64, progress func(string)) {
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		if m := measured[e.Source]; m > 0 {
+			kb, note := measuredKB(e.SizeKB, m)
+			e.SizeKB, e.SizeFrom = kb, sizeMeasured
+			if note != "" {
+				e.Notes = append(e.Notes, note)
+			}
+			continue
+		}
+		if token == "" || !suspectSize(e.SizeKB, e.DefaultBranch) {
+			continue
+		}
+		progress(fmt.Sprintf("Checking the size GitHub reports for %s (%s)...", e.Source, formatKB(e.SizeKB)))
+		refs, err := countRemoteRefs(ctx, e.Source, token)
+		if err != nil {
+			e.Notes = append(e.Notes, fmt.Sprintf("GitHub's size of %s could not be checked: %v", formatKB(e.SizeKB), err))
+		}
+		kb, from, note := resolveSize(e.SizeKB, refs)
+		e.SizeKB, e.SizeFrom = kb, from
+		if note != "" {
+			e.Notes = append(e.Notes, note)
+		}
+	}
+}
+
+// sizeText renders the entry's size for the plan and runbook.
+func (e planEntry) sizeText() string {
+	switch e.SizeFrom {
+	case sizeUnknown:
+		return "unknown"
+	case sizeMeasured:
+		return formatKB(e.SizeKB) + " (measured)"
+	}
+	return formatKB(e.SizeKB)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const hugeKB = 40 << 20 // 40 GB
+
+func TestSuspectSize(t *testing.T) {
+	tests := []struct {
+		kb            int
+		defaultBranch string
+		want          bool
+	}{
+		{0, "main", true},
+		{0, "", false}, // empty, and GitHub says so
+		{1, "main", false},
+		{largeRepoKB - 1, "main", false},
+		{largeRepoKB, "main", true},
+		{hugeKB, "", true},
+		{1<<31 - 1, "main", true},
+	}
+	for _, tt := range tests {
+		if got := suspectSize(tt.kb, tt.defaultBranch); got != tt.want {
+			t.Errorf("suspectSize(%d, %q) = %v, want %v", tt.kb, tt.defaultBranch, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSize(t *testing.T) {
+	tests := []struct {
+		kb, refs int
+		wantKB   int
+		wantFrom string
+		wantNote string
+	}{
+		{0, -1, 0, "", ""},
+		{hugeKB, -1, hugeKB, "", ""},
+		{0, 3, 0, sizeUnknown, "GitHub reports 0 KB but it has 3 ref(s); its size is unknown until cloned"},
+		{0, 0, 0, "", ""},
+		{hugeKB, 0, 0, "", "GitHub reports 40.0 GB but it has no refs; it is empty"},
+		{hugeKB, 12, hugeKB, "", ""},
+	}
+	for _, tt := range tests {
+		kb, from, note := resolveSize(tt.kb, tt.refs)
+		if kb != tt.wantKB || from != tt.wantFrom || note != tt.wantNote {
+			t.Errorf("resolveSize(%d, %d) = %d, %q, %q, want %d, %q, %q", tt.kb, tt.refs, kb, from, note, tt.wantKB, tt.wantFrom, tt.wantNote)
+		}
+	}
+}
+
+func TestMeasuredKB(t *testing.T) {
+	tests := []struct {
+		reportedKB int
+		measured   int64
+		wantKB     int
+		noted      bool
+	}{
+		{0, 1, 1, true},
+		{0, 5 << 30, 5 << 20, true},
+		{1000, 1000 * 1024, 1000, false},
+		{1000, 2001 * 1024, 2001, true},
+		{hugeKB, 1 << 20, 1024, true},
+		{hugeKB, 60 << 30, 60 << 20, false},
+	}
+	for _, tt := range tests {
+		kb, note := measuredKB(tt.reportedKB, tt.measured)
+		if kb != tt.wantKB || (note != "") != tt.noted {
+			t.Errorf("measuredKB(%d, %d) = %d, %q, want %d, noted %v", tt.reportedKB, tt.measured, kb, note, tt.wantKB, tt.noted)
+		}
+	}
+}
+
+// fakeGitHubRemotes makes git fetch https://github.com/owner/name.git from
+// dir/owner/name.git.
+func fakeGitHubRemotes(t *testing.T, dir string) {
+	t.Helper()
+	config := filepath.Join(t.TempDir(), "gitconfig")
+	for _, prefix := range []string{"https://github.com/", "https://ghp_test@github.com/"} {
+		if out, err := exec.Command("git", "config", "--file", config, "--add", "url."+dir+"/.insteadOf", prefix).CombinedOutput(); err != nil {
+			t.Fatalf("git config: %v\n%s", err, out)
+		}
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", config)
+}
+
+// Zero and huge sizes are checked against the repositories themselves
+// before the plan and runbook decide anything by them.
+func TestCheckSizes(t *testing.T) {
+	dir := t.TempDir()
+	fixture := func(name string, branches ...string) {
+		path := filepath.Join(dir, "acme", name+".git")
+		if len(branches) == 0 {
+			if out, err := exec.Command("git", "init", "-q", "--bare", path).CombinedOutput(); err != nil {
+				t.Fatalf("git init: %v\n%s", err, out)
+			}
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(testBareRepo(t, branches...), path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fixture("zero", "main", "release/2024")
+	fixture("huge-empty")
+	fixture("huge", "main")
+	fakeGitHubRemotes(t, dir)
+
+	p := &migrationPlan{Entries: []planEntry{
+		{Source: "acme/zero", SizeKB: 0, DefaultBranch: "main"},
+		{Source: "acme/blank", SizeKB: 0},
+		{Source: "acme/huge-empty", SizeKB: hugeKB, DefaultBranch: "main"},
+		{Source: "acme/huge", SizeKB: hugeKB, DefaultBranch: "main"},
+		{Source: "acme/missing", SizeKB: 0, DefaultBranch: "main"},
+		{Source: "acme/measured", SizeKB: hugeKB, DefaultBranch: "main"},
+		{Source: "acme/small", SizeKB: 12, DefaultBranch: "main"},
+	}}
+	var checked []string
+	p.checkSizes(t.Context(), "ghp_test", map[string]int64{"acme/measured": 3 << 20}, func(msg string) { checked = append(checked, msg) })
+	if len(checked) != 4 {
+		t.Errorf("%d sizes checked, want 4 (zero, huge-empty, huge, missing): %q", len(checked), checked)
+	}
+
+	tests := []struct {
+		sizeText string
+		precheck string // expected among the entry's prechecks
+		notLarge bool
+	}{
+		{"unknown", "size unknown, its estimate assumes an empty repository", true},
+		{"0 KB", "", true},
+		{"0 KB", "it has no refs; it is empty", true},
+		{"40.0 GB", "large repository (40.0 GB); schedule it early in the window", false},
+		{"0 KB", "could not be checked", true},
+		{"3.0 MB (measured)", "its clone measured 3.0 MB", true},
+		{"12 KB", "", true},
+	}
+	for i, tt := range tests {
+		e := p.Entries[i]
+		if got := e.sizeText(); got != tt.sizeText {
+			t.Errorf("%s: size %q, want %q", e.Source, got, tt.sizeText)
+		}
+		checks := strings.Join(e.prechecks(""), "\n")
+		if tt.precheck != "" && !strings.Contains(checks, tt.precheck) {
+			t.Errorf("%s: prechecks %q, want %q among them", e.Source, checks, tt.precheck)
+		}
+		if large := strings.Contains(checks, "large repository"); large == tt.notLarge {
+			t.Errorf("%s: large repository check %v, want %v", e.Source, large, !tt.notLarge)
+		}
+	}
+	if strings.Contains(strings.Join(p.Entries[4].Notes, " "), "ghp_test") {
+		t.Errorf("the token is in a note: %q", p.Entries[4].Notes)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// inhibitSleep keeps the system awake until release is called, using
+// caffeinate's idle-sleep assertion. -w ends it if gitui dies first.
+func inhibitSleep(reason string) (release func(), err error) {
+	cmd := exec.Command("caffeinate", "-i", "-w", strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
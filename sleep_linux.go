@@ -0,0 +1,26 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// inhibitSleep keeps the system awake until release is called, by holding a
+// logind sleep and idle inhibitor lock. The lock lives as long as the
+// systemd-inhibit process, which waits on its stdin.
+func inhibitSleep(reason string) (release func(), err error) {
+	cmd := exec.Command("systemd-inhibit", "--what=sleep:idle", "--who=gitui", "--why="+reason, "--mode=block", "cat")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("systemd-inhibit: %v", err)
+	}
+	return func() {
+		stdin.Close()
+		cmd.Wait()
+	}, nil
+}
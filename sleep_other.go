@@ -0,0 +1,10 @@
+//go:build !windows && !darwin && !linux
+
+package main
+
+import "errors"
+
+// inhibitSleep is not supported on this platform.
+func inhibitSleep(reason string) (release func(), err error) {
+	return nil, errors.New("preventing sleep is not supported on this platform")
+}
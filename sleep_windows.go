@@ -0,0 +1,40 @@
+//go:build windows
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+const (
+	esContinuous     = 0x80000000
+	esSystemRequired = 0x00000001
+)
+
+var setThreadExecutionState = syscall.NewLazyDLL("kernel32.dll").NewProc("SetThreadExecutionState")
+
+// inhibitSleep keeps the system awake until release is called. The
+// execution state belongs to a thread, so a locked goroutine holds it.
+func inhibitSleep(reason string) (release func(), err error) {
+	if err := setThreadExecutionState.Find(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	started := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if r, _, err := setThreadExecutionState.Call(esContinuous | esSystemRequired); r == 0 {
+			started <- err
+			return
+		}
+		started <- nil
+		<-done
+		setThreadExecutionState.Call(esContinuous)
+	}()
+	if err := <-started; err != nil {
+		return nil, err
+	}
+	return func() { close(done) }, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Splitting migrates parts of a monorepo into separate target repositories.
+// Each part is produced with git filter-repo, which REWRITES HISTORY: the
+// split repositories' commit SHAs differ from the source's.
+
+// splitRemainder, as a split part's subdirectory, stands for everything no
+// other part takes (the "legacy" remainder).
+const splitRemainder = "*"
+
+// splitPart maps a subdirectory of the source to a target repository.
+type splitPart struct {
+	Subdir string
+	Target string
+}
+
+// splitPlans holds the split plan of each source repository ("owner/repo").
+type splitPlans struct {
+	mu     sync.Mutex
+	byRepo map[string][]splitPart
+}
+
+// parseSplitPlans parses one part per line: "owner/repo subdir target",
+// with "*" as the subdirectory for the remainder. Blank lines and lines
+// starting with # are ignored.
+func parseSplitPlans(text string) (map[string][]splitPart, error) {
+	plans := map[string][]splitPart{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want \"owner/repo subdir target\", got %q", i+1, line)
+		}
+		repo, subdir, target := fields[0], strings.Trim(fields[1], "/"), fields[2]
+		if subdir == "" || strings.Contains(subdir, "..") {
+			return nil, fmt.Errorf("line %d: invalid subdirectory %q", i+1, fields[1])
+		}
+		plans[repo] = append(plans[repo], splitPart{Subdir: subdir, Target: target})
+	}
+	for repo, parts := range plans {
+		remainders := 0
+		for _, p := range parts {
+			if p.Subdir == splitRemainder {
+				remainders++
+			}
+		}
+		if remainders > 1 {
+			return nil, fmt.Errorf("%s: more than one remainder (*) target", repo)
+		}
+	}
+	return plans, nil
+}
+
+// Set replaces all plans.
+func (s *splitPlans) Set(plans map[string][]splitPart) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byRepo = plans
+}
+
+// Get returns repo's plan, or nil if it is migrated whole.
+func (s *splitPlans) Get(repo string) []splitPart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byRepo[repo]
+}
+
+// String renders the plans in the format parseSplitPlans reads.
+func (s *splitPlans) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var lines []string
+	for repo, parts := range s.byRepo {
+		for _, p := range parts {
+			lines = append(lines, fmt.Sprintf("%s %s %s", repo, p.Subdir, p.Target))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// splitReport is the outcome of one split part.
+type splitReport struct {
+	Source        string `json:"source"`
+	Subdir        string `json:"subdir"`
+	Target        string `json:"target"`
+	NameStrategy  string `json:"name_strategy,omitempty"` // the collision strategy that renamed it
+	SourceCommits int    `json:"source_commits"`          // commits touching Subdir in the source
+	SplitCommits  int    `json:"split_commits"`           // commits in the split repository
+	Verified      bool   `json:"verified"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// projectManifests are files that mark the root of a project.
+var projectManifests = map[string]bool{
+	"go.mod": true, "package.json": true, "pom.xml": true, "build.gradle": true,
+	"Cargo.toml": true, "pyproject.toml": true, "setup.py": true, "Gemfile": true,
+}
+
+// monorepoProjects returns the subdirectories of the clone's default branch
+// that look like separate projects: they contain a manifest, up to three
+// levels deep. Three or more suggest the repository is a monorepo.
+func monorepoProjects(ctx context.Context, dir string) []string {
+	out, err := runGit(ctx, nil, "-C", dir, "ls-tree", "-r", "--name-only", "HEAD")
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var projects []string
+	for _, file := range strings.Split(out, "\n") {
+		d, name := path.Split(file)
+		d = strings.TrimSuffix(d, "/")
+		if d == "" || strings.Count(d, "/") > 2 || !projectManifests[name] || seen[d] {
+			continue
+		}
+		seen[d] = true
+		projects = append(projects, d)
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+// commitCount counts the commits reachable from any ref of dir, only those
+// touching paths if given.
+func commitCount(ctx context.Context, dir string, paths ...string) (int, error) {
+	args := []string{"-C", dir, "rev-list", "--count", "--all"}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	out, err := runGit(ctx, nil, args...)
+	if err != nil {
+		return 0, fmt.Errorf("counting commits: %v", err)
+	}
+	return strconv.Atoi(strings.TrimSpace(out))
+}
+
+// filterRepoAvailable reports whether git filter-repo is installed.
+func filterRepoAvailable() bool {
+	_, err := runGit(context.Background(), nil, "filter-repo", "--version")
+	return err == nil
+}
+
+// splitClone copies the bare clone in dir and rewrites the copy's history to
+// part: the subdirectory becomes the root, or for the remainder, every other
+// part's subdirectory is removed. It returns the copy's directory.
+func splitClone(ctx context.Context, dir string, part splitPart, others []string, stream io.Writer) (string, error) {
+	splitDir, err := os.MkdirTemp("", "split-"+part.Target)
+	if err != nil {
+		return "", err
+	}
+	if output, err := runGit(ctx, stream, "clone", "--bare", "--no-local", dir, splitDir); err != nil {
+		os.RemoveAll(splitDir)
+		return "", fmt.Errorf("copying clone: %v, output: %s", err, output)
+	}
+
+	args := []string{"-C", splitDir, "filter-repo", "--force"}
+	if part.Subdir == splitRemainder {
+		args = append(args, "--invert-paths")
+		for _, o := range others {
+			args = append(args, "--path", o+"/")
+		}
+	} else {
+		args = append(args, "--subdirectory-filter", part.Subdir)
+	}
+	if output, err := runGit(ctx, stream, args...); err != nil {
+		os.RemoveAll(splitDir)
+		return "", fmt.Errorf("git filter-repo: %v, output: %s", err, output)
+	}
+	return splitDir, nil
+}
+
+// migrateSplit migrates each part of repo's split plan from the clone in
+// dir to its own target repository, with its own verification, and returns
+// a report per part.
+func migrateSplit(ctx context.Context, target targetProvider, dir, repo string, parts []splitPart, defaultBranch string, stream io.Writer, logMsg func(string)) []splitReport {
+	var subdirs []string
+	for _, p := range parts {
+		if p.Subdir != splitRemainder {
+			subdirs = append(subdirs, p.Subdir)
+		}
+	}
+
+	var reports []splitReport
+	for _, part := range parts {
+		r := splitReport{Source: repo, Subdir: part.Subdir, Target: part.Target, Status: statusFailed}
+		fail := func(err error) {
+			r.Error = err.Error()
+			logMsg(fmt.Sprintf("Error splitting %s/%s into %s: %v", repo, part.Subdir, part.Target, err))
+			reports = append(reports, r)
+		}
+		logMsg(fmt.Sprintf("Splitting %s: %s -> %s (rewriting history)", repo, part.Subdir, part.Target))
+
+		var err error
+		if part.Subdir == splitRemainder {
+			// Commits that touch anything outside the split subdirectories.
+			var exclude []string
+			for _, s := range subdirs {
+				exclude = append(exclude, ":(exclude)"+s)
+			}
+			r.SourceCommits, err = commitCount(ctx, dir, append([]string{"."}, exclude...)...)
+		} else {
+			r.SourceCommits, err = commitCount(ctx, dir, part.Subdir)
+		}
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		splitDir, err := splitClone(ctx, dir, part, subdirs, stream)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		r.SplitCommits, err = commitCount(ctx, splitDir)
+		if err != nil {
+			os.RemoveAll(splitDir)
+			fail(err)
+			continue
+		}
+
+		pushURL, err := target.CreateRepo(ctx, part.Target)
+		if err == nil {
+			_, err = runGit(ctx, stream, "-C", splitDir, "remote", "add", "target", pushURL)
+		}
+		if err == nil {
+			if _, output, perr := pushRefs(ctx, splitDir, "target", stream, "--all"); perr != nil {
+				err = fmt.Errorf("%v, output: %s", perr, output)
+			}
+		}
+		var tagFailures []refOutcome
+		if err == nil {
+			tagFailures, err = pushTags(ctx, splitDir, "target", stream)
+		}
+		if err != nil {
+			os.RemoveAll(splitDir)
+			fail(err)
+			continue
+		}
+
+		r.Verified = verifyStep(ctx, splitDir, "target", part.Target, failedRefs(tagFailures), logMsg)
+		if r.SplitCommits != r.SourceCommits {
+			// filter-repo drops commits left empty, so counts can differ
+			// around merges; report it rather than fail.
+			logMsg(fmt.Sprintf("Warning: %s has %d commits, %s of %s had %d", part.Target, r.SplitCommits, part.Subdir, repo, r.SourceCommits))
+			r.Verified = false
+		}
+		if defaultBranch != "" {
+			if err := target.SetDefaultBranch(ctx, part.Target, defaultBranch); err != nil {
+				logMsg(fmt.Sprintf("Warning: default branch for %s not set: %v", part.Target, err))
+			}
+		}
+		r.Status = statusMigrated
+		if !r.Verified {
+			r.Status = statusUnverified
+		}
+		logMsg(fmt.Sprintf("Split %s/%s into %s: %d of %d commits.", repo, part.Subdir, part.Target, r.SplitCommits, r.SourceCommits))
+		os.RemoveAll(splitDir)
+		reports = append(reports, r)
+	}
+	return reports
+}
@@ -0,0 +1,67 @@
+package main
+
+// Repository outcomes shown in the finished list.
+const (
+	statusFailed   = "failed"
+	statusMigrated = "migrated"
+	// statusWarnings means every branch landed but some tags did not;
+	// the report lists them.
+	statusWarnings = "migrated with warnings"
+	// statusUnverified means the push went through but the target's refs
+	// did not match the local copy.
+	statusUnverified = "migrated, verification failed"
+	// statusRetained means the user asked for the local copy to be deleted
+	// but verification did not pass, so it was kept for a cheap re-push.
+	statusRetained = "migrated, local copy retained pending verification"
+	// statusCleanedUp means the push was verified and the local copy
+	// deleted. A repository with refs that did not push stays at
+	// statusWarnings when its copy is deleted; its report entry's cleanup
+	// says so.
+	statusCleanedUp = "migrated and cleaned up"
+	// statusConfirmed means the repository's canary check, a while after
+	// it was verified, found its refs still as pushed.
+	statusConfirmed = "migrated, verified (confirmed)"
+	// statusLateFailure means the canary check found the refs changed or
+	// missing on the target after the repository had been verified.
+	statusLateFailure = "migrated, late verification failed"
+	// statusSecretsBlocked means the secret scan found something and the
+	// policy (or the user) stopped the migration before anything was pushed.
+	statusSecretsBlocked = "skipped, secrets found in history"
+	// statusSplit means the repository was migrated as several target
+	// repositories by its split plan, with rewritten history.
+	statusSplit = "migrated as split repositories (history rewritten)"
+	// statusSourceRemoved means the repository was listed at the start of
+	// the run but had been deleted on GitHub by the time it was cloned.
+	statusSourceRemoved = "source removed after planning"
+	// statusWontMigrate means someone decided after a run that the
+	// repository is not to be migrated; its report entry has the reason.
+	statusWontMigrate = "won't migrate"
+	// statusHeld means the repository is on hold (see holdsPath) and was
+	// not touched; its report entry has the hold's reason.
+	statusHeld = "on hold"
+	// statusLicenseBlocked means the repository's license is flagged by
+	// the license policy, and the gate blocked it or nobody confirmed it;
+	// its report entry's reason says which.
+	statusLicenseBlocked = "skipped, license policy"
+	// statusDryRun means the repository went through a dry run, which
+	// logged what would happen and changed nothing; its report entry's
+	// reason says if the target name is already taken.
+	statusDryRun = "dry run"
+	// statusCancelled means the run was cancelled before the repository
+	// finished; anything partial was cleaned up.
+	statusCancelled = "cancelled"
+)
+
+// Checkpoints of a repository still in progress, recorded in the
+// migration state (see recordCheckpoint) but never its final outcome. A
+// repository left at one was interrupted, by a crash or a lost connection,
+// and Resume migrates it again.
+const (
+	// statusPending means the repository is queued in a run that has not
+	// started it.
+	statusPending = "pending"
+	// statusCloned means the repository was cloned but not yet pushed.
+	statusCloned = "cloned"
+	// statusPushed means the repository was pushed but not yet verified.
+	statusPushed = "pushed"
+)
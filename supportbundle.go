@@ -0,0 +1,268 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// supportDir is where support bundles are written. Nothing is uploaded;
+// the user attaches the bundle to a bug report themselves.
+const supportDir = "support"
+
+// credentialPatterns find credentials in text for redaction: the secret
+// scan's rules, plus the forms tokens take in this tool's own files.
+var credentialPatterns = func() []secretRule {
+	rules := append([]secretRule{}, secretRules...)
+	return append(rules,
+		secretRule{"credentials in URL", regexp.MustCompile(`(?i)(https?://)[^/@\s:]+(:[^/@\s]*)?@`)},
+		secretRule{"Authorization header", regexp.MustCompile(`(?i)(authorization:\s*(basic|bearer|token)\s+)\S+`)},
+		secretRule{"access token parameter", regexp.MustCompile(`(?i)((access_)?token=)[^&\s"]+`)},
+	)
+}()
+
+// redactText replaces credentials in text: the known tokens verbatim, and
+// anything credentialPatterns match.
+func redactText(text string, known []string) string {
+	for _, k := range known {
+		if len(k) >= 8 {
+			text = strings.ReplaceAll(text, k, "***")
+		}
+	}
+	for _, r := range credentialPatterns {
+		text = r.Pattern.ReplaceAllStringFunc(text, func(m string) string {
+			// Keep the prefix the pattern captured, such as "https://".
+			if sub := r.Pattern.FindStringSubmatch(m); len(sub) > 1 && sub[1] != "" {
+				if r.Name == "credentials in URL" {
+					return sub[1] + "***@"
+				}
+				return sub[1] + "***"
+			}
+			return "***"
+		})
+	}
+	return text
+}
+
+var (
+	secretsMu         sync.Mutex
+	registeredSecrets []string
+)
+
+// registerSecret adds secret to what redactLog redacts. Tokens are
+// registered as they are entered, signed in with or handed to git.
+func registerSecret(secret string) {
+	if len(secret) < 8 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if !containsString(registeredSecrets, secret) {
+		registeredSecrets = append(registeredSecrets, secret)
+	}
+}
+
+// redactLog returns msg with the registered secrets, extra, and anything
+// credentialPatterns match, redacted. Every log line goes through it on
+// its way to the window, a log file or the terminal.
+func redactLog(msg string, extra ...string) string {
+	secretsMu.Lock()
+	known := append(extra, registeredSecrets...)
+	secretsMu.Unlock()
+	return redactText(msg, known)
+}
+
+// leakedCredentials returns the kinds of credential left in text, if any.
+func leakedCredentials(text string, known []string) []string {
+	var found []string
+	for _, k := range known {
+		if len(k) >= 8 && strings.Contains(text, k) {
+			found = append(found, "a token entered in the app")
+			break
+		}
+	}
+	for _, r := range credentialPatterns {
+		for _, m := range r.Pattern.FindAllString(text, -1) {
+			if !strings.Contains(m, "***") {
+				found = append(found, r.Name)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// toolVersion describes the build: module version, commit and Go version.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "build info unavailable (" + runtime.Version() + ")"
+	}
+	lines := []string{
+		fmt.Sprintf("module: %s %s", info.Main.Path, info.Main.Version),
+		"go: " + info.GoVersion,
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH", "CGO_ENABLED":
+			lines = append(lines, fmt.Sprintf("%s: %s", s.Key, s.Value))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// environmentCheck reports what the migration depends on: the platform,
+// git and its extensions, and the settings taken from the environment.
+// Values of variables that look like credentials are not included.
+func environmentCheck() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "os: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	for _, check := range []struct {
+		name string
+		args []string
+	}{
+		{"git", []string{"version"}},
+		{"git-lfs", []string{"lfs", "version"}},
+		{"git-filter-repo", []string{"filter-repo", "--version"}},
+	} {
+		out, err := runGit(context.Background(), nil, check.args...)
+		if err != nil {
+			fmt.Fprintf(&b, "%s: not available (%v)\n", check.name, err)
+		} else {
+			fmt.Fprintf(&b, "%s: %s\n", check.name, strings.TrimSpace(lastLine(out)))
+		}
+	}
+	fmt.Fprintf(&b, "transfers: %s\n", transfers.Describe())
+	fmt.Fprintf(&b, "read-only mode: %t\n", isReadOnly())
+
+	var names []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		upper := strings.ToUpper(name)
+		if strings.HasPrefix(upper, "GITUI_") || strings.HasSuffix(upper, "_PROXY") || upper == "GITHUB_PAT" || upper == "ADO_PAT" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := os.Getenv(name)
+		upper := strings.ToUpper(name)
+		if strings.Contains(upper, "TOKEN") || strings.Contains(upper, "PAT") || strings.Contains(upper, "SECRET") || strings.Contains(upper, "KEY") {
+			value = "(set)"
+		}
+		fmt.Fprintf(&b, "env %s=%s\n", name, value)
+	}
+	return b.String()
+}
+
+// supportInputs is what goes into a support bundle besides the files the
+// tool keeps in the working directory.
+type supportInputs struct {
+	LogFiles []string // log files written during runs
+	Profile  string   // the window's settings, without credentials
+	Tokens   []string // credentials entered in the app, to redact
+	Checks   []string // results of checks against the configured services
+}
+
+// createSupportBundle zips, with credentials redacted, the log files, an
+// environment check, the build info, the profile, the last run's report
+// and MIGRATION.md, the plan config, the creation record and the audit
+// log. The archive is scanned once written; if a credential is found in
+// it, it is deleted and an error returned. It returns the bundle's path.
+func createSupportBundle(in supportInputs) (string, error) {
+	files := map[string]string{
+		"environment.txt": environmentCheck() + strings.Join(append(in.Checks, ""), "\n"),
+		"version.txt":     toolVersion(),
+		"profile.txt":     in.Profile,
+	}
+	add := func(name, path string) {
+		data, err := readFileRecover(path, func([]byte) error { return nil })
+		if err == nil {
+			files[name] = string(data)
+		}
+	}
+	for _, path := range in.LogFiles {
+		add("logs/"+filepath.Base(path), path)
+	}
+	if reports, err := listReports(); err == nil && len(reports) > 0 {
+		last := reports[len(reports)-1]
+		add("last-run/report.json", filepath.Join(reportsDir, last.ID+".json"))
+		if last.MigrationDoc != "" {
+			add("last-run/MIGRATION.md", last.MigrationDoc)
+		}
+	}
+	add("plan/migrate.yaml", "migrate.yaml")
+	add("state/created-repos.jsonl", createdReposPath)
+	add("audit.log", auditLogPath)
+
+	if err := os.MkdirAll(supportDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(supportDir, fmt.Sprintf("gitui-support-%s.zip", time.Now().UTC().Format("20060102T150405Z")))
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(f, redactText(files[name], in.Tokens))
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+
+	if leaks, err := scanSupportBundle(path, in.Tokens); err != nil || len(leaks) > 0 {
+		os.Remove(path)
+		if err != nil {
+			return "", fmt.Errorf("checking the bundle: %v", err)
+		}
+		return "", fmt.Errorf("the bundle still contained credentials (%s) and was deleted", strings.Join(leaks, "; "))
+	}
+	return path, nil
+}
+
+// scanSupportBundle reads back every file of the bundle and returns the
+// credentials found, as "file: kind".
+func scanSupportBundle(path string, known []string) ([]string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var leaks []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range leakedCredentials(string(data), known) {
+			leaks = append(leaks, fmt.Sprintf("%s: %s", f.Name, kind))
+		}
+	}
+	return leaks, nil
+}
@@ -0,0 +1,253 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// tailLines is how many lines of git output are kept per repository.
+const tailLines = 500
+
+// tailBuffer keeps the last max lines added to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+}
+
+func (b *tailBuffer) Add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > b.max {
+		b.lines = b.lines[len(b.lines)-b.max:]
+	}
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Join(b.lines, "\n")
+}
+
+func (b *tailBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.lines)
+}
+
+// tailView shows a tab per in-flight repository with its live git output,
+// separate from the global log. When a repository finishes its tab is closed
+// and the captured tail stays viewable from the finished list.
+type tailView struct {
+	window fyne.Window
+	ui     *uiBatcher
+	tabs   *container.AppTabs
+
+	mu       sync.Mutex
+	finished []*repoTail
+	list     *widget.List
+
+	// How the git transfers streaming to the tabs are watched for signs
+	// of life, and where their state goes; see SetLiveness.
+	liveness   livenessConfig
+	onTransfer func(repo, state string)
+	onStall    func(repo, state string)
+}
+
+// repoTail is the live output of one repository. It is an io.Writer so it
+// can be handed straight to runGit.
+type repoTail struct {
+	view   *tailView
+	repo   string
+	status string
+	buf    *tailBuffer
+	lines  *lineWriter
+	entry  *widget.Entry
+	tab    *container.TabItem
+
+	mu     sync.Mutex
+	paused bool
+	live   *transferLiveness // the git transfer being watched, if any
+}
+
+func newTailView(w fyne.Window, ui *uiBatcher) *tailView {
+	v := &tailView{window: w, ui: ui, tabs: container.NewAppTabs()}
+	v.list = widget.NewList(
+		func() int {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			return len(v.finished)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			v.mu.Lock()
+			t := v.finished[id]
+			v.mu.Unlock()
+			o.(*widget.Label).SetText(fmt.Sprintf("%s — %s (%d lines)", t.repo, t.status, t.buf.Len()))
+		},
+	)
+	v.list.OnSelected = func(id widget.ListItemID) {
+		v.mu.Lock()
+		t := v.finished[id]
+		v.mu.Unlock()
+		v.showFinished(t)
+		v.list.UnselectAll()
+	}
+	return v
+}
+
+// Content returns the widget tree for the view.
+func (v *tailView) Content() fyne.CanvasObject {
+	finished := container.NewBorder(widget.NewLabel("Finished (select to view output):"), nil, nil, nil, v.list)
+	split := container.NewVSplit(v.tabs, finished)
+	split.Offset = 0.7
+	return split
+}
+
+// Start opens a live tab for repo.
+func (v *tailView) Start(repo string) *repoTail {
+	t := &repoTail{view: v, repo: repo, buf: &tailBuffer{max: tailLines}}
+	t.lines = &lineWriter{fn: t.addLine}
+
+	t.entry = widget.NewMultiLineEntry()
+	t.entry.Wrapping = fyne.TextWrapOff
+	t.entry.Disable() // make read-only
+
+	pause := widget.NewCheck("Pause scroll", func(checked bool) {
+		t.mu.Lock()
+		t.paused = checked
+		t.mu.Unlock()
+		if !checked {
+			t.refresh()
+		}
+	})
+	copyBtn := widget.NewButton("Copy", func() {
+		v.window.Clipboard().SetContent(t.buf.String())
+	})
+
+	t.tab = container.NewTabItem(repo, container.NewBorder(nil, container.NewHBox(pause, copyBtn), nil, nil, t.entry))
+	v.tabs.Append(t.tab)
+	return t
+}
+
+// SetLiveness watches the git commands streaming to the tabs from now on
+// for signs of life with config. onTransfer gets a repository's transfer
+// state whenever it changes, "" once the command exits; onStall gets it
+// when the transfer stalls.
+func (v *tailView) SetLiveness(config livenessConfig, onTransfer, onStall func(repo, state string)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.liveness, v.onTransfer, v.onStall = config, onTransfer, onStall
+}
+
+// Watch watches the git process pid, streaming to t and working in dir,
+// for signs of life; see watchLiveness.
+func (t *repoTail) Watch(pid int, dir string) (stop func()) {
+	v := t.view
+	v.mu.Lock()
+	config, onTransfer, onStall := v.liveness, v.onTransfer, v.onStall
+	v.mu.Unlock()
+	if onTransfer == nil {
+		return func() {}
+	}
+	l := newTransferLiveness(config, time.Now(), transferCounter(pid, dir))
+	t.mu.Lock()
+	t.live = l
+	t.mu.Unlock()
+	stopWatching := watchLiveness(config, pid, dir, l,
+		func(state string) { onTransfer(t.repo, state) },
+		func(state string) { onStall(t.repo, state) })
+	return func() {
+		stopWatching()
+		t.mu.Lock()
+		t.live = nil
+		t.mu.Unlock()
+		onTransfer(t.repo, "")
+	}
+}
+
+func (t *repoTail) Write(p []byte) (int, error) {
+	return t.lines.Write(p)
+}
+
+func (t *repoTail) addLine(line string) {
+	line = redactLog(line)
+	t.buf.Add(line)
+	if gist, ok := parseProgressLine(line); ok {
+		t.mu.Lock()
+		live := t.live
+		t.mu.Unlock()
+		if live != nil {
+			live.Progress(time.Now(), gist)
+		}
+	}
+	t.refresh()
+}
+
+// refresh schedules copying the buffer into the tab and scrolling to the
+// end, unless the user paused scrolling.
+func (t *repoTail) refresh() {
+	t.mu.Lock()
+	paused := t.paused
+	t.mu.Unlock()
+	if paused {
+		return
+	}
+	t.view.ui.Schedule(t, func() {
+		t.entry.SetText(t.buf.String())
+		t.entry.CursorRow = t.buf.Len()
+		t.entry.Refresh()
+	})
+}
+
+// Finish closes the repository's live tab and moves its tail into the
+// finished list with the given status.
+func (t *repoTail) Finish(status string) {
+	// Flush a trailing partial line.
+	t.lines.Write([]byte("\n"))
+	t.status = status
+
+	v := t.view
+	v.tabs.Remove(t.tab)
+	v.mu.Lock()
+	v.finished = append(v.finished, t)
+	v.mu.Unlock()
+	v.ui.Schedule(v.list, v.list.Refresh)
+}
+
+// showFinished displays the captured tail of a finished repository.
+func (v *tailView) showFinished(t *repoTail) {
+	entry := widget.NewMultiLineEntry()
+	entry.SetText(t.buf.String())
+	entry.Disable()
+	copyBtn := widget.NewButton("Copy", func() {
+		v.window.Clipboard().SetContent(t.buf.String())
+	})
+	d := dialog.NewCustom(fmt.Sprintf("%s — %s", t.repo, t.status), "Close",
+		container.NewBorder(nil, copyBtn, nil, nil, entry), v.window)
+	d.Resize(fyne.NewSize(700, 450))
+	d.Show()
+}
+
+// Output returns the captured tail of repo's latest finished migration,
+// and false if it has none this session.
+func (v *tailView) Output(repo string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := len(v.finished) - 1; i >= 0; i-- {
+		if v.finished[i].repo == repo {
+			return v.finished[i].buf.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// targetProvider is a git hosting service that repositories are migrated
+// into.
+type targetProvider interface {
+	// Name is the provider's display name.
+	Name() string
+	// CreateRepo creates repository name and returns an authenticated URL
+	// to push to.
+	CreateRepo(ctx context.Context, name string) (string, error)
+	// SetDefaultBranch sets the repository's default branch. branch is a
+	// branch name such as "trunk" or "release/2024".
+	SetDefaultBranch(ctx context.Context, name, branch string) error
+	// MakeReadOnly applies mode to a repository that is archived on GitHub
+	// and describes the state that was applied.
+	MakeReadOnly(ctx context.Context, name string, mode archiveMode) (string, error)
+}
+
+// repoShortName returns the name part of an "owner/name" repository, which
+// is what the target repository is called.
+func repoShortName(fullName string) string {
+	return fullName[strings.LastIndex(fullName, "/")+1:]
+}
+
+// disambiguatedName returns the target name for source (owner/name) when
+// its name is already taken, case-insensitively, as ADO compares: the name
+// with the owner appended, or with a number if that is taken too.
+func disambiguatedName(source string, taken map[string]bool) string {
+	name := repoShortName(source)
+	owner := strings.TrimSuffix(source, "/"+name)
+	candidate := name + "-" + owner
+	for n := 2; taken[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s-%d", name, n)
+	}
+	return candidate
+}
+
+// finalRepoName returns the repository name as the target reports it in
+// pushURL, which may differ in case from the requested name; requested if
+// the URL does not end in it.
+func finalRepoName(pushURL, requested string) string {
+	u, err := url.Parse(pushURL)
+	if err != nil {
+		return requested
+	}
+	name := strings.TrimSuffix(path.Base(u.Path), ".git")
+	if strings.EqualFold(name, requested) {
+		return name
+	}
+	return requested
+}
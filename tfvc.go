@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// projectSourceControl is the version control an ADO project uses.
+type projectSourceControl struct {
+	Type     string // the type the project was created with, "Git" or "Tfvc"
+	GitRepos int    // Git repositories the project has
+}
+
+// GitEnabled reports whether repositories can be migrated into the
+// project: it is a Git project, or a TFVC project that has Git
+// repositories too.
+func (p projectSourceControl) GitEnabled() bool {
+	return !strings.EqualFold(p.Type, "Tfvc") || p.GitRepos > 0
+}
+
+func (p projectSourceControl) String() string {
+	switch {
+	case !strings.EqualFold(p.Type, "Tfvc"):
+		return fmt.Sprintf("%s, Git repositories: %d", orDefault(p.Type, "Git"), p.GitRepos)
+	case p.GitRepos > 0:
+		return fmt.Sprintf("TFVC, Git repositories: %d", p.GitRepos)
+	default:
+		return "TFVC only"
+	}
+}
+
+// getProjectSourceControl looks up the project's version control type from
+// its capabilities, and counts its Git repositories.
+func getProjectSourceControl(ctx context.Context, org, project, token string) (projectSourceControl, error) {
+	var p struct {
+		Capabilities struct {
+			VersionControl struct {
+				SourceControlType string `json:"sourceControlType"`
+			} `json:"versioncontrol"`
+		} `json:"capabilities"`
+	}
+	apiURL := fmt.Sprintf("%s/_apis/projects/%s?includeCapabilities=true&api-version=7.0", org, url.PathEscape(project))
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &p); err != nil {
+		return projectSourceControl{}, fmt.Errorf("looking up project: %v", err)
+	}
+	sc := projectSourceControl{Type: p.Capabilities.VersionControl.SourceControlType}
+	repos, err := listAzureRepos(ctx, org, project, token)
+	if err != nil {
+		return sc, fmt.Errorf("listing Git repositories: %v", err)
+	}
+	sc.GitRepos = len(repos)
+	return sc, nil
+}
+
+// check returns the error that stops a run into project if Git is not
+// enabled in it, where every repository creation would fail on its own.
+func (p projectSourceControl) check(project string) error {
+	if p.GitEnabled() {
+		return nil
+	}
+	return fmt.Errorf("project %s uses TFVC; create a Git-enabled project or enable Git repos (Project settings > Repositories) and run again", project)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// logClock stamps on-screen log lines. It prints the time of day only, and
+// adds the date on the first line and whenever the day rolls over, so an
+// overnight run's log stays unambiguous. In compact mode the date is never
+// printed.
+type logClock struct {
+	mu      sync.Mutex
+	utc     bool
+	compact bool
+	lastDay string
+}
+
+// SetUTC switches the on-screen stamps between local time and UTC.
+func (c *logClock) SetUTC(utc bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.utc != utc {
+		c.utc = utc
+		// Force the date to be printed again so the switch is visible.
+		c.lastDay = ""
+	}
+}
+
+// SetCompact switches date printing off (or back on).
+func (c *logClock) SetCompact(compact bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.compact = compact
+	c.lastDay = ""
+}
+
+// Stamp returns the UI timestamp for t.
+func (c *logClock) Stamp(t time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zone := ""
+	if c.utc {
+		t = t.UTC()
+		zone = "Z"
+	}
+	day := t.Format("2006-01-02")
+	if day != c.lastDay && !c.compact {
+		c.lastDay = day
+		return t.Format("2006-01-02 15:04:05") + zone
+	}
+	return t.Format("15:04:05") + zone
+}
+
+// fileTimestamp formats t for log files and reports: RFC3339 in UTC, which
+// lines up with the ADO audit log.
+func fileTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatDuration renders d for humans, e.g. "1h 23m 45s" or "850ms".
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh %dm %ds", h, m, s)
+	case m > 0:
+		return fmt.Sprintf("%dm %ds", m, s)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
+
+// zoneSummary describes the local zone and its UTC offset at t, for run and
+// report headers, e.g. "local zone CEST (UTC+02:00), started 2024-06-01T20:00:00Z".
+func zoneSummary(t time.Time) string {
+	name, _ := t.Zone()
+	return fmt.Sprintf("local zone %s (UTC%s), started %s", name, t.Format("-07:00"), fileTimestamp(t))
+}
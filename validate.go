@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// preflightCheck is one check of Validate: the inputs are tried against
+// GitHub, the target and the local git before a run touches anything.
+type preflightCheck struct {
+	Name   string
+	Detail string // what was found
+	Err    error  // why the check failed; nil if it passed
+}
+
+func (c preflightCheck) String() string {
+	if c.Err != nil {
+		return fmt.Sprintf("%s: FAILED: %v", c.Name, c.Err)
+	}
+	return fmt.Sprintf("%s: OK, %s", c.Name, c.Detail)
+}
+
+// gitHubTokenScopes looks up the token's user and the OAuth scopes GitHub
+// reports for it. classic is false for fine-grained tokens, which have
+// per-repository permissions instead of scopes.
+func gitHubTokenScopes(ctx context.Context, token string) (login string, scopes []string, classic bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user", nil)
+	if err != nil {
+		return "", nil, false, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError("GitHub", resp)
+		apiErr.RateLimitReset = gitHubRateLimitReset(resp)
+		return "", nil, false, apiErr
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", nil, false, err
+	}
+	header, classic := resp.Header["X-Oauth-Scopes"]
+	if classic && len(header) > 0 {
+		for _, s := range strings.Split(header[0], ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return user.Login, scopes, classic, nil
+}
+
+// checkGitHubToken checks that the token authenticates, and reports whose
+// it is and its scopes. It also returns the login, "" if the check failed.
+func checkGitHubToken(ctx context.Context, token string) (preflightCheck, string) {
+	c := preflightCheck{Name: "GitHub token"}
+	if token == "" {
+		c.Err = errors.New("the GitHub PAT is empty")
+		return c, ""
+	}
+	login, scopes, classic, err := gitHubTokenScopes(ctx, token)
+	switch {
+	case err != nil:
+		c.Err = err
+	case !classic:
+		c.Detail = fmt.Sprintf("authenticated as %s with a fine-grained token (permissions are per repository; it needs Contents read access)", login)
+	case len(scopes) == 0:
+		c.Detail = fmt.Sprintf("authenticated as %s, no scopes: only public repositories can be cloned", login)
+	default:
+		c.Detail = fmt.Sprintf("authenticated as %s, scopes: %s", login, strings.Join(scopes, ", "))
+		if !containsString(scopes, "repo") {
+			c.Detail += " (without repo, private repositories cannot be cloned)"
+		}
+	}
+	return c, login
+}
+
+// checkAzureProject checks that the project exists and the token can list
+// its repositories.
+func checkAzureProject(ctx context.Context, org, project, token string) preflightCheck {
+	c := preflightCheck{Name: "Azure DevOps project"}
+	if token == "" || org == "" || project == "" {
+		c.Err = errors.New("the Azure PAT, organization and project are required")
+		return c
+	}
+	repos, err := listAzureRepos(ctx, org, project, token)
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		c.Err = fmt.Errorf("project %s does not exist in %s, or the token cannot see it", project, org)
+	case err != nil:
+		c.Err = err
+	default:
+		c.Detail = fmt.Sprintf("project %s exists and the token can list its %d repositories", project, len(repos))
+	}
+	return c
+}
+
+// checkGit checks that git is on PATH and runs.
+func checkGit() preflightCheck {
+	c := preflightCheck{Name: "git"}
+	output, err := runGit(context.Background(), nil, "version")
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		c.Err = errors.New("git is not on PATH; install it or add it to PATH")
+	case err != nil:
+		c.Err = fmt.Errorf("git version: %v, output: %s", err, strings.TrimSpace(output))
+	default:
+		c.Detail = strings.TrimSpace(output)
+	}
+	return c
+}
+
+// firstFailure returns the first failed check, or nil if all passed.
+func firstFailure(checks []preflightCheck) *preflightCheck {
+	for i := range checks {
+		if checks[i].Err != nil {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+// targetRepoNames maps the lower-cased names of repos to the names as the
+// target spells them; target names are unique case-insensitively.
+func targetRepoNames(repos []azureRepo) map[string]string {
+	names := map[string]string{}
+	for _, r := range repos {
+		names[strings.ToLower(r.Name)] = r.Name
+	}
+	return names
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseRefs parses "<sha> <ref>" lines (for-each-ref or ls-remote output,
+// which separates with a tab) into a ref -> sha map. Peeled tag entries
+// ("^{}") are skipped so annotated tags compare by tag object on both sides.
+func parseRefs(output string) map[string]string {
+	refs := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasSuffix(fields[1], "^{}") {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	return refs
+}
+
+// localRefs lists the branches and tags of the clone in dir.
+func localRefs(ctx context.Context, dir string) (map[string]string, error) {
+	out, err := runGit(ctx, nil, "-C", dir, "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads", "refs/tags")
+	if err != nil {
+		return nil, fmt.Errorf("listing local refs: %v, output: %s", err, out)
+	}
+	return parseRefs(out), nil
+}
+
+// remoteRefs lists the branches and tags remote reports.
+func remoteRefs(ctx context.Context, dir, remote string) (map[string]string, error) {
+	out, err := runGit(ctx, nil, lsRemoteArgs(dir, remote)...)
+	if err != nil {
+		return nil, fmt.Errorf("listing refs of %s: %v", remote, err)
+	}
+	return parseRefs(out), nil
+}
+
+// verifyPush compares the branches and tags of the clone in dir with what
+// remote reports after a push, except the refs in skip, which are already
+// known not to have pushed. It returns one line per difference; an empty
+// result means every ref arrived with the same SHA.
+func verifyPush(ctx context.Context, dir, remote string, skip []string) ([]string, error) {
+	local, err := localRefs(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	pushed, err := remoteRefs(ctx, dir, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range skip {
+		delete(local, ref)
+	}
+	return checkPushedRefs(local, pushed), nil
+}
+
+// checkPushedRefs compares the refs expected on the target with those it
+// reports, pushed. It returns one line per difference, sorted.
+func checkPushedRefs(expected, pushed map[string]string) []string {
+	var problems []string
+	for ref, sha := range expected {
+		got, ok := pushed[ref]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s missing on target", ref))
+		case got != sha:
+			problems = append(problems, fmt.Sprintf("%s is %s on target, expected %s", ref, got, sha))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// verifyStep runs verifyPush and logs the outcome. It reports whether the
+// push was verified.
+func verifyStep(ctx context.Context, dir, remote, repo string, skip []string, logMsg func(string)) bool {
+	problems, err := verifyPush(ctx, dir, remote, skip)
+	if err != nil {
+		logMsg(fmt.Sprintf("Verification of %s could not run: %v", repo, err))
+		return false
+	}
+	if len(problems) > 0 {
+		logMsg(fmt.Sprintf("Verification of %s failed, %d ref(s) differ:", repo, len(problems)))
+		for _, p := range problems {
+			logMsg("  " + p)
+		}
+		return false
+	}
+	if len(skip) > 0 {
+		logMsg(fmt.Sprintf("Verified all branches and tags of %s except the %d that did not push.", repo, len(skip)))
+		return true
+	}
+	logMsg(fmt.Sprintf("Verified all branches and tags of %s.", repo))
+	return true
+}
+
+// verifyAgainstSource compares the branches and tags the source (remote
+// source of the clone in dir) reports now with those target reports. The
+// clone is only as current as the moment it was taken, so a branch pushed
+// to the source since, which verifyPush cannot see, shows up here. Refs
+// leftOut reports were not to be migrated are not compared. It returns one
+// line per difference.
+func verifyAgainstSource(ctx context.Context, dir, source, target string, leftOut func(ref string) bool) ([]string, error) {
+	sourceRefs, err := remoteRefs(ctx, dir, source)
+	if err != nil {
+		return nil, err
+	}
+	targetRefs, err := remoteRefs(ctx, dir, target)
+	if err != nil {
+		return nil, err
+	}
+	return checkSourceRefs(sourceRefs, targetRefs, leftOut), nil
+}
+
+// checkSourceRefs compares the source's refs with the target's, both as
+// ls-remote reports them, except those leftOut reports. It returns one
+// line per difference, sorted.
+func checkSourceRefs(source, target map[string]string, leftOut func(ref string) bool) []string {
+	var problems []string
+	for ref, sha := range source {
+		if leftOut(ref) {
+			continue
+		}
+		got, ok := target[ref]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s missing on target, %s on source", ref, sha))
+		case got != sha:
+			problems = append(problems, fmt.Sprintf("%s is %s on target, %s on source", ref, got, sha))
+		}
+	}
+	for ref, sha := range target {
+		if _, ok := source[ref]; !ok && !leftOut(ref) {
+			problems = append(problems, fmt.Sprintf("%s is %s on target but no longer on source", ref, sha))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// sourceVerifyStep runs verifyAgainstSource and logs the outcome. It
+// returns the differences, and false if the comparison could not run,
+// which is a warning: the push itself was verified by verifyStep.
+func sourceVerifyStep(ctx context.Context, dir, source, target, repo string, leftOut func(ref string) bool, logMsg func(string)) ([]string, bool) {
+	problems, err := verifyAgainstSource(ctx, dir, source, target, leftOut)
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not compare the refs of %s with its source: %v", repo, err))
+		return nil, false
+	}
+	if len(problems) > 0 {
+		logMsg(fmt.Sprintf("The refs of %s on the target differ from its source, %d ref(s):", repo, len(problems)))
+		for _, p := range problems {
+			logMsg("  " + p)
+		}
+		return problems, true
+	}
+	logMsg(fmt.Sprintf("Every branch and tag of %s on the target matches its source.", repo))
+	return nil, true
+}
+
+// refStats summarizes one side of a migrated repository in the terms
+// stakeholders check: how much is there and how recent it is.
+type refStats struct {
+	Branches             int    `json:"branches"`
+	Tags                 int    `json:"tags"`
+	DefaultBranchCommits int    `json:"default_branch_commits"`
+	LatestCommit         string `json:"latest_commit,omitempty"` // committer date, RFC3339
+}
+
+// repoComparison sets the source's stats beside the target's.
+type repoComparison struct {
+	DefaultBranch string   `json:"default_branch"`
+	Source        refStats `json:"source"`
+	Target        refStats `json:"target"`
+	// Mismatches are differences not explained by the refs known not to
+	// have pushed.
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// verifyRefPrefix is where compareWithTarget fetches the target's refs to,
+// removed again when it is done.
+const verifyRefPrefix = "refs/verify/"
+
+// collectRefStats computes the stats of the refs under prefix ("refs/" for
+// the clone's own) of the clone in dir.
+func collectRefStats(ctx context.Context, dir, prefix, defaultBranch string) (refStats, error) {
+	var s refStats
+	for _, kind := range []string{"heads", "tags"} {
+		out, err := runGit(ctx, nil, "-C", dir, "for-each-ref", "--format=%(refname)", prefix+kind)
+		if err != nil {
+			return s, fmt.Errorf("listing %s: %v", prefix+kind, err)
+		}
+		n := len(strings.Fields(out))
+		if kind == "heads" {
+			s.Branches = n
+		} else {
+			s.Tags = n
+		}
+	}
+	if defaultBranch != "" {
+		if out, err := runGit(ctx, nil, "-C", dir, "rev-list", "--count", prefix+"heads/"+defaultBranch, "--"); err == nil {
+			s.DefaultBranchCommits, _ = strconv.Atoi(strings.TrimSpace(out))
+		}
+	}
+	out, err := runGit(ctx, nil, "-C", dir, "for-each-ref", "--sort=-committerdate", "--count=1", "--format=%(committerdate:iso-strict)", prefix+"heads")
+	if err == nil {
+		s.LatestCommit = strings.TrimSpace(out)
+	}
+	return s, nil
+}
+
+// compareWithTarget fetches the target's branches and tags into the clone
+// in dir (cheap, the objects are already there) and compares their stats
+// with the clone's. Refs in skip are expected to be missing on the target.
+func compareWithTarget(ctx context.Context, dir, remote string, skip []string, stream io.Writer) (*repoComparison, error) {
+	c := &repoComparison{}
+	if out, err := runGit(ctx, nil, headArgs(dir)...); err == nil {
+		c.DefaultBranch = strings.TrimSpace(out)
+	}
+	var err error
+	if c.Source, err = collectRefStats(ctx, dir, "refs/", c.DefaultBranch); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		// Leave the clone as it was.
+		out, _ := runGit(ctx, nil, "-C", dir, "for-each-ref", "--format=delete %(refname)", verifyRefPrefix)
+		gitInput(dir, out, "update-ref", "--stdin")
+	}()
+	if output, err := runGitTransfer(ctx, remoteHost(ctx, dir, remote), stream, compareFetchArgs(dir, remote)...); err != nil {
+		return nil, fmt.Errorf("fetching target refs: %v, output: %s", err, lastLine(output))
+	}
+	if c.Target, err = collectRefStats(ctx, dir, verifyRefPrefix, c.DefaultBranch); err != nil {
+		return nil, err
+	}
+
+	skippedBranches, skippedTags := 0, 0
+	for _, ref := range skip {
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			skippedBranches++
+		case strings.HasPrefix(ref, "refs/tags/"):
+			skippedTags++
+		}
+	}
+	if want := c.Source.Branches - skippedBranches; c.Target.Branches != want {
+		c.Mismatches = append(c.Mismatches, fmt.Sprintf("%d branches on target, expected %d", c.Target.Branches, want))
+	}
+	if want := c.Source.Tags - skippedTags; c.Target.Tags != want {
+		c.Mismatches = append(c.Mismatches, fmt.Sprintf("%d tags on target, expected %d", c.Target.Tags, want))
+	}
+	if c.Target.DefaultBranchCommits != c.Source.DefaultBranchCommits {
+		c.Mismatches = append(c.Mismatches, fmt.Sprintf("%d commits on %s on target, expected %d", c.Target.DefaultBranchCommits, c.DefaultBranch, c.Source.DefaultBranchCommits))
+	}
+	if c.Target.LatestCommit != c.Source.LatestCommit {
+		c.Mismatches = append(c.Mismatches, fmt.Sprintf("latest commit on target is from %s, expected %s", orDefault(c.Target.LatestCommit, "never"), c.Source.LatestCommit))
+	}
+	return c, nil
+}
+
+// lines renders the comparison as an aligned source / target table.
+func (c *repoComparison) lines() []string {
+	row := func(label, source, target string) string {
+		return fmt.Sprintf("  %-28s %-26s %s", label, source, target)
+	}
+	return []string{
+		row("", "source", "target"),
+		row("branches", strconv.Itoa(c.Source.Branches), strconv.Itoa(c.Target.Branches)),
+		row("tags", strconv.Itoa(c.Source.Tags), strconv.Itoa(c.Target.Tags)),
+		row("commits on "+c.DefaultBranch, strconv.Itoa(c.Source.DefaultBranchCommits), strconv.Itoa(c.Target.DefaultBranchCommits)),
+		row("latest commit", c.Source.LatestCommit, c.Target.LatestCommit),
+	}
+}
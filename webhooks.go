@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// GitHub repository webhooks are recreated as Azure DevOps service hooks
+// by a content feature, once the repository is migrated: each active hook
+// becomes a Web Hooks subscription to the same URL for every Azure DevOps
+// event its GitHub events map to. The preview lists the subscriptions, and
+// with each the GitHub events that have no equivalent, before any is
+// created. Azure DevOps sends payloads of its own shape, unsigned; GitHub
+// does not reveal a hook's secret, so receivers that check signatures need
+// changing either way.
+
+// webhookEvents maps GitHub webhook events to the Azure DevOps service hook
+// events closest to them.
+var webhookEvents = map[string][]string{
+	"push":                        {"git.push"},
+	"create":                      {"git.push"}, // a new branch or tag is pushed
+	"delete":                      {"git.push"},
+	"pull_request":                {"git.pullrequest.created", "git.pullrequest.updated", "git.pullrequest.merged"},
+	"pull_request_review":         {"git.pullrequest.updated"}, // votes change the pull request
+	"pull_request_review_comment": {"ms.vss-code.git-pullrequest-comment-event"},
+	"issues":                      {"workitem.created", "workitem.updated"},
+	"issue_comment":               {"workitem.commented", "ms.vss-code.git-pullrequest-comment-event"},
+	"workflow_run":                {"build.complete"},
+	"check_suite":                 {"build.complete"},
+}
+
+// gitHubHook is the subset of the GitHub webhook API object the migration
+// uses.
+type gitHubHook struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"` // "web" for webhooks
+	Active bool     `json:"active"`
+	Events []string `json:"events"`
+	Config struct {
+		URL         string `json:"url"`
+		InsecureSSL string `json:"insecure_ssl"`
+	} `json:"config"`
+}
+
+// listGitHubHooks lists repo's webhooks. Listing them takes admin rights
+// on the repository.
+func listGitHubHooks(ctx context.Context, repo, token string) ([]gitHubHook, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/hooks?per_page=100", repo)
+	var hooks []gitHubHook
+	for apiURL != "" {
+		var page []gitHubHook
+		next, err := gitHubGet(ctx, apiURL, token, &page)
+		if err != nil {
+			return hooks, err
+		}
+		hooks = append(hooks, page...)
+		apiURL = next
+	}
+	return hooks, nil
+}
+
+// mapHookEvents returns the Azure DevOps events hook's GitHub events map
+// to, sorted, and the GitHub events that map to none.
+func mapHookEvents(events []string) (mapped, unmapped []string) {
+	seen := map[string]bool{}
+	add := func(list []string) {
+		for _, e := range list {
+			if !seen[e] {
+				seen[e] = true
+				mapped = append(mapped, e)
+			}
+		}
+	}
+	for _, e := range events {
+		if e == "*" {
+			for _, list := range webhookEvents {
+				add(list)
+			}
+			continue
+		}
+		list, ok := webhookEvents[e]
+		if !ok {
+			unmapped = append(unmapped, e)
+			continue
+		}
+		add(list)
+	}
+	sort.Strings(mapped)
+	return mapped, unmapped
+}
+
+// serviceHook is the subset of the service hook subscription API object
+// the migration uses.
+type serviceHook struct {
+	EventType       string            `json:"eventType"`
+	PublisherInputs map[string]string `json:"publisherInputs"`
+	ConsumerInputs  map[string]string `json:"consumerInputs"`
+}
+
+// listServiceHooks lists the organization's Web Hooks subscriptions.
+func listServiceHooks(ctx context.Context, org, token string) ([]serviceHook, error) {
+	apiURL := fmt.Sprintf("%s/_apis/hooks/subscriptions?consumerId=webHooks&consumerActionId=httpRequest&api-version=7.0", org)
+	var result struct {
+		Value []serviceHook `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// hookPublisherInputs filters eventType's events to the repository. Work
+// item and build events cannot be filtered by repository, so their hooks
+// cover the whole project.
+func hookPublisherInputs(eventType string, repo *azureRepo) map[string]string {
+	inputs := map[string]string{"projectId": repo.Project.ID}
+	if strings.HasPrefix(eventType, "git.") || strings.HasPrefix(eventType, "ms.vss-code.") {
+		inputs["repository"] = repo.ID
+	}
+	return inputs
+}
+
+// createServiceHook subscribes hookURL to eventType of the repository.
+func createServiceHook(ctx context.Context, t *azureTarget, repo *azureRepo, eventType, hookURL string, insecure bool) error {
+	consumerInputs := map[string]string{"url": hookURL}
+	if insecure {
+		consumerInputs["acceptUntrustedCerts"] = "true"
+	}
+	payload := map[string]interface{}{
+		"publisherId":      "tfs",
+		"eventType":        eventType,
+		"resourceVersion":  "1.0",
+		"consumerId":       "webHooks",
+		"consumerActionId": "httpRequest",
+		"publisherInputs":  hookPublisherInputs(eventType, repo),
+		"consumerInputs":   consumerInputs,
+	}
+	apiURL := fmt.Sprintf("%s/_apis/hooks/subscriptions?api-version=7.0", t.org)
+	return azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusOK, nil)
+}
+
+// webhooksFeature previews recreating each repository's active GitHub
+// webhooks as service hooks. Subscriptions that already exist for the
+// same event, URL and repository are left alone.
+func webhooksFeature() *contentFeature {
+	return &contentFeature{
+		Name:  "Webhooks as service hooks",
+		OptIn: true,
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			hooks, err := listGitHubHooks(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("listing GitHub webhooks: %v", err)
+			}
+			var active []gitHubHook
+			for _, h := range hooks {
+				if h.Active && h.Name == "web" && h.Config.URL != "" {
+					active = append(active, h)
+				}
+			}
+			if len(active) == 0 {
+				return nil, nil
+			}
+			target, err := getAzureRepo(ctx, t.org, t.project, repo.Target, t.token)
+			if err != nil {
+				return nil, fmt.Errorf("looking up Azure repo: %v", err)
+			}
+			existing, err := listServiceHooks(ctx, t.org, t.token)
+			if err != nil {
+				return nil, fmt.Errorf("listing service hooks: %v", err)
+			}
+			exists := func(eventType, hookURL string) bool {
+				want := hookPublisherInputs(eventType, target)
+				for _, s := range existing {
+					if s.EventType == eventType && s.ConsumerInputs["url"] == hookURL &&
+						strings.EqualFold(s.PublisherInputs["projectId"], want["projectId"]) &&
+						strings.EqualFold(s.PublisherInputs["repository"], want["repository"]) {
+						return true
+					}
+				}
+				return false
+			}
+
+			var ops []*contentOp
+			var unmappable []string
+			for _, h := range active {
+				h := h
+				mapped, unmapped := mapHookEvents(h.Events)
+				if len(mapped) == 0 {
+					unmappable = append(unmappable, fmt.Sprintf("hook %d to %s (%s)", h.ID, h.Config.URL, strings.Join(unmapped, ", ")))
+					continue
+				}
+				detail := fmt.Sprintf("from GitHub hook %d", h.ID)
+				if len(unmapped) > 0 {
+					detail += "; events not mapped: " + strings.Join(unmapped, ", ")
+				}
+				insecure := h.Config.InsecureSSL == "1"
+				if insecure {
+					detail += "; certificate not checked"
+				}
+				for _, eventType := range mapped {
+					eventType := eventType
+					if exists(eventType, h.Config.URL) {
+						continue
+					}
+					ops = append(ops, &contentOp{
+						Change: "+",
+						What:   fmt.Sprintf("service hook %s to %s", eventType, h.Config.URL),
+						Detail: detail,
+						apply: func(ctx context.Context) error {
+							return createServiceHook(ctx, t, target, eventType, h.Config.URL, insecure)
+						},
+					})
+				}
+			}
+			if len(unmappable) > 0 {
+				note := "no event to map for " + strings.Join(unmappable, "; ")
+				if len(ops) == 0 {
+					return nil, errors.New(note)
+				}
+				last := ops[len(ops)-1]
+				last.Detail += "; " + note
+			}
+			return ops, nil
+		},
+	}
+}
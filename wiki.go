@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// A GitHub wiki is a repository of its own, owner/name.wiki.git, and is
+// migrated by a content feature, opted into in the content preview, once
+// the repository itself is migrated. Its history is pushed to a repository
+// of the target project named after the migrated one with wikiRepoSuffix,
+// which is then published as a code wiki: the pages keep their history and
+// stay editable through git as well as the web UI. Page file names carry
+// over as they are; both services show hyphens in them as spaces.
+
+// wikiRepoSuffix is appended to the target repository's name for its
+// wiki's repository, and wikiNameSuffix for the published wiki's name.
+const (
+	wikiRepoSuffix = ".wiki"
+	wikiNameSuffix = "-wiki"
+)
+
+// gitHubWikiHead returns the default branch of repo's wiki and the commit
+// it points to; ok is false if the wiki has no pages, which GitHub serves
+// as no repository at all.
+func gitHubWikiHead(ctx context.Context, repo, token string) (branch, sha string, ok bool, err error) {
+	wikiURL := fmt.Sprintf("https://%s@github.com/%s.wiki.git", token, repo)
+	out, err := runGit(ctx, nil, "ls-remote", "--symref", wikiURL, "HEAD")
+	if err != nil {
+		if repoNotFound(out) {
+			return "", "", false, nil
+		}
+		return "", "", false, fmt.Errorf("%v: %s", err, strings.ReplaceAll(lastLine(out), token, "***"))
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "ref:":
+			branch = strings.TrimPrefix(fields[1], "refs/heads/")
+		case len(fields) == 2 && fields[1] == "HEAD":
+			sha = fields[0]
+		}
+	}
+	if branch == "" || sha == "" {
+		return "", "", false, nil
+	}
+	return branch, sha, true, nil
+}
+
+// azureWiki is the subset of the Azure DevOps wiki API object the
+// migration uses.
+type azureWiki struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	RepositoryID string `json:"repositoryId"`
+	RemoteURL    string `json:"remoteUrl"`
+}
+
+// listAzureWikis lists the project's wikis.
+func listAzureWikis(ctx context.Context, org, project, token string) ([]azureWiki, error) {
+	apiURL := fmt.Sprintf("%s/%s/_apis/wiki/wikis?api-version=7.0", org, url.PathEscape(project))
+	var result struct {
+		Value []azureWiki `json:"value"`
+	}
+	if err := azureRequest(ctx, "GET", apiURL, token, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// pushGitHubWiki pushes every branch of repo's wiki, with its history, to
+// the target repository wikiRepo, creating that first if need be.
+func pushGitHubWiki(ctx context.Context, t *azureTarget, repo, wikiRepo, githubToken string) error {
+	target, _, err := ensureAzureRepo(ctx, t.org, t.project, wikiRepo, t.token)
+	if err != nil {
+		return fmt.Errorf("creating %s: %v", wikiRepo, err)
+	}
+	work, err := os.MkdirTemp("", "gitui-wiki-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	wikiURL := fmt.Sprintf("https://%s@github.com/%s.wiki.git", githubToken, repo)
+	if out, err := runGitTransfer(ctx, "github.com", nil, "clone", "--bare", "--quiet", wikiURL, work); err != nil {
+		return fmt.Errorf("cloning the wiki: %v, output: %s", err, strings.ReplaceAll(lastLine(out), githubToken, "***"))
+	}
+	pushURL := authRemoteURL(ctx, target.RemoteURL, t.token)
+	if out, err := runGitTransfer(ctx, gitHost(pushURL), nil, "-C", work, "push", "--force", pushURL, "refs/heads/*:refs/heads/*"); err != nil {
+		return fmt.Errorf("pushing the wiki: %v, output: %s", err, strings.ReplaceAll(lastLine(out), t.token, "***"))
+	}
+	return nil
+}
+
+// publishCodeWiki publishes branch of the target repository wikiRepo as a
+// code wiki named name.
+func publishCodeWiki(ctx context.Context, t *azureTarget, wikiRepo, branch, name string) error {
+	repo, err := getAzureRepo(ctx, t.org, t.project, wikiRepo, t.token)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %v", wikiRepo, err)
+	}
+	apiURL := fmt.Sprintf("%s/%s/_apis/wiki/wikis?api-version=7.0", t.org, url.PathEscape(t.project))
+	payload := map[string]interface{}{
+		"name":         name,
+		"type":         "codeWiki",
+		"projectId":    repo.Project.ID,
+		"repositoryId": repo.ID,
+		"mappedPath":   "/",
+		"version":      map[string]string{"version": branch},
+	}
+	return azureRequest(ctx, "POST", apiURL, t.token, payload, http.StatusCreated, nil)
+}
+
+// wikiFeature previews migrating each repository's GitHub wiki: pushing
+// its history to the target, where it is missing or behind, and publishing
+// it as a code wiki unless it already is.
+func wikiFeature() *contentFeature {
+	return &contentFeature{
+		Name:  "GitHub wiki",
+		OptIn: true,
+		Preview: func(ctx context.Context, t *azureTarget, repo migratedRepo, githubToken string) ([]*contentOp, error) {
+			meta, err := getGitHubRepo(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("fetching GitHub metadata: %v", err)
+			}
+			if !meta.HasWiki {
+				return nil, nil
+			}
+			branch, sha, ok, err := gitHubWikiHead(ctx, repo.Source, githubToken)
+			if err != nil {
+				return nil, fmt.Errorf("looking up the wiki: %v", err)
+			}
+			if !ok {
+				return nil, nil
+			}
+
+			wikiRepo := repo.Target + wikiRepoSuffix
+			wikiName := repo.Target + wikiNameSuffix
+			var ops []*contentOp
+			pushOp := &contentOp{
+				Change: "+",
+				What:   "repository " + wikiRepo,
+				Detail: fmt.Sprintf("the wiki's history, %s at %s", branch, shortSHA(sha)),
+				apply:  func(ctx context.Context) error { return pushGitHubWiki(ctx, t, repo.Source, wikiRepo, githubToken) },
+			}
+			existing, err := getAzureRepo(ctx, t.org, t.project, wikiRepo, t.token)
+			var apiErr *apiError
+			switch {
+			case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+				ops = append(ops, pushOp)
+			case err != nil:
+				return nil, fmt.Errorf("looking up %s: %v", wikiRepo, err)
+			default:
+				pushed, err := azureBranchSHA(ctx, t, wikiRepo, branch)
+				if err != nil {
+					return nil, fmt.Errorf("looking up %s of %s: %v", branch, wikiRepo, err)
+				}
+				if pushed != sha {
+					pushOp.Change = "~"
+					pushOp.Detail = fmt.Sprintf("%s: %s → %s", branch, orDefault(shortSHA(pushed), "none"), shortSHA(sha))
+					ops = append(ops, pushOp)
+				}
+			}
+
+			wikis, err := listAzureWikis(ctx, t.org, t.project, t.token)
+			if err != nil {
+				return nil, fmt.Errorf("listing wikis: %v", err)
+			}
+			for _, w := range wikis {
+				if existing != nil && w.RepositoryID == existing.ID || strings.EqualFold(w.Name, wikiName) {
+					return ops, nil
+				}
+			}
+			return append(ops, &contentOp{
+				Change: "+",
+				What:   "code wiki " + wikiName,
+				Detail: fmt.Sprintf("published from %s, branch %s", wikiRepo, branch),
+				apply:  func(ctx context.Context) error { return publishCodeWiki(ctx, t, wikiRepo, branch, wikiName) },
+			}), nil
+		},
+	}
+}